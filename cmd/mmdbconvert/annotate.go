@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/maxmind/mmdbconvert/internal/annotate"
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// runAnnotate implements the "annotate" subcommand. It copies a CSV or
+// Parquet file of IP addresses or networks to --output, appending the data
+// columns of the configuration looked up through the merged view for the
+// address in --ip-column. The output has the format of the input.
+func runAnnotate(args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ContinueOnError)
+	var (
+		configPath string
+		inputPath  string
+		outputPath string
+		ipColumn   string
+		workers    int
+		quiet      bool
+	)
+	fs.StringVar(&configPath, "config", "", "Path to TOML configuration file")
+	fs.StringVar(&inputPath, "input", "", "CSV or Parquet file to annotate")
+	fs.StringVar(&outputPath, "output", "", "Path of the annotated file")
+	fs.StringVar(&ipColumn, "ip-column", "ip", "Column holding the IP address or network of each row")
+	fs.IntVar(&workers, "workers", runtime.GOMAXPROCS(0), "Number of lookups running at once")
+	fs.BoolVar(&quiet, "quiet", false, "Suppress progress output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		if fs.NArg() == 0 {
+			return errors.New("config file path required")
+		}
+		configPath = fs.Arg(0)
+	}
+	if inputPath == "" {
+		return errors.New("--input is required")
+	}
+	if outputPath == "" {
+		return errors.New("--output is required")
+	}
+	if workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	if outputPath == inputPath {
+		return errors.New("--output must differ from --input")
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	// #nosec G304 -- path comes from trusted command-line flag
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	isParquet, err := hasParquetMagic(in)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", inputPath, err)
+	}
+
+	if !quiet {
+		fmt.Println("Opening databases:")
+	}
+	readers, err := openReaders(cfg, quiet)
+	if err != nil {
+		return err
+	}
+	defer readers.Close()
+	pool, err := merger.NewLookupPool(readers, cfg, workers)
+	if err != nil {
+		return fmt.Errorf("creating merger: %w", err)
+	}
+	lookup := func(addr netip.Addr) (row.Row, error) {
+		_, values, err := pool.Lookup(addr)
+		return values, err
+	}
+
+	// #nosec G304 -- path comes from trusted command-line flag
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer out.Close()
+	written := false
+	defer func() {
+		// A partial output is not an annotated file
+		if !written {
+			os.Remove(outputPath)
+		}
+	}()
+	buffered := bufio.NewWriter(out)
+
+	opts := annotate.Options{IPColumn: ipColumn, Workers: workers}
+	var stats annotate.Stats
+	if isParquet {
+		stats, err = annotate.Parquet(in, buffered, cfg, opts, lookup)
+	} else {
+		opts.Delimiter = []rune(cfg.Output.CSV.Delimiter)[0]
+		stats, err = annotate.CSV(bufio.NewReader(in), buffered, cfg, opts, lookup)
+	}
+	if err != nil {
+		return fmt.Errorf("annotating %s: %w", inputPath, err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("writing %s: %w", outputPath, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", outputPath, err)
+	}
+	written = true
+
+	if !quiet {
+		fmt.Printf(
+			"Annotated %d rows of %s with %d columns: %s\n",
+			stats.Rows,
+			inputPath,
+			len(cfg.Columns),
+			outputPath,
+		)
+		if stats.Missing > 0 {
+			fmt.Printf("  %d rows had no IP address and were annotated with nulls\n", stats.Missing)
+		}
+	}
+	return nil
+}
+
+// hasParquetMagic reports whether f starts with the Parquet magic string,
+// leaving f at its start.
+func hasParquetMagic(f *os.File) (bool, error) {
+	start := make([]byte, 4)
+	n, err := io.ReadFull(f, start)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(string(start[:n]), "PAR1"), nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/export"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// runCat implements the "cat" subcommand. It reads earlier exports, CSV,
+// Parquet, or merge files, and prints their ranges and data columns as CSV
+// or JSON lines, in the same form whatever the format they were written in.
+func runCat(args []string) error {
+	fs := flag.NewFlagSet("cat", flag.ContinueOnError)
+	var (
+		configPath string
+		format     string
+	)
+	fs.StringVar(
+		&configPath,
+		"config",
+		"",
+		"Configuration the exports were written with, for their network columns and CSV settings",
+	)
+	fs.StringVar(&format, "format", "csv", "Output format: csv or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("export file path required")
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unknown output format '%s', must be csv or json", format)
+	}
+
+	var opts export.Options
+	if configPath != "" {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		opts = export.OptionsFromConfig(cfg)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	var p catPrinter = &jsonCatPrinter{enc: json.NewEncoder(out)}
+	if format == "csv" {
+		p = &csvCatPrinter{w: csv.NewWriter(out)}
+	}
+
+	var columns []string
+	for i, path := range fs.Args() {
+		r, err := export.Open(path, opts)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			columns = r.Columns()
+		} else if !slices.Equal(r.Columns(), columns) {
+			r.Close()
+			return fmt.Errorf("the columns of %s differ from those of %s", path, fs.Arg(0))
+		}
+		err = catExport(p, r, i == 0)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func catExport(p catPrinter, r row.Reader, header bool) error {
+	if header {
+		if err := p.header(r.Columns()); err != nil {
+			return err
+		}
+	}
+	for {
+		start, end, data, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.row(start, end, data); err != nil {
+			return err
+		}
+	}
+}
+
+// catPrinter prints the ranges read by cat.
+type catPrinter interface {
+	header(columns []string) error
+	row(start, end netip.Addr, data row.Row) error
+	flush() error
+}
+
+// csvCatPrinter prints a start_ip and an end_ip column followed by the data
+// columns. Null values are empty.
+type csvCatPrinter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (p *csvCatPrinter) header(columns []string) error {
+	return p.w.Write(append([]string{"start_ip", "end_ip"}, columns...))
+}
+
+func (p *csvCatPrinter) row(start, end netip.Addr, data row.Row) error {
+	p.record = append(p.record[:0], start.String(), end.String())
+	for _, value := range data {
+		if value == nil {
+			p.record = append(p.record, "")
+			continue
+		}
+		s, err := row.FormatValue(value)
+		if err != nil {
+			return err
+		}
+		p.record = append(p.record, s)
+	}
+	return p.w.Write(p.record)
+}
+
+func (p *csvCatPrinter) flush() error {
+	p.w.Flush()
+	return p.w.Error()
+}
+
+// jsonCatPrinter prints an object per range, with the data columns under
+// "data".
+type jsonCatPrinter struct {
+	enc     *json.Encoder
+	columns []string
+}
+
+type catRange struct {
+	Start string         `json:"start"`
+	End   string         `json:"end"`
+	Data  map[string]any `json:"data"`
+}
+
+func (p *jsonCatPrinter) header(columns []string) error {
+	p.columns = columns
+	return nil
+}
+
+func (p *jsonCatPrinter) row(start, end netip.Addr, data row.Row) error {
+	values := make(map[string]any, len(data))
+	for i, name := range p.columns {
+		values[name] = data.Interface(i)
+	}
+	return p.enc.Encode(catRange{Start: start.String(), End: end.String(), Data: values})
+}
+
+func (*jsonCatPrinter) flush() error {
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/maxmind/mmdbconvert/internal/compare"
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+// compareReport is the JSON form of a compare run.
+type compareReport struct {
+	Left          string                `json:"left"`
+	Right         string                `json:"right"`
+	Families      []compareFamilyReport `json:"families"`
+	Disagreements []compareDisagreement `json:"largest_disagreements"`
+}
+
+type compareFamilyReport struct {
+	Family        string                  `json:"family"`
+	AgreementRate float64                 `json:"agreement_rate"`
+	Outcomes      map[string]compareTally `json:"outcomes"`
+}
+
+type compareTally struct {
+	Ranges    uint64 `json:"ranges"`
+	Addresses string `json:"addresses"`
+}
+
+type compareDisagreement struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Addresses string `json:"addresses"`
+	Left      string `json:"left"`
+	Right     string `json:"right"`
+}
+
+// runCompare implements the "compare" subcommand. It merges the databases
+// behind two configured columns and reports how often their values agree,
+// along with the largest ranges where they disagree.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	var (
+		configPath string
+		left       string
+		right      string
+		top        int
+		format     string
+	)
+	fs.StringVar(&configPath, "config", "", "Path to TOML configuration file")
+	fs.StringVar(&left, "left", "", "First data column to compare")
+	fs.StringVar(&right, "right", "", "Second data column to compare")
+	fs.IntVar(&top, "top", 10, "Number of largest disagreeing ranges to report")
+	fs.StringVar(&format, "format", "text", "Report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		if fs.NArg() == 0 {
+			return errors.New("config file path required")
+		}
+		configPath = fs.Arg(0)
+	}
+	if left == "" || right == "" {
+		return errors.New("both --left and --right columns are required")
+	}
+	if left == right {
+		return errors.New("--left and --right must name different columns")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown report format '%s', must be text or json", format)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	// Merge only the two compared columns so that ranges are as large as
+	// possible and unrelated columns don't fragment them.
+	leftCol, err := findColumn(cfg, left)
+	if err != nil {
+		return err
+	}
+	rightCol, err := findColumn(cfg, right)
+	if err != nil {
+		return err
+	}
+	cfg.Columns = []config.Column{leftCol, rightCol}
+
+	readers, err := openMerge(cfg, "compare")
+	if err != nil {
+		return err
+	}
+	defer readers.Close()
+
+	comparator := compare.NewComparator(0, 1, top)
+	_, _, _, err = mergeDatabases(
+		cfg,
+		readers,
+		comparator,
+		&runMonitors{},
+		nil,
+		true,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	report := buildCompareReport(comparator, leftCol, rightCol)
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding report: %w", err)
+		}
+		return nil
+	}
+	return writeCompareText(os.Stdout, report)
+}
+
+func findColumn(cfg *config.Config, name string) (config.Column, error) {
+	for _, col := range cfg.Columns {
+		if string(col.Name) == name {
+			return col, nil
+		}
+	}
+	return config.Column{}, fmt.Errorf("column '%s' is not a configured data column", name)
+}
+
+func buildCompareReport(
+	c *compare.Comparator,
+	left, right config.Column,
+) compareReport {
+	report := compareReport{
+		Left:  fmt.Sprintf("%s (%s)", left.Name, left.Database),
+		Right: fmt.Sprintf("%s (%s)", right.Name, right.Database),
+	}
+
+	for _, ipv4 := range []bool{true, false} {
+		fr := compareFamilyReport{
+			Family:        "ipv6",
+			AgreementRate: c.AgreementRate(ipv4),
+			Outcomes:      map[string]compareTally{},
+		}
+		if ipv4 {
+			fr.Family = "ipv4"
+		}
+		var ranges uint64
+		for _, o := range []compare.Outcome{
+			compare.Agree, compare.Disagree, compare.LeftOnly, compare.RightOnly,
+		} {
+			t := c.Tally(ipv4, o)
+			ranges += t.Ranges
+			fr.Outcomes[o.String()] = compareTally{
+				Ranges:    t.Ranges,
+				Addresses: t.Addresses.String(),
+			}
+		}
+		if ranges > 0 {
+			report.Families = append(report.Families, fr)
+		}
+	}
+
+	for _, d := range c.LargestDisagreements() {
+		report.Disagreements = append(report.Disagreements, compareDisagreement{
+			Start:     d.Start.String(),
+			End:       d.End.String(),
+			Addresses: d.Size.String(),
+			Left:      d.Left,
+			Right:     d.Right,
+		})
+	}
+	return report
+}
+
+func writeCompareText(w io.Writer, report compareReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Comparing %s with %s\n\n", report.Left, report.Right)
+	for _, fr := range report.Families {
+		fmt.Fprintf(
+			tw,
+			"%s: %s agreement where both have data\n",
+			fr.Family,
+			formatPercent(fr.AgreementRate),
+		)
+		fmt.Fprintln(tw, "  OUTCOME\tRANGES\tADDRESSES\t")
+		for _, name := range []string{"agree", "disagree", "left_only", "right_only"} {
+			t := fr.Outcomes[name]
+			fmt.Fprintf(tw, "  %s\t%d\t%s\t\n", name, t.Ranges, t.Addresses)
+		}
+		fmt.Fprintln(tw)
+	}
+	if len(report.Disagreements) > 0 {
+		fmt.Fprintln(tw, "Largest disagreeing ranges:")
+		fmt.Fprintln(tw, "  START\tEND\tADDRESSES\tLEFT\tRIGHT\t")
+		for _, d := range report.Disagreements {
+			fmt.Fprintf(
+				tw,
+				"  %s\t%s\t%s\t%s\t%s\t\n",
+				d.Start, d.End, d.Addresses, d.Left, d.Right,
+			)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
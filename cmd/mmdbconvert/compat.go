@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/maxmind/mmdbconvert/internal/compat"
+)
+
+// runCompatCheck decodes a sample of the generated MMDB with the geoip2-golang
+// record structs and fails if any field cannot be read as intended.
+func runCompatCheck(path string, samples int, quiet bool) error {
+	if !quiet {
+		fmt.Println()
+		fmt.Println("Checking compatibility with geoip2-golang...")
+	}
+
+	report, err := compat.CheckGeoIP2(path, samples)
+	if err != nil {
+		return fmt.Errorf("compatibility check: %w", err)
+	}
+
+	if len(report.Problems) > 0 {
+		if err := writeCompatProblems(os.Stderr, report); err != nil {
+			return err
+		}
+		return fmt.Errorf(
+			"compatibility check failed: %d fields cannot be read by %s",
+			len(report.Problems),
+			report.RecordType,
+		)
+	}
+
+	if !quiet {
+		fmt.Printf(
+			"  %d networks decoded as %s without problems\n",
+			report.Sampled,
+			report.RecordType,
+		)
+	}
+	return nil
+}
+
+func writeCompatProblems(w io.Writer, report *compat.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(
+		tw,
+		"Fields that %s cannot read (%d networks sampled):\n",
+		report.RecordType,
+		report.Sampled,
+	)
+	fmt.Fprintln(tw, "  FIELD\tNETWORKS\tEXAMPLE\tPROBLEM\t")
+	for _, p := range report.Problems {
+		fmt.Fprintf(tw, "  %s\t%d\t%s\t%s\t\n", p.Field, p.Count, p.Network, p.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing compatibility report: %w", err)
+	}
+	return nil
+}
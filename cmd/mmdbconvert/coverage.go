@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/coverage"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// runCoverage implements the "coverage" subcommand. It runs the configured
+// merge without writing output and reports, per database and per column, the
+// share of the address space and of emitted rows carrying non-null data.
+func runCoverage(args []string) error {
+	fs := flag.NewFlagSet("coverage", flag.ContinueOnError)
+	var (
+		configPath string
+		groupBy    string
+		format     string
+	)
+	fs.StringVar(&configPath, "config", "", "Path to TOML configuration file")
+	fs.StringVar(&groupBy, "by", "", "Data column to break the report down by (e.g., a country column)")
+	fs.StringVar(&format, "format", "text", "Report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		if fs.NArg() == 0 {
+			return errors.New("config file path required")
+		}
+		configPath = fs.Arg(0)
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown report format '%s', must be text or json", format)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	readers, err := openMerge(cfg, "coverage")
+	if err != nil {
+		return err
+	}
+	defer readers.Close()
+
+	collector, err := coverage.NewCollector(cfg, groupBy, emitsRanges(cfg))
+	if err != nil {
+		return err
+	}
+
+	_, _, _, err = mergeDatabases(
+		cfg,
+		readers,
+		collector,
+		&runMonitors{},
+		nil,
+		true,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	report := collector.Report()
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding report: %w", err)
+		}
+		return nil
+	}
+	return writeCoverageText(os.Stdout, report)
+}
+
+// emitsRanges reports whether the configured output writes one row per
+// accumulated range rather than one row per CIDR.
+func emitsRanges(cfg *config.Config) bool {
+	if cfg.Output.Format != "csv" {
+		return false
+	}
+	for _, col := range cfg.Network.Columns {
+		switch col.Type {
+		case writer.NetworkColumnStartIP, writer.NetworkColumnEndIP,
+			writer.NetworkColumnStartInt, writer.NetworkColumnEndInt,
+			writer.NetworkColumnStartDecimal, writer.NetworkColumnEndDecimal,
+			writer.NetworkColumnValidFrom, writer.NetworkColumnValidTo:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func writeCoverageText(w io.Writer, report coverage.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, fr := range report.Families {
+		writeFamilyText(tw, fr, "")
+		for _, gr := range fr.Groups {
+			writeFamilyText(tw, gr, "  ")
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+func writeFamilyText(w io.Writer, fr coverage.FamilyReport, indent string) {
+	title := string(fr.Family)
+	if fr.Group != nil {
+		group := *fr.Group
+		if group == "" {
+			group = "(null)"
+		}
+		title = group
+	}
+	fmt.Fprintf(
+		w,
+		"%s%s: %d rows, %s of address space\n",
+		indent,
+		title,
+		fr.Rows,
+		formatPercent(fr.AddressFraction),
+	)
+	fmt.Fprintf(w, "%s  KIND\tNAME\tDATABASE\tADDRESSES\tROWS\t\n", indent)
+	for _, e := range fr.Databases {
+		fmt.Fprintf(
+			w,
+			"%s  database\t%s\t%s\t%s\t%s\t\n",
+			indent, e.Name, e.Name,
+			formatPercent(e.AddressFraction), formatPercent(e.RowFraction),
+		)
+	}
+	for _, e := range fr.Columns {
+		fmt.Fprintf(
+			w,
+			"%s  column\t%s\t%s\t%s\t%s\t\n",
+			indent, e.Name, e.Database,
+			formatPercent(e.AddressFraction), formatPercent(e.RowFraction),
+		)
+	}
+	fmt.Fprintln(w)
+}
+
+func formatPercent(f float64) string {
+	if f != 0 && f < 0.0001 {
+		return fmt.Sprintf("%.3g%%", f*100)
+	}
+	return fmt.Sprintf("%.2f%%", f*100)
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/trace"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// startPprofServer serves the net/http/pprof endpoints on addr under
+// /debug/pprof/ until the process exits, so that a long run can be profiled
+// while it is in progress. It returns once the address is bound.
+func startPprofServer(addr string) (net.Addr, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Warning: pprof server stopped: %v\n", err)
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+// startTrace writes a runtime execution trace to path until the returned
+// function is called.
+func startTrace(path string) (func() error, error) {
+	// #nosec G304 -- trace path comes from trusted command-line flag
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating trace file: %w", err)
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("starting trace: %w", err)
+	}
+	return func() error {
+		trace.Stop()
+		return f.Close()
+	}, nil
+}
+
+// stageTimer records the time spent in each stage of a run.
+type stageTimer struct {
+	stages []stageTime
+	start  time.Time
+}
+
+type stageTime struct {
+	name    string
+	elapsed time.Duration
+}
+
+// Start ends the current stage, if any, and starts the stage name.
+func (t *stageTimer) Start(name string) {
+	t.Stop()
+	t.stages = append(t.stages, stageTime{name: name})
+	t.start = time.Now()
+}
+
+// Stop ends the current stage.
+func (t *stageTimer) Stop() {
+	if t.start.IsZero() {
+		return
+	}
+	t.stages[len(t.stages)-1].elapsed = time.Since(t.start)
+	t.start = time.Time{}
+}
+
+// writeTimings reports the time spent in each stage and, if stats is not
+// nil, in each step of the merge per database.
+func writeTimings(w io.Writer, t *stageTimer, stats *merger.Stats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Time by stage:")
+	for _, s := range t.stages {
+		fmt.Fprintf(tw, "  %s\t%v\t\n", s.name, s.elapsed.Round(time.Microsecond))
+	}
+	if stats != nil {
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "Merge breakdown (%d networks):\n", stats.Networks)
+		fmt.Fprintln(tw, "  DATABASE\tITERATE\tDECODE\tPATH WALK\t")
+		for _, db := range stats.Databases {
+			fmt.Fprintf(
+				tw,
+				"  %s\t%v\t%v\t%v\t\n",
+				db.Name,
+				db.Iterate.Round(time.Microsecond),
+				db.Decode.Round(time.Microsecond),
+				db.Walk.Round(time.Microsecond),
+			)
+		}
+		fmt.Fprintf(
+			tw,
+			"  Accumulating ranges: %v, writing rows: %v\n",
+			stats.Accumulate.Round(time.Microsecond),
+			stats.Write.Round(time.Microsecond),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing timings: %w", err)
+	}
+	return nil
+}
+
+// writeMergeBreaks reports the columns whose values differed between
+// adjacent networks that were not merged, the columns breaking the most
+// merges on their own first, as they are the ones worth dropping or setting
+// merge_ignore on.
+func writeMergeBreaks(w io.Writer, breaks *merger.MergeBreaks) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Merge breaks (%d adjacent networks not merged):\n", breaks.Total)
+	columns := sortedMergeBreaks(breaks)
+	if len(columns) == 0 {
+		fmt.Fprintln(tw, "  none")
+	} else {
+		fmt.Fprintln(tw, "  COLUMN\tBREAKS\tALONE\t")
+		for _, col := range columns {
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t\n", col.Name, col.Breaks, col.Sole)
+		}
+		fmt.Fprintln(tw, "  Breaks a column made alone would merge without it or with merge_ignore.")
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing merge breaks: %w", err)
+	}
+	return nil
+}
+
+// sortedMergeBreaks returns the columns that broke merges, by the breaks
+// they made alone and then by all their breaks, most first.
+func sortedMergeBreaks(breaks *merger.MergeBreaks) []merger.ColumnBreaks {
+	var columns []merger.ColumnBreaks
+	for _, col := range breaks.Columns {
+		if col.Breaks > 0 {
+			columns = append(columns, col)
+		}
+	}
+	slices.SortStableFunc(columns, func(a, b merger.ColumnBreaks) int {
+		if c := cmp.Compare(b.Sole, a.Sole); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Breaks, a.Breaks)
+	})
+	return columns
+}
+
+// mergeBreaksSummary is the merge breaks of each column, as reported in the
+// summary of a run.
+type mergeBreaksSummary struct {
+	Total   int64                `json:"total"`
+	Columns []columnBreakSummary `json:"columns"`
+}
+
+type columnBreakSummary struct {
+	Name   string `json:"name"`
+	Breaks int64  `json:"breaks"`
+	Alone  int64  `json:"alone"`
+}
+
+// summaryMergeBreaks returns the summary of breaks, or nil if they were not
+// counted.
+func summaryMergeBreaks(breaks *merger.MergeBreaks) *mergeBreaksSummary {
+	if breaks == nil {
+		return nil
+	}
+	summary := &mergeBreaksSummary{Total: breaks.Total, Columns: []columnBreakSummary{}}
+	for _, col := range sortedMergeBreaks(breaks) {
+		summary.Columns = append(summary.Columns, columnBreakSummary{
+			Name:   col.Name,
+			Breaks: col.Breaks,
+			Alone:  col.Sole,
+		})
+	}
+	return summary
+}
+
+// resourceUsage is the resources used by a run, as reported in its summary.
+type resourceUsage struct {
+	// PeakRSS is the peak resident set size of the process, or 0 where it
+	// is unknown. It is read from /proc, so only Linux is supported.
+	PeakRSS      uint64 `json:"peak_rss_bytes,omitempty"`
+	TotalAlloc   uint64 `json:"total_alloc_bytes"` // Bytes allocated on the heap during the run
+	GCCycles     uint32 `json:"gc_cycles"`         // Garbage collections during the run
+	BytesWritten int64  `json:"bytes_written"`     // Bytes written to the output files
+}
+
+// resourceMeter measures the resources used from its creation on.
+type resourceMeter struct {
+	start   runtime.MemStats
+	written atomic.Int64
+}
+
+func newResourceMeter() *resourceMeter {
+	m := &resourceMeter{}
+	runtime.ReadMemStats(&m.start)
+	return m
+}
+
+// wrapOutput returns the function wrapping output files to count the bytes
+// written to them, after wrapping them with inner if it is not nil.
+func (m *resourceMeter) wrapOutput(inner func(io.Writer) io.Writer) func(io.Writer) io.Writer {
+	return func(w io.Writer) io.Writer {
+		if inner != nil {
+			w = inner(w)
+		}
+		return &countingWriter{w: w, n: &m.written}
+	}
+}
+
+// usage returns the resources used so far.
+func (m *resourceMeter) usage() resourceUsage {
+	var now runtime.MemStats
+	runtime.ReadMemStats(&now)
+	return resourceUsage{
+		PeakRSS:      readPeakRSS(),
+		TotalAlloc:   now.TotalAlloc - m.start.TotalAlloc,
+		GCCycles:     now.NumGC - m.start.NumGC,
+		BytesWritten: m.written.Load(),
+	}
+}
+
+// countingWriter adds the bytes written to w to n.
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// Sync commits the wrapped writer to stable storage if it supports it.
+func (c *countingWriter) Sync() error {
+	if syncer, ok := c.w.(row.Syncer); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// readPeakRSS returns the peak resident set size of the process from the
+// VmHWM line of /proc/self/status, or 0 if it cannot be read.
+func readPeakRSS() uint64 {
+	if runtime.GOOS != "linux" {
+		return 0
+	}
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	return parsePeakRSS(string(data))
+}
+
+func parsePeakRSS(status string) uint64 {
+	for line := range strings.Lines(status) {
+		value, ok := strings.CutPrefix(line, "VmHWM:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
+
+// writeResourceUsage reports the resources used by a run.
+func writeResourceUsage(w io.Writer, u resourceUsage) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Resource usage:")
+	if u.PeakRSS > 0 {
+		fmt.Fprintf(tw, "  Peak RSS\t%s\t\n", formatSize(int64(min(u.PeakRSS, math.MaxInt64))))
+	}
+	fmt.Fprintf(tw, "  Allocated\t%s\t\n", formatSize(int64(min(u.TotalAlloc, math.MaxInt64))))
+	fmt.Fprintf(tw, "  GC cycles\t%d\t\n", u.GCCycles)
+	fmt.Fprintf(tw, "  Written\t%s\t\n", formatSize(u.BytesWritten))
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing resource usage: %w", err)
+	}
+	return nil
+}
+
+// runSummary is the JSON form of the end-of-run summary, written by
+// --summary-json.
+type runSummary struct {
+	Version     string           `json:"version"`
+	Elapsed     float64          `json:"elapsed_seconds"`
+	Outputs     []string         `json:"outputs"`
+	Databases   []databaseHealth `json:"databases,omitempty"`    // Health of each database when opened
+	SkippedRows int              `json:"skipped_rows,omitempty"` // Rows in output.ignore_errors networks
+	// SkippedInserts are the networks MMDB output could not insert, with
+	// output.mmdb.on_insert_error = "skip"
+	SkippedInserts int `json:"skipped_inserts,omitempty"`
+	// EmptyColumns are the data columns without a value in any row
+	EmptyColumns []string `json:"empty_columns,omitempty"`
+	// MergeBreaks are the merge breaks of each column, with --merge-breaks
+	MergeBreaks *mergeBreaksSummary `json:"merge_breaks,omitempty"`
+	// QASample describes the sample written with --qa-sample
+	QASample *qaSampleSummary `json:"qa_sample,omitempty"`
+	// Warnings are the non-fatal problems found during the run
+	Warnings  []runWarning   `json:"warnings,omitempty"`
+	Stages    []stageSummary `json:"stages"`
+	Resources resourceUsage  `json:"resources"`
+}
+
+type stageSummary struct {
+	Name    string  `json:"name"`
+	Elapsed float64 `json:"elapsed_seconds"`
+}
+
+// summaryStages returns the time spent in each stage recorded by t.
+func summaryStages(t *stageTimer) []stageSummary {
+	stages := make([]stageSummary, len(t.stages))
+	for i, s := range t.stages {
+		stages[i] = stageSummary{Name: s.name, Elapsed: s.elapsed.Seconds()}
+	}
+	return stages
+}
+
+// writeSummaryJSON writes s to path.
+func writeSummaryJSON(path string, s runSummary) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing run summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/merger"
+)
+
+func TestStartPprofServer(t *testing.T) {
+	addr, err := startPprofServer("127.0.0.1:0")
+	require.NoError(t, err)
+
+	resp, err := http.Get("http://" + addr.String() + "/debug/pprof/goroutine?debug=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = startPprofServer(addr.String())
+	require.Error(t, err, "the address is in use")
+}
+
+func TestStartTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.trace")
+	stop, err := startTrace(path)
+	require.NoError(t, err)
+	require.NoError(t, stop())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Positive(t, info.Size())
+}
+
+func TestWriteTimings(t *testing.T) {
+	timer := &stageTimer{}
+	timer.Start("open_databases")
+	timer.Start("merge")
+	timer.Stop()
+	require.Len(t, timer.stages, 2)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeTimings(&buf, timer, nil))
+	assert.Contains(t, buf.String(), "  open_databases")
+	assert.Contains(t, buf.String(), "  merge")
+	assert.NotContains(t, buf.String(), "Merge breakdown")
+
+	buf.Reset()
+	stats := &merger.Stats{
+		Databases: []merger.DatabaseStats{
+			{Name: "city", Iterate: 3 * time.Second, Decode: 2 * time.Second, Walk: time.Second},
+		},
+		Accumulate: 500 * time.Millisecond,
+		Write:      4 * time.Second,
+		Networks:   1000,
+	}
+	require.NoError(t, writeTimings(&buf, timer, stats))
+	assert.Contains(t, buf.String(), "Merge breakdown (1000 networks):")
+	assert.Regexp(t, `city +3s +2s +1s`, buf.String())
+	assert.Contains(t, buf.String(), "Accumulating ranges: 500ms, writing rows: 4s")
+}
+
+func TestWriteMergeBreaks(t *testing.T) {
+	breaks := &merger.MergeBreaks{
+		Total: 30,
+		Columns: []merger.ColumnBreaks{
+			{Name: "country", Breaks: 12, Sole: 2},
+			{Name: "asn", Breaks: 0},
+			{Name: "city", Breaks: 25, Sole: 15},
+			{Name: "postal_code", Breaks: 20, Sole: 2},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeMergeBreaks(&buf, breaks))
+	assert.Regexp(
+		t,
+		`Merge breaks \(30 adjacent networks not merged\):\n`+
+			` +COLUMN +BREAKS +ALONE +\n`+
+			` +city +25 +15 +\n`+
+			` +postal_code +20 +2 +\n`+
+			` +country +12 +2 +\n`,
+		buf.String(),
+	)
+	assert.NotContains(t, buf.String(), "asn")
+
+	summary := summaryMergeBreaks(breaks)
+	require.Len(t, summary.Columns, 3)
+	assert.Equal(t, columnBreakSummary{Name: "city", Breaks: 25, Alone: 15}, summary.Columns[0])
+	assert.Nil(t, summaryMergeBreaks(nil))
+}
+
+func TestResourceMeter(t *testing.T) {
+	meter := newResourceMeter()
+	var out bytes.Buffer
+	w := meter.wrapOutput(nil)(&out)
+	_, err := w.Write(make([]byte, 1500))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+
+	usage := meter.usage()
+	assert.Equal(t, int64(1503), usage.BytesWritten)
+	assert.Positive(t, usage.TotalAlloc)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeResourceUsage(&buf, resourceUsage{
+		TotalAlloc:   2_500_000,
+		GCCycles:     7,
+		BytesWritten: 1503,
+	}))
+	assert.Regexp(t, `Allocated +2\.5 MB`, buf.String())
+	assert.Regexp(t, `GC cycles +7`, buf.String())
+	assert.Regexp(t, `Written +1\.5 kB`, buf.String())
+	assert.NotContains(t, buf.String(), "Peak RSS", "unknown peak RSS is left out")
+}
+
+func TestParsePeakRSS(t *testing.T) {
+	status := "Name:\tmmdbconvert\nVmPeak:\t  812344 kB\nVmHWM:\t   20480 kB\nVmRSS:\t   19000 kB\n"
+	assert.Equal(t, uint64(20480*1024), parsePeakRSS(status))
+	assert.Zero(t, parsePeakRSS("Name:\tmmdbconvert\n"))
+}
+
+func TestWriteSummaryJSON(t *testing.T) {
+	timer := &stageTimer{}
+	timer.Start("merge")
+	timer.Stop()
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	require.NoError(t, writeSummaryJSON(path, runSummary{
+		Version:   "1.2.3",
+		Elapsed:   1.5,
+		Outputs:   []string{"out.csv"},
+		Stages:    summaryStages(timer),
+		Resources: resourceUsage{PeakRSS: 4096, TotalAlloc: 100, GCCycles: 2, BytesWritten: 10},
+	}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var summary map[string]any
+	require.NoError(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, "1.2.3", summary["version"])
+	assert.Equal(t, []any{"out.csv"}, summary["outputs"])
+	assert.NotContains(t, summary, "skipped_rows")
+	assert.Equal(t, "merge", summary["stages"].([]any)[0].(map[string]any)["name"])
+	assert.Equal(t, map[string]any{
+		"peak_rss_bytes":    float64(4096),
+		"total_alloc_bytes": float64(100),
+		"gc_cycles":         float64(2),
+		"bytes_written":     float64(10),
+	}, summary["resources"])
+}
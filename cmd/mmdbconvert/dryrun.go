@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/estimate"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+)
+
+// runDryRun estimates the size of the configured export without writing it.
+func runDryRun(w io.Writer, cfg *config.Config, readers *mmdb.Readers) error {
+	est, err := estimate.Run(readers, cfg, estimate.DefaultSampleRanges)
+	if err != nil {
+		return fmt.Errorf("estimating output: %w", err)
+	}
+	return writeEstimate(w, cfg, est)
+}
+
+func writeEstimate(w io.Writer, cfg *config.Config, est *estimate.Estimate) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Dry run: no output written")
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "  DATABASE\tNODES\tNETWORKS\t")
+	for _, db := range est.Databases {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t\n", db.Name, db.Nodes, db.Networks)
+	}
+	fmt.Fprintln(tw)
+
+	approx := "~"
+	if est.Exact {
+		fmt.Fprintln(tw, "Output (the sample covered the whole merge):")
+		approx = ""
+	} else {
+		fmt.Fprintf(
+			tw,
+			"Estimated output (sampled %s of %s's networks):\n",
+			formatPercent(est.Sampled),
+			est.Databases[0].Name,
+		)
+	}
+	fmt.Fprintf(tw, "  Rows: %s%d\n", approx, est.Rows)
+	if est.Bytes == 0 {
+		fmt.Fprintf(tw, "  Size: not estimated for %s output\n", cfg.Output.Format)
+	} else {
+		fmt.Fprintf(tw, "  Size: %s%s\n", approx, formatSize(est.Bytes))
+	}
+	if maxSize := cfg.Output.CSV.MaxFileSize; maxSize > 0 && est.Bytes > 0 {
+		fmt.Fprintf(
+			tw,
+			"  Parts of at most %s: %s%d\n",
+			formatSize(maxSize),
+			approx,
+			est.Parts(maxSize),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing estimate: %w", err)
+	}
+	return nil
+}
+
+// formatSize formats a number of bytes with a decimal unit.
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	for _, suffix := range []string{"kB", "MB", "GB", "TB"} {
+		value /= unit
+		if value < unit || suffix == "TB" {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return ""
+}
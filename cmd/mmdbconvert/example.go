@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxmind/mmdbconvert/internal/example"
+)
+
+// exampleOutputLines is the number of output lines the example command
+// shows.
+const exampleOutputLines = 10
+
+// runExample implements the "example" subcommand. It writes a commented
+// configuration and a miniature sample database for an edition to a new
+// directory, runs the configuration, and shows the output with the next
+// steps.
+func runExample(args []string) error {
+	fs := flag.NewFlagSet("example", flag.ContinueOnError)
+	var (
+		edition string
+		dir     string
+	)
+	fs.StringVar(
+		&edition,
+		"edition",
+		"GeoLite2-City",
+		"Edition to write the example for ("+strings.Join(example.Editions(), ", ")+")",
+	)
+	fs.StringVar(&dir, "dir", "mmdbconvert-example", "Directory to write the example to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := example.Write(dir, edition)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s and %s to %s\n\n", files.Config, files.Database, dir)
+
+	// The paths of the configuration are relative to its directory
+	if err := inDir(dir, func() error {
+		return run(context.Background(), files.Config, runOptions{quiet: true})
+	}); err != nil {
+		return fmt.Errorf("running the example: %w", err)
+	}
+
+	output := filepath.Join(dir, files.Output)
+	fmt.Printf("%s:\n\n", output)
+	if err := printHead(os.Stdout, output, exampleOutputLines); err != nil {
+		return err
+	}
+	fmt.Printf(`
+Next steps:
+    cd %s
+    # Edit config.toml, then convert again
+    mmdbconvert --config %s
+    # Point the database path at a full %s database to convert it
+`, dir, files.Config, edition)
+	return nil
+}
+
+// inDir runs fn with dir as the working directory.
+func inDir(dir string, fn func() error) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	fnErr := fn()
+	if err := os.Chdir(wd); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// printHead writes the first n lines of the file at path to w, indented.
+func printHead(w io.Writer, path string, n int) error {
+	f, err := os.Open(path) // #nosec G304 -- path is the example output
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < n && scanner.Scan(); i++ {
+		fmt.Fprintf(w, "    %s\n", scanner.Text())
+	}
+	return scanner.Err()
+}
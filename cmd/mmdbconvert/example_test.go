@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExample(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "example")
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	require.NoError(t, runExample([]string{"--edition", "GeoLite2-City", "--dir", dir}))
+
+	after, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, wd, after, "working directory restored")
+
+	data, err := os.ReadFile(filepath.Join(dir, "GeoLite2-City.csv"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "81.2.69.140/30,EU,GB,United Kingdom,ENG,England,2643743,London,")
+
+	// A second run must not overwrite the example
+	require.ErrorContains(t, runExample([]string{"--dir", dir}), "already exists")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+)
+
+// fixture describes a small MMDB built for the golden tests.
+type fixture struct {
+	name     string
+	options  mmdbwriter.Options
+	networks map[string]mmdbtype.DataType // Records by network
+}
+
+// sharedNames is inserted under several records so that the writer stores
+// it once and the records refer to it through pointers.
+var sharedNames = mmdbtype.Map{
+	"de": mmdbtype.String("Vereinigte Staaten"),
+	"en": mmdbtype.String("United States"),
+}
+
+// sharedRecord is inserted for several networks, which the writer also
+// deduplicates.
+var sharedRecord = mmdbtype.Map{
+	"country": mmdbtype.Map{
+		"iso_code": mmdbtype.String("US"),
+		"names":    sharedNames,
+	},
+	"location": mmdbtype.Map{
+		"latitude":  mmdbtype.Float64(37.751),
+		"longitude": mmdbtype.Float64(-97.822),
+	},
+}
+
+// goldenFixtures are the databases used by the golden tests. They are kept
+// small and built at test time so that each edge case is visible here.
+var goldenFixtures = []fixture{
+	{
+		// An IPv6 tree with IPv4 data, reachable through the default
+		// IPv4 aliases, and a few IPv6 networks.
+		name: "geo",
+		options: mmdbwriter.Options{
+			DatabaseType: "Golden-Geo",
+			IPVersion:    6,
+			RecordSize:   28,
+		},
+		networks: map[string]mmdbtype.DataType{
+			"1.0.0.0/24": sharedRecord,
+			"1.0.1.0/24": sharedRecord,
+			"1.0.2.0/23": mmdbtype.Map{
+				"country": mmdbtype.Map{
+					"iso_code": mmdbtype.String("CA"),
+					"names":    mmdbtype.Map{"en": mmdbtype.String("Canada")},
+				},
+				"registered_country": mmdbtype.Map{
+					"iso_code": mmdbtype.String("US"),
+					"names":    sharedNames,
+				},
+				"subdivisions": mmdbtype.Slice{
+					mmdbtype.Map{"iso_code": mmdbtype.String("ON")},
+					mmdbtype.Map{"iso_code": mmdbtype.String("QC")},
+				},
+			},
+			"2a02:1000::/32": mmdbtype.Map{
+				"country": mmdbtype.Map{"iso_code": mmdbtype.String("DE")},
+				"deep": mmdbtype.Map{"a": mmdbtype.Map{"b": mmdbtype.Slice{
+					mmdbtype.Map{"c": mmdbtype.Map{"d": mmdbtype.String("bottom")}},
+				}}},
+			},
+			"2a02:1001::/33": sharedRecord,
+		},
+	},
+	{
+		// A database whose networks split the ranges of geo.
+		name: "anon",
+		options: mmdbwriter.Options{
+			DatabaseType: "Golden-Anonymous",
+			IPVersion:    6,
+			RecordSize:   24,
+		},
+		networks: map[string]mmdbtype.DataType{
+			"1.0.0.128/25": mmdbtype.Map{
+				"is_anonymous": mmdbtype.Bool(true),
+				"is_tor":       mmdbtype.Bool(false),
+			},
+			"1.0.3.0/24": mmdbtype.Map{
+				"is_anonymous": mmdbtype.Bool(true),
+				"is_tor":       mmdbtype.Bool(true),
+			},
+		},
+	},
+	{
+		// An IPv6 tree without IPv4 aliases whose data is stored under
+		// IPv4-mapped addresses. IPv4 networks are looked up in ::/96, so
+		// this data is not merged into them.
+		name: "mapped",
+		options: mmdbwriter.Options{
+			DatabaseType:        "Golden-Mapped",
+			IPVersion:           6,
+			RecordSize:          24,
+			DisableIPv4Aliasing: true,
+		},
+		networks: map[string]mmdbtype.DataType{
+			"::ffff:1.0.1.0/120": mmdbtype.Map{
+				"asn": mmdbtype.Uint32(13335),
+			},
+		},
+	},
+}
+
+// buildFixtures writes every golden fixture to dir and returns their paths
+// by name.
+func buildFixtures(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	paths := make(map[string]string, len(goldenFixtures))
+	for _, f := range goldenFixtures {
+		paths[f.name] = buildFixture(t, dir, f)
+	}
+	return paths
+}
+
+// buildFixture writes f to dir, named after it, and returns its path.
+func buildFixture(t *testing.T, dir string, f fixture) string {
+	t.Helper()
+
+	path := filepath.Join(dir, f.name+".mmdb")
+	mmdbtest.Write(t, path, f.options, f.networks)
+	return path
+}
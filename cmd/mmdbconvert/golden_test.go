@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenColumns are the data columns shared by the golden cases. They cover
+// a database with an IPv4-mapped network, records shared through pointers,
+// and paths into deeply nested maps and arrays.
+const goldenColumns = `
+[[databases]]
+name = "geo"
+path = "$geo"
+
+[[databases]]
+name = "anon"
+path = "$anon"
+
+[[databases]]
+name = "mapped"
+path = "$mapped"
+
+[[columns]]
+name = "country_code"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "country_name"
+database = "geo"
+path = ["country", "names", "en"]
+
+[[columns]]
+name = "latitude"
+database = "geo"
+path = ["location", "latitude"]
+
+[[columns]]
+name = "last_subdivision"
+database = "geo"
+path = ["subdivisions", -1, "iso_code"]
+
+[[columns]]
+name = "deep"
+database = "geo"
+path = ["deep", "a", "b", 0, "c", "d"]
+
+[[columns]]
+name = "is_anonymous"
+database = "anon"
+path = ["is_anonymous"]
+
+[[columns]]
+name = "asn"
+database = "mapped"
+path = ["asn"]
+`
+
+// goldenCase is a full conversion whose output is compared against
+// testdata/golden/<name>.golden. Paths in config are written as $<fixture>
+// and $out.
+type goldenCase struct {
+	name   string
+	config string
+	dump   func(t *testing.T, path string) []byte
+}
+
+var goldenCases = []goldenCase{
+	{
+		name: "csv",
+		config: `
+[output]
+format = "csv"
+file = "$out"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[network.columns]]
+name = "start_ip"
+type = "start_ip"
+` + goldenColumns,
+		dump: readFile,
+	},
+	{
+		name: "csv_range",
+		config: `
+[output]
+format = "csv"
+file = "$out"
+
+[[network.columns]]
+name = "start_ip"
+type = "start_ip"
+
+[[network.columns]]
+name = "end_ip"
+type = "end_ip"
+` + goldenColumns,
+		dump: readFile,
+	},
+	{
+		name: "parquet",
+		config: `
+[output]
+format = "parquet"
+file = "$out"
+
+[output.parquet]
+compression = "none"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[network.columns]]
+name = "end_ip"
+type = "end_ip"
+` + goldenColumns,
+		dump: dumpParquet,
+	},
+	{
+		name: "mmdb",
+		config: `
+[output]
+format = "mmdb"
+file = "$out"
+
+[output.mmdb]
+database_type = "Golden-Merged"
+` + goldenColumns,
+		dump: dumpMMDB,
+	},
+}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	paths := buildFixtures(t, dir)
+
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runGolden(t, paths, tc, runOptions{quiet: true})
+		})
+	}
+}
+
+// TestGolden_Check runs the golden cases with --check, whose checks must
+// pass without changing the output.
+func TestGolden_Check(t *testing.T) {
+	dir := t.TempDir()
+	paths := buildFixtures(t, dir)
+
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runGolden(t, paths, tc, runOptions{quiet: true, check: true})
+		})
+	}
+}
+
+// runGolden runs the configuration of tc on the fixtures at paths with opts
+// and compares the output with the golden file.
+func runGolden(t *testing.T, paths map[string]string, tc goldenCase, opts runOptions) {
+	t.Helper()
+
+	out := filepath.Join(t.TempDir(), "out")
+	replacements := []string{"$out", out}
+	for name, path := range paths {
+		replacements = append(replacements, "$"+name, path)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(
+		configPath,
+		[]byte(strings.NewReplacer(replacements...).Replace(tc.config)),
+		0o600,
+	))
+
+	require.NoError(t, run(t.Context(), configPath, opts))
+	assertGolden(t, tc.name, tc.dump(t, out))
+}
+
+// TestGolden_AdditionalOutputs writes the csv and mmdb golden outputs from
+// one merge, with a third output selecting a single column.
+func TestGolden_AdditionalOutputs(t *testing.T) {
+	dir := t.TempDir()
+	paths := buildFixtures(t, dir)
+	out := t.TempDir()
+
+	config := `
+[output]
+format = "csv"
+file = "$out/csv"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[network.columns]]
+name = "start_ip"
+type = "start_ip"
+
+[[outputs]]
+format = "mmdb"
+file = "$out/mmdb"
+network_columns = []
+
+[outputs.mmdb]
+database_type = "Golden-Merged"
+
+[[outputs]]
+format = "csv"
+file = "$out/country_code"
+columns = ["country_code"]
+network_columns = ["network"]
+` + goldenColumns
+	replacements := []string{"$out", out}
+	for name, path := range paths {
+		replacements = append(replacements, "$"+name, path)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(
+		configPath,
+		[]byte(strings.NewReplacer(replacements...).Replace(config)),
+		0o600,
+	))
+
+	require.NoError(t, run(t.Context(), configPath, runOptions{quiet: true}))
+	assertGolden(t, "csv", readFile(t, filepath.Join(out, "csv")))
+	assertGolden(t, "mmdb", dumpMMDB(t, filepath.Join(out, "mmdb")))
+	assertGolden(t, "outputs_country_code", readFile(t, filepath.Join(out, "country_code")))
+}
+
+// TestGolden_Tenants writes the csv and mmdb golden outputs as the outputs
+// of two tenants, from one merge.
+func TestGolden_Tenants(t *testing.T) {
+	dir := t.TempDir()
+	paths := buildFixtures(t, dir)
+	out := t.TempDir()
+
+	config := `
+[output]
+format = "csv"
+file = "$out/{tenant}"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[network.columns]]
+name = "start_ip"
+type = "start_ip"
+` + goldenColumns
+	replacements := []string{"$out", out}
+	for name, path := range paths {
+		replacements = append(replacements, "$"+name, path)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(
+		configPath,
+		[]byte(strings.NewReplacer(replacements...).Replace(config)),
+		0o600,
+	))
+
+	tenants := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tenants, "csv.toml"), nil, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(tenants, "merged.toml"), []byte(`
+name = "mmdb"
+
+[output]
+format = "mmdb"
+
+[output.mmdb]
+database_type = "Golden-Merged"
+`), 0o600))
+
+	require.NoError(t, run(t.Context(), configPath, runOptions{
+		quiet:   true,
+		tenants: filepath.Join(tenants, "*.toml"),
+	}))
+	assertGolden(t, "csv", readFile(t, filepath.Join(out, "csv")))
+	assertGolden(t, "mmdb", dumpMMDB(t, filepath.Join(out, "mmdb")))
+	// The base configuration itself writes no output
+	assert.NoFileExists(t, filepath.Join(out, "{tenant}"))
+}
+
+// assertGolden compares got against the golden file for name, rewriting it
+// instead when -update is set.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "golden", name+".golden")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, got, 0o600))
+		return
+	}
+
+	// #nosec G304 -- path is built from the test case name
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run 'go test ./cmd/mmdbconvert -run TestGolden -update' to create it")
+	assert.Equal(t, string(want), string(got))
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	// #nosec G304 -- path is the output of the test conversion
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
+
+// dumpParquet renders the schema and every row of a Parquet file as text.
+// Byte arrays that are not valid UTF-8, such as integer IPv6 addresses, are
+// written in hex.
+func dumpParquet(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data := readFile(t, path)
+	pf, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, pf.Schema())
+	fields := pf.Schema().Fields()
+	for _, rg := range pf.RowGroups() {
+		rows := rg.Rows()
+		batch := make([]parquet.Row, 16)
+		for {
+			n, err := rows.ReadRows(batch)
+			for _, r := range batch[:n] {
+				values := make([]string, 0, len(r))
+				for _, v := range r {
+					values = append(values, fields[v.Column()].Name()+"="+formatParquetValue(v))
+				}
+				fmt.Fprintln(&buf, strings.Join(values, " "))
+			}
+			if err != nil {
+				break
+			}
+		}
+		require.NoError(t, rows.Close())
+	}
+	return buf.Bytes()
+}
+
+func formatParquetValue(v parquet.Value) string {
+	switch {
+	case v.IsNull():
+		return "null"
+	case v.Kind() == parquet.ByteArray || v.Kind() == parquet.FixedLenByteArray:
+		b := v.ByteArray()
+		if utf8.Valid(b) {
+			return strconv.Quote(string(b))
+		}
+		return "0x" + hex.EncodeToString(b)
+	default:
+		return v.String()
+	}
+}
+
+// dumpMMDB renders the metadata that does not change between builds and
+// every network of an MMDB file, with its record as JSON.
+func dumpMMDB(t *testing.T, path string) []byte {
+	t.Helper()
+
+	reader, err := maxminddb.Open(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(
+		&buf,
+		"database_type=%s ip_version=%d record_size=%d\n",
+		reader.Metadata.DatabaseType,
+		reader.Metadata.IPVersion,
+		reader.Metadata.RecordSize,
+	)
+	for result := range reader.Networks() {
+		require.NoError(t, result.Err())
+		var record any
+		require.NoError(t, result.Decode(&record))
+		encoded, err := json.Marshal(record)
+		require.NoError(t, err)
+		fmt.Fprintf(&buf, "%s %s\n", result.Prefix(), encoded)
+	}
+	return buf.Bytes()
+}
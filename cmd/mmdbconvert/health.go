@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+)
+
+// databaseHealth describes a database as opened, so that stale or corrupt
+// inputs show before the merge rather than after it.
+type databaseHealth struct {
+	Name          string `json:"name"`
+	Path          string `json:"path"`
+	DatabaseType  string `json:"database_type"`
+	BuildEpoch    uint   `json:"build_epoch"`
+	AgeDays       int    `json:"age_days"` // Days between the build and the run
+	RecordSize    uint   `json:"record_size"`
+	NodeCount     uint   `json:"node_count"`
+	FormatVersion string `json:"binary_format_version"`
+	Verified      bool   `json:"verified,omitempty"` // Passed the checks of verify = true
+}
+
+// checkDatabases reports the health of every configured database,
+// verifying those with verify set, and fails on the first that does not
+// pass. A file opened under several names is verified once.
+func checkDatabases(
+	cfg *config.Config,
+	readers *mmdb.Readers,
+	now time.Time,
+	quiet bool,
+) ([]databaseHealth, error) {
+	var (
+		health   []databaseHealth
+		verified []*mmdb.Reader
+	)
+	if !quiet {
+		fmt.Println("Checking MMDB databases...")
+	}
+	for _, db := range cfg.Databases {
+		reader, ok := readers.Get(db.Name)
+		if !ok {
+			continue
+		}
+		metadata := reader.Metadata()
+		built := time.Unix(int64(metadata.BuildEpoch), 0).UTC()
+		h := databaseHealth{
+			Name:         db.Name,
+			Path:         db.Path,
+			DatabaseType: metadata.DatabaseType,
+			BuildEpoch:   metadata.BuildEpoch,
+			AgeDays:      int(now.Sub(built).Hours() / 24),
+			RecordSize:   metadata.RecordSize,
+			NodeCount:    metadata.NodeCount,
+			FormatVersion: fmt.Sprintf(
+				"%d.%d",
+				metadata.BinaryFormatMajorVersion,
+				metadata.BinaryFormatMinorVersion,
+			),
+		}
+		if db.Verify {
+			shared := false
+			for _, other := range verified {
+				shared = shared || reader.SharesFile(other)
+			}
+			if !shared {
+				if err := reader.Verify(); err != nil {
+					return nil, fmt.Errorf("database '%s' (%s) is corrupt: %w", db.Name, db.Path, err)
+				}
+				verified = append(verified, reader)
+			}
+			h.Verified = true
+		}
+		health = append(health, h)
+
+		if !quiet {
+			fmt.Printf(
+				"  - %s: %s built %s (%d days ago), %d-bit records, %d nodes, format %s",
+				db.Name,
+				h.DatabaseType,
+				built.Format(time.DateOnly),
+				h.AgeDays,
+				h.RecordSize,
+				h.NodeCount,
+				h.FormatVersion,
+			)
+			if h.Verified {
+				fmt.Print(", verified")
+			}
+			fmt.Println()
+		}
+	}
+	return health, nil
+}
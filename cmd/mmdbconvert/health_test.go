@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+// describedFixture is the geo fixture with the description that verifying
+// a database requires.
+func describedFixture() fixture {
+	f := goldenFixtures[0]
+	f.options.Description = map[string]string{"en": "Golden Geo"}
+	return f
+}
+
+func TestCheckDatabases(t *testing.T) {
+	path := buildFixture(t, t.TempDir(), describedFixture())
+
+	cfg := &config.Config{Databases: []config.Database{
+		{Name: "geo", Path: path, Verify: true},
+		{Name: "alias", Path: path, Verify: true},
+		{Name: "unverified", Path: path},
+	}}
+	readers, err := openReaders(cfg, true)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	geo, _ := readers.Get("geo")
+	built := time.Unix(int64(geo.Metadata().BuildEpoch), 0)
+	health, err := checkDatabases(cfg, readers, built.Add(50*time.Hour), true)
+	require.NoError(t, err)
+	require.Len(t, health, 3)
+	assert.Equal(t, "geo", health[0].Name)
+	assert.Equal(t, "Golden-Geo", health[0].DatabaseType)
+	assert.Equal(t, 2, health[0].AgeDays)
+	assert.Equal(t, uint(28), health[0].RecordSize)
+	assert.Positive(t, health[0].NodeCount)
+	assert.Equal(t, "2.0", health[0].FormatVersion)
+	assert.True(t, health[0].Verified)
+	assert.True(t, health[1].Verified)
+	assert.False(t, health[2].Verified)
+}
+
+func TestCheckDatabases_Corrupt(t *testing.T) {
+	path := buildFixture(t, t.TempDir(), describedFixture())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// Overwrite the data section, which the file opens without reading
+	end := bytes.LastIndex(data, []byte("\xab\xcd\xefMaxMind.com"))
+	copy(data[end-64:end], bytes.Repeat([]byte{0xff}, 64))
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	cfg := &config.Config{Databases: []config.Database{{Name: "geo", Path: path}}}
+	readers, err := openReaders(cfg, true)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	// Only verified databases are checked
+	_, err = checkDatabases(cfg, readers, time.Now(), true)
+	require.NoError(t, err)
+
+	cfg.Databases[0].Verify = true
+	_, err = checkDatabases(cfg, readers, time.Now(), true)
+	require.ErrorContains(t, err, "database 'geo' ("+path+") is corrupt")
+}
+
+func TestOpenReaders_Edition(t *testing.T) {
+	path := buildFixture(t, t.TempDir(), selftestFixtures[0])
+
+	cfg := &config.Config{Databases: []config.Database{
+		{Name: "city", Path: path, Edition: "GeoIP2-City"},
+	}}
+	readers, err := openReaders(cfg, true)
+	require.NoError(t, err)
+	require.NoError(t, readers.Close())
+
+	cfg.Databases[0].Edition = "GeoLite2-ASN"
+	_, err = openReaders(cfg, true)
+	require.EqualError(
+		t,
+		err,
+		"database 'city' ("+path+") has database type 'GeoIP2-City', not edition GeoLite2-ASN",
+	)
+}
+
+func TestOpenMerge(t *testing.T) {
+	path := buildFixture(t, t.TempDir(), describedFixture())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	end := bytes.LastIndex(data, []byte("\xab\xcd\xefMaxMind.com"))
+	copy(data[end-64:end], bytes.Repeat([]byte{0xff}, 64))
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	cfg := &config.Config{Databases: []config.Database{{Name: "geo", Path: path}}}
+	readers, err := openMerge(cfg, "coverage")
+	require.NoError(t, err)
+	require.NoError(t, readers.Close())
+
+	// Databases are checked as for run
+	cfg.Databases[0].Verify = true
+	_, err = openMerge(cfg, "coverage")
+	require.ErrorContains(t, err, "database 'geo' ("+path+") is corrupt")
+
+	cfg.Databases[0].Verify = false
+	cfg.Databases[0].History = []string{path}
+	_, err = openMerge(cfg, "coverage")
+	require.EqualError(
+		t,
+		err,
+		"coverage is not supported with database history (database 'geo')",
+	)
+}
@@ -1,49 +1,220 @@
-// mmdbconvert merges multiple MaxMind MMDB databases and exports to CSV, Parquet, or MMDB format.
+// mmdbconvert merges multiple MaxMind MMDB databases and exports to CSV, Parquet, MMDB, or
+// reverse DNS zone format.
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"maps"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"runtime/pprof"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/maxmind/mmdbconvert/internal/compat"
 	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/edition"
+	"github.com/maxmind/mmdbconvert/internal/heartbeat"
+	"github.com/maxmind/mmdbconvert/internal/history"
 	"github.com/maxmind/mmdbconvert/internal/merger"
 	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/premerge"
+	"github.com/maxmind/mmdbconvert/internal/provenance"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/telemetry"
+	"github.com/maxmind/mmdbconvert/internal/throttle"
+	"github.com/maxmind/mmdbconvert/internal/verify"
 	"github.com/maxmind/mmdbconvert/internal/writer"
 )
 
 const version = "0.1.0"
 
+// subcommands maps subcommand names to their entry points. Each entry point
+// receives the arguments following the subcommand name and returns an error
+// to report before exiting with a non-zero status.
+var subcommands = map[string]func(args []string) error{
+	"annotate":  runAnnotate,
+	"cat":       runCat,
+	"compare":   runCompare,
+	"coverage":  runCoverage,
+	"example":   runExample,
+	"netset":    runNetset,
+	"re-export": runReexport,
+	"selftest":  runSelftest,
+	"spotcheck": runSpotcheck,
+	"validate":  runValidate,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Define command-line flags
 	var (
 		configPath   string
 		quiet        bool
+		verbose      bool
+		dryRun       bool
+		redact       bool
+		check        bool
+		tenants      string
+		saveMerge    string
+		summaryJSON  string
+		warningsJSON string
+		qaSample     string
+		qaRate       float64
+		mergeBreaks  bool
+		traceNetwork string
+		traceFile    string
 		showHelp     bool
 		showVer      bool
 		cpuprofile   string
 		memprofile   string
+		pprofAddr    string
+		tracePath    string
 		disableCache bool
+		compatCheck  string
+		compatSample int
+		throttle     throttleOptions
 	)
 
 	flag.StringVar(&configPath, "config", "", "Path to TOML configuration file")
 	flag.BoolVar(&quiet, "quiet", false, "Suppress progress output")
+	flag.BoolVar(&verbose, "verbose", false, "Report the merge time per database and step")
+	flag.BoolVar(&verbose, "v", false, "Shorthand for --verbose")
+	flag.BoolVar(
+		&dryRun,
+		"dry-run",
+		false,
+		"Estimate the output rows and size from a sample of the merge without writing output",
+	)
+	flag.BoolVar(
+		&redact,
+		"redact",
+		false,
+		"Apply the redact policies of the data columns, for an export to share",
+	)
+	flag.BoolVar(
+		&check,
+		"check",
+		false,
+		"Verify the networks of every row before it is written, failing on any that would be wrong",
+	)
+	flag.StringVar(
+		&tenants,
+		"tenants",
+		"",
+		"Write the outputs of each tenant overlay matching this pattern instead of [output] (e.g. 'tenants/*.toml')",
+	)
+	flag.StringVar(
+		&saveMerge,
+		"save-merge",
+		"",
+		"Also save the merged rows to this merge file, for the re-export command",
+	)
+	flag.StringVar(
+		&summaryJSON,
+		"summary-json",
+		"",
+		"Also write the end-of-run summary, with stage timings and resource usage, as JSON to this file",
+	)
+	flag.StringVar(
+		&warningsJSON,
+		"warnings-json",
+		"",
+		"Also write the warnings of the run, even a failed one, as JSON to this file",
+	)
+	flag.StringVar(
+		&qaSample,
+		"qa-sample",
+		"",
+		"Also write a random sample of the rows of [output] to this CSV file, for review",
+	)
+	flag.Float64Var(
+		&qaRate,
+		"qa-rate",
+		0.001,
+		"Share of the rows copied to the --qa-sample file",
+	)
+	flag.BoolVar(
+		&mergeBreaks,
+		"merge-breaks",
+		false,
+		"Report the data columns whose differing values kept the most adjacent networks from merging",
+	)
+	flag.StringVar(
+		&traceNetwork,
+		"trace-network",
+		"",
+		"Record how the merge builds the rows of these comma-separated networks (e.g. 81.2.69.0/24)",
+	)
+	flag.StringVar(
+		&traceFile,
+		"trace-network-file",
+		"network-trace.json",
+		"File to write the --trace-network trace to, as JSON",
+	)
 	flag.BoolVar(&showHelp, "help", false, "Show usage information")
 	flag.BoolVar(&showVer, "version", false, "Show version information")
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "Write CPU profile to file")
 	flag.StringVar(&memprofile, "memprofile", "", "Write memory profile to file")
+	flag.StringVar(
+		&pprofAddr,
+		"pprof-addr",
+		"",
+		"Serve net/http/pprof endpoints on this address during the run (e.g. localhost:6060)",
+	)
+	flag.StringVar(&tracePath, "trace", "", "Write runtime execution trace to file")
 	flag.BoolVar(
 		&disableCache,
 		"disable-cache",
 		false,
 		"Disable MMDB unmarshaler caching to reduce memory usage (several times slower)",
 	)
+	flag.StringVar(
+		&compatCheck,
+		"compat-check",
+		"",
+		"Verify MMDB output decodes with a client library's record structs (supported: geoip2)",
+	)
+	flag.IntVar(
+		&compatSample,
+		"compat-samples",
+		1000,
+		"Number of networks to decode for --compat-check (0 checks every network)",
+	)
+	flag.IntVar(
+		&throttle.networksPerSecond,
+		"throttle-networks",
+		0,
+		"Maximum networks written per second (0 for no limit)",
+	)
+	flag.IntVar(
+		&throttle.writeBytesPerSecond,
+		"throttle-write-bytes",
+		0,
+		"Maximum bytes written to the output per second (0 for no limit)",
+	)
+	flag.Float64Var(
+		&throttle.maxLoad,
+		"throttle-max-load",
+		0,
+		"Pause while the one-minute load average is above this value (0 to never pause, Linux only)",
+	)
 
 	flag.Usage = usage
 	flag.Parse()
@@ -60,6 +231,16 @@ func main() {
 		os.Exit(0)
 	}
 
+	if compatCheck != "" && compatCheck != compat.ClientGeoIP2 {
+		fmt.Fprintf(
+			os.Stderr,
+			"Error: unknown --compat-check client '%s', must be: %s\n",
+			compatCheck,
+			compat.ClientGeoIP2,
+		)
+		os.Exit(1)
+	}
+
 	// Get config path from positional argument if not specified with flag
 	if configPath == "" {
 		if flag.NArg() == 0 {
@@ -87,8 +268,65 @@ func main() {
 		}
 	}
 
+	if pprofAddr != "" {
+		addr, err := startPprofServer(pprofAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting pprof server: %v\n", err)
+			os.Exit(1)
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Serving pprof on http://%s/debug/pprof/\n", addr)
+		}
+	}
+
+	var stopTrace func() error
+	if tracePath != "" {
+		var err error
+		stopTrace, err = startTrace(tracePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	ctx := context.Background()
+	shutdownTracing, err := telemetry.Setup(ctx, version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up tracing: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Run the conversion
-	runErr := run(configPath, quiet, disableCache)
+	runErr := run(ctx, configPath, runOptions{
+		quiet:         quiet,
+		verbose:       verbose,
+		dryRun:        dryRun,
+		redact:        redact,
+		check:         check,
+		tenants:       tenants,
+		saveMerge:     saveMerge,
+		summaryJSON:   summaryJSON,
+		warningsJSON:  warningsJSON,
+		qaSample:      qaSample,
+		qaRate:        qaRate,
+		mergeBreaks:   mergeBreaks,
+		traceNetwork:  traceNetwork,
+		traceFile:     traceFile,
+		disableCache:  disableCache,
+		compatCheck:   compatCheck,
+		compatSamples: compatSample,
+		throttle:      throttle,
+	})
+
+	if err := shutdownTracing(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: exporting traces: %v\n", err)
+	}
+
+	if stopTrace != nil {
+		if err := stopTrace(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: writing trace: %v\n", err)
+		}
+	}
 
 	// Stop CPU profiling and close file before potentially exiting
 	if cpuProfileFile != nil {
@@ -119,9 +357,108 @@ func main() {
 	}
 }
 
-// run performs the main conversion process.
-func run(configPath string, quiet, disableCache bool) error {
+// runOptions holds the command-line settings for a conversion.
+type runOptions struct {
+	quiet         bool
+	verbose       bool    // Break the merge time down by database and step
+	dryRun        bool    // Estimate the output instead of writing it
+	redact        bool    // Apply the redact policies of the columns
+	check         bool    // Verify the networks of every row written
+	tenants       string  // Pattern of the tenant overlays to write outputs for
+	saveMerge     string  // Merge file to save the merged rows to
+	summaryJSON   string  // File to write the JSON form of the summary to
+	warningsJSON  string  // File to write the warnings to, even if the run fails
+	qaSample      string  // CSV file to write a random sample of the rows of [output] to
+	qaRate        float64 // Share of the rows to sample
+	mergeBreaks   bool    // Count the merge breaks of each column
+	traceNetwork  string  // Comma-separated networks to trace the merge of
+	traceFile     string  // File to write the trace to
+	disableCache  bool
+	compatCheck   string // Client library to verify MMDB output against
+	compatSamples int
+	throttle      throttleOptions
+}
+
+// throttleOptions bounds the resources used by a conversion. Zero values
+// leave a resource unbounded.
+type throttleOptions struct {
+	networksPerSecond   int
+	writeBytesPerSecond int
+	maxLoad             float64
+}
+
+// wrapOutput returns the function wrapping output files to limit the write
+// rate, or nil if the rate is not limited. The limit applies to all output
+// files together.
+func (t throttleOptions) wrapOutput() func(io.Writer) io.Writer {
+	if t.writeBytesPerSecond <= 0 {
+		return nil
+	}
+	limiter := throttle.NewLimiter(float64(t.writeBytesPerSecond))
+	return func(w io.Writer) io.Writer {
+		return throttle.NewIOWriter(w, limiter)
+	}
+}
+
+// wrapRows wraps w so that rows are written at the configured rate and not
+// while the load average is too high.
+func (t throttleOptions) wrapRows(w row.Writer, quiet bool) (row.Writer, error) {
+	var limiter *throttle.Limiter
+	if t.networksPerSecond > 0 {
+		limiter = throttle.NewLimiter(float64(t.networksPerSecond))
+	}
+	var guard *throttle.LoadGuard
+	if t.maxLoad > 0 {
+		var err error
+		guard, err = throttle.NewLoadGuard(t.maxLoad)
+		if err != nil {
+			return nil, fmt.Errorf("--throttle-max-load: %w", err)
+		}
+		if !quiet {
+			guard.OnPause = func(load float64) {
+				fmt.Printf("  Pausing: load average %.2f is above %.2f\n", load, t.maxLoad)
+			}
+		}
+	}
+	if limiter == nil && guard == nil {
+		return w, nil
+	}
+	return throttle.NewWriter(w, limiter, guard), nil
+}
+
+// validate checks that no limit is negative.
+func (t throttleOptions) validate() error {
+	switch {
+	case t.networksPerSecond < 0:
+		return errors.New("--throttle-networks cannot be negative")
+	case t.writeBytesPerSecond < 0:
+		return errors.New("--throttle-write-bytes cannot be negative")
+	case t.maxLoad < 0:
+		return errors.New("--throttle-max-load cannot be negative")
+	}
+	return nil
+}
+
+// run performs the main conversion process, tracing each stage as a child
+// of the span in ctx.
+func run(ctx context.Context, configPath string, opts runOptions) (err error) {
 	startTime := time.Now()
+	meter := newResourceMeter()
+	quiet := opts.quiet
+	timer := &stageTimer{}
+	timer.Start("load_config")
+
+	ctx, span := telemetry.Start(ctx, "run")
+	defer func() { telemetry.End(span, err) }()
+
+	warnings := &warningLog{}
+	if opts.warningsJSON != "" {
+		defer func() {
+			if writeErr := writeWarningsJSON(opts.warningsJSON, warnings); writeErr != nil && err == nil {
+				err = writeErr
+			}
+		}()
+	}
 
 	if !quiet {
 		fmt.Printf("mmdbconvert v%s\n", version)
@@ -129,22 +466,94 @@ func run(configPath string, quiet, disableCache bool) error {
 	}
 
 	// Load configuration
+	_, loadSpan := telemetry.Start(ctx, "load_config")
 	cfg, err := config.LoadConfig(configPath)
+	if err == nil {
+		loadSpan.SetAttributes(
+			telemetry.AttrFormat.String(cfg.Output.Format),
+			telemetry.AttrDatabases.Int(len(cfg.Databases)),
+			telemetry.AttrColumns.Int(len(cfg.Columns)),
+		)
+	}
+	telemetry.End(loadSpan, err)
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
+	warnCaseCollisions(cfg, warnings)
 
 	// Override DisableCache from command-line flag if provided
 	// Command-line flag takes precedence over config file
-	if disableCache {
+	if opts.disableCache {
 		cfg.DisableCache = true
 	}
 
+	// Tenants replace the outputs of the configuration with their own
+	outputs := []outputConfig{{cfg: cfg, redact: opts.redact}}
+	if opts.tenants != "" {
+		outputs, err = loadTenants(configPath, opts.tenants, opts.redact)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, out := range outputs {
+		if out.redact && !hasRedactPolicy(cfg) {
+			return errRedactWithoutPolicy
+		}
+		if opts.compatCheck != "" && out.cfg.Output.Format != "mmdb" {
+			return errors.New("--compat-check is only supported for mmdb output")
+		}
+	}
+	if _, ok := cfg.HistoryDatabase(); ok && opts.mergeBreaks {
+		return errors.New("--merge-breaks is not supported with database history")
+	}
+	traceNetworks, err := parseTraceNetworks(opts.traceNetwork)
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.HistoryDatabase(); ok && len(traceNetworks) > 0 {
+		return errors.New("--trace-network is not supported with database history")
+	}
+	if err := opts.throttle.validate(); err != nil {
+		return err
+	}
+	if err := validateQASample(opts.qaSample, opts.qaRate, cfg, opts.tenants); err != nil {
+		return err
+	}
+
+	monitors := &runMonitors{}
+	if cfg.Heartbeat.File != "" {
+		monitors.heartbeat, err = heartbeat.Start(
+			cfg.Heartbeat.File,
+			time.Duration(cfg.Heartbeat.EverySeconds)*time.Second,
+		)
+		if err != nil {
+			return fmt.Errorf("starting heartbeat: %w", err)
+		}
+		defer func() {
+			if stopErr := monitors.heartbeat.Stop(err); stopErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", stopErr)
+			}
+		}()
+	}
+	monitors.startWatchdog(cfg, &meter.written)
+	defer monitors.watchdog.Stop()
+
 	if !quiet {
-		fmt.Printf("Output format: %s\n", cfg.Output.Format)
-		if cfg.Output.File != "" {
+		switch {
+		case opts.tenants != "":
+			fmt.Printf("Tenants: %d\n", len(outputs))
+		case cfg.Output.Format == "postgres":
+			fmt.Printf("Output format: %s\n", cfg.Output.Format)
+			fmt.Printf("Output table: %s\n", cfg.Output.Postgres.Table)
+		case cfg.Output.Format == "bigquery":
+			fmt.Printf("Output format: %s\n", cfg.Output.Format)
+			fmt.Printf("Output table: %s\n", bigQueryTable(cfg))
+		case cfg.Output.File != "":
+			fmt.Printf("Output format: %s\n", cfg.Output.Format)
 			fmt.Printf("Output file: %s\n", cfg.Output.File)
-		} else {
+		default:
+			fmt.Printf("Output format: %s\n", cfg.Output.Format)
 			fmt.Printf("Output files: IPv4=%s, IPv6=%s\n", cfg.Output.IPv4File, cfg.Output.IPv6File)
 		}
 		fmt.Printf("Databases: %d\n", len(cfg.Databases))
@@ -158,33 +567,127 @@ func run(configPath string, quiet, disableCache bool) error {
 		fmt.Println("Opening MMDB databases...")
 	}
 
-	databases := make(map[string]config.Database, len(cfg.Databases))
-	for _, db := range cfg.Databases {
-		databases[db.Name] = db
-		if !quiet {
-			fmt.Printf("  - %s: %s (priority: %d)\n", db.Name, db.Path, db.Priority)
+	monitors.SetStage("open_databases")
+	timer.Start("open_databases")
+	_, openSpan := telemetry.Start(ctx, "open_databases")
+	readers, err := openReaders(cfg, quiet)
+	telemetry.End(openSpan, err)
+	if err != nil {
+		return err
+	}
+	defer readers.Close()
+	health, err := checkDatabases(cfg, readers, startTime, quiet)
+	if err != nil {
+		return err
+	}
+	recordProvenance(cfg, readers, opts.redact)
+	if opts.tenants != "" {
+		for _, out := range outputs {
+			recordProvenance(out.cfg, readers, out.redact)
 		}
 	}
 
-	readers, err := mmdb.OpenDatabases(databases)
-	if err != nil {
-		return fmt.Errorf("opening databases: %w", err)
+	timer.Start("premerge")
+	if err := premergeDatabases(cfg, readers, warnings, quiet); err != nil {
+		return err
 	}
-	defer readers.Close()
 
-	if err := validateParquetNetworkColumns(cfg, readers); err != nil {
+	timer.Start("prepare_output")
+	src := databaseSources{cfg: cfg, readers: readers}
+	if err := validateParquetNetworkColumns(cfg, src); err != nil {
 		return fmt.Errorf("validating network columns: %w", err)
 	}
 
-	rowWriter, closers, outputPaths, err := prepareRowWriter(cfg, readers, quiet)
-	if err != nil {
-		return err
+	if opts.dryRun {
+		if !quiet {
+			fmt.Println()
+		}
+		return runDryRun(os.Stdout, cfg, readers)
 	}
+
+	// Every output shares the limit on bytes written
+	wrapOutput := meter.wrapOutput(opts.throttle.wrapOutput())
+	var (
+		rowWriter     row.Writer
+		closers       []io.Closer
+		ignoreWriters []*writer.IgnoreErrorsWriter
+		outputPaths   []string
+		compatPaths   []string
+		verifiers     []*verify.Writer
+	)
 	defer func() {
 		for _, closer := range closers {
 			closer.Close()
 		}
 	}()
+	var sampleWriter *writer.SampleWriter
+	if opts.qaSample != "" {
+		var sampleFile io.Closer
+		sampleWriter, sampleFile, err = createQASample(opts.qaSample, opts.qaRate, cfg)
+		if err != nil {
+			return err
+		}
+		closers = append(closers, sampleFile)
+	}
+	for _, out := range outputs {
+		w, outClosers, err := prepareOutputs(
+			ctx,
+			out,
+			src,
+			wrapOutput,
+			sampleWriter,
+			opts.check,
+			warnings,
+			quiet,
+		)
+		closers = append(closers, outClosers...)
+		if err != nil {
+			if out.tenant != "" {
+				return fmt.Errorf("tenant '%s': %w", out.tenant, err)
+			}
+			return err
+		}
+		if rowWriter == nil {
+			rowWriter = w.writer
+		} else {
+			rowWriter = writer.NewTeeWriter(rowWriter, w.writer)
+		}
+		ignoreWriters = append(ignoreWriters, w.ignoreWriters...)
+		outputPaths = append(outputPaths, w.paths...)
+		compatPaths = append(compatPaths, w.compatPaths...)
+		verifiers = append(verifiers, w.verifiers...)
+	}
+	skipped := func() int {
+		n := 0
+		for _, w := range ignoreWriters {
+			n += w.Skipped()
+		}
+		return n
+	}
+
+	// The merged rows are saved before the output settings change them, so
+	// that every re-export applies its own
+	if opts.saveMerge != "" {
+		mergeWriter, mergeFile, err := createMergeFile(opts.saveMerge, cfg, src)
+		if err != nil {
+			return err
+		}
+		defer mergeFile.Close()
+		rowWriter = writer.NewTeeWriter(rowWriter, mergeWriter)
+	}
+
+	rowWriter, err = opts.throttle.wrapRows(rowWriter, quiet)
+	if err != nil {
+		return err
+	}
+	// Values are counted as merged, before any output selects or filters
+	// them
+	var valueCounter *writer.EmptyColumnsWriter
+	if cfg.EmptyColumns != config.EmptyColumnsIgnore {
+		valueCounter = writer.NewEmptyColumnsWriter(rowWriter, len(cfg.Columns))
+		rowWriter = valueCounter
+	}
+	rowWriter = monitors.heartbeat.Writer(rowWriter)
 
 	if !quiet {
 		fmt.Println("Merging databases and writing output...")
@@ -193,24 +696,125 @@ func run(configPath string, quiet, disableCache bool) error {
 		}
 	}
 
-	// Create merger and run
-	m, err := merger.NewMerger(readers, cfg, rowWriter)
+	monitors.SetStage("merge")
+	timer.Start("merge")
+	_, mergeSpan := telemetry.Start(ctx, "merge")
+	stats, breaks, trace, err := mergeDatabases(
+		cfg,
+		readers,
+		rowWriter,
+		monitors,
+		warnings,
+		quiet,
+		opts.verbose,
+		opts.mergeBreaks,
+		traceNetworks,
+	)
+	telemetry.End(mergeSpan, err)
 	if err != nil {
-		return fmt.Errorf("creating merger: %w", err)
+		return err
 	}
-	if err := m.Merge(); err != nil {
-		return fmt.Errorf("merging databases: %w", err)
+	if trace != nil {
+		if err := writeNetworkTrace(opts.traceFile, trace, quiet); err != nil {
+			return err
+		}
 	}
 
 	// Flush writer
-	if flusher, ok := rowWriter.(interface{ Flush() error }); ok {
+	monitors.SetStage("flush")
+	timer.Start("flush")
+	if flusher, ok := rowWriter.(row.Flusher); ok {
 		if err := flusher.Flush(); err != nil {
 			return fmt.Errorf("flushing output: %w", err)
 		}
 	}
 
+	var emptyColumns []string
+	if valueCounter != nil {
+		emptyColumns = reportEmptyColumns(cfg, valueCounter.EmptyColumns(), warnings)
+		if len(emptyColumns) > 0 && cfg.EmptyColumns == config.EmptyColumnsFail {
+			return fmt.Errorf(
+				"columns without a value in any row: %s",
+				strings.Join(emptyColumns, ", "),
+			)
+		}
+	}
+
+	if len(verifiers) > 0 {
+		monitors.SetStage("verify")
+		timer.Start("verify")
+		if err := runVerifiers(verifiers, quiet); err != nil {
+			return err
+		}
+	}
+
+	// Old parts are only removed once the new one is complete
+	var retained []*config.Config
+	for _, out := range outputs {
+		retained = append(retained, out.cfg)
+		retained = append(retained, out.cfg.AdditionalOutputs()...)
+	}
+	for _, out := range retained {
+		if !out.Output.Retention.Enabled() {
+			continue
+		}
+		monitors.SetStage("retention")
+		timer.Start("retention")
+		removed, err := writer.PruneDataset(out.Output.File, out.Output.Retention, time.Now())
+		if err != nil {
+			return fmt.Errorf("applying output.retention: %w", err)
+		}
+		if !quiet {
+			for _, path := range removed {
+				fmt.Printf("Removed old dataset part: %s\n", path)
+			}
+		}
+	}
+
+	if opts.compatCheck != "" {
+		monitors.SetStage("compat_check")
+		timer.Start("compat_check")
+		for _, path := range compatPaths {
+			if err := runCompatCheck(path, opts.compatSamples, quiet); err != nil {
+				return err
+			}
+		}
+	}
+
+	timer.Stop()
+	elapsed := time.Since(startTime)
+	resources := meter.usage()
+
+	if opts.summaryJSON != "" {
+		summary := runSummary{
+			Version:   version,
+			Elapsed:   elapsed.Seconds(),
+			Outputs:   outputPaths,
+			Databases: health,
+			Stages:    summaryStages(timer),
+			Resources: resources,
+		}
+		if len(ignoreWriters) > 0 {
+			summary.SkippedRows = skipped()
+		}
+		summary.SkippedInserts = warnings.count(warningSkippedInsert)
+		summary.EmptyColumns = emptyColumns
+		summary.MergeBreaks = summaryMergeBreaks(breaks)
+		summary.Warnings = warnings.list()
+		if sampleWriter != nil {
+			summary.QASample = &qaSampleSummary{
+				File:    opts.qaSample,
+				Rate:    opts.qaRate,
+				Rows:    sampleWriter.Rows(),
+				Sampled: sampleWriter.Sampled(),
+			}
+		}
+		if err := writeSummaryJSON(opts.summaryJSON, summary); err != nil {
+			return err
+		}
+	}
+
 	if !quiet {
-		elapsed := time.Since(startTime)
 		fmt.Println()
 		fmt.Printf("✓ Successfully completed in %v\n", elapsed.Round(time.Millisecond))
 		if len(outputPaths) == 1 {
@@ -221,167 +825,1132 @@ func run(configPath string, quiet, disableCache bool) error {
 				fmt.Printf("  - %s\n", path)
 			}
 		}
+		if n := skipped(); n > 0 {
+			fmt.Printf("Skipped %d rows in output.ignore_errors networks (see warnings)\n", n)
+		}
+		if n := warnings.count(warningSkippedInsert); n > 0 {
+			fmt.Printf("Skipped %d networks MMDB output could not insert (see warnings)\n", n)
+		}
+		if sampleWriter != nil {
+			fmt.Printf(
+				"QA sample of %d of %d rows written to: %s\n",
+				sampleWriter.Sampled(),
+				sampleWriter.Rows(),
+				opts.qaSample,
+			)
+		}
+		fmt.Println()
+		if err := writeTimings(os.Stdout, timer, stats); err != nil {
+			return err
+		}
+		if breaks != nil {
+			fmt.Println()
+			if err := writeMergeBreaks(os.Stdout, breaks); err != nil {
+				return err
+			}
+		}
+		fmt.Println()
+		if err := writeResourceUsage(os.Stdout, resources); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func prepareRowWriter(
+// wrapRowWriter wraps the output writer rowWriter in the writers applying
+// the output settings: ignored errors, the network checks with check, the
+// limits, range alignment, redaction with redact, anonymity, the filter,
+// reserved networks, and syncing. written counts the bytes of the output
+// files, if they are limited. The IgnoreErrorsWriter is returned too, if
+// there is one, to report the skipped rows.
+func wrapRowWriter(
 	cfg *config.Config,
-	readers *mmdb.Readers,
-	quiet bool,
-) (merger.RowWriter, []io.Closer, []string, error) {
+	src ipSources,
+	rowWriter row.Writer,
+	redact bool,
+	check bool,
+	written *atomic.Int64,
+	warnings *warningLog,
+) (row.Writer, *writer.IgnoreErrorsWriter, error) {
+	// Ignored errors are caught right above the output writer, where the
+	// failing rows are known
 	var (
-		closers     []io.Closer
-		outputPaths []string
+		ignoreWriter *writer.IgnoreErrorsWriter
+		err          error
 	)
-
-	closeAll := func() {
-		for _, closer := range closers {
-			closer.Close()
+	if len(cfg.Output.IgnoreErrors) > 0 {
+		ignoreWriter, err = writer.NewIgnoreErrorsWriter(
+			rowWriter,
+			cfg,
+			func(e *writer.IgnoredError) {
+				warnings.warn(runWarning{
+					Kind:    warningIgnoredError,
+					Message: e.Error(),
+					Network: e.Network.String(),
+				})
+			},
+		)
+		if err != nil {
+			return nil, nil, err
 		}
+		rowWriter = ignoreWriter
 	}
 
-	switch cfg.Output.Format {
-	case "csv":
-		if cfg.Output.IPv4File != "" && cfg.Output.IPv6File != "" {
-			if !quiet {
-				fmt.Println()
-				fmt.Println("Creating output files...")
-			}
-			ipv4Path, ipv6Path := splitConfiguredPaths(
-				cfg.Output.File,
-				cfg.Output.IPv4File,
-				cfg.Output.IPv6File,
-			)
-			ipv4File, err := createOutputFile(ipv4Path)
-			if err != nil {
-				closeAll()
-				return nil, nil, nil, fmt.Errorf("creating IPv4 output file: %w", err)
-			}
-			closers = append(closers, ipv4File)
-			outputPaths = append(outputPaths, ipv4Path)
-
-			ipv6File, err := createOutputFile(ipv6Path)
-			if err != nil {
-				closeAll()
-				return nil, nil, nil, fmt.Errorf("creating IPv6 output file: %w", err)
-			}
-			closers = append(closers, ipv6File)
-			outputPaths = append(outputPaths, ipv6Path)
-
-			return writer.NewSplitRowWriter(
-				writer.NewCSVWriter(ipv4File, cfg),
-				writer.NewCSVWriter(ipv6File, cfg),
-			), closers, outputPaths, nil
-		}
+	// Networks are checked above the ignored errors, so that a failed check
+	// is never downgraded to a warning, and below every writer changing them
+	if check {
+		rowWriter = writer.NewCheckWriter(rowWriter)
+	}
 
-		if !quiet {
-			fmt.Println()
-			fmt.Println("Creating output file...")
+	// Limits count the rows reaching the output, once ranges are split
+	if cfg.Output.Limits.Enabled() {
+		file := cfg.Output.File
+		switch {
+		case cfg.Output.Format == "postgres":
+			file = postgresTarget(cfg)
+		case cfg.Output.Format == "bigquery":
+			file = bigQueryTarget(cfg)
+		case file == "":
+			file = cfg.Output.IPv4File + " and " + cfg.Output.IPv6File
 		}
-		outputFile, err := createOutputFile(cfg.Output.File)
-		if err != nil {
-			closeAll()
-			return nil, nil, nil, fmt.Errorf("creating output file: %w", err)
-		}
-		closers = append(closers, outputFile)
-		outputPaths = append(outputPaths, cfg.Output.File)
-		return writer.NewCSVWriter(outputFile, cfg), closers, outputPaths, nil
-
-	case "parquet":
-		if cfg.Output.IPv4File != "" && cfg.Output.IPv6File != "" {
-			if !quiet {
-				fmt.Println()
-				fmt.Println("Creating output files...")
-			}
-			ipv4Path, ipv6Path := splitConfiguredPaths(
-				cfg.Output.File,
-				cfg.Output.IPv4File,
-				cfg.Output.IPv6File,
-			)
-
-			ipv4File, err := createOutputFile(ipv4Path)
-			if err != nil {
-				closeAll()
-				return nil, nil, nil, fmt.Errorf("creating IPv4 output file: %w", err)
-			}
-			closers = append(closers, ipv4File)
-			outputPaths = append(outputPaths, ipv4Path)
-
-			ipv6File, err := createOutputFile(ipv6Path)
-			if err != nil {
-				closeAll()
-				return nil, nil, nil, fmt.Errorf("creating IPv6 output file: %w", err)
-			}
-			closers = append(closers, ipv6File)
-			outputPaths = append(outputPaths, ipv6Path)
+		rowWriter = writer.NewLimitWriter(rowWriter, cfg, written, func(e *writer.LimitError) {
+			warnings.warn(runWarning{
+				Kind:    warningLimit,
+				Message: fmt.Sprintf("%v; leaving out the remaining rows of %s", e, file),
+			})
+		})
+	}
 
-			ipv4Writer, err := writer.NewParquetWriterWithIPVersion(
-				ipv4File,
-				cfg,
-				writer.IPVersion4,
-			)
-			if err != nil {
-				closeAll()
-				return nil, nil, nil, fmt.Errorf("creating IPv4 Parquet writer: %w", err)
-			}
-			ipv6Writer, err := writer.NewParquetWriterWithIPVersion(
-				ipv6File,
-				cfg,
-				writer.IPVersion6,
-			)
-			if err != nil {
-				closeAll()
-				return nil, nil, nil, fmt.Errorf("creating IPv6 Parquet writer: %w", err)
-			}
-			return writer.NewSplitRowWriter(ipv4Writer, ipv6Writer), closers, outputPaths, nil
-		}
+	// Ranges are split below the filter and reserved networks, so that every
+	// row reaching the output lies within one aligned network
+	if cfg.Output.Align.Enabled() {
+		rowWriter = writer.NewAlignWriter(rowWriter, cfg)
+	}
 
-		if !quiet {
-			fmt.Println()
-			fmt.Println("Creating output file...")
-		}
-		outputFile, err := createOutputFile(cfg.Output.File)
+	// Redaction sits below the filter, so that rows are filtered on their
+	// original values
+	if redact {
+		rowWriter, err = writer.NewRedactWriter(rowWriter, cfg)
 		if err != nil {
-			closeAll()
-			return nil, nil, nil, fmt.Errorf("creating output file: %w", err)
+			return nil, nil, err
 		}
-		closers = append(closers, outputFile)
-		outputPaths = append(outputPaths, cfg.Output.File)
+	}
 
-		parquetWriter, err := writer.NewParquetWriter(outputFile, cfg)
+	// Anonymity sits below the filter, so that only the rows kept count, and
+	// above alignment, so that networks are sized before being split
+	if cfg.Output.Anonymity.Enabled() {
+		rowWriter, err = writer.NewAnonymityWriter(rowWriter, cfg)
 		if err != nil {
-			closeAll()
-			return nil, nil, nil, fmt.Errorf("creating Parquet writer: %w", err)
-		}
-		return parquetWriter, closers, outputPaths, nil
-
-	case "mmdb":
-		if !quiet {
-			fmt.Println()
-			fmt.Println("Creating output file...")
+			return nil, nil, err
 		}
+	}
 
-		// Detect IP version from databases
-		ipVersion, err := detectIPVersionFromDatabases(cfg, readers)
+	// The filter sits below the reserved network writer, so that the rows
+	// of reserved networks are filtered too
+	if len(cfg.Output.Filter) > 0 {
+		rowWriter, err = writer.NewFilterWriter(rowWriter, cfg)
 		if err != nil {
-			closeAll()
-			return nil, nil, nil, fmt.Errorf("detecting IP version: %w", err)
+			return nil, nil, err
 		}
+	}
 
-		mmdbWriter, err := writer.NewMMDBWriter(cfg.Output.File, cfg, ipVersion)
+	if cfg.Output.ReservedNetworks.Include {
+		rowWriter, err = wrapReservedNetworks(cfg, src, rowWriter)
 		if err != nil {
-			closeAll()
-			return nil, nil, nil, fmt.Errorf("creating MMDB writer: %w", err)
+			return nil, nil, err
 		}
-
-		outputPaths = append(outputPaths, cfg.Output.File)
-		return mmdbWriter, closers, outputPaths, nil
 	}
 
-	closeAll()
-	return nil, nil, nil, fmt.Errorf("unsupported output format: %s", cfg.Output.Format)
+	if cfg.Output.Sync.Enabled() {
+		rowWriter = writer.NewSyncWriter(
+			rowWriter,
+			cfg.Output.Sync.EveryRows,
+			time.Duration(cfg.Output.Sync.EverySeconds)*time.Second,
+		)
+	}
+	return rowWriter, ignoreWriter, nil
+}
+
+// setPermissions wraps rowWriter, the writer of the output of cfg to paths,
+// in a writer setting output.permissions on its files once flushed, if cfg
+// configures them. The parts of rotated output are found among closers.
+func setPermissions(
+	cfg *config.Config,
+	rowWriter row.Writer,
+	paths []string,
+	closers []io.Closer,
+) (row.Writer, error) {
+	if !cfg.Output.Permissions.Enabled() {
+		return rowWriter, nil
+	}
+	files := func() []string {
+		files := slices.Clone(paths)
+		for _, closer := range closers {
+			if parts, ok := closer.(*writer.PartFiles); ok {
+				files = append(files, parts.Paths()...)
+			}
+		}
+		return files
+	}
+	return writer.NewPermissionsWriter(rowWriter, cfg.Output.Permissions, files)
+}
+
+// verifyOutput wraps rowWriter, the writer of the output of cfg to paths,
+// in a writer recording the rows to check the output against, if cfg
+// verifies it. The recording writer is returned too, or nil.
+func verifyOutput(cfg *config.Config, rowWriter row.Writer, paths []string) (row.Writer, *verify.Writer) {
+	if !cfg.Output.Verify.Enabled() {
+		return rowWriter, nil
+	}
+	verifier := verify.NewWriter(rowWriter, cfg, paths)
+	return verifier, verifier
+}
+
+// runVerifiers reads the flushed outputs back and checks them against the
+// rows written.
+func runVerifiers(verifiers []*verify.Writer, quiet bool) error {
+	for _, verifier := range verifiers {
+		if err := verifier.Verify(); err != nil {
+			return fmt.Errorf("verifying output: %w", err)
+		}
+		if !quiet {
+			fmt.Printf(
+				"Verified %s (%d rows written, %d sampled)\n",
+				strings.Join(verifier.Paths(), ", "),
+				verifier.Rows(),
+				verifier.Samples(),
+			)
+		}
+	}
+	return nil
+}
+
+// countOutput returns wrapOutput extended to count the bytes written to the
+// output files of cfg, and the count, if cfg limits them. Otherwise it
+// returns wrapOutput and nil.
+func countOutput(
+	cfg *config.Config,
+	wrapOutput func(io.Writer) io.Writer,
+) (func(io.Writer) io.Writer, *atomic.Int64) {
+	if cfg.Output.Limits.MaxBytes <= 0 {
+		return wrapOutput, nil
+	}
+	written := new(atomic.Int64)
+	return func(w io.Writer) io.Writer {
+		if wrapOutput != nil {
+			w = wrapOutput(w)
+		}
+		return &countingWriter{w: w, n: written}
+	}, written
+}
+
+// outputConfig is a configuration whose outputs a run writes: that of the
+// run, or that of a tenant.
+type outputConfig struct {
+	tenant string // Name of the tenant, if any
+	cfg    *config.Config
+	redact bool // Apply the redact policies of the columns
+}
+
+// loadTenants loads the tenant overlays matching pattern over the
+// configuration at configPath. A tenant is redacted if its overlay says so,
+// and otherwise if redact is set. No two tenants can write the same file.
+func loadTenants(configPath, pattern string, redact bool) ([]outputConfig, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("--tenants: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("--tenants: no files match '%s'", pattern)
+	}
+
+	outputs := make([]outputConfig, 0, len(paths))
+	writers := map[string]string{} // Tenant writing each file
+	for _, path := range paths {
+		tenant, err := config.LoadTenant(configPath, path)
+		if err != nil {
+			return nil, fmt.Errorf("loading tenant: %w", err)
+		}
+		for _, out := range outputs {
+			if out.tenant == tenant.Name {
+				return nil, fmt.Errorf("tenant '%s' is configured twice, the second time by %s", tenant.Name, path)
+			}
+		}
+		for _, file := range tenant.Config.OutputFiles() {
+			if other, ok := writers[file]; ok {
+				return nil, fmt.Errorf(
+					"tenant '%s': '%s' is also written by tenant '%s'; use %s in the output file names",
+					tenant.Name,
+					file,
+					other,
+					config.TenantPlaceholder,
+				)
+			}
+			writers[file] = tenant.Name
+		}
+
+		out := outputConfig{tenant: tenant.Name, cfg: tenant.Config, redact: redact}
+		if tenant.Redact != nil {
+			out.redact = *tenant.Redact
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs, nil
+}
+
+// outputWriters are the writers of the outputs of one configuration.
+type outputWriters struct {
+	writer        row.Writer
+	ignoreWriters []*writer.IgnoreErrorsWriter // Of every output ignoring errors
+	paths         []string                     // Files of every output
+	compatPaths   []string                     // Files of [output], which --compat-check checks
+	verifiers     []*verify.Writer
+}
+
+// prepareOutputs creates the writer of [output] and of the [[outputs]] of
+// out. The rows written to [output] are also given to sample, if not nil. It
+// returns the writers and the files to close even on error.
+func prepareOutputs(
+	ctx context.Context,
+	out outputConfig,
+	src ipSources,
+	wrapOutput func(io.Writer) io.Writer,
+	sample *writer.SampleWriter,
+	check bool,
+	warnings *warningLog,
+	quiet bool,
+) (outputWriters, []io.Closer, error) {
+	if out.tenant != "" {
+		if err := validateParquetNetworkColumns(out.cfg, src); err != nil {
+			return outputWriters{}, nil, fmt.Errorf("validating network columns: %w", err)
+		}
+	}
+	countedOutput, written := countOutput(out.cfg, wrapOutput)
+	rowWriter, closers, paths, err := prepareRowWriter(
+		ctx,
+		out.cfg,
+		src,
+		countedOutput,
+		warnings,
+		quiet,
+	)
+	if err != nil {
+		return outputWriters{}, closers, err
+	}
+	rowWriter, err = setPermissions(out.cfg, rowWriter, paths, closers)
+	if err != nil {
+		return outputWriters{}, closers, err
+	}
+	var verifiers []*verify.Writer
+	rowWriter, verifier := verifyOutput(out.cfg, rowWriter, paths)
+	if verifier != nil {
+		verifiers = append(verifiers, verifier)
+	}
+	// The sample is drawn from the rows reaching the output, once filtered
+	// and limited
+	if sample != nil {
+		rowWriter = writer.NewTeeWriter(rowWriter, sample)
+	}
+	rowWriter, ignoreWriter, err := wrapRowWriter(
+		out.cfg,
+		src,
+		rowWriter,
+		out.redact,
+		check,
+		written,
+		warnings,
+	)
+	if err != nil {
+		return outputWriters{}, closers, err
+	}
+
+	additional, additionalClosers, err := addAdditionalOutputs(
+		ctx,
+		out.cfg,
+		src,
+		rowWriter,
+		wrapOutput,
+		out.redact,
+		check,
+		warnings,
+		quiet,
+	)
+	closers = append(closers, additionalClosers...)
+	if err != nil {
+		return outputWriters{}, closers, err
+	}
+	var ignoreWriters []*writer.IgnoreErrorsWriter
+	if ignoreWriter != nil {
+		ignoreWriters = append(ignoreWriters, ignoreWriter)
+	}
+	return outputWriters{
+		writer:        additional.writer,
+		ignoreWriters: append(ignoreWriters, additional.ignoreWriters...),
+		paths:         append(slices.Clip(paths), additional.paths...),
+		compatPaths:   paths,
+		verifiers:     append(verifiers, additional.verifiers...),
+	}, closers, nil
+}
+
+// addAdditionalOutputs adds a writer for each of the [[outputs]] of cfg to
+// rowWriter, which writes the rows of the merge to [output]. Each is given
+// the columns its output selects, and applies its own output settings. It
+// returns the writer with the ignored errors, paths, and verifiers of the
+// outputs added, and the files to close even on error.
+func addAdditionalOutputs(
+	ctx context.Context,
+	cfg *config.Config,
+	src ipSources,
+	rowWriter row.Writer,
+	wrapOutput func(io.Writer) io.Writer,
+	redact bool,
+	check bool,
+	warnings *warningLog,
+	quiet bool,
+) (outputWriters, []io.Closer, error) {
+	var (
+		closers []io.Closer
+		outputs outputWriters
+	)
+	for i, out := range cfg.AdditionalOutputs() {
+		out.Provenance = cfg.Provenance
+		if err := validateParquetNetworkColumns(out, src); err != nil {
+			return outputWriters{}, closers, fmt.Errorf("validating network columns of outputs[%d]: %w", i, err)
+		}
+		countedOutput, written := countOutput(out, wrapOutput)
+		w, outClosers, outPaths, err := prepareRowWriter(
+			ctx,
+			out,
+			src,
+			countedOutput,
+			warnings,
+			quiet,
+		)
+		closers = append(closers, outClosers...)
+		if err != nil {
+			return outputWriters{}, closers, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		outputs.paths = append(outputs.paths, outPaths...)
+		w, err = setPermissions(out, w, outPaths, outClosers)
+		if err != nil {
+			return outputWriters{}, closers, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+
+		w, verifier := verifyOutput(out, w, outPaths)
+		if verifier != nil {
+			outputs.verifiers = append(outputs.verifiers, verifier)
+		}
+		w, ignoreWriter, err := wrapRowWriter(out, src, w, redact, check, written, warnings)
+		if err != nil {
+			return outputWriters{}, closers, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		if ignoreWriter != nil {
+			outputs.ignoreWriters = append(outputs.ignoreWriters, ignoreWriter)
+		}
+		selectWriter, err := writer.NewSelectWriter(w, cfg, out)
+		if err != nil {
+			return outputWriters{}, closers, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		rowWriter = writer.NewTeeWriter(rowWriter, selectWriter)
+	}
+	outputs.writer = rowWriter
+	return outputs, closers, nil
+}
+
+// warnCaseCollisions warns about column names that differ only in case.
+func warnCaseCollisions(cfg *config.Config, warnings *warningLog) {
+	for _, names := range cfg.CaseCollisions() {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = "'" + string(name) + "'"
+		}
+		warnings.warn(runWarning{
+			Kind: warningCaseCollision,
+			Message: fmt.Sprintf(
+				"column names %s differ only in case, which tools that ignore case, such as SQL databases, cannot tell apart",
+				strings.Join(quoted, ", "),
+			),
+		})
+	}
+}
+
+// openReaders opens every configured database, optionally listing them.
+func openReaders(cfg *config.Config, quiet bool) (*mmdb.Readers, error) {
+	databases := make(map[string]config.Database, len(cfg.Databases))
+	for _, db := range cfg.Databases {
+		databases[db.Name] = db
+		if !quiet {
+			fmt.Printf("  - %s: %s (priority: %d)\n", db.Name, db.Path, db.Priority)
+		}
+	}
+
+	readers, err := mmdb.OpenDatabases(databases)
+	if err != nil {
+		return nil, fmt.Errorf("opening databases: %w", err)
+	}
+	if err := checkEditions(cfg, readers); err != nil {
+		readers.Close()
+		return nil, err
+	}
+	return readers, nil
+}
+
+// openMerge opens the databases of cfg for a command that merges them
+// without writing output, such as coverage, with the same checks and
+// pre-merging as run. Database history is not supported, as such commands
+// report on a single build of every database.
+func openMerge(cfg *config.Config, command string) (*mmdb.Readers, error) {
+	if db, ok := cfg.HistoryDatabase(); ok {
+		return nil, fmt.Errorf(
+			"%s is not supported with database history (database '%s')",
+			command,
+			db.Name,
+		)
+	}
+	readers, err := openReaders(cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkDatabases(cfg, readers, time.Now(), true); err != nil {
+		readers.Close()
+		return nil, err
+	}
+	if err := premergeDatabases(cfg, readers, nil, true); err != nil {
+		readers.Close()
+		return nil, err
+	}
+	return readers, nil
+}
+
+// checkEditions fails if the database type of a database with an edition
+// is not that of the edition, as the paths of its fields would find
+// nothing, or the wrong values, in its records.
+func checkEditions(cfg *config.Config, readers *mmdb.Readers) error {
+	for _, db := range cfg.Databases {
+		ed, ok := edition.Lookup(db.Edition)
+		if !ok {
+			continue
+		}
+		reader, ok := readers.Get(db.Name)
+		if !ok {
+			continue
+		}
+		if databaseType := reader.Metadata().DatabaseType; !ed.Matches(databaseType) {
+			return fmt.Errorf(
+				"database '%s' (%s) has database type '%s', not edition %s",
+				db.Name,
+				db.Path,
+				databaseType,
+				ed.Name,
+			)
+		}
+	}
+	return nil
+}
+
+// mergeDatabases merges the databases into w, once per build when a database
+// has history. With collectStats, it returns the time spent in each step of
+// the merge, with countBreaks the merge breaks of each column, and with
+// traceNetworks the trace of their merge; there are none for history merges.
+func mergeDatabases(
+	cfg *config.Config,
+	readers *mmdb.Readers,
+	w row.Writer,
+	monitors *runMonitors,
+	warnings *warningLog,
+	quiet bool,
+	collectStats bool,
+	countBreaks bool,
+	traceNetworks []netip.Prefix,
+) (*merger.Stats, *merger.MergeBreaks, *merger.Trace, error) {
+	if db, ok := cfg.HistoryDatabase(); ok {
+		return nil, nil, nil, mergeHistory(cfg, db, w, monitors, quiet)
+	}
+
+	m, err := merger.NewMerger(readers, cfg, w)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("creating merger: %w", err)
+	}
+	if collectStats {
+		m.EnableStats()
+	}
+	if countBreaks {
+		m.EnableMergeBreaks()
+	}
+	if len(traceNetworks) > 0 {
+		m.EnableTrace(traceNetworks)
+	}
+	defer monitors.trackMerge(m)()
+	if err := m.Merge(); err != nil {
+		return nil, nil, nil, fmt.Errorf("merging databases: %w", err)
+	}
+	warnUnknownCountries(m, warnings)
+	return m.Stats(), m.MergeBreaks(), m.Trace(), nil
+}
+
+// warnUnknownCountries warns of the strings that the country steps of
+// column transforms did not recognize as country codes.
+func warnUnknownCountries(m *merger.Merger, warnings *warningLog) {
+	unknown := m.UnknownCountries()
+	for _, name := range slices.Sorted(maps.Keys(unknown)) {
+		codes := unknown[name]
+		counts := make([]string, 0, len(codes))
+		for _, code := range slices.Sorted(maps.Keys(codes)) {
+			counts = append(counts, fmt.Sprintf("'%s' (%d values)", code, codes[code]))
+		}
+		warnings.warn(runWarning{
+			Kind: warningUnknownCountries,
+			Message: fmt.Sprintf(
+				"column '%s': unknown country codes %s",
+				name,
+				strings.Join(counts, ", "),
+			),
+			Column: string(name),
+		})
+	}
+}
+
+// reportEmptyColumns warns of the data columns of cfg at indexes empty,
+// which have no value in any row, naming the database and path each reads,
+// and returns their names.
+func reportEmptyColumns(cfg *config.Config, empty []int, warnings *warningLog) []string {
+	names := make([]string, 0, len(empty))
+	for _, i := range empty {
+		col := cfg.Columns[i]
+		names = append(names, string(col.Name))
+		warnings.warn(runWarning{
+			Kind:    warningEmptyColumn,
+			Message: describeEmptyColumn(cfg, col),
+			Column:  string(col.Name),
+		})
+	}
+	return names
+}
+
+// describeEmptyColumn describes a column without a value in any row, and
+// where its values were read from.
+func describeEmptyColumn(cfg *config.Config, col config.Column) string {
+	msg := fmt.Sprintf("column '%s' has no value in any row", col.Name)
+	if col.Database == "" {
+		return msg
+	}
+	if col.Tag != "" || col.PrefixLength {
+		// The column is set for every network the database has data for
+		msg += fmt.Sprintf("; check database '%s'", col.Database)
+	} else {
+		msg += fmt.Sprintf("; check path %v in database '%s'", col.Path, col.Database)
+	}
+	for _, db := range cfg.Databases {
+		if db.Name == col.Database {
+			msg += fmt.Sprintf(" (%s)", db.Path)
+		}
+	}
+	return msg
+}
+
+// mergeHistory merges every build of db and writes rows with the interval
+// in which each was valid.
+func mergeHistory(
+	cfg *config.Config,
+	db config.Database,
+	w row.Writer,
+	monitors *runMonitors,
+	quiet bool,
+) error {
+	builds, err := history.Builds(db)
+	if err != nil {
+		return fmt.Errorf("finding builds of %s: %w", db.Name, err)
+	}
+	if !quiet {
+		fmt.Printf("  Found %d builds of %s\n", len(builds), db.Name)
+	}
+
+	err = history.Run(cfg, builds, w, func(b history.Build) {
+		monitors.SetStage("merge " + b.Path)
+		if !quiet {
+			fmt.Printf("  Merging build %s (%s)\n", b.Path, b.Time.Format(time.DateOnly))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("merging database history: %w", err)
+	}
+	return nil
+}
+
+// recordProvenance adds the tool version, the source databases, and with
+// redact the redacted columns to the provenance recorded in the output.
+func recordProvenance(cfg *config.Config, readers *mmdb.Readers, redact bool) {
+	cfg.Provenance.ToolVersion = version
+	for _, db := range cfg.Databases {
+		reader, ok := readers.Get(db.Name)
+		if !ok {
+			continue
+		}
+		metadata := reader.Metadata()
+		cfg.Provenance.Sources = append(cfg.Provenance.Sources, provenance.Source{
+			Name:         db.Name,
+			DatabaseType: metadata.DatabaseType,
+			BuildEpoch:   metadata.BuildEpoch,
+			Tags:         db.Tags,
+		})
+	}
+	if redact {
+		recordRedactions(cfg)
+	}
+}
+
+var errRedactWithoutPolicy = errors.New("--redact requires a column with a redact policy")
+
+// hasRedactPolicy reports whether some column has a redact policy.
+func hasRedactPolicy(cfg *config.Config) bool {
+	return slices.ContainsFunc(cfg.Columns, func(col config.Column) bool {
+		return col.Redact != nil
+	})
+}
+
+// recordRedactions adds the redact policies of the columns to the
+// provenance recorded in the output.
+func recordRedactions(cfg *config.Config) {
+	for _, col := range cfg.Columns {
+		if col.Redact != nil {
+			cfg.Provenance.Redactions = append(cfg.Provenance.Redactions, provenance.Redaction{
+				Column: string(col.Name),
+				Policy: col.Redact.String(),
+			})
+		}
+	}
+}
+
+// premergeDatabases applies max_nesting_depth, and warns when the number of
+// databases iterated together is likely to make the merge slow.
+func premergeDatabases(
+	cfg *config.Config,
+	readers *mmdb.Readers,
+	warnings *warningLog,
+	quiet bool,
+) error {
+	merged, err := premerge.Apply(readers, cfg)
+	if err != nil {
+		return fmt.Errorf("pre-merging databases: %w", err)
+	}
+	if !quiet {
+		for _, p := range merged {
+			fmt.Printf(
+				"  Pre-merged %s into %s (%d nodes)\n",
+				strings.Join(p.Sources, " and "),
+				p.Name,
+				p.Nodes,
+			)
+		}
+	}
+
+	if n := len(merger.IteratedDatabaseNames(cfg)); n > premerge.WarnThreshold {
+		warnings.warn(runWarning{
+			Kind: warningIteratedDatabases,
+			Message: fmt.Sprintf(
+				"%d databases are iterated together, which multiplies the work per network; set max_nesting_depth to pre-merge the smallest ones",
+				n,
+			),
+		})
+	}
+	return nil
+}
+
+// prepareRowWriter creates the output files and the writer for the
+// configured format, one per IP version when the output is split.
+//
+// wrapOutput, if not nil, wraps each output file, for example to limit the
+// write rate. Networks MMDB output leaves out under
+// output.mmdb.on_insert_error are reported to warnings.
+func prepareRowWriter(
+	ctx context.Context,
+	cfg *config.Config,
+	src ipSources,
+	wrapOutput func(io.Writer) io.Writer,
+	warnings *warningLog,
+	quiet bool,
+) (row.Writer, []io.Closer, []string, error) {
+	if cfg.Output.Format == "mmdb" {
+		return prepareMMDBWriter(ctx, cfg, src, wrapOutput, warnings, quiet)
+	}
+	if cfg.Output.Format == "postgres" {
+		return preparePostgresWriter(ctx, cfg, wrapOutput, quiet)
+	}
+	if cfg.Output.Format == "bigquery" {
+		return prepareBigQueryWriter(ctx, cfg, quiet)
+	}
+	if cfg.Output.Format == "clickhouse" && cfg.Output.ClickHouse.DDLFile != "" {
+		if err := writeClickHouseDDL(cfg, quiet); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if cfg.Output.Format == "protobuf" {
+		if err := writeProtobufSchema(cfg, quiet); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	output := func(f *os.File) io.Writer {
+		if wrapOutput == nil {
+			return f
+		}
+		return wrapOutput(f)
+	}
+
+	if cfg.Output.IPv4File == "" || cfg.Output.IPv6File == "" {
+		if !quiet {
+			fmt.Println()
+			fmt.Println("Creating output file...")
+		}
+		path := cfg.Output.File
+		if cfg.Output.Parquet.Append {
+			var err error
+			path, err = writer.PrepareDatasetPart(cfg.Output.File, cfg)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("appending to dataset: %w", err)
+			}
+		}
+		if cfg.Output.CSV.MaxFileSize > 0 {
+			rowWriter, parts, err := createRotatingCSVWriter(path, cfg, wrapOutput)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return telemetry.NewWriter(ctx, rowWriter, path),
+				[]io.Closer{parts},
+				[]string{parts.ManifestPath()},
+				nil
+		}
+		outputFile, err := createOutputFile(path)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("creating output file: %w", err)
+		}
+		rowWriter, err := writer.New(output(outputFile), cfg, writer.IPVersionAny)
+		if err != nil {
+			outputFile.Close()
+			return nil, nil, nil, fmt.Errorf("creating output writer: %w", err)
+		}
+		closers := []io.Closer{outputFile}
+		// A SQLite writer removes its temporary database if not flushed
+		if closer, ok := rowWriter.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
+		return telemetry.NewWriter(ctx, rowWriter, path),
+			closers,
+			[]string{path},
+			nil
+	}
+
+	if !quiet {
+		fmt.Println()
+		fmt.Println("Creating output files...")
+	}
+	ipv4Path, ipv6Path := splitConfiguredPaths(
+		cfg.Output.File,
+		cfg.Output.IPv4File,
+		cfg.Output.IPv6File,
+	)
+
+	var closers []io.Closer
+	closeAll := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+
+	if cfg.Output.CSV.MaxFileSize > 0 {
+		ipv4Writer, ipv4Parts, err := createRotatingCSVWriter(ipv4Path, cfg, wrapOutput)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		closers = append(closers, ipv4Parts)
+		ipv6Writer, ipv6Parts, err := createRotatingCSVWriter(ipv6Path, cfg, wrapOutput)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, err
+		}
+		closers = append(closers, ipv6Parts)
+		return writer.NewSplitRowWriter(
+				telemetry.NewWriter(ctx, ipv4Writer, ipv4Path),
+				telemetry.NewWriter(ctx, ipv6Writer, ipv6Path),
+			),
+			closers,
+			[]string{ipv4Parts.ManifestPath(), ipv6Parts.ManifestPath()},
+			nil
+	}
+
+	ipv4File, err := createOutputFile(ipv4Path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("creating IPv4 output file: %w", err)
+	}
+	closers = append(closers, ipv4File)
+
+	ipv6File, err := createOutputFile(ipv6Path)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, fmt.Errorf("creating IPv6 output file: %w", err)
+	}
+	closers = append(closers, ipv6File)
+
+	ipv4Writer, err := writer.New(output(ipv4File), cfg, writer.IPVersion4)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, fmt.Errorf("creating IPv4 output writer: %w", err)
+	}
+	if closer, ok := ipv4Writer.(io.Closer); ok {
+		closers = append(closers, closer)
+	}
+	ipv6Writer, err := writer.New(output(ipv6File), cfg, writer.IPVersion6)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, fmt.Errorf("creating IPv6 output writer: %w", err)
+	}
+	if closer, ok := ipv6Writer.(io.Closer); ok {
+		closers = append(closers, closer)
+	}
+	return writer.NewSplitRowWriter(
+			telemetry.NewWriter(ctx, ipv4Writer, ipv4Path),
+			telemetry.NewWriter(ctx, ipv6Writer, ipv6Path),
+		),
+		closers,
+		[]string{ipv4Path, ipv6Path},
+		nil
+}
+
+// writeClickHouseDDL writes the CREATE TABLE statement of the ClickHouse
+// output to output.clickhouse.ddl_file.
+func writeClickHouseDDL(cfg *config.Config, quiet bool) error {
+	path := cfg.Output.ClickHouse.DDLFile
+	if err := os.WriteFile(path, []byte(writer.ClickHouseDDL(cfg)), 0o600); err != nil {
+		return fmt.Errorf("writing ClickHouse DDL file: %w", err)
+	}
+	if !quiet {
+		fmt.Printf("  Wrote table definition to %s\n", path)
+	}
+	return nil
+}
+
+// writeProtobufSchema writes the .proto schema of the protobuf output.
+func writeProtobufSchema(cfg *config.Config, quiet bool) error {
+	path := cfg.Output.Protobuf.SchemaFile
+	if err := os.WriteFile(path, []byte(writer.ProtobufSchema(cfg)), 0o600); err != nil {
+		return fmt.Errorf("writing protobuf schema file: %w", err)
+	}
+	if !quiet {
+		fmt.Printf("  Wrote message schema to %s\n", path)
+	}
+	return nil
+}
+
+// preparePostgresWriter connects to the PostgreSQL server and starts
+// copying rows into the table. The rows are committed when the writer is
+// flushed, and rolled back if it is closed first.
+func preparePostgresWriter(
+	ctx context.Context,
+	cfg *config.Config,
+	wrapOutput func(io.Writer) io.Writer,
+	quiet bool,
+) (row.Writer, []io.Closer, []string, error) {
+	if !quiet {
+		fmt.Println()
+		fmt.Println("Connecting to PostgreSQL...")
+	}
+	pgWriter, err := writer.NewPostgresWriter(ctx, cfg, wrapOutput)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	target := postgresTarget(cfg)
+	return telemetry.NewWriter(ctx, pgWriter, target),
+		[]io.Closer{pgWriter},
+		[]string{target},
+		nil
+}
+
+// postgresTarget describes the table of PostgreSQL output, in place of an
+// output file. The connection string is left out, as it may hold a
+// password.
+func postgresTarget(cfg *config.Config) string {
+	return "PostgreSQL table " + cfg.Output.Postgres.Table
+}
+
+// prepareBigQueryWriter creates the table unless it exists and opens a
+// stream appending rows to it. The rows are committed when the writer is
+// flushed, and discarded if it is closed first. Rows are not written to a
+// file, so the write rate is not limited.
+func prepareBigQueryWriter(
+	ctx context.Context,
+	cfg *config.Config,
+	quiet bool,
+) (row.Writer, []io.Closer, []string, error) {
+	if !quiet {
+		fmt.Println()
+		fmt.Println("Connecting to BigQuery...")
+	}
+	bqWriter, err := writer.NewBigQueryWriter(ctx, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	target := bigQueryTarget(cfg)
+	return telemetry.NewWriter(ctx, bqWriter, target),
+		[]io.Closer{bqWriter},
+		[]string{target},
+		nil
+}
+
+// bigQueryTable returns the table of BigQuery output, qualified by its
+// project and dataset.
+func bigQueryTable(cfg *config.Config) string {
+	bq := cfg.Output.BigQuery
+	return bq.Project + "." + bq.Dataset + "." + bq.Table
+}
+
+// bigQueryTarget describes the table of BigQuery output, in place of an
+// output file.
+func bigQueryTarget(cfg *config.Config) string {
+	return "BigQuery table " + bigQueryTable(cfg)
+}
+
+// prepareMMDBWriter creates the MMDB writer. The tree is written out only
+// when the writer is flushed, so the output file is created then rather than
+// here, which also keeps it intact while it is loaded as the base database.
+func prepareMMDBWriter(
+	ctx context.Context,
+	cfg *config.Config,
+	src ipSources,
+	wrapOutput func(io.Writer) io.Writer,
+	warnings *warningLog,
+	quiet bool,
+) (row.Writer, []io.Closer, []string, error) {
+	if !quiet {
+		fmt.Println()
+		fmt.Println("Creating output file...")
+	}
+
+	if cfg.Output.IPv4File != "" && cfg.Output.IPv6File != "" {
+		return prepareSplitMMDBWriter(ctx, cfg, wrapOutput, warnings, quiet)
+	}
+
+	// Detect IP version from the sources
+	ipVersion, err := detectIPVersion(cfg, src)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("detecting IP version: %w", err)
+	}
+
+	if !quiet && cfg.Output.MMDB.Base != "" {
+		fmt.Printf(
+			"  Loading base database %s (insert strategy: %s)\n",
+			cfg.Output.MMDB.Base,
+			cfg.Output.MMDB.InsertStrategy,
+		)
+	}
+
+	if !quiet {
+		fmt.Printf("  IP version: %d\n", ipVersion)
+	}
+
+	mmdbWriter, err := writer.NewMMDBWriter(cfg.Output.File, cfg, ipVersion)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("creating MMDB writer: %w", err)
+	}
+	mmdbWriter.ReportSkippedInserts(warnSkippedInsert(warnings))
+	if wrapOutput != nil {
+		mmdbWriter.WrapFile(wrapOutput)
+	}
+	return telemetry.NewWriter(ctx, mmdbWriter, cfg.Output.File),
+		nil,
+		[]string{cfg.Output.File},
+		nil
+}
+
+// prepareSplitMMDBWriter creates an IPv4 and an IPv6 MMDB writer. Each tree
+// is built in its own goroutine, and both are written out concurrently when
+// the writer is flushed.
+func prepareSplitMMDBWriter(
+	ctx context.Context,
+	cfg *config.Config,
+	wrapOutput func(io.Writer) io.Writer,
+	warnings *warningLog,
+	quiet bool,
+) (row.Writer, []io.Closer, []string, error) {
+	if !quiet {
+		fmt.Println("  Building IPv4 and IPv6 trees concurrently")
+	}
+
+	ipv4Writer, err := writer.NewMMDBWriter(cfg.Output.IPv4File, cfg, 4)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("creating IPv4 MMDB writer: %w", err)
+	}
+	ipv6Writer, err := writer.NewMMDBWriter(cfg.Output.IPv6File, cfg, 6)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("creating IPv6 MMDB writer: %w", err)
+	}
+	ipv4Writer.ReportSkippedInserts(warnSkippedInsert(warnings))
+	ipv6Writer.ReportSkippedInserts(warnSkippedInsert(warnings))
+	if wrapOutput != nil {
+		ipv4Writer.WrapFile(wrapOutput)
+		ipv6Writer.WrapFile(wrapOutput)
+	}
+
+	// Closing stops the goroutines of a run that fails before flushing
+	ipv4Async := writer.NewAsyncWriter(ipv4Writer)
+	ipv6Async := writer.NewAsyncWriter(ipv6Writer)
+	rowWriter := writer.NewSplitRowWriter(
+		telemetry.NewWriter(ctx, ipv4Async, cfg.Output.IPv4File),
+		telemetry.NewWriter(ctx, ipv6Async, cfg.Output.IPv6File),
+	)
+	outputPaths := []string{cfg.Output.IPv4File, cfg.Output.IPv6File}
+	return rowWriter, []io.Closer{ipv4Async, ipv6Async}, outputPaths, nil
+}
+
+// warnSkippedInsert returns the function warning of each network an MMDB
+// writer leaves out under output.mmdb.on_insert_error = "skip".
+func warnSkippedInsert(warnings *warningLog) func(*writer.InsertError) {
+	return func(e *writer.InsertError) {
+		warnings.warn(runWarning{
+			Kind:    warningSkippedInsert,
+			Message: e.Error(),
+			Network: e.Network.String(),
+		})
+	}
+}
+
+// wrapReservedNetworks wraps rowWriter so that reserved networks are written
+// with the configured constant values. IPv6 reserved networks are included
+// only when some database is an IPv6 tree.
+func wrapReservedNetworks(
+	cfg *config.Config,
+	src ipSources,
+	rowWriter row.Writer,
+) (row.Writer, error) {
+	data, err := writer.ReservedRowData(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ipVersion, err := src.treeIPVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	return writer.NewReservedNetworkWriter(
+		rowWriter,
+		writer.ReservedRanges(ipVersion == 6),
+		data,
+	), nil
+}
+
+// createRotatingCSVWriter creates a CSV writer for path that rotates to a
+// new part file at output.csv.max_file_size bytes.
+func createRotatingCSVWriter(
+	path string,
+	cfg *config.Config,
+	wrapOutput func(io.Writer) io.Writer,
+) (*writer.CSVWriter, *writer.PartFiles, error) {
+	parts := writer.NewPartFiles(path, wrapOutput)
+	csvWriter, err := writer.NewRotatingCSVWriter(parts, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating output file: %w", err)
+	}
+	return csvWriter, parts, nil
 }
 
 func createOutputFile(path string) (*os.File, error) {
@@ -393,29 +1962,89 @@ func createOutputFile(path string) (*os.File, error) {
 	return file, nil
 }
 
-func detectIPVersionFromDatabases(cfg *config.Config, readers *mmdb.Readers) (int, error) {
-	// Get the first database from config to detect IP version
-	// In practice, all databases in the merge should have the same IP version
-	// due to validation in merger
-	if len(cfg.Databases) == 0 {
+// ipSources describes the IP versions of the data being converted, read
+// from the source databases or from a merge file.
+type ipSources interface {
+	// treeIPVersion returns 6 if some source database is an IPv6 tree, and
+	// 4 otherwise.
+	treeIPVersion() (int, error)
+	// hasIPv6Networks reports whether some source has IPv6 data.
+	hasIPv6Networks() (bool, error)
+}
+
+// databaseSources are the IP versions of the configured databases.
+type databaseSources struct {
+	cfg     *config.Config
+	readers *mmdb.Readers
+}
+
+// treeIPVersion returns the IP version of the databases. IPv4-only databases
+// mixed with IPv6 databases are merged in separate IPv4 and IPv6 passes,
+// whose output needs an IPv6 tree.
+func (s databaseSources) treeIPVersion() (int, error) {
+	if len(s.cfg.Databases) == 0 {
 		return 0, errors.New("no databases configured")
 	}
 
-	firstDB := cfg.Databases[0].Name
-	reader, ok := readers.Get(firstDB)
-	if !ok {
-		return 0, fmt.Errorf("database '%s' not found", firstDB)
+	ipVersion := 4
+	for _, db := range s.cfg.Databases {
+		reader, ok := s.readers.Get(db.Name)
+		if !ok {
+			return 0, fmt.Errorf("database '%s' not found", db.Name)
+		}
+
+		metadata := reader.Metadata()
+		switch metadata.IPVersion {
+		case 4:
+		case 6:
+			ipVersion = 6
+		default:
+			return 0, fmt.Errorf(
+				"invalid IP version %d in database '%s'",
+				metadata.IPVersion,
+				db.Name,
+			)
+		}
 	}
 
-	metadata := reader.Metadata()
-	//nolint:gosec // IPVersion is always 4 or 6, no overflow risk
-	ipVersion := int(metadata.IPVersion)
+	return ipVersion, nil
+}
 
-	if ipVersion != 4 && ipVersion != 6 {
-		return 0, fmt.Errorf("invalid IP version %d in database '%s'", ipVersion, firstDB)
+func (s databaseSources) hasIPv6Networks() (bool, error) {
+	for _, db := range s.cfg.Databases {
+		reader, ok := s.readers.Get(db.Name)
+		if !ok {
+			return false, fmt.Errorf("database '%s' not found", db.Name)
+		}
+		hasIPv6, err := reader.HasIPv6Networks()
+		if err != nil {
+			return false, fmt.Errorf("checking database '%s': %w", db.Name, err)
+		}
+		if hasIPv6 {
+			return true, nil
+		}
 	}
+	return false, nil
+}
 
-	return ipVersion, nil
+func detectIPVersion(cfg *config.Config, src ipSources) (int, error) {
+	switch cfg.Output.MMDB.IPVersion {
+	case "ipv4":
+		return 4, nil
+	case "ipv6":
+		return 6, nil
+	case "auto":
+		// Build an IPv4 tree unless some database has IPv6 data
+		hasIPv6, err := src.hasIPv6Networks()
+		if err != nil {
+			return 0, err
+		}
+		if hasIPv6 {
+			return 6, nil
+		}
+		return 4, nil
+	}
+	return src.treeIPVersion()
 }
 
 func splitConfiguredPaths(base, ipv4Override, ipv6Override string) (ipv4, ipv6 string) {
@@ -443,7 +2072,7 @@ func splitConfiguredPaths(base, ipv4Override, ipv6Override string) (ipv4, ipv6 s
 	return ipv4, ipv6
 }
 
-func validateParquetNetworkColumns(cfg *config.Config, readers *mmdb.Readers) error {
+func validateParquetNetworkColumns(cfg *config.Config, src ipSources) error {
 	if cfg.Output.Format != "parquet" {
 		return nil
 	}
@@ -457,14 +2086,14 @@ func validateParquetNetworkColumns(cfg *config.Config, readers *mmdb.Readers) er
 		return nil
 	}
 
-	ipVersion, err := detectIPVersionFromDatabases(cfg, readers)
+	ipVersion, err := detectIPVersion(cfg, src)
 	if err != nil {
 		return err
 	}
 
 	if ipVersion == 6 {
 		return errors.New(
-			"network column types 'start_int' and 'end_int' require split IPv4/IPv6 outputs when processing IPv6 databases; set output.ipv4_file and output.ipv6_file or switch to start_ip/end_ip",
+			"network column types 'start_int' and 'end_int' require split IPv4/IPv6 outputs when processing IPv6 databases; set output.split_by_ip_version, or output.ipv4_file and output.ipv6_file, or switch to start_ip/end_ip",
 		)
 	}
 
@@ -484,22 +2113,68 @@ func hasIntegerNetworkColumns(cols []config.NetworkColumn) bool {
 func usage() {
 	fmt.Fprint(
 		os.Stderr,
-		`mmdbconvert - Merge MaxMind MMDB databases and export to CSV, Parquet, or MMDB
+		`mmdbconvert - Merge MaxMind MMDB databases and export to CSV, Parquet, MMDB, and more
 
 USAGE:
     mmdbconvert [OPTIONS] <config-file>
     mmdbconvert --config <config-file> [OPTIONS]
+    mmdbconvert <command> [OPTIONS]
+
+COMMANDS:
+    annotate               Append the configured columns to a CSV or Parquet file of IPs or networks
+    cat                    Print the ranges and columns of earlier CSV, Parquet, or merge file exports
+    compare                Report agreement between two columns from different databases
+    coverage               Report the share of the address space populated per database and column
+    example                Write a working example configuration with a sample database and run it
+    netset <op>            Union, intersect, or subtract the networks of exports or CIDR lists
+    re-export              Export a merge file saved with --save-merge without merging again
+    selftest               Convert the MaxMind-DB test databases to every format and check the outputs
+    spotcheck              Compare merged lookups for a list of IPs against expected values
+    validate               Check the configuration and profile the column value types of a sample
 
 OPTIONS:
     --config <file>        Path to TOML configuration file
     --quiet                Suppress progress output
+    -v, --verbose          Also report the merge time per database: iteration, decoding,
+                           path walks, accumulation and writing
+    --dry-run              Estimate output rows and size from a sample without writing output
+    --redact               Apply the redact policies of the data columns
+    --check                Verify the networks of every row before it is written
+    --tenants <pattern>    Write the outputs of each tenant overlay matching the pattern
+                           instead of [output], from one merge
+    --save-merge <file>    Also save the merged rows to a merge file for re-export
+    --summary-json <file>  Also write the end-of-run summary, with stage timings and resource
+                           usage, as JSON
+    --warnings-json <file> Also write the warnings of the run, even a failed one, as JSON
+    --qa-sample <file>     Also write a random sample of the rows of [output] to a CSV file
+    --qa-rate <share>      Share of the rows sampled for --qa-sample (default: 0.001)
+    --merge-breaks         Report the data columns whose differing values kept the most
+                           adjacent networks from merging
+    --trace-network <nets> Record the database records, values, and rows merged for these
+                           comma-separated networks
+    --trace-network-file <file>
+                           File to write the --trace-network trace to (default:
+                           network-trace.json)
     --disable-cache        Disable MMDB unmarshaler caching to reduce memory (several times slower)
+    --compat-check <lib>   Verify MMDB output decodes with a client library's structs (geoip2)
+    --compat-samples <n>   Networks to decode for --compat-check (default: 1000, 0 for all)
+    --throttle-networks <n>
+                           Maximum networks written per second (default: no limit)
+    --throttle-write-bytes <n>
+                           Maximum bytes written to the output per second (default: no limit)
+    --throttle-max-load <load>
+                           Pause while the one-minute load average is above this (Linux only)
     --cpuprofile <file>    Write CPU profile to file
     --memprofile <file>    Write memory profile to file
+    --pprof-addr <addr>    Serve net/http/pprof endpoints during the run (e.g. localhost:6060)
+    --trace <file>         Write runtime execution trace to file
     --help                 Show this help message
     --version              Show version information
 
 EXAMPLES:
+    # Start from a working example with a generated GeoLite2-City sample
+    mmdbconvert example --edition GeoLite2-City
+
     # Basic usage with config file
     mmdbconvert config.toml
 
@@ -509,9 +2184,60 @@ EXAMPLES:
     # Suppress progress output
     mmdbconvert --config config.toml --quiet
 
+    # Estimate the output size before running a large export
+    mmdbconvert --config config.toml --dry-run
+
+    # Break the merge time down by database
+    mmdbconvert --config config.toml -v
+
+    # Export with the columns' redact policies applied, for sharing
+    mmdbconvert --config config.toml --redact
+
+    # Write a 0.1% sample of the exported rows for the data-quality team to review
+    mmdbconvert --config config.toml --qa-sample sample.csv --qa-rate 0.001
+
+    # Merge once, then export the merge to other formats with their own configs
+    mmdbconvert --config csv.toml --save-merge merged.bin
+    mmdbconvert re-export --config parquet.toml --input merged.bin
+
     # Profile performance
     mmdbconvert --config config.toml --cpuprofile cpu.prof --memprofile mem.prof --quiet
 
+    # Profile a long production run while it is in progress
+    mmdbconvert --config config.toml --pprof-addr localhost:6060 --trace run.trace
+    go tool pprof http://localhost:6060/debug/pprof/profile?seconds=30
+
+    # Run on a shared host: 50,000 networks/s, 20 MB/s, pause above load 8
+    mmdbconvert --config config.toml --throttle-networks 50000 \
+        --throttle-write-bytes 20000000 --throttle-max-load 8
+
+    # Check MMDB output against the geoip2-golang record structs
+    mmdbconvert --config config.toml --compat-check geoip2
+
+    # Report column coverage, broken down by country
+    mmdbconvert coverage --config config.toml --by country_code
+
+    # Compare the country reported by two databases
+    mmdbconvert compare --config config.toml --left vendor_a_country --right vendor_b_country
+
+    # Check known IPs against expected values (exits non-zero on mismatch)
+    mmdbconvert spotcheck --config config.toml --ips ips.txt --expect expected.csv
+
+    # Add the configured columns to each flow, looked up by source address
+    mmdbconvert annotate --config config.toml --input flows.parquet --ip-column src_ip --output flows_enriched.parquet
+
+    # Print a Parquet export as CSV, ranges first
+    mmdbconvert cat --config config.toml merged.parquet
+
+    # Anonymous networks that are not in an allowlist of CIDRs
+    mmdbconvert netset subtract anonymous.csv allowlist.txt > blocked.txt
+
+    # Profile the value types of every column before a full run
+    mmdbconvert validate --config config.toml
+
+    # Verify this build on this platform with the MaxMind-DB test databases
+    mmdbconvert selftest --test-data MaxMind-DB/test-data
+
 CONFIGURATION:
     See docs/config.md for configuration file format and options.
 
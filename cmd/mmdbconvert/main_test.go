@@ -1,14 +1,20 @@
 package main
 
 import (
+	"io"
+	"net/netip"
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
 	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/writer"
 )
 
 const testDataDir = "../../testdata/MaxMind-DB/test-data"
@@ -41,7 +47,7 @@ func TestValidateParquetNetworkColumns_IPv6SingleFileError(t *testing.T) {
 	}
 
 	readers := openTestReaders(t, cfg)
-	err := validateParquetNetworkColumns(cfg, readers)
+	err := validateParquetNetworkColumns(cfg, databaseSources{cfg: cfg, readers: readers})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "start_int")
 }
@@ -75,7 +81,7 @@ func TestValidateParquetNetworkColumns_SplitOutputsAllowed(t *testing.T) {
 	}
 
 	readers := openTestReaders(t, cfg)
-	require.NoError(t, validateParquetNetworkColumns(cfg, readers))
+	require.NoError(t, validateParquetNetworkColumns(cfg, databaseSources{cfg: cfg, readers: readers}))
 }
 
 func TestValidateParquetNetworkColumns_IPv4SingleFileAllowed(t *testing.T) {
@@ -105,7 +111,57 @@ func TestValidateParquetNetworkColumns_IPv4SingleFileAllowed(t *testing.T) {
 	}
 
 	readers := openTestReaders(t, cfg)
-	require.NoError(t, validateParquetNetworkColumns(cfg, readers))
+	require.NoError(t, validateParquetNetworkColumns(cfg, databaseSources{cfg: cfg, readers: readers}))
+}
+
+func TestLoadTenants(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+[output]
+format = "csv"
+file = "shared.csv"
+
+[[databases]]
+name = "geo"
+path = "geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`), 0o600))
+	tenants := filepath.Join(dir, "tenants")
+	require.NoError(t, os.Mkdir(tenants, 0o750))
+	pattern := filepath.Join(tenants, "*.toml")
+
+	require.NoError(t, os.WriteFile(
+		filepath.Join(tenants, "acme.toml"),
+		[]byte("redact = true\n\n[output]\nfile = \"acme.csv\"\n"),
+		0o600,
+	))
+	require.NoError(t, os.WriteFile(filepath.Join(tenants, "globex.toml"), nil, 0o600))
+
+	outputs, err := loadTenants(configPath, pattern, false)
+	require.NoError(t, err)
+	require.Len(t, outputs, 2)
+	assert.Equal(t, "acme", outputs[0].tenant)
+	assert.True(t, outputs[0].redact)
+	assert.Equal(t, "acme.csv", outputs[0].cfg.Output.File)
+	assert.Equal(t, "globex", outputs[1].tenant)
+	assert.False(t, outputs[1].redact)
+
+	// Without the placeholder, two tenants write the base's file
+	require.NoError(t, os.WriteFile(filepath.Join(tenants, "initech.toml"), nil, 0o600))
+	_, err = loadTenants(configPath, pattern, false)
+	require.EqualError(
+		t,
+		err,
+		"tenant 'initech': 'shared.csv' is also written by tenant 'globex'; use {tenant} in the output file names",
+	)
+
+	_, err = loadTenants(configPath, filepath.Join(dir, "missing", "*.toml"), false)
+	require.ErrorContains(t, err, "--tenants: no files match")
 }
 
 func openTestReaders(t *testing.T, cfg *config.Config) *mmdb.Readers {
@@ -118,3 +174,86 @@ func openTestReaders(t *testing.T, cfg *config.Config) *mmdb.Readers {
 	t.Cleanup(func() { _ = readers.Close() })
 	return readers
 }
+
+func TestDescribeEmptyColumn(t *testing.T) {
+	cfg := &config.Config{
+		Databases: []config.Database{{Name: "city", Path: "/data/GeoIP2-City.mmdb"}},
+	}
+	assert.Equal(
+		t,
+		"column 'city' has no value in any row; check path [city names en] in database 'city' (/data/GeoIP2-City.mmdb)",
+		describeEmptyColumn(cfg, config.Column{
+			Name:     "city",
+			Database: "city",
+			Path:     config.Path{"city", "names", "en"},
+		}),
+	)
+	assert.Equal(
+		t,
+		"column 'city_prefix' has no value in any row; check database 'city' (/data/GeoIP2-City.mmdb)",
+		describeEmptyColumn(cfg, config.Column{Name: "city_prefix", Database: "city", PrefixLength: true}),
+	)
+	assert.Equal(
+		t,
+		"column 'score' has no value in any row",
+		describeEmptyColumn(cfg, config.Column{Name: "score", Kind: config.ColumnKindScore}),
+	)
+}
+
+func TestAddAdditionalOutputs_IgnoredErrors(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+[output]
+format = "msgpack"
+file = "`+filepath.Join(dir, "all.msgpack")+`"
+
+[[outputs]]
+format = "msgpack"
+file = "`+filepath.Join(dir, "asn.msgpack")+`"
+columns = ["asn"]
+
+[[outputs.ignore_errors]]
+network = "1.0.0.0/8"
+reason = "unparsable ASNs"
+
+[[databases]]
+name = "isp"
+path = "isp.mmdb"
+
+[[columns]]
+name = "asn"
+database = "isp"
+path = ["autonomous_system_number"]
+type = "int64"
+`), 0o600))
+	cfg, err := config.LoadConfig(configPath)
+	require.NoError(t, err)
+
+	warnings := &warningLog{}
+	outputs, closers, err := addAdditionalOutputs(
+		t.Context(),
+		cfg,
+		databaseSources{cfg: cfg},
+		writer.NewCSVWriter(io.Discard, cfg),
+		nil,
+		false,
+		false,
+		warnings,
+		true,
+	)
+	for _, closer := range closers {
+		defer closer.Close()
+	}
+	require.NoError(t, err)
+
+	// The row the additional output cannot write is skipped and counted
+	require.NoError(t, outputs.writer.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("AS13335")},
+	))
+	require.NoError(t, row.Flush(outputs.writer))
+	require.Len(t, outputs.ignoreWriters, 1)
+	assert.Equal(t, 1, outputs.ignoreWriters[0].Skipped())
+	assert.Equal(t, 1, warnings.count(warningIgnoredError))
+}
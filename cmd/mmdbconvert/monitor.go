@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/heartbeat"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/watchdog"
+)
+
+// runMonitors follow the progress of a run: the heartbeat and the watchdog,
+// each nil unless configured.
+type runMonitors struct {
+	heartbeat *heartbeat.Heartbeat
+	watchdog  *watchdog.Watchdog
+
+	// checkpoints are those of the merge in progress, for the diagnostics
+	// of a stall.
+	checkpoints atomic.Pointer[merger.Checkpoints]
+}
+
+// startWatchdog starts the watchdog if cfg configures one, counting the
+// bytes written to the outputs, written, as progress.
+func (m *runMonitors) startWatchdog(cfg *config.Config, written *atomic.Int64) {
+	if cfg.Watchdog.StallSeconds <= 0 {
+		return
+	}
+	m.watchdog = watchdog.Start(time.Duration(cfg.Watchdog.StallSeconds)*time.Second, m.abort)
+	m.watchdog.Watch(watchdog.Counter{Name: "bytes_written", Value: written.Load})
+}
+
+// SetStage records that the run has entered stage.
+func (m *runMonitors) SetStage(stage string) {
+	m.heartbeat.SetStage(stage)
+	m.watchdog.SetStage(stage)
+}
+
+// trackMerge takes checkpoints of the merge of mrg, if some monitor uses
+// them, and returns the function to call once the merge is over.
+func (m *runMonitors) trackMerge(mrg *merger.Merger) func() {
+	if m.heartbeat == nil && m.watchdog == nil {
+		return func() {}
+	}
+	checkpoints := mrg.EnableCheckpoints()
+	m.checkpoints.Store(checkpoints)
+	m.heartbeat.TrackPosition(mergePosition(checkpoints))
+	m.watchdog.Watch(watchdog.Counter{
+		Name: "networks_merged",
+		Value: func() int64 {
+			checkpoint, _ := checkpoints.Latest()
+			return checkpoint.Networks
+		},
+	})
+	return func() {
+		m.heartbeat.TrackPosition(nil)
+		m.checkpoints.Store(nil)
+	}
+}
+
+// abort ends a stalled run, reporting the stall, the position of the merge
+// if one is in progress, and the stacks of every goroutine, which show where
+// the run is blocked.
+func (m *runMonitors) abort(stall watchdog.Stall) {
+	err := fmt.Errorf(
+		"no progress for %v in stage %s; aborting",
+		stall.Duration.Round(time.Second),
+		stall.Stage,
+	)
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	for _, counter := range stall.Counters {
+		fmt.Fprintf(os.Stderr, "  %s: %d\n", counter.Name, counter.Value)
+	}
+	if checkpoints := m.checkpoints.Load(); checkpoints != nil {
+		if checkpoint, ok := checkpoints.Latest(); ok {
+			fmt.Fprintf(os.Stderr, "  merging: %s\n", describeCheckpoint(checkpoint))
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Goroutines:")
+	_ = pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+
+	if stopErr := m.heartbeat.Stop(err); stopErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", stopErr)
+	}
+	os.Exit(1)
+}
+
+// describeCheckpoint describes where a merge is, such as "81.2.69.128/25
+// (city at 81.2.69.0/24, anonymous at 81.2.69.128/25)".
+func describeCheckpoint(checkpoint merger.Checkpoint) string {
+	databases := make([]string, len(checkpoint.Databases))
+	for i, db := range checkpoint.Databases {
+		databases[i] = db.Name + " not read"
+		if db.Network.IsValid() {
+			databases[i] = db.Name + " at " + db.Network.String()
+		}
+	}
+	return fmt.Sprintf("%s (%s)", checkpoint.Network, strings.Join(databases, ", "))
+}
+
+// mergePosition returns the function giving the heartbeat the position of
+// the merge taking checkpoints.
+func mergePosition(checkpoints *merger.Checkpoints) func() (heartbeat.Position, bool) {
+	return func() (heartbeat.Position, bool) {
+		checkpoint, ok := checkpoints.Latest()
+		if !ok {
+			return heartbeat.Position{}, false
+		}
+		position := heartbeat.Position{
+			Network:   checkpoint.Network.String(),
+			Networks:  checkpoint.Networks,
+			Databases: make([]heartbeat.DatabasePosition, len(checkpoint.Databases)),
+		}
+		for i, db := range checkpoint.Databases {
+			position.Databases[i].Name = db.Name
+			if db.Network.IsValid() {
+				position.Databases[i].Network = db.Network.String()
+			}
+		}
+		return position, true
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxmind/mmdbconvert/internal/merger"
+)
+
+func TestDescribeCheckpoint(t *testing.T) {
+	checkpoint := merger.Checkpoint{
+		Network:  netip.MustParsePrefix("81.2.69.128/25"),
+		Networks: 31,
+		Databases: []merger.DatabasePosition{
+			{Name: "city", Network: netip.MustParsePrefix("81.2.69.0/24")},
+			{Name: "legacy"},
+		},
+	}
+	assert.Equal(
+		t,
+		"81.2.69.128/25 (city at 81.2.69.0/24, legacy not read)",
+		describeCheckpoint(checkpoint),
+	)
+}
+
+func TestRunMonitors_Nil(t *testing.T) {
+	// Without a heartbeat or watchdog, stages and merges are not tracked.
+	monitors := &runMonitors{}
+	monitors.SetStage("merge")
+	monitors.trackMerge(nil)()
+	assert.Nil(t, monitors.checkpoints.Load())
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/export"
+)
+
+// netsetOps are the set operations of the netset subcommand. Each combines
+// the sets read from the files, in order.
+var netsetOps = map[string]func(b *netipx.IPSetBuilder, set *netipx.IPSet){
+	"union":     (*netipx.IPSetBuilder).AddSet,
+	"intersect": (*netipx.IPSetBuilder).Intersect,
+	"subtract":  (*netipx.IPSetBuilder).RemoveSet,
+}
+
+// runNetset implements the "netset" subcommand. It reads the networks of
+// two or more files, either earlier exports or plain lists of networks,
+// combines them with a set operation, and prints the result as the fewest
+// CIDRs or ranges covering it.
+func runNetset(args []string) error {
+	if len(args) == 0 || netsetOps[args[0]] == nil {
+		return errors.New("operation required: union, intersect, or subtract")
+	}
+	op := args[0]
+
+	fs := flag.NewFlagSet("netset "+op, flag.ContinueOnError)
+	var (
+		configPath string
+		ranges     bool
+	)
+	fs.StringVar(
+		&configPath,
+		"config",
+		"",
+		"Configuration the exports were written with, for their network columns and CSV settings",
+	)
+	fs.BoolVar(&ranges, "ranges", false, "Print start-end ranges instead of CIDRs")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("at least two files required")
+	}
+
+	var opts export.Options
+	if configPath != "" {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		opts = export.OptionsFromConfig(cfg)
+	}
+
+	result, err := combineNetworkSets(op, fs.Args(), func(path string) (*netipx.IPSet, error) {
+		return readNetworkSet(path, opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	if ranges {
+		for _, r := range result.Ranges() {
+			fmt.Fprintf(out, "%s-%s\n", r.From(), r.To())
+		}
+	} else {
+		for _, prefix := range result.Prefixes() {
+			fmt.Fprintln(out, prefix)
+		}
+	}
+	return out.Flush()
+}
+
+// combineNetworkSets applies op to the sets of the files in order: the
+// first set is combined with each of the following ones.
+func combineNetworkSets(
+	op string,
+	paths []string,
+	read func(path string) (*netipx.IPSet, error),
+) (*netipx.IPSet, error) {
+	var b netipx.IPSetBuilder
+	for i, path := range paths {
+		set, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			b.AddSet(set)
+			continue
+		}
+		netsetOps[op](&b, set)
+	}
+	return b.IPSet()
+}
+
+// readNetworkSet reads the networks of the file at path. A file whose first
+// line is a CIDR, an address, or a start-end range is a plain list of them;
+// anything else is an export, whose rows' ranges are read.
+func readNetworkSet(path string, opts export.Options) (*netipx.IPSet, error) {
+	// #nosec G304 -- path comes from a trusted command-line argument
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if isNetworkList(f) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		set, err := parseNetworkList(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return set, nil
+	}
+
+	r, err := export.Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var b netipx.IPSetBuilder
+	for {
+		start, end, _, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		b.AddRange(netipx.IPRangeFrom(start, end))
+	}
+	return b.IPSet()
+}
+
+// isNetworkList reports whether the first line of r, after blank lines and
+// '#' comments, is a network.
+func isNetworkList(r io.Reader) bool {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		_, err := parseNetworkLine(line)
+		return err == nil
+	}
+	return false
+}
+
+// parseNetworkList reads one CIDR, address, or start-end range per line,
+// ignoring blank lines and lines starting with '#'.
+func parseNetworkList(r io.Reader) (*netipx.IPSet, error) {
+	var b netipx.IPSetBuilder
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		ipRange, err := parseNetworkLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		b.AddRange(ipRange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b.IPSet()
+}
+
+func parseNetworkLine(s string) (netipx.IPRange, error) {
+	if strings.Contains(s, "-") {
+		return netipx.ParseIPRange(s)
+	}
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netipx.IPRange{}, err
+		}
+		return netipx.RangeOfPrefix(prefix.Masked()), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netipx.IPRange{}, err
+	}
+	return netipx.IPRangeFrom(addr, addr), nil
+}
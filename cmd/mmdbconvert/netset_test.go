@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/export"
+)
+
+func TestParseNetworkList(t *testing.T) {
+	set, err := parseNetworkList(strings.NewReader(`
+# allowlist
+1.0.0.0/24
+1.0.1.7/24
+2.0.0.1
+3.0.0.0-3.0.0.255
+2001:db8::/32
+`))
+	require.NoError(t, err)
+
+	var prefixes []string
+	for _, prefix := range set.Prefixes() {
+		prefixes = append(prefixes, prefix.String())
+	}
+	assert.Equal(t, []string{
+		"1.0.0.0/23",
+		"2.0.0.1/32",
+		"3.0.0.0/24",
+		"2001:db8::/32",
+	}, prefixes)
+
+	_, err = parseNetworkList(strings.NewReader("1.0.0.0/24\nnot-an-ip\n"))
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestCombineNetworkSets(t *testing.T) {
+	sets := map[string]string{
+		"a": "1.0.0.0/16\n2001:db8::/32\n",
+		"b": "1.0.128.0/17\n2.0.0.0/24\n",
+		"c": "1.0.192.0/18\n",
+	}
+	read := func(path string) (*netipx.IPSet, error) {
+		return parseNetworkList(strings.NewReader(sets[path]))
+	}
+
+	tests := []struct {
+		op       string
+		paths    []string
+		expected []string
+	}{
+		{
+			op:       "union",
+			paths:    []string{"a", "b", "c"},
+			expected: []string{"1.0.0.0/16", "2.0.0.0/24", "2001:db8::/32"},
+		},
+		{
+			op:       "intersect",
+			paths:    []string{"a", "b"},
+			expected: []string{"1.0.128.0/17"},
+		},
+		{
+			op:       "intersect",
+			paths:    []string{"a", "b", "c"},
+			expected: []string{"1.0.192.0/18"},
+		},
+		{
+			op:       "subtract",
+			paths:    []string{"a", "b"},
+			expected: []string{"1.0.0.0/17", "2001:db8::/32"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op+" "+strings.Join(tt.paths, ","), func(t *testing.T) {
+			set, err := combineNetworkSets(tt.op, tt.paths, read)
+			require.NoError(t, err)
+			var prefixes []string
+			for _, prefix := range set.Prefixes() {
+				prefixes = append(prefixes, prefix.String())
+			}
+			assert.Equal(t, tt.expected, prefixes)
+		})
+	}
+}
+
+func TestReadNetworkSet_Export(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"# tool_version=1.2.3\nnetwork,country\n1.0.0.0/24,US\n1.0.1.0/24,FR\n",
+	), 0o600))
+
+	set, err := readNetworkSet(path, export.Options{})
+	require.NoError(t, err)
+	require.Len(t, set.Prefixes(), 1)
+	assert.Equal(t, "1.0.0.0/23", set.Prefixes()[0].String())
+}
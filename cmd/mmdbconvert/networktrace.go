@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/maxmind/mmdbconvert/internal/merger"
+)
+
+// parseTraceNetworks parses the comma-separated networks of
+// --trace-network. An IP address traces its own network.
+func parseTraceNetworks(s string) ([]netip.Prefix, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var networks []netip.Prefix
+	for field := range strings.SplitSeq(s, ",") {
+		field = strings.TrimSpace(field)
+		if addr, err := netip.ParseAddr(field); err == nil {
+			addr = addr.Unmap()
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, fmt.Errorf("--trace-network: invalid network '%s'", field)
+		}
+		networks = append(networks, prefix.Masked())
+	}
+	return networks, nil
+}
+
+// writeNetworkTrace writes the trace of the merge of the traced networks
+// to path as JSON.
+func writeNetworkTrace(path string, trace *merger.Trace, quiet bool) error {
+	data, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing network trace: %w", err)
+	}
+	if !quiet {
+		fmt.Printf(
+			"  Traced %d merged networks and %d rows to %s\n",
+			len(trace.Steps),
+			len(trace.Rows),
+			path,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceNetworks(t *testing.T) {
+	networks, err := parseTraceNetworks("81.2.69.7/24, 2001:db8::1,::ffff:1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("81.2.69.0/24"),
+		netip.MustParsePrefix("2001:db8::1/128"),
+		netip.MustParsePrefix("1.2.3.4/32"),
+	}, networks)
+
+	networks, err = parseTraceNetworks("")
+	require.NoError(t, err)
+	assert.Empty(t, networks)
+
+	_, err = parseTraceNetworks("81.2.69.0/24,81.2.69.0/33")
+	require.EqualError(t, err, "--trace-network: invalid network '81.2.69.0/33'")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// qaSampleSummary describes the sample written with --qa-sample.
+type qaSampleSummary struct {
+	File    string  `json:"file"`
+	Rate    float64 `json:"rate"`
+	Rows    int     `json:"rows"`    // Rows written to [output]
+	Sampled int     `json:"sampled"` // Rows copied to the sample
+}
+
+// validateQASample checks the --qa-sample options against the
+// configuration of the run.
+func validateQASample(path string, rate float64, cfg *config.Config, tenants string) error {
+	if path == "" {
+		return nil
+	}
+	if tenants != "" {
+		return errors.New("--qa-sample is not supported with --tenants")
+	}
+	if rate <= 0 || rate > 1 {
+		return fmt.Errorf("--qa-rate must be greater than 0 and at most 1, got %v", rate)
+	}
+	if slices.Contains(cfg.OutputFiles(), path) {
+		return errors.New("--qa-sample cannot be an output file")
+	}
+	return nil
+}
+
+// createQASample creates the CSV file at path that a share rate of the rows
+// written to the output of cfg, drawn at random, is copied to. The sample
+// has the columns of the output, and a CIDR column named network if the
+// output has no network columns, as for MMDB output. The file is complete
+// once the returned writer is flushed.
+func createQASample(
+	path string,
+	rate float64,
+	cfg *config.Config,
+) (*writer.SampleWriter, *os.File, error) {
+	includeHeader := true
+	sampleCfg := *cfg
+	sampleCfg.Output = config.OutputConfig{
+		Format: "csv",
+		File:   path,
+		CSV:    config.CSVConfig{Delimiter: ",", IncludeHeader: &includeHeader},
+	}
+	if len(cfg.Network.Columns) == 0 {
+		sampleCfg.Network.Columns = []config.NetworkColumn{
+			{Name: "network", Type: writer.NetworkColumnCIDR},
+		}
+	}
+
+	f, err := createOutputFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating QA sample: %w", err)
+	}
+	src := rand.NewPCG(rand.Uint64(), rand.Uint64())
+	return writer.NewSampleWriter(writer.NewCSVWriter(f, &sampleCfg), rate, src), f, nil
+}
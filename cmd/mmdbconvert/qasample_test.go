@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestValidateQASample(t *testing.T) {
+	cfg := &config.Config{Output: config.OutputConfig{Format: "csv", File: "geo.csv"}}
+
+	require.NoError(t, validateQASample("", 5, cfg, ""))
+	require.NoError(t, validateQASample("sample.csv", 1, cfg, ""))
+	require.EqualError(
+		t,
+		validateQASample("sample.csv", 0, cfg, ""),
+		"--qa-rate must be greater than 0 and at most 1, got 0",
+	)
+	require.EqualError(
+		t,
+		validateQASample("geo.csv", 0.01, cfg, ""),
+		"--qa-sample cannot be an output file",
+	)
+	require.EqualError(
+		t,
+		validateQASample("sample.csv", 0.01, cfg, "tenants/*.toml"),
+		"--qa-sample is not supported with --tenants",
+	)
+}
+
+func TestCreateQASample(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.csv")
+	// MMDB output has no network columns, which the sample needs
+	cfg := &config.Config{
+		Output:  config.OutputConfig{Format: "mmdb", File: "geo.mmdb"},
+		Network: config.NetworkConfig{Columns: []config.NetworkColumn{}},
+		Columns: []config.Column{
+			{Name: "country", Aliases: config.Aliases{Header: "Country"}},
+		},
+	}
+	w, f, err := createQASample(path, 1, cfg)
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{mmdbtype.String("AU")}))
+	require.NoError(t, w.Flush())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "network,Country\n1.0.0.0/24,AU\n", string(data))
+	assert.Empty(t, cfg.Network.Columns, "the configuration of the output is left as is")
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mergefile"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/verify"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// runReexport implements the "re-export" subcommand. It writes the merged
+// rows saved in a merge file by --save-merge to the output of a
+// configuration, without opening the databases. The data columns of the
+// configuration are taken by name from the merge file; the output and
+// network column settings apply as in a full run.
+func runReexport(args []string) error {
+	fs := flag.NewFlagSet("re-export", flag.ContinueOnError)
+	var (
+		configPath string
+		inputPath  string
+		redact     bool
+		check      bool
+		quiet      bool
+	)
+	fs.StringVar(&configPath, "config", "", "Path to TOML configuration file")
+	fs.StringVar(&inputPath, "input", "", "Merge file written with --save-merge")
+	fs.BoolVar(&redact, "redact", false, "Apply the redact policies of the data columns")
+	fs.BoolVar(&check, "check", false, "Verify the networks of every row before it is written")
+	fs.BoolVar(&quiet, "quiet", false, "Suppress progress output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		if fs.NArg() == 0 {
+			return errors.New("config file path required")
+		}
+		configPath = fs.Arg(0)
+	}
+	if inputPath == "" {
+		return errors.New("--input is required")
+	}
+
+	startTime := time.Now()
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	warnCaseCollisions(cfg, nil)
+	if redact && !hasRedactPolicy(cfg) {
+		return errRedactWithoutPolicy
+	}
+
+	input, err := mergefile.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	header := input.Header()
+
+	columns := make([]string, len(cfg.Columns))
+	for i, col := range cfg.Columns {
+		columns[i] = string(col.Name)
+		if !slices.Contains(header.Columns, columns[i]) {
+			return fmt.Errorf(
+				"column '%s' is not in %s, which has: %s",
+				col.Name,
+				inputPath,
+				strings.Join(header.Columns, ", "),
+			)
+		}
+	}
+
+	cfg.Provenance.ToolVersion = version
+	cfg.Provenance.Sources = header.Sources
+	if redact {
+		recordRedactions(cfg)
+	}
+
+	src := mergeFileSources{input}
+	if err := validateParquetNetworkColumns(cfg, src); err != nil {
+		return fmt.Errorf("validating network columns: %w", err)
+	}
+
+	if !quiet {
+		fmt.Printf(
+			"Re-exporting %s (%d ranges, %d distinct rows) as %s\n",
+			inputPath,
+			input.Ranges(),
+			input.Rows(),
+			cfg.Output.Format,
+		)
+	}
+
+	wrapOutput, written := countOutput(cfg, nil)
+	rowWriter, closers, outputPaths, err := prepareRowWriter(
+		context.Background(),
+		cfg,
+		src,
+		wrapOutput,
+		nil,
+		quiet,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}()
+
+	rowWriter, verifier := verifyOutput(cfg, rowWriter, outputPaths)
+	rowWriter, ignoreWriter, err := wrapRowWriter(cfg, src, rowWriter, redact, check, written, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := input.Export(rowWriter, columns, *cfg.Output.IncludeEmptyRows); err != nil {
+		return fmt.Errorf("re-exporting %s: %w", inputPath, err)
+	}
+	if err := row.Flush(rowWriter); err != nil {
+		return fmt.Errorf("flushing output: %w", err)
+	}
+	if verifier != nil {
+		if err := runVerifiers([]*verify.Writer{verifier}, quiet); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Output.Retention.Enabled() {
+		removed, err := writer.PruneDataset(cfg.Output.File, cfg.Output.Retention, time.Now())
+		if err != nil {
+			return fmt.Errorf("applying output.retention: %w", err)
+		}
+		if !quiet {
+			for _, path := range removed {
+				fmt.Printf("Removed old dataset part: %s\n", path)
+			}
+		}
+	}
+
+	if !quiet {
+		fmt.Printf(
+			"✓ Successfully completed in %v\n",
+			time.Since(startTime).Round(time.Millisecond),
+		)
+		for _, path := range outputPaths {
+			fmt.Printf("Output written to: %s\n", path)
+		}
+		if ignoreWriter != nil && ignoreWriter.Skipped() > 0 {
+			fmt.Printf(
+				"Skipped %d rows in output.ignore_errors networks (see warnings)\n",
+				ignoreWriter.Skipped(),
+			)
+		}
+	}
+	return nil
+}
+
+// mergeFileSources are the IP versions recorded in a merge file.
+type mergeFileSources struct {
+	input *mergefile.Reader
+}
+
+func (s mergeFileSources) treeIPVersion() (int, error) {
+	switch ipVersion := s.input.Header().IPVersion; ipVersion {
+	case 4, 6:
+		return ipVersion, nil
+	default:
+		return 0, fmt.Errorf("invalid IP version %d in merge file", ipVersion)
+	}
+}
+
+func (s mergeFileSources) hasIPv6Networks() (bool, error) {
+	return s.input.HasIPv6Networks(), nil
+}
+
+// createMergeFile creates the merge file at path for --save-merge. The file
+// is completed when the returned writer is flushed.
+func createMergeFile(
+	path string,
+	cfg *config.Config,
+	src ipSources,
+) (*mergefile.Writer, *os.File, error) {
+	ipVersion, err := src.treeIPVersion()
+	if err != nil {
+		return nil, nil, fmt.Errorf("detecting IP version: %w", err)
+	}
+	columns := make([]string, len(cfg.Columns))
+	for i, col := range cfg.Columns {
+		columns[i] = string(col.Name)
+	}
+
+	file, err := createOutputFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating merge file: %w", err)
+	}
+	w, err := mergefile.NewWriter(file, mergefile.Header{
+		Columns:     columns,
+		IPVersion:   ipVersion,
+		ToolVersion: version,
+		Sources:     cfg.Provenance.Sources,
+	})
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return w, file, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/maxmind/mmdbconvert/internal/selftest"
+)
+
+// defaultTestDataDir is where a checkout of this repository has the
+// MaxMind-DB test databases.
+var defaultTestDataDir = filepath.Join("testdata", "MaxMind-DB", "test-data")
+
+// runSelftest implements the "selftest" subcommand. It converts the
+// MaxMind-DB test databases through every output format with this build and
+// checks each output, returning an error if any check fails.
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	var (
+		testDataDir string
+		workDir     string
+	)
+	fs.StringVar(
+		&testDataDir,
+		"test-data",
+		defaultTestDataDir,
+		"Directory with the MaxMind-DB test databases",
+	)
+	fs.StringVar(
+		&workDir,
+		"work-dir",
+		"",
+		"Keep the configurations and outputs in this directory (default: a temporary directory)",
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if workDir == "" {
+		dir, err := os.MkdirTemp("", "mmdbconvert-selftest-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		workDir = dir
+	} else if err := os.MkdirAll(workDir, 0o750); err != nil {
+		return err
+	}
+
+	results, err := selftest.Run(testDataDir, workDir, func(configPath string) error {
+		return run(context.Background(), configPath, runOptions{quiet: true})
+	})
+	if err != nil {
+		return fmt.Errorf(
+			"%w (pass the test-data directory of a clone of https://github.com/maxmind/MaxMind-DB with --test-data)",
+			err,
+		)
+	}
+	return reportSelftest(os.Stdout, results)
+}
+
+// reportSelftest writes a line per format and returns an error if any
+// format failed.
+func reportSelftest(w io.Writer, results []selftest.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Format\tRows\tResult")
+	failed := 0
+	for _, r := range results {
+		status := "ok"
+		if r.Err != nil {
+			status = "FAILED: " + r.Err.Error()
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", r.Format, r.Rows, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d formats failed", failed, len(results))
+	}
+	fmt.Fprintf(w, "All %d formats passed\n", len(results))
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/selftest"
+)
+
+// cityRecord is a record of the City test database.
+func cityRecord(country string, geonameID uint32, latitude float64) mmdbtype.Map {
+	return mmdbtype.Map{
+		"country":  mmdbtype.Map{"iso_code": mmdbtype.String(country)},
+		"city":     mmdbtype.Map{"geoname_id": mmdbtype.Uint32(geonameID)},
+		"location": mmdbtype.Map{"latitude": mmdbtype.Float64(latitude)},
+	}
+}
+
+// selftestFixtures stand in for the MaxMind-DB test databases, with the
+// records the self-test relies on.
+var selftestFixtures = []fixture{
+	{
+		name:    "GeoIP2-City-Test",
+		options: mmdbwriter.Options{DatabaseType: "GeoIP2-City", IPVersion: 6, RecordSize: 28},
+		networks: map[string]mmdbtype.DataType{
+			"81.2.69.142/31":   cityRecord("GB", 2643743, 51.5142),
+			"89.160.20.112/28": cityRecord("SE", 2694762, 58.4167),
+			"216.160.83.56/29": cityRecord("US", 5803556, 47.2513),
+			"2.125.160.216/29": cityRecord("GB", 2655045, 51.75),
+			"2001:218::/32":    cityRecord("JP", 1850147, 35.68536),
+		},
+	},
+	{
+		name:    "GeoIP2-Anonymous-IP-Test",
+		options: mmdbwriter.Options{DatabaseType: "GeoIP2-Anonymous-IP", IPVersion: 6, RecordSize: 28},
+		networks: map[string]mmdbtype.DataType{
+			"81.2.69.0/24": mmdbtype.Map{"is_anonymous": mmdbtype.Bool(true)},
+			"1.2.0.0/16":   mmdbtype.Map{"is_anonymous": mmdbtype.Bool(true)},
+		},
+	},
+}
+
+func TestSelftest_Fixtures(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range selftestFixtures {
+		buildFixture(t, dir, f)
+	}
+
+	results, err := selftest.Run(dir, t.TempDir(), func(configPath string) error {
+		return run(t.Context(), configPath, runOptions{quiet: true})
+	})
+	require.NoError(t, err)
+	require.Len(t, results, len(selftest.Formats))
+	for _, r := range results {
+		require.NoError(t, r.Err, r.Format)
+		assert.Positive(t, r.Rows, r.Format)
+	}
+}
+
+func TestSelftest_MaxMindTestData(t *testing.T) {
+	require.NoError(t, runSelftest([]string{"--test-data", testDataDir}))
+}
+
+func TestSelftest_MissingTestData(t *testing.T) {
+	err := runSelftest([]string{"--test-data", t.TempDir()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), selftest.CityDatabase)
+	assert.Contains(t, err.Error(), "--test-data")
+}
+
+func TestReportSelftest(t *testing.T) {
+	var buf bytes.Buffer
+	err := reportSelftest(&buf, []selftest.Result{
+		{Format: "csv", Rows: 12},
+		{Format: "parquet", Rows: 11, Err: errors.New("11 rows, but CSV output has 12")},
+	})
+	require.EqualError(t, err, "1 of 2 formats failed")
+	assert.Equal(t, "Format   Rows  Result\n"+
+		"csv      12    ok\n"+
+		"parquet  11    FAILED: 11 rows, but CSV output has 12\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, reportSelftest(&buf, []selftest.Result{{Format: "csv", Rows: 12}}))
+	assert.Contains(t, buf.String(), "All 1 formats passed\n")
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// spotcheckExpectation holds the expected values for one address, keyed by
+// column name.
+type spotcheckExpectation struct {
+	addr   netip.Addr
+	values map[string]string
+}
+
+// spotcheckMismatch describes a difference between an expected and an
+// actual value.
+type spotcheckMismatch struct {
+	addr     netip.Addr
+	column   string
+	expected string
+	actual   string
+}
+
+// runSpotcheck implements the "spotcheck" subcommand. It looks up a list of
+// addresses through the merged view and compares the results with expected
+// values, returning an error when anything differs.
+func runSpotcheck(args []string) error {
+	fs := flag.NewFlagSet("spotcheck", flag.ContinueOnError)
+	var (
+		configPath string
+		ipsPath    string
+		expectPath string
+	)
+	fs.StringVar(&configPath, "config", "", "Path to TOML configuration file")
+	fs.StringVar(&ipsPath, "ips", "", "File with one IP address per line (default: every IP in --expect)")
+	fs.StringVar(&expectPath, "expect", "", "CSV file with an 'ip' column and expected column values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		if fs.NArg() == 0 {
+			return errors.New("config file path required")
+		}
+		configPath = fs.Arg(0)
+	}
+	if expectPath == "" {
+		return errors.New("--expect is required")
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	expectations, err := readExpectations(expectPath)
+	if err != nil {
+		return err
+	}
+	if err := validateExpectationColumns(cfg, expectations); err != nil {
+		return err
+	}
+
+	addrs := make([]netip.Addr, 0, len(expectations))
+	for _, e := range expectations {
+		addrs = append(addrs, e.addr)
+	}
+	if ipsPath != "" {
+		addrs, err = readIPList(ipsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	readers, err := openMerge(cfg, "spotcheck")
+	if err != nil {
+		return err
+	}
+	defer readers.Close()
+
+	m, err := merger.NewMerger(readers, cfg, nil)
+	if err != nil {
+		return fmt.Errorf("creating merger: %w", err)
+	}
+
+	byAddr := make(map[netip.Addr]spotcheckExpectation, len(expectations))
+	for _, e := range expectations {
+		byAddr[e.addr] = e
+	}
+
+	var mismatches []spotcheckMismatch
+	for _, addr := range addrs {
+		expected, ok := byAddr[addr]
+		if !ok {
+			return fmt.Errorf("no expected values for %s in %s", addr, expectPath)
+		}
+		_, values, err := m.Lookup(addr)
+		if err != nil {
+			return err
+		}
+		found, err := diffRow(cfg, expected, values)
+		if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, found...)
+	}
+
+	for _, mm := range mismatches {
+		fmt.Printf(
+			"%s: column '%s': expected %q, got %q\n",
+			mm.addr,
+			mm.column,
+			mm.expected,
+			mm.actual,
+		)
+	}
+	fmt.Printf("Checked %d addresses: %d mismatches\n", len(addrs), len(mismatches))
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d mismatches found", len(mismatches))
+	}
+	return nil
+}
+
+// readExpectations reads a CSV file whose header contains an "ip" column and
+// any number of configured data column names.
+func readExpectations(path string) ([]spotcheckExpectation, error) {
+	// #nosec G304 -- path comes from trusted command-line flag
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening expectations: %w", err)
+	}
+	defer f.Close()
+	return parseExpectations(f)
+}
+
+func parseExpectations(r io.Reader) ([]spotcheckExpectation, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading expectations header: %w", err)
+	}
+
+	ipIndex := -1
+	for i, name := range header {
+		if name == "ip" {
+			ipIndex = i
+		}
+	}
+	if ipIndex < 0 {
+		return nil, errors.New("expectations file must have an 'ip' column")
+	}
+
+	var out []spotcheckExpectation
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading expectations: %w", err)
+		}
+		addr, err := netip.ParseAddr(strings.TrimSpace(record[ipIndex]))
+		if err != nil {
+			return nil, fmt.Errorf("parsing expected IP: %w", err)
+		}
+		e := spotcheckExpectation{addr: addr, values: map[string]string{}}
+		for i, name := range header {
+			if i != ipIndex {
+				e.values[name] = record[i]
+			}
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// validateExpectationColumns ensures every expected column is configured so
+// that typos in the expectations file don't silently skip checks.
+func validateExpectationColumns(cfg *config.Config, expectations []spotcheckExpectation) error {
+	configured := make(map[string]bool, len(cfg.Columns))
+	for _, col := range cfg.Columns {
+		configured[string(col.Name)] = true
+	}
+	for _, e := range expectations {
+		for name := range e.values {
+			if !configured[name] {
+				return fmt.Errorf("expectations column '%s' is not a configured data column", name)
+			}
+		}
+	}
+	return nil
+}
+
+// readIPList reads one IP address per line, ignoring blank lines and lines
+// starting with '#'.
+func readIPList(path string) ([]netip.Addr, error) {
+	// #nosec G304 -- path comes from trusted command-line flag
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening IP list: %w", err)
+	}
+	defer f.Close()
+	return parseIPList(f)
+}
+
+func parseIPList(r io.Reader) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addr, err := netip.ParseAddr(line)
+		if err != nil {
+			return nil, fmt.Errorf("parsing IP list: %w", err)
+		}
+		addrs = append(addrs, addr)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading IP list: %w", err)
+	}
+	return addrs, nil
+}
+
+// diffRow compares the expected values with a merged row. Values are
+// compared using their CSV representation; an empty expected value means
+// the column must be null.
+func diffRow(
+	cfg *config.Config,
+	expected spotcheckExpectation,
+	values row.Row,
+) ([]spotcheckMismatch, error) {
+	var mismatches []spotcheckMismatch
+	for i, col := range cfg.Columns {
+		want, ok := expected.values[string(col.Name)]
+		if !ok {
+			continue
+		}
+		got, err := values.Text(i)
+		if err != nil {
+			return nil, fmt.Errorf("formatting column '%s': %w", col.Name, err)
+		}
+		if got != want {
+			mismatches = append(mismatches, spotcheckMismatch{
+				addr:     expected.addr,
+				column:   string(col.Name),
+				expected: want,
+				actual:   got,
+			})
+		}
+	}
+	return mismatches, nil
+}
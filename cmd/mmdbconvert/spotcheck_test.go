@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+func TestParseExpectations(t *testing.T) {
+	input := "country,ip,is_anonymous\n# comment\nUS,1.2.3.4,1\n,2001:db8::1,\n"
+
+	expectations, err := parseExpectations(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, expectations, 2)
+
+	assert.Equal(t, netip.MustParseAddr("1.2.3.4"), expectations[0].addr)
+	assert.Equal(t, map[string]string{"country": "US", "is_anonymous": "1"}, expectations[0].values)
+	assert.Equal(t, map[string]string{"country": "", "is_anonymous": ""}, expectations[1].values)
+}
+
+func TestParseExpectations_MissingIPColumn(t *testing.T) {
+	_, err := parseExpectations(strings.NewReader("country\nUS\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'ip' column")
+}
+
+func TestParseIPList(t *testing.T) {
+	addrs, err := parseIPList(strings.NewReader("# header\n1.2.3.4\n\n  2001:db8::1  \n"))
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Addr{
+		netip.MustParseAddr("1.2.3.4"),
+		netip.MustParseAddr("2001:db8::1"),
+	}, addrs)
+
+	_, err = parseIPList(strings.NewReader("not-an-ip\n"))
+	require.Error(t, err)
+}
+
+func TestDiffRow(t *testing.T) {
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "is_anonymous"},
+			{Name: "city"},
+		},
+	}
+	expected := spotcheckExpectation{
+		addr: netip.MustParseAddr("1.2.3.4"),
+		values: map[string]string{
+			"country":      "US",
+			"is_anonymous": "",
+		},
+	}
+
+	mismatches, err := diffRow(cfg, expected, []mmdbtype.DataType{
+		mmdbtype.String("CA"),
+		mmdbtype.Bool(true),
+		mmdbtype.String("ignored"),
+	})
+	require.NoError(t, err)
+	require.Len(t, mismatches, 2)
+	assert.Equal(t, "country", mismatches[0].column)
+	assert.Equal(t, "US", mismatches[0].expected)
+	assert.Equal(t, "CA", mismatches[0].actual)
+	assert.Equal(t, "is_anonymous", mismatches[1].column)
+	assert.Equal(t, "1", mismatches[1].actual)
+
+	mismatches, err = diffRow(cfg, expected, []mmdbtype.DataType{
+		mmdbtype.String("US"),
+		nil,
+		nil,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/profile"
+)
+
+// runValidate implements the "validate" subcommand. It checks the
+// configuration, opens the databases, and profiles a sample of the merge,
+// reporting per column the types its values have and example values. It
+// returns an error if a column resolves both to maps or slices and to
+// scalars, which usually means its path selects different fields in
+// different records.
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var (
+		configPath string
+		samples    int
+		examples   int
+	)
+	fs.StringVar(&configPath, "config", "", "Path to TOML configuration file")
+	fs.IntVar(
+		&samples,
+		"samples",
+		profile.DefaultSampleRanges,
+		"Number of merged ranges to profile (0 profiles the whole merge)",
+	)
+	fs.IntVar(&examples, "examples", profile.DefaultExamples, "Example values to show per column")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if configPath == "" {
+		if fs.NArg() == 0 {
+			return errors.New("config file path required")
+		}
+		configPath = fs.Arg(0)
+	}
+	if samples < 0 || examples < 0 {
+		return errors.New("--samples and --examples must not be negative")
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	warnCaseCollisions(cfg, nil)
+
+	readers, err := openReaders(cfg, true)
+	if err != nil {
+		return err
+	}
+	defer readers.Close()
+
+	report, err := profile.Run(readers, cfg, samples, examples)
+	if err != nil {
+		return err
+	}
+	if err := writeProfileText(os.Stdout, cfg, report); err != nil {
+		return err
+	}
+
+	if mixed := report.Mixed(); len(mixed) > 0 {
+		return fmt.Errorf(
+			"%d column(s) resolve to both maps or slices and scalars; check their paths",
+			len(mixed),
+		)
+	}
+	return nil
+}
+
+func writeProfileText(w io.Writer, cfg *config.Config, report *profile.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(
+		tw,
+		"Configuration is valid: %d databases, %d columns\n",
+		len(cfg.Databases),
+		len(cfg.Columns),
+	)
+	scope := "the start of the merge"
+	if report.Complete {
+		scope = "the whole merge"
+	}
+	fmt.Fprintf(tw, "Profiled %d merged ranges (%s)\n\n", report.Rows, scope)
+
+	fmt.Fprintln(tw, "  COLUMN\tDATABASE\tTYPES\tEXAMPLES\t")
+	for _, col := range report.Columns {
+		types := make([]string, len(col.Types))
+		for i, t := range col.Types {
+			types[i] = fmt.Sprintf(
+				"%s %s",
+				t.Type,
+				formatPercent(float64(t.Count)/float64(report.Rows)),
+			)
+		}
+		fmt.Fprintf(
+			tw,
+			"  %s\t%s\t%s\t%s\t\n",
+			col.Name,
+			col.Database,
+			strings.Join(types, ", "),
+			strings.Join(col.Examples, " | "),
+		)
+	}
+
+	for _, col := range report.Mixed() {
+		fmt.Fprintf(
+			tw,
+			"\nWarning: column '%s' resolves to maps or slices in some records and to scalars in others\n",
+			col.Name,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Kinds of warnings, by what they are about.
+const (
+	warningIgnoredError      = "ignored_error"      // A row skipped in an output.ignore_errors network
+	warningLimit             = "limit"              // Rows left out once output.limits was reached
+	warningCaseCollision     = "case_collision"     // Column names differing only in case
+	warningUnknownCountries  = "unknown_countries"  // Values a country transform did not recognize
+	warningEmptyColumn       = "empty_column"       // A data column without a value in any row
+	warningIteratedDatabases = "iterated_databases" // Too many databases iterated together
+	warningSkippedInsert     = "skipped_insert"     // A network MMDB output could not insert
+)
+
+// runWarning is a non-fatal problem found during a run.
+type runWarning struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Column  string `json:"column,omitempty"`  // The data column it is about
+	Network string `json:"network,omitempty"` // The network it is about
+}
+
+// warningLog collects the warnings of a run, which are printed to stderr
+// as they occur and listed in the summary, where CI jobs that swallow stderr
+// can still find them. It is safe for concurrent use. A nil log only prints
+// the warnings.
+type warningLog struct {
+	mu       sync.Mutex
+	warnings []runWarning
+}
+
+// warn prints w and records it.
+func (l *warningLog) warn(w runWarning) {
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", w.Message)
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, w)
+}
+
+// list returns the warnings recorded so far, in order.
+func (l *warningLog) list() []runWarning {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.warnings)
+}
+
+// count returns the number of warnings of kind recorded so far.
+func (l *warningLog) count(kind string) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, w := range l.warnings {
+		if w.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// writeWarningsJSON writes the warnings of l to path as a JSON array, empty
+// if there were none.
+func writeWarningsJSON(path string, l *warningLog) error {
+	warnings := l.list()
+	if warnings == nil {
+		warnings = []runWarning{}
+	}
+	data, err := json.MarshalIndent(warnings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing warnings: %w", err)
+	}
+	return nil
+}
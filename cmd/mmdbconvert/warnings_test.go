@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarningLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "warnings.json")
+
+	// Without warnings, the file holds an empty list
+	warnings := &warningLog{}
+	require.NoError(t, writeWarningsJSON(path, warnings))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, "[]", string(data))
+
+	warnings.warn(runWarning{
+		Kind:    warningEmptyColumn,
+		Message: "column 'city' has no value in any row",
+		Column:  "city",
+	})
+	warnings.warn(runWarning{
+		Kind:    warningIgnoredError,
+		Message: "skipped 1.0.0.0-1.0.0.255",
+		Network: "1.0.0.0/24",
+	})
+	require.NoError(t, writeWarningsJSON(path, warnings))
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	var written []runWarning
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, warnings.list(), written)
+	assert.Equal(t, "empty_column", written[0].Kind)
+	assert.Equal(t, "1.0.0.0/24", written[1].Network)
+	assert.Equal(t, 1, warnings.count(warningIgnoredError))
+	assert.Zero(t, warnings.count(warningSkippedInsert))
+
+	// A nil log only prints
+	var unrecorded *warningLog
+	unrecorded.warn(runWarning{Kind: warningLimit, Message: "limit reached"})
+	assert.Nil(t, unrecorded.list())
+	assert.Zero(t, unrecorded.count(warningLimit))
+}
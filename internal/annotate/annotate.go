@@ -0,0 +1,152 @@
+// Package annotate enriches existing files of IP addresses or networks with
+// merged columns. Each row of the input is copied to the output with the
+// configured columns appended, looked up for the address in one of its
+// columns. CSV and Parquet files are supported; the output has the format
+// of the input.
+package annotate
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// Lookup returns the merged values of the configured columns for addr,
+// ordered by config.Columns. It must be safe for concurrent use when
+// Options.Workers is above 1.
+type Lookup func(addr netip.Addr) (row.Row, error)
+
+// Options describe the input of an annotation.
+type Options struct {
+	// IPColumn is the column holding the address or network of each row.
+	IPColumn string
+
+	// Delimiter separates CSV fields (default: ',').
+	Delimiter rune
+
+	// Workers is the number of lookups running at once (default: 1). Rows
+	// are written in input order whatever the number of workers.
+	Workers int
+}
+
+// Stats counts the rows of an annotation.
+type Stats struct {
+	Rows    int64 // Rows copied to the output
+	Missing int64 // Rows with an empty IP column, annotated with nulls
+}
+
+// checkColumns returns the index of the IP column in columns, and an error
+// if it is missing or if a configured column, named as in output of format,
+// would replace an input column.
+func checkColumns(cfg *config.Config, format string, columns []string, ipColumn string) (int, error) {
+	index := -1
+	names := make(map[string]bool, len(columns))
+	for i, name := range columns {
+		if name == ipColumn {
+			index = i
+		}
+		names[name] = true
+	}
+	if index < 0 {
+		return -1, fmt.Errorf(
+			"IP column '%s' not found, the input has: %s",
+			ipColumn,
+			strings.Join(columns, ", "),
+		)
+	}
+	for _, col := range cfg.Columns {
+		if name := col.OutputName(format); names[name] {
+			return -1, fmt.Errorf("column '%s' is already in the input", name)
+		}
+	}
+	return index, nil
+}
+
+// parseAddr returns the address looked up for the value of an IP column: the
+// address itself, or the first address of a network. An empty value has no
+// address.
+func parseAddr(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, nil
+	}
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		return prefix.Masked().Addr(), nil
+	}
+	return netip.ParseAddr(s)
+}
+
+// batchSize is the number of rows whose lookups are spread over the
+// workers at once.
+const batchSize = 1024
+
+// batch holds the addresses of the rows being annotated and, once looked
+// up, their values.
+type batch struct {
+	addrs  []netip.Addr
+	values []row.Row
+}
+
+// add parses the IP column of the next row. A row without an address is
+// annotated with nulls.
+func (b *batch) add(s string, stats *Stats) error {
+	addr, err := parseAddr(s)
+	if err != nil {
+		return err
+	}
+	if !addr.IsValid() {
+		stats.Missing++
+	}
+	b.addrs = append(b.addrs, addr)
+	return nil
+}
+
+// lookup looks up the values of every row, with up to workers lookups
+// running at once. On error, it returns the index of the failed row.
+func (b *batch) lookup(lookup Lookup, workers int, nulls row.Row) (int, error) {
+	b.values = slices.Grow(b.values[:0], len(b.addrs))[:len(b.addrs)]
+	if workers < 1 {
+		workers = 1
+	}
+	workers = min(workers, len(b.addrs))
+
+	errs := make([]error, len(b.addrs))
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Go(func() {
+			// Each worker annotates every workers-th row
+			for i := w; i < len(b.addrs); i += workers {
+				if !b.addrs[i].IsValid() {
+					b.values[i] = nulls
+					continue
+				}
+				b.values[i], errs[i] = lookup(b.addrs[i])
+				if errs[i] != nil {
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// reset empties the batch for the next rows.
+func (b *batch) reset() {
+	b.addrs = b.addrs[:0]
+	clear(b.values)
+}
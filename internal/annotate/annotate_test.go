@@ -0,0 +1,249 @@
+package annotate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func testConfig() *config.Config {
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "asn", Type: "int64"},
+		},
+	}
+	cfg.Output.Parquet.Compression = "snappy"
+	cfg.Output.Parquet.RowGroupSize = 2
+	return cfg
+}
+
+// testLookup returns values for 1.0.0.0/24 and 2001:db8::/32 only.
+func testLookup(addr netip.Addr) (row.Row, error) {
+	switch {
+	case netip.MustParsePrefix("1.0.0.0/24").Contains(addr):
+		return row.Row{mmdbtype.String("US"), mmdbtype.Uint32(13335)}, nil
+	case netip.MustParsePrefix("2001:db8::/32").Contains(addr):
+		return row.Row{mmdbtype.String("DE"), nil}, nil
+	default:
+		return row.Row{nil, nil}, nil
+	}
+}
+
+func TestCSV(t *testing.T) {
+	in := strings.NewReader(`src_ip,bytes
+1.0.0.1,100
+1.0.0.0/24,5
+"",7
+2001:db8::1,20
+9.9.9.9,1
+`)
+	var out bytes.Buffer
+	stats, err := CSV(in, &out, testConfig(), Options{IPColumn: "src_ip"}, testLookup)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Rows: 5, Missing: 1}, stats)
+	assert.Equal(t, `src_ip,bytes,country,asn
+1.0.0.1,100,US,13335
+1.0.0.0/24,5,US,13335
+,7,,
+2001:db8::1,20,DE,
+9.9.9.9,1,,
+`, out.String())
+}
+
+func TestCSV_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		ipColumn    string
+		expectError string
+	}{
+		{
+			name:        "missing IP column",
+			input:       "ip,bytes\n1.0.0.1,1\n",
+			ipColumn:    "src_ip",
+			expectError: "IP column 'src_ip' not found, the input has: ip, bytes",
+		},
+		{
+			name:        "column collision",
+			input:       "ip,country\n1.0.0.1,US\n",
+			ipColumn:    "ip",
+			expectError: "column 'country' is already in the input",
+		},
+		{
+			name:        "invalid address",
+			input:       "ip\n1.0.0.1\nexample.com\n",
+			ipColumn:    "ip",
+			expectError: "line 3",
+		},
+		{
+			name:        "empty",
+			input:       "",
+			ipColumn:    "ip",
+			expectError: "empty CSV file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CSV(
+				strings.NewReader(tt.input),
+				io.Discard,
+				testConfig(),
+				Options{IPColumn: tt.ipColumn},
+				testLookup,
+			)
+			require.ErrorContains(t, err, tt.expectError)
+		})
+	}
+}
+
+type flow struct {
+	SrcIP string `parquet:"src_ip,optional"`
+	Bytes int64  `parquet:"bytes"`
+}
+
+func TestParquet(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "flows.parquet")
+	f, err := os.Create(inPath)
+	require.NoError(t, err)
+	w := parquet.NewGenericWriter[flow](f)
+	_, err = w.Write([]flow{
+		{SrcIP: "1.0.0.1", Bytes: 100},
+		{SrcIP: "2001:db8::1", Bytes: 20},
+		{SrcIP: "", Bytes: 7},
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	in, err := os.Open(inPath)
+	require.NoError(t, err)
+	defer in.Close()
+	var out bytes.Buffer
+	stats, err := Parquet(in, &out, testConfig(), Options{IPColumn: "src_ip"}, testLookup)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Rows: 3, Missing: 1}, stats)
+
+	pf, err := parquet.OpenFile(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	r := parquet.NewGenericReader[map[string]any](pf, pf.Schema())
+	defer r.Close()
+	records := make([]map[string]any, 4)
+	for i := range records {
+		records[i] = map[string]any{}
+	}
+	n, err := r.Read(records)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, n)
+	assert.Equal(t, map[string]any{
+		"src_ip": "1.0.0.1", "bytes": int64(100), "country": "US", "asn": int64(13335),
+	}, records[0])
+	assert.Equal(t, map[string]any{
+		"src_ip": "2001:db8::1", "bytes": int64(20), "country": "DE", "asn": nil,
+	}, records[1])
+	assert.Nil(t, records[2]["src_ip"])
+	assert.Nil(t, records[2]["country"])
+}
+
+func TestParquet_Groups(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "flows.parquet")
+	f, err := os.Create(inPath)
+	require.NoError(t, err)
+	w := parquet.NewGenericWriter[flow](f)
+	_, err = w.Write([]flow{{SrcIP: "1.0.0.1", Bytes: 100}})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	annotate := func(group string) (*parquet.File, error) {
+		in, err := os.Open(inPath)
+		require.NoError(t, err)
+		defer in.Close()
+		cfg := testConfig()
+		for i := range cfg.Columns {
+			cfg.Columns[i].Group = group
+		}
+		var out bytes.Buffer
+		if _, err := Parquet(in, &out, cfg, Options{IPColumn: "src_ip"}, testLookup); err != nil {
+			return nil, err
+		}
+		return parquet.OpenFile(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	}
+
+	pf, err := annotate("geo")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"bytes"},
+		{"geo", "asn"},
+		{"geo", "country"},
+		{"src_ip"},
+	}, pf.Schema().Columns())
+
+	_, err = annotate("bytes")
+	require.EqualError(t, err, "group 'bytes' of column 'country' is already in the input")
+}
+
+func TestCSV_Workers(t *testing.T) {
+	// More rows than a batch, so that rows of several batches are looked up
+	// concurrently and must still be written in input order
+	var in, expected strings.Builder
+	in.WriteString("ip\n")
+	expected.WriteString("ip,country,asn\n")
+	for i := range batchSize + 100 {
+		fmt.Fprintf(&in, "10.%d.%d.1\n", i/256, i%256)
+		fmt.Fprintf(&expected, "10.%d.%d.1,%d,%d\n", i/256, i%256, i/256, i%256)
+	}
+	lookup := func(addr netip.Addr) (row.Row, error) {
+		b := addr.As4()
+		return row.Row{
+			mmdbtype.String(strconv.Itoa(int(b[1]))),
+			mmdbtype.Uint32(b[2]),
+		}, nil
+	}
+
+	var out bytes.Buffer
+	stats, err := CSV(
+		strings.NewReader(in.String()),
+		&out,
+		testConfig(),
+		Options{IPColumn: "ip", Workers: 8},
+		lookup,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(batchSize+100), stats.Rows)
+	assert.Equal(t, expected.String(), out.String())
+
+	// A failed lookup reports the line of its row
+	_, err = CSV(
+		strings.NewReader(in.String()),
+		io.Discard,
+		testConfig(),
+		Options{IPColumn: "ip", Workers: 8},
+		func(addr netip.Addr) (row.Row, error) {
+			if addr == netip.MustParseAddr("10.4.1.1") {
+				return nil, errors.New("lookup failed")
+			}
+			return lookup(addr)
+		},
+	)
+	require.EqualError(t, err, "line 1027: lookup failed")
+}
@@ -0,0 +1,122 @@
+package annotate
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// CSV annotates the CSV file read from in, which must start with a header,
+// and writes it to out with the configured columns appended. Values are
+// written as in CSV exports, with nulls empty.
+func CSV(
+	in io.Reader,
+	out io.Writer,
+	cfg *config.Config,
+	opts Options,
+	lookup Lookup,
+) (Stats, error) {
+	var stats Stats
+	r := csv.NewReader(in)
+	w := csv.NewWriter(out)
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
+		w.Comma = opts.Delimiter
+	}
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return stats, errors.New("empty CSV file, expected a header")
+	}
+	if err != nil {
+		return stats, err
+	}
+	ipIndex, err := checkColumns(cfg, "csv", header, opts.IPColumn)
+	if err != nil {
+		return stats, err
+	}
+	r.FieldsPerRecord = len(header)
+	a := &csvAnnotator{
+		cfg:    cfg,
+		opts:   opts,
+		lookup: lookup,
+		w:      w,
+		nulls:  make(row.Row, len(cfg.Columns)),
+		record: append([]string(nil), header...),
+	}
+	for _, col := range cfg.Columns {
+		a.record = append(a.record, col.OutputName("csv"))
+	}
+	if err := w.Write(a.record); err != nil {
+		return stats, err
+	}
+
+	for {
+		fields, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		line, _ := r.FieldPos(0)
+		if err := a.batch.add(fields[ipIndex], &stats); err != nil {
+			return stats, fmt.Errorf("line %d: %w", line, err)
+		}
+		a.fields = append(a.fields, fields)
+		a.lines = append(a.lines, line)
+		if len(a.fields) == batchSize {
+			if err := a.flush(&stats); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := a.flush(&stats); err != nil {
+		return stats, err
+	}
+	w.Flush()
+	return stats, w.Error()
+}
+
+// csvAnnotator annotates the rows of a CSV file a batch at a time.
+type csvAnnotator struct {
+	cfg    *config.Config
+	opts   Options
+	lookup Lookup
+	w      *csv.Writer
+	nulls  row.Row
+	batch  batch
+	fields [][]string // Fields of the rows of the batch
+	lines  []int      // Line of each row of the batch
+	record []string
+}
+
+// flush looks up and writes the rows of the batch.
+func (a *csvAnnotator) flush(stats *Stats) error {
+	if i, err := a.batch.lookup(a.lookup, a.opts.Workers, a.nulls); err != nil {
+		return fmt.Errorf("line %d: %w", a.lines[i], err)
+	}
+	for i, fields := range a.fields {
+		values := a.batch.values[i]
+		a.record = append(a.record[:0], fields...)
+		for j, col := range a.cfg.Columns {
+			s, err := values.Text(j)
+			if err != nil {
+				return fmt.Errorf("line %d, column '%s': %w", a.lines[i], col.Name, err)
+			}
+			a.record = append(a.record, s)
+		}
+		if err := a.w.Write(a.record); err != nil {
+			return err
+		}
+		stats.Rows++
+	}
+	a.batch.reset()
+	a.fields = a.fields[:0]
+	a.lines = a.lines[:0]
+	return nil
+}
@@ -0,0 +1,155 @@
+package annotate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// Parquet annotates the Parquet file in, whose IP column must be a string
+// column, and writes it to out with the configured columns appended. The
+// appended columns have the types, encodings, and compression of the
+// Parquet output of cfg, and row groups hold its row_group_size rows.
+func Parquet(
+	in *os.File,
+	out io.Writer,
+	cfg *config.Config,
+	opts Options,
+	lookup Lookup,
+) (Stats, error) {
+	var stats Stats
+	info, err := in.Stat()
+	if err != nil {
+		return stats, err
+	}
+	pf, err := parquet.OpenFile(in, info.Size())
+	if err != nil {
+		return stats, err
+	}
+
+	input := pf.Schema()
+	fields := make(parquet.Group)
+	columns := make([]string, 0, len(input.Fields()))
+	for _, field := range input.Fields() {
+		fields[field.Name()] = field
+		columns = append(columns, field.Name())
+	}
+	if _, err := checkColumns(cfg, "parquet", columns, opts.IPColumn); err != nil {
+		return stats, err
+	}
+	for _, col := range cfg.Columns {
+		if col.Group != "" && slices.Contains(columns, col.Group) {
+			return stats, fmt.Errorf("group '%s' of column '%s' is already in the input", col.Group, col.Name)
+		}
+	}
+	if ipField := fields[opts.IPColumn]; !ipField.Leaf() || ipField.Type().Kind() != parquet.ByteArray {
+		return stats, fmt.Errorf("IP column '%s' is not a string column", opts.IPColumn)
+	}
+	if err := writer.AddParquetDataFields(fields, cfg); err != nil {
+		return stats, err
+	}
+
+	codec, err := writer.ParquetCompression(cfg)
+	if err != nil {
+		return stats, fmt.Errorf("getting compression codec: %w", err)
+	}
+	r := parquet.NewGenericReader[map[string]any](in, input)
+	defer r.Close()
+	w := parquet.NewGenericWriter[map[string]any](
+		out,
+		parquet.NewSchema(input.Name(), fields),
+		parquet.Compression(codec),
+	)
+
+	nulls := make(row.Row, len(cfg.Columns))
+	records := make([]map[string]any, batchSize)
+	var b batch
+	groupRows := 0
+	for {
+		for i := range records {
+			if records[i] == nil {
+				records[i] = map[string]any{}
+			} else {
+				clear(records[i])
+			}
+		}
+		n, err := r.Read(records)
+		if n > 0 {
+			if err := annotateRecords(records[:n], &b, cfg, opts, lookup, nulls, &stats); err != nil {
+				return stats, err
+			}
+			if _, err := w.Write(records[:n]); err != nil {
+				return stats, fmt.Errorf("writing Parquet rows: %w", err)
+			}
+			groupRows += n
+			if groupRows >= cfg.Output.Parquet.RowGroupSize {
+				if err := w.Flush(); err != nil {
+					return stats, fmt.Errorf("flushing row group: %w", err)
+				}
+				groupRows = 0
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return stats, fmt.Errorf("closing Parquet writer: %w", err)
+	}
+	return stats, nil
+}
+
+// annotateRecords adds the configured columns to rows of the input.
+func annotateRecords(
+	records []map[string]any,
+	b *batch,
+	cfg *config.Config,
+	opts Options,
+	lookup Lookup,
+	nulls row.Row,
+	stats *Stats,
+) error {
+	b.reset()
+	for i, record := range records {
+		var ip string
+		switch v := record[opts.IPColumn].(type) {
+		case string:
+			ip = v
+		case []byte:
+			ip = string(v)
+		}
+		if err := b.add(ip, stats); err != nil {
+			return fmt.Errorf("row %d: %w", stats.Rows+int64(i)+1, err)
+		}
+	}
+	if i, err := b.lookup(lookup, opts.Workers, nulls); err != nil {
+		return fmt.Errorf("row %d: %w", stats.Rows+int64(i)+1, err)
+	}
+	for i, record := range records {
+		for j, col := range cfg.Columns {
+			value, err := writer.ParquetDataValue(b.values[i], j, col)
+			if err != nil {
+				return fmt.Errorf(
+					"row %d, column '%s': %w",
+					stats.Rows+int64(i)+1,
+					col.Name,
+					err,
+				)
+			}
+			writer.SetParquetDataValue(record, col, value)
+		}
+	}
+	stats.Rows += int64(len(records))
+	return nil
+}
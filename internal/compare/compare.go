@@ -0,0 +1,202 @@
+// Package compare measures agreement between two columns that describe the
+// same concept in different databases, e.g., the country reported by two
+// vendors for the same network.
+package compare
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"slices"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// Outcome classifies how the two compared values relate for a range.
+type Outcome int
+
+// Outcomes recorded by the Comparator.
+const (
+	Agree Outcome = iota
+	Disagree
+	LeftOnly
+	RightOnly
+	numOutcomes
+)
+
+// String returns the report label for the outcome.
+func (o Outcome) String() string {
+	switch o {
+	case Agree:
+		return "agree"
+	case Disagree:
+		return "disagree"
+	case LeftOnly:
+		return "left_only"
+	case RightOnly:
+		return "right_only"
+	default:
+		return fmt.Sprintf("outcome(%d)", int(o))
+	}
+}
+
+// Tally holds the number of ranges and addresses for an outcome.
+type Tally struct {
+	Ranges    uint64
+	Addresses *big.Int
+}
+
+// Disagreement is a range where both databases have a value but the values
+// differ.
+type Disagreement struct {
+	Start netip.Addr
+	End   netip.Addr
+	Size  *big.Int
+	Left  string // Textual representation of the values, as for row.Row.Text
+	Right string
+}
+
+// Comparator implements row.RangeWriter. It
+// compares the values at two column indexes of every merged row and keeps
+// the largest disagreeing ranges.
+type Comparator struct {
+	left  int
+	right int
+	top   int
+
+	tallies [2][numOutcomes]Tally // [IPv4, IPv6][outcome]
+	largest disagreementHeap
+}
+
+// NewComparator creates a comparator for the data columns at indexes left
+// and right, retaining up to top of the largest disagreeing ranges.
+func NewComparator(left, right, top int) *Comparator {
+	c := &Comparator{left: left, right: right, top: top}
+	for f := range c.tallies {
+		for o := range c.tallies[f] {
+			c.tallies[f][o].Addresses = new(big.Int)
+		}
+	}
+	return c
+}
+
+// WriteRow records a single prefix.
+func (c *Comparator) WriteRow(prefix netip.Prefix, data row.Row) error {
+	return c.WriteRange(prefix.Addr(), netipx.PrefixLastIP(prefix), data)
+}
+
+// WriteRange records a range.
+func (c *Comparator) WriteRange(start, end netip.Addr, data row.Row) error {
+	var outcome Outcome
+	switch {
+	case data.IsNull(c.left) && data.IsNull(c.right):
+		return nil
+	case data.IsNull(c.left):
+		outcome = RightOnly
+	case data.IsNull(c.right):
+		outcome = LeftOnly
+	case data.ColumnsEqual(c.left, c.right):
+		outcome = Agree
+	default:
+		outcome = Disagree
+	}
+
+	size := network.RangeSize(start, end)
+	t := &c.tallies[familyIndex(start)][outcome]
+	t.Ranges++
+	t.Addresses.Add(t.Addresses, size)
+
+	if outcome == Disagree && c.top > 0 {
+		left, err := data.Text(c.left)
+		if err != nil {
+			return err
+		}
+		right, err := data.Text(c.right)
+		if err != nil {
+			return err
+		}
+		c.pushDisagreement(Disagreement{
+			Start: start,
+			End:   end,
+			Size:  size,
+			Left:  left,
+			Right: right,
+		})
+	}
+	return nil
+}
+
+func (c *Comparator) pushDisagreement(d Disagreement) {
+	if c.largest.Len() < c.top {
+		heap.Push(&c.largest, d)
+		return
+	}
+	if d.Size.Cmp(c.largest[0].Size) > 0 {
+		c.largest[0] = d
+		heap.Fix(&c.largest, 0)
+	}
+}
+
+// Tally returns the counts for an outcome within an address family.
+func (c *Comparator) Tally(ipv4 bool, outcome Outcome) Tally {
+	if ipv4 {
+		return c.tallies[0][outcome]
+	}
+	return c.tallies[1][outcome]
+}
+
+// AgreementRate returns the share of addresses where both databases have a
+// value and the values agree, among addresses where both have a value.
+func (c *Comparator) AgreementRate(ipv4 bool) float64 {
+	agree := c.Tally(ipv4, Agree).Addresses
+	both := new(big.Int).Add(agree, c.Tally(ipv4, Disagree).Addresses)
+	if both.Sign() == 0 {
+		return 0
+	}
+	f, _ := new(big.Rat).SetFrac(agree, both).Float64()
+	return f
+}
+
+// LargestDisagreements returns the retained disagreeing ranges, largest
+// first.
+func (c *Comparator) LargestDisagreements() []Disagreement {
+	out := slices.Clone(c.largest)
+	slices.SortFunc(out, func(a, b Disagreement) int {
+		if n := b.Size.Cmp(a.Size); n != 0 {
+			return n
+		}
+		return a.Start.Compare(b.Start)
+	})
+	return out
+}
+
+func familyIndex(addr netip.Addr) int {
+	if addr.Is4() {
+		return 0
+	}
+	return 1
+}
+
+// disagreementHeap is a min-heap by range size so the smallest retained
+// disagreement can be evicted in O(log n).
+type disagreementHeap []Disagreement
+
+func (h disagreementHeap) Len() int           { return len(h) }
+func (h disagreementHeap) Less(i, j int) bool { return h[i].Size.Cmp(h[j].Size) < 0 }
+func (h disagreementHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *disagreementHeap) Push(x any) {
+	*h = append(*h, x.(Disagreement))
+}
+
+func (h *disagreementHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,70 @@
+package compare
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparator_Outcomes(t *testing.T) {
+	c := NewComparator(0, 1, 10)
+
+	rows := []struct {
+		prefix string
+		left   mmdbtype.DataType
+		right  mmdbtype.DataType
+	}{
+		{"1.0.0.0/24", mmdbtype.String("US"), mmdbtype.String("US")},
+		{"1.0.1.0/24", mmdbtype.String("US"), mmdbtype.String("CA")},
+		{"1.0.2.0/24", mmdbtype.String("US"), nil},
+		{"1.0.3.0/25", nil, mmdbtype.String("MX")},
+		{"1.0.4.0/24", nil, nil},
+		{"2001:db8::/32", mmdbtype.String("DE"), mmdbtype.String("DE")},
+	}
+	for _, r := range rows {
+		require.NoError(t, c.WriteRow(
+			netip.MustParsePrefix(r.prefix),
+			[]mmdbtype.DataType{r.left, r.right},
+		))
+	}
+
+	assert.Equal(t, uint64(1), c.Tally(true, Agree).Ranges)
+	assert.Equal(t, "256", c.Tally(true, Agree).Addresses.String())
+	assert.Equal(t, uint64(1), c.Tally(true, Disagree).Ranges)
+	assert.Equal(t, uint64(1), c.Tally(true, LeftOnly).Ranges)
+	assert.Equal(t, "128", c.Tally(true, RightOnly).Addresses.String())
+	assert.InDelta(t, 0.5, c.AgreementRate(true), 1e-9)
+
+	assert.Equal(t, uint64(1), c.Tally(false, Agree).Ranges)
+	assert.InDelta(t, 1.0, c.AgreementRate(false), 1e-9)
+}
+
+func TestComparator_LargestDisagreements(t *testing.T) {
+	c := NewComparator(0, 1, 2)
+
+	for _, r := range []struct {
+		start, end string
+	}{
+		{"10.0.0.0", "10.0.0.15"},
+		{"10.0.1.0", "10.0.1.255"},
+		{"10.0.2.0", "10.0.2.0"},
+		{"10.1.0.0", "10.1.255.255"},
+	} {
+		require.NoError(t, c.WriteRange(
+			netip.MustParseAddr(r.start),
+			netip.MustParseAddr(r.end),
+			[]mmdbtype.DataType{mmdbtype.String("A"), mmdbtype.String("B")},
+		))
+	}
+
+	largest := c.LargestDisagreements()
+	require.Len(t, largest, 2)
+	assert.Equal(t, netip.MustParseAddr("10.1.0.0"), largest[0].Start)
+	assert.Equal(t, "65536", largest[0].Size.String())
+	assert.Equal(t, netip.MustParseAddr("10.0.1.0"), largest[1].Start)
+	assert.Equal(t, "A", largest[1].Left)
+	assert.Equal(t, "B", largest[1].Right)
+}
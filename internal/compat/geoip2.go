@@ -0,0 +1,270 @@
+// Package compat checks generated MMDB files against the record structs used
+// by client libraries, catching type and layout mistakes before a database
+// ships to production readers.
+package compat
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang/v2"
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// ClientGeoIP2 is the name of the geoip2-golang compatibility check.
+const ClientGeoIP2 = "geoip2"
+
+// Problem is a field that a client library cannot read as intended.
+type Problem struct {
+	Field   string       // Dotted path of the field, e.g., "country.geoname_id"
+	Message string       // First decoder error, or a note that the field is ignored
+	Network netip.Prefix // First sampled network showing the problem
+	Count   int          // Number of sampled networks showing the problem
+}
+
+// Report summarizes a compatibility check.
+type Report struct {
+	RecordType string // Name of the client struct, e.g., "geoip2.City"
+	Sampled    int
+	Problems   []Problem
+}
+
+// recordTypes maps database type substrings to the geoip2-golang struct
+// used to read them. More specific names come first.
+var recordTypes = []struct {
+	match string
+	typ   reflect.Type
+}{
+	{"Anonymous-IP", reflect.TypeFor[geoip2.AnonymousIP]()},
+	{"Connection-Type", reflect.TypeFor[geoip2.ConnectionType]()},
+	{"Domain", reflect.TypeFor[geoip2.Domain]()},
+	{"Enterprise", reflect.TypeFor[geoip2.Enterprise]()},
+	{"ISP", reflect.TypeFor[geoip2.ISP]()},
+	{"ASN", reflect.TypeFor[geoip2.ASN]()},
+	{"City", reflect.TypeFor[geoip2.City]()},
+	{"Country", reflect.TypeFor[geoip2.Country]()},
+}
+
+// recordTypeFor returns the geoip2-golang struct for a database type.
+func recordTypeFor(databaseType string) (reflect.Type, error) {
+	for _, rt := range recordTypes {
+		if strings.Contains(databaseType, rt.match) {
+			return rt.typ, nil
+		}
+	}
+	return nil, fmt.Errorf(
+		"cannot determine geoip2 record type for database_type '%s'",
+		databaseType,
+	)
+}
+
+// CheckGeoIP2 decodes up to samples networks, spread evenly across the
+// database at path, into the geoip2-golang struct matching its database type.
+// Fields that fail to decode, and record keys the struct ignores, are
+// reported as problems.
+func CheckGeoIP2(path string, samples int) (*Report, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	typ, err := recordTypeFor(reader.Metadata.DatabaseType)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	for result := range reader.Networks() {
+		if err := result.Err(); err != nil {
+			return nil, fmt.Errorf("iterating networks: %w", err)
+		}
+		total++
+	}
+	stride := 1
+	if samples > 0 && total > samples {
+		stride = total / samples
+	}
+
+	report := &Report{RecordType: typ.String()}
+	problems := map[string]*Problem{}
+	var i int
+	for result := range reader.Networks() {
+		if err := result.Err(); err != nil {
+			return nil, fmt.Errorf("iterating networks: %w", err)
+		}
+		i++
+		if (i-1)%stride != 0 || (samples > 0 && report.Sampled >= samples) {
+			continue
+		}
+		report.Sampled++
+
+		prefix := result.Prefix()
+
+		var found []Problem
+		if err := result.Decode(reflect.New(typ).Interface()); err != nil {
+			found = decodeProblems(result, nil, typ)
+		}
+
+		var record any
+		if err := result.Decode(&record); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", prefix, err)
+		}
+		for _, field := range ignoredFields(record, typ, "") {
+			found = append(found, Problem{
+				Field:   field,
+				Message: "not read by " + typ.String(),
+			})
+		}
+
+		for _, p := range found {
+			if existing, ok := problems[p.Field]; ok {
+				existing.Count++
+				continue
+			}
+			p.Network = prefix
+			p.Count = 1
+			problems[p.Field] = &p
+		}
+	}
+
+	for _, p := range problems {
+		report.Problems = append(report.Problems, *p)
+	}
+	slices.SortFunc(report.Problems, func(a, b Problem) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+	return report, nil
+}
+
+// decodeProblems narrows a failed decode down to the fields responsible. It
+// decodes each field of typ on its own by wrapping it in single-field
+// structs mirroring the path from the record root, descending into nested
+// structs to find the innermost failing field.
+func decodeProblems(
+	result maxminddb.Result,
+	chain []reflect.StructField,
+	typ reflect.Type,
+) []Problem {
+	var problems []Problem
+	for field := range fieldsOf(typ) {
+		fieldChain := append(slices.Clip(chain), field)
+		err := result.Decode(reflect.New(wrapFields(fieldChain)).Interface())
+		if err == nil {
+			continue
+		}
+		if elem := structElem(field.Type); elem != nil {
+			if nested := decodeProblems(result, fieldChain, elem); len(nested) > 0 {
+				problems = append(problems, nested...)
+				continue
+			}
+		}
+		problems = append(problems, Problem{
+			Field:   fieldPath(fieldChain),
+			Message: err.Error(),
+		})
+	}
+	return problems
+}
+
+// wrapFields builds a struct type containing only the last field of chain,
+// nested under the preceding fields so that it decodes from the same
+// location in the record.
+func wrapFields(chain []reflect.StructField) reflect.Type {
+	var inner reflect.Type
+	for i := len(chain) - 1; i >= 0; i-- {
+		field := chain[i]
+		typ := field.Type
+		if inner != nil {
+			typ = inner
+			if field.Type.Kind() == reflect.Slice {
+				typ = reflect.SliceOf(inner)
+			}
+		}
+		inner = reflect.StructOf([]reflect.StructField{{
+			Name: field.Name,
+			Type: typ,
+			Tag:  field.Tag,
+		}})
+	}
+	return inner
+}
+
+// ignoredFields returns the dotted paths of map keys in record that typ has
+// no field for.
+func ignoredFields(record any, typ reflect.Type, prefix string) []string {
+	var ignored []string
+	switch value := record.(type) {
+	case map[string]any:
+		if typ.Kind() != reflect.Struct {
+			return nil
+		}
+		byTag := map[string]reflect.Type{}
+		for field := range fieldsOf(typ) {
+			byTag[keyOf(field)] = field.Type
+		}
+		for key, v := range value {
+			ft, ok := byTag[key]
+			if !ok {
+				ignored = append(ignored, prefix+key)
+				continue
+			}
+			ignored = append(ignored, ignoredFields(v, ft, prefix+key+".")...)
+		}
+	case []any:
+		if typ.Kind() != reflect.Slice {
+			return nil
+		}
+		for _, v := range value {
+			ignored = append(ignored, ignoredFields(v, typ.Elem(), prefix)...)
+		}
+	}
+	slices.Sort(ignored)
+	return slices.Compact(ignored)
+}
+
+// fieldsOf yields the fields of a struct type that are decoded from a
+// record key.
+func fieldsOf(typ reflect.Type) func(yield func(reflect.StructField) bool) {
+	return func(yield func(reflect.StructField) bool) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			if key := keyOf(field); key == "" || key == "-" {
+				continue
+			}
+			if !yield(field) {
+				return
+			}
+		}
+	}
+}
+
+// structElem returns the struct type of a struct or slice-of-struct field,
+// or nil.
+func structElem(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Struct {
+		return typ
+	}
+	return nil
+}
+
+// keyOf returns the record key of a field, the name in its maxminddb tag
+// without options such as maxsize.
+func keyOf(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("maxminddb"), ",")
+	return key
+}
+
+func fieldPath(chain []reflect.StructField) string {
+	parts := make([]string, len(chain))
+	for i, field := range chain {
+		parts[i] = keyOf(field)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,97 @@
+package compat
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+)
+
+// options returns the options of a test database of databaseType.
+func options(databaseType string) mmdbwriter.Options {
+	opts := mmdbtest.Options()
+	opts.DatabaseType = databaseType
+	return opts
+}
+
+func TestCheckGeoIP2_Compatible(t *testing.T) {
+	path := mmdbtest.WriteTemp(t, options("GeoIP2-City"), map[string]mmdbtype.Map{
+		"1.0.0.0/24": {
+			"country": mmdbtype.Map{
+				"iso_code":   mmdbtype.String("US"),
+				"geoname_id": mmdbtype.Uint32(6252001),
+			},
+			"subdivisions": mmdbtype.Slice{
+				mmdbtype.Map{"iso_code": mmdbtype.String("CA")},
+			},
+			"location": mmdbtype.Map{"latitude": mmdbtype.Float64(37.75)},
+		},
+	})
+
+	report, err := CheckGeoIP2(path, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "geoip2.City", report.RecordType)
+	assert.Equal(t, 1, report.Sampled)
+	assert.Empty(t, report.Problems)
+}
+
+func TestCheckGeoIP2_Problems(t *testing.T) {
+	path := mmdbtest.WriteTemp(t, options("GeoIP2-City"), map[string]mmdbtype.Map{
+		"1.0.0.0/24": {
+			"country": mmdbtype.Map{
+				"iso_code":   mmdbtype.String("US"),
+				"geoname_id": mmdbtype.String("6252001"),
+			},
+			"subdivisions": mmdbtype.Slice{
+				mmdbtype.Map{"geoname_id": mmdbtype.Float64(1)},
+			},
+			"country_code": mmdbtype.String("US"),
+		},
+		"2.0.0.0/24": {
+			"country": mmdbtype.Map{"geoname_id": mmdbtype.String("2635167")},
+		},
+	})
+
+	report, err := CheckGeoIP2(path, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Sampled)
+
+	require.Len(t, report.Problems, 3)
+	assert.Equal(t, "country.geoname_id", report.Problems[0].Field)
+	assert.Equal(t, 2, report.Problems[0].Count)
+	assert.Contains(t, report.Problems[0].Message, "cannot unmarshal")
+	assert.Equal(t, "country_code", report.Problems[1].Field)
+	assert.Equal(t, "not read by geoip2.City", report.Problems[1].Message)
+	assert.Equal(t, "subdivisions.geoname_id", report.Problems[2].Field)
+	assert.Equal(t, netip.MustParsePrefix("1.0.0.0/24"), report.Problems[2].Network)
+}
+
+func TestCheckGeoIP2_Sampling(t *testing.T) {
+	records := map[string]mmdbtype.Map{}
+	for _, network := range []string{
+		"1.0.0.0/24", "2.0.0.0/24", "3.0.0.0/24", "4.0.0.0/24", "5.0.0.0/24",
+	} {
+		records[network] = mmdbtype.Map{"is_anonymous": mmdbtype.Bool(true)}
+	}
+	path := mmdbtest.WriteTemp(t, options("GeoIP2-Anonymous-IP"), records)
+
+	report, err := CheckGeoIP2(path, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "geoip2.AnonymousIP", report.RecordType)
+	assert.Equal(t, 2, report.Sampled)
+}
+
+func TestCheckGeoIP2_UnknownDatabaseType(t *testing.T) {
+	path := mmdbtest.WriteTemp(t, options("Custom-Data"), map[string]mmdbtype.Map{
+		"1.0.0.0/24": {"value": mmdbtype.String("x")},
+	})
+
+	_, err := CheckGeoIP2(path, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot determine geoip2 record type")
+}
@@ -2,53 +2,372 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/pelletier/go-toml/v2"
+
+	"github.com/maxmind/mmdbconvert/internal/edition"
+	"github.com/maxmind/mmdbconvert/internal/preset"
+	"github.com/maxmind/mmdbconvert/internal/provenance"
+	"github.com/maxmind/mmdbconvert/internal/region"
+	"github.com/maxmind/mmdbconvert/internal/template"
+	"github.com/maxmind/mmdbconvert/internal/transform"
+	"github.com/maxmind/mmdbconvert/internal/translit"
+)
+
+// Database modes.
+const (
+	DatabaseModeIterate = "iterate"
+	DatabaseModeLookup  = "lookup"
+)
+
+const (
+	formatCSV        = "csv"
+	formatParquet    = "parquet"
+	formatMMDB       = "mmdb"
+	formatPTR        = "ptr"
+	formatVCL        = "vcl"
+	formatEnvoy      = "envoy"
+	formatSQLite     = "sqlite"
+	formatClickHouse = "clickhouse"
+	formatPostgres   = "postgres"
+	formatXLSX       = "xlsx"
+	formatProtobuf   = "protobuf"
+	formatMsgpack    = "msgpack"
+	formatBigQuery   = "bigquery"
+)
+
+// XLSXMaxRows is the most rows an XLSX worksheet holds below its header.
+const XLSXMaxRows = 1<<20 - 1
+
+// Actions when an output exceeds output.limits.
+const (
+	LimitAbort    = "abort"
+	LimitTruncate = "truncate"
+)
+
+// Actions when a network cannot be inserted into MMDB output.
+const (
+	InsertErrorAbort = "abort"
+	InsertErrorSkip  = "skip"
+)
+
+// Actions on data columns without a value in any row written.
+const (
+	EmptyColumnsWarn   = "warn"
+	EmptyColumnsFail   = "fail"
+	EmptyColumnsIgnore = "ignore"
 )
 
+// Actions on the rows of networks smaller than output.anonymity allows.
 const (
-	formatCSV     = "csv"
-	formatParquet = "parquet"
-	formatMMDB    = "mmdb"
+	AnonymityDrop       = "drop"
+	AnonymityGeneralize = "generalize"
 )
 
+// csvProfileGeoIPLegacy is the CSV profile reproducing the legacy GeoIP
+// country CSV files.
+const csvProfileGeoIPLegacy = "geoip-legacy"
+
+// geoIPLegacyNetworkTypes are the network columns of the legacy GeoIP
+// country CSV files, in order.
+var geoIPLegacyNetworkTypes = []string{"start_ip", "end_ip", "start_int", "end_int"}
+
 // Config represents the complete configuration file structure.
 type Config struct {
-	Output       OutputConfig  `toml:"output"`
-	Network      NetworkConfig `toml:"network"`
-	Databases    []Database    `toml:"databases"`
-	Columns      []Column      `toml:"columns"`
-	DisableCache bool          `toml:"disable_cache"` // Disable MMDB unmarshaler caching (default: false)
+	Output          OutputConfig       `toml:"output"`
+	Outputs         []AdditionalOutput `toml:"outputs"` // Further outputs written from the same merge
+	Network         NetworkConfig      `toml:"network"`
+	Databases       []Database         `toml:"databases"`
+	Columns         []Column           `toml:"columns"`
+	DisableCache    bool               `toml:"disable_cache"`     // Disable MMDB unmarshaler caching (default: false)
+	MaxNestingDepth int                `toml:"max_nesting_depth"` // Max databases iterated together; smaller ones are pre-merged (default: 0, no limit)
+	DriverDatabase  string             `toml:"driver_database"`   // Database iterated in the outer loop (default: first used by columns)
+	EmptyColumns    string             `toml:"empty_columns"`     // Action on data columns without a value in any row: "warn", "fail", or "ignore" (default: "warn")
+
+	Heartbeat HeartbeatConfig `toml:"heartbeat"` // Status file for liveness probes
+	Watchdog  WatchdogConfig  `toml:"watchdog"`  // Abort runs that stop making progress
+	Preset    PresetConfig    `toml:"preset"`    // Predefined columns for a granularity
+
+	// Transforms are named pipelines of string transforms, applied to the
+	// columns referencing them with transform.
+	Transforms map[string]TransformConfig `toml:"transforms"`
+
+	// TimeZoneReference is the RFC 3339 time at which time_zone columns
+	// compute offsets and daylight saving time (default: the time of the
+	// merge).
+	TimeZoneReference string `toml:"time_zone_reference"`
+
+	// CountryRollupFile is a CSV file overriding the built-in reference data
+	// of country_rollup columns.
+	CountryRollupFile string `toml:"country_rollup_file"`
+
+	// Provenance is recorded in the output. LoadConfig sets the hash of the
+	// configuration file; the caller adds the rest once it is known.
+	Provenance provenance.Info `toml:"-"`
+
+	// additionalOutputs are the configurations writing Outputs, set by
+	// LoadConfig.
+	additionalOutputs []*Config
+}
+
+// AdditionalOutput is an output written from the same merge as [output],
+// with the same settings. It can write a subset of the columns.
+type AdditionalOutput struct {
+	OutputConfig
+	// Columns are the data columns written, by name and in this order
+	// (default: every data column)
+	Columns []string `toml:"columns"`
+	// NetworkColumns are the network columns written, by name (default:
+	// every network column, or the defaults of the format if none are
+	// configured)
+	NetworkColumns []string `toml:"network_columns"`
 }
 
 // OutputConfig defines output file settings.
 type OutputConfig struct {
-	Format           string        `toml:"format"`  // "csv", "parquet", or "mmdb"
-	File             string        `toml:"file"`    // Output file path
-	CSV              CSVConfig     `toml:"csv"`     // CSV-specific options
-	Parquet          ParquetConfig `toml:"parquet"` // Parquet-specific options
-	MMDB             MMDBConfig    `toml:"mmdb"`    // MMDB-specific options
-	IPv4File         string        `toml:"ipv4_file"`
-	IPv6File         string        `toml:"ipv6_file"`
-	IncludeEmptyRows *bool         `toml:"include_empty_rows"` // Include rows with no MMDB data (default: false)
+	Format     string           `toml:"format"`     // "csv", "parquet", "mmdb", "ptr", "vcl", "envoy", "sqlite", "clickhouse", "postgres", "xlsx", "protobuf", "msgpack", or "bigquery"
+	File       string           `toml:"file"`       // Output file path
+	CSV        CSVConfig        `toml:"csv"`        // CSV-specific options
+	Parquet    ParquetConfig    `toml:"parquet"`    // Parquet-specific options
+	MMDB       MMDBConfig       `toml:"mmdb"`       // MMDB-specific options
+	PTR        PTRConfig        `toml:"ptr"`        // Reverse DNS zone options
+	VCL        VCLConfig        `toml:"vcl"`        // Varnish ACL options
+	Envoy      EnvoyConfig      `toml:"envoy"`      // Envoy CIDR list options
+	SQLite     SQLiteConfig     `toml:"sqlite"`     // SQLite database options
+	ClickHouse ClickHouseConfig `toml:"clickhouse"` // ClickHouse bulk load options
+	Postgres   PostgresConfig   `toml:"postgres"`   // PostgreSQL table options
+	BigQuery   BigQueryConfig   `toml:"bigquery"`   // BigQuery table options
+	XLSX       XLSXConfig       `toml:"xlsx"`       // Excel spreadsheet options
+	Protobuf   ProtobufConfig   `toml:"protobuf"`   // Length-delimited protobuf options
+	IPv4File   string           `toml:"ipv4_file"`
+	IPv6File   string           `toml:"ipv6_file"`
+	// SplitByIPVersion writes IPv4 and IPv6 rows to <file>-v4.<ext> and
+	// <file>-v6.<ext> rather than to file
+	SplitByIPVersion bool           `toml:"split_by_ip_version"`
+	IncludeEmptyRows *bool          `toml:"include_empty_rows"` // Include rows with no MMDB data (default: false)
+	Filter           map[string]any `toml:"filter"`             // Only write rows whose data columns have these values
+
+	ReservedNetworks ReservedNetworksConfig `toml:"reserved_networks"` // Rows for reserved networks (CSV/Parquet only)
+	Sync             SyncConfig             `toml:"sync"`              // Periodic sync to disk (CSV/Parquet only)
+	Retention        RetentionConfig        `toml:"retention"`         // Removal of old dataset parts (Parquet append only)
+	Align            AlignConfig            `toml:"align"`             // Splitting of ranges at network boundaries (CSV/Parquet only)
+	Limits           LimitsConfig           `toml:"limits"`            // Hard limits on the size of the output
+	Anonymity        AnonymityConfig        `toml:"anonymity"`         // Minimum size of the networks of rows
+	Verify           VerifyConfig           `toml:"verify"`            // Reading back of the output once written (CSV/Parquet/MMDB only)
+	Permissions      PermissionsConfig      `toml:"permissions"`       // Mode and owner of the output files
+
+	// IgnoreErrors lists networks whose rows are skipped with a warning,
+	// rather than failing the export, when they cannot be written
+	IgnoreErrors []IgnoreErrorsConfig `toml:"ignore_errors"`
+}
+
+// IgnoreErrorsConfig is a network whose write errors are downgraded to
+// warnings, such as a known-broken record of a vendor database.
+type IgnoreErrorsConfig struct {
+	Network string `toml:"network"` // CIDR of the network
+	Reason  string `toml:"reason"`  // Why errors are ignored, logged with each warning
+}
+
+// RetentionConfig controls which part files of a Parquet dataset are kept
+// after a new part is appended. Zero disables a limit.
+type RetentionConfig struct {
+	KeepLast   int `toml:"keep_last"`    // Keep only the newest parts (default: 0)
+	MaxAgeDays int `toml:"max_age_days"` // Remove parts older than this (default: 0)
+}
+
+// Enabled reports whether any retention limit is configured.
+func (r RetentionConfig) Enabled() bool {
+	return r.KeepLast > 0 || r.MaxAgeDays > 0
+}
+
+// SyncConfig controls periodic syncing of CSV and Parquet output to stable
+// storage during long exports. Zero disables a trigger.
+type SyncConfig struct {
+	EveryRows    int `toml:"every_rows"`    // Sync after this many rows (default: 0)
+	EverySeconds int `toml:"every_seconds"` // Sync after this many seconds (default: 0)
+}
+
+// Enabled reports whether any sync trigger is configured.
+func (s SyncConfig) Enabled() bool {
+	return s.EveryRows > 0 || s.EverySeconds > 0
+}
+
+// AlignConfig splits ranges at the boundaries of networks of a prefix
+// length, so that no row spans two of them. Zero disables splitting for that
+// IP version.
+type AlignConfig struct {
+	IPv4 int `toml:"ipv4"` // Prefix length: 8, 16, or 24 (default: 0)
+	IPv6 int `toml:"ipv6"` // Prefix length: a multiple of 8 up to 120 (default: 0)
+}
+
+// Enabled reports whether ranges are split for either IP version.
+func (a AlignConfig) Enabled() bool {
+	return a.IPv4 > 0 || a.IPv6 > 0
+}
+
+// LimitsConfig bounds the size of an output, protecting downstream systems
+// from exports much larger than intended. Zero disables a limit.
+type LimitsConfig struct {
+	MaxRows  int64  `toml:"max_rows"`  // Maximum rows written (default: 0)
+	MaxBytes int64  `toml:"max_bytes"` // Maximum bytes written to the output files (default: 0)
+	OnExceed string `toml:"on_exceed"` // "abort" or "truncate" (default: "abort")
+}
+
+// Enabled reports whether any limit is configured.
+func (l LimitsConfig) Enabled() bool {
+	return l.MaxRows > 0 || l.MaxBytes > 0
+}
+
+// AnonymityConfig suppresses or generalizes the rows of networks too small
+// to share, so that each row of the output covers a minimum number of
+// addresses. Zero disables a minimum.
+type AnonymityConfig struct {
+	MinAddresses        int64    `toml:"min_addresses"`          // Minimum addresses covered by a row (default: 0)
+	IPv6MaxPrefixLength int      `toml:"ipv6_max_prefix_length"` // IPv6 rows must cover a network this large instead (default: 0)
+	Action              string   `toml:"action"`                 // "drop" or "generalize" (default: "drop")
+	Generalize          []string `toml:"generalize"`             // Data columns cleared in small rows with action = "generalize"
+}
+
+// Enabled reports whether any minimum is configured.
+func (a AnonymityConfig) Enabled() bool {
+	return a.MinAddresses > 0 || a.IPv6MaxPrefixLength > 0
+}
+
+// PermissionsConfig sets the mode and owner of the output files once they
+// are written, for readers running as another user.
+type PermissionsConfig struct {
+	Mode  string `toml:"mode"`  // Octal file mode, such as "0640", set regardless of the umask
+	Owner string `toml:"owner"` // User name or ID, another user only when running as root
+	Group string `toml:"group"` // Group name or ID, another group only when running as root
+}
+
+// Enabled reports whether any permission is configured.
+func (p PermissionsConfig) Enabled() bool {
+	return p != PermissionsConfig{}
+}
+
+// FileMode returns the configured mode, or 0 if none is.
+func (p PermissionsConfig) FileMode() os.FileMode {
+	mode, err := strconv.ParseUint(p.Mode, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return os.FileMode(mode)
+}
+
+// VerifyConfig reads the output back once it is written and checks it
+// against the rows written: their number, and the values of a sample.
+type VerifyConfig struct {
+	SampleRows int `toml:"sample_rows"` // Rows whose values are checked (default: 0, no verification)
+}
+
+// Enabled reports whether the output is verified.
+func (v VerifyConfig) Enabled() bool {
+	return v.SampleRows > 0
+}
+
+// HeartbeatConfig controls the status file rewritten periodically during a
+// conversion, so that orchestrators can detect hung runs.
+type HeartbeatConfig struct {
+	File         string `toml:"file"`          // Status file path (default: none)
+	EverySeconds int    `toml:"every_seconds"` // Seconds between updates (default: 10)
+}
+
+// WatchdogConfig controls the watchdog aborting a conversion that makes no
+// progress, such as one blocked on a network filesystem, instead of letting
+// it hang.
+type WatchdogConfig struct {
+	// StallSeconds is the time without networks merged, bytes written, or a
+	// new stage after which the run is aborted (default: 0, never)
+	StallSeconds int `toml:"stall_seconds"`
+}
+
+// ReservedNetworksConfig controls rows emitted for reserved and private
+// networks in CSV and Parquet output.
+type ReservedNetworksConfig struct {
+	Include bool           `toml:"include"` // Emit rows for reserved networks (default: false)
+	Values  map[string]any `toml:"values"`  // Constant values by data column name
+}
+
+// PresetConfig selects a predefined set of columns of a GeoIP2 or GeoLite2
+// City database for a granularity. LoadConfig adds the columns before the
+// configured ones.
+type PresetConfig struct {
+	Name     string `toml:"name"`     // "country", "subdivision", "city", or "postal"
+	Database string `toml:"database"` // Database the columns read from
+}
+
+// TransformConfig is a pipeline of string transforms.
+type TransformConfig struct {
+	Steps []string `toml:"steps"` // Steps applied in order, such as "trim" or "title_case"
+
+	// CountryAliases maps codes, such as user-assigned codes, to the code
+	// the country steps output in their place.
+	CountryAliases map[string]string `toml:"country_aliases"`
+	// UnknownCountries is what the country steps do with strings that are
+	// not country codes: "keep", "empty", or "error" (default: "keep").
+	UnknownCountries string `toml:"unknown_countries"`
+	// OrgNamesFile is a CSV file mapping organization names to the name the
+	// org_name step outputs in their place.
+	OrgNamesFile string `toml:"org_names_file"`
+}
+
+// Options returns the options of the transform package for the pipeline.
+func (t TransformConfig) Options() transform.Options {
+	return transform.Options{
+		CountryAliases:   t.CountryAliases,
+		UnknownCountries: t.UnknownCountries,
+	}
 }
 
 // CSVConfig defines CSV output options.
 type CSVConfig struct {
 	Delimiter     string `toml:"delimiter"`      // Field delimiter (default: ",")
 	IncludeHeader *bool  `toml:"include_header"` // Include column headers (default: true)
+
+	ProvenanceHeader bool  `toml:"provenance_header"` // Start with provenance comment lines (default: false)
+	MaxFileSize      int64 `toml:"max_file_size"`     // Rotate to a new part file at this many bytes (default: 0, no limit)
+
+	// Profile reproduces a fixed legacy layout: "geoip-legacy" for the
+	// GeoIP Legacy country CSV files (default: none)
+	Profile string `toml:"profile"`
 }
 
 // ParquetConfig defines Parquet output options.
 type ParquetConfig struct {
 	Compression  string `toml:"compression"`    // "none", "snappy", "gzip", "lz4", "zstd" (default: "snappy")
 	RowGroupSize int    `toml:"row_group_size"` // Rows per row group (default: 500000)
+
+	Columns map[string]ParquetColumnConfig `toml:"columns"` // Per-column options by column name
+
+	// Append writes a new part file into the dataset directory output.file,
+	// after checking that its schema matches the existing part files
+	Append         bool   `toml:"append"`          // (default: false)
+	SchemaMismatch string `toml:"schema_mismatch"` // "error" or "cast" (default: "error")
+}
+
+// ParquetColumnConfig overrides the encoding and compression of a single
+// Parquet column.
+type ParquetColumnConfig struct {
+	Encoding    string `toml:"encoding"`    // Value encoding (default: "plain")
+	Dictionary  *bool  `toml:"dictionary"`  // Dictionary-encode values (default: false)
+	Compression string `toml:"compression"` // Overrides output.parquet.compression
 }
 
 // MMDBConfig defines MMDB output options.
@@ -58,6 +377,79 @@ type MMDBConfig struct {
 	Languages               []string          `toml:"languages"`                 // List of languages (auto-populated from description if empty)
 	RecordSize              *int              `toml:"record_size"`               // 24, 28, or 32 (default: 28)
 	IncludeReservedNetworks *bool             `toml:"include_reserved_networks"` // Include reserved networks (default: false)
+	Template                string            `toml:"template"`                  // Output structure template (e.g., "geoip2-city")
+	Base                    string            `toml:"base"`                      // Existing MMDB to apply merged rows on top of
+	InsertStrategy          string            `toml:"insert_strategy"`           // "replace", "top_level_merge", "deep_merge" (default: "replace")
+	IPVersion               string            `toml:"ip_version"`                // "ipv4", "ipv6", or "auto" (default: first database's IP version)
+	// OnInsertError is "abort" to fail on a network the tree rejects, such
+	// as a reserved network, or "skip" to leave it out with a warning
+	// (default: "abort")
+	OnInsertError string `toml:"on_insert_error"`
+}
+
+// PTRConfig defines reverse DNS zone output options.
+type PTRConfig struct {
+	// Name of the PTR records, with {column} replaced by the value of a
+	// data column (e.g., "{country}.as{asn}.geo.lab.example.")
+	Template string `toml:"template"`
+	TTL      int    `toml:"ttl"` // Record TTL in seconds (default: 3600)
+}
+
+// VCLConfig defines Varnish VCL ACL output options.
+type VCLConfig struct {
+	ACL string `toml:"acl"` // ACL name (default: "mmdbconvert")
+}
+
+// EnvoyConfig defines Envoy CIDR list output options.
+type EnvoyConfig struct {
+	Encoding string `toml:"encoding"` // "yaml" or "json" (default: "yaml")
+}
+
+// SQLiteConfig defines SQLite database output options.
+type SQLiteConfig struct {
+	Table string `toml:"table"` // Table of the rows (default: "networks")
+}
+
+// ClickHouseConfig defines ClickHouse bulk load output options.
+type ClickHouseConfig struct {
+	Encoding string `toml:"encoding"` // "rowbinary" or "tsv" (default: "rowbinary")
+	Table    string `toml:"table"`    // Table named in the DDL file (default: "networks")
+	DDLFile  string `toml:"ddl_file"` // Where to write the CREATE TABLE statement of the rows
+}
+
+// PostgresConfig defines PostgreSQL output options.
+type PostgresConfig struct {
+	DSN   string `toml:"dsn"`   // Connection string, as a URL or key=value pairs
+	Table string `toml:"table"` // Table the rows are copied into, optionally schema-qualified (default: "networks")
+	// CreateTable creates the table, with an index for lookups, unless it
+	// exists
+	CreateTable bool `toml:"create_table"`
+	// Truncate removes the previous rows of the table, in the transaction
+	// copying the new ones
+	Truncate bool `toml:"truncate"`
+}
+
+// BigQueryConfig defines BigQuery output options. The table is created
+// from the columns unless it exists.
+type BigQueryConfig struct {
+	Project string `toml:"project"` // Project of the dataset, which also runs the load
+	Dataset string `toml:"dataset"` // Dataset of the table
+	Table   string `toml:"table"`   // Table the rows are loaded into (default: "networks")
+}
+
+// XLSXConfig defines Excel spreadsheet output options.
+type XLSXConfig struct {
+	Sheet   string `toml:"sheet"`    // Name of the worksheet (default: "networks")
+	MaxRows int    `toml:"max_rows"` // Rows allowed before the export fails (default: 1048575, the most a sheet holds)
+}
+
+// ProtobufConfig defines length-delimited protobuf output options.
+type ProtobufConfig struct {
+	Package string `toml:"package"` // Package of the generated schema (default: none)
+	Message string `toml:"message"` // Name of the message of each row (default: "Network")
+	// Where to write the generated .proto schema (default: output.file with
+	// the .proto extension)
+	SchemaFile string `toml:"schema_file"`
 }
 
 // NetworkConfig defines network column configuration.
@@ -68,7 +460,43 @@ type NetworkConfig struct {
 // NetworkColumn defines a network column in the output.
 type NetworkColumn struct {
 	Name mmdbtype.String `toml:"name"` // Column name
-	Type string          `toml:"type"` // "cidr", "start_ip", "end_ip", "start_int", "end_int"
+	// "cidr", "start_ip", "end_ip", "start_int", "end_int", "start_decimal",
+	// "end_decimal", "valid_from", or "valid_to"
+	Type string `toml:"type"`
+	Aliases
+}
+
+// OutputName returns the name of the column in output of format.
+func (c NetworkColumn) OutputName(format string) string {
+	return c.Aliases.outputName(c.Name, format)
+}
+
+// Aliases are names a column has in some output formats instead of its own,
+// such as to follow the naming conventions of a warehouse while CSV headers
+// stay readable. Columns are still referred to by name in the rest of the
+// configuration.
+type Aliases struct {
+	Header      string `toml:"header"`       // Header in CSV and XLSX output
+	StorageName string `toml:"storage_name"` // Field name in Parquet, protobuf, and MessagePack output
+	SQLName     string `toml:"sql_name"`     // Column name in SQLite, ClickHouse, PostgreSQL, and BigQuery tables
+}
+
+// outputName returns the alias of a column named name for format, or name
+// if it has none.
+func (a Aliases) outputName(name mmdbtype.String, format string) string {
+	var alias string
+	switch format {
+	case formatCSV, formatXLSX:
+		alias = a.Header
+	case formatParquet, formatProtobuf, formatMsgpack:
+		alias = a.StorageName
+	case formatSQLite, formatClickHouse, formatPostgres, formatBigQuery:
+		alias = a.SQLName
+	}
+	if alias == "" {
+		return string(name)
+	}
+	return alias
 }
 
 // Database defines an MMDB database source.
@@ -76,6 +504,30 @@ type Database struct {
 	Name     string `toml:"name"`     // Identifier for referencing in columns
 	Path     string `toml:"path"`     // Path to MMDB file
 	Priority int    `toml:"priority"` // Priority of the database. Network regions from higher priority databases overlaps databases with lower priority in result file.
+	Preload  bool   `toml:"preload"`  // Load into memory and look up networks instead of iterating the database
+	Verify   bool   `toml:"verify"`   // Check the search tree and data section when opened
+
+	// Mode is how the merge reads the database within the networks of the
+	// databases iterated before it: "iterate" (default) iterates its
+	// networks, and "lookup" looks up the first address of each network.
+	Mode string `toml:"mode"`
+	// AllowImpreciseLookup lets a database in lookup mode use the record of
+	// a network's first address for the whole network, even where the
+	// database splits it further.
+	AllowImpreciseLookup bool `toml:"allow_imprecise_lookup"`
+
+	// Glob patterns of other builds of this database. With history, the
+	// output is time-sliced: each row has the interval in which it was valid.
+	History []string `toml:"history"`
+
+	// Constant values describing the database, such as its vendor. Columns
+	// can output them with tag, and they are recorded in the provenance.
+	Tags map[string]string `toml:"tags"`
+
+	// Edition is the GeoIP2 or GeoLite2 edition of the database, such as
+	// "GeoIP2-ISP". Columns reading from it can name its fields with field,
+	// and its database type must match the edition when it is opened.
+	Edition string `toml:"edition"`
 }
 
 // Column defines a data column mapping from MMDB to output.
@@ -83,8 +535,123 @@ type Column struct {
 	Name       mmdbtype.String `toml:"name"`        // Output column name
 	Database   string          `toml:"database"`    // Database to read from (references Database.Name)
 	Path       Path            `toml:"path"`        // Path segments to the field
+	Field      string          `toml:"field"`       // Field of the database's edition, instead of path
 	OutputPath *Path           `toml:"output_path"` // Path segments for MMDB output (defaults to [name])
-	Type       string          `toml:"type"`        // Optional type hint: "string", "int64", "float64", "bool", "binary" (Parquet only)
+	Type       string          `toml:"type"`        // Optional type hint: "string", "int64", "float64", "bool", "binary" (Parquet, ClickHouse, PostgreSQL, protobuf, MessagePack, and BigQuery only)
+	// How to combine this column's value with data already at its output_path (MMDB only):
+	// "error", "keep_existing", "overwrite", or "concatenate"
+	ConflictPolicy string `toml:"conflict_policy"`
+	// Group nests the column in a struct field of this name, with the other
+	// columns of the group (Parquet only). The column keeps its name within
+	// the group.
+	Group string `toml:"group"`
+
+	// MergeIgnore keeps differing values of this column from splitting
+	// ranges: adjacent networks that differ only in ignored columns are
+	// merged, keeping the values of the first network.
+	MergeIgnore bool `toml:"merge_ignore"`
+
+	// Tag outputs the value of this tag of the database, instead of a field,
+	// for networks the database has data for.
+	Tag string `toml:"tag"`
+	// TagValue is the value of Tag, set by LoadConfig.
+	TagValue mmdbtype.DataType `toml:"-"`
+
+	// PrefixLength outputs the prefix length of the database's network
+	// holding the record, instead of a field, for networks the database has
+	// data for.
+	PrefixLength bool `toml:"prefix_length"`
+
+	// Transliterate converts the strings of the value to ASCII with the
+	// rules of this locale, or with the default rules for "ascii".
+	Transliterate string `toml:"transliterate"`
+
+	// Transform applies the named pipeline of [transforms] to the strings
+	// of the value, after Transliterate.
+	Transform string `toml:"transform"`
+
+	// TimeZone outputs a property of the time zone named by the value, such
+	// as "Europe/Berlin", at time_zone_reference: "utc_offset" or "dst".
+	TimeZone string `toml:"time_zone"`
+
+	// CountryRollup outputs a property of the country whose alpha-2 code is
+	// the value, from reference data: "continent_code",
+	// "is_in_european_union", or "un_region".
+	CountryRollup string `toml:"country_rollup"`
+
+	// Redact is applied to the column's values when redaction is enabled
+	// with --redact.
+	Redact *RedactConfig `toml:"redact"`
+
+	// Kind expands the column into a family of columns when loading the
+	// configuration. The expanded columns keep it, for validation.
+	Kind string `toml:"kind"`
+	// Default is output instead of a missing value for networks the
+	// database has data for. Set by LoadConfig for expanded columns.
+	Default mmdbtype.DataType `toml:"-"`
+
+	// Weights of the columns summed into a column of kind "score", keyed by
+	// column name.
+	Weights map[string]float64 `toml:"weights"`
+
+	Aliases
+}
+
+// OutputName returns the name of the column in output of format.
+func (c Column) OutputName(format string) string {
+	return c.Aliases.outputName(c.Name, format)
+}
+
+// ColumnKindTraitsBooleans expands a column into one bool column for each
+// boolean trait of anonymity and hosting databases, named and located by
+// appending the trait to the column's name, path, and output_path.
+const ColumnKindTraitsBooleans = "traits_booleans"
+
+// Properties of time zones output by time_zone columns.
+const (
+	TimeZoneUTCOffset = "utc_offset" // Offset from UTC in seconds
+	TimeZoneDST       = "dst"        // Whether daylight saving time is in effect
+)
+
+// ColumnKindScore computes a column during the merge as the weighted sum of
+// other boolean or numeric columns, instead of reading it from a database.
+const ColumnKindScore = "score"
+
+// traitsBooleans are the boolean traits of the GeoIP2 Anonymous IP
+// database, which are only present in records when true.
+var traitsBooleans = []string{
+	"is_anonymous",
+	"is_anonymous_vpn",
+	"is_hosting_provider",
+	"is_public_proxy",
+	"is_residential_proxy",
+	"is_tor_exit_node",
+}
+
+// Redaction policies.
+const (
+	RedactDrop     = "drop"
+	RedactHash     = "hash"
+	RedactTruncate = "truncate"
+)
+
+// RedactConfig is the redaction policy of a column.
+type RedactConfig struct {
+	// "drop" (empty value), "hash" (salted HMAC-SHA256), or "truncate"
+	// (floats cut to Decimals decimal places)
+	Policy   string `toml:"policy"`
+	Salt     string `toml:"salt"`     // Salt of "hash"
+	SaltEnv  string `toml:"salt_env"` // Environment variable holding the salt of "hash"
+	Decimals *int   `toml:"decimals"` // Decimal places kept by "truncate"
+}
+
+// String describes the policy as recorded in the provenance, such as
+// "hash" or "truncate:2".
+func (r *RedactConfig) String() string {
+	if r.Policy == RedactTruncate && r.Decimals != nil {
+		return fmt.Sprintf("%s:%d", r.Policy, *r.Decimals)
+	}
+	return r.Policy
 }
 
 // Path represents the decoded path segments for MMDB lookup.
@@ -134,12 +701,25 @@ func LoadConfig(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
+	return parseConfig(data)
+}
 
+// parseConfig parses, completes, and validates the TOML configuration in
+// data.
+func parseConfig(data []byte) (*Config, error) {
 	var config Config
 	if err := toml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("parsing TOML: %w", err)
 	}
 
+	sum := sha256.Sum256(data)
+	config.Provenance.ConfigSHA256 = hex.EncodeToString(sum[:])
+
+	// Additional outputs apply the defaults for their own format
+	parsed := config
+	parsed.Columns = slices.Clone(config.Columns)
+	parsed.Network.Columns = slices.Clone(config.Network.Columns)
+
 	// Apply defaults
 	applyDefaults(&config)
 
@@ -147,25 +727,175 @@ func LoadConfig(path string) (*Config, error) {
 	if err := validate(&config); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	// Score columns are computed by the merge, so their weights refer to
+	// the columns of the merge, which [[outputs]] need not select
+	if err := validateScoreWeights(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	files := outputFiles(config.Output)
+	for i, out := range config.Outputs {
+		derived, err := additionalOutputConfig(parsed, out)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration: outputs[%d]: %w", i, err)
+		}
+		for _, file := range outputFiles(derived.Output) {
+			if slices.Contains(files, file) {
+				return nil, fmt.Errorf(
+					"invalid configuration: outputs[%d]: '%s' is also written by another output",
+					i,
+					file,
+				)
+			}
+			files = append(files, file)
+		}
+		config.additionalOutputs = append(config.additionalOutputs, derived)
+	}
+
+	return &config, nil
+}
+
+// AdditionalOutputs returns the configurations writing the [[outputs]], in
+// order. They have the data and network columns each output writes; the
+// rest of the configuration is that of the merge.
+func (c *Config) AdditionalOutputs() []*Config {
+	return c.additionalOutputs
+}
+
+// OutputFiles returns the files written by [output] and the [[outputs]].
+func (c *Config) OutputFiles() []string {
+	files := outputFiles(c.Output)
+	for _, out := range c.additionalOutputs {
+		files = append(files, outputFiles(out.Output)...)
+	}
+	return files
+}
+
+// additionalOutputConfig returns the configuration writing out, derived
+// from the parsed configuration before defaults are applied.
+func additionalOutputConfig(parsed Config, out AdditionalOutput) (*Config, error) {
+	config := parsed
+	config.Output = out.OutputConfig
+	config.Outputs = nil
+	config.Columns = slices.Clone(parsed.Columns)
+	config.Network.Columns = slices.Clone(parsed.Network.Columns)
+	applyDefaults(&config)
+
+	// Type hints only shape Parquet, ClickHouse, PostgreSQL, protobuf, MessagePack, and BigQuery output, and groups only
+	// Parquet output
+	for i := range config.Columns {
+		if !typedFormat(config.Output.Format) {
+			config.Columns[i].Type = ""
+		}
+		if config.Output.Format != formatParquet {
+			config.Columns[i].Group = ""
+		}
+	}
+
+	if out.Columns != nil {
+		columns := make([]Column, 0, len(out.Columns))
+		for _, name := range out.Columns {
+			i := slices.IndexFunc(config.Columns, func(col Column) bool {
+				return string(col.Name) == name
+			})
+			if i < 0 {
+				return nil, fmt.Errorf("column '%s' is not a configured data column", name)
+			}
+			columns = append(columns, config.Columns[i])
+		}
+		config.Columns = columns
+	}
+	if out.NetworkColumns != nil {
+		columns := make([]NetworkColumn, 0, len(out.NetworkColumns))
+		for _, name := range out.NetworkColumns {
+			i := slices.IndexFunc(config.Network.Columns, func(col NetworkColumn) bool {
+				return string(col.Name) == name
+			})
+			if i < 0 {
+				return nil, fmt.Errorf("network column '%s' is not a configured network column", name)
+			}
+			columns = append(columns, config.Network.Columns[i])
+		}
+		config.Network.Columns = columns
+	}
 
+	if err := validate(&config); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+// outputFiles returns the files configured for an output.
+func outputFiles(output OutputConfig) []string {
+	var files []string
+	for _, file := range []string{output.File, output.IPv4File, output.IPv6File} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
 // applyDefaults applies default values to configuration.
 func applyDefaults(config *Config) {
 	// DisableCache defaults to false (zero value), no action needed
 
+	// Preset columns come first, so that templates apply to them too
+	if p, ok := preset.Lookup(config.Preset.Name); ok {
+		columns := make([]Column, 0, len(p.Columns)+len(config.Columns))
+		for _, col := range p.Columns {
+			columns = append(columns, Column{
+				Name:        mmdbtype.String(col.Name),
+				Database:    config.Preset.Database,
+				Path:        Path(slices.Clone(col.Path)),
+				MergeIgnore: col.MergeIgnore,
+			})
+		}
+		config.Columns = append(columns, config.Columns...)
+	}
+	resolveEditionFields(config)
+	config.Columns = expandColumnKinds(config.Columns, typedFormat(config.Output.Format))
+
 	// Output defaults
 	if config.Output.IncludeEmptyRows == nil {
 		config.Output.IncludeEmptyRows = boolPtr(false)
 	}
+	if config.Output.Limits.OnExceed == "" {
+		config.Output.Limits.OnExceed = LimitAbort
+	}
+	if config.Output.Anonymity.Action == "" {
+		config.Output.Anonymity.Action = AnonymityDrop
+	}
+
+	// The schema of protobuf output is named after output.file, before it
+	// is split
+	if config.Output.Format == formatProtobuf {
+		if config.Output.Protobuf.Message == "" {
+			config.Output.Protobuf.Message = "Network"
+		}
+		if file := config.Output.File; config.Output.Protobuf.SchemaFile == "" && file != "" {
+			config.Output.Protobuf.SchemaFile = strings.TrimSuffix(file, filepath.Ext(file)) + ".proto"
+		}
+	}
+
+	// Split files derived from output.file replace it, as if configured
+	// as output.ipv4_file and output.ipv6_file
+	if config.Output.SplitByIPVersion && config.Output.File != "" &&
+		config.Output.IPv4File == "" && config.Output.IPv6File == "" {
+		config.Output.IPv4File = splitFile(config.Output.File, "-v4")
+		config.Output.IPv6File = splitFile(config.Output.File, "-v6")
+		config.Output.File = ""
+	}
 
 	// CSV defaults
 	if config.Output.CSV.Delimiter == "" {
 		config.Output.CSV.Delimiter = ","
 	}
 	if config.Output.CSV.IncludeHeader == nil {
-		config.Output.CSV.IncludeHeader = boolPtr(true)
+		// The legacy GeoIP files have no header
+		config.Output.CSV.IncludeHeader = boolPtr(
+			config.Output.CSV.Profile != csvProfileGeoIPLegacy,
+		)
 	}
 
 	// Parquet defaults
@@ -175,6 +905,9 @@ func applyDefaults(config *Config) {
 	if config.Output.Parquet.RowGroupSize == 0 {
 		config.Output.Parquet.RowGroupSize = 500000
 	}
+	if config.Output.Parquet.Append && config.Output.Parquet.SchemaMismatch == "" {
+		config.Output.Parquet.SchemaMismatch = "error"
+	}
 
 	// MMDB defaults
 	if config.Output.Format == formatMMDB {
@@ -182,7 +915,14 @@ func applyDefaults(config *Config) {
 			config.Output.MMDB.RecordSize = intPtr(28)
 		}
 		if config.Output.MMDB.IncludeReservedNetworks == nil {
-			config.Output.MMDB.IncludeReservedNetworks = boolPtr(false)
+			// Keep every network of a base database by default
+			config.Output.MMDB.IncludeReservedNetworks = boolPtr(config.Output.MMDB.Base != "")
+		}
+		if config.Output.MMDB.Base != "" && config.Output.MMDB.InsertStrategy == "" {
+			config.Output.MMDB.InsertStrategy = "replace"
+		}
+		if config.Output.MMDB.OnInsertError == "" {
+			config.Output.MMDB.OnInsertError = InsertErrorAbort
 		}
 		// Auto-populate languages from description keys if not specified
 		if len(config.Output.MMDB.Languages) == 0 {
@@ -192,27 +932,173 @@ func applyDefaults(config *Config) {
 			// Sort for deterministic output
 			slices.Sort(config.Output.MMDB.Languages)
 		}
+		// Templates supply the database type and the output paths of the
+		// columns they know about
+		if tmpl, ok := template.Lookup(config.Output.MMDB.Template); ok {
+			if config.Output.MMDB.DatabaseType == "" {
+				config.Output.MMDB.DatabaseType = tmpl.DatabaseType
+			}
+			for i := range config.Columns {
+				col := &config.Columns[i]
+				field, ok := tmpl.Field(string(col.Name))
+				if ok && col.OutputPath == nil {
+					path := Path(slices.Clone(field.Path))
+					col.OutputPath = &path
+				}
+			}
+		}
 	}
 
 	// Network column defaults - apply format-specific defaults if no columns specified
 	if len(config.Network.Columns) == 0 {
 		switch config.Output.Format {
-		case formatParquet:
-			// Parquet default: integer columns for query performance
+		case formatParquet, formatSQLite, formatClickHouse:
+			// Parquet, SQLite, and ClickHouse default: integer columns for
+			// query performance
 			config.Network.Columns = []NetworkColumn{
 				{Name: "start_int", Type: "start_int"},
 				{Name: "end_int", Type: "end_int"},
 			}
-		case formatMMDB:
-			// MMDB default: no network columns (data written by prefix)
+		case formatPostgres, formatBigQuery:
+			// PostgreSQL and BigQuery default: a CIDR column, which inet
+			// operators and NET functions query
+			config.Network.Columns = []NetworkColumn{
+				{Name: "network", Type: "cidr"},
+			}
+		case formatMMDB, formatPTR, formatVCL, formatEnvoy:
+			// MMDB, PTR, VCL, and Envoy default: no network columns (data
+			// written by prefix)
 			config.Network.Columns = []NetworkColumn{}
 		default:
+			if config.Output.CSV.Profile == csvProfileGeoIPLegacy {
+				for _, typ := range geoIPLegacyNetworkTypes {
+					config.Network.Columns = append(
+						config.Network.Columns,
+						NetworkColumn{Name: mmdbtype.String(typ), Type: typ},
+					)
+				}
+				break
+			}
 			// CSV default: human-readable CIDR
 			config.Network.Columns = []NetworkColumn{
 				{Name: "network", Type: "cidr"},
 			}
 		}
+		if _, ok := config.HistoryDatabase(); ok &&
+			(config.Output.Format == formatCSV || config.Output.Format == formatParquet) {
+			config.Network.Columns = append(
+				config.Network.Columns,
+				NetworkColumn{Name: "valid_from", Type: "valid_from"},
+				NetworkColumn{Name: "valid_to", Type: "valid_to"},
+			)
+		}
+	}
+
+	for i := range config.Columns {
+		col := &config.Columns[i]
+		if col.PrefixLength && col.Type == "" && typedFormat(config.Output.Format) {
+			col.Type = "int64"
+		}
+		if col.Kind == ColumnKindScore && col.Type == "" && typedFormat(config.Output.Format) {
+			col.Type = "float64"
+		}
+		if col.TimeZone != "" && col.Type == "" && typedFormat(config.Output.Format) {
+			col.Type = timeZoneTypes[col.TimeZone]
+		}
+		if col.CountryRollup != "" && col.Type == "" && typedFormat(config.Output.Format) {
+			col.Type = countryRollupTypes[col.CountryRollup]
+		}
+		if col.Tag == "" {
+			continue
+		}
+		for _, db := range config.Databases {
+			if value, ok := db.Tags[col.Tag]; ok && db.Name == col.Database {
+				col.TagValue = mmdbtype.String(value)
+			}
+		}
+	}
+
+	if config.EmptyColumns == "" {
+		config.EmptyColumns = EmptyColumnsWarn
+	}
+
+	if config.Heartbeat.File != "" && config.Heartbeat.EverySeconds == 0 {
+		config.Heartbeat.EverySeconds = 10
+	}
+
+	if config.Output.Format == formatPTR && config.Output.PTR.TTL == 0 {
+		config.Output.PTR.TTL = 3600
+	}
+	if config.Output.Format == formatVCL && config.Output.VCL.ACL == "" {
+		config.Output.VCL.ACL = "mmdbconvert"
+	}
+	if config.Output.Format == formatEnvoy && config.Output.Envoy.Encoding == "" {
+		config.Output.Envoy.Encoding = "yaml"
+	}
+	if config.Output.Format == formatSQLite && config.Output.SQLite.Table == "" {
+		config.Output.SQLite.Table = "networks"
+	}
+	if config.Output.Format == formatClickHouse {
+		if config.Output.ClickHouse.Encoding == "" {
+			config.Output.ClickHouse.Encoding = "rowbinary"
+		}
+		if config.Output.ClickHouse.Table == "" {
+			config.Output.ClickHouse.Table = "networks"
+		}
+	}
+	if config.Output.Format == formatPostgres && config.Output.Postgres.Table == "" {
+		config.Output.Postgres.Table = "networks"
+	}
+	if config.Output.Format == formatBigQuery && config.Output.BigQuery.Table == "" {
+		config.Output.BigQuery.Table = "networks"
+	}
+	if config.Output.Format == formatXLSX {
+		if config.Output.XLSX.Sheet == "" {
+			config.Output.XLSX.Sheet = "networks"
+		}
+		if config.Output.XLSX.MaxRows == 0 {
+			config.Output.XLSX.MaxRows = XLSXMaxRows
+		}
+	}
+}
+
+// splitFile returns file with suffix inserted before its extension.
+func splitFile(file, suffix string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + suffix + ext
+}
+
+// expandColumnKinds replaces the columns with a kind by the columns they
+// expand to, typed as bool when the output has typed columns. Columns with an
+// unknown kind are kept for validation to report.
+func expandColumnKinds(columns []Column, typed bool) []Column {
+	if !slices.ContainsFunc(columns, func(col Column) bool {
+		return col.Kind == ColumnKindTraitsBooleans
+	}) {
+		return columns
+	}
+	expanded := make([]Column, 0, len(columns)+len(traitsBooleans))
+	for _, col := range columns {
+		if col.Kind != ColumnKindTraitsBooleans {
+			expanded = append(expanded, col)
+			continue
+		}
+		for _, trait := range traitsBooleans {
+			c := col
+			c.Name = col.Name + mmdbtype.String(trait)
+			c.Path = append(slices.Clone(col.Path), trait)
+			if col.OutputPath != nil {
+				path := append(slices.Clone(*col.OutputPath), trait)
+				c.OutputPath = &path
+			}
+			if typed && c.Type == "" {
+				c.Type = "bool"
+			}
+			c.Default = mmdbtype.Bool(false)
+			expanded = append(expanded, c)
+		}
 	}
+	return expanded
 }
 
 func boolPtr(v bool) *bool {
@@ -231,14 +1117,35 @@ func validate(config *Config) error {
 	if config.Output.Format == "" {
 		return errors.New("output.format is required")
 	}
-	if config.Output.Format != formatCSV && config.Output.Format != formatParquet &&
-		config.Output.Format != formatMMDB {
+	switch config.Output.Format {
+	case formatCSV, formatParquet, formatMMDB, formatPTR, formatVCL, formatEnvoy, formatSQLite,
+		formatClickHouse, formatPostgres, formatXLSX, formatProtobuf, formatMsgpack, formatBigQuery:
+	default:
 		return fmt.Errorf(
-			"output.format must be 'csv', 'parquet', or 'mmdb', got '%s'",
+			"output.format must be 'csv', 'parquet', 'mmdb', 'ptr', 'vcl', 'envoy', 'sqlite', 'clickhouse', 'postgres', 'xlsx', 'protobuf', 'msgpack', or 'bigquery', got '%s'",
 			config.Output.Format,
 		)
 	}
-	if config.Output.File == "" && (config.Output.IPv4File == "" || config.Output.IPv6File == "") {
+	if config.Output.SplitByIPVersion && config.Output.Format == formatPostgres {
+		return errors.New("output.split_by_ip_version is not supported for PostgreSQL output")
+	}
+	if config.Output.SplitByIPVersion && config.Output.Format == formatBigQuery {
+		return errors.New("output.split_by_ip_version is not supported for BigQuery output")
+	}
+	if err := validatePostgres(config); err != nil {
+		return err
+	}
+	if err := validateBigQuery(config); err != nil {
+		return err
+	}
+	if config.Output.SplitByIPVersion && config.Output.File == "" &&
+		config.Output.IPv4File == "" && config.Output.IPv6File == "" {
+		return errors.New("output.split_by_ip_version requires output.file")
+	}
+	// PostgreSQL and BigQuery rows are loaded into a table, not written to
+	// files
+	if config.Output.Format != formatPostgres && config.Output.Format != formatBigQuery &&
+		config.Output.File == "" && (config.Output.IPv4File == "" || config.Output.IPv6File == "") {
 		return errors.New(
 			"either output.file must be set or both output.ipv4_file and output.ipv6_file must be provided",
 		)
@@ -249,6 +1156,29 @@ func validate(config *Config) error {
 		)
 	}
 
+	// Validate CSV delimiter. Only the first byte is used.
+	if config.Output.Format == formatCSV {
+		switch config.Output.CSV.Delimiter[0] {
+		case '"', '\r', '\n':
+			return fmt.Errorf(
+				"invalid CSV delimiter %q, must not be a quote or line break",
+				config.Output.CSV.Delimiter,
+			)
+		}
+		if err := validateCSVProfile(config); err != nil {
+			return err
+		}
+		if config.Output.CSV.MaxFileSize < 0 {
+			return errors.New("output.csv.max_file_size cannot be negative")
+		}
+	} else if config.Output.CSV.ProvenanceHeader {
+		return errors.New("output.csv.provenance_header is only supported for CSV output")
+	} else if config.Output.CSV.Profile != "" {
+		return errors.New("output.csv.profile is only supported for CSV output")
+	} else if config.Output.CSV.MaxFileSize != 0 {
+		return errors.New("output.csv.max_file_size is only supported for CSV output")
+	}
+
 	// Validate Parquet compression
 	if config.Output.Format == formatParquet {
 		validCompressions := map[string]bool{
@@ -260,14 +1190,64 @@ func validate(config *Config) error {
 				config.Output.Parquet.Compression,
 			)
 		}
+		if err := validateParquetColumns(config, validCompressions); err != nil {
+			return err
+		}
+		if err := validateParquetAppend(config); err != nil {
+			return err
+		}
+	} else if len(config.Output.Parquet.Columns) > 0 {
+		return errors.New("output.parquet.columns is only supported for Parquet output")
+	} else if config.Output.Parquet.Append {
+		return errors.New("output.parquet.append is only supported for Parquet output")
 	}
 
 	// Validate MMDB configuration
 	if config.Output.Format == formatMMDB {
-		if config.Output.MMDB.DatabaseType == "" {
+		if config.Output.MMDB.Template != "" {
+			if err := validateTemplate(config); err != nil {
+				return err
+			}
+		}
+
+		// A base database supplies its own database type
+		if config.Output.MMDB.DatabaseType == "" && config.Output.MMDB.Base == "" {
 			return errors.New("output.mmdb.database_type is required for MMDB output")
 		}
 
+		switch config.Output.MMDB.IPVersion {
+		case "", "ipv4", "ipv6", "auto":
+		default:
+			return fmt.Errorf(
+				"invalid output.mmdb.ip_version '%s', must be one of: ipv4, ipv6, auto",
+				config.Output.MMDB.IPVersion,
+			)
+		}
+
+		if config.Output.MMDB.InsertStrategy != "" {
+			if config.Output.MMDB.Base == "" {
+				return errors.New("output.mmdb.insert_strategy requires output.mmdb.base")
+			}
+			validStrategies := map[string]bool{
+				"replace": true, "top_level_merge": true, "deep_merge": true,
+			}
+			if !validStrategies[config.Output.MMDB.InsertStrategy] {
+				return fmt.Errorf(
+					"invalid output.mmdb.insert_strategy '%s', must be one of: replace, top_level_merge, deep_merge",
+					config.Output.MMDB.InsertStrategy,
+				)
+			}
+		}
+
+		switch config.Output.MMDB.OnInsertError {
+		case InsertErrorAbort, InsertErrorSkip:
+		default:
+			return fmt.Errorf(
+				"invalid output.mmdb.on_insert_error '%s', must be one of: abort, skip",
+				config.Output.MMDB.OnInsertError,
+			)
+		}
+
 		if config.Output.MMDB.RecordSize != nil {
 			rs := *config.Output.MMDB.RecordSize
 			if rs != 24 && rs != 28 && rs != 32 {
@@ -275,18 +1255,175 @@ func validate(config *Config) error {
 			}
 		}
 
-		// Reject split files for MMDB
+		// Split files are written as one IPv4 and one IPv6 tree
 		if config.Output.IPv4File != "" || config.Output.IPv6File != "" {
-			return errors.New("split IPv4/IPv6 files not supported for MMDB output")
+			if config.Output.MMDB.Base != "" {
+				return errors.New(
+					"output.mmdb.base is not supported with split IPv4/IPv6 MMDB output",
+				)
+			}
+			if config.Output.MMDB.IPVersion != "" {
+				return errors.New(
+					"output.mmdb.ip_version cannot be used with split IPv4/IPv6 MMDB output, which always writes an IPv4 and an IPv6 tree",
+				)
+			}
+		}
+	} else if config.Output.MMDB.Template != "" {
+		return errors.New("output.mmdb.template is only supported for MMDB output")
+	}
+
+	if config.Output.Format == formatPTR {
+		if config.Output.PTR.Template == "" {
+			return errors.New("output.ptr.template is required for PTR output")
+		}
+		if config.Output.PTR.TTL < 0 {
+			return errors.New("output.ptr.ttl cannot be negative")
+		}
+		if len(config.Network.Columns) > 0 {
+			return errors.New("network columns are not supported for PTR output")
+		}
+	} else if config.Output.PTR.Template != "" {
+		return errors.New("output.ptr.template is only supported for PTR output")
+	}
+
+	if config.Output.Format == formatVCL && !validVCLName(config.Output.VCL.ACL) {
+		return fmt.Errorf(
+			"invalid output.vcl.acl '%s', must start with a letter and contain only letters, digits, and underscores",
+			config.Output.VCL.ACL,
+		)
+	}
+	if config.Output.Format == formatEnvoy {
+		switch config.Output.Envoy.Encoding {
+		case "yaml", "json":
+		default:
+			return fmt.Errorf(
+				"invalid output.envoy.encoding '%s', must be one of: yaml, json",
+				config.Output.Envoy.Encoding,
+			)
 		}
 	}
+	if (config.Output.Format == formatVCL || config.Output.Format == formatEnvoy) &&
+		len(config.Network.Columns) > 0 {
+		return fmt.Errorf(
+			"network columns are not supported for %s output",
+			config.Output.Format,
+		)
+	}
+
+	if err := validateSQLite(config); err != nil {
+		return err
+	}
+	if err := validateClickHouse(config); err != nil {
+		return err
+	}
+	if err := validateXLSX(config); err != nil {
+		return err
+	}
+	if err := validateProtobuf(config); err != nil {
+		return err
+	}
+
+	if err := validateReservedNetworks(config); err != nil {
+		return err
+	}
+	if err := validateColumnValues(config, "output.filter", config.Output.Filter); err != nil {
+		return err
+	}
+
+	if err := validateHistory(config); err != nil {
+		return err
+	}
+	if err := validateIgnoreErrors(config); err != nil {
+		return err
+	}
+
+	if config.Output.Sync.EveryRows < 0 {
+		return errors.New("output.sync.every_rows cannot be negative")
+	}
+	if config.Output.Sync.EverySeconds < 0 {
+		return errors.New("output.sync.every_seconds cannot be negative")
+	}
+	if config.Output.Retention.KeepLast < 0 {
+		return errors.New("output.retention.keep_last cannot be negative")
+	}
+	if config.Output.Retention.MaxAgeDays < 0 {
+		return errors.New("output.retention.max_age_days cannot be negative")
+	}
+	if config.Output.Retention.Enabled() && !config.Output.Parquet.Append {
+		return errors.New(
+			"output.retention requires output.parquet.append, as only dataset parts accumulate",
+		)
+	}
+	if config.Output.Sync.Enabled() && config.Output.Format == formatMMDB {
+		return errors.New(
+			"output.sync is not supported for MMDB output, which is written when the merge completes",
+		)
+	}
+	if config.Output.Sync.Enabled() && config.Output.Format == formatSQLite {
+		return errors.New(
+			"output.sync is not supported for SQLite output, which is written when the merge completes",
+		)
+	}
+	if config.Output.Sync.Enabled() && config.Output.Format == formatPostgres {
+		return errors.New(
+			"output.sync is not supported for PostgreSQL output, whose rows are committed when the merge completes",
+		)
+	}
+	if config.Output.Sync.Enabled() && config.Output.Format == formatBigQuery {
+		return errors.New(
+			"output.sync is not supported for BigQuery output, whose rows are committed when the merge completes",
+		)
+	}
+
+	if err := validateAlign(config); err != nil {
+		return err
+	}
+	if err := validateLimits(config); err != nil {
+		return err
+	}
+	if err := validateAnonymity(config); err != nil {
+		return err
+	}
+	if err := validateVerify(config); err != nil {
+		return err
+	}
+	if err := validatePermissions(config); err != nil {
+		return err
+	}
+
+	if config.Heartbeat.EverySeconds < 0 {
+		return errors.New("heartbeat.every_seconds cannot be negative")
+	}
+	if config.Heartbeat.EverySeconds > 0 && config.Heartbeat.File == "" {
+		return errors.New("heartbeat.every_seconds requires heartbeat.file")
+	}
+	if config.Watchdog.StallSeconds < 0 {
+		return errors.New("watchdog.stall_seconds cannot be negative")
+	}
+	switch config.EmptyColumns {
+	case EmptyColumnsWarn, EmptyColumnsFail, EmptyColumnsIgnore:
+	default:
+		return fmt.Errorf(
+			"invalid empty_columns '%s', must be one of: %s, %s, %s",
+			config.EmptyColumns,
+			EmptyColumnsWarn,
+			EmptyColumnsFail,
+			EmptyColumnsIgnore,
+		)
+	}
 
-	// Validate type hints only allowed for Parquet
-	if config.Output.Format == formatCSV || config.Output.Format == formatMMDB {
+	// Validate type hints only allowed for Parquet, ClickHouse, PostgreSQL, protobuf, MessagePack, and BigQuery
+	if config.Output.Format != formatParquet {
 		for _, col := range config.Columns {
-			if col.Type != "" {
+			if col.Type != "" && !typedFormat(config.Output.Format) {
+				return fmt.Errorf(
+					"column '%s': type hints not supported for %s output (only for parquet, clickhouse, postgres, protobuf, msgpack, and bigquery)",
+					col.Name, config.Output.Format,
+				)
+			}
+			if col.Group != "" {
 				return fmt.Errorf(
-					"column '%s': type hints not supported for %s output (only for parquet)",
+					"column '%s': group not supported for %s output (only for parquet)",
 					col.Name, config.Output.Format,
 				)
 			}
@@ -297,6 +1434,20 @@ func validate(config *Config) error {
 	if len(config.Databases) == 0 {
 		return errors.New("at least one database is required")
 	}
+	if config.MaxNestingDepth < 0 {
+		return fmt.Errorf("max_nesting_depth cannot be negative, got %d", config.MaxNestingDepth)
+	}
+	if err := validatePreset(config); err != nil {
+		return err
+	}
+	if err := validateTransforms(config); err != nil {
+		return err
+	}
+	if config.TimeZoneReference != "" {
+		if _, err := time.Parse(time.RFC3339, config.TimeZoneReference); err != nil {
+			return fmt.Errorf("invalid time_zone_reference '%s', must be an RFC 3339 time", config.TimeZoneReference)
+		}
+	}
 
 	// Check for duplicate database names
 	dbNames := map[string]bool{}
@@ -311,13 +1462,26 @@ func validate(config *Config) error {
 			return fmt.Errorf("duplicate database name '%s'", db.Name)
 		}
 		dbNames[db.Name] = true
+		if err := validateDatabaseMode(db); err != nil {
+			return err
+		}
+		if err := validateEdition(db); err != nil {
+			return err
+		}
+	}
+	if err := validateDriverDatabase(config); err != nil {
+		return err
+	}
+
+	if err := validateColumnNames(config); err != nil {
+		return err
 	}
 
 	// Validate network columns
 	validNetworkTypes := map[string]bool{
 		"cidr": true, "start_ip": true, "end_ip": true, "start_int": true, "end_int": true,
+		"start_decimal": true, "end_decimal": true, "valid_from": true, "valid_to": true,
 	}
-	networkColNames := map[mmdbtype.String]bool{}
 	for _, col := range config.Network.Columns {
 		if col.Name == "" {
 			return errors.New("network column name is required")
@@ -327,33 +1491,47 @@ func validate(config *Config) error {
 		}
 		if !validNetworkTypes[col.Type] {
 			return fmt.Errorf(
-				"invalid network column type '%s' for column '%s', must be one of: cidr, start_ip, end_ip, start_int, end_int",
+				"invalid network column type '%s' for column '%s', must be one of: cidr, start_ip, end_ip, start_int, end_int, start_decimal, end_decimal, valid_from, valid_to",
 				col.Type,
 				col.Name,
 			)
 		}
-		if networkColNames[col.Name] {
-			return fmt.Errorf("duplicate network column name '%s'", col.Name)
-		}
-		networkColNames[col.Name] = true
 	}
 
 	// Validate data columns
 	validDataTypes := map[string]bool{
 		"": true, "string": true, "int64": true, "float64": true, "bool": true, "binary": true,
 	}
-	dataColNames := map[mmdbtype.String]bool{}
+	validConflictPolicies := map[string]bool{
+		"error": true, "keep_existing": true, "overwrite": true, "concatenate": true,
+	}
 	for _, col := range config.Columns {
+		if col.Kind != "" && col.Kind != ColumnKindTraitsBooleans && col.Kind != ColumnKindScore {
+			return fmt.Errorf(
+				"invalid kind '%s' for column '%s', must be one of: %s, %s",
+				col.Kind,
+				col.Name,
+				ColumnKindTraitsBooleans,
+				ColumnKindScore,
+			)
+		}
 		if col.Name == "" {
 			return errors.New("column name is required")
 		}
-		if col.Database == "" {
+		if col.Weights != nil && col.Kind != ColumnKindScore {
+			return fmt.Errorf("column '%s': weights require kind '%s'", col.Name, ColumnKindScore)
+		}
+		if col.Kind == ColumnKindScore {
+			if err := validateScoreColumn(col); err != nil {
+				return err
+			}
+		} else if col.Database == "" {
 			return fmt.Errorf("column database is required for column '%s'", col.Name)
 		}
 		// Empty path is allowed - path = [] means "copy entire record"
 
 		// Validate database reference
-		if !dbNames[col.Database] {
+		if col.Kind != ColumnKindScore && !dbNames[col.Database] {
 			return fmt.Errorf(
 				"column '%s' references unknown database '%s'",
 				col.Name,
@@ -361,6 +1539,94 @@ func validate(config *Config) error {
 			)
 		}
 
+		if col.Field != "" {
+			if err := validateEditionField(col, config.Databases); err != nil {
+				return err
+			}
+		}
+
+		if col.Tag != "" {
+			if len(col.Path) > 0 {
+				return fmt.Errorf("column '%s': tag and path cannot both be set", col.Name)
+			}
+			if col.TagValue == nil {
+				return fmt.Errorf(
+					"column '%s': database '%s' has no tag '%s'",
+					col.Name,
+					col.Database,
+					col.Tag,
+				)
+			}
+			if col.Type != "" && col.Type != "string" {
+				return fmt.Errorf("column '%s': tag values can only have type 'string'", col.Name)
+			}
+		}
+
+		if col.PrefixLength {
+			if len(col.Path) > 0 || col.Tag != "" {
+				return fmt.Errorf(
+					"column '%s': prefix_length cannot be combined with path or tag",
+					col.Name,
+				)
+			}
+			if col.Type != "" && col.Type != "int64" && col.Type != "string" {
+				return fmt.Errorf(
+					"column '%s': prefix lengths can only have type 'int64' or 'string'",
+					col.Name,
+				)
+			}
+		}
+
+		if col.Kind == ColumnKindTraitsBooleans {
+			if col.Tag != "" || col.PrefixLength {
+				return fmt.Errorf(
+					"column '%s': kind '%s' cannot be combined with tag or prefix_length",
+					col.Name,
+					col.Kind,
+				)
+			}
+			if col.Type != "" && col.Type != "bool" {
+				return fmt.Errorf(
+					"column '%s': %s columns can only have type 'bool'",
+					col.Name,
+					col.Kind,
+				)
+			}
+		}
+
+		if col.Transliterate != "" {
+			if _, ok := translit.New(col.Transliterate); !ok {
+				return fmt.Errorf(
+					"column '%s': invalid transliterate locale '%s', must be one of: %s",
+					col.Name,
+					col.Transliterate,
+					strings.Join(translit.Locales(), ", "),
+				)
+			}
+		}
+
+		if col.TimeZone != "" {
+			if err := validateTimeZoneColumn(col); err != nil {
+				return err
+			}
+		}
+
+		if col.CountryRollup != "" {
+			if err := validateCountryRollupColumn(col); err != nil {
+				return err
+			}
+		}
+
+		if col.Transform != "" {
+			if _, ok := config.Transforms[col.Transform]; !ok {
+				return fmt.Errorf(
+					"column '%s': unknown transform '%s', not defined in [transforms]",
+					col.Name,
+					col.Transform,
+				)
+			}
+		}
+
 		// Validate type hint
 		if !validDataTypes[col.Type] {
 			return fmt.Errorf(
@@ -370,20 +1636,1216 @@ func validate(config *Config) error {
 			)
 		}
 
-		// Check for duplicate column names (including network columns)
-		if networkColNames[col.Name] {
+		// Empty output_path is allowed - it means merge into root for MMDB output
+		if col.OutputPath != nil {
+			for _, seg := range *col.OutputPath {
+				var idx int64
+				switch v := seg.(type) {
+				case int:
+					idx = int64(v)
+				case int64:
+					idx = v
+				}
+				if idx < 0 {
+					return fmt.Errorf(
+						"column '%s': output_path index %d must not be negative",
+						col.Name,
+						idx,
+					)
+				}
+			}
+		}
+
+		if col.Redact != nil {
+			if err := validateRedact(col); err != nil {
+				return err
+			}
+		}
+
+		if col.ConflictPolicy != "" {
+			if config.Output.Format != formatMMDB {
+				return fmt.Errorf(
+					"column '%s': conflict_policy is only supported for mmdb output",
+					col.Name,
+				)
+			}
+			if !validConflictPolicies[col.ConflictPolicy] {
+				return fmt.Errorf(
+					"invalid conflict_policy '%s' for column '%s', must be one of: error, keep_existing, overwrite, concatenate",
+					col.ConflictPolicy,
+					col.Name,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateParquetColumns checks the per-column Parquet options. Whether an
+// encoding suits a column's physical type is checked when the schema is
+// built, as integer network columns change type between IPv4 and IPv6 files.
+func validateParquetColumns(config *Config, validCompressions map[string]bool) error {
+	columns := map[string]bool{}
+	for _, col := range config.Network.Columns {
+		columns[string(col.Name)] = true
+	}
+	for _, col := range config.Columns {
+		columns[string(col.Name)] = true
+	}
+
+	validEncodings := map[string]bool{
+		"plain":                   true,
+		"rle":                     true,
+		"delta_binary_packed":     true,
+		"delta_length_byte_array": true,
+		"delta_byte_array":        true,
+		"byte_stream_split":       true,
+	}
+	for name, opts := range config.Output.Parquet.Columns {
+		if !columns[name] {
+			return fmt.Errorf(
+				"output.parquet.columns: '%s' is not a configured network or data column",
+				name,
+			)
+		}
+		if opts.Encoding != "" && !validEncodings[opts.Encoding] {
+			return fmt.Errorf(
+				"invalid parquet encoding '%s' for column '%s', must be one of: plain, rle, delta_binary_packed, delta_length_byte_array, delta_byte_array, byte_stream_split",
+				opts.Encoding,
+				name,
+			)
+		}
+		if opts.Dictionary != nil && *opts.Dictionary && opts.Encoding != "" {
+			return fmt.Errorf(
+				"column '%s': dictionary = true cannot be combined with encoding '%s'",
+				name,
+				opts.Encoding,
+			)
+		}
+		if opts.Compression != "" && !validCompressions[opts.Compression] {
+			return fmt.Errorf(
+				"invalid parquet compression '%s' for column '%s', must be one of: none, snappy, gzip, lz4, zstd",
+				opts.Compression,
+				name,
+			)
+		}
+	}
+	return nil
+}
+
+// validateRedact checks the redaction policy of col.
+func validateRedact(col Column) error {
+	r := col.Redact
+	switch r.Policy {
+	case RedactDrop:
+	case RedactHash:
+		if (r.Salt == "") == (r.SaltEnv == "") {
+			return fmt.Errorf(
+				"column '%s': redact policy 'hash' requires exactly one of salt or salt_env",
+				col.Name,
+			)
+		}
+		if col.Type != "" && col.Type != "string" {
+			return fmt.Errorf("column '%s': hashed values can only have type 'string'", col.Name)
+		}
+	case RedactTruncate:
+		if r.Decimals == nil || *r.Decimals < 0 {
 			return fmt.Errorf(
-				"duplicate column name '%s' (already used as network column)",
+				"column '%s': redact policy 'truncate' requires decimals of 0 or more",
 				col.Name,
 			)
 		}
-		if dataColNames[col.Name] {
-			return fmt.Errorf("duplicate column name '%s'", col.Name)
+	default:
+		return fmt.Errorf(
+			"invalid redact policy '%s' for column '%s', must be one of: drop, hash, truncate",
+			r.Policy,
+			col.Name,
+		)
+	}
+	return nil
+}
+
+// validateColumnNames checks that the names of the network and data columns
+// are unique and usable by the output format, listing every offending name.
+// Missing names are reported by the column checks.
+func validateColumnNames(config *Config) error {
+	var problems []string
+	network := map[mmdbtype.String]bool{}
+	data := map[mmdbtype.String]bool{}
+	checkName := func(name mmdbtype.String) {
+		if strings.ContainsFunc(string(name), unicode.IsControl) {
+			problems = append(problems, fmt.Sprintf("column name %q contains control characters", name))
+		}
+		// Parquet readers separate the fields of nested columns with dots
+		if config.Output.Format == formatParquet && strings.Contains(string(name), ".") {
+			problems = append(
+				problems,
+				fmt.Sprintf("column name '%s' contains '.', which is not allowed in Parquet output", name),
+			)
+		}
+		if config.Output.Format == formatBigQuery &&
+			(!protobufIdentifier.MatchString(string(name)) || len(name) > 300) {
+			problems = append(
+				problems,
+				fmt.Sprintf(
+					"column name '%s' is not a BigQuery column name, which has at most 300 letters, digits, and underscores; set sql_name",
+					name,
+				),
+			)
+		}
+		if config.Output.Format == formatProtobuf && !protobufIdentifier.MatchString(string(name)) {
+			problems = append(
+				problems,
+				fmt.Sprintf(
+					"column name '%s' is not a protobuf field name, which has only letters, digits, and underscores; set storage_name",
+					name,
+				),
+			)
 		}
-		dataColNames[col.Name] = true
+	}
 
-		// Empty output_path is allowed - it means merge into root for MMDB output
+	// Columns are checked by the name they have in the output, which an
+	// alias must not give another column
+	output := map[string]mmdbtype.String{}
+	checkOutputName := func(col mmdbtype.String, name string) {
+		if other, ok := output[name]; ok {
+			problems = append(problems, fmt.Sprintf(
+				"columns '%s' and '%s' are both named '%s' in %s output",
+				other,
+				col,
+				name,
+				config.Output.Format,
+			))
+			return
+		}
+		output[name] = col
+		checkName(mmdbtype.String(name))
+	}
+
+	for _, col := range config.Network.Columns {
+		if col.Name == "" {
+			continue
+		}
+		if network[col.Name] {
+			problems = append(problems, fmt.Sprintf("duplicate network column name '%s'", col.Name))
+			continue
+		}
+		network[col.Name] = true
+		checkOutputName(col.Name, col.OutputName(config.Output.Format))
+	}
+	for _, col := range config.Columns {
+		if col.Name == "" {
+			continue
+		}
+		switch {
+		case network[col.Name]:
+			problems = append(
+				problems,
+				fmt.Sprintf("duplicate column name '%s' (already used as network column)", col.Name),
+			)
+		case data[col.Name]:
+			problems = append(problems, fmt.Sprintf("duplicate column name '%s'", col.Name))
+		default:
+			data[col.Name] = true
+			checkOutputName(col.Name, col.OutputName(config.Output.Format))
+		}
+	}
+	groups := map[mmdbtype.String]bool{}
+	for _, col := range config.Columns {
+		group := mmdbtype.String(col.Group)
+		if group == "" || groups[group] {
+			continue
+		}
+		groups[group] = true
+		if network[group] || data[group] {
+			problems = append(problems, fmt.Sprintf("group name '%s' is already used as a column name", group))
+			continue
+		}
+		checkName(group)
+	}
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(problems[0])
+	default:
+		return fmt.Errorf("%d invalid column names: %s", len(problems), strings.Join(problems, "; "))
+	}
+}
+
+// CaseCollisions returns the groups of column names in the output, network
+// and data columns alike, that differ only in case. They are valid, but tools that
+// ignore the case of names, such as most SQL databases, cannot tell them
+// apart.
+func (c *Config) CaseCollisions() [][]mmdbtype.String {
+	var (
+		order  []string
+		groups = map[string][]mmdbtype.String{}
+	)
+	add := func(name mmdbtype.String) {
+		key := strings.ToLower(string(name))
+		if slices.Contains(groups[key], name) {
+			return
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], name)
+	}
+	for _, col := range c.Network.Columns {
+		add(mmdbtype.String(col.OutputName(c.Output.Format)))
+	}
+	for _, col := range c.Columns {
+		add(mmdbtype.String(col.OutputName(c.Output.Format)))
+	}
+
+	var collisions [][]mmdbtype.String
+	for _, key := range order {
+		if len(groups[key]) > 1 {
+			collisions = append(collisions, groups[key])
+		}
 	}
+	return collisions
+}
 
+// validateIgnoreErrors checks that every output.ignore_errors entry has a
+// valid network and a reason.
+func validateIgnoreErrors(config *Config) error {
+	for i, entry := range config.Output.IgnoreErrors {
+		if _, err := netip.ParsePrefix(entry.Network); err != nil {
+			return fmt.Errorf(
+				"output.ignore_errors[%d]: invalid network '%s': %w",
+				i,
+				entry.Network,
+				err,
+			)
+		}
+		if strings.TrimSpace(entry.Reason) == "" {
+			return fmt.Errorf(
+				"output.ignore_errors[%d]: reason is required, to explain the ignored errors",
+				i,
+			)
+		}
+	}
 	return nil
 }
+
+// validateReservedNetworks checks the reserved network options: they apply
+// only to CSV and Parquet output, and values must name data columns and be
+// scalars.
+func validateReservedNetworks(config *Config) error {
+	reserved := config.Output.ReservedNetworks
+	if !reserved.Include {
+		if len(reserved.Values) > 0 {
+			return errors.New(
+				"output.reserved_networks.values requires output.reserved_networks.include = true",
+			)
+		}
+		return nil
+	}
+	if config.Output.Format == formatMMDB {
+		return errors.New(
+			"output.reserved_networks is not supported for MMDB output; use output.mmdb.include_reserved_networks",
+		)
+	}
+
+	return validateColumnValues(config, "output.reserved_networks.values", reserved.Values)
+}
+
+// validateAlign checks the range alignment options: prefix lengths must be
+// on byte boundaries, and only CSV and Parquet output has ranges to split.
+func validateAlign(config *Config) error {
+	align := config.Output.Align
+	if align.IPv4 != 0 && align.IPv4 != 8 && align.IPv4 != 16 && align.IPv4 != 24 {
+		return fmt.Errorf("output.align.ipv4 must be 8, 16, or 24, got %d", align.IPv4)
+	}
+	if align.IPv6 < 0 || align.IPv6 > 120 || align.IPv6%8 != 0 {
+		return fmt.Errorf("output.align.ipv6 must be a multiple of 8 up to 120, got %d", align.IPv6)
+	}
+	if align.Enabled() && config.Output.Format != formatCSV && config.Output.Format != formatParquet {
+		return fmt.Errorf(
+			"output.align is not supported for %s output, which is written as networks (only for csv and parquet)",
+			config.Output.Format,
+		)
+	}
+	return nil
+}
+
+// validateLimits checks the output limits: they cannot be negative, and
+// the size of MMDB output is only known once the merge completes.
+func validateLimits(config *Config) error {
+	limits := config.Output.Limits
+	if limits.MaxRows < 0 {
+		return errors.New("output.limits.max_rows cannot be negative")
+	}
+	if limits.MaxBytes < 0 {
+		return errors.New("output.limits.max_bytes cannot be negative")
+	}
+	if limits.OnExceed != LimitAbort && limits.OnExceed != LimitTruncate {
+		return fmt.Errorf(
+			"invalid output.limits.on_exceed '%s', must be one of: %s, %s",
+			limits.OnExceed,
+			LimitAbort,
+			LimitTruncate,
+		)
+	}
+	if limits.MaxBytes > 0 && config.Output.Format == formatMMDB {
+		return errors.New(
+			"output.limits.max_bytes is not supported for MMDB output, which is written when the merge completes",
+		)
+	}
+	if limits.MaxBytes > 0 && config.Output.Format == formatSQLite {
+		return errors.New(
+			"output.limits.max_bytes is not supported for SQLite output, which is written when the merge completes",
+		)
+	}
+	return nil
+}
+
+// validateXLSX checks the worksheet name, which Excel limits to 31
+// characters other than those of cell references, and that the row guard
+// fits in a worksheet.
+func validateXLSX(config *Config) error {
+	xlsx := config.Output.XLSX
+	if config.Output.Format != formatXLSX {
+		if xlsx != (XLSXConfig{}) {
+			return errors.New("output.xlsx is only supported for XLSX output")
+		}
+		return nil
+	}
+	if len([]rune(xlsx.Sheet)) > 31 || strings.ContainsAny(xlsx.Sheet, `[]:*?/\`) ||
+		strings.HasPrefix(xlsx.Sheet, "'") || strings.HasSuffix(xlsx.Sheet, "'") {
+		return fmt.Errorf(
+			"invalid output.xlsx.sheet '%s', must be at most 31 characters, without []:*?/\\ or surrounding quotes",
+			xlsx.Sheet,
+		)
+	}
+	if xlsx.MaxRows < 0 || xlsx.MaxRows > XLSXMaxRows {
+		return fmt.Errorf(
+			"output.xlsx.max_rows must be between 1 and %d, the rows a worksheet holds below its header, got %d",
+			XLSXMaxRows,
+			xlsx.MaxRows,
+		)
+	}
+	return nil
+}
+
+// protobufIdentifier matches the names of protobuf messages and fields.
+var protobufIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateProtobuf checks the names of the generated schema, and that it
+// is written next to the output rather than over it.
+func validateProtobuf(config *Config) error {
+	pb := config.Output.Protobuf
+	if config.Output.Format != formatProtobuf {
+		if pb != (ProtobufConfig{}) {
+			return errors.New("output.protobuf is only supported for protobuf output")
+		}
+		return nil
+	}
+	if !protobufIdentifier.MatchString(pb.Message) {
+		return fmt.Errorf(
+			"invalid output.protobuf.message '%s', must be letters, digits, and underscores, not starting with a digit",
+			pb.Message,
+		)
+	}
+	if pb.Package != "" && slices.ContainsFunc(strings.Split(pb.Package, "."), func(part string) bool {
+		return !protobufIdentifier.MatchString(part)
+	}) {
+		return fmt.Errorf("invalid output.protobuf.package '%s', must be identifiers separated by dots", pb.Package)
+	}
+	if pb.SchemaFile == "" {
+		return errors.New("output.protobuf.schema_file is required with output.ipv4_file and output.ipv6_file")
+	}
+	if slices.Contains(outputFiles(config.Output), pb.SchemaFile) {
+		return errors.New("output.protobuf.schema_file cannot be an output file")
+	}
+	return nil
+}
+
+// validateClickHouse checks the ClickHouse options.
+func validateClickHouse(config *Config) error {
+	ch := config.Output.ClickHouse
+	if config.Output.Format != formatClickHouse {
+		if ch != (ClickHouseConfig{}) {
+			return errors.New("output.clickhouse is only supported for ClickHouse output")
+		}
+		return nil
+	}
+	switch ch.Encoding {
+	case "rowbinary", "tsv":
+	default:
+		return fmt.Errorf(
+			"invalid output.clickhouse.encoding '%s', must be one of: rowbinary, tsv",
+			ch.Encoding,
+		)
+	}
+	if ch.DDLFile != "" && (ch.DDLFile == config.Output.File ||
+		ch.DDLFile == config.Output.IPv4File || ch.DDLFile == config.Output.IPv6File) {
+		return errors.New("output.clickhouse.ddl_file cannot be an output file")
+	}
+	return nil
+}
+
+// typedFormat reports whether output of format has typed columns, which
+// type hints shape.
+func typedFormat(format string) bool {
+	return format == formatParquet || format == formatClickHouse || format == formatPostgres ||
+		format == formatProtobuf || format == formatMsgpack || format == formatBigQuery
+}
+
+// validatePostgres checks the PostgreSQL options: the rows need a
+// connection string, and are copied into a table rather than written to
+// files.
+func validatePostgres(config *Config) error {
+	pg := config.Output.Postgres
+	if config.Output.Format != formatPostgres {
+		if pg != (PostgresConfig{}) {
+			return errors.New("output.postgres is only supported for PostgreSQL output")
+		}
+		return nil
+	}
+	if pg.DSN == "" {
+		return errors.New("output.postgres.dsn is required for PostgreSQL output")
+	}
+	if config.Output.File != "" || config.Output.IPv4File != "" || config.Output.IPv6File != "" {
+		return errors.New(
+			"output.file, output.ipv4_file, and output.ipv6_file are not supported for PostgreSQL output, which copies the rows into output.postgres.table",
+		)
+	}
+	if slices.Contains(strings.Split(pg.Table, "."), "") {
+		return fmt.Errorf("invalid output.postgres.table '%s'", pg.Table)
+	}
+	return nil
+}
+
+// bigQueryDataset matches the names of BigQuery datasets.
+var bigQueryDataset = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// validateBigQuery checks the BigQuery options: the rows need a project
+// and a dataset, and are loaded into a table rather than written to files.
+func validateBigQuery(config *Config) error {
+	bq := config.Output.BigQuery
+	if config.Output.Format != formatBigQuery {
+		if bq != (BigQueryConfig{}) {
+			return errors.New("output.bigquery is only supported for BigQuery output")
+		}
+		return nil
+	}
+	if bq.Project == "" {
+		return errors.New("output.bigquery.project is required for BigQuery output")
+	}
+	if bq.Dataset == "" {
+		return errors.New("output.bigquery.dataset is required for BigQuery output")
+	}
+	if !bigQueryDataset.MatchString(bq.Dataset) || len(bq.Dataset) > 1024 {
+		return fmt.Errorf(
+			"invalid output.bigquery.dataset '%s', must be at most 1024 letters, digits, and underscores",
+			bq.Dataset,
+		)
+	}
+	if strings.ContainsAny(bq.Table, "./") {
+		return fmt.Errorf("invalid output.bigquery.table '%s'", bq.Table)
+	}
+	if config.Output.File != "" || config.Output.IPv4File != "" || config.Output.IPv6File != "" {
+		return errors.New(
+			"output.file, output.ipv4_file, and output.ipv6_file are not supported for BigQuery output, which loads the rows into output.bigquery.table",
+		)
+	}
+	return nil
+}
+
+// validateSQLite checks the SQLite options: the rows need integer start
+// and end network columns, which the lookup index covers, and the table
+// name must not be one SQLite reserves.
+func validateSQLite(config *Config) error {
+	if config.Output.Format != formatSQLite {
+		if config.Output.SQLite.Table != "" {
+			return errors.New("output.sqlite.table is only supported for SQLite output")
+		}
+		return nil
+	}
+	if strings.HasPrefix(strings.ToLower(config.Output.SQLite.Table), "sqlite_") {
+		return fmt.Errorf(
+			"invalid output.sqlite.table '%s', names starting with 'sqlite_' are reserved",
+			config.Output.SQLite.Table,
+		)
+	}
+	var hasStart, hasEnd bool
+	for _, col := range config.Network.Columns {
+		hasStart = hasStart || col.Type == "start_int"
+		hasEnd = hasEnd || col.Type == "end_int"
+	}
+	if !hasStart || !hasEnd {
+		return errors.New(
+			"SQLite output requires network columns of type 'start_int' and 'end_int', which are indexed for lookups",
+		)
+	}
+	return nil
+}
+
+// validateAnonymity checks the minimum network sizes and that the columns
+// to generalize are data columns.
+func validateAnonymity(config *Config) error {
+	anonymity := config.Output.Anonymity
+	if anonymity.MinAddresses < 0 {
+		return errors.New("output.anonymity.min_addresses cannot be negative")
+	}
+	if anonymity.IPv6MaxPrefixLength < 0 || anonymity.IPv6MaxPrefixLength > 128 {
+		return fmt.Errorf(
+			"output.anonymity.ipv6_max_prefix_length must be between 0 and 128, got %d",
+			anonymity.IPv6MaxPrefixLength,
+		)
+	}
+	switch anonymity.Action {
+	case AnonymityDrop:
+		if len(anonymity.Generalize) > 0 {
+			return errors.New(`output.anonymity.generalize requires output.anonymity.action = "generalize"`)
+		}
+	case AnonymityGeneralize:
+		if len(anonymity.Generalize) == 0 {
+			return errors.New(
+				`output.anonymity.action = "generalize" requires output.anonymity.generalize, the columns to clear`,
+			)
+		}
+	default:
+		return fmt.Errorf(
+			"invalid output.anonymity.action '%s', must be one of: %s, %s",
+			anonymity.Action,
+			AnonymityDrop,
+			AnonymityGeneralize,
+		)
+	}
+	for _, name := range anonymity.Generalize {
+		if !slices.ContainsFunc(config.Columns, func(col Column) bool {
+			return string(col.Name) == name
+		}) {
+			return fmt.Errorf("output.anonymity.generalize: '%s' is not a configured data column", name)
+		}
+	}
+	return nil
+}
+
+// validateVerify checks that the output can be read back: it is a single
+// CSV, Parquet, or MMDB file per IP version, whose records are the rows
+// written.
+func validateVerify(config *Config) error {
+	if config.Output.Verify.SampleRows < 0 {
+		return errors.New("output.verify.sample_rows cannot be negative")
+	}
+	if !config.Output.Verify.Enabled() {
+		return nil
+	}
+	switch config.Output.Format {
+	case formatCSV:
+		if config.Output.CSV.MaxFileSize > 0 {
+			return errors.New("output.verify is not supported with output.csv.max_file_size")
+		}
+		if config.Output.CSV.Profile != "" {
+			return errors.New("output.verify is not supported with output.csv.profile")
+		}
+	case formatParquet:
+	case formatMMDB:
+		if config.Output.MMDB.Base != "" && config.Output.MMDB.InsertStrategy != "replace" {
+			return fmt.Errorf(
+				"output.verify is not supported with output.mmdb.insert_strategy '%s', which merges records with the base database",
+				config.Output.MMDB.InsertStrategy,
+			)
+		}
+	default:
+		return fmt.Errorf("output.verify is not supported for %s output", config.Output.Format)
+	}
+	return nil
+}
+
+// validatePermissions checks that the mode is an octal file mode, and that
+// the output is written to files.
+func validatePermissions(config *Config) error {
+	perms := config.Output.Permissions
+	if !perms.Enabled() {
+		return nil
+	}
+	if config.Output.Format == formatPostgres {
+		return errors.New("output.permissions is not supported for PostgreSQL output")
+	}
+	if config.Output.Format == formatBigQuery {
+		return errors.New("output.permissions is not supported for BigQuery output")
+	}
+	if perms.Mode != "" {
+		mode, err := strconv.ParseUint(perms.Mode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf(
+				"invalid output.permissions.mode '%s', must be an octal mode such as \"0640\"",
+				perms.Mode,
+			)
+		}
+	}
+	return nil
+}
+
+// timeZoneTypes are the Parquet types of the time_zone properties.
+var timeZoneTypes = map[string]string{
+	TimeZoneUTCOffset: "int64",
+	TimeZoneDST:       "bool",
+}
+
+// validateTimeZoneColumn checks a column outputting a property of the time
+// zone at its path.
+func validateTimeZoneColumn(col Column) error {
+	typ, ok := timeZoneTypes[col.TimeZone]
+	if !ok {
+		return fmt.Errorf(
+			"column '%s': invalid time_zone '%s', must be one of: %s, %s",
+			col.Name,
+			col.TimeZone,
+			TimeZoneUTCOffset,
+			TimeZoneDST,
+		)
+	}
+	if col.Tag != "" || col.PrefixLength || col.Kind != "" {
+		return fmt.Errorf(
+			"column '%s': time_zone cannot be combined with tag, prefix_length, or kind",
+			col.Name,
+		)
+	}
+	if col.Type != "" && col.Type != typ {
+		return fmt.Errorf(
+			"column '%s': time_zone '%s' can only have type '%s'",
+			col.Name,
+			col.TimeZone,
+			typ,
+		)
+	}
+	return nil
+}
+
+// countryRollupTypes are the Parquet types of the country_rollup
+// properties.
+var countryRollupTypes = map[string]string{
+	region.ContinentCode:     "string",
+	region.IsInEuropeanUnion: "bool",
+	region.UNRegion:          "string",
+}
+
+// validateCountryRollupColumn checks a column outputting a property of the
+// country at its path.
+func validateCountryRollupColumn(col Column) error {
+	typ, ok := countryRollupTypes[col.CountryRollup]
+	if !ok {
+		return fmt.Errorf(
+			"column '%s': invalid country_rollup '%s', must be one of: %s",
+			col.Name,
+			col.CountryRollup,
+			strings.Join(region.Properties(), ", "),
+		)
+	}
+	if col.Tag != "" || col.PrefixLength || col.Kind != "" || col.TimeZone != "" {
+		return fmt.Errorf(
+			"column '%s': country_rollup cannot be combined with tag, prefix_length, kind, or time_zone",
+			col.Name,
+		)
+	}
+	if col.Type != "" && col.Type != typ {
+		return fmt.Errorf(
+			"column '%s': country_rollup '%s' can only have type '%s'",
+			col.Name,
+			col.CountryRollup,
+			typ,
+		)
+	}
+	return nil
+}
+
+// validateScoreColumn checks the settings of a column of kind "score", which
+// is computed from its weights instead of read from a database.
+func validateScoreColumn(col Column) error {
+	if col.Database != "" || col.Path != nil || col.Tag != "" || col.PrefixLength ||
+		col.Transliterate != "" || col.Transform != "" || col.TimeZone != "" || col.CountryRollup != "" {
+		return fmt.Errorf(
+			"column '%s': kind '%s' cannot be combined with database, path, tag, prefix_length, transliterate, transform, time_zone, or country_rollup",
+			col.Name,
+			col.Kind,
+		)
+	}
+	if len(col.Weights) == 0 {
+		return fmt.Errorf("column '%s': kind '%s' requires weights", col.Name, col.Kind)
+	}
+	if col.Type != "" && col.Type != "float64" {
+		return fmt.Errorf("column '%s': scores can only have type 'float64'", col.Name)
+	}
+	return nil
+}
+
+// validateScoreWeights checks that the weights of score columns are keyed by
+// the names of data columns that are not scores themselves.
+func validateScoreWeights(config *Config) error {
+	kinds := map[string]string{}
+	for _, col := range config.Columns {
+		kinds[string(col.Name)] = col.Kind
+	}
+	for _, col := range config.Columns {
+		if col.Kind != ColumnKindScore {
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(col.Weights)) {
+			kind, ok := kinds[name]
+			switch {
+			case !ok:
+				return fmt.Errorf(
+					"column '%s': weights: '%s' is not a configured data column",
+					col.Name,
+					name,
+				)
+			case kind == ColumnKindScore:
+				return fmt.Errorf(
+					"column '%s': weights: '%s' is a score column itself",
+					col.Name,
+					name,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// validateColumnValues checks that values, set in section, are keyed by
+// data column names and hold scalar values.
+func validateColumnValues(config *Config, section string, values map[string]any) error {
+	columns := map[string]bool{}
+	for _, col := range config.Columns {
+		columns[string(col.Name)] = true
+	}
+	for name, value := range values {
+		if !columns[name] {
+			return fmt.Errorf(
+				"%s: '%s' is not a configured data column",
+				section,
+				name,
+			)
+		}
+		switch value.(type) {
+		case string, bool, int64, float64:
+		default:
+			return fmt.Errorf(
+				"%s: value for '%s' must be a string, integer, float, or boolean, got %T",
+				section,
+				name,
+				value,
+			)
+		}
+	}
+	return nil
+}
+
+// validVCLName reports whether name can be used as a VCL ACL name.
+func validVCLName(name string) bool {
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '_'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// validateHistory checks the options of a time-sliced export: only one
+// database may have history, the output must be able to hold overlapping
+// rows, and the validity columns are only used with history.
+func validateHistory(config *Config) error {
+	var history string
+	for _, db := range config.Databases {
+		if len(db.History) == 0 {
+			continue
+		}
+		if history != "" {
+			return fmt.Errorf(
+				"only one database can have history, found '%s' and '%s'",
+				history,
+				db.Name,
+			)
+		}
+		history = db.Name
+		for _, pattern := range db.History {
+			if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+				return fmt.Errorf("invalid history pattern %q for database '%s'", pattern, db.Name)
+			}
+		}
+	}
+
+	hasValidFrom := false
+	for _, col := range config.Network.Columns {
+		if col.Type != "valid_from" && col.Type != "valid_to" {
+			continue
+		}
+		if history == "" {
+			return fmt.Errorf(
+				"network column '%s' of type '%s' requires a database with history",
+				col.Name,
+				col.Type,
+			)
+		}
+		hasValidFrom = hasValidFrom || col.Type == "valid_from"
+	}
+	if history == "" {
+		return nil
+	}
+
+	switch {
+	case config.Output.Format == formatMMDB:
+		return errors.New("database history is not supported for MMDB output")
+	case config.Output.Format != formatCSV && config.Output.Format != formatParquet:
+		return fmt.Errorf("database history is not supported for %s output", config.Output.Format)
+	case !hasValidFrom:
+		return errors.New("database history requires a network column of type 'valid_from'")
+	case config.MaxNestingDepth > 0:
+		return errors.New("max_nesting_depth cannot be used with database history")
+	case config.Output.ReservedNetworks.Include:
+		return errors.New("output.reserved_networks cannot be used with database history")
+	}
+	return nil
+}
+
+// HistoryDatabase returns the database with history, if there is one.
+func (c *Config) HistoryDatabase() (Database, bool) {
+	for _, db := range c.Databases {
+		if len(db.History) > 0 {
+			return db, true
+		}
+	}
+	return Database{}, false
+}
+
+// validateParquetAppend checks the options for appending to a dataset.
+func validateParquetAppend(config *Config) error {
+	if !config.Output.Parquet.Append {
+		if config.Output.Parquet.SchemaMismatch != "" {
+			return errors.New("output.parquet.schema_mismatch requires output.parquet.append")
+		}
+		return nil
+	}
+	switch config.Output.Parquet.SchemaMismatch {
+	case "error", "cast":
+	default:
+		return fmt.Errorf(
+			"invalid output.parquet.schema_mismatch '%s', must be one of: error, cast",
+			config.Output.Parquet.SchemaMismatch,
+		)
+	}
+	if config.Output.File == "" {
+		return errors.New(
+			"output.parquet.append requires output.file, the dataset directory, and cannot be used with split IPv4/IPv6 output",
+		)
+	}
+	return nil
+}
+
+// validatePreset checks that the preset exists and reads from a configured
+// database.
+func validatePreset(config *Config) error {
+	if config.Preset.Name == "" {
+		if config.Preset.Database != "" {
+			return errors.New("preset.database requires preset.name")
+		}
+		return nil
+	}
+	if _, ok := preset.Lookup(config.Preset.Name); !ok {
+		return fmt.Errorf(
+			"invalid preset.name '%s', must be one of: %s",
+			config.Preset.Name,
+			strings.Join(preset.Names(), ", "),
+		)
+	}
+	if config.Preset.Database == "" {
+		return errors.New("preset.database is required")
+	}
+	if !slices.ContainsFunc(config.Databases, func(db Database) bool {
+		return db.Name == config.Preset.Database
+	}) {
+		return fmt.Errorf("preset.database references unknown database '%s'", config.Preset.Database)
+	}
+	return nil
+}
+
+// editionKindTypes are the type hints of the kinds of edition fields.
+var editionKindTypes = map[template.Kind]string{
+	template.KindString:  "string",
+	template.KindBool:    "bool",
+	template.KindUint16:  "int64",
+	template.KindUint32:  "int64",
+	template.KindFloat64: "float64",
+}
+
+// resolveEditionFields sets the path of the columns naming a field of the
+// edition of their database, and for typed formats their type hint. Columns
+// with a path, or naming an unknown edition or field, are left for
+// validateEditionField to report.
+func resolveEditionFields(config *Config) {
+	editions := map[string]string{}
+	for _, db := range config.Databases {
+		editions[db.Name] = db.Edition
+	}
+	for i := range config.Columns {
+		col := &config.Columns[i]
+		if col.Field == "" || len(col.Path) > 0 {
+			continue
+		}
+		ed, ok := edition.Lookup(editions[col.Database])
+		if !ok {
+			continue
+		}
+		field, ok := ed.Field(col.Field)
+		if !ok {
+			continue
+		}
+		col.Path = Path(slices.Clone(field.Path))
+		if col.Type == "" && typedFormat(config.Output.Format) {
+			col.Type = editionKindTypes[field.Kind]
+		}
+	}
+}
+
+// validateEdition checks the edition of a database.
+func validateEdition(db Database) error {
+	if db.Edition == "" {
+		return nil
+	}
+	if _, ok := edition.Lookup(db.Edition); !ok {
+		return fmt.Errorf(
+			"invalid edition '%s' for database '%s', must be one of: %s",
+			db.Edition,
+			db.Name,
+			strings.Join(edition.Names(), ", "),
+		)
+	}
+	return nil
+}
+
+// validateEditionField checks that a column naming a field reads from a
+// database with an edition that has the field, and that the field was
+// resolved to its path rather than combined with another source of values.
+func validateEditionField(col Column, databases []Database) error {
+	if col.Tag != "" || col.PrefixLength || col.Kind != "" {
+		return fmt.Errorf(
+			"column '%s': field cannot be combined with tag, prefix_length, or kind",
+			col.Name,
+		)
+	}
+	i := slices.IndexFunc(databases, func(db Database) bool {
+		return db.Name == col.Database
+	})
+	if databases[i].Edition == "" {
+		return fmt.Errorf(
+			"column '%s': field requires an edition for database '%s'",
+			col.Name,
+			col.Database,
+		)
+	}
+	ed, _ := edition.Lookup(databases[i].Edition)
+	field, ok := ed.Field(col.Field)
+	if !ok {
+		return fmt.Errorf(
+			"column '%s': edition %s has no field '%s', must be one of: %s",
+			col.Name,
+			ed.Name,
+			col.Field,
+			strings.Join(ed.FieldNames(), ", "),
+		)
+	}
+	if !slices.Equal(col.Path, Path(field.Path)) {
+		return fmt.Errorf("column '%s': field and path cannot both be set", col.Name)
+	}
+	return nil
+}
+
+// validateDatabaseMode checks the mode of a database and the options that
+// depend on it.
+func validateDatabaseMode(db Database) error {
+	switch db.Mode {
+	case "", DatabaseModeIterate:
+		if db.AllowImpreciseLookup {
+			return fmt.Errorf(
+				"database '%s': allow_imprecise_lookup requires mode = \"lookup\"",
+				db.Name,
+			)
+		}
+	case DatabaseModeLookup:
+		if db.Preload {
+			return fmt.Errorf("database '%s': mode = \"lookup\" cannot be combined with preload", db.Name)
+		}
+	default:
+		return fmt.Errorf(
+			"invalid mode '%s' for database '%s', must be one of: iterate, lookup",
+			db.Mode,
+			db.Name,
+		)
+	}
+	return nil
+}
+
+// validateDriverDatabase checks that the driver database is one the merge
+// iterates: configured, not preloaded, and used by a column.
+func validateDriverDatabase(config *Config) error {
+	if config.DriverDatabase == "" {
+		return nil
+	}
+	i := slices.IndexFunc(config.Databases, func(db Database) bool {
+		return db.Name == config.DriverDatabase
+	})
+	if i < 0 {
+		return fmt.Errorf("driver_database references unknown database '%s'", config.DriverDatabase)
+	}
+	if config.Databases[i].Preload || config.Databases[i].Mode == DatabaseModeLookup {
+		return fmt.Errorf(
+			"driver_database '%s' is preloaded or in lookup mode, so it is looked up rather than iterated",
+			config.DriverDatabase,
+		)
+	}
+	if !slices.ContainsFunc(config.Columns, func(col Column) bool {
+		return col.Database == config.DriverDatabase
+	}) {
+		return fmt.Errorf("driver_database '%s' is not used by any column", config.DriverDatabase)
+	}
+	return nil
+}
+
+// validateTransforms checks the steps of each transform pipeline.
+func validateTransforms(config *Config) error {
+	for _, name := range slices.Sorted(maps.Keys(config.Transforms)) {
+		tc := config.Transforms[name]
+		if _, err := transform.New(tc.Steps, tc.Options()); err != nil {
+			return fmt.Errorf("transforms.%s: %w", name, err)
+		}
+		if tc.OrgNamesFile != "" && !slices.Contains(tc.Steps, transform.OrgNameStep) {
+			return fmt.Errorf(
+				"transforms.%s: org_names_file requires the step: %s",
+				name,
+				transform.OrgNameStep,
+			)
+		}
+	}
+	return nil
+}
+
+// validateCSVProfile checks that the configuration produces the exact layout
+// of the CSV profile: the start and end of each range as addresses and
+// integers, followed by the country code and name, without a header.
+func validateCSVProfile(config *Config) error {
+	switch config.Output.CSV.Profile {
+	case "":
+		return nil
+	case csvProfileGeoIPLegacy:
+	default:
+		return fmt.Errorf(
+			"invalid output.csv.profile '%s', must be: %s",
+			config.Output.CSV.Profile,
+			csvProfileGeoIPLegacy,
+		)
+	}
+
+	if _, ok := config.HistoryDatabase(); ok {
+		return fmt.Errorf(
+			"database history is not supported with output.csv.profile '%s'",
+			csvProfileGeoIPLegacy,
+		)
+	}
+
+	types := make([]string, len(config.Network.Columns))
+	for i, col := range config.Network.Columns {
+		types[i] = col.Type
+	}
+	if !slices.Equal(types, geoIPLegacyNetworkTypes) {
+		return fmt.Errorf(
+			"output.csv.profile '%s' requires the network columns %s, in that order",
+			csvProfileGeoIPLegacy,
+			strings.Join(geoIPLegacyNetworkTypes, ", "),
+		)
+	}
+	if len(config.Columns) != 2 {
+		return fmt.Errorf(
+			"output.csv.profile '%s' requires exactly two data columns, the country code and the country name, got %d",
+			csvProfileGeoIPLegacy,
+			len(config.Columns),
+		)
+	}
+	if config.Output.CSV.Delimiter != "," {
+		return fmt.Errorf(
+			"output.csv.delimiter cannot be changed with output.csv.profile '%s'",
+			csvProfileGeoIPLegacy,
+		)
+	}
+	if *config.Output.CSV.IncludeHeader {
+		return fmt.Errorf(
+			"output.csv.include_header cannot be enabled with output.csv.profile '%s'",
+			csvProfileGeoIPLegacy,
+		)
+	}
+	return nil
+}
+
+// validateTemplate checks that the configured columns fit the output
+// template: every required field has a column, and columns that fill a
+// template field don't move it elsewhere.
+func validateTemplate(config *Config) error {
+	tmpl, ok := template.Lookup(config.Output.MMDB.Template)
+	if !ok {
+		return fmt.Errorf(
+			"invalid output.mmdb.template '%s', must be one of: %s",
+			config.Output.MMDB.Template,
+			strings.Join(template.Names(), ", "),
+		)
+	}
+
+	configured := map[string]Column{}
+	for _, col := range config.Columns {
+		configured[string(col.Name)] = col
+	}
+
+	for _, field := range tmpl.Fields {
+		col, ok := configured[field.Name]
+		if !ok {
+			if field.Required {
+				return fmt.Errorf(
+					"template '%s' requires a column named '%s'",
+					tmpl.Name,
+					field.Name,
+				)
+			}
+			continue
+		}
+		if !segmentsEqual(*col.OutputPath, field.Path) {
+			return fmt.Errorf(
+				"column '%s': output_path %v conflicts with template '%s', which places it at %v",
+				col.Name,
+				[]any(*col.OutputPath),
+				tmpl.Name,
+				field.Path,
+			)
+		}
+	}
+	return nil
+}
+
+// segmentsEqual compares path segments, treating int and int64 indexes as
+// equal.
+func segmentsEqual(a, b []any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if normalizeIndex(a[i]) != normalizeIndex(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeIndex(seg any) any {
+	if v, ok := seg.(int); ok {
+		return int64(v)
+	}
+	return seg
+}
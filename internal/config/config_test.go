@@ -4,9 +4,11 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/stretchr/testify/require"
 )
 
@@ -95,6 +97,66 @@ path = ["country", "iso_code"]
 				assertPathEquals(t, cfg.Columns[0].Path, "country", "iso_code")
 			},
 		},
+		{
+			name: "split by IP version",
+			toml: `
+[output]
+format = "parquet"
+file = "out/geo.parquet"
+split_by_ip_version = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.File != "" {
+					t.Error("expected output.file empty when splitting")
+				}
+				if cfg.Output.IPv4File != "out/geo-v4.parquet" ||
+					cfg.Output.IPv6File != "out/geo-v6.parquet" {
+					t.Errorf(
+						"expected derived per-version filenames, got %s and %s",
+						cfg.Output.IPv4File,
+						cfg.Output.IPv6File,
+					)
+				}
+			},
+		},
+		{
+			name: "output permissions",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.permissions]
+mode = "0640"
+owner = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if !cfg.Output.Permissions.Enabled() {
+					t.Error("expected output.permissions enabled")
+				}
+				if mode := cfg.Output.Permissions.FileMode(); mode != 0o640 {
+					t.Errorf("expected mode 0640, got %o", mode)
+				}
+			},
+		},
 		{
 			name: "parquet config with custom network columns",
 			toml: `
@@ -223,35 +285,4773 @@ path = ["country", "iso_code"]
 				assertPathEquals(t, cfg.Columns[0].Path, "country", "iso_code")
 			},
 		},
-	}
+		{
+			name: "MMDB template fills output paths and database type",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Create temporary config file
-			tmpDir := t.TempDir()
-			configPath := filepath.Join(tmpDir, "config.toml")
-			if err := os.WriteFile(configPath, []byte(tt.toml), 0o644); err != nil {
-				t.Fatalf("failed to write test config: %v", err)
-			}
+[output.mmdb]
+template = "geoip2-country"
 
-			// Load and validate config
-			cfg, err := LoadConfig(configPath)
-			if err != nil {
-				t.Fatalf("LoadConfig failed: %v", err)
-			}
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
 
-			// Run custom validation
-			tt.validate(t, cfg)
-		})
-	}
-}
+[[columns]]
+name = "country_iso_code"
+database = "geo"
+path = ["country", "iso_code"]
 
-func TestLoadConfig_InvalidMixedOutputs(t *testing.T) {
-	const toml = `
+[[columns]]
+name = "custom"
+database = "geo"
+path = ["custom"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.MMDB.DatabaseType != "GeoIP2-Country" {
+					t.Errorf(
+						"expected database_type from template, got %s",
+						cfg.Output.MMDB.DatabaseType,
+					)
+				}
+				require.NotNil(t, cfg.Columns[0].OutputPath)
+				assertPathEquals(t, *cfg.Columns[0].OutputPath, "country", "iso_code")
+				if cfg.Columns[1].OutputPath != nil {
+					t.Errorf("expected no output_path for non-template column")
+				}
+			},
+		},
+		{
+			name: "MMDB base database defaults",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+base = "existing.mmdb"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.MMDB.InsertStrategy != "replace" {
+					t.Errorf(
+						"expected default insert_strategy=replace, got %s",
+						cfg.Output.MMDB.InsertStrategy,
+					)
+				}
+				if cfg.Output.MMDB.DatabaseType != "" {
+					t.Errorf("expected database_type to be left to the base database")
+				}
+				if !*cfg.Output.MMDB.IncludeReservedNetworks {
+					t.Errorf("expected include_reserved_networks default true with a base")
+				}
+				if cfg.Output.MMDB.OnInsertError != InsertErrorAbort {
+					t.Errorf(
+						"expected default on_insert_error=abort, got %s",
+						cfg.Output.MMDB.OnInsertError,
+					)
+				}
+			},
+		},
+		{
+			name: "periodic sync",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.sync]
+every_rows = 100000
+every_seconds = 30
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.Sync.EveryRows != 100000 {
+					t.Errorf("expected every_rows=100000, got %d", cfg.Output.Sync.EveryRows)
+				}
+				if cfg.Output.Sync.EverySeconds != 30 {
+					t.Errorf("expected every_seconds=30, got %d", cfg.Output.Sync.EverySeconds)
+				}
+				if !cfg.Output.Sync.Enabled() {
+					t.Error("expected sync to be enabled")
+				}
+			},
+		},
+		{
+			name: "range alignment",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.align]
+ipv4 = 16
+ipv6 = 32
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, AlignConfig{IPv4: 16, IPv6: 32}, cfg.Output.Align)
+				require.True(t, cfg.Output.Align.Enabled())
+			},
+		},
+		{
+			name: "output limits",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.limits]
+max_rows = 1000000
+max_bytes = 104857600
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, LimitsConfig{
+					MaxRows:  1000000,
+					MaxBytes: 104857600,
+					OnExceed: LimitAbort,
+				}, cfg.Output.Limits)
+				require.True(t, cfg.Output.Limits.Enabled())
+			},
+		},
+		{
+			name: "output verify",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.verify]
+sample_rows = 1000
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, VerifyConfig{SampleRows: 1000}, cfg.Output.Verify)
+				require.True(t, cfg.Output.Verify.Enabled())
+			},
+		},
+		{
+			name: "output anonymity",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.anonymity]
+min_addresses = 256
+ipv6_max_prefix_length = 48
+action = "generalize"
+generalize = ["city"]
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "city"
+database = "geo"
+path = ["city", "names", "en"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, AnonymityConfig{
+					MinAddresses:        256,
+					IPv6MaxPrefixLength: 48,
+					Action:              AnonymityGeneralize,
+					Generalize:          []string{"city"},
+				}, cfg.Output.Anonymity)
+				require.True(t, cfg.Output.Anonymity.Enabled())
+			},
+		},
+		{
+			name: "score column",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[outputs]]
+format = "csv"
+file = "risk.csv"
+columns = ["risk_score"]
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+kind = "traits_booleans"
+database = "anon"
+
+[[columns]]
+name = "risk_score"
+kind = "score"
+weights = { is_anonymous_vpn = 40, is_tor_exit_node = 60 }
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Columns, 7)
+				score := cfg.Columns[6]
+				require.Equal(t, ColumnKindScore, score.Kind)
+				require.Empty(t, score.Database)
+				require.Equal(t, "float64", score.Type)
+				require.Equal(
+					t,
+					map[string]float64{"is_anonymous_vpn": 40, "is_tor_exit_node": 60},
+					score.Weights,
+				)
+				require.Equal(t, "", cfg.AdditionalOutputs()[0].Columns[0].Type)
+			},
+		},
+		{
+			name: "time zone columns",
+			toml: `
+time_zone_reference = "2025-01-01T00:00:00Z"
+
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "city"
+path = "/path/to/city.mmdb"
+
+[[columns]]
+name = "utc_offset"
+database = "city"
+path = ["location", "time_zone"]
+time_zone = "utc_offset"
+
+[[columns]]
+name = "is_dst"
+database = "city"
+path = ["location", "time_zone"]
+time_zone = "dst"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, "2025-01-01T00:00:00Z", cfg.TimeZoneReference)
+				require.Equal(t, TimeZoneUTCOffset, cfg.Columns[0].TimeZone)
+				require.Equal(t, "int64", cfg.Columns[0].Type)
+				require.Equal(t, TimeZoneDST, cfg.Columns[1].TimeZone)
+				require.Equal(t, "bool", cfg.Columns[1].Type)
+			},
+		},
+		{
+			name: "country rollup columns",
+			toml: `
+country_rollup_file = "countries.csv"
+
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "country"
+path = "/path/to/country.mmdb"
+
+[[columns]]
+name = "continent"
+database = "country"
+path = ["country", "iso_code"]
+country_rollup = "continent_code"
+
+[[columns]]
+name = "is_eu"
+database = "country"
+path = ["country", "iso_code"]
+country_rollup = "is_in_european_union"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, "countries.csv", cfg.CountryRollupFile)
+				require.Equal(t, "continent_code", cfg.Columns[0].CountryRollup)
+				require.Equal(t, "string", cfg.Columns[0].Type)
+				require.Equal(t, "is_in_european_union", cfg.Columns[1].CountryRollup)
+				require.Equal(t, "bool", cfg.Columns[1].Type)
+			},
+		},
+		{
+			name: "split mmdb output",
+			toml: `
+[output]
+format = "mmdb"
+ipv4_file = "out_ipv4.mmdb"
+ipv6_file = "out_ipv6.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.IPv4File != "out_ipv4.mmdb" || cfg.Output.IPv6File != "out_ipv6.mmdb" {
+					t.Error("missing per-version filenames")
+				}
+			},
+		},
+		{
+			name: "max_nesting_depth",
+			toml: `
+max_nesting_depth = 2
+
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.MaxNestingDepth != 2 {
+					t.Errorf("expected max_nesting_depth=2, got %d", cfg.MaxNestingDepth)
+				}
+			},
+		},
+		{
+			name: "driver_database",
+			toml: `
+driver_database = "anon"
+
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "is_anonymous"
+database = "anon"
+path = ["is_anonymous"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, "anon", cfg.DriverDatabase)
+			},
+		},
+		{
+			name: "database in lookup mode",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "asn"
+path = "/path/to/asn.mmdb"
+mode = "lookup"
+allow_imprecise_lookup = true
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "asn"
+database = "asn"
+path = ["autonomous_system_number"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Empty(t, cfg.Databases[0].Mode)
+				require.Equal(t, DatabaseModeLookup, cfg.Databases[1].Mode)
+				require.True(t, cfg.Databases[1].AllowImpreciseLookup)
+			},
+		},
+		{
+			name: "preloaded database",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "overrides"
+path = "/path/to/overrides.mmdb"
+preload = true
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "override"
+database = "overrides"
+path = ["country"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Databases[0].Preload {
+					t.Error("expected geo not to be preloaded")
+				}
+				if !cfg.Databases[1].Preload {
+					t.Error("expected overrides to be preloaded")
+				}
+			},
+		},
+		{
+			name: "csv provenance header",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.csv]
+provenance_header = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if !cfg.Output.CSV.ProvenanceHeader {
+					t.Error("expected provenance_header to be true")
+				}
+				if len(cfg.Provenance.ConfigSHA256) != 64 {
+					t.Errorf("expected a hex SHA-256 config hash, got %q", cfg.Provenance.ConfigSHA256)
+				}
+			},
+		},
+		{
+			name: "database history",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+history = ["/path/to/archive/geo-*.mmdb"]
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				db, ok := cfg.HistoryDatabase()
+				if !ok || db.Name != "geo" {
+					t.Fatalf("expected geo to have history, got %v", db)
+				}
+				var types []string
+				for _, col := range cfg.Network.Columns {
+					types = append(types, col.Type)
+				}
+				if got := strings.Join(types, ","); got != "cidr,valid_from,valid_to" {
+					t.Errorf("expected default network columns cidr,valid_from,valid_to, got %s", got)
+				}
+			},
+		},
+		{
+			name: "heartbeat",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[heartbeat]
+file = "/tmp/mmdbconvert-status.json"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Heartbeat.File != "/tmp/mmdbconvert-status.json" {
+					t.Errorf("unexpected heartbeat file %q", cfg.Heartbeat.File)
+				}
+				if cfg.Heartbeat.EverySeconds != 10 {
+					t.Errorf("expected heartbeat every 10 seconds by default, got %d", cfg.Heartbeat.EverySeconds)
+				}
+			},
+		},
+		{
+			name: "empty columns warn by default",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.EmptyColumns != EmptyColumnsWarn {
+					t.Errorf("expected empty_columns %q by default, got %q", EmptyColumnsWarn, cfg.EmptyColumns)
+				}
+			},
+		},
+		{
+			name: "database tags",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+tags = { vendor = "maxmind", edition = "GeoIP2-City" }
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "geo_vendor"
+database = "geo"
+tag = "vendor"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Databases[0].Tags["edition"] != "GeoIP2-City" {
+					t.Errorf("unexpected tags %v", cfg.Databases[0].Tags)
+				}
+				if cfg.Columns[1].TagValue != mmdbtype.String("maxmind") {
+					t.Errorf("expected tag value maxmind, got %v", cfg.Columns[1].TagValue)
+				}
+			},
+		},
+		{
+			name: "ptr output",
+			toml: `
+[output]
+format = "ptr"
+file = "geo.rev"
+
+[output.ptr]
+template = "{country}.geo.example."
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.PTR.TTL != 3600 {
+					t.Errorf("expected default TTL 3600, got %d", cfg.Output.PTR.TTL)
+				}
+				if len(cfg.Network.Columns) != 0 {
+					t.Errorf("expected no network columns, got %v", cfg.Network.Columns)
+				}
+			},
+		},
+		{
+			name: "vcl output with filter",
+			toml: `
+[output]
+format = "vcl"
+file = "hosting.vcl"
+
+[output.filter]
+is_hosting = true
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_hosting"
+database = "anon"
+path = ["is_hosting_provider"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.VCL.ACL != "mmdbconvert" {
+					t.Errorf("expected default ACL name mmdbconvert, got %q", cfg.Output.VCL.ACL)
+				}
+				if cfg.Output.Filter["is_hosting"] != true {
+					t.Errorf("unexpected filter %v", cfg.Output.Filter)
+				}
+			},
+		},
+		{
+			name: "envoy output",
+			toml: `
+[output]
+format = "envoy"
+file = "hosting.yaml"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_hosting"
+database = "anon"
+path = ["is_hosting_provider"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.Envoy.Encoding != "yaml" {
+					t.Errorf("expected default encoding yaml, got %q", cfg.Output.Envoy.Encoding)
+				}
+			},
+		},
+		{
+			name: "sqlite output",
+			toml: `
+[output]
+format = "sqlite"
+file = "geo.sqlite"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.SQLite.Table != "networks" {
+					t.Errorf("expected default table networks, got %q", cfg.Output.SQLite.Table)
+				}
+				if len(cfg.Network.Columns) != 2 || cfg.Network.Columns[0].Type != "start_int" ||
+					cfg.Network.Columns[1].Type != "end_int" {
+					t.Errorf("expected integer network columns, got %v", cfg.Network.Columns)
+				}
+			},
+		},
+		{
+			name: "column aliases",
+			toml: `
+[output]
+format = "parquet"
+file = "geo.parquet"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+header = "Network"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+header = "Country"
+storage_name = "cntry_cd"
+sql_name = "country_code"
+
+[[outputs]]
+format = "csv"
+file = "geo.csv"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				col := cfg.Columns[0]
+				for format, want := range map[string]string{
+					"csv":     "Country",
+					"xlsx":    "Country",
+					"parquet": "cntry_cd",
+					"sqlite":  "country_code",
+					"mmdb":    "country",
+				} {
+					if got := col.OutputName(format); got != want {
+						t.Errorf("expected %s name '%s', got '%s'", format, want, got)
+					}
+				}
+				if got := cfg.Network.Columns[0].OutputName("parquet"); got != "network" {
+					t.Errorf("expected network column name 'network', got '%s'", got)
+				}
+				csv := cfg.AdditionalOutputs()[0]
+				if got := csv.Columns[0].OutputName(csv.Output.Format); got != "Country" {
+					t.Errorf("expected the header in csv output, got '%s'", got)
+				}
+			},
+		},
+		{
+			name: "xlsx output",
+			toml: `
+[output]
+format = "xlsx"
+file = "geo.xlsx"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.XLSX.Sheet != "networks" || cfg.Output.XLSX.MaxRows != XLSXMaxRows {
+					t.Errorf("unexpected xlsx defaults %+v", cfg.Output.XLSX)
+				}
+				if len(cfg.Network.Columns) != 1 || cfg.Network.Columns[0].Type != "cidr" {
+					t.Errorf("expected a cidr network column, got %v", cfg.Network.Columns)
+				}
+			},
+		},
+		{
+			name: "protobuf output",
+			toml: `
+[output]
+format = "protobuf"
+file = "out/geo.pb"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "country-name"
+storage_name = "country_name"
+database = "geo"
+path = ["country", "names", "en"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.Protobuf.Message != "Network" {
+					t.Errorf("expected message 'Network', got '%s'", cfg.Output.Protobuf.Message)
+				}
+				if cfg.Output.Protobuf.SchemaFile != "out/geo.proto" {
+					t.Errorf("expected schema file 'out/geo.proto', got '%s'", cfg.Output.Protobuf.SchemaFile)
+				}
+			},
+		},
+		{
+			name: "msgpack output",
+			toml: `
+[output]
+format = "msgpack"
+file = "geo.msgpack"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "accuracy"
+storage_name = "accuracy_radius"
+database = "geo"
+path = ["location", "accuracy_radius"]
+type = "int64"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if len(cfg.Network.Columns) != 1 || cfg.Network.Columns[0].Type != "cidr" {
+					t.Errorf("expected a cidr network column, got %v", cfg.Network.Columns)
+				}
+				if got := cfg.Columns[0].OutputName(cfg.Output.Format); got != "accuracy_radius" {
+					t.Errorf("expected the storage name as the key, got '%s'", got)
+				}
+			},
+		},
+		{
+			name: "clickhouse output",
+			toml: `
+[output]
+format = "clickhouse"
+file = "geo.bin"
+
+[output.clickhouse]
+ddl_file = "geo.sql"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "accuracy"
+database = "geo"
+path = ["location", "accuracy_radius"]
+type = "int64"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.ClickHouse.Encoding != "rowbinary" || cfg.Output.ClickHouse.Table != "networks" {
+					t.Errorf("unexpected clickhouse defaults %+v", cfg.Output.ClickHouse)
+				}
+				if len(cfg.Network.Columns) != 2 || cfg.Network.Columns[0].Type != "start_int" {
+					t.Errorf("expected integer network columns, got %v", cfg.Network.Columns)
+				}
+				if cfg.Columns[1].Type != "int64" {
+					t.Errorf("expected type hint to be kept, got %q", cfg.Columns[1].Type)
+				}
+			},
+		},
+		{
+			name: "bigquery output",
+			toml: `
+[output]
+format = "bigquery"
+
+[output.bigquery]
+project = "geo-project"
+dataset = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country-code"
+database = "geo"
+path = ["country", "iso_code"]
+sql_name = "country_code"
+
+[[columns]]
+name = "accuracy"
+database = "geo"
+path = ["location", "accuracy_radius"]
+type = "int64"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.BigQuery.Table != "networks" {
+					t.Errorf("expected default table networks, got %q", cfg.Output.BigQuery.Table)
+				}
+				if len(cfg.Network.Columns) != 1 || cfg.Network.Columns[0].Type != "cidr" {
+					t.Errorf("expected a cidr network column, got %v", cfg.Network.Columns)
+				}
+				if cfg.Columns[0].OutputName("bigquery") != "country_code" {
+					t.Errorf("expected sql_name to be used, got %q", cfg.Columns[0].OutputName("bigquery"))
+				}
+				if cfg.Columns[1].Type != "int64" {
+					t.Errorf("expected type hint to be kept, got %q", cfg.Columns[1].Type)
+				}
+				if len(cfg.OutputFiles()) != 0 {
+					t.Errorf("expected no output files, got %v", cfg.OutputFiles())
+				}
+			},
+		},
+		{
+			name: "postgres output",
+			toml: `
+[output]
+format = "postgres"
+
+[output.postgres]
+dsn = "postgres://geo@localhost/geo"
+create_table = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "accuracy"
+database = "geo"
+path = ["location", "accuracy_radius"]
+type = "int64"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.Postgres.Table != "networks" {
+					t.Errorf("expected default table networks, got %q", cfg.Output.Postgres.Table)
+				}
+				if len(cfg.Network.Columns) != 1 || cfg.Network.Columns[0].Type != "cidr" {
+					t.Errorf("expected a cidr network column, got %v", cfg.Network.Columns)
+				}
+				if cfg.Columns[1].Type != "int64" {
+					t.Errorf("expected type hint to be kept, got %q", cfg.Columns[1].Type)
+				}
+				if len(cfg.OutputFiles()) != 0 {
+					t.Errorf("expected no output files, got %v", cfg.OutputFiles())
+				}
+			},
+		},
+		{
+			name: "geoip legacy csv profile",
+			toml: `
+[output]
+format = "csv"
+file = "GeoIPCountryWhois.csv"
+
+[output.csv]
+profile = "geoip-legacy"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country_code"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "country_name"
+database = "geo"
+path = ["country", "names", "en"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if *cfg.Output.CSV.IncludeHeader {
+					t.Error("expected the header to be disabled by default")
+				}
+				var types []string
+				for _, col := range cfg.Network.Columns {
+					types = append(types, col.Type)
+				}
+				want := []string{"start_ip", "end_ip", "start_int", "end_int"}
+				if !slices.Equal(types, want) {
+					t.Errorf("expected network columns %v, got %v", want, types)
+				}
+			},
+		},
+		{
+			name: "edition fields",
+			toml: `
+[output]
+format = "parquet"
+file = "out.parquet"
+
+[[databases]]
+name = "isp"
+path = "/path/to/isp.mmdb"
+edition = "GeoIP2-ISP"
+
+[[columns]]
+name = "asn"
+database = "isp"
+field = "autonomous_system_number"
+
+[[columns]]
+name = "isp"
+database = "isp"
+field = "isp"
+type = "binary"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				asn := cfg.Columns[0]
+				if !slices.Equal(asn.Path, Path{"autonomous_system_number"}) || asn.Type != "int64" {
+					t.Errorf("expected the path and type of the ASN field, got %v %s", asn.Path, asn.Type)
+				}
+				isp := cfg.Columns[1]
+				if !slices.Equal(isp.Path, Path{"isp"}) || isp.Type != "binary" {
+					t.Errorf("expected the path of the ISP field and the configured type, got %v %s", isp.Path, isp.Type)
+				}
+			},
+		},
+		{
+			name: "city preset",
+			toml: `
+[output]
+format = "mmdb"
+file = "out.mmdb"
+
+[output.mmdb]
+template = "geoip2-city"
+
+[preset]
+name = "city"
+database = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_anonymous"
+database = "anon"
+path = ["is_anonymous"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if len(cfg.Columns) != 12 {
+					t.Fatalf("expected 11 preset columns and 1 configured column, got %d", len(cfg.Columns))
+				}
+				first := cfg.Columns[0]
+				if first.Name != "continent_code" || first.Database != "geo" {
+					t.Errorf("expected preset columns first, got %+v", first)
+				}
+				if first.OutputPath == nil {
+					t.Error("expected template output paths for preset columns")
+				}
+				if cfg.Columns[11].Name != "is_anonymous" {
+					t.Errorf("expected configured column last, got %s", cfg.Columns[11].Name)
+				}
+				for _, col := range cfg.Columns {
+					ignored := col.Name == "latitude" || col.Name == "longitude" ||
+						col.Name == "accuracy_radius"
+					if col.MergeIgnore != ignored {
+						t.Errorf("column %s: expected merge_ignore %v", col.Name, ignored)
+					}
+				}
+			},
+		},
+		{
+			name: "parquet dataset append",
+			toml: `
+[output]
+format = "parquet"
+file = "dataset"
+
+[output.parquet]
+append = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Output.Parquet.SchemaMismatch != "error" {
+					t.Errorf(
+						"expected default schema_mismatch error, got %q",
+						cfg.Output.Parquet.SchemaMismatch,
+					)
+				}
+			},
+		},
+		{
+			name: "ignore_errors networks",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[[output.ignore_errors]]
+network = "1.2.3.0/24"
+reason = "broken record in the vendor test database"
+
+[[output.ignore_errors]]
+network = "2001:db8::/32"
+reason = "documentation range"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				expected := []IgnoreErrorsConfig{
+					{Network: "1.2.3.0/24", Reason: "broken record in the vendor test database"},
+					{Network: "2001:db8::/32", Reason: "documentation range"},
+				}
+				if !reflect.DeepEqual(cfg.Output.IgnoreErrors, expected) {
+					t.Errorf("expected ignore_errors %v, got %v", expected, cfg.Output.IgnoreErrors)
+				}
+			},
+		},
+		{
+			name: "prefix_length column",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "geo_prefix_length"
+database = "geo"
+prefix_length = true
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				if !cfg.Columns[0].PrefixLength {
+					t.Error("expected prefix_length to be set")
+				}
+				if cfg.Columns[0].Type != "int64" {
+					t.Errorf("expected type int64 by default, got '%s'", cfg.Columns[0].Type)
+				}
+			},
+		},
+		{
+			name: "redact policies",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "latitude"
+database = "geo"
+path = ["location", "latitude"]
+redact = { policy = "truncate", decimals = 2 }
+
+[[columns]]
+name = "postal_code"
+database = "geo"
+path = ["postal", "code"]
+redact = { policy = "hash", salt_env = "POSTAL_SALT" }
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, "truncate:2", cfg.Columns[0].Redact.String())
+				require.Equal(t, "hash", cfg.Columns[1].Redact.String())
+				require.Equal(t, "POSTAL_SALT", cfg.Columns[1].Redact.SaltEnv)
+			},
+		},
+		{
+			name: "named transforms",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[transforms.clean_name]
+steps = ["trim", "collapse_ws", "title_case"]
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "city"
+database = "geo"
+path = ["city", "names", "en"]
+transform = "clean_name"
+
+[[columns]]
+name = "subdivision"
+database = "geo"
+path = ["subdivisions", 0, "names", "en"]
+transform = "clean_name"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, map[string]TransformConfig{
+					"clean_name": {Steps: []string{"trim", "collapse_ws", "title_case"}},
+				}, cfg.Transforms)
+				require.Equal(t, "clean_name", cfg.Columns[0].Transform)
+				require.Equal(t, "clean_name", cfg.Columns[1].Transform)
+			},
+		},
+		{
+			name: "country code transform",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[transforms.country]
+steps = ["trim", "country_alpha3"]
+country_aliases = { UK = "GB", XK = "XKX" }
+unknown_countries = "error"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+transform = "country"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, TransformConfig{
+					Steps:            []string{"trim", "country_alpha3"},
+					CountryAliases:   map[string]string{"UK": "GB", "XK": "XKX"},
+					UnknownCountries: "error",
+				}, cfg.Transforms["country"])
+			},
+		},
+		{
+			name: "organization name transform",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[transforms.org]
+steps = ["trim", "org_name"]
+org_names_file = "org_names.csv"
+
+[[databases]]
+name = "asn"
+path = "/path/to/asn.mmdb"
+
+[[columns]]
+name = "org"
+database = "asn"
+path = ["autonomous_system_organization"]
+transform = "org"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, TransformConfig{
+					Steps:        []string{"trim", "org_name"},
+					OrgNamesFile: "org_names.csv",
+				}, cfg.Transforms["org"])
+			},
+		},
+		{
+			name: "traits_booleans columns",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+record_size = 28
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "anon_"
+kind = "traits_booleans"
+database = "anon"
+output_path = ["traits"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Columns, 7)
+				require.Equal(t, mmdbtype.String("country"), cfg.Columns[0].Name)
+				require.Nil(t, cfg.Columns[0].Default)
+
+				col := cfg.Columns[1]
+				require.Equal(t, mmdbtype.String("anon_is_anonymous"), col.Name)
+				require.Equal(t, "anon", col.Database)
+				require.Equal(t, Path{"is_anonymous"}, col.Path)
+				require.Equal(t, &Path{"traits", "is_anonymous"}, col.OutputPath)
+				require.Equal(t, mmdbtype.Bool(false), col.Default)
+
+				var names []string
+				for _, col := range cfg.Columns[1:] {
+					names = append(names, string(col.Name))
+				}
+				require.Equal(t, []string{
+					"anon_is_anonymous",
+					"anon_is_anonymous_vpn",
+					"anon_is_hosting_provider",
+					"anon_is_public_proxy",
+					"anon_is_residential_proxy",
+					"anon_is_tor_exit_node",
+				}, names)
+			},
+		},
+		{
+			name: "traits_booleans columns with template",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+template = "geoip2-anonymous-ip"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+kind = "traits_booleans"
+database = "anon"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Columns, 6)
+				require.Equal(t, mmdbtype.String("is_hosting_provider"), cfg.Columns[2].Name)
+				require.Equal(t, &Path{"is_hosting_provider"}, cfg.Columns[2].OutputPath)
+			},
+		},
+		{
+			name: "traits_booleans columns for parquet",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+kind = "traits_booleans"
+database = "anon"
+path = ["traits"]
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Columns, 6)
+				for _, col := range cfg.Columns {
+					require.Equal(t, "bool", col.Type)
+					require.Equal(t, Path{"traits", string(col.Name)}, col.Path)
+				}
+			},
+		},
+		{
+			name: "additional outputs",
+			toml: `
+[output]
+format = "parquet"
+file = "wide.parquet"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[network.columns]]
+name = "start_ip"
+type = "start_ip"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "latitude"
+database = "geo"
+path = ["location", "latitude"]
+type = "float64"
+group = "location"
+
+[[outputs]]
+format = "csv"
+file = "edge.csv"
+columns = ["latitude", "country"]
+network_columns = ["start_ip"]
+
+[outputs.csv]
+delimiter = ";"
+
+[[outputs]]
+format = "mmdb"
+file = "out.mmdb"
+
+[outputs.mmdb]
+database_type = "Edge"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Columns, 2)
+				outputs := cfg.AdditionalOutputs()
+				require.Len(t, outputs, 2)
+
+				csv := outputs[0]
+				require.Equal(t, "edge.csv", csv.Output.File)
+				require.Equal(t, ";", csv.Output.CSV.Delimiter)
+				require.Equal(t, []Column{
+					{Name: "latitude", Database: "geo", Path: Path{"location", "latitude"}},
+					{Name: "country", Database: "geo", Path: Path{"country", "iso_code"}},
+				}, csv.Columns, "type hints and groups only apply to Parquet")
+				require.Equal(t, []NetworkColumn{{Name: "start_ip", Type: "start_ip"}}, csv.Network.Columns)
+				require.Equal(t, cfg.Databases, csv.Databases)
+
+				// Defaults apply for the format of the output
+				mmdb := outputs[1]
+				require.Len(t, mmdb.Columns, 2)
+				require.Equal(t, "Edge", mmdb.Output.MMDB.DatabaseType)
+				require.Equal(t, 28, *mmdb.Output.MMDB.RecordSize)
+				require.Empty(t, mmdb.Columns[1].Type)
+				require.Equal(t, cfg.Network.Columns, mmdb.Network.Columns)
+			},
+		},
+		{
+			name: "additional output with default network columns",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[outputs]]
+format = "parquet"
+ipv4_file = "v4.parquet"
+ipv6_file = "v6.parquet"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Equal(t, []NetworkColumn{{Name: "network", Type: "cidr"}}, cfg.Network.Columns)
+				outputs := cfg.AdditionalOutputs()
+				require.Len(t, outputs, 1)
+				require.Equal(t, []NetworkColumn{
+					{Name: "start_int", Type: "start_int"},
+					{Name: "end_int", Type: "end_int"},
+				}, outputs[0].Network.Columns)
+				require.Equal(t, cfg.Columns, outputs[0].Columns)
+			},
+		},
+		{
+			name: "column groups",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "latitude"
+database = "geo"
+path = ["location", "latitude"]
+type = "float64"
+group = "location"
+
+[[columns]]
+name = "anon_"
+kind = "traits_booleans"
+database = "geo"
+path = ["traits"]
+group = "traits"
+`,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Columns, 7)
+				require.Equal(t, "location", cfg.Columns[0].Group)
+				// Expanded columns keep the group
+				for _, col := range cfg.Columns[1:] {
+					require.Equal(t, "traits", col.Group)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create temporary config file
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "config.toml")
+			if err := os.WriteFile(configPath, []byte(tt.toml), 0o644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+
+			// Load and validate config
+			cfg, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+
+			// Run custom validation
+			tt.validate(t, cfg)
+		})
+	}
+}
+
+func TestLoadConfig_InvalidMixedOutputs(t *testing.T) {
+	const toml = `
+[output]
+format = "csv"
+file = "combined.csv"
+ipv4_file = "v4.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`
+
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(toml), 0o644))
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "cannot be used together") {
+		t.Fatalf("expected error about mutually exclusive files, got %v", err)
+	}
+}
+
+func TestLoadConfig_InvalidPartialSplit(t *testing.T) {
+	const toml = `
+[output]
+format = "csv"
+ipv4_file = "v4.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`
+
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(toml), 0o644))
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "either output.file must be set") {
+		t.Fatalf("expected error about providing both ipv4 and ipv6 files, got %v", err)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		toml        string
+		expectError string
+	}{
+		{
+			name: "missing output format",
+			toml: `
+[output]
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.format is required",
+		},
+		{
+			name: "invalid output format",
+			toml: `
+[output]
+format = "json"
+file = "output.json"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.format must be 'csv', 'parquet', 'mmdb', 'ptr', 'vcl', 'envoy', 'sqlite', 'clickhouse', 'postgres', 'xlsx', 'protobuf', 'msgpack', or 'bigquery'",
+		},
+		{
+			name: "missing output file",
+			toml: `
+[output]
+format = "csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "either output.file must be set or both output.ipv4_file and output.ipv6_file must be provided",
+		},
+		{
+			name: "no databases",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "at least one database is required",
+		},
+		{
+			name: "duplicate database names",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo1.mmdb"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo2.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "duplicate database name 'geo'",
+		},
+		{
+			name: "missing database name",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "database name is required",
+		},
+		{
+			name: "missing database path",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "database path is required",
+		},
+		{
+			name: "unknown database reference",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "unknown"
+path = ["country", "iso_code"]
+`,
+			expectError: "references unknown database 'unknown'",
+		},
+		{
+			name: "duplicate column names",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "name"]
+`,
+			expectError: "duplicate column name 'country'",
+		},
+		{
+			name: "missing column name",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "column name is required",
+		},
+		{
+			name: "missing column database",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+path = ["country", "iso_code"]
+`,
+			expectError: "column database is required",
+		},
+		// Note: missing column path is now valid - path = [] means "copy entire record"
+		{
+			name: "invalid network column type",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[network.columns]]
+name = "network"
+type = "invalid"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid network column type 'invalid'",
+		},
+		{
+			name: "invalid data column type",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+type = "invalid"
+`,
+			expectError: "type hints not supported for csv output",
+		},
+		{
+			name: "invalid parquet compression",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.parquet]
+compression = "invalid"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid parquet compression 'invalid'",
+		},
+		{
+			name: "duplicate network column names",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[network.columns]]
+name = "network"
+type = "start_ip"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "duplicate network column name 'network'",
+		},
+		{
+			name: "column name conflicts with network column",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "network"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "duplicate column name 'network' (already used as network column)",
+		},
+		{
+			name: "conflict_policy on non-mmdb output",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+conflict_policy = "overwrite"
+`,
+			expectError: "conflict_policy is only supported for mmdb output",
+		},
+		{
+			name: "invalid conflict_policy",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+conflict_policy = "merge"
+`,
+			expectError: "invalid conflict_policy 'merge' for column 'country'",
+		},
+		{
+			name: "negative output_path index",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "subdivision"
+database = "geo"
+path = ["subdivisions", -1, "iso_code"]
+output_path = ["subdivisions", -1, "iso_code"]
+`,
+			expectError: "output_path index -1 must not be negative",
+		},
+		{
+			name: "unknown MMDB template",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+template = "geoip2-isp"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country_iso_code"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.mmdb.template 'geoip2-isp'",
+		},
+		{
+			name: "MMDB template missing required column",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+template = "geoip2-anonymous-ip"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_anonymous_vpn"
+database = "anon"
+path = ["is_anonymous_vpn"]
+`,
+			expectError: "template 'geoip2-anonymous-ip' requires a column named 'is_anonymous'",
+		},
+		{
+			name: "MMDB template output_path conflict",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+template = "geoip2-country"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country_iso_code"
+database = "geo"
+path = ["country", "iso_code"]
+output_path = ["country_code"]
+`,
+			expectError: "conflicts with template 'geoip2-country'",
+		},
+		{
+			name: "MMDB template with non-mmdb output",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.mmdb]
+template = "geoip2-country"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country_iso_code"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.mmdb.template is only supported for MMDB output",
+		},
+		{
+			name: "insert_strategy without base",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+insert_strategy = "deep_merge"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.mmdb.insert_strategy requires output.mmdb.base",
+		},
+		{
+			name: "invalid insert_strategy",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+base = "existing.mmdb"
+insert_strategy = "append"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.mmdb.insert_strategy 'append'",
+		},
+		{
+			name: "invalid MMDB on_insert_error",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+on_insert_error = "ignore"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.mmdb.on_insert_error 'ignore', must be one of: abort, skip",
+		},
+		{
+			name: "invalid MMDB ip_version",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+ip_version = "4"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.mmdb.ip_version '4', must be one of: ipv4, ipv6, auto",
+		},
+		{
+			name: "reserved network values for unknown column",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.reserved_networks]
+include = true
+values = { country_code = "ZZ" }
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "'country_code' is not a configured data column",
+		},
+		{
+			name: "reserved network values without include",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.reserved_networks]
+values = { country = "ZZ" }
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "requires output.reserved_networks.include = true",
+		},
+		{
+			name: "reserved networks with mmdb output",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+
+[output.reserved_networks]
+include = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.reserved_networks is not supported for MMDB output",
+		},
+		{
+			name: "reserved network value with non-scalar type",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.reserved_networks]
+include = true
+values = { country = ["ZZ"] }
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "value for 'country' must be a string, integer, float, or boolean",
+		},
+		{
+			name: "negative sync rows",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.sync]
+every_rows = -1
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.sync.every_rows cannot be negative",
+		},
+		{
+			name: "sync with mmdb output",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+
+[output.sync]
+every_seconds = 30
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.sync is not supported for MMDB output",
+		},
+		{
+			name: "alignment not on a byte boundary",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.align]
+ipv4 = 12
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.align.ipv4 must be 8, 16, or 24, got 12",
+		},
+		{
+			name: "ipv6 alignment too long",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.align]
+ipv6 = 128
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.align.ipv6 must be a multiple of 8 up to 120, got 128",
+		},
+		{
+			name: "alignment with vcl output",
+			toml: `
+[output]
+format = "vcl"
+file = "output.vcl"
+
+[output.align]
+ipv4 = 16
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.align is not supported for vcl output",
+		},
+		{
+			name: "negative row limit",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.limits]
+max_rows = -1
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.limits.max_rows cannot be negative",
+		},
+		{
+			name: "invalid on_exceed",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.limits]
+max_rows = 10
+on_exceed = "warn"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.limits.on_exceed 'warn', must be one of: abort, truncate",
+		},
+		{
+			name: "byte limit with mmdb output",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+
+[output.limits]
+max_bytes = 1000
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.limits.max_bytes is not supported for MMDB output",
+		},
+		{
+			name: "negative verify sample rows",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.verify]
+sample_rows = -1
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.verify.sample_rows cannot be negative",
+		},
+		{
+			name: "verify with unsupported format",
+			toml: `
+[output]
+format = "vcl"
+file = "output.vcl"
+
+[output.vcl]
+acl = "test"
+
+[output.verify]
+sample_rows = 100
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.verify is not supported for vcl output",
+		},
+		{
+			name: "verify with rotated csv",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.csv]
+max_file_size = 1000000
+
+[output.verify]
+sample_rows = 100
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.verify is not supported with output.csv.max_file_size",
+		},
+		{
+			name: "verify with merged base records",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+base = "base.mmdb"
+insert_strategy = "deep_merge"
+
+[output.verify]
+sample_rows = 100
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.verify is not supported with output.mmdb.insert_strategy 'deep_merge'",
+		},
+		{
+			name: "invalid clickhouse encoding",
+			toml: `
+[output]
+format = "clickhouse"
+file = "geo.bin"
+
+[output.clickhouse]
+encoding = "native"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.clickhouse.encoding 'native', must be one of: rowbinary, tsv",
+		},
+		{
+			name: "clickhouse ddl file is the output file",
+			toml: `
+[output]
+format = "clickhouse"
+file = "geo.tsv"
+
+[output.clickhouse]
+encoding = "tsv"
+ddl_file = "geo.tsv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.clickhouse.ddl_file cannot be an output file",
+		},
+		{
+			name: "clickhouse options for csv output",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.clickhouse]
+ddl_file = "geo.sql"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.clickhouse is only supported for ClickHouse output",
+		},
+		{
+			name: "alias used by another column",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+header = "city"
+
+[[columns]]
+name = "city"
+database = "geo"
+path = ["city", "names", "en"]
+`,
+			expectError: "columns 'country' and 'city' are both named 'city' in csv output",
+		},
+		{
+			name: "storage name with a dot",
+			toml: `
+[output]
+format = "parquet"
+file = "geo.parquet"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+storage_name = "country.iso_code"
+`,
+			expectError: "column name 'country.iso_code' contains '.', which is not allowed in Parquet output",
+		},
+		{
+			name: "invalid xlsx sheet name",
+			toml: `
+[output]
+format = "xlsx"
+file = "geo.xlsx"
+
+[output.xlsx]
+sheet = "2024/Q1"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.xlsx.sheet '2024/Q1'",
+		},
+		{
+			name: "xlsx sheet name too long",
+			toml: `
+[output]
+format = "xlsx"
+file = "geo.xlsx"
+
+[output.xlsx]
+sheet = "networks of the countries we ship to"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.xlsx.sheet",
+		},
+		{
+			name: "xlsx max rows above the worksheet limit",
+			toml: `
+[output]
+format = "xlsx"
+file = "geo.xlsx"
+
+[output.xlsx]
+max_rows = 1048576
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.xlsx.max_rows must be between 1 and 1048575",
+		},
+		{
+			name: "xlsx options for csv output",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.xlsx]
+sheet = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.xlsx is only supported for XLSX output",
+		},
+		{
+			name: "invalid protobuf message name",
+			toml: `
+[output]
+format = "protobuf"
+file = "geo.pb"
+
+[output.protobuf]
+message = "geo.Network"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.protobuf.message 'geo.Network'",
+		},
+		{
+			name: "invalid protobuf package",
+			toml: `
+[output]
+format = "protobuf"
+file = "geo.pb"
+
+[output.protobuf]
+package = "geo..v1"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.protobuf.package 'geo..v1'",
+		},
+		{
+			name: "column name not a protobuf field name",
+			toml: `
+[output]
+format = "protobuf"
+file = "geo.pb"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country-code"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "column name 'country-code' is not a protobuf field name",
+		},
+		{
+			name: "protobuf schema file overwriting the output",
+			toml: `
+[output]
+format = "protobuf"
+file = "geo.pb"
+
+[output.protobuf]
+schema_file = "geo.pb"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.protobuf.schema_file cannot be an output file",
+		},
+		{
+			name: "protobuf split output without schema file",
+			toml: `
+[output]
+format = "protobuf"
+ipv4_file = "geo-v4.pb"
+ipv6_file = "geo-v6.pb"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.protobuf.schema_file is required with output.ipv4_file and output.ipv6_file",
+		},
+		{
+			name: "protobuf options for csv output",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.protobuf]
+message = "Geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.protobuf is only supported for protobuf output",
+		},
+		{
+			name: "postgres output without dsn",
+			toml: `
+[output]
+format = "postgres"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.postgres.dsn is required for PostgreSQL output",
+		},
+		{
+			name: "invalid permissions mode",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.permissions]
+mode = "rw-r--r--"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.permissions.mode 'rw-r--r--'",
+		},
+		{
+			name: "permissions mode out of range",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.permissions]
+mode = "1777"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.permissions.mode '1777'",
+		},
+		{
+			name: "split by IP version without output file",
+			toml: `
+[output]
+format = "csv"
+split_by_ip_version = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.split_by_ip_version requires output.file",
+		},
+		{
+			name: "split by IP version for postgres output",
+			toml: `
+[output]
+format = "postgres"
+split_by_ip_version = true
+
+[output.postgres]
+dsn = "postgres://geo@localhost/geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.split_by_ip_version is not supported for PostgreSQL output",
+		},
+		{
+			name: "postgres output with output file",
+			toml: `
+[output]
+format = "postgres"
+file = "geo.csv"
+
+[output.postgres]
+dsn = "postgres://geo@localhost/geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.file, output.ipv4_file, and output.ipv6_file are not supported for PostgreSQL output",
+		},
+		{
+			name: "postgres output with invalid table",
+			toml: `
+[output]
+format = "postgres"
+
+[output.postgres]
+dsn = "postgres://geo@localhost/geo"
+table = "geo."
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.postgres.table 'geo.'",
+		},
+		{
+			name: "postgres output with sync",
+			toml: `
+[output]
+format = "postgres"
+
+[output.postgres]
+dsn = "postgres://geo@localhost/geo"
+
+[output.sync]
+every_rows = 1000
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.sync is not supported for PostgreSQL output",
+		},
+		{
+			name: "postgres options for csv output",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.postgres]
+dsn = "postgres://geo@localhost/geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.postgres is only supported for PostgreSQL output",
+		},
+		{
+			name: "bigquery output without project",
+			toml: `
+[output]
+format = "bigquery"
+
+[output.bigquery]
+dataset = "geo"
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.bigquery.project is required for BigQuery output",
+		},
+		{
+			name: "bigquery output without dataset",
+			toml: `
+[output]
+format = "bigquery"
+
+[output.bigquery]
+project = "geo-project"
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.bigquery.dataset is required for BigQuery output",
+		},
+		{
+			name: "bigquery output with invalid dataset",
+			toml: `
+[output]
+format = "bigquery"
+
+[output.bigquery]
+project = "geo-project"
+dataset = "geo-data"
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.bigquery.dataset 'geo-data'",
+		},
+		{
+			name: "bigquery output with qualified table",
+			toml: `
+[output]
+format = "bigquery"
+
+[output.bigquery]
+project = "geo-project"
+dataset = "geo"
+table = "geo.networks"
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.bigquery.table 'geo.networks'",
+		},
+		{
+			name: "bigquery output with output file",
+			toml: `
+[output]
+format = "bigquery"
+file = "geo.csv"
+
+[output.bigquery]
+project = "geo-project"
+dataset = "geo"
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.file, output.ipv4_file, and output.ipv6_file are not supported for BigQuery output",
+		},
+		{
+			name: "split by IP version for bigquery output",
+			toml: `
+[output]
+format = "bigquery"
+split_by_ip_version = true
+
+[output.bigquery]
+project = "geo-project"
+dataset = "geo"
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.split_by_ip_version is not supported for BigQuery output",
+		},
+		{
+			name: "bigquery output with sync",
+			toml: `
+[output]
+format = "bigquery"
+
+[output.bigquery]
+project = "geo-project"
+dataset = "geo"
+
+[output.sync]
+every_rows = 1000
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.sync is not supported for BigQuery output",
+		},
+		{
+			name: "bigquery options for csv output",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.bigquery]
+project = "geo-project"
+
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.bigquery is only supported for BigQuery output",
+		},
+		{
+			name: "column name not a bigquery column name",
+			toml: `
+[output]
+format = "bigquery"
+
+[output.bigquery]
+project = "geo-project"
+dataset = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country-code"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "column name 'country-code' is not a BigQuery column name",
+		},
+		{
+			name: "sqlite output without integer network columns",
+			toml: `
+[output]
+format = "sqlite"
+file = "geo.sqlite"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "SQLite output requires network columns of type 'start_int' and 'end_int'",
+		},
+		{
+			name: "reserved sqlite table",
+			toml: `
+[output]
+format = "sqlite"
+file = "geo.sqlite"
+
+[output.sqlite]
+table = "sqlite_geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.sqlite.table 'sqlite_geo', names starting with 'sqlite_' are reserved",
+		},
+		{
+			name: "sqlite table for csv output",
+			toml: `
+[output]
+format = "csv"
+file = "geo.csv"
+
+[output.sqlite]
+table = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.sqlite.table is only supported for SQLite output",
+		},
+		{
+			name: "invalid anonymity action",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.anonymity]
+min_addresses = 256
+action = "merge"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "city"
+database = "geo"
+path = ["city", "names", "en"]
+`,
+			expectError: "invalid output.anonymity.action 'merge', must be one of: drop, generalize",
+		},
+		{
+			name: "anonymity generalize without action",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.anonymity]
+min_addresses = 256
+generalize = ["city"]
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "city"
+database = "geo"
+path = ["city", "names", "en"]
+`,
+			expectError: "output.anonymity.generalize requires output.anonymity.action = \"generalize\"",
+		},
+		{
+			name: "anonymity generalize unknown column",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.anonymity]
+min_addresses = 256
+action = "generalize"
+generalize = ["postal_code"]
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "city"
+database = "geo"
+path = ["city", "names", "en"]
+`,
+			expectError: "output.anonymity.generalize: 'postal_code' is not a configured data column",
+		},
+		{
+			name: "weights without score kind",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_tor_exit_node"
+database = "anon"
+path = ["is_tor_exit_node"]
+
+[[columns]]
+name = "risk_score"
+database = "anon"
+path = ["risk"]
+weights = { is_tor_exit_node = 60 }
+`,
+			expectError: "column 'risk_score': weights require kind 'score'",
+		},
+		{
+			name: "score column with database",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_tor_exit_node"
+database = "anon"
+path = ["is_tor_exit_node"]
+
+[[columns]]
+name = "risk_score"
+kind = "score"
+database = "anon"
+weights = { is_tor_exit_node = 60 }
+`,
+			expectError: "column 'risk_score': kind 'score' cannot be combined with database, path",
+		},
+		{
+			name: "score column without weights",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_tor_exit_node"
+database = "anon"
+path = ["is_tor_exit_node"]
+
+[[columns]]
+name = "risk_score"
+kind = "score"
+`,
+			expectError: "column 'risk_score': kind 'score' requires weights",
+		},
+		{
+			name: "score weight for unknown column",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_tor_exit_node"
+database = "anon"
+path = ["is_tor_exit_node"]
+
+[[columns]]
+name = "risk_score"
+kind = "score"
+weights = { is_tor_exit_node = 60, is_vpn = 40 }
+`,
+			expectError: "column 'risk_score': weights: 'is_vpn' is not a configured data column",
+		},
+		{
+			name: "score weight for score column",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "is_tor_exit_node"
+database = "anon"
+path = ["is_tor_exit_node"]
+
+[[columns]]
+name = "tor_score"
+kind = "score"
+weights = { is_tor_exit_node = 1 }
+
+[[columns]]
+name = "risk_score"
+kind = "score"
+weights = { tor_score = 60 }
+`,
+			expectError: "column 'risk_score': weights: 'tor_score' is a score column itself",
+		},
+		{
+			name: "invalid time zone property",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "city"
+path = "/path/to/city.mmdb"
+
+[[columns]]
+name = "utc_offset"
+database = "city"
+path = ["location", "time_zone"]
+time_zone = "abbreviation"
+`,
+			expectError: "column 'utc_offset': invalid time_zone 'abbreviation', must be one of: utc_offset, dst",
+		},
+		{
+			name: "time zone column with wrong type",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "city"
+path = "/path/to/city.mmdb"
+
+[[columns]]
+name = "utc_offset"
+database = "city"
+path = ["location", "time_zone"]
+time_zone = "dst"
+type = "string"
+`,
+			expectError: "column 'utc_offset': time_zone 'dst' can only have type 'bool'",
+		},
+		{
+			name: "invalid time zone reference",
+			toml: `
+time_zone_reference = "2025-01-01"
+
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "city"
+path = "/path/to/city.mmdb"
+
+[[columns]]
+name = "utc_offset"
+database = "city"
+path = ["location", "time_zone"]
+time_zone = "utc_offset"
+`,
+			expectError: "invalid time_zone_reference '2025-01-01', must be an RFC 3339 time",
+		},
+		{
+			name: "invalid country rollup",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "country"
+path = "/path/to/country.mmdb"
+
+[[columns]]
+name = "continent"
+database = "country"
+path = ["country", "iso_code"]
+country_rollup = "subregion"
+`,
+			expectError: "column 'continent': invalid country_rollup 'subregion', must be one of: continent_code, is_in_european_union, un_region",
+		},
+		{
+			name: "country rollup with time zone",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "country"
+path = "/path/to/country.mmdb"
+
+[[columns]]
+name = "continent"
+database = "country"
+path = ["country", "iso_code"]
+country_rollup = "continent_code"
+time_zone = "dst"
+`,
+			expectError: "column 'continent': country_rollup cannot be combined with tag, prefix_length, kind, or time_zone",
+		},
+		{
+			name: "country rollup with wrong type",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "country"
+path = "/path/to/country.mmdb"
+
+[[columns]]
+name = "continent"
+database = "country"
+path = ["country", "iso_code"]
+country_rollup = "is_in_european_union"
+type = "string"
+`,
+			expectError: "column 'continent': country_rollup 'is_in_european_union' can only have type 'bool'",
+		},
+		{
+			name: "parquet column options for unknown column",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.parquet.columns.region]
+encoding = "plain"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "'region' is not a configured network or data column",
+		},
+		{
+			name: "invalid parquet column encoding",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.parquet.columns.country]
+encoding = "zigzag"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid parquet encoding 'zigzag' for column 'country'",
+		},
+		{
+			name: "parquet dictionary combined with encoding",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.parquet.columns.country]
+dictionary = true
+encoding = "delta_byte_array"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "dictionary = true cannot be combined with encoding",
+		},
+		{
+			name: "invalid parquet column compression",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.parquet.columns.country]
+compression = "brotli"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid parquet compression 'brotli' for column 'country'",
+		},
+		{
+			name: "parquet column options with csv output",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.parquet.columns.country]
+dictionary = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.parquet.columns is only supported for Parquet output",
+		},
+		{
+			name: "quote as csv delimiter",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.csv]
+delimiter = '"'
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid CSV delimiter",
+		},
+		{
+			name: "split mmdb output with base",
+			toml: `
+[output]
+format = "mmdb"
+ipv4_file = "out_ipv4.mmdb"
+ipv6_file = "out_ipv6.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+base = "existing.mmdb"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.mmdb.base is not supported with split IPv4/IPv6 MMDB output",
+		},
+		{
+			name: "split mmdb output with ip_version",
+			toml: `
+[output]
+format = "mmdb"
+ipv4_file = "out_ipv4.mmdb"
+ipv6_file = "out_ipv6.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+ip_version = "auto"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.mmdb.ip_version cannot be used with split IPv4/IPv6 MMDB output",
+		},
+		{
+			name: "negative max_nesting_depth",
+			toml: `
+max_nesting_depth = -1
+
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "max_nesting_depth cannot be negative",
+		},
+		{
+			name: "invalid database mode",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "asn"
+path = "/path/to/asn.mmdb"
+mode = "scan"
+
+[[columns]]
+name = "asn"
+database = "asn"
+path = ["autonomous_system_number"]
+`,
+			expectError: `invalid mode 'scan' for database 'asn', must be one of: iterate, lookup`,
+		},
+		{
+			name: "allow_imprecise_lookup without lookup mode",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "asn"
+path = "/path/to/asn.mmdb"
+allow_imprecise_lookup = true
+
+[[columns]]
+name = "asn"
+database = "asn"
+path = ["autonomous_system_number"]
+`,
+			expectError: `database 'asn': allow_imprecise_lookup requires mode = "lookup"`,
+		},
+		{
+			name: "lookup mode with preload",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "asn"
+path = "/path/to/asn.mmdb"
+mode = "lookup"
+preload = true
+
+[[columns]]
+name = "asn"
+database = "asn"
+path = ["autonomous_system_number"]
+`,
+			expectError: `database 'asn': mode = "lookup" cannot be combined with preload`,
+		},
+		{
+			name: "unknown driver_database",
+			toml: `
+driver_database = "asn"
+
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "overrides"
+path = "/path/to/overrides.mmdb"
+preload = true
+
+[[databases]]
+name = "unused"
+path = "/path/to/unused.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "override"
+database = "overrides"
+path = ["country"]
+`,
+			expectError: "driver_database references unknown database 'asn'",
+		},
+		{
+			name: "preloaded driver_database",
+			toml: `
+driver_database = "overrides"
+
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "overrides"
+path = "/path/to/overrides.mmdb"
+preload = true
+
+[[databases]]
+name = "unused"
+path = "/path/to/unused.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "override"
+database = "overrides"
+path = ["country"]
+`,
+			expectError: "driver_database 'overrides' is preloaded or in lookup mode, so it is looked up rather than iterated",
+		},
+		{
+			name: "unused driver_database",
+			toml: `
+driver_database = "unused"
+
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[databases]]
+name = "overrides"
+path = "/path/to/overrides.mmdb"
+preload = true
+
+[[databases]]
+name = "unused"
+path = "/path/to/unused.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "override"
+database = "overrides"
+path = ["country"]
+`,
+			expectError: "driver_database 'unused' is not used by any column",
+		},
+		{
+			name: "provenance header with parquet output",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[output.csv]
+provenance_header = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.csv.provenance_header is only supported for CSV output",
+		},
+		{
+			name: "history on two databases",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+history = ["/path/to/archive/geo-*.mmdb"]
+
+[[databases]]
+name = "other"
+path = "/path/to/other.mmdb"
+history = ["/path/to/archive/other-*.mmdb"]
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "only one database can have history",
+		},
+		{
+			name: "invalid history pattern",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+history = ["/path/to/[geo.mmdb"]
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid history pattern",
+		},
+		{
+			name: "validity column without history",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[network.columns]]
+name = "valid_from"
+type = "valid_from"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "requires a database with history",
+		},
+		{
+			name: "history with mmdb output",
+			toml: `
+[output]
+format = "mmdb"
+file = "output.mmdb"
+
+[output.mmdb]
+database_type = "Test"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+history = ["/path/to/archive/geo-*.mmdb"]
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "database history is not supported for MMDB output",
+		},
+		{
+			name: "history without valid_from column",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+history = ["/path/to/archive/geo-*.mmdb"]
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "database history requires a network column of type 'valid_from'",
+		},
+		{
+			name: "negative heartbeat interval",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[heartbeat]
+file = "status.json"
+every_seconds = -1
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "heartbeat.every_seconds cannot be negative",
+		},
+		{
+			name: "negative watchdog stall time",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[watchdog]
+stall_seconds = -1
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "watchdog.stall_seconds cannot be negative",
+		},
+		{
+			name: "invalid empty columns action",
+			toml: `
+empty_columns = "abort"
+
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid empty_columns 'abort', must be one of: warn, fail, ignore",
+		},
+		{
+			name: "heartbeat interval without file",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[heartbeat]
+every_seconds = 5
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "heartbeat.every_seconds requires heartbeat.file",
+		},
+		{
+			name: "unknown tag",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+tags = { vendor = "maxmind" }
+
+[[columns]]
+name = "geo_edition"
+database = "geo"
+tag = "edition"
+`,
+			expectError: "column 'geo_edition': database 'geo' has no tag 'edition'",
+		},
+		{
+			name: "tag with path",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+tags = { vendor = "maxmind" }
+
+[[columns]]
+name = "geo_vendor"
+database = "geo"
+tag = "vendor"
+path = ["country", "iso_code"]
+`,
+			expectError: "column 'geo_vendor': tag and path cannot both be set",
+		},
+		{
+			name: "tag with non-string type",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+tags = { vendor = "maxmind" }
+
+[[columns]]
+name = "geo_vendor"
+database = "geo"
+tag = "vendor"
+type = "int64"
+`,
+			expectError: "column 'geo_vendor': tag values can only have type 'string'",
+		},
+		{
+			name: "ptr output without template",
+			toml: `
+[output]
+format = "ptr"
+file = "geo.rev"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.ptr.template is required for PTR output",
+		},
+		{
+			name: "ptr output with network columns",
+			toml: `
+[output]
+format = "ptr"
+file = "geo.rev"
+
+[output.ptr]
+template = "{country}.geo.example."
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "network columns are not supported for PTR output",
+		},
+		{
+			name: "filter on unknown column",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[output.filter]
+is_hosting = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.filter: 'is_hosting' is not a configured data column",
+		},
+		{
+			name: "invalid vcl acl name",
+			toml: `
+[output]
+format = "vcl"
+file = "hosting.vcl"
+
+[output.vcl]
+acl = "hosting-networks"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.vcl.acl 'hosting-networks'",
+		},
+		{
+			name: "invalid envoy encoding",
+			toml: `
+[output]
+format = "envoy"
+file = "hosting.txt"
+
+[output.envoy]
+encoding = "text"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.envoy.encoding 'text', must be one of: yaml, json",
+		},
+		{
+			name: "unknown csv profile",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[output.csv]
+profile = "geoip-city"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.csv.profile 'geoip-city', must be: geoip-legacy",
+		},
+		{
+			name: "geoip legacy profile with one data column",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[output.csv]
+profile = "geoip-legacy"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.csv.profile 'geoip-legacy' requires exactly two data columns, the country code and the country name, got 1",
+		},
+		{
+			name: "geoip legacy profile with cidr column",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[output.csv]
+profile = "geoip-legacy"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country_code"
+database = "geo"
+path = ["country", "iso_code"]
+
+[[columns]]
+name = "country_name"
+database = "geo"
+path = ["country", "names", "en"]
+`,
+			expectError: "output.csv.profile 'geoip-legacy' requires the network columns start_ip, end_ip, start_int, end_int, in that order",
+		},
+		{
+			name: "csv profile with parquet output",
+			toml: `
+[output]
+format = "parquet"
+file = "out.parquet"
+
+[output.csv]
+profile = "geoip-legacy"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.csv.profile is only supported for CSV output",
+		},
+		{
+			name: "unknown edition",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[[databases]]
+name = "isp"
+path = "/path/to/isp.mmdb"
+edition = "GeoIP2-Domain"
+`,
+			expectError: "invalid edition 'GeoIP2-Domain' for database 'isp', must be one of: GeoIP2-Anonymous-IP, GeoIP2-City",
+		},
+		{
+			name: "field without edition",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[[databases]]
+name = "isp"
+path = "/path/to/isp.mmdb"
+
+[[columns]]
+name = "isp"
+database = "isp"
+field = "isp"
+`,
+			expectError: "column 'isp': field requires an edition for database 'isp'",
+		},
+		{
+			name: "unknown edition field",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[[databases]]
+name = "asn"
+path = "/path/to/asn.mmdb"
+edition = "GeoLite2-ASN"
+
+[[columns]]
+name = "isp"
+database = "asn"
+field = "isp"
+`,
+			expectError: "column 'isp': edition GeoLite2-ASN has no field 'isp', must be one of: autonomous_system_number, autonomous_system_organization",
+		},
+		{
+			name: "field and path",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[[databases]]
+name = "isp"
+path = "/path/to/isp.mmdb"
+edition = "GeoIP2-ISP"
+
+[[columns]]
+name = "isp"
+database = "isp"
+field = "isp"
+path = ["organization"]
+`,
+			expectError: "column 'isp': field and path cannot both be set",
+		},
+		{
+			name: "field and tag",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[[databases]]
+name = "isp"
+path = "/path/to/isp.mmdb"
+edition = "GeoIP2-ISP"
+tags = { vendor = "maxmind" }
+
+[[columns]]
+name = "isp"
+database = "isp"
+field = "isp"
+tag = "vendor"
+`,
+			expectError: "column 'isp': field cannot be combined with tag, prefix_length, or kind",
+		},
+		{
+			name: "unknown preset",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[preset]
+name = "metro"
+database = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+`,
+			expectError: "invalid preset.name 'metro', must be one of: city, country, postal, subdivision",
+		},
+		{
+			name: "preset without database",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[preset]
+name = "country"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+`,
+			expectError: "preset.database is required",
+		},
+		{
+			name: "preset with unknown database",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[preset]
+name = "postal"
+database = "city"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+`,
+			expectError: "preset.database references unknown database 'city'",
+		},
+		{
+			name: "preset column configured again",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[preset]
+name = "country"
+database = "geo"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country_name"
+database = "geo"
+path = ["country", "names", "de"]
+`,
+			expectError: "duplicate column name 'country_name'",
+		},
+		{
+			name: "invalid schema mismatch policy",
+			toml: `
+[output]
+format = "parquet"
+file = "dataset"
+
+[output.parquet]
+append = true
+schema_mismatch = "ignore"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "invalid output.parquet.schema_mismatch 'ignore', must be one of: error, cast",
+		},
+		{
+			name: "schema mismatch policy without append",
+			toml: `
+[output]
+format = "parquet"
+file = "out.parquet"
+
+[output.parquet]
+schema_mismatch = "cast"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.parquet.schema_mismatch requires output.parquet.append",
+		},
+		{
+			name: "append with split output",
+			toml: `
+[output]
+format = "parquet"
+ipv4_file = "ipv4"
+ipv6_file = "ipv6"
+
+[output.parquet]
+append = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.parquet.append requires output.file",
+		},
+		{
+			name: "append with csv output",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
+
+[output.parquet]
+append = true
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.parquet.append is only supported for Parquet output",
+		},
+		{
+			name: "retention without append",
+			toml: `
+[output]
+format = "parquet"
+file = "out.parquet"
+
+[output.retention]
+keep_last = 7
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.retention requires output.parquet.append, as only dataset parts accumulate",
+		},
+		{
+			name: "negative retention age",
+			toml: `
+[output]
+format = "parquet"
+file = "dataset"
+
+[output.parquet]
+append = true
+
+[output.retention]
+max_age_days = -1
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.retention.max_age_days cannot be negative",
+		},
+		{
+			name: "max file size with parquet output",
+			toml: `
+[output]
+format = "parquet"
+file = "out.parquet"
+
+[output.csv]
+max_file_size = 1000000
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.csv.max_file_size is only supported for CSV output",
+		},
+		{
+			name: "negative max file size",
+			toml: `
 [output]
 format = "csv"
-file = "combined.csv"
-ipv4_file = "v4.csv"
+file = "out.csv"
+
+[output.csv]
+max_file_size = -1
 
 [[databases]]
 name = "geo"
@@ -261,23 +5061,40 @@ path = "/path/to/geo.mmdb"
 name = "country"
 database = "geo"
 path = ["country", "iso_code"]
-`
+`,
+			expectError: "output.csv.max_file_size cannot be negative",
+		},
+		{
+			name: "invalid ignore_errors network",
+			toml: `
+[output]
+format = "csv"
+file = "out.csv"
 
-	tmp := t.TempDir()
-	path := filepath.Join(tmp, "config.toml")
-	require.NoError(t, os.WriteFile(path, []byte(toml), 0o644))
+[[output.ignore_errors]]
+network = "1.2.3.4/33"
+reason = "broken vendor record"
 
-	_, err := LoadConfig(path)
-	if err == nil || !strings.Contains(err.Error(), "cannot be used together") {
-		t.Fatalf("expected error about mutually exclusive files, got %v", err)
-	}
-}
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
 
-func TestLoadConfig_InvalidPartialSplit(t *testing.T) {
-	const toml = `
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+`,
+			expectError: "output.ignore_errors[0]: invalid network '1.2.3.4/33'",
+		},
+		{
+			name: "ignore_errors without reason",
+			toml: `
 [output]
 format = "csv"
-ipv4_file = "v4.csv"
+file = "out.csv"
+
+[[output.ignore_errors]]
+network = "1.2.3.0/24"
 
 [[databases]]
 name = "geo"
@@ -287,28 +5104,52 @@ path = "/path/to/geo.mmdb"
 name = "country"
 database = "geo"
 path = ["country", "iso_code"]
-`
+`,
+			expectError: "output.ignore_errors[0]: reason is required",
+		},
+		{
+			name: "prefix_length with path",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
 
-	tmp := t.TempDir()
-	path := filepath.Join(tmp, "config.toml")
-	require.NoError(t, os.WriteFile(path, []byte(toml), 0o644))
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
 
-	_, err := LoadConfig(path)
-	if err == nil || !strings.Contains(err.Error(), "either output.file must be set") {
-		t.Fatalf("expected error about providing both ipv4 and ipv6 files, got %v", err)
-	}
-}
+[[columns]]
+name = "geo_prefix_length"
+database = "geo"
+prefix_length = true
+path = ["country", "iso_code"]
+`,
+			expectError: "column 'geo_prefix_length': prefix_length cannot be combined with path or tag",
+		},
+		{
+			name: "prefix_length with bool type",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
 
-func TestLoadConfig_Invalid(t *testing.T) {
-	tests := []struct {
-		name        string
-		toml        string
-		expectError string
-	}{
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "geo_prefix_length"
+database = "geo"
+prefix_length = true
+type = "bool"
+`,
+			expectError: "column 'geo_prefix_length': prefix lengths can only have type 'int64' or 'string'",
+		},
 		{
-			name: "missing output format",
+			name: "additional output with an unknown column",
 			toml: `
 [output]
+format = "csv"
 file = "output.csv"
 
 [[databases]]
@@ -319,15 +5160,20 @@ path = "/path/to/geo.mmdb"
 name = "country"
 database = "geo"
 path = ["country", "iso_code"]
+
+[[outputs]]
+format = "csv"
+file = "edge.csv"
+columns = ["city"]
 `,
-			expectError: "output.format is required",
+			expectError: "outputs[0]: column 'city' is not a configured data column",
 		},
 		{
-			name: "invalid output format",
+			name: "additional output with an unknown network column",
 			toml: `
 [output]
-format = "json"
-file = "output.json"
+format = "csv"
+file = "output.csv"
 
 [[databases]]
 name = "geo"
@@ -337,14 +5183,20 @@ path = "/path/to/geo.mmdb"
 name = "country"
 database = "geo"
 path = ["country", "iso_code"]
+
+[[outputs]]
+format = "csv"
+file = "edge.csv"
+network_columns = ["start_ip"]
 `,
-			expectError: "output.format must be 'csv', 'parquet', or 'mmdb'",
+			expectError: "outputs[0]: network column 'start_ip' is not a configured network column",
 		},
 		{
-			name: "missing output file",
+			name: "additional output writing the file of another output",
 			toml: `
 [output]
 format = "csv"
+file = "output.csv"
 
 [[databases]]
 name = "geo"
@@ -354,25 +5206,41 @@ path = "/path/to/geo.mmdb"
 name = "country"
 database = "geo"
 path = ["country", "iso_code"]
+
+[[outputs]]
+format = "csv"
+file = "edge.csv"
+
+[[outputs]]
+format = "parquet"
+file = "output.csv"
 `,
-			expectError: "either output.file must be set or both output.ipv4_file and output.ipv6_file must be provided",
+			expectError: "outputs[1]: 'output.csv' is also written by another output",
 		},
 		{
-			name: "no databases",
+			name: "invalid additional output",
 			toml: `
 [output]
 format = "csv"
 file = "output.csv"
 
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
 [[columns]]
 name = "country"
 database = "geo"
 path = ["country", "iso_code"]
+
+[[outputs]]
+format = "mmdb"
+file = "out.mmdb"
 `,
-			expectError: "at least one database is required",
+			expectError: "outputs[0]: output.mmdb.database_type is required",
 		},
 		{
-			name: "duplicate database names",
+			name: "group for csv output",
 			toml: `
 [output]
 format = "csv"
@@ -380,55 +5248,83 @@ file = "output.csv"
 
 [[databases]]
 name = "geo"
-path = "/path/to/geo1.mmdb"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "latitude"
+database = "geo"
+path = ["location", "latitude"]
+group = "location"
+`,
+			expectError: "column 'latitude': group not supported for csv output (only for parquet)",
+		},
+		{
+			name: "group with the name of a column",
+			toml: `
+[output]
+format = "parquet"
+file = "output.parquet"
 
 [[databases]]
 name = "geo"
-path = "/path/to/geo2.mmdb"
+path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
+name = "location"
 database = "geo"
-path = ["country", "iso_code"]
+path = ["location", "time_zone"]
+
+[[columns]]
+name = "latitude"
+database = "geo"
+path = ["location", "latitude"]
+group = "location"
 `,
-			expectError: "duplicate database name 'geo'",
+			expectError: "group name 'location' is already used as a column name",
 		},
 		{
-			name: "missing database name",
+			name: "group with the name of a network column",
 			toml: `
 [output]
-format = "csv"
-file = "output.csv"
+format = "parquet"
+file = "output.parquet"
+
+[[network.columns]]
+name = "network"
+type = "cidr"
 
 [[databases]]
+name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
+name = "latitude"
 database = "geo"
-path = ["country", "iso_code"]
+path = ["location", "latitude"]
+group = "network"
 `,
-			expectError: "database name is required",
+			expectError: "group name 'network' is already used as a column name",
 		},
 		{
-			name: "missing database path",
+			name: "parquet column name with a dot",
 			toml: `
 [output]
-format = "csv"
-file = "output.csv"
+format = "parquet"
+file = "output.parquet"
 
 [[databases]]
 name = "geo"
+path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
+name = "country.iso_code"
 database = "geo"
 path = ["country", "iso_code"]
 `,
-			expectError: "database path is required",
+			expectError: "column name 'country.iso_code' contains '.', which is not allowed in Parquet output",
 		},
 		{
-			name: "unknown database reference",
+			name: "column name with a line break",
 			toml: `
 [output]
 format = "csv"
@@ -439,37 +5335,44 @@ name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
-database = "unknown"
+name = "country\ncode"
+database = "geo"
 path = ["country", "iso_code"]
 `,
-			expectError: "references unknown database 'unknown'",
+			expectError: `column name "country\ncode" contains control characters`,
 		},
 		{
-			name: "duplicate column names",
+			name: "every invalid column name listed",
 			toml: `
 [output]
-format = "csv"
-file = "output.csv"
+format = "parquet"
+file = "output.parquet"
 
 [[databases]]
 name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
+name = "start_int"
 database = "geo"
 path = ["country", "iso_code"]
 
 [[columns]]
-name = "country"
+name = "city.name"
 database = "geo"
-path = ["country", "name"]
+path = ["city", "names", "en"]
+
+[[columns]]
+name = "city.name"
+database = "geo"
+path = ["city", "names", "de"]
 `,
-			expectError: "duplicate column name 'country'",
+			expectError: "3 invalid column names: duplicate column name 'start_int' (already used as network column); " +
+				"column name 'city.name' contains '.', which is not allowed in Parquet output; " +
+				"duplicate column name 'city.name'",
 		},
 		{
-			name: "missing column name",
+			name: "unknown transliterate locale",
 			toml: `
 [output]
 format = "csv"
@@ -480,13 +5383,15 @@ name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
+name = "city"
 database = "geo"
-path = ["country", "iso_code"]
+path = ["city", "names", "en"]
+transliterate = "xx"
 `,
-			expectError: "column name is required",
+			expectError: "column 'city': invalid transliterate locale 'xx', must be one of: ascii, da, de, nb, nn, no, uk",
 		},
 		{
-			name: "missing column database",
+			name: "undefined transform",
 			toml: `
 [output]
 format = "csv"
@@ -497,41 +5402,45 @@ name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
-path = ["country", "iso_code"]
+name = "city"
+database = "geo"
+path = ["city", "names", "en"]
+transform = "clean_name"
 `,
-			expectError: "column database is required",
+			expectError: "column 'city': unknown transform 'clean_name', not defined in [transforms]",
 		},
-		// Note: missing column path is now valid - path = [] means "copy entire record"
 		{
-			name: "invalid network column type",
+			name: "unknown transform step",
 			toml: `
 [output]
 format = "csv"
 file = "output.csv"
 
-[[network.columns]]
-name = "network"
-type = "invalid"
+[transforms.clean_name]
+steps = ["trim", "snake_case"]
 
 [[databases]]
 name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
+name = "city"
 database = "geo"
-path = ["country", "iso_code"]
+path = ["city", "names", "en"]
 `,
-			expectError: "invalid network column type 'invalid'",
+			expectError: "transforms.clean_name: unknown step 'snake_case', must be one of: collapse_ws, country_alpha2, country_alpha3, country_numeric, lower, org_name, title_case, trim, upper",
 		},
 		{
-			name: "invalid data column type",
+			name: "country aliases without country step",
 			toml: `
 [output]
 format = "csv"
 file = "output.csv"
 
+[transforms.country]
+steps = ["upper"]
+country_aliases = { UK = "GB" }
+
 [[databases]]
 name = "geo"
 path = "/path/to/geo.mmdb"
@@ -540,78 +5449,125 @@ path = "/path/to/geo.mmdb"
 name = "country"
 database = "geo"
 path = ["country", "iso_code"]
-type = "invalid"
+transform = "country"
 `,
-			expectError: "type hints not supported for csv output",
+			expectError: "transforms.country: country_aliases and unknown_countries require one of the steps",
 		},
 		{
-			name: "invalid parquet compression",
+			name: "org_names_file without org_name step",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[transforms.org]
+steps = ["trim"]
+org_names_file = "org_names.csv"
+
+[[databases]]
+name = "asn"
+path = "/path/to/asn.mmdb"
+
+[[columns]]
+name = "org"
+database = "asn"
+path = ["autonomous_system_organization"]
+transform = "org"
+`,
+			expectError: "transforms.org: org_names_file requires the step: org_name",
+		},
+		{
+			name: "unknown column kind",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
+
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+name = "flags"
+kind = "booleans"
+database = "anon"
+`,
+			expectError: "invalid kind 'booleans' for column 'flags', must be one of: traits_booleans",
+		},
+		{
+			name: "traits_booleans with non-bool type",
 			toml: `
 [output]
 format = "parquet"
 file = "output.parquet"
 
-[output.parquet]
-compression = "invalid"
+[[databases]]
+name = "anon"
+path = "/path/to/anon.mmdb"
+
+[[columns]]
+kind = "traits_booleans"
+database = "anon"
+type = "int64"
+`,
+			expectError: "column 'is_anonymous': traits_booleans columns can only have type 'bool'",
+		},
+		{
+			name: "unknown redact policy",
+			toml: `
+[output]
+format = "csv"
+file = "output.csv"
 
 [[databases]]
 name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
+name = "postal_code"
 database = "geo"
-path = ["country", "iso_code"]
+path = ["postal", "code"]
+redact = { policy = "mask" }
 `,
-			expectError: "invalid parquet compression 'invalid'",
+			expectError: "invalid redact policy 'mask' for column 'postal_code', must be one of: drop, hash, truncate",
 		},
 		{
-			name: "duplicate network column names",
+			name: "redact hash without salt",
 			toml: `
 [output]
 format = "csv"
 file = "output.csv"
 
-[[network.columns]]
-name = "network"
-type = "cidr"
-
-[[network.columns]]
-name = "network"
-type = "start_ip"
-
 [[databases]]
 name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "country"
+name = "postal_code"
 database = "geo"
-path = ["country", "iso_code"]
+path = ["postal", "code"]
+redact = { policy = "hash" }
 `,
-			expectError: "duplicate network column name 'network'",
+			expectError: "column 'postal_code': redact policy 'hash' requires exactly one of salt or salt_env",
 		},
 		{
-			name: "column name conflicts with network column",
+			name: "redact truncate without decimals",
 			toml: `
 [output]
 format = "csv"
 file = "output.csv"
 
-[[network.columns]]
-name = "network"
-type = "cidr"
-
 [[databases]]
 name = "geo"
 path = "/path/to/geo.mmdb"
 
 [[columns]]
-name = "network"
+name = "latitude"
 database = "geo"
-path = ["country", "iso_code"]
+path = ["location", "latitude"]
+redact = { policy = "truncate" }
 `,
-			expectError: "duplicate column name 'network' (already used as network column)",
+			expectError: "column 'latitude': redact policy 'truncate' requires decimals of 0 or more",
 		},
 	}
 
@@ -750,3 +5706,24 @@ func assertPathEquals(t *testing.T, path Path, expected ...any) {
 		t.Fatalf("expected path %v, got %v", expected, path.Segments())
 	}
 }
+
+func TestConfig_CaseCollisions(t *testing.T) {
+	cfg := &Config{
+		Network: NetworkConfig{Columns: []NetworkColumn{{Name: "Network", Type: "cidr"}}},
+		Columns: []Column{
+			{Name: "network"},
+			{Name: "country"},
+			{Name: "City"},
+			{Name: "CITY"},
+			{Name: "city"},
+		},
+	}
+	require.Equal(
+		t,
+		[][]mmdbtype.String{{"Network", "network"}, {"City", "CITY", "city"}},
+		cfg.CaseCollisions(),
+	)
+
+	cfg.Columns = cfg.Columns[1:2]
+	require.Empty(t, cfg.CaseCollisions())
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// TenantPlaceholder is replaced by the tenant name in the output file names
+// of a base configuration.
+const TenantPlaceholder = "{tenant}"
+
+// tenantKeys are the keys a tenant overlay may set. Tenants share the merge
+// of the base configuration, so only their output settings differ.
+var tenantKeys = []string{"name", "redact", "output", "outputs"}
+
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Tenant is the configuration of one tenant's exports: a base configuration
+// with the tenant's overlay applied.
+type Tenant struct {
+	Name   string
+	Redact *bool // Whether to apply the redact policies, if the overlay sets it
+	Config *Config
+}
+
+// LoadTenant loads the base configuration at basePath with the tenant
+// overlay at overlayPath applied. The [output] table of the overlay is
+// merged into that of the base, key by key and recursively; its [[outputs]]
+// replace those of the base. The tenant name, which defaults to the overlay
+// file name without its extension, replaces {tenant} in output file names.
+func LoadTenant(basePath, overlayPath string) (*Tenant, error) {
+	base, err := readTable(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	overlay, err := readTable(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading tenant overlay: %w", err)
+	}
+
+	tenant := &Tenant{
+		Name: strings.TrimSuffix(filepath.Base(overlayPath), filepath.Ext(overlayPath)),
+	}
+	for _, key := range slices.Sorted(maps.Keys(overlay)) {
+		value := overlay[key]
+		var ok bool
+		switch key {
+		case "name":
+			tenant.Name, ok = value.(string)
+		case "redact":
+			var redact bool
+			if redact, ok = value.(bool); ok {
+				tenant.Redact = &redact
+			}
+		case "output":
+			var output map[string]any
+			if output, ok = value.(map[string]any); ok {
+				baseOutput, _ := base["output"].(map[string]any)
+				base["output"] = mergeTables(baseOutput, output)
+			}
+		case "outputs":
+			base["outputs"], ok = value, true
+		default:
+			return nil, fmt.Errorf(
+				"tenant overlay %s: '%s' cannot be set, only %s; tenants share the merge of the base configuration",
+				overlayPath,
+				key,
+				strings.Join(tenantKeys, ", "),
+			)
+		}
+		if !ok {
+			return nil, fmt.Errorf("tenant overlay %s: invalid value for '%s'", overlayPath, key)
+		}
+	}
+	if !tenantName.MatchString(tenant.Name) {
+		return nil, fmt.Errorf(
+			"tenant overlay %s: tenant name '%s' must consist of letters, digits, '.', '_', and '-'",
+			overlayPath,
+			tenant.Name,
+		)
+	}
+
+	replaceTenant(base["output"], tenant.Name)
+	if outputs, ok := base["outputs"].([]any); ok {
+		for _, out := range outputs {
+			replaceTenant(out, tenant.Name)
+		}
+	}
+
+	data, err := toml.Marshal(base)
+	if err != nil {
+		return nil, fmt.Errorf("tenant '%s': encoding configuration: %w", tenant.Name, err)
+	}
+	tenant.Config, err = parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("tenant '%s': %w", tenant.Name, err)
+	}
+	return tenant, nil
+}
+
+// readTable reads the TOML document at path as a table.
+func readTable(path string) (map[string]any, error) {
+	// #nosec G304 -- path is a user-provided config file path, which is intentional
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var table map[string]any
+	if err := toml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("parsing TOML: %w", err)
+	}
+	if table == nil {
+		table = map[string]any{}
+	}
+	return table, nil
+}
+
+// mergeTables returns base with the keys of overlay set over it. Tables in
+// both are merged recursively; any other value of overlay replaces that of
+// base.
+func mergeTables(base, overlay map[string]any) map[string]any {
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]any, len(overlay))
+	}
+	for key, value := range overlay {
+		baseTable, baseOK := merged[key].(map[string]any)
+		table, ok := value.(map[string]any)
+		if baseOK && ok {
+			merged[key] = mergeTables(baseTable, table)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// replaceTenant replaces the tenant placeholder in the file names of an
+// output table.
+func replaceTenant(output any, name string) {
+	table, ok := output.(map[string]any)
+	if !ok {
+		return
+	}
+	for _, key := range []string{"file", "ipv4_file", "ipv6_file"} {
+		if file, ok := table[key].(string); ok {
+			table[key] = strings.ReplaceAll(file, TenantPlaceholder, name)
+		}
+	}
+}
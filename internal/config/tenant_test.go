@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const tenantBase = `
+[output]
+format = "csv"
+file = "exports/{tenant}.csv"
+
+[output.filter]
+is_hosting = false
+
+[[outputs]]
+format = "mmdb"
+file = "exports/{tenant}.mmdb"
+
+[outputs.mmdb]
+database_type = "Tenant-Export"
+
+[[databases]]
+name = "geo"
+path = "/path/to/geo.mmdb"
+
+[[columns]]
+name = "country"
+database = "geo"
+path = ["country", "iso_code"]
+redact = { policy = "drop" }
+
+[[columns]]
+name = "is_hosting"
+database = "geo"
+path = ["traits", "is_hosting"]
+`
+
+// writeTenantFiles writes the base configuration and an overlay named file
+// and returns their paths.
+func writeTenantFiles(t *testing.T, file, overlay string) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base.toml")
+	overlayPath := filepath.Join(dir, file)
+	require.NoError(t, os.WriteFile(basePath, []byte(tenantBase), 0o600))
+	require.NoError(t, os.WriteFile(overlayPath, []byte(overlay), 0o600))
+	return basePath, overlayPath
+}
+
+func TestLoadTenant(t *testing.T) {
+	basePath, overlayPath := writeTenantFiles(t, "acme.toml", `
+redact = true
+
+[output]
+format = "parquet"
+
+[output.filter]
+country = "US"
+`)
+
+	tenant, err := LoadTenant(basePath, overlayPath)
+	require.NoError(t, err)
+	require.Equal(t, "acme", tenant.Name)
+	require.NotNil(t, tenant.Redact)
+	require.True(t, *tenant.Redact)
+
+	cfg := tenant.Config
+	require.Equal(t, "parquet", cfg.Output.Format)
+	require.Equal(t, "exports/acme.csv", cfg.Output.File)
+	// Tables are merged, so the tenant filter adds to that of the base
+	require.Equal(t, map[string]any{"is_hosting": false, "country": "US"}, cfg.Output.Filter)
+	// Defaults are those of the tenant's format
+	require.Equal(t, "start_int", cfg.Network.Columns[0].Type)
+	require.Equal(t, []string{"exports/acme.csv", "exports/acme.mmdb"}, cfg.OutputFiles())
+	require.Len(t, cfg.Columns, 2)
+}
+
+func TestLoadTenant_Outputs(t *testing.T) {
+	basePath, overlayPath := writeTenantFiles(t, "tenant.toml", `
+name = "globex"
+
+[[outputs]]
+format = "csv"
+file = "exports/{tenant}-countries.csv"
+columns = ["country"]
+`)
+
+	tenant, err := LoadTenant(basePath, overlayPath)
+	require.NoError(t, err)
+	require.Equal(t, "globex", tenant.Name)
+	require.Nil(t, tenant.Redact)
+	// The [[outputs]] of the overlay replace those of the base
+	require.Equal(
+		t,
+		[]string{"exports/globex.csv", "exports/globex-countries.csv"},
+		tenant.Config.OutputFiles(),
+	)
+}
+
+func TestLoadTenant_EmptyOverlay(t *testing.T) {
+	basePath, overlayPath := writeTenantFiles(t, "initech.toml", "")
+
+	tenant, err := LoadTenant(basePath, overlayPath)
+	require.NoError(t, err)
+	require.Equal(t, "initech", tenant.Name)
+	require.Equal(t, "exports/initech.csv", tenant.Config.Output.File)
+	require.Equal(t, map[string]any{"is_hosting": false}, tenant.Config.Output.Filter)
+}
+
+func TestLoadTenant_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		overlay     string
+		expectError string
+	}{
+		{
+			name:        "merge setting",
+			file:        "acme.toml",
+			overlay:     "[[columns]]\nname = \"city\"\n",
+			expectError: "'columns' cannot be set, only name, redact, output, outputs",
+		},
+		{
+			name:        "invalid name",
+			file:        "acme.toml",
+			overlay:     `name = "../acme"`,
+			expectError: "tenant name '../acme' must consist of letters, digits",
+		},
+		{
+			name:        "redact not a boolean",
+			file:        "acme.toml",
+			overlay:     `redact = "yes"`,
+			expectError: "invalid value for 'redact'",
+		},
+		{
+			name:        "invalid merged output",
+			file:        "acme.toml",
+			overlay:     "[output]\nformat = \"xml\"\n",
+			expectError: "tenant 'acme': invalid configuration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basePath, overlayPath := writeTenantFiles(t, tt.file, tt.overlay)
+			_, err := LoadTenant(basePath, overlayPath)
+			require.ErrorContains(t, err, tt.expectError)
+		})
+	}
+}
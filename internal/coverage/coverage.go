@@ -0,0 +1,323 @@
+// Package coverage computes how much of the address space each configured
+// column and database populates in a merged export.
+package coverage
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"slices"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// Family identifies an IP address family in a coverage report.
+type Family string
+
+// Address families reported by the collector.
+const (
+	FamilyIPv4 Family = "ipv4"
+	FamilyIPv6 Family = "ipv6"
+)
+
+// Counts holds the number of addresses and emitted rows with non-null data.
+type Counts struct {
+	Addresses *big.Int
+	Rows      uint64
+}
+
+func newCounts() Counts {
+	return Counts{Addresses: new(big.Int)}
+}
+
+func (c *Counts) add(addresses *big.Int) {
+	c.Addresses.Add(c.Addresses, addresses)
+	c.Rows++
+}
+
+// FamilyStats accumulates coverage for one address family, optionally
+// restricted to a single group (e.g., one country).
+type FamilyStats struct {
+	// Total counts all emitted rows and the addresses they cover.
+	Total Counts
+	// Columns holds per-column counts ordered by config.Columns.
+	Columns []Counts
+	// Databases holds per-database counts ordered by Collector.Databases.
+	Databases []Counts
+}
+
+func newFamilyStats(columns, databases int) *FamilyStats {
+	s := &FamilyStats{
+		Total:     newCounts(),
+		Columns:   make([]Counts, columns),
+		Databases: make([]Counts, databases),
+	}
+	for i := range s.Columns {
+		s.Columns[i] = newCounts()
+	}
+	for i := range s.Databases {
+		s.Databases[i] = newCounts()
+	}
+	return s
+}
+
+// Collector implements row.RangeWriter, tallying
+// non-null values instead of writing output.
+type Collector struct {
+	// Databases lists the database names in the order used by
+	// FamilyStats.Databases.
+	Databases []string
+
+	config      *config.Config
+	columnDB    []int // column index -> index in Databases
+	groupColumn int   // column index used for grouping, or -1
+	rangeRows   bool  // count ranges rather than CIDRs as rows
+
+	families map[Family]*FamilyStats
+	groups   map[Family]map[string]*FamilyStats
+}
+
+// NewCollector creates a collector for the given configuration. groupBy
+// optionally names a data column whose value is used to break down the
+// report (e.g., a country code column); pass "" to disable grouping. When
+// rangeRows is true, each accumulated range counts as one row, matching
+// writers that emit start/end ranges instead of CIDRs.
+func NewCollector(cfg *config.Config, groupBy string, rangeRows bool) (*Collector, error) {
+	c := &Collector{
+		config:      cfg,
+		columnDB:    make([]int, len(cfg.Columns)),
+		groupColumn: -1,
+		rangeRows:   rangeRows,
+		families:    map[Family]*FamilyStats{},
+		groups:      map[Family]map[string]*FamilyStats{},
+	}
+
+	for i, col := range cfg.Columns {
+		if groupBy != "" && string(col.Name) == groupBy {
+			c.groupColumn = i
+		}
+
+		// Score columns are computed, and count towards no database
+		if col.Kind == config.ColumnKindScore {
+			c.columnDB[i] = -1
+			continue
+		}
+		idx := slices.Index(c.Databases, col.Database)
+		if idx < 0 {
+			idx = len(c.Databases)
+			c.Databases = append(c.Databases, col.Database)
+		}
+		c.columnDB[i] = idx
+	}
+
+	if groupBy != "" && c.groupColumn < 0 {
+		return nil, fmt.Errorf("group-by column '%s' is not a configured data column", groupBy)
+	}
+
+	return c, nil
+}
+
+// WriteRow records a single prefix.
+func (c *Collector) WriteRow(prefix netip.Prefix, data row.Row) error {
+	c.record(prefix.Addr().Is4(), network.PrefixSize(prefix), data)
+	return nil
+}
+
+// WriteRange records a range. When the collector counts CIDR rows, the range
+// is split into prefixes so that row counts match prefix-based writers.
+func (c *Collector) WriteRange(start, end netip.Addr, data row.Row) error {
+	if c.rangeRows {
+		c.record(start.Is4(), network.RangeSize(start, end), data)
+		return nil
+	}
+	for _, prefix := range netipx.IPRangeFrom(start, end).Prefixes() {
+		c.record(prefix.Addr().Is4(), network.PrefixSize(prefix), data)
+	}
+	return nil
+}
+
+func (c *Collector) record(is4 bool, addresses *big.Int, data row.Row) {
+	family := FamilyIPv6
+	if is4 {
+		family = FamilyIPv4
+	}
+
+	c.tally(c.familyStats(family), addresses, data)
+
+	if c.groupColumn >= 0 {
+		// Text only fails for values that cannot be marshaled to JSON,
+		// which the MMDB reader does not produce
+		key, _ := data.Text(c.groupColumn)
+		c.tally(c.groupStats(family, key), addresses, data)
+	}
+}
+
+func (c *Collector) tally(stats *FamilyStats, addresses *big.Int, data row.Row) {
+	stats.Total.add(addresses)
+
+	seen := make([]bool, len(c.Databases))
+	for i := range data {
+		if data.IsNull(i) {
+			continue
+		}
+		stats.Columns[i].add(addresses)
+		if db := c.columnDB[i]; db >= 0 {
+			seen[db] = true
+		}
+	}
+	for i, ok := range seen {
+		if ok {
+			stats.Databases[i].add(addresses)
+		}
+	}
+}
+
+func (c *Collector) familyStats(family Family) *FamilyStats {
+	s, ok := c.families[family]
+	if !ok {
+		s = newFamilyStats(len(c.config.Columns), len(c.Databases))
+		c.families[family] = s
+	}
+	return s
+}
+
+func (c *Collector) groupStats(family Family, key string) *FamilyStats {
+	byKey, ok := c.groups[family]
+	if !ok {
+		byKey = map[string]*FamilyStats{}
+		c.groups[family] = byKey
+	}
+	s, ok := byKey[key]
+	if !ok {
+		s = newFamilyStats(len(c.config.Columns), len(c.Databases))
+		byKey[key] = s
+	}
+	return s
+}
+
+// Family returns the accumulated statistics for an address family, or nil
+// if no rows of that family were seen.
+func (c *Collector) Family(family Family) *FamilyStats {
+	return c.families[family]
+}
+
+// Groups returns the per-group statistics for an address family keyed by
+// the grouping column's value. Rows where the grouping column is null are
+// reported under the empty key.
+func (c *Collector) Groups(family Family) map[string]*FamilyStats {
+	return c.groups[family]
+}
+
+// Report is a serializable summary of the statistics gathered by a Collector.
+type Report struct {
+	Families []FamilyReport `json:"families"`
+}
+
+// FamilyReport summarizes coverage for one address family, or for one group
+// within a family when Group is set.
+type FamilyReport struct {
+	Family          Family         `json:"family"`
+	Group           *string        `json:"group,omitempty"`
+	Rows            uint64         `json:"rows"`
+	AddressFraction float64        `json:"address_fraction"`
+	Databases       []Entry        `json:"databases"`
+	Columns         []Entry        `json:"columns"`
+	Groups          []FamilyReport `json:"groups,omitempty"`
+}
+
+// Entry reports the share of addresses and rows with non-null data for a
+// single database or column. Fractions of addresses are relative to the
+// whole address family; fractions of rows are relative to emitted rows.
+type Entry struct {
+	Name            string  `json:"name"`
+	Database        string  `json:"database,omitempty"`
+	Rows            uint64  `json:"rows"`
+	AddressFraction float64 `json:"address_fraction"`
+	RowFraction     float64 `json:"row_fraction"`
+}
+
+// Report builds a Report from the collected statistics. Families are listed
+// IPv4 first and groups are sorted by key.
+func (c *Collector) Report() Report {
+	var r Report
+	for _, family := range []Family{FamilyIPv4, FamilyIPv6} {
+		stats := c.families[family]
+		if stats == nil {
+			continue
+		}
+		fr := c.familyReport(family, stats)
+
+		groups := c.groups[family]
+		keys := make([]string, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			gr := c.familyReport(family, groups[key])
+			gr.Group = &key
+			fr.Groups = append(fr.Groups, gr)
+		}
+
+		r.Families = append(r.Families, fr)
+	}
+	return r
+}
+
+func (c *Collector) familyReport(family Family, stats *FamilyStats) FamilyReport {
+	space := AddressSpace(family)
+	fr := FamilyReport{
+		Family:          family,
+		Rows:            stats.Total.Rows,
+		AddressFraction: Fraction(stats.Total.Addresses, space),
+	}
+	for i, name := range c.Databases {
+		fr.Databases = append(fr.Databases, entry(name, "", stats.Databases[i], stats, space))
+	}
+	for i, col := range c.config.Columns {
+		fr.Columns = append(
+			fr.Columns,
+			entry(string(col.Name), col.Database, stats.Columns[i], stats, space),
+		)
+	}
+	return fr
+}
+
+func entry(name, database string, counts Counts, stats *FamilyStats, space *big.Int) Entry {
+	return Entry{
+		Name:            name,
+		Database:        database,
+		Rows:            counts.Rows,
+		AddressFraction: Fraction(counts.Addresses, space),
+		RowFraction:     RowFraction(counts.Rows, stats.Total.Rows),
+	}
+}
+
+// AddressSpace returns the total number of addresses in a family.
+func AddressSpace(family Family) *big.Int {
+	if family == FamilyIPv4 {
+		return new(big.Int).Lsh(big.NewInt(1), 32)
+	}
+	return new(big.Int).Lsh(big.NewInt(1), 128)
+}
+
+// Fraction returns num/den as a float64, or 0 when den is zero.
+func Fraction(num, den *big.Int) float64 {
+	if den.Sign() == 0 {
+		return 0
+	}
+	f, _ := new(big.Rat).SetFrac(num, den).Float64()
+	return f
+}
+
+// RowFraction returns num/den as a float64, or 0 when den is zero.
+func RowFraction(num, den uint64) float64 {
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
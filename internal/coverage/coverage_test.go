@@ -0,0 +1,126 @@
+package coverage
+
+import (
+	"math/big"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Columns: []config.Column{
+			{Name: "country", Database: "city"},
+			{Name: "city", Database: "city"},
+			{Name: "is_anonymous", Database: "anon"},
+		},
+	}
+}
+
+func TestCollector_CountsPerColumnAndDatabase(t *testing.T) {
+	c, err := NewCollector(testConfig(), "", false)
+	require.NoError(t, err)
+
+	require.NoError(t, c.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		[]mmdbtype.DataType{mmdbtype.String("US"), mmdbtype.String("Boston"), nil},
+	))
+	require.NoError(t, c.WriteRow(
+		netip.MustParsePrefix("2.0.0.0/24"),
+		[]mmdbtype.DataType{mmdbtype.String("DE"), nil, mmdbtype.Bool(true)},
+	))
+	require.NoError(t, c.WriteRow(
+		netip.MustParsePrefix("2001:db8::/32"),
+		[]mmdbtype.DataType{nil, nil, mmdbtype.Bool(true)},
+	))
+
+	v4 := c.Family(FamilyIPv4)
+	require.NotNil(t, v4)
+	assert.Equal(t, uint64(2), v4.Total.Rows)
+	assert.Equal(t, big.NewInt(512), v4.Total.Addresses)
+	assert.Equal(t, uint64(2), v4.Columns[0].Rows)
+	assert.Equal(t, uint64(1), v4.Columns[1].Rows)
+	assert.Equal(t, uint64(1), v4.Columns[2].Rows)
+
+	assert.Equal(t, []string{"city", "anon"}, c.Databases)
+	assert.Equal(t, uint64(2), v4.Databases[0].Rows)
+	assert.Equal(t, uint64(1), v4.Databases[1].Rows)
+
+	v6 := c.Family(FamilyIPv6)
+	require.NotNil(t, v6)
+	assert.Equal(t, uint64(1), v6.Total.Rows)
+	assert.Equal(t, uint64(0), v6.Databases[0].Rows)
+	assert.Equal(t, uint64(1), v6.Databases[1].Rows)
+
+	report := c.Report()
+	require.Len(t, report.Families, 2)
+	assert.Equal(t, FamilyIPv4, report.Families[0].Family)
+	assert.InDelta(t, 512.0/(1<<32), report.Families[0].AddressFraction, 1e-15)
+	assert.InDelta(t, 0.5, report.Families[0].Columns[1].RowFraction, 1e-9)
+}
+
+func TestCollector_WriteRange(t *testing.T) {
+	data := []mmdbtype.DataType{mmdbtype.String("US"), nil, nil}
+	start := netip.MustParseAddr("1.0.0.0")
+	end := netip.MustParseAddr("1.0.2.255")
+
+	t.Run("counts CIDR rows", func(t *testing.T) {
+		c, err := NewCollector(testConfig(), "", false)
+		require.NoError(t, err)
+		require.NoError(t, c.WriteRange(start, end, data))
+
+		v4 := c.Family(FamilyIPv4)
+		assert.Equal(t, uint64(2), v4.Total.Rows) // 1.0.0.0/23 + 1.0.2.0/24
+		assert.Equal(t, big.NewInt(768), v4.Total.Addresses)
+	})
+
+	t.Run("counts range rows", func(t *testing.T) {
+		c, err := NewCollector(testConfig(), "", true)
+		require.NoError(t, err)
+		require.NoError(t, c.WriteRange(start, end, data))
+
+		v4 := c.Family(FamilyIPv4)
+		assert.Equal(t, uint64(1), v4.Total.Rows)
+		assert.Equal(t, big.NewInt(768), v4.Total.Addresses)
+	})
+}
+
+func TestCollector_GroupBy(t *testing.T) {
+	c, err := NewCollector(testConfig(), "country", false)
+	require.NoError(t, err)
+
+	require.NoError(t, c.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		[]mmdbtype.DataType{mmdbtype.String("US"), mmdbtype.String("Boston"), nil},
+	))
+	require.NoError(t, c.WriteRow(
+		netip.MustParsePrefix("1.0.1.0/24"),
+		[]mmdbtype.DataType{mmdbtype.String("US"), nil, nil},
+	))
+	require.NoError(t, c.WriteRow(
+		netip.MustParsePrefix("2.0.0.0/24"),
+		[]mmdbtype.DataType{nil, nil, mmdbtype.Bool(true)},
+	))
+
+	groups := c.Groups(FamilyIPv4)
+	require.Len(t, groups, 2)
+	assert.Equal(t, uint64(2), groups["US"].Total.Rows)
+	assert.Equal(t, uint64(1), groups["US"].Columns[1].Rows)
+	assert.Equal(t, uint64(1), groups[""].Total.Rows)
+
+	report := c.Report()
+	require.Len(t, report.Families[0].Groups, 2)
+	assert.Empty(t, *report.Families[0].Groups[0].Group)
+	assert.Equal(t, "US", *report.Families[0].Groups[1].Group)
+}
+
+func TestNewCollector_UnknownGroupColumn(t *testing.T) {
+	_, err := NewCollector(testConfig(), "missing", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing")
+}
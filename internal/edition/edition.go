@@ -0,0 +1,168 @@
+// Package edition catalogs the record structure of GeoIP2 and GeoLite2
+// editions. An edition maps short field names to the paths of the fields in
+// its records and their types, so that a configuration can name fields
+// instead of spelling out paths, and can be checked against the database
+// type of the file it reads.
+package edition
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/maxmind/mmdbconvert/internal/template"
+)
+
+// Field is a field of an edition's records.
+type Field struct {
+	Name string        // Short name that columns refer to the field by
+	Path []any         // Path in the records
+	Kind template.Kind // Type of the values
+}
+
+// Edition is a named database edition.
+type Edition struct {
+	Name   string // Also the database type of its files, e.g., "GeoIP2-ISP"
+	Fields []Field
+}
+
+// Field returns the named field of the edition.
+func (e *Edition) Field(name string) (Field, bool) {
+	for _, f := range e.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// FieldNames returns the names of the fields of the edition, in order.
+func (e *Edition) FieldNames() []string {
+	names := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Matches reports whether a database of databaseType has the records of
+// the edition. Regional variants of an edition, such as
+// "GeoIP2-City-Europe", match it.
+func (e *Edition) Matches(databaseType string) bool {
+	return databaseType == e.Name || strings.HasPrefix(databaseType, e.Name+"-")
+}
+
+// Lookup returns the edition with the given name.
+func Lookup(name string) (*Edition, bool) {
+	e, ok := editions[name]
+	return e, ok
+}
+
+// Names returns the names of all editions, sorted.
+func Names() []string {
+	return slices.Sorted(maps.Keys(editions))
+}
+
+// templateFields returns the fields of the named template, whose column
+// names and output paths follow the records of the edition it writes.
+func templateFields(name string) []Field {
+	tmpl, ok := template.Lookup(name)
+	if !ok {
+		panic("edition: unknown template " + name)
+	}
+	fields := make([]Field, len(tmpl.Fields))
+	for i, f := range tmpl.Fields {
+		fields[i] = Field{Name: f.Name, Path: f.Path, Kind: f.Kind}
+	}
+	return fields
+}
+
+var asnFields = []Field{
+	{
+		Name: "autonomous_system_number",
+		Path: []any{"autonomous_system_number"},
+		Kind: template.KindUint32,
+	},
+	{
+		Name: "autonomous_system_organization",
+		Path: []any{"autonomous_system_organization"},
+		Kind: template.KindString,
+	},
+}
+
+var ispFields = slices.Concat(asnFields, []Field{
+	{Name: "isp", Path: []any{"isp"}, Kind: template.KindString},
+	{Name: "organization", Path: []any{"organization"}, Kind: template.KindString},
+	{Name: "mobile_country_code", Path: []any{"mobile_country_code"}, Kind: template.KindString},
+	{Name: "mobile_network_code", Path: []any{"mobile_network_code"}, Kind: template.KindString},
+})
+
+var connectionTypeFields = []Field{
+	{Name: "connection_type", Path: []any{"connection_type"}, Kind: template.KindString},
+}
+
+// enterpriseFields are the fields Enterprise records add to City records:
+// the confidence of each place, and traits.
+var enterpriseFields = []Field{
+	{Name: "country_confidence", Path: []any{"country", "confidence"}, Kind: template.KindUint16},
+	{
+		Name: "subdivision_1_confidence",
+		Path: []any{"subdivisions", 0, "confidence"},
+		Kind: template.KindUint16,
+	},
+	{Name: "city_confidence", Path: []any{"city", "confidence"}, Kind: template.KindUint16},
+	{Name: "postal_confidence", Path: []any{"postal", "confidence"}, Kind: template.KindUint16},
+	{
+		Name: "autonomous_system_number",
+		Path: []any{"traits", "autonomous_system_number"},
+		Kind: template.KindUint32,
+	},
+	{
+		Name: "autonomous_system_organization",
+		Path: []any{"traits", "autonomous_system_organization"},
+		Kind: template.KindString,
+	},
+	{Name: "connection_type", Path: []any{"traits", "connection_type"}, Kind: template.KindString},
+	{Name: "domain", Path: []any{"traits", "domain"}, Kind: template.KindString},
+	{Name: "isp", Path: []any{"traits", "isp"}, Kind: template.KindString},
+	{Name: "organization", Path: []any{"traits", "organization"}, Kind: template.KindString},
+	{Name: "user_type", Path: []any{"traits", "user_type"}, Kind: template.KindString},
+	{
+		Name: "mobile_country_code",
+		Path: []any{"traits", "mobile_country_code"},
+		Kind: template.KindString,
+	},
+	{
+		Name: "mobile_network_code",
+		Path: []any{"traits", "mobile_network_code"},
+		Kind: template.KindString,
+	},
+	{Name: "static_ip_score", Path: []any{"traits", "static_ip_score"}, Kind: template.KindFloat64},
+	{Name: "is_anycast", Path: []any{"traits", "is_anycast"}, Kind: template.KindBool},
+	{
+		Name: "is_legitimate_proxy",
+		Path: []any{"traits", "is_legitimate_proxy"},
+		Kind: template.KindBool,
+	},
+}
+
+var editions = map[string]*Edition{
+	"GeoIP2-Anonymous-IP": {
+		Name:   "GeoIP2-Anonymous-IP",
+		Fields: templateFields("geoip2-anonymous-ip"),
+	},
+	"GeoIP2-City": {Name: "GeoIP2-City", Fields: templateFields("geoip2-city")},
+	"GeoIP2-Connection-Type": {
+		Name:   "GeoIP2-Connection-Type",
+		Fields: connectionTypeFields,
+	},
+	"GeoIP2-Country": {Name: "GeoIP2-Country", Fields: templateFields("geoip2-country")},
+	"GeoIP2-Enterprise": {
+		Name:   "GeoIP2-Enterprise",
+		Fields: slices.Concat(templateFields("geoip2-city"), enterpriseFields),
+	},
+	"GeoIP2-ISP":       {Name: "GeoIP2-ISP", Fields: ispFields},
+	"GeoLite2-ASN":     {Name: "GeoLite2-ASN", Fields: asnFields},
+	"GeoLite2-City":    {Name: "GeoLite2-City", Fields: templateFields("geoip2-city")},
+	"GeoLite2-Country": {Name: "GeoLite2-Country", Fields: templateFields("geoip2-country")},
+}
@@ -0,0 +1,70 @@
+package edition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/template"
+)
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, []string{
+		"GeoIP2-Anonymous-IP",
+		"GeoIP2-City",
+		"GeoIP2-Connection-Type",
+		"GeoIP2-Country",
+		"GeoIP2-Enterprise",
+		"GeoIP2-ISP",
+		"GeoLite2-ASN",
+		"GeoLite2-City",
+		"GeoLite2-Country",
+	}, Names())
+
+	_, ok := Lookup("geoip2-isp")
+	assert.False(t, ok)
+
+	for _, name := range Names() {
+		e, ok := Lookup(name)
+		require.True(t, ok)
+		assert.Equal(t, name, e.Name)
+
+		// Field names are unique within an edition
+		seen := map[string]bool{}
+		for _, f := range e.Fields {
+			assert.False(t, seen[f.Name], "%s: duplicate field %s", name, f.Name)
+			seen[f.Name] = true
+			assert.NotEmpty(t, f.Path, "%s: field %s", name, f.Name)
+		}
+	}
+}
+
+func TestEdition_Field(t *testing.T) {
+	isp, _ := Lookup("GeoIP2-ISP")
+	f, ok := isp.Field("autonomous_system_number")
+	require.True(t, ok)
+	assert.Equal(t, []any{"autonomous_system_number"}, f.Path)
+	assert.Equal(t, template.KindUint32, f.Kind)
+
+	// Enterprise records hold network fields among the traits
+	enterprise, _ := Lookup("GeoIP2-Enterprise")
+	f, ok = enterprise.Field("isp")
+	require.True(t, ok)
+	assert.Equal(t, []any{"traits", "isp"}, f.Path)
+	f, ok = enterprise.Field("city_name")
+	require.True(t, ok)
+	assert.Equal(t, []any{"city", "names", "en"}, f.Path)
+
+	_, ok = isp.Field("city_name")
+	assert.False(t, ok)
+}
+
+func TestEdition_Matches(t *testing.T) {
+	city, _ := Lookup("GeoIP2-City")
+	assert.True(t, city.Matches("GeoIP2-City"))
+	assert.True(t, city.Matches("GeoIP2-City-Europe"))
+	assert.False(t, city.Matches("GeoLite2-City"))
+	assert.False(t, city.Matches("GeoIP2-Country"))
+	assert.False(t, city.Matches("GeoIP2-CityX"))
+}
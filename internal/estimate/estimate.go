@@ -0,0 +1,241 @@
+// Package estimate predicts the number of rows and the size of an export
+// before running it, from the search tree sizes of the databases and a
+// sample of the merge.
+package estimate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// DefaultSampleRanges is the number of merged ranges sampled by default.
+const DefaultSampleRanges = 50000
+
+// Database describes the search tree of a database.
+type Database struct {
+	Name  string
+	Nodes uint
+	// Networks is the number of networks in the search tree, with or
+	// without data: a tree of n nodes has n+1 leaves.
+	Networks int64
+}
+
+// Estimate is the predicted size of an export.
+type Estimate struct {
+	Databases []Database // Iterated databases, in merge order
+	Rows      int64      // Output rows
+	// Bytes is the size of the output, or 0 if it is not estimated, as for
+	// MMDB output, whose size depends on how much data is deduplicated.
+	Bytes int64
+	// Exact is set when the sample covered the whole merge, so that Rows
+	// and Bytes are the actual size of the output.
+	Exact bool
+	// Sampled is the share of the first database's networks that the
+	// sample covered.
+	Sampled float64
+}
+
+// Parts returns the number of part files of at most maxSize bytes that the
+// output is expected to be rotated into, or 0 if the size is unknown.
+func (e *Estimate) Parts(maxSize int64) int64 {
+	if e.Bytes == 0 || maxSize <= 0 {
+		return 0
+	}
+	return (e.Bytes + maxSize - 1) / maxSize
+}
+
+// errSampleFull stops the merge once the sample is complete.
+var errSampleFull = errors.New("sample complete")
+
+// Run merges the first sampleRanges ranges of the export configured by cfg,
+// writing them in the output format to count rows and bytes, and
+// extrapolates to the whole merge by the share of the first database's
+// search tree that the sample covered. The estimate is rough, as the start of
+// the address space, which the sample covers, may be denser or sparser than
+// the rest.
+func Run(readers *mmdb.Readers, cfg *config.Config, sampleRanges int) (*Estimate, error) {
+	est := &Estimate{}
+	for _, name := range merger.IteratedDatabaseNames(cfg) {
+		reader, ok := readers.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("database '%s' not found", name)
+		}
+		nodes := reader.Metadata().NodeCount
+		est.Databases = append(est.Databases, Database{
+			Name:  name,
+			Nodes: nodes,
+			//nolint:gosec // node counts fit in 32 bits
+			Networks: int64(nodes) + 1,
+		})
+	}
+	if len(est.Databases) == 0 {
+		return nil, errors.New("no databases configured")
+	}
+
+	out := &countingOutput{}
+	var formatWriter row.Writer
+	if cfg.Output.Format != "mmdb" {
+		var err error
+		formatWriter, err = writer.New(out, cfg, writer.IPVersionAny)
+		if err != nil {
+			return nil, fmt.Errorf("creating %s writer: %w", cfg.Output.Format, err)
+		}
+		if closer, ok := formatWriter.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+	rowCount := newRowCounter(formatWriter)
+
+	var w row.Writer = rowCount
+	if len(cfg.Output.Filter) > 0 {
+		filter, err := writer.NewFilterWriter(rowCount, cfg)
+		if err != nil {
+			return nil, err
+		}
+		w = filter
+	}
+	s := &sampler{writer: w, limit: sampleRanges}
+
+	m, err := merger.NewMerger(readers, cfg, s)
+	if err != nil {
+		return nil, fmt.Errorf("creating merger: %w", err)
+	}
+	err = m.Merge()
+	if err != nil && !errors.Is(err, errSampleFull) {
+		return nil, fmt.Errorf("merging sample: %w", err)
+	}
+	est.Exact = err == nil
+	if formatWriter != nil {
+		if err := row.Flush(formatWriter); err != nil {
+			return nil, fmt.Errorf("writing sample: %w", err)
+		}
+	}
+
+	rows := rowCount.rows()
+	if est.Exact {
+		est.Rows = rows
+		est.Bytes = out.n
+		est.Sampled = 1
+		return est, nil
+	}
+
+	first, _ := readers.Get(est.Databases[0].Name)
+	covered, err := networksThrough(first, s.last)
+	if err != nil {
+		return nil, err
+	}
+	est.Sampled = min(float64(covered)/float64(est.Databases[0].Networks), 1)
+	est.Rows = int64(float64(rows) / est.Sampled)
+	if rows > 0 {
+		est.Bytes = int64(float64(out.n) / float64(rows) * float64(est.Rows))
+	}
+	return est, nil
+}
+
+// networksThrough counts the networks of r, with or without data, that
+// start at or before addr.
+func networksThrough(r *mmdb.Reader, addr netip.Addr) (int64, error) {
+	var n int64
+	for result := range r.Networks(maxminddb.IncludeNetworksWithoutData()) {
+		if err := result.Err(); err != nil {
+			return 0, fmt.Errorf("iterating networks: %w", err)
+		}
+		if result.Prefix().Addr().Compare(addr) > 0 {
+			break
+		}
+		n++
+	}
+	return n, nil
+}
+
+// sampler passes the first limit ranges from the merger on to writer.
+type sampler struct {
+	writer row.Writer
+	limit  int
+	ranges int
+	last   netip.Addr // Last address of the last range
+}
+
+func (s *sampler) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return s.WriteRange(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+func (s *sampler) WriteRange(start, end netip.Addr, r row.Row) error {
+	if s.ranges >= s.limit {
+		return errSampleFull
+	}
+	s.ranges++
+	s.last = end
+	return row.WriteRange(s.writer, start, end, r)
+}
+
+// rowCounter counts the rows written to the format writer, which is nil
+// for MMDB output. A range is a single row if the format writer writes
+// ranges as such, and one row per CIDR otherwise.
+type rowCounter struct {
+	writer row.Writer
+	n      int64
+}
+
+// rangeCounter is a rowCounter for format writers that accept ranges.
+type rangeCounter struct {
+	rowCounter
+}
+
+func (c *rangeCounter) WriteRange(start, end netip.Addr, r row.Row) error {
+	c.n++
+	return row.WriteRange(c.writer, start, end, r)
+}
+
+// counter is a row.Writer counting the rows written.
+type counter interface {
+	row.Writer
+	rows() int64
+}
+
+// rangeWriter is implemented by format writers that write some ranges as a
+// single row, depending on the network columns.
+type rangeWriter interface {
+	row.RangeWriter
+	WritesRanges() bool
+}
+
+func newRowCounter(w row.Writer) counter {
+	if rw, ok := w.(rangeWriter); ok && rw.WritesRanges() {
+		return &rangeCounter{rowCounter{writer: w}}
+	}
+	return &rowCounter{writer: w}
+}
+
+func (c *rowCounter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	c.n++
+	if c.writer == nil {
+		return nil
+	}
+	return c.writer.WriteRow(prefix, r)
+}
+
+func (c *rowCounter) rows() int64 {
+	return c.n
+}
+
+// countingOutput counts the bytes written by the format writer.
+type countingOutput struct {
+	n int64
+}
+
+func (c *countingOutput) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
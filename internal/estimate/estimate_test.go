@@ -0,0 +1,115 @@
+package estimate
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// openSlash8Database opens an IPv4 database with a distinct record for every
+// /8, so that each is a row of its own.
+func openSlash8Database(t *testing.T) *mmdb.Readers {
+	t.Helper()
+
+	records := make(map[string]mmdbtype.Map, 256)
+	for i := range 256 {
+		records[fmt.Sprintf("%d.0.0.0/8", i)] = mmdbtype.Map{"id": mmdbtype.Uint32(i)}
+	}
+	opts := mmdbtest.Options()
+	opts.IPVersion = 4
+	path := mmdbtest.WriteTemp(t, opts, records)
+
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"db": {Name: "db", Path: path},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { readers.Close() })
+	return readers
+}
+
+func csvConfig() *config.Config {
+	includeHeader := true
+	return &config.Config{
+		Output: config.OutputConfig{
+			Format: "csv",
+			CSV:    config.CSVConfig{Delimiter: ",", IncludeHeader: &includeHeader},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "id", Database: "db", Path: config.Path{"id"}}},
+	}
+}
+
+// export writes the whole export to a buffer.
+func export(t *testing.T, readers *mmdb.Readers, cfg *config.Config) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := writer.New(&buf, cfg, writer.IPVersionAny)
+	require.NoError(t, err)
+	m, err := merger.NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+	require.NoError(t, w.(*writer.CSVWriter).Flush())
+	return buf.Bytes()
+}
+
+func TestRun(t *testing.T) {
+	readers := openSlash8Database(t)
+	cfg := csvConfig()
+	actual := export(t, readers, cfg)
+
+	t.Run("whole merge", func(t *testing.T) {
+		est, err := Run(readers, cfg, DefaultSampleRanges)
+		require.NoError(t, err)
+		assert.True(t, est.Exact)
+		assert.Equal(t, int64(256), est.Rows)
+		assert.Equal(t, int64(len(actual)), est.Bytes)
+		assert.Equal(t, []Database{{Name: "db", Nodes: 255, Networks: 256}}, est.Databases)
+	})
+
+	t.Run("sample", func(t *testing.T) {
+		est, err := Run(readers, cfg, 64)
+		require.NoError(t, err)
+		assert.False(t, est.Exact)
+		assert.InDelta(t, 0.25, est.Sampled, 0.001)
+		assert.Equal(t, int64(256), est.Rows)
+		assert.InEpsilon(t, len(actual), est.Bytes, 0.1)
+	})
+
+	t.Run("mmdb", func(t *testing.T) {
+		cfg := csvConfig()
+		cfg.Output.Format = "mmdb"
+		est, err := Run(readers, cfg, 64)
+		require.NoError(t, err)
+		assert.Equal(t, int64(256), est.Rows)
+		assert.Zero(t, est.Bytes, "the size of MMDB output is not estimated")
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		cfg := csvConfig()
+		cfg.Output.Filter = map[string]any{"id": int64(7)}
+		est, err := Run(readers, cfg, DefaultSampleRanges)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), est.Rows)
+	})
+}
+
+func TestEstimate_Parts(t *testing.T) {
+	est := &Estimate{Bytes: 2500}
+	assert.Equal(t, int64(3), est.Parts(1000))
+	assert.Equal(t, int64(1), est.Parts(2500))
+	assert.Zero(t, est.Parts(0))
+	assert.Zero(t, (&Estimate{}).Parts(1000), "size unknown")
+}
@@ -0,0 +1,372 @@
+// Package example writes self-contained example conversions for new users.
+// Each example is a commented configuration and a miniature database in the
+// format of a MaxMind edition, generated on the fly, so that the
+// configuration works without downloading any database.
+//
+// The sample records are made up. They have the structure of the edition's
+// records, but their values are not those of any release.
+package example
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"go4.org/netipx"
+)
+
+// ConfigFile is the name of the configuration of every example.
+const ConfigFile = "config.toml"
+
+// edition is an edition that examples can be written for.
+type edition struct {
+	// config is the configuration, in which {database} and {output} are
+	// replaced by the file names of the sample database and the output.
+	config   string
+	networks []network
+}
+
+// network is a record of a sample database.
+type network struct {
+	cidr   string
+	record mmdbtype.Map
+}
+
+var editions = map[string]edition{
+	"GeoLite2-ASN":     {config: asnConfig, networks: asnNetworks},
+	"GeoLite2-City":    {config: cityConfig, networks: cityNetworks},
+	"GeoLite2-Country": {config: countryConfig, networks: countryNetworks},
+}
+
+// Editions returns the names of the editions that examples can be written
+// for, sorted.
+func Editions() []string {
+	return slices.Sorted(maps.Keys(editions))
+}
+
+// Files are the files of an example, relative to its directory.
+type Files struct {
+	Config   string
+	Database string
+	Output   string // Written when the configuration is run
+}
+
+// Write writes the example for the named edition to dir, which is created if
+// needed. Existing files are not overwritten.
+func Write(dir, name string) (Files, error) {
+	ed, ok := editions[name]
+	if !ok {
+		return Files{}, fmt.Errorf(
+			"unknown edition '%s', must be one of: %s",
+			name,
+			strings.Join(Editions(), ", "),
+		)
+	}
+	files := Files{
+		Config:   ConfigFile,
+		Database: name + "-Sample.mmdb",
+		Output:   name + ".csv",
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return Files{}, err
+	}
+	if err := writeDatabase(filepath.Join(dir, files.Database), name, ed.networks); err != nil {
+		return Files{}, fmt.Errorf("writing sample database: %w", err)
+	}
+	config := strings.NewReplacer(
+		"{database}", files.Database,
+		"{output}", files.Output,
+	).Replace(ed.config)
+	if err := createFile(filepath.Join(dir, files.Config), func(f *os.File) error {
+		_, err := f.WriteString(config)
+		return err
+	}); err != nil {
+		return Files{}, fmt.Errorf("writing configuration: %w", err)
+	}
+	return files, nil
+}
+
+// writeDatabase writes the sample database of the named edition to path.
+func writeDatabase(path, name string, networks []network) error {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: name,
+		Description: map[string]string{
+			"en": "Miniature sample of " + name + " with made-up records, written by mmdbconvert example",
+		},
+		IPVersion:  6,
+		RecordSize: 24,
+	})
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		prefix := netip.MustParsePrefix(n.cidr)
+		if err := tree.Insert(netipx.PrefixIPNet(prefix), n.record); err != nil {
+			return fmt.Errorf("inserting %s: %w", n.cidr, err)
+		}
+	}
+	return createFile(path, func(f *os.File) error {
+		_, err := tree.WriteTo(f)
+		return err
+	})
+}
+
+// createFile creates path, failing if it exists, and writes it with write.
+func createFile(path string, write func(*os.File) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) // #nosec G304 -- path is in the example directory
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists; choose another directory", path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// names returns the localized names of a record, in English only.
+func names(en string) mmdbtype.Map {
+	return mmdbtype.Map{"en": mmdbtype.String(en)}
+}
+
+// countryRecord returns the fields of a record of the Country edition.
+func countryRecord(continent, country, countryName string) mmdbtype.Map {
+	return mmdbtype.Map{
+		"continent": mmdbtype.Map{"code": mmdbtype.String(continent)},
+		"country": mmdbtype.Map{
+			"iso_code": mmdbtype.String(country),
+			"names":    names(countryName),
+		},
+		"registered_country": mmdbtype.Map{
+			"iso_code": mmdbtype.String(country),
+			"names":    names(countryName),
+		},
+	}
+}
+
+// place is a city of the sample City database.
+type place struct {
+	continent, country, countryName string
+	subdivision, subdivisionName    string
+	geonameID                       uint32
+	city, timeZone, postal          string
+	latitude, longitude             float64
+	accuracyRadius                  uint16
+}
+
+// cityRecord returns the record of a network in p.
+func cityRecord(p place) mmdbtype.Map {
+	record := countryRecord(p.continent, p.country, p.countryName)
+	record["subdivisions"] = mmdbtype.Slice{mmdbtype.Map{
+		"iso_code": mmdbtype.String(p.subdivision),
+		"names":    names(p.subdivisionName),
+	}}
+	record["city"] = mmdbtype.Map{
+		"geoname_id": mmdbtype.Uint32(p.geonameID),
+		"names":      names(p.city),
+	}
+	record["location"] = mmdbtype.Map{
+		"accuracy_radius": mmdbtype.Uint16(p.accuracyRadius),
+		"latitude":        mmdbtype.Float64(p.latitude),
+		"longitude":       mmdbtype.Float64(p.longitude),
+		"time_zone":       mmdbtype.String(p.timeZone),
+	}
+	record["postal"] = mmdbtype.Map{"code": mmdbtype.String(p.postal)}
+	return record
+}
+
+var (
+	london = place{
+		continent: "EU", country: "GB", countryName: "United Kingdom",
+		subdivision: "ENG", subdivisionName: "England",
+		geonameID: 2643743, city: "London", timeZone: "Europe/London", postal: "EC1A",
+		latitude: 51.5142, longitude: -0.0931, accuracyRadius: 20,
+	}
+	linkoping = place{
+		continent: "EU", country: "SE", countryName: "Sweden",
+		subdivision: "E", subdivisionName: "Östergötland County",
+		geonameID: 2694762, city: "Linköping", timeZone: "Europe/Stockholm", postal: "582 22",
+		latitude: 58.4167, longitude: 15.6167, accuracyRadius: 50,
+	}
+	milton = place{
+		continent: "NA", country: "US", countryName: "United States",
+		subdivision: "WA", subdivisionName: "Washington",
+		geonameID: 5803556, city: "Milton", timeZone: "America/Los_Angeles", postal: "98354",
+		latitude: 47.2513, longitude: -122.3149, accuracyRadius: 20,
+	}
+	tokyo = place{
+		continent: "AS", country: "JP", countryName: "Japan",
+		subdivision: "13", subdivisionName: "Tokyo",
+		geonameID: 1850147, city: "Tokyo", timeZone: "Asia/Tokyo", postal: "100-0001",
+		latitude: 35.6893, longitude: 139.6899, accuracyRadius: 100,
+	}
+)
+
+// The networks of the City sample include adjacent networks of the same
+// city, which the output merges into one row.
+var cityNetworks = []network{
+	{"81.2.69.140/31", cityRecord(london)},
+	{"81.2.69.142/31", cityRecord(london)},
+	{"89.160.20.112/28", cityRecord(linkoping)},
+	{"216.160.83.56/29", cityRecord(milton)},
+	{"2001:218::/32", cityRecord(tokyo)},
+}
+
+var countryNetworks = []network{
+	{"81.2.69.0/24", countryRecord("EU", "GB", "United Kingdom")},
+	{"89.160.20.0/24", countryRecord("EU", "SE", "Sweden")},
+	{"216.160.83.0/24", countryRecord("NA", "US", "United States")},
+	{"2001:218::/32", countryRecord("AS", "JP", "Japan")},
+}
+
+// asnRecord returns the record of a network announced by an autonomous
+// system.
+func asnRecord(number uint32, organization string) mmdbtype.Map {
+	return mmdbtype.Map{
+		"autonomous_system_number":       mmdbtype.Uint32(number),
+		"autonomous_system_organization": mmdbtype.String(organization),
+	}
+}
+
+var asnNetworks = []network{
+	{"1.128.0.0/11", asnRecord(1221, "Telstra Pty Ltd")},
+	{"12.81.92.0/22", asnRecord(7018, "AT&T Services")},
+	{"89.160.20.0/24", asnRecord(29518, "Bredband2 AB")},
+	{"2001:218::/32", asnRecord(2914, "NTT America, Inc.")},
+}
+
+const cityConfig = `# Example mmdbconvert configuration for GeoLite2-City.
+#
+# It converts {database}, a miniature sample with made-up records,
+# to {output}. Run it from this directory with:
+#
+#     mmdbconvert --config config.toml
+#
+# To convert a full database, change the path of the database below. See
+# docs/config.md for every option.
+
+[output]
+format = "csv"     # Also "parquet", "mmdb", "ptr", "vcl", "envoy", "sqlite", "clickhouse", "postgres", "xlsx", "protobuf", "msgpack", and "bigquery"
+file = "{output}"
+
+# Each database is read by name from the columns. Paths are relative to the
+# directory mmdbconvert is run from.
+[[databases]]
+name = "city"
+path = "{database}"
+
+# The network of each row. Without any network columns, CSV output has a
+# CIDR column named "network".
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+# A preset adds the usual columns of a granularity: continent_code,
+# country_iso_code, country_name, subdivision_1_iso_code, subdivision_1_name,
+# city_geoname_id, city_name, time_zone, latitude, longitude, and
+# accuracy_radius. Adjacent networks of the same city are merged into one row.
+[preset]
+name = "city"      # Also "country", "subdivision", and "postal"
+database = "city"
+
+# Further columns select a value of the database's records by its path.
+[[columns]]
+name = "postal_code"
+database = "city"
+path = ["postal", "code"]
+`
+
+const countryConfig = `# Example mmdbconvert configuration for GeoLite2-Country.
+#
+# It converts {database}, a miniature sample with made-up records,
+# to {output}. Run it from this directory with:
+#
+#     mmdbconvert --config config.toml
+#
+# To convert a full database, change the path of the database below. See
+# docs/config.md for every option.
+
+[output]
+format = "csv"     # Also "parquet", "mmdb", "ptr", "vcl", "envoy", "sqlite", "clickhouse", "postgres", "xlsx", "protobuf", "msgpack", and "bigquery"
+file = "{output}"
+
+# Each database is read by name from the columns. Paths are relative to the
+# directory mmdbconvert is run from.
+[[databases]]
+name = "country"
+path = "{database}"
+
+# The network of each row. Without any network columns, CSV output has a
+# CIDR column named "network".
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+# A preset adds the usual columns of a granularity: continent_code,
+# country_iso_code, and country_name.
+[preset]
+name = "country"
+database = "country"
+
+# Further columns select a value of the database's records by its path.
+[[columns]]
+name = "registered_country_iso_code"
+database = "country"
+path = ["registered_country", "iso_code"]
+`
+
+const asnConfig = `# Example mmdbconvert configuration for GeoLite2-ASN.
+#
+# It converts {database}, a miniature sample with made-up records,
+# to {output}. Run it from this directory with:
+#
+#     mmdbconvert --config config.toml
+#
+# To convert a full database, change the path of the database below. See
+# docs/config.md for every option.
+
+[output]
+format = "csv"     # Also "parquet", "mmdb", "ptr", "vcl", "envoy", "sqlite", "clickhouse", "postgres", "xlsx", "protobuf", "msgpack", and "bigquery"
+file = "{output}"
+
+# Each database is read by name from the columns. Paths are relative to the
+# directory mmdbconvert is run from.
+[[databases]]
+name = "asn"
+path = "{database}"
+
+# The network of each row, as a CIDR and as its first and last addresses.
+[[network.columns]]
+name = "network"
+type = "cidr"
+
+[[network.columns]]
+name = "start_ip"
+type = "start_ip"
+
+[[network.columns]]
+name = "end_ip"
+type = "end_ip"
+
+# Each column selects a value of the database's records by its path.
+[[columns]]
+name = "autonomous_system_number"
+database = "asn"
+path = ["autonomous_system_number"]
+
+[[columns]]
+name = "autonomous_system_organization"
+database = "asn"
+path = ["autonomous_system_organization"]
+`
@@ -0,0 +1,61 @@
+package example
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+)
+
+func TestWrite(t *testing.T) {
+	for _, name := range Editions() {
+		t.Run(name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			files, err := Write(".", name)
+			require.NoError(t, err)
+			assert.Equal(t, Files{
+				Config:   "config.toml",
+				Database: name + "-Sample.mmdb",
+				Output:   name + ".csv",
+			}, files)
+
+			cfg, err := config.LoadConfig(files.Config)
+			require.NoError(t, err)
+			assert.Equal(t, files.Output, cfg.Output.File)
+			require.Len(t, cfg.Databases, 1)
+			assert.Equal(t, files.Database, cfg.Databases[0].Path)
+
+			reader, err := mmdb.Open(cfg.Databases[0])
+			require.NoError(t, err)
+			defer reader.Close()
+			assert.Equal(t, name, reader.Metadata().DatabaseType)
+		})
+	}
+}
+
+func TestWrite_UnknownEdition(t *testing.T) {
+	_, err := Write(t.TempDir(), "GeoIP2-Domain")
+	require.EqualError(
+		t,
+		err,
+		"unknown edition 'GeoIP2-Domain', must be one of: GeoLite2-ASN, GeoLite2-City, GeoLite2-Country",
+	)
+}
+
+func TestWrite_ExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, ConfigFile)
+	require.NoError(t, os.WriteFile(config, []byte("# mine\n"), 0o600))
+
+	_, err := Write(dir, "GeoLite2-City")
+	require.ErrorContains(t, err, "already exists")
+
+	data, err := os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Equal(t, "# mine\n", string(data))
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// csvReader reads a CSV export. Provenance comment lines are skipped.
+type csvReader struct {
+	file   *os.File
+	r      *csv.Reader
+	layout *layout
+	row    row.Row
+}
+
+func newCSVReader(file *os.File, opts Options) (*csvReader, error) {
+	r := csv.NewReader(bufio.NewReader(file))
+	r.Comment = '#'
+	r.ReuseRecord = true
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
+	}
+
+	columns := opts.Columns
+	if columns == nil {
+		header, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty CSV file, expected a header")
+		}
+		if err != nil {
+			return nil, err
+		}
+		columns = append([]string(nil), header...)
+	} else {
+		r.FieldsPerRecord = len(columns)
+	}
+
+	l, err := newLayout(columns, opts.Network)
+	if err != nil {
+		return nil, err
+	}
+	return &csvReader{
+		file:   file,
+		r:      r,
+		layout: l,
+		row:    make(row.Row, len(l.data)),
+	}, nil
+}
+
+// Columns returns the data column names.
+func (r *csvReader) Columns() []string {
+	return r.layout.names
+}
+
+// Read returns the range and row of the next record. Empty fields are null.
+func (r *csvReader) Read() (start, end netip.Addr, data row.Row, err error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return start, end, nil, err
+	}
+	line, _ := r.r.FieldPos(0)
+
+	start, end, err = r.layout.bounds(
+		func(i int) (string, error) {
+			if record[i] == "" {
+				return "", r.layout.empty(i)
+			}
+			return record[i], nil
+		},
+		func(i int) (netip.Addr, error) {
+			if record[i] == "" {
+				return netip.Addr{}, r.layout.empty(i)
+			}
+			return decimalAddr(record[i])
+		},
+	)
+	if err != nil {
+		return start, end, nil, fmt.Errorf("line %d: %w", line, err)
+	}
+
+	for i, index := range r.layout.data {
+		if record[index] == "" {
+			r.row[i] = nil
+		} else {
+			r.row[i] = mmdbtype.String(record[index])
+		}
+	}
+	return start, end, r.row, nil
+}
+
+// Close closes the file.
+func (r *csvReader) Close() error {
+	return r.file.Close()
+}
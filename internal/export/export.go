@@ -0,0 +1,264 @@
+// Package export reads the outputs of earlier runs back as rows, so that
+// commands can work on past exports the same way whatever their format.
+// CSV and Parquet files and merge files are supported.
+//
+// The range of each row is read from its network columns: a CIDR column, or
+// a pair of start and end columns of the same kind. Every other column is a
+// data column. Parquet values keep their types; CSV values are strings, as
+// the format does not record types.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mergefile"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// Options describe how an export was written. The zero value reads the
+// default layout of each format.
+type Options struct {
+	// Network lists the network columns by name. Without it, a column is a
+	// network column if it is named after a network column type, or
+	// "network" for a CIDR column, as in the default layouts.
+	Network []config.NetworkColumn
+
+	// Columns names the columns of a CSV file written without a header.
+	Columns []string
+
+	// Delimiter separates CSV fields (default: ',').
+	Delimiter rune
+}
+
+// OptionsFromConfig returns the options reading the output of cfg.
+func OptionsFromConfig(cfg *config.Config) Options {
+	// The columns are named as in the output
+	opts := Options{Network: slices.Clone(cfg.Network.Columns)}
+	for i, col := range opts.Network {
+		opts.Network[i].Name = mmdbtype.String(col.OutputName(cfg.Output.Format))
+	}
+	if cfg.Output.CSV.Delimiter != "" {
+		opts.Delimiter = []rune(cfg.Output.CSV.Delimiter)[0]
+	}
+	if cfg.Output.CSV.IncludeHeader != nil && !*cfg.Output.CSV.IncludeHeader {
+		for _, col := range opts.Network {
+			opts.Columns = append(opts.Columns, string(col.Name))
+		}
+		for _, col := range cfg.Columns {
+			opts.Columns = append(opts.Columns, col.OutputName(cfg.Output.Format))
+		}
+	}
+	return opts
+}
+
+// parquetMagic starts every Parquet file.
+const parquetMagic = "PAR1"
+
+// Open opens the export at path, detecting its format from its first bytes:
+// merge files and Parquet files start with a magic string, and anything else
+// is read as CSV.
+func Open(path string, opts Options) (row.Reader, error) {
+	// #nosec G304 -- path comes from a trusted command-line argument
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	start := make([]byte, len(mergefile.Magic))
+	n, err := io.ReadFull(file, start)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		file.Close()
+		return nil, err
+	}
+
+	r, err := openFormat(file, path, string(start[:n]), opts)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// openFormat opens the export in file, whose first bytes are start.
+func openFormat(file *os.File, path, start string, opts Options) (row.Reader, error) {
+	switch {
+	case start == mergefile.Magic:
+		file.Close()
+		r, err := openMergeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case strings.HasPrefix(start, parquetMagic):
+		r, err := newParquetReader(file, opts)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	default:
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		r, err := newCSVReader(file, opts)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// defaultNetworkTypes are the network column types recognized by name when
+// no network columns are given.
+var defaultNetworkTypes = map[string]string{
+	"network":                        writer.NetworkColumnCIDR,
+	writer.NetworkColumnCIDR:         writer.NetworkColumnCIDR,
+	writer.NetworkColumnStartIP:      writer.NetworkColumnStartIP,
+	writer.NetworkColumnEndIP:        writer.NetworkColumnEndIP,
+	writer.NetworkColumnStartInt:     writer.NetworkColumnStartInt,
+	writer.NetworkColumnEndInt:       writer.NetworkColumnEndInt,
+	writer.NetworkColumnStartDecimal: writer.NetworkColumnStartDecimal,
+	writer.NetworkColumnEndDecimal:   writer.NetworkColumnEndDecimal,
+}
+
+// boundPairs are the start and end column types that give a range, in
+// order of preference.
+var boundPairs = [][2]string{
+	{writer.NetworkColumnStartIP, writer.NetworkColumnEndIP},
+	{writer.NetworkColumnStartInt, writer.NetworkColumnEndInt},
+	{writer.NetworkColumnStartDecimal, writer.NetworkColumnEndDecimal},
+}
+
+// layout locates the range and the data columns of a row among the columns
+// of an export.
+type layout struct {
+	cidr       int      // Index of the CIDR column, or -1
+	start, end int      // Indexes of the start and end columns, if no CIDR
+	boundType  string   // Type of the start column
+	data       []int    // Indexes of the data columns
+	names      []string // Names of the data columns
+	columns    []string // Names of all columns
+}
+
+func newLayout(columns []string, network []config.NetworkColumn) (*layout, error) {
+	types := defaultNetworkTypes
+	if network != nil {
+		types = map[string]string{}
+		for _, col := range network {
+			types[string(col.Name)] = col.Type
+		}
+	}
+
+	l := &layout{cidr: -1, start: -1, end: -1, columns: columns}
+	byType := map[string]int{}
+	for i, name := range columns {
+		typ, ok := types[name]
+		switch typ {
+		case writer.NetworkColumnValidFrom, writer.NetworkColumnValidTo:
+			// The validity of a time-sliced row is data to its readers
+			ok = false
+		}
+		if !ok {
+			l.data = append(l.data, i)
+			l.names = append(l.names, name)
+			continue
+		}
+		if _, dup := byType[typ]; !dup {
+			byType[typ] = i
+		}
+	}
+
+	if i, ok := byType[writer.NetworkColumnCIDR]; ok {
+		l.cidr = i
+		return l, nil
+	}
+	for _, pair := range boundPairs {
+		start, okStart := byType[pair[0]]
+		end, okEnd := byType[pair[1]]
+		if okStart && okEnd {
+			l.start, l.end, l.boundType = start, end, pair[0]
+			return l, nil
+		}
+	}
+	return nil, errors.New(
+		"no network columns found: expected a CIDR column or a pair of start and end columns",
+	)
+}
+
+// bounds returns the range of a row. text returns the text of a column, and
+// intAddr the address of a start_int or end_int column.
+func (l *layout) bounds(
+	text func(i int) (string, error),
+	intAddr func(i int) (netip.Addr, error),
+) (start, end netip.Addr, err error) {
+	if l.cidr >= 0 {
+		s, err := text(l.cidr)
+		if err != nil {
+			return start, end, err
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return start, end, err
+		}
+		prefix = prefix.Masked()
+		return prefix.Addr(), netipx.PrefixLastIP(prefix), nil
+	}
+
+	parse := func(i int) (netip.Addr, error) {
+		if l.boundType == writer.NetworkColumnStartInt {
+			return intAddr(i)
+		}
+		s, err := text(i)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		if l.boundType == writer.NetworkColumnStartDecimal {
+			return decimalAddr(s)
+		}
+		return netip.ParseAddr(s)
+	}
+	if start, err = parse(l.start); err != nil {
+		return start, end, err
+	}
+	if end, err = parse(l.end); err != nil {
+		return start, end, err
+	}
+	if start.Is4() != end.Is4() || end.Less(start) {
+		return start, end, fmt.Errorf("invalid range %s-%s", start, end)
+	}
+	return start, end, nil
+}
+
+var maxUint32 = big.NewInt(1<<32 - 1)
+
+// decimalAddr parses an address written as a decimal integer. Integers that
+// fit in 32 bits are IPv4 addresses, as the merge writes IPv4 networks as
+// such.
+func decimalAddr(s string) (netip.Addr, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return netip.Addr{}, fmt.Errorf("invalid address integer '%s'", s)
+	}
+	if n.Cmp(maxUint32) <= 0 {
+		var b [4]byte
+		return netip.AddrFrom4([4]byte(n.FillBytes(b[:]))), nil
+	}
+	var b [16]byte
+	return netip.AddrFrom16([16]byte(n.FillBytes(b[:]))), nil
+}
+
+// empty reports that network column i is empty in a row.
+func (l *layout) empty(i int) error {
+	return fmt.Errorf("network column '%s' is empty", l.columns[i])
+}
@@ -0,0 +1,289 @@
+package export
+
+import (
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mergefile"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+type readRange struct {
+	start, end string
+	data       row.Row
+}
+
+// readAll reads every range of the export at path.
+func readAll(t *testing.T, path string, opts Options) ([]string, []readRange) {
+	t.Helper()
+	r, err := Open(path, opts)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var ranges []readRange
+	for {
+		start, end, data, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		ranges = append(ranges, readRange{start.String(), end.String(), append(row.Row(nil), data...)})
+	}
+	return r.Columns(), ranges
+}
+
+// writeExport writes rows for the prefixes to a file with the writer for
+// cfg.
+func writeExport(
+	t *testing.T,
+	name string,
+	cfg *config.Config,
+	ipVersion int,
+	rows map[string]row.Row,
+	prefixes ...string,
+) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w, err := writer.New(f, cfg, ipVersion)
+	require.NoError(t, err)
+	for _, prefix := range prefixes {
+		require.NoError(t, w.WriteRow(netip.MustParsePrefix(prefix), rows[prefix]))
+	}
+	require.NoError(t, row.Flush(w))
+	return path
+}
+
+var testRows = map[string]row.Row{
+	"1.0.0.0/24":    {mmdbtype.String("US"), mmdbtype.Uint32(13335), mmdbtype.Bool(true)},
+	"2.0.0.0/23":    {mmdbtype.String("FR"), nil, mmdbtype.Bool(false)},
+	"2001:db8::/32": {mmdbtype.String("DE"), mmdbtype.Uint32(3320), nil},
+}
+
+func TestOpen_CSV(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Format: "csv",
+			CSV:    config.CSVConfig{Delimiter: ",", ProvenanceHeader: true},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "country"}, {Name: "asn"}, {Name: "is_hosting"}},
+	}
+	cfg.Provenance.ToolVersion = "1.2.3"
+	path := writeExport(
+		t, "out.csv", cfg, writer.IPVersionAny, testRows,
+		"1.0.0.0/24", "2.0.0.0/23", "2001:db8::/32",
+	)
+
+	columns, ranges := readAll(t, path, Options{})
+	assert.Equal(t, []string{"country", "asn", "is_hosting"}, columns)
+	assert.Equal(t, []readRange{
+		{"1.0.0.0", "1.0.0.255", row.Row{
+			mmdbtype.String("US"), mmdbtype.String("13335"), mmdbtype.String("1"),
+		}},
+		{"2.0.0.0", "2.0.1.255", row.Row{
+			mmdbtype.String("FR"), nil, mmdbtype.String("0"),
+		}},
+		{"2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", row.Row{
+			mmdbtype.String("DE"), mmdbtype.String("3320"), nil,
+		}},
+	}, ranges)
+}
+
+func TestOpen_CSVWithoutHeader(t *testing.T) {
+	includeHeader := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Format: "csv",
+			CSV:    config.CSVConfig{Delimiter: "\t", IncludeHeader: &includeHeader},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "first", Type: "start_decimal"},
+				{Name: "last", Type: "end_decimal"},
+			},
+		},
+		Columns: []config.Column{{Name: "country"}, {Name: "asn"}, {Name: "is_hosting"}},
+	}
+	path := writeExport(
+		t, "out.tsv", cfg, writer.IPVersionAny, testRows,
+		"1.0.0.0/24", "2001:db8::/32",
+	)
+
+	columns, ranges := readAll(t, path, OptionsFromConfig(cfg))
+	assert.Equal(t, []string{"country", "asn", "is_hosting"}, columns)
+	require.Len(t, ranges, 2)
+	assert.Equal(t, "1.0.0.0", ranges[0].start)
+	assert.Equal(t, "1.0.0.255", ranges[0].end)
+	assert.Equal(t, "2001:db8::", ranges[1].start)
+	assert.Equal(t, "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", ranges[1].end)
+}
+
+func TestOpen_Parquet(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Format:  "parquet",
+			Parquet: config.ParquetConfig{Compression: "snappy", RowGroupSize: 2},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_int", Type: "start_int"},
+				{Name: "end_int", Type: "end_int"},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "asn", Type: "int64"},
+			{Name: "is_hosting", Type: "bool"},
+		},
+	}
+	path := writeExport(
+		t, "out.parquet", cfg, writer.IPVersionAny, testRows,
+		"1.0.0.0/24", "2.0.0.0/23",
+	)
+
+	columns, ranges := readAll(t, path, Options{})
+	assert.ElementsMatch(t, []string{"country", "asn", "is_hosting"}, columns)
+	require.Len(t, ranges, 2)
+	assert.Equal(t, "1.0.0.0", ranges[0].start)
+	assert.Equal(t, "2.0.1.255", ranges[1].end)
+
+	values := map[string]mmdbtype.DataType{}
+	for i, name := range columns {
+		values[name] = ranges[0].data[i]
+	}
+	assert.Equal(t, map[string]mmdbtype.DataType{
+		"country":    mmdbtype.String("US"),
+		"asn":        mmdbtype.Uint64(13335),
+		"is_hosting": mmdbtype.Bool(true),
+	}, values)
+
+	// IPv6 integers are 16-byte values
+	path = writeExport(
+		t, "out_ipv6.parquet", cfg, writer.IPVersion6, testRows, "2001:db8::/32",
+	)
+	_, ranges = readAll(t, path, Options{})
+	require.Len(t, ranges, 1)
+	assert.Equal(t, "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", ranges[0].end)
+}
+
+func TestOpen_ParquetGroups(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Format:  "parquet",
+			Parquet: config.ParquetConfig{Compression: "snappy", RowGroupSize: 2},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "asn", Type: "int64", Group: "isp"},
+			{Name: "is_hosting", Type: "bool", Group: "isp"},
+		},
+	}
+	path := writeExport(t, "out.parquet", cfg, writer.IPVersionAny, testRows, "1.0.0.0/24")
+
+	// Columns of groups are read by their own names
+	columns, ranges := readAll(t, path, Options{})
+	assert.ElementsMatch(t, []string{"country", "asn", "is_hosting"}, columns)
+	require.Len(t, ranges, 1)
+	values := map[string]mmdbtype.DataType{}
+	for i, name := range columns {
+		values[name] = ranges[0].data[i]
+	}
+	assert.Equal(t, map[string]mmdbtype.DataType{
+		"country":    mmdbtype.String("US"),
+		"asn":        mmdbtype.Uint64(13335),
+		"is_hosting": mmdbtype.Bool(true),
+	}, values)
+}
+
+func TestOpen_MergeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "merged.bin")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	w, err := mergefile.NewWriter(f, mergefile.Header{
+		Columns:   []string{"country", "asn", "is_hosting"},
+		IPVersion: 6,
+	})
+	require.NoError(t, err)
+	for _, prefix := range []string{"1.0.0.0/24", "2001:db8::/32"} {
+		require.NoError(t, w.WriteRow(netip.MustParsePrefix(prefix), testRows[prefix]))
+	}
+	require.NoError(t, w.Flush())
+	require.NoError(t, f.Close())
+
+	columns, ranges := readAll(t, path, Options{})
+	assert.Equal(t, []string{"country", "asn", "is_hosting"}, columns)
+	assert.Equal(t, []readRange{
+		{"1.0.0.0", "1.0.0.255", testRows["1.0.0.0/24"]},
+		{"2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", testRows["2001:db8::/32"]},
+	}, ranges)
+
+	// Closing before the end stops reading the file
+	r, err := Open(path, Options{})
+	require.NoError(t, err)
+	_, _, _, err = r.Read()
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+}
+
+func TestOpen_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name        string
+		contents    string
+		opts        Options
+		expectError string
+	}{
+		{
+			name:        "no network columns",
+			contents:    "ip,country\n1.0.0.1,US\n",
+			expectError: "no network columns found",
+		},
+		{
+			name:        "empty",
+			contents:    "",
+			expectError: "empty CSV file",
+		},
+		{
+			name:     "configured network columns",
+			contents: "ip,country\n1.0.0.1,US\n",
+			opts: Options{Network: []config.NetworkColumn{
+				{Name: "ip", Type: "cidr"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".csv")
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+			r, err := Open(path, tt.opts)
+			if tt.expectError != "" {
+				require.ErrorContains(t, err, tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+			defer r.Close()
+			// A bare address is not a CIDR
+			_, _, _, err = r.Read()
+			require.ErrorContains(t, err, "line 2")
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package export
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"net/netip"
+	"slices"
+
+	"github.com/maxmind/mmdbconvert/internal/mergefile"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// mergeFileReader reads a merge file, pulling its ranges from Each.
+type mergeFileReader struct {
+	input *mergefile.Reader
+	next  func() (mergeRange, error, bool)
+	stop  func()
+}
+
+type mergeRange struct {
+	start, end netip.Addr
+	data       row.Row
+}
+
+var errStopped = errors.New("stopped")
+
+func openMergeFile(path string) (*mergeFileReader, error) {
+	input, err := mergefile.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	ranges := func(yield func(mergeRange, error) bool) {
+		err := input.Each(func(start, end netip.Addr, data row.Row) error {
+			if !yield(mergeRange{start, end, data}, nil) {
+				return errStopped
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopped) {
+			yield(mergeRange{}, err)
+		}
+	}
+	next, stop := iter.Pull2(iter.Seq2[mergeRange, error](ranges))
+	return &mergeFileReader{input: input, next: next, stop: stop}, nil
+}
+
+// Columns returns the data column names.
+func (r *mergeFileReader) Columns() []string {
+	return slices.Clone(r.input.Header().Columns)
+}
+
+// Read returns the next range.
+func (r *mergeFileReader) Read() (start, end netip.Addr, data row.Row, err error) {
+	next, err, ok := r.next()
+	if !ok {
+		return start, end, nil, io.EOF
+	}
+	if err != nil {
+		return start, end, nil, err
+	}
+	return next.start, next.end, next.data, nil
+}
+
+// Close closes the file.
+func (r *mergeFileReader) Close() error {
+	r.stop()
+	return r.input.Close()
+}
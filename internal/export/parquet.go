@@ -0,0 +1,209 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// parquetBatchSize is the number of rows read from the file at once.
+const parquetBatchSize = 256
+
+// parquetReader reads a Parquet export, converting each value to the MMDB
+// type matching its column type.
+type parquetReader struct {
+	file   *os.File
+	r      *parquet.Reader
+	layout *layout
+	leaves []parquet.Node  // Node of each column
+	batch  []parquet.Row   // Rows read from the file
+	next   int             // Index of the next row in batch
+	values []parquet.Value // Values of the current row by column
+	row    row.Row
+	rowNum int64
+}
+
+func newParquetReader(file *os.File, opts Options) (*parquetReader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	schema := pf.Schema()
+	paths := schema.Columns()
+	columns := make([]string, len(paths))
+	leaves := make([]parquet.Node, len(paths))
+	// The columns of a group are read by their own name, which is unique
+	// in exports
+	names := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if len(path) > 2 {
+			return nil, fmt.Errorf("nested column '%s' is not supported", strings.Join(path, "."))
+		}
+		name := path[len(path)-1]
+		if names[name] {
+			return nil, fmt.Errorf("nested column '%s' has the name of another column", strings.Join(path, "."))
+		}
+		names[name] = true
+		leaf, _ := schema.Lookup(path...)
+		columns[leaf.ColumnIndex] = name
+		leaves[leaf.ColumnIndex] = leaf.Node
+	}
+
+	l, err := newLayout(columns, opts.Network)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetReader{
+		file:   file,
+		r:      parquet.NewReader(pf),
+		layout: l,
+		leaves: leaves,
+		values: make([]parquet.Value, len(columns)),
+		row:    make(row.Row, len(l.data)),
+	}, nil
+}
+
+// Columns returns the data column names.
+func (r *parquetReader) Columns() []string {
+	return r.layout.names
+}
+
+// Read returns the range and row of the next Parquet row.
+func (r *parquetReader) Read() (start, end netip.Addr, data row.Row, err error) {
+	if r.next == len(r.batch) {
+		if err := r.fill(); err != nil {
+			return start, end, nil, err
+		}
+	}
+	clear(r.values)
+	for _, v := range r.batch[r.next] {
+		if c := v.Column(); c >= 0 && c < len(r.values) {
+			r.values[c] = v
+		}
+	}
+	r.next++
+	r.rowNum++
+
+	start, end, err = r.layout.bounds(r.text, r.intAddr)
+	if err != nil {
+		return start, end, nil, fmt.Errorf("row %d: %w", r.rowNum, err)
+	}
+	for i, index := range r.layout.data {
+		if r.row[i], err = r.value(index); err != nil {
+			return start, end, nil, fmt.Errorf(
+				"row %d, column '%s': %w",
+				r.rowNum,
+				r.layout.names[i],
+				err,
+			)
+		}
+	}
+	return start, end, r.row, nil
+}
+
+// fill reads the next batch of rows.
+func (r *parquetReader) fill() error {
+	if r.batch == nil {
+		r.batch = make([]parquet.Row, parquetBatchSize)
+	}
+	r.batch = r.batch[:cap(r.batch)]
+	n, err := r.r.ReadRows(r.batch)
+	r.batch, r.next = r.batch[:n], 0
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return err
+}
+
+func (r *parquetReader) text(i int) (string, error) {
+	v := r.values[i]
+	if v.IsNull() {
+		return "", r.layout.empty(i)
+	}
+	if v.Kind() != parquet.ByteArray {
+		return "", fmt.Errorf("network column '%s' is not a string", r.layout.columns[i])
+	}
+	return string(v.ByteArray()), nil
+}
+
+// intAddr returns the address of a start_int or end_int column: an int64
+// for IPv4, or 16 big-endian bytes for IPv6.
+func (r *parquetReader) intAddr(i int) (netip.Addr, error) {
+	v := r.values[i]
+	switch {
+	case v.IsNull():
+		return netip.Addr{}, r.layout.empty(i)
+	case v.Kind() == parquet.Int64 && v.Int64() >= 0 && v.Int64() <= math.MaxUint32:
+		n := uint32(v.Int64())
+		return netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}), nil
+	case v.Kind() == parquet.FixedLenByteArray && len(v.ByteArray()) == 16:
+		return netip.AddrFrom16([16]byte(v.ByteArray())), nil
+	default:
+		return netip.Addr{}, fmt.Errorf(
+			"network column '%s' is not an IPv4 int64 or IPv6 16-byte integer",
+			r.layout.columns[i],
+		)
+	}
+}
+
+var errIntRange = errors.New("integer out of the range of MMDB types")
+
+// value converts column i to an MMDB type. Non-negative integers become
+// uint64 and negative ones int32, the MMDB signed type.
+func (r *parquetReader) value(i int) (mmdbtype.DataType, error) {
+	v := r.values[i]
+	if v.IsNull() {
+		return nil, nil
+	}
+	switch v.Kind() {
+	case parquet.Boolean:
+		return mmdbtype.Bool(v.Boolean()), nil
+	case parquet.Int32:
+		return mmdbtype.Int32(v.Int32()), nil
+	case parquet.Int64:
+		n := v.Int64()
+		if n >= 0 {
+			return mmdbtype.Uint64(n), nil
+		}
+		if n < math.MinInt32 {
+			return nil, errIntRange
+		}
+		return mmdbtype.Int32(n), nil
+	case parquet.Float:
+		return mmdbtype.Float32(v.Float()), nil
+	case parquet.Double:
+		return mmdbtype.Float64(v.Double()), nil
+	case parquet.ByteArray:
+		if lt := r.leaves[i].Type().LogicalType(); lt != nil && lt.UTF8 != nil {
+			return mmdbtype.String(v.ByteArray()), nil
+		}
+		return mmdbtype.Bytes(slices.Clone(v.ByteArray())), nil
+	case parquet.FixedLenByteArray:
+		return mmdbtype.Bytes(slices.Clone(v.ByteArray())), nil
+	default:
+		return nil, fmt.Errorf("unsupported Parquet type %s", v.Kind())
+	}
+}
+
+// Close closes the file.
+func (r *parquetReader) Close() error {
+	return errors.Join(r.r.Close(), r.file.Close())
+}
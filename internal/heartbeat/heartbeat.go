@@ -0,0 +1,249 @@
+// Package heartbeat keeps a small JSON status file up to date during a
+// conversion. The file is rewritten at a fixed interval, so its updated_at
+// shows that the process is alive, while last_progress_at only moves when
+// rows are written or the conversion enters a new stage. Orchestrators can
+// compare either against the current time to detect a hung run. During a
+// merge, the file also records the network being merged and where each
+// database is read, to see where a hung run is stuck.
+package heartbeat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// States of a conversion.
+const (
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// Status is the content of the status file. Times are RFC 3339 in UTC.
+type Status struct {
+	PID            int    `json:"pid"`
+	State          string `json:"state"`
+	Stage          string `json:"stage"`
+	Rows           int64  `json:"rows"`
+	StartedAt      string `json:"started_at"`
+	UpdatedAt      string `json:"updated_at"`
+	LastProgressAt string `json:"last_progress_at"`
+	Error          string `json:"error,omitempty"`
+	// Position is where the merge was at the last update, while one is
+	// tracked.
+	Position *Position `json:"position,omitempty"`
+}
+
+// Position is where a merge is in the address space.
+type Position struct {
+	Network   string             `json:"network"`  // Network being merged
+	Networks  int64              `json:"networks"` // Networks merged so far
+	Databases []DatabasePosition `json:"databases"`
+}
+
+// DatabasePosition is the network of the record at which a database is
+// read. Network is empty if the database is not read at the position.
+type DatabasePosition struct {
+	Name    string `json:"name"`
+	Network string `json:"network,omitempty"`
+}
+
+// Heartbeat rewrites the status file until it is stopped. All methods of a
+// nil *Heartbeat do nothing, so callers need not check whether a heartbeat
+// is configured.
+type Heartbeat struct {
+	path string
+	now  func() time.Time
+	rows atomic.Int64
+
+	mu           sync.Mutex // Guards the fields below and the file
+	status       Status
+	lastRows     int64
+	lastProgress time.Time
+	position     func() (Position, bool)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start writes the status file at path and rewrites it every interval until
+// Stop is called.
+func Start(path string, interval time.Duration) (*Heartbeat, error) {
+	return start(path, interval, time.Now)
+}
+
+func start(path string, interval time.Duration, now func() time.Time) (*Heartbeat, error) {
+	started := now()
+	h := &Heartbeat{
+		path: path,
+		now:  now,
+		status: Status{
+			PID:       os.Getpid(),
+			State:     StateRunning,
+			StartedAt: formatTime(started),
+		},
+		lastProgress: started,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	err := h.writeLocked()
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go h.loop(interval)
+	return h, nil
+}
+
+func (h *Heartbeat) loop(interval time.Duration) {
+	defer close(h.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.mu.Lock()
+			// A status file that cannot be written is reported by its age
+			_ = h.writeLocked()
+			h.mu.Unlock()
+		}
+	}
+}
+
+// SetStage records that the conversion has entered stage, which counts as
+// progress.
+func (h *Heartbeat) SetStage(stage string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.Stage = stage
+	h.lastProgress = h.now()
+	_ = h.writeLocked()
+}
+
+// TrackPosition records the position returned by position in every update,
+// until it is called again with nil. position reports false if it has no
+// position yet. It is called with the status file being written, so it must
+// not block.
+func (h *Heartbeat) TrackPosition(position func() (Position, bool)) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.position = position
+	h.status.Position = nil
+}
+
+// Stop stops the updates and writes the final state: succeeded if err is
+// nil, failed with err otherwise.
+func (h *Heartbeat) Stop(err error) error {
+	if h == nil {
+		return nil
+	}
+	close(h.stop)
+	<-h.done
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.State = StateSucceeded
+	if err != nil {
+		h.status.State = StateFailed
+		h.status.Error = err.Error()
+	}
+	return h.writeLocked()
+}
+
+// writeLocked writes the status file. It writes to a temporary file first
+// and renames it, so that readers never see a partial file.
+func (h *Heartbeat) writeLocked() error {
+	now := h.now()
+	if rows := h.rows.Load(); rows != h.lastRows {
+		h.lastRows = rows
+		h.lastProgress = now
+	}
+	h.status.Rows = h.lastRows
+	h.status.UpdatedAt = formatTime(now)
+	h.status.LastProgressAt = formatTime(h.lastProgress)
+	if h.position != nil {
+		if position, ok := h.position(); ok {
+			h.status.Position = &position
+		}
+	}
+
+	data, err := json.Marshal(h.status)
+	if err != nil {
+		return fmt.Errorf("encoding heartbeat: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), "."+filepath.Base(h.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing heartbeat: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing heartbeat: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing heartbeat: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing heartbeat: %w", err)
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Writer wraps w so that the rows written through it are counted as
+// progress. It returns w unchanged on a nil *Heartbeat.
+func (h *Heartbeat) Writer(w row.Writer) row.Writer {
+	if h == nil {
+		return w
+	}
+	return &countingWriter{w: w, rows: &h.rows}
+}
+
+// countingWriter counts the rows passed to the wrapped writer.
+type countingWriter struct {
+	w    row.Writer
+	rows *atomic.Int64
+}
+
+func (c *countingWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	c.rows.Add(1)
+	return c.w.WriteRow(prefix, r)
+}
+
+func (c *countingWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	c.rows.Add(1)
+	return row.WriteRange(c.w, start, end, r)
+}
+
+func (c *countingWriter) Flush() error {
+	return row.Flush(c.w)
+}
+
+func (c *countingWriter) Sync() error {
+	return row.Sync(c.w)
+}
@@ -0,0 +1,150 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+type nopWriter struct{}
+
+func (nopWriter) WriteRow(netip.Prefix, row.Row) error { return nil }
+
+func readStatus(t *testing.T, path string) Status {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var status Status
+	require.NoError(t, json.Unmarshal(data, &status))
+	return status
+}
+
+func TestHeartbeat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	// The interval is long enough that only explicit updates are written.
+	h, err := start(path, time.Hour, now)
+	require.NoError(t, err)
+
+	status := readStatus(t, path)
+	assert.Equal(t, os.Getpid(), status.PID)
+	assert.Equal(t, StateRunning, status.State)
+	assert.Equal(t, "2025-01-01T00:00:00Z", status.StartedAt)
+	assert.Equal(t, "2025-01-01T00:00:00Z", status.LastProgressAt)
+
+	clock = clock.Add(time.Minute)
+	h.SetStage("merge")
+	w := h.Writer(nopWriter{})
+	prefix := netip.MustParsePrefix("1.0.0.0/24")
+	require.NoError(t, w.WriteRow(prefix, row.Row{mmdbtype.String("AU")}))
+	require.NoError(t, row.WriteRange(
+		w,
+		netip.MustParseAddr("2.0.0.0"),
+		netip.MustParseAddr("2.0.1.255"),
+		row.Row{mmdbtype.String("FR")},
+	))
+
+	// Rows are picked up by the next update, which counts as progress.
+	clock = clock.Add(time.Minute)
+	h.SetStage("flush")
+	status = readStatus(t, path)
+	assert.Equal(t, "flush", status.Stage)
+	assert.Equal(t, int64(2), status.Rows)
+	assert.Equal(t, "2025-01-01T00:02:00Z", status.LastProgressAt)
+
+	// Updates without rows or a new stage leave last_progress_at alone.
+	clock = clock.Add(time.Minute)
+	h.mu.Lock()
+	require.NoError(t, h.writeLocked())
+	h.mu.Unlock()
+	status = readStatus(t, path)
+	assert.Equal(t, "2025-01-01T00:03:00Z", status.UpdatedAt)
+	assert.Equal(t, "2025-01-01T00:02:00Z", status.LastProgressAt)
+
+	require.NoError(t, h.Stop(nil))
+	status = readStatus(t, path)
+	assert.Equal(t, StateSucceeded, status.State)
+	assert.Empty(t, status.Error)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are renamed into place")
+}
+
+func TestHeartbeat_TrackPosition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	h, err := Start(path, time.Hour)
+	require.NoError(t, err)
+	defer h.Stop(nil)
+
+	position := Position{
+		Network:  "81.2.69.128/25",
+		Networks: 31,
+		Databases: []DatabasePosition{
+			{Name: "city", Network: "81.2.69.0/24"},
+			{Name: "anonymous"},
+		},
+	}
+	taken := false
+	h.TrackPosition(func() (Position, bool) { return position, taken })
+	h.SetStage("merge")
+	assert.Nil(t, readStatus(t, path).Position, "no position is taken yet")
+
+	taken = true
+	h.SetStage("merge")
+	assert.Equal(t, &position, readStatus(t, path).Position)
+
+	h.TrackPosition(nil)
+	h.SetStage("flush")
+	assert.Nil(t, readStatus(t, path).Position)
+}
+
+func TestHeartbeat_Failed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	h, err := Start(path, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, h.Stop(errors.New("merging databases: boom")))
+	status := readStatus(t, path)
+	assert.Equal(t, StateFailed, status.State)
+	assert.Equal(t, "merging databases: boom", status.Error)
+}
+
+func TestHeartbeat_Ticks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	h, err := Start(path, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer h.Stop(nil)
+
+	_ = h.Writer(nopWriter{}).WriteRow(netip.MustParsePrefix("1.0.0.0/24"), nil)
+	assert.Eventually(t, func() bool {
+		return readStatus(t, path).Rows == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHeartbeat_Nil(t *testing.T) {
+	var h *Heartbeat
+	h.SetStage("merge")
+	w := nopWriter{}
+	assert.Equal(t, row.Writer(w), h.Writer(w))
+	assert.NoError(t, h.Stop(nil))
+}
+
+func TestStart_Error(t *testing.T) {
+	_, err := Start(filepath.Join(t.TempDir(), "missing", "status.json"), time.Hour)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "writing heartbeat")
+}
@@ -0,0 +1,293 @@
+// Package history produces time-sliced exports from several builds of one
+// database. Each build is merged with the other databases as usual, and
+// successive merges are compared so that every output row carries the
+// interval in which it was valid: from the build that introduced it until
+// the build that changed or removed it.
+package history
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// Build is one build of the database with history.
+type Build struct {
+	Path string
+	Time time.Time // Build time from the database metadata
+}
+
+// Builds returns the builds of db, its path and every file matching its
+// history patterns, ordered by build time.
+func Builds(db config.Database) ([]Build, error) {
+	paths := []string{filepath.Clean(db.Path)}
+	for _, pattern := range db.History {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("expanding history pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("history pattern %q matches no files", pattern)
+		}
+		for _, match := range matches {
+			paths = append(paths, filepath.Clean(match))
+		}
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	builds := make([]Build, 0, len(paths))
+	for _, path := range paths {
+		reader, err := mmdb.Open(config.Database{Name: db.Name, Path: path})
+		if err != nil {
+			return nil, err
+		}
+		epoch := reader.Metadata().BuildEpoch
+		reader.Close()
+
+		// #nosec G115 -- build epochs are Unix times well within int64
+		builds = append(builds, Build{Path: path, Time: time.Unix(int64(epoch), 0).UTC()})
+	}
+
+	slices.SortFunc(builds, func(a, b Build) int {
+		return a.Time.Compare(b.Time)
+	})
+	for i := 1; i < len(builds); i++ {
+		if builds[i].Time.Equal(builds[i-1].Time) {
+			return nil, fmt.Errorf(
+				"builds %s and %s have the same build time %s",
+				builds[i-1].Path,
+				builds[i].Path,
+				builds[i].Time.Format(time.RFC3339),
+			)
+		}
+	}
+	return builds, nil
+}
+
+// Run merges every build in turn, in place of the path of the database with
+// history, and writes the time-sliced rows to w. Rows are written in
+// ascending order of their first address, so rows for the same addresses
+// in different intervals follow each other. progress, if not nil, is called
+// before each build is merged.
+func Run(cfg *config.Config, builds []Build, w row.Writer, progress func(Build)) error {
+	db, ok := cfg.HistoryDatabase()
+	if !ok {
+		return fmt.Errorf("no database has history")
+	}
+
+	var tracker Tracker
+	for _, build := range builds {
+		if progress != nil {
+			progress(build)
+		}
+		ranges, err := mergeBuild(cfg, db.Name, build)
+		if err != nil {
+			return fmt.Errorf("merging build %s: %w", build.Path, err)
+		}
+		tracker.Add(build.Time, ranges)
+	}
+
+	for _, span := range tracker.Spans() {
+		if err := row.WriteRange(
+			w,
+			span.Start,
+			span.End,
+			span.Data.WithValidity(span.From, span.To),
+		); err != nil {
+			return fmt.Errorf("writing range %s-%s: %w", span.Start, span.End, err)
+		}
+	}
+	return nil
+}
+
+// mergeBuild merges the configured databases with build in place of the
+// database named name, and returns the merged ranges.
+func mergeBuild(cfg *config.Config, name string, build Build) ([]Span, error) {
+	buildCfg := *cfg
+	buildCfg.Databases = slices.Clone(cfg.Databases)
+	databases := make(map[string]config.Database, len(buildCfg.Databases))
+	for i := range buildCfg.Databases {
+		if buildCfg.Databases[i].Name == name {
+			buildCfg.Databases[i].Path = build.Path
+		}
+		databases[buildCfg.Databases[i].Name] = buildCfg.Databases[i]
+	}
+
+	readers, err := mmdb.OpenDatabases(databases)
+	if err != nil {
+		return nil, fmt.Errorf("opening databases: %w", err)
+	}
+	defer readers.Close()
+
+	collector := &collector{}
+	m, err := merger.NewMerger(readers, &buildCfg, collector)
+	if err != nil {
+		return nil, fmt.Errorf("creating merger: %w", err)
+	}
+	if err := m.Merge(); err != nil {
+		return nil, err
+	}
+	return collector.ranges, nil
+}
+
+// collector keeps the merged ranges of one build.
+type collector struct {
+	ranges []Span
+}
+
+func (c *collector) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return c.WriteRange(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+func (c *collector) WriteRange(start, end netip.Addr, r row.Row) error {
+	// The merger reuses its rows
+	c.ranges = append(c.ranges, Span{Start: start, End: end, Data: slices.Clone(r)})
+	return nil
+}
+
+// Span is an address range with its data and the interval in which the data
+// was valid.
+type Span struct {
+	Start netip.Addr
+	End   netip.Addr
+	Data  row.Row
+	From  time.Time
+	To    time.Time // Zero while the data is still valid
+}
+
+// Tracker follows the merged ranges of successive builds and records how
+// long each row stayed unchanged.
+type Tracker struct {
+	open   []Span // Rows valid in the latest build, in address order
+	closed []Span // Rows that have been changed or removed
+}
+
+// Add records the merged ranges of the build made at t. ranges must be in
+// address order and must not overlap, and builds must be added in time
+// order. Rows that are unchanged since the previous build keep their start
+// of validity; parts of rows that changed are closed at t.
+func (tr *Tracker) Add(t time.Time, ranges []Span) {
+	var next []Span
+	open := tr.open
+	i, j := 0, 0
+	var prev, cur Span
+	if len(open) > 0 {
+		prev = open[0]
+	}
+	if len(ranges) > 0 {
+		cur = ranges[0]
+	}
+
+	for i < len(open) && j < len(ranges) {
+		switch {
+		case prev.End.Less(cur.Start):
+			tr.close(prev, t)
+			if i++; i < len(open) {
+				prev = open[i]
+			}
+		case cur.End.Less(prev.Start):
+			next = appendSpan(next, Span{Start: cur.Start, End: cur.End, Data: cur.Data, From: t})
+			if j++; j < len(ranges) {
+				cur = ranges[j]
+			}
+		case prev.Start.Less(cur.Start):
+			before := prev
+			before.End = cur.Start.Prev()
+			tr.close(before, t)
+			prev.Start = cur.Start
+		case cur.Start.Less(prev.Start):
+			next = appendSpan(next, Span{
+				Start: cur.Start,
+				End:   prev.Start.Prev(),
+				Data:  cur.Data,
+				From:  t,
+			})
+			cur.Start = prev.Start
+		default:
+			end := prev.End
+			if cur.End.Less(end) {
+				end = cur.End
+			}
+			if prev.Data.Equal(cur.Data) {
+				next = appendSpan(next, Span{Start: prev.Start, End: end, Data: prev.Data, From: prev.From})
+			} else {
+				changed := prev
+				changed.End = end
+				tr.close(changed, t)
+				next = appendSpan(next, Span{Start: cur.Start, End: end, Data: cur.Data, From: t})
+			}
+
+			if end == prev.End {
+				if i++; i < len(open) {
+					prev = open[i]
+				}
+			} else {
+				prev.Start = end.Next()
+			}
+			if end == cur.End {
+				if j++; j < len(ranges) {
+					cur = ranges[j]
+				}
+			} else {
+				cur.Start = end.Next()
+			}
+		}
+	}
+	// Whatever remains of either side has no counterpart in the other.
+	for i < len(open) {
+		tr.close(prev, t)
+		if i++; i < len(open) {
+			prev = open[i]
+		}
+	}
+	for j < len(ranges) {
+		next = appendSpan(next, Span{Start: cur.Start, End: cur.End, Data: cur.Data, From: t})
+		if j++; j < len(ranges) {
+			cur = ranges[j]
+		}
+	}
+	tr.open = next
+}
+
+// close records that span stopped being valid at t.
+func (tr *Tracker) close(span Span, t time.Time) {
+	span.To = t
+	tr.closed = appendSpan(tr.closed, span)
+}
+
+// Spans returns every row seen so far with its interval of validity,
+// ordered by first address and then by start of validity.
+func (tr *Tracker) Spans() []Span {
+	spans := slices.Concat(tr.closed, tr.open)
+	slices.SortStableFunc(spans, func(a, b Span) int {
+		return cmp.Or(a.Start.Compare(b.Start), a.From.Compare(b.From))
+	})
+	return spans
+}
+
+// appendSpan appends span, extending the last span instead when span
+// continues it with the same data and interval.
+func appendSpan(spans []Span, span Span) []Span {
+	if n := len(spans); n > 0 {
+		last := &spans[n-1]
+		if last.End.Next() == span.Start &&
+			last.From.Equal(span.From) &&
+			last.To.Equal(span.To) &&
+			last.Data.Equal(span.Data) {
+			last.End = span.End
+			return spans
+		}
+	}
+	return append(spans, span)
+}
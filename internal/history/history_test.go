@@ -0,0 +1,242 @@
+package history
+
+import (
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+var (
+	jan = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb = time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func span(start, end, value string) Span {
+	return Span{
+		Start: netip.MustParseAddr(start),
+		End:   netip.MustParseAddr(end),
+		Data:  row.Row{mmdbtype.String(value)},
+	}
+}
+
+func valid(s Span, from, to time.Time) Span {
+	s.From = from
+	s.To = to
+	return s
+}
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name   string
+		builds [][]Span
+		want   []Span
+	}{
+		{
+			name: "unchanged",
+			builds: [][]Span{
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+			},
+			want: []Span{
+				valid(span("1.0.0.0", "1.0.0.255", "AU"), jan, time.Time{}),
+			},
+		},
+		{
+			name: "changed",
+			builds: [][]Span{
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+				{span("1.0.0.0", "1.0.0.255", "NZ")},
+			},
+			want: []Span{
+				valid(span("1.0.0.0", "1.0.0.255", "AU"), jan, feb),
+				valid(span("1.0.0.0", "1.0.0.255", "NZ"), feb, time.Time{}),
+			},
+		},
+		{
+			name: "added and removed",
+			builds: [][]Span{
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+				{span("2.0.0.0", "2.0.0.255", "FR")},
+			},
+			want: []Span{
+				valid(span("1.0.0.0", "1.0.0.255", "AU"), jan, feb),
+				valid(span("2.0.0.0", "2.0.0.255", "FR"), feb, time.Time{}),
+			},
+		},
+		{
+			name: "split range",
+			builds: [][]Span{
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+				{
+					span("1.0.0.0", "1.0.0.127", "AU"),
+					span("1.0.0.128", "1.0.0.255", "NZ"),
+				},
+			},
+			want: []Span{
+				valid(span("1.0.0.0", "1.0.0.127", "AU"), jan, time.Time{}),
+				valid(span("1.0.0.128", "1.0.0.255", "AU"), jan, feb),
+				valid(span("1.0.0.128", "1.0.0.255", "NZ"), feb, time.Time{}),
+			},
+		},
+		{
+			name: "partial overlap",
+			builds: [][]Span{
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+				{span("1.0.0.128", "1.0.1.127", "AU")},
+			},
+			want: []Span{
+				valid(span("1.0.0.0", "1.0.0.127", "AU"), jan, feb),
+				valid(span("1.0.0.128", "1.0.0.255", "AU"), jan, time.Time{}),
+				valid(span("1.0.1.0", "1.0.1.127", "AU"), feb, time.Time{}),
+			},
+		},
+		{
+			name: "changed and changed back",
+			builds: [][]Span{
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+				{span("1.0.0.0", "1.0.0.255", "NZ")},
+				{span("1.0.0.0", "1.0.0.255", "AU")},
+			},
+			want: []Span{
+				valid(span("1.0.0.0", "1.0.0.255", "AU"), jan, feb),
+				valid(span("1.0.0.0", "1.0.0.255", "NZ"), feb, mar),
+				valid(span("1.0.0.0", "1.0.0.255", "AU"), mar, time.Time{}),
+			},
+		},
+		{
+			name: "ranges across address families",
+			builds: [][]Span{
+				{
+					span("1.0.0.0", "1.0.0.255", "AU"),
+					span("2001:db8::", "2001:db8::ffff", "DE"),
+				},
+				{span("2001:db8::", "2001:db8::ffff", "DE")},
+			},
+			want: []Span{
+				valid(span("1.0.0.0", "1.0.0.255", "AU"), jan, feb),
+				valid(span("2001:db8::", "2001:db8::ffff", "DE"), jan, time.Time{}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracker Tracker
+			for i, ranges := range tt.builds {
+				tracker.Add(jan.AddDate(0, i, 0), ranges)
+			}
+			assert.Equal(t, tt.want, tracker.Spans())
+		})
+	}
+}
+
+// rangesWriter records the ranges written to it as text.
+type rangesWriter struct {
+	ranges []string
+}
+
+func (w *rangesWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.WriteRange(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+func (w *rangesWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	values := make([]string, len(r))
+	for i := range r {
+		text, err := r.Text(i)
+		if err != nil {
+			return err
+		}
+		values[i] = text
+	}
+	w.ranges = append(w.ranges, start.String()+"-"+end.String()+" "+strings.Join(values, "\t"))
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeBuild(t, filepath.Join(dir, "city-2025-02.mmdb"), feb, map[string]string{
+		"1.0.0.0/24": "NZ",
+		"2.0.0.0/24": "FR",
+	})
+	writeBuild(t, filepath.Join(dir, "city-2025-01.mmdb"), jan, map[string]string{
+		"1.0.0.0/24": "AU",
+		"2.0.0.0/24": "FR",
+	})
+
+	db := config.Database{
+		Name:    "city",
+		Path:    filepath.Join(dir, "city-2025-02.mmdb"),
+		History: []string{filepath.Join(dir, "city-*.mmdb")},
+	}
+	builds, err := Builds(db)
+	require.NoError(t, err)
+	assert.Equal(t, []Build{
+		{Path: filepath.Join(dir, "city-2025-01.mmdb"), Time: jan},
+		{Path: filepath.Join(dir, "city-2025-02.mmdb"), Time: feb},
+	}, builds)
+
+	cfg := &config.Config{
+		Databases: []config.Database{db},
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country"}},
+		},
+	}
+	w := &rangesWriter{}
+	var merged []string
+	require.NoError(t, Run(cfg, builds, w, func(b Build) {
+		merged = append(merged, filepath.Base(b.Path))
+	}))
+
+	assert.Equal(t, []string{"city-2025-01.mmdb", "city-2025-02.mmdb"}, merged)
+	assert.Equal(t, []string{
+		"1.0.0.0-1.0.0.255 AU\t2025-01-01T00:00:00Z\t2025-02-01T00:00:00Z",
+		"1.0.0.0-1.0.0.255 NZ\t2025-02-01T00:00:00Z\t",
+		"2.0.0.0-2.0.0.255 FR\t2025-01-01T00:00:00Z\t",
+	}, w.ranges)
+}
+
+func TestBuilds_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeBuild(t, filepath.Join(dir, "a.mmdb"), jan, map[string]string{"1.0.0.0/24": "AU"})
+	writeBuild(t, filepath.Join(dir, "b.mmdb"), jan, map[string]string{"1.0.0.0/24": "NZ"})
+
+	_, err := Builds(config.Database{
+		Name:    "city",
+		Path:    filepath.Join(dir, "a.mmdb"),
+		History: []string{filepath.Join(dir, "*.mmdb")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "have the same build time")
+
+	_, err = Builds(config.Database{
+		Name:    "city",
+		Path:    filepath.Join(dir, "a.mmdb"),
+		History: []string{filepath.Join(dir, "missing-*.mmdb")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "matches no files")
+}
+
+func writeBuild(t *testing.T, path string, built time.Time, countries map[string]string) {
+	t.Helper()
+
+	records := make(map[string]mmdbtype.Map, len(countries))
+	for cidr, country := range countries {
+		records[cidr] = mmdbtype.Map{"country": mmdbtype.String(country)}
+	}
+	opts := mmdbtest.Options()
+	opts.BuildEpoch = built.Unix()
+	mmdbtest.Write(t, path, opts, records)
+}
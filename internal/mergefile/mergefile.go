@@ -0,0 +1,154 @@
+// Package mergefile reads and writes merge files, which capture the result
+// of a completed merge so that it can be exported to other formats without
+// merging the databases again.
+//
+// A merge file starts with a magic string, a version byte, and a JSON header
+// naming the data columns. Records follow: a data record holds the values of
+// a distinct row, numbered in order of appearance, and a range record holds
+// the first and last address of a range and the number of its row, so that
+// the values shared by many ranges are stored once. A fixed-size footer
+// counts the records and marks the file as complete; a file without one was
+// left behind by a failed merge and is rejected.
+package mergefile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/provenance"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// Magic starts every merge file.
+const Magic = "MMDBCMRG"
+
+const (
+	version = 1
+
+	recordData  byte = 'D'
+	recordRange byte = 'R'
+	recordEnd   byte = 'E'
+
+	flagIPv6Networks byte = 1 << 0
+
+	// footerSize is the size of the end record: the record type, the range
+	// and row counts, the flags, and the magic string.
+	footerSize = 1 + 8 + 8 + 1 + len(Magic)
+
+	// maxHeaderSize bounds the header read from a corrupt file.
+	maxHeaderSize = 16 << 20
+)
+
+// Header describes the contents of a merge file.
+type Header struct {
+	Columns     []string            `json:"columns"`    // Data column names, in row order
+	IPVersion   int                 `json:"ip_version"` // 6 if some source database is an IPv6 tree
+	ToolVersion string              `json:"tool_version,omitempty"`
+	Sources     []provenance.Source `json:"sources,omitempty"`
+}
+
+// Writer writes a merge file. It is a row.Writer, so that it can receive the
+// rows of a merge, and must be flushed to complete the file.
+type Writer struct {
+	w       *bufio.Writer
+	columns int
+	rows    map[string]uint64 // Encoded row to its number
+	ranges  uint64
+	flags   byte
+	buf     []byte
+	done    bool
+}
+
+// NewWriter writes the start of a merge file with header to w.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("encoding merge file header: %w", err)
+	}
+	bw := bufio.NewWriter(w)
+	b := append([]byte(Magic), version)
+	b = binary.AppendUvarint(b, uint64(len(encoded)))
+	b = append(b, encoded...)
+	if _, err := bw.Write(b); err != nil {
+		return nil, fmt.Errorf("writing merge file header: %w", err)
+	}
+	return &Writer{
+		w:       bw,
+		columns: len(header.Columns),
+		rows:    map[string]uint64{},
+	}, nil
+}
+
+// WriteRow writes the range of prefix.
+func (w *Writer) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.WriteRange(prefix.Masked().Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WriteRange writes a range, and the values of r unless an earlier range
+// had the same ones.
+func (w *Writer) WriteRange(start, end netip.Addr, r row.Row) error {
+	if w.done {
+		return errors.New("merge file already flushed")
+	}
+	if len(r) != w.columns {
+		return fmt.Errorf("row has %d values, expected %d", len(r), w.columns)
+	}
+
+	var err error
+	w.buf = w.buf[:0]
+	for _, value := range r {
+		if w.buf, err = appendValue(w.buf, value); err != nil {
+			return fmt.Errorf("encoding row for %s-%s: %w", start, end, err)
+		}
+	}
+	id, ok := w.rows[string(w.buf)]
+	if !ok {
+		id = uint64(len(w.rows))
+		w.rows[string(w.buf)] = id
+		record := binary.AppendUvarint([]byte{recordData}, uint64(len(w.buf)))
+		if _, err := w.w.Write(record); err != nil {
+			return err
+		}
+		if _, err := w.w.Write(w.buf); err != nil {
+			return err
+		}
+	}
+
+	record := append([]byte{recordRange}, byte(start.BitLen()/8))
+	record = append(record, start.AsSlice()...)
+	record = append(record, end.AsSlice()...)
+	record = binary.AppendUvarint(record, id)
+	if _, err := w.w.Write(record); err != nil {
+		return err
+	}
+	w.ranges++
+	if start.Is6() && !start.Is4In6() {
+		w.flags |= flagIPv6Networks
+	}
+	return nil
+}
+
+// Flush writes the footer completing the file. Nothing can be written
+// afterwards.
+func (w *Writer) Flush() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	footer := []byte{recordEnd}
+	footer = binary.BigEndian.AppendUint64(footer, w.ranges)
+	footer = binary.BigEndian.AppendUint64(footer, uint64(len(w.rows)))
+	footer = append(footer, w.flags)
+	footer = append(footer, Magic...)
+	if _, err := w.w.Write(footer); err != nil {
+		return fmt.Errorf("writing merge file footer: %w", err)
+	}
+	return w.w.Flush()
+}
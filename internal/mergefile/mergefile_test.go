@@ -0,0 +1,211 @@
+package mergefile
+
+import (
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/provenance"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+type writtenRange struct {
+	start, end string
+	data       row.Row
+}
+
+// rangeRecorder records the ranges written to it.
+type rangeRecorder struct {
+	ranges []writtenRange
+}
+
+func (r *rangeRecorder) WriteRow(prefix netip.Prefix, data row.Row) error {
+	return r.WriteRange(prefix.Addr(), prefix.Addr(), data)
+}
+
+func (r *rangeRecorder) WriteRange(start, end netip.Addr, data row.Row) error {
+	r.ranges = append(r.ranges, writtenRange{start.String(), end.String(), data})
+	return nil
+}
+
+func writeFile(t *testing.T, header Header, write func(w *Writer)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "merge.bin")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w, err := NewWriter(f, header)
+	require.NoError(t, err)
+	write(w)
+	require.NoError(t, w.Flush())
+	return path
+}
+
+func TestRoundTrip(t *testing.T) {
+	header := Header{
+		Columns:     []string{"country", "city", "is_anonymous"},
+		IPVersion:   6,
+		ToolVersion: "1.2.3",
+		Sources:     []provenance.Source{{Name: "city", DatabaseType: "GeoIP2-City", BuildEpoch: 1}},
+	}
+	us := row.Row{mmdbtype.String("US"), mmdbtype.String("Boston"), mmdbtype.Bool(false)}
+	de := row.Row{mmdbtype.String("DE"), nil, mmdbtype.Bool(true)}
+
+	path := writeFile(t, header, func(w *Writer) {
+		require.NoError(t, w.WriteRange(
+			netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.255"), us,
+		))
+		require.NoError(t, w.WriteRow(netip.MustParsePrefix("2.0.0.0/24"), de))
+		require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), us))
+	})
+
+	r, err := Open(path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.Equal(t, header, r.Header())
+	assert.Equal(t, uint64(3), r.Ranges())
+	assert.Equal(t, uint64(2), r.Rows(), "equal rows are stored once")
+	assert.True(t, r.HasIPv6Networks())
+
+	rec := &rangeRecorder{}
+	require.NoError(t, r.Each(func(start, end netip.Addr, data row.Row) error {
+		return rec.WriteRange(start, end, data)
+	}))
+	assert.Equal(t, []writtenRange{
+		{"1.0.0.0", "1.0.0.255", us},
+		{"2.0.0.0", "2.0.0.255", de},
+		{"2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", us},
+	}, rec.ranges)
+}
+
+func TestValues(t *testing.T) {
+	values := row.Row{
+		nil,
+		mmdbtype.String("München"),
+		mmdbtype.Bytes{0, 1, 2},
+		mmdbtype.Bool(true),
+		mmdbtype.Bool(false),
+		mmdbtype.Uint16(65535),
+		mmdbtype.Uint32(4294967295),
+		mmdbtype.Uint64(18446744073709551615),
+		(*mmdbtype.Uint128)(new(big.Int).Lsh(big.NewInt(1), 100)),
+		mmdbtype.Int32(-42),
+		mmdbtype.Float32(1.5),
+		mmdbtype.Float64(-37.751),
+		mmdbtype.Map{
+			"names": mmdbtype.Map{"en": mmdbtype.String("Munich")},
+			"codes": mmdbtype.Slice{mmdbtype.String("MUC"), mmdbtype.Uint32(1)},
+		},
+	}
+	columns := make([]string, len(values))
+	for i := range columns {
+		columns[i] = string(rune('a' + i))
+	}
+
+	path := writeFile(t, Header{Columns: columns, IPVersion: 4}, func(w *Writer) {
+		require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), values))
+	})
+	r, err := Open(path)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.False(t, r.HasIPv6Networks())
+
+	require.NoError(t, r.Each(func(_, _ netip.Addr, data row.Row) error {
+		assert.Equal(t, values, data)
+		return nil
+	}))
+}
+
+func TestExport(t *testing.T) {
+	header := Header{Columns: []string{"country", "city"}, IPVersion: 4}
+	path := writeFile(t, header, func(w *Writer) {
+		for _, r := range []struct {
+			prefix string
+			data   row.Row
+		}{
+			{"1.0.0.0/24", row.Row{mmdbtype.String("US"), mmdbtype.String("Boston")}},
+			{"1.0.1.0/24", row.Row{mmdbtype.String("US"), mmdbtype.String("Denver")}},
+			{"1.0.3.0/24", row.Row{mmdbtype.String("US"), mmdbtype.String("Austin")}},
+			{"1.0.4.0/24", row.Row{nil, mmdbtype.String("Nowhere")}},
+		} {
+			require.NoError(t, w.WriteRow(netip.MustParsePrefix(r.prefix), r.data))
+		}
+	})
+	r, err := Open(path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	rec := &rangeRecorder{}
+	require.NoError(t, r.Export(rec, []string{"country"}, false))
+	assert.Equal(t, []writtenRange{
+		// Adjacent ranges are joined, but not across the gap at 1.0.2.0
+		{"1.0.0.0", "1.0.1.255", row.Row{mmdbtype.String("US")}},
+		{"1.0.3.0", "1.0.3.255", row.Row{mmdbtype.String("US")}},
+	}, rec.ranges)
+
+	rec = &rangeRecorder{}
+	require.NoError(t, r.Export(rec, []string{"country"}, true))
+	assert.Len(t, rec.ranges, 3)
+	assert.Equal(t, writtenRange{"1.0.4.0", "1.0.4.255", row.Row{nil}}, rec.ranges[2])
+
+	rec = &rangeRecorder{}
+	require.NoError(t, r.Export(rec, []string{"city", "country"}, false))
+	assert.Len(t, rec.ranges, 4)
+	assert.Equal(
+		t,
+		row.Row{mmdbtype.String("Boston"), mmdbtype.String("US")},
+		rec.ranges[0].data,
+	)
+
+	err = r.Export(&rangeRecorder{}, []string{"asn"}, false)
+	require.EqualError(t, err, "column 'asn' is not in the merge file")
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	valid := writeFile(t, Header{Columns: []string{"a"}, IPVersion: 4}, func(w *Writer) {
+		require.NoError(t, w.WriteRow(
+			netip.MustParsePrefix("1.0.0.0/24"),
+			row.Row{mmdbtype.String("x")},
+		))
+	})
+	contents, err := os.ReadFile(valid)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		contents    []byte
+		expectError string
+	}{
+		{"not a merge file", []byte("a,b,c\n"), "not a merge file"},
+		{"truncated", contents[:len(contents)-footerSize], "incomplete merge file"},
+		{
+			"unsupported version",
+			append([]byte(Magic+"\x09"), contents[len(Magic)+1:]...),
+			"unsupported merge file version 9",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "merge.bin")
+			require.NoError(t, os.WriteFile(path, tt.contents, 0o600))
+			_, err := Open(path)
+			require.ErrorContains(t, err, tt.expectError)
+		})
+	}
+}
+
+func TestWriter_ColumnCount(t *testing.T) {
+	w, err := NewWriter(io.Discard, Header{Columns: []string{"a", "b"}})
+	require.NoError(t, err)
+	err = w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{nil})
+	require.EqualError(t, err, "row has 1 values, expected 2")
+}
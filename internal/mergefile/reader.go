@@ -0,0 +1,255 @@
+package mergefile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+var errIncomplete = errors.New("incomplete merge file: the merge writing it did not finish")
+
+// Reader reads a merge file.
+type Reader struct {
+	file      *os.File
+	header    Header
+	bodyStart int64
+	bodyEnd   int64
+	ranges    uint64
+	rows      uint64
+	flags     byte
+}
+
+// Open opens the merge file at path and reads its header and footer.
+func Open(path string) (*Reader, error) {
+	// #nosec G304 -- path comes from a trusted command-line argument
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func newReader(file *os.File) (*Reader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	br := bufio.NewReader(file)
+	start := make([]byte, len(Magic)+1)
+	if _, err := io.ReadFull(br, start); err != nil || string(start[:len(Magic)]) != Magic {
+		return nil, errors.New("not a merge file")
+	}
+	if start[len(Magic)] != version {
+		return nil, fmt.Errorf("unsupported merge file version %d", start[len(Magic)])
+	}
+	headerSize, err := binary.ReadUvarint(br)
+	if err != nil || headerSize > maxHeaderSize {
+		return nil, errCorrupt
+	}
+	encoded := make([]byte, headerSize)
+	if _, err := io.ReadFull(br, encoded); err != nil {
+		return nil, errCorrupt
+	}
+	r := &Reader{file: file}
+	if err := json.Unmarshal(encoded, &r.header); err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	r.bodyStart = int64(len(start) + len(binary.AppendUvarint(nil, headerSize)) + len(encoded))
+	r.bodyEnd = size - int64(footerSize)
+
+	footer := make([]byte, footerSize)
+	if r.bodyEnd < r.bodyStart {
+		return nil, errIncomplete
+	}
+	if _, err := file.ReadAt(footer, r.bodyEnd); err != nil {
+		return nil, err
+	}
+	if footer[0] != recordEnd || string(footer[footerSize-len(Magic):]) != Magic {
+		return nil, errIncomplete
+	}
+	r.ranges = binary.BigEndian.Uint64(footer[1:])
+	r.rows = binary.BigEndian.Uint64(footer[9:])
+	r.flags = footer[17]
+	return r, nil
+}
+
+// Close closes the file.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// Header returns the header of the file.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Ranges returns the number of ranges in the file.
+func (r *Reader) Ranges() uint64 {
+	return r.ranges
+}
+
+// Rows returns the number of distinct rows in the file.
+func (r *Reader) Rows() uint64 {
+	return r.rows
+}
+
+// HasIPv6Networks reports whether some range is outside the IPv4 space.
+func (r *Reader) HasIPv6Networks() bool {
+	return r.flags&flagIPv6Networks != 0
+}
+
+// Each calls fn with every range in the order it was written. The row passed
+// to fn is shared by every range with the same values and must not be
+// modified.
+func (r *Reader) Each(fn func(start, end netip.Addr, data row.Row) error) error {
+	br := bufio.NewReader(io.NewSectionReader(r.file, r.bodyStart, r.bodyEnd-r.bodyStart))
+	var (
+		rows   []row.Row
+		ranges uint64
+		buf    []byte
+	)
+	for {
+		kind, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case recordData:
+			size, err := binary.ReadUvarint(br)
+			if err != nil || size > uint64(r.bodyEnd) || uint64(len(rows)) >= r.rows {
+				return errCorrupt
+			}
+			buf = slices.Grow(buf[:0], int(size))[:size]
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return errCorrupt
+			}
+			data, err := decodeRow(buf, len(r.header.Columns))
+			if err != nil {
+				return err
+			}
+			rows = append(rows, data)
+		case recordRange:
+			start, end, id, err := readRange(br)
+			if err != nil {
+				return err
+			}
+			if id >= uint64(len(rows)) {
+				return errCorrupt
+			}
+			ranges++
+			if err := fn(start, end, rows[id]); err != nil {
+				return err
+			}
+		default:
+			return errCorrupt
+		}
+	}
+	if ranges != r.ranges || uint64(len(rows)) != r.rows {
+		return errCorrupt
+	}
+	return nil
+}
+
+// Export writes the ranges to w with the values of the data columns named
+// columns, in that order. Adjacent ranges with equal values are written as
+// one, and ranges with no values are left out unless includeEmpty.
+func (r *Reader) Export(w row.Writer, columns []string, includeEmpty bool) error {
+	indexes := make([]int, len(columns))
+	for i, name := range columns {
+		indexes[i] = slices.Index(r.header.Columns, name)
+		if indexes[i] < 0 {
+			return fmt.Errorf("column '%s' is not in the merge file", name)
+		}
+	}
+
+	var (
+		pending                  row.Row
+		pendingStart, pendingEnd netip.Addr
+	)
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		if !includeEmpty && pending.IsEmpty() {
+			return nil
+		}
+		return row.WriteRange(w, pendingStart, pendingEnd, pending)
+	}
+
+	err := r.Each(func(start, end netip.Addr, data row.Row) error {
+		projected := make(row.Row, len(indexes))
+		for i, index := range indexes {
+			projected[i] = data[index]
+		}
+		if pending != nil && pendingEnd.Next() == start && pending.Equal(projected) {
+			pendingEnd = end
+			return nil
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		pending, pendingStart, pendingEnd = projected, start, end
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func decodeRow(b []byte, columns int) (row.Row, error) {
+	d := decoder{b: bytes.Clone(b)}
+	data := make(row.Row, columns)
+	for i := range data {
+		value, err := d.value(0)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = value
+	}
+	if len(d.b) != 0 {
+		return nil, errCorrupt
+	}
+	return data, nil
+}
+
+func readRange(br *bufio.Reader) (start, end netip.Addr, id uint64, err error) {
+	size, err := br.ReadByte()
+	if err != nil || (size != 4 && size != 16) {
+		return start, end, 0, errCorrupt
+	}
+	addrs := make([]byte, 2*int(size))
+	if _, err := io.ReadFull(br, addrs); err != nil {
+		return start, end, 0, errCorrupt
+	}
+	start, _ = netip.AddrFromSlice(addrs[:size])
+	end, _ = netip.AddrFromSlice(addrs[size:])
+	if end.Less(start) {
+		return start, end, 0, errCorrupt
+	}
+	id, err = binary.ReadUvarint(br)
+	if err != nil {
+		return start, end, 0, errCorrupt
+	}
+	return start, end, id, nil
+}
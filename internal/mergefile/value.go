@@ -0,0 +1,250 @@
+package mergefile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Value type tags.
+const (
+	tagNil byte = iota
+	tagString
+	tagBytes
+	tagFalse
+	tagTrue
+	tagUint16
+	tagUint32
+	tagUint64
+	tagUint128
+	tagInt32
+	tagFloat32
+	tagFloat64
+	tagMap
+	tagSlice
+)
+
+// maxDepth bounds the nesting of decoded maps and slices.
+const maxDepth = 64
+
+// appendValue appends the encoding of v to b. Map keys are written in
+// sorted order, so equal values have equal encodings.
+func appendValue(b []byte, v mmdbtype.DataType) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, tagNil), nil
+	case mmdbtype.String:
+		b = append(b, tagString)
+		return appendString(b, string(v)), nil
+	case mmdbtype.Bytes:
+		b = append(b, tagBytes)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		return append(b, v...), nil
+	case mmdbtype.Bool:
+		if v {
+			return append(b, tagTrue), nil
+		}
+		return append(b, tagFalse), nil
+	case mmdbtype.Uint16:
+		return binary.AppendUvarint(append(b, tagUint16), uint64(v)), nil
+	case mmdbtype.Uint32:
+		return binary.AppendUvarint(append(b, tagUint32), uint64(v)), nil
+	case mmdbtype.Uint64:
+		return binary.AppendUvarint(append(b, tagUint64), uint64(v)), nil
+	case *mmdbtype.Uint128:
+		bytes := (*big.Int)(v).Bytes()
+		b = append(b, tagUint128)
+		b = binary.AppendUvarint(b, uint64(len(bytes)))
+		return append(b, bytes...), nil
+	case mmdbtype.Int32:
+		return binary.AppendVarint(append(b, tagInt32), int64(v)), nil
+	case mmdbtype.Float32:
+		return binary.BigEndian.AppendUint32(append(b, tagFloat32), math.Float32bits(float32(v))), nil
+	case mmdbtype.Float64:
+		return binary.BigEndian.AppendUint64(append(b, tagFloat64), math.Float64bits(float64(v))), nil
+	case mmdbtype.Map:
+		b = append(b, tagMap)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		keys := make([]mmdbtype.String, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		var err error
+		for _, key := range keys {
+			b = appendString(b, string(key))
+			if b, err = appendValue(b, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case mmdbtype.Slice:
+		b = append(b, tagSlice)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		var err error
+		for _, value := range v {
+			if b, err = appendValue(b, value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+var errCorrupt = errors.New("corrupt merge file")
+
+// decoder decodes values from a byte slice.
+type decoder struct {
+	b []byte
+}
+
+func (d *decoder) byte() (byte, error) {
+	if len(d.b) == 0 {
+		return 0, errCorrupt
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c, nil
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		return 0, errCorrupt
+	}
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, errCorrupt
+	}
+	b := d.b[:n:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+func (d *decoder) value(depth int) (mmdbtype.DataType, error) {
+	if depth > maxDepth {
+		return nil, errCorrupt
+	}
+	tag, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case tagNil:
+		return nil, nil
+	case tagString:
+		b, err := d.bytes()
+		return mmdbtype.String(b), err
+	case tagBytes:
+		b, err := d.bytes()
+		return mmdbtype.Bytes(slices.Clone(b)), err
+	case tagFalse:
+		return mmdbtype.Bool(false), nil
+	case tagTrue:
+		return mmdbtype.Bool(true), nil
+	case tagUint16, tagUint32, tagUint64:
+		v, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tag == tagUint16 && v <= math.MaxUint16:
+			return mmdbtype.Uint16(v), nil
+		case tag == tagUint32 && v <= math.MaxUint32:
+			return mmdbtype.Uint32(v), nil
+		case tag == tagUint64:
+			return mmdbtype.Uint64(v), nil
+		}
+		return nil, errCorrupt
+	case tagUint128:
+		b, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return (*mmdbtype.Uint128)(new(big.Int).SetBytes(b)), nil
+	case tagInt32:
+		v, n := binary.Varint(d.b)
+		if n <= 0 || v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, errCorrupt
+		}
+		d.b = d.b[n:]
+		return mmdbtype.Int32(v), nil
+	case tagFloat32:
+		if len(d.b) < 4 {
+			return nil, errCorrupt
+		}
+		v := math.Float32frombits(binary.BigEndian.Uint32(d.b))
+		d.b = d.b[4:]
+		return mmdbtype.Float32(v), nil
+	case tagFloat64:
+		if len(d.b) < 8 {
+			return nil, errCorrupt
+		}
+		v := math.Float64frombits(binary.BigEndian.Uint64(d.b))
+		d.b = d.b[8:]
+		return mmdbtype.Float64(v), nil
+	case tagMap:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		m := make(mmdbtype.Map, n)
+		for range n {
+			key, err := d.bytes()
+			if err != nil {
+				return nil, err
+			}
+			if m[mmdbtype.String(key)], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case tagSlice:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		s := make(mmdbtype.Slice, n)
+		for i := range s {
+			if s[i], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	default:
+		return nil, errCorrupt
+	}
+}
+
+// length decodes the number of entries of a map or slice. Every entry takes
+// at least a byte, which bounds the allocation for corrupt input.
+func (d *decoder) length() (int, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.b)) {
+		return 0, errCorrupt
+	}
+	return int(n), nil
+}
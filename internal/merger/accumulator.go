@@ -7,7 +7,8 @@ import (
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"go4.org/netipx"
 
-	"github.com/maxmind/mmdbconvert/internal/network"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
 )
 
 // AccumulatedRange represents a continuous IP range with associated data.
@@ -17,28 +18,23 @@ type AccumulatedRange struct {
 	Data    []mmdbtype.DataType // column values ordered by config.Columns
 }
 
-// RowWriter defines the interface for writing output rows.
-type RowWriter interface {
-	// WriteRow writes a single row with network prefix and column data.
-	WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error
-}
-
-// RangeRowWriter can accept full start/end ranges instead of prefixes.
-type RangeRowWriter interface {
-	WriteRange(start, end netip.Addr, data []mmdbtype.DataType) error
-}
-
 // Accumulator accumulates adjacent networks with identical data and flushes
 // them as CIDRs when data changes. This enables O(1) memory usage.
 type Accumulator struct {
 	current          *AccumulatedRange
-	writer           RowWriter
+	writer           row.Writer
 	includeEmptyRows bool
 	pool             *slicePool // Pool for returning slices when flushing
+
+	// mergeIgnored marks the columns whose values don't keep adjacent
+	// networks from merging, indexed like the data. Nil if there are none.
+	mergeIgnored []bool
+
+	breaks *MergeBreaks // Counted merge breaks, if enabled
 }
 
 // NewAccumulator creates a new streaming accumulator.
-func NewAccumulator(writer RowWriter, includeEmptyRows bool, pool *slicePool) *Accumulator {
+func NewAccumulator(writer row.Writer, includeEmptyRows bool, pool *slicePool) *Accumulator {
 	return &Accumulator{
 		writer:           writer,
 		includeEmptyRows: includeEmptyRows,
@@ -71,13 +67,17 @@ func (a *Accumulator) Process(prefix netip.Prefix, data []mmdbtype.DataType) err
 	}
 
 	// Check if we can extend current accumulation
-	canExtend := network.IsAdjacent(a.current.EndIP, addr) && dataEquals(a.current.Data, data)
+	adjacent := network.IsAdjacent(a.current.EndIP, addr)
+	canExtend := adjacent && a.mergeable(data)
 
 	if canExtend {
 		// Extend the current range (no allocation needed)
 		a.current.EndIP = endIP
 		return nil
 	}
+	if adjacent && a.breaks != nil {
+		a.countBreak(data)
+	}
 
 	// Data changed or not adjacent - flush current range
 	if err := a.Flush(); err != nil {
@@ -96,39 +96,28 @@ func (a *Accumulator) Process(prefix netip.Prefix, data []mmdbtype.DataType) err
 	return nil
 }
 
-// Flush writes the current accumulated range as one or more CIDR rows.
-// An accumulated range may produce multiple CIDRs if it doesn't align perfectly.
+// Flush writes the current accumulated range. Writers that do not accept
+// ranges receive one row per CIDR, as a range may not align to a single prefix.
 func (a *Accumulator) Flush() error {
 	if a.current == nil {
 		return nil
 	}
 
-	if rangeWriter, ok := a.writer.(RangeRowWriter); ok {
-		if err := rangeWriter.WriteRange(a.current.StartIP, a.current.EndIP, a.current.Data); err != nil {
-			return fmt.Errorf(
-				"writing range %s-%s: %w",
-				a.current.StartIP,
-				a.current.EndIP,
-				err,
-			)
-		}
-		// Return the slice to the pool after writing
-		a.pool.Put(a.current.Data)
-		a.current = nil
-		return nil
-	}
-
-	// Convert the IP range to valid CIDRs
-	cidrs := netipx.IPRangeFrom(a.current.StartIP, a.current.EndIP).Prefixes()
-
-	// Write each CIDR as a separate row
-	for _, cidr := range cidrs {
-		if err := a.writer.WriteRow(cidr, a.current.Data); err != nil {
-			return fmt.Errorf("writing row for %s: %w", cidr, err)
-		}
+	if err := row.WriteRange(
+		a.writer,
+		a.current.StartIP,
+		a.current.EndIP,
+		a.current.Data,
+	); err != nil {
+		return fmt.Errorf(
+			"writing range %s-%s: %w",
+			a.current.StartIP,
+			a.current.EndIP,
+			err,
+		)
 	}
 
-	// Return the slice to the pool after writing all rows
+	// Return the slice to the pool after writing
 	a.pool.Put(a.current.Data)
 
 	// Clear current accumulation
@@ -136,38 +125,27 @@ func (a *Accumulator) Flush() error {
 	return nil
 }
 
-// dataEquals compares two data slices for equality.
-// Treats nil values as equal (both represent missing data).
-func dataEquals(a, b []mmdbtype.DataType) bool {
-	if len(a) != len(b) {
-		return false
+// mergeable reports whether data equals the data of the current range in
+// every column that is not ignored for merging.
+func (a *Accumulator) mergeable(data []mmdbtype.DataType) bool {
+	if a.mergeIgnored == nil {
+		return dataEquals(a.current.Data, data)
 	}
-	for i := range a {
-		if !dataValueEquals(a[i], b[i]) {
+	for i := range a.current.Data {
+		if !a.mergeIgnored[i] && !row.Row(a.current.Data[i:i+1]).Equal(data[i:i+1]) {
 			return false
 		}
 	}
 	return true
 }
 
-// dataValueEquals compares two individual values, handling nil.
-func dataValueEquals(a, b mmdbtype.DataType) bool {
-	if a == nil && b == nil {
-		return true
-	}
-	if a == nil || b == nil {
-		return false
-	}
-	// Use mmdbtype.DataType.Equal() for actual values
-	return a.Equal(b)
+// dataEquals compares two data slices for equality.
+// Treats nil values as equal (both represent missing data).
+func dataEquals(a, b []mmdbtype.DataType) bool {
+	return row.Row(a).Equal(b)
 }
 
 // isEmptyData checks if all values in the slice are nil.
 func isEmptyData(data []mmdbtype.DataType) bool {
-	for _, v := range data {
-		if v != nil {
-			return false
-		}
-	}
-	return true
+	return row.Row(data).IsEmpty()
 }
@@ -7,6 +7,8 @@ import (
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
 )
 
 // mockWriter captures written rows for testing.
@@ -32,7 +34,7 @@ type mockRow struct {
 	data   []mmdbtype.DataType
 }
 
-func (m *mockWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error {
+func (m *mockWriter) WriteRow(prefix netip.Prefix, data row.Row) error {
 	if m.stopOn != nil && prefix.Contains(*m.stopOn) {
 		m.found = true
 		if m.stopErr != nil {
@@ -46,14 +48,14 @@ func (m *mockWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) err
 	return nil
 }
 
-func (m *mockRangeWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error {
+func (m *mockRangeWriter) WriteRow(prefix netip.Prefix, data row.Row) error {
 	dataCopy := make([]mmdbtype.DataType, len(data))
 	copy(dataCopy, data)
 	m.rows = append(m.rows, mockRow{prefix: prefix, data: dataCopy})
 	return nil
 }
 
-func (m *mockRangeWriter) WriteRange(start, end netip.Addr, data []mmdbtype.DataType) error {
+func (m *mockRangeWriter) WriteRange(start, end netip.Addr, data row.Row) error {
 	dataCopy := make([]mmdbtype.DataType, len(data))
 	copy(dataCopy, data)
 	m.ranges = append(m.ranges, struct {
@@ -149,6 +151,38 @@ func TestAccumulator_AdjacentNetworksWithDifferentData(t *testing.T) {
 	)
 }
 
+func TestAccumulator_MergeIgnored(t *testing.T) {
+	writer := &mockWriter{}
+	acc := NewAccumulator(writer, true, newSlicePool(2))
+	acc.mergeIgnored = []bool{false, true}
+
+	for _, n := range []struct {
+		prefix  string
+		country string
+		lat     float64
+	}{
+		{"10.0.0.0/25", "US", 1.5},
+		{"10.0.0.128/25", "US", 2.5},
+		{"10.0.1.0/24", "CA", 2.5},
+	} {
+		require.NoError(t, acc.Process(
+			netip.MustParsePrefix(n.prefix),
+			[]mmdbtype.DataType{mmdbtype.String(n.country), mmdbtype.Float64(n.lat)},
+		))
+	}
+	require.NoError(t, acc.Flush())
+
+	// Ignored values don't split ranges, and the first network's are kept
+	require.Len(t, writer.rows, 2)
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/24"), writer.rows[0].prefix)
+	assert.Equal(
+		t,
+		[]mmdbtype.DataType{mmdbtype.String("US"), mmdbtype.Float64(1.5)},
+		writer.rows[0].data,
+	)
+	assert.Equal(t, netip.MustParsePrefix("10.0.1.0/24"), writer.rows[1].prefix)
+}
+
 func TestAccumulator_NonAdjacentNetworks(t *testing.T) {
 	writer := &mockWriter{}
 	acc := NewAccumulator(writer, true, newSlicePool(1))
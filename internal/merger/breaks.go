@@ -0,0 +1,72 @@
+package merger
+
+import (
+	"slices"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// MergeBreaks counts the adjacent networks that were not merged into one
+// range because their data differed, by the columns that differed, to find
+// the columns that keep the output large. They are only counted when
+// enabled with Merger.EnableMergeBreaks.
+type MergeBreaks struct {
+	Total   int64          // Adjacent networks not merged
+	Columns []ColumnBreaks // Indexed like config.Columns
+}
+
+// ColumnBreaks counts the merge breaks of one column.
+type ColumnBreaks struct {
+	Name string
+	// Breaks counts the breaks at which the values of the column differed,
+	// whether or not other columns differed too.
+	Breaks int64
+	// Sole counts the breaks at which only this column differed, which
+	// would have merged without it or with merge_ignore set on it.
+	Sole int64
+}
+
+// EnableMergeBreaks starts counting the merge breaks of each column,
+// available from MergeBreaks once the merge completes. Columns with
+// merge_ignore never break merges.
+func (m *Merger) EnableMergeBreaks() {
+	breaks := &MergeBreaks{Columns: make([]ColumnBreaks, len(m.config.Columns))}
+	for i, col := range m.config.Columns {
+		breaks.Columns[i].Name = string(col.Name)
+	}
+	m.acc.breaks = breaks
+}
+
+// MergeBreaks returns the counted merge breaks, or nil if EnableMergeBreaks
+// was not called.
+func (m *Merger) MergeBreaks() *MergeBreaks {
+	if m.acc.breaks == nil {
+		return nil
+	}
+	breaks := *m.acc.breaks
+	breaks.Columns = slices.Clone(m.acc.breaks.Columns)
+	return &breaks
+}
+
+// countBreak counts the break between the current range and the adjacent
+// network with data, which differ in a column that is not ignored.
+func (a *Accumulator) countBreak(data []mmdbtype.DataType) {
+	a.breaks.Total++
+	differing, last := 0, -1
+	for i := range a.current.Data {
+		if a.mergeIgnored != nil && a.mergeIgnored[i] {
+			continue
+		}
+		if row.Row(a.current.Data[i : i+1]).Equal(data[i : i+1]) {
+			continue
+		}
+		a.breaks.Columns[i].Breaks++
+		differing++
+		last = i
+	}
+	if differing == 1 {
+		a.breaks.Columns[last].Sole++
+	}
+}
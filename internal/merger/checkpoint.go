@@ -0,0 +1,82 @@
+package merger
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// Checkpoint is the position of a merge in progress, to see where a merge
+// that appears hung is stuck.
+type Checkpoint struct {
+	Network   netip.Prefix       // Network being processed
+	Networks  int64              // Networks processed so far, including Network
+	Databases []DatabasePosition // Iterated databases, outermost first
+}
+
+// DatabasePosition is the network of the record at which an iterated
+// database is read, which may be larger than the network being processed.
+type DatabasePosition struct {
+	Name string
+	// Network is invalid for an IPv4-only database during the IPv6 pass of
+	// a merge mixing IP versions, which does not read it.
+	Network netip.Prefix
+}
+
+// Checkpoints passes the checkpoints of a merge to another goroutine. A
+// checkpoint is only taken when one has been asked for since the last, so
+// that a merge costs one atomic load per network otherwise.
+type Checkpoints struct {
+	requested atomic.Bool
+
+	mu     sync.Mutex // Guards the fields below
+	latest Checkpoint
+	taken  bool
+}
+
+// Latest returns the checkpoint taken last, and asks for the next one to be
+// taken at the next network. It reports false if none has been taken yet.
+// It is safe to call while the merge runs.
+func (c *Checkpoints) Latest() (Checkpoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requested.Store(true)
+	return c.latest, c.taken
+}
+
+// EnableCheckpoints starts taking checkpoints of the merge, beginning with
+// its first network, available from the returned Checkpoints while the
+// merge runs.
+func (m *Merger) EnableCheckpoints() *Checkpoints {
+	m.checkpoints = &Checkpoints{}
+	m.checkpoints.requested.Store(true)
+	return m.checkpoints
+}
+
+// checkpoint counts the network prefix, read from results, and takes a
+// checkpoint if one was asked for.
+func (m *Merger) checkpoint(results []maxminddb.Result, prefix netip.Prefix) {
+	m.networks++
+	if !m.checkpoints.requested.Load() {
+		return
+	}
+
+	c := m.checkpoints
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requested.Store(false)
+	c.latest = Checkpoint{
+		Network:   prefix,
+		Networks:  m.networks,
+		Databases: make([]DatabasePosition, len(results)),
+	}
+	c.taken = true
+	for i, result := range results {
+		c.latest.Databases[i].Name = m.dbNamesList[i]
+		if !m.skipped(i) {
+			c.latest.Databases[i].Network = result.Prefix()
+		}
+	}
+}
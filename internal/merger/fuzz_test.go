@@ -0,0 +1,238 @@
+package merger
+
+import (
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// fuzzKeys are the map keys used by fuzzed records. Keeping the set small
+// makes it likely that fuzzed paths find something.
+var fuzzKeys = []string{"a", "b", "c", "d"}
+
+// fuzzInput builds records and paths from fuzzer-provided bytes. Once the
+// bytes run out, every read returns zero.
+type fuzzInput struct {
+	data []byte
+}
+
+func (in *fuzzInput) next() byte {
+	if len(in.data) == 0 {
+		return 0
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b
+}
+
+func (in *fuzzInput) value(depth int) mmdbtype.DataType {
+	b := in.next()
+	kinds := byte(5)
+	if depth >= 4 {
+		kinds = 3
+	}
+	switch b % kinds {
+	case 0:
+		return mmdbtype.String(fuzzKeys[int(in.next())%len(fuzzKeys)])
+	case 1:
+		return mmdbtype.Uint32(in.next())
+	case 2:
+		return mmdbtype.Bool(b&0x80 != 0)
+	case 3:
+		return in.mapValue(depth + 1)
+	default:
+		s := make(mmdbtype.Slice, in.next()%4)
+		for i := range s {
+			s[i] = in.value(depth + 1)
+		}
+		return s
+	}
+}
+
+func (in *fuzzInput) mapValue(depth int) mmdbtype.Map {
+	m := mmdbtype.Map{}
+	for range in.next() % 4 {
+		m[mmdbtype.String(fuzzKeys[int(in.next())%len(fuzzKeys)])] = in.value(depth)
+	}
+	return m
+}
+
+// parseFuzzPath splits spec on '/' into path segments, treating segments
+// that parse as integers as slice indexes.
+func parseFuzzPath(spec string) []any {
+	if spec == "" {
+		return nil
+	}
+	parts := strings.Split(spec, "/")
+	if len(parts) > 8 {
+		parts = parts[:8]
+	}
+	path := make([]any, len(parts))
+	for i, part := range parts {
+		if idx, err := strconv.Atoi(part); err == nil {
+			path[i] = idx
+		} else {
+			path[i] = part
+		}
+	}
+	return path
+}
+
+func FuzzWalkPath(f *testing.F) {
+	f.Add([]byte{3, 2, 0, 3, 1, 0, 0, 1, 4, 2, 1, 9, 0, 2}, "a/b")
+	f.Add([]byte{3, 1, 1, 4, 3, 1, 7, 1, 8, 1, 9}, "b/-1")
+	f.Add([]byte{3, 1, 2, 3, 1, 3, 0, 1}, "c/d/a/0")
+	f.Add([]byte{}, "")
+
+	f.Fuzz(func(t *testing.T, data []byte, spec string) {
+		in := &fuzzInput{data: data}
+		root := in.mapValue(0)
+		original := root.Copy()
+		path := parseFuzzPath(spec)
+
+		value, err := walkPath(root, path)
+		if !reflect.DeepEqual(original, root) {
+			t.Fatalf("walkPath modified the record: %v", root)
+		}
+		again, againErr := walkPath(root, path)
+		if !reflect.DeepEqual(value, again) || (err == nil) != (againErr == nil) {
+			t.Fatalf("walkPath is not deterministic for %v", path)
+		}
+		if len(path) == 0 {
+			if err != nil || !reflect.DeepEqual(value, root) {
+				t.Fatalf("empty path returned %v, %v", value, err)
+			}
+			return
+		}
+
+		// Walking one segment at a time from the value of the parent
+		// path must agree with walking the full path.
+		parent, parentErr := walkPath(root, path[:len(path)-1])
+		switch {
+		case parentErr != nil:
+			if err == nil {
+				t.Fatalf("parent of %v failed with %v but the path did not", path, parentErr)
+			}
+			return
+		case parent == nil:
+			if err != nil || value != nil {
+				t.Fatalf("missing parent of %v returned %v, %v", path, value, err)
+			}
+			return
+		}
+		var step mmdbtype.DataType
+		var stepErr error
+		if m, ok := parent.(mmdbtype.Map); ok {
+			step, stepErr = walkPath(m, path[len(path)-1:])
+		} else {
+			step, stepErr = walkPath(mmdbtype.Map{"_": parent}, append([]any{"_"}, path[len(path)-1:]...))
+		}
+		if !reflect.DeepEqual(value, step) || (err == nil) != (stepErr == nil) {
+			t.Fatalf(
+				"walking %v returned %v, %v; walking the last segment from its parent returned %v, %v",
+				path,
+				value,
+				err,
+				step,
+				stepErr,
+			)
+		}
+	})
+}
+
+func FuzzAccumulator(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 1, 8, 1, 0, 2, 0x10, 2, 0, 0}, false)
+	f.Add([]byte{3, 1, 3, 1, 0x21, 0, 5, 3, 5, 3, 0, 1}, true)
+	f.Add([]byte{8, 1, 8, 1, 8, 1, 8, 1}, false)
+
+	f.Fuzz(func(t *testing.T, data []byte, includeEmptyRows bool) {
+		writer := &mockRangeWriter{}
+		acc := NewAccumulator(writer, includeEmptyRows, newSlicePool(1))
+
+		// Build ascending, non-overlapping networks from the input, as the
+		// merger produces them. The low bits of each byte pick the prefix
+		// length and the high bits whether to leave a gap before it.
+		var want netipx.IPSetBuilder
+		values := map[netip.Prefix]mmdbtype.DataType{}
+		cursor := netip.MustParseAddr("1.0.0.0")
+		for i := 0; i+1 < len(data) && i < 400; i += 2 {
+			bits := 24 + int(data[i]%9)
+			prefix := netip.PrefixFrom(cursor, bits).Masked()
+			if prefix.Addr() != cursor {
+				prefix = netip.PrefixFrom(netipx.PrefixLastIP(prefix).Next(), bits)
+			}
+			if data[i]&0x10 != 0 {
+				prefix = netip.PrefixFrom(netipx.PrefixLastIP(prefix).Next(), bits)
+			}
+			cursor = netipx.PrefixLastIP(prefix).Next()
+
+			var value mmdbtype.DataType
+			if v := data[i+1] % 4; v != 0 {
+				value = mmdbtype.String(strconv.Itoa(int(v)))
+			}
+			if err := acc.Process(prefix, []mmdbtype.DataType{value}); err != nil {
+				t.Fatal(err)
+			}
+			if value != nil || includeEmptyRows {
+				want.AddPrefix(prefix)
+				values[prefix] = value
+			}
+		}
+		if err := acc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		var got netipx.IPSetBuilder
+		for i, r := range writer.ranges {
+			if r.end.Less(r.start) {
+				t.Fatalf("range %s-%s ends before it starts", r.start, r.end)
+			}
+			if i > 0 {
+				prev := writer.ranges[i-1]
+				if !prev.end.Less(r.start) {
+					t.Fatalf("range %s-%s overlaps or precedes %s-%s", r.start, r.end, prev.start, prev.end)
+				}
+				if network.IsAdjacent(prev.end, r.start) && dataEquals(prev.data, r.data) {
+					t.Fatalf("adjacent ranges %s-%s and %s-%s have the same data", prev.start, prev.end, r.start, r.end)
+				}
+			}
+			got.AddRange(netipx.IPRangeFrom(r.start, r.end))
+		}
+
+		// Every network is written with its own data, and nothing else is.
+		for prefix, value := range values {
+			for _, addr := range []netip.Addr{prefix.Addr(), netipx.PrefixLastIP(prefix)} {
+				found := false
+				for _, r := range writer.ranges {
+					if !addr.Less(r.start) && !r.end.Less(addr) {
+						found = true
+						if !reflect.DeepEqual(r.data, []mmdbtype.DataType{value}) {
+							t.Fatalf("%s was written with %v, want %v", addr, r.data, value)
+						}
+					}
+				}
+				if !found {
+					t.Fatalf("%s was not written", addr)
+				}
+			}
+		}
+		wantSet, err := want.IPSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotSet, err := got.IPSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(wantSet.Ranges(), gotSet.Ranges()) {
+			t.Fatalf("wrote %v, want %v", gotSet.Ranges(), wantSet.Ranges())
+		}
+	})
+}
@@ -10,15 +10,22 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/oschwald/maxminddb-golang/v2"
+	"go4.org/netipx"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
 	"github.com/maxmind/mmdbconvert/internal/mmdb"
-	"github.com/maxmind/mmdbconvert/internal/network"
+	"github.com/maxmind/mmdbconvert/internal/region"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/transform"
+	"github.com/maxmind/mmdbconvert/internal/translit"
+	"github.com/maxmind/mmdbconvert/network"
 )
 
 // slicePool manages reusable data slices to reduce allocations.
@@ -63,25 +70,81 @@ type columnExtractor struct {
 	database string          // Database name for error messages
 	dbIndex  int             // Index in readersList for O(1) Result lookup
 	colIndex int             // Index in config.Columns for slice ordering
+
+	// tag is output instead of the value at path, when there is one. Set for
+	// tag columns only.
+	tag mmdbtype.DataType
+	// prefixLength outputs the prefix length of the database's network
+	// instead of a value.
+	prefixLength bool
+	// translit transliterates the strings of the value, if set.
+	translit *translit.Transliterator
+	// transform applies a transform pipeline to the strings of the value,
+	// after translit, if set.
+	transform *transform.Pipeline
+	// timeZone replaces the name of a time zone by one of its properties,
+	// after transform, if set.
+	timeZone *timeZoneColumn
+	// rollup replaces a country code by a property of the country, after
+	// transform, if set.
+	rollup *rollupColumn
+	// defaultValue is output instead of a missing value when the database
+	// has a record for the network, if set.
+	defaultValue mmdbtype.DataType
 }
 
+// readMode is how the merge reads an iterated database within the networks
+// of the databases before it.
+type readMode uint8
+
+const (
+	// readIterate iterates the networks of the database within each network.
+	readIterate readMode = iota
+	// readLookup looks up the first address of each network, and iterates
+	// the networks within it if the database splits it further.
+	readLookup
+	// readLookupImprecise looks up the first address of each network and
+	// uses its record for the whole network.
+	readLookupImprecise
+)
+
 // Merger handles merging multiple MMDB databases into a single output stream.
+// It is not safe for concurrent use; see LookupPool for concurrent lookups.
 type Merger struct {
-	readers       *mmdb.Readers
-	config        *config.Config
-	acc           *Accumulator
-	readersList   []*mmdb.Reader    // Ordered list of readers for iteration
-	dbNamesList   []string          // Corresponding database names
-	extractors    []columnExtractor // Pre-built extractors for each column
-	unmarshalers  []*mmdbtype.Unmarshaler
-	slicePool     *slicePool          // Pool for reusable data slices
-	workingSlice  []mmdbtype.DataType // Reusable working slice (cleared each iteration)
-	resultsBuffer []maxminddb.Result  // Pre-allocated buffer for recursion (eliminates slices.Concat allocations)
+	readers        *mmdb.Readers
+	config         *config.Config
+	acc            *Accumulator
+	readersList    []*mmdb.Reader      // Ordered list of readers for iteration
+	readModes      []readMode          // How each of readersList is read
+	dbNamesList    []string            // Database names: iterated ones, then preloaded ones
+	preloaded      []*mmdb.Preloaded   // Preloaded databases, indexed from len(readersList) in dbNamesList
+	preloadRecords []mmdbtype.DataType // Records of the preloaded databases for the current range
+	preloadNets    []netip.Prefix      // Networks of preloadRecords
+	boundsBuffer   []netip.Addr        // Reusable buffer of addresses where a prefix is split
+	extractors     []columnExtractor   // Pre-built extractors for each column read from a database
+	scores         []scoreColumn       // Columns computed from the extracted columns
+	unmarshalers   []*mmdbtype.Unmarshaler
+	decodePaths    []bool              // Per database: decode each column's path instead of the full record
+	decodedRecords []mmdbtype.DataType // Reusable buffer of full records, indexed like dbNamesList
+	slicePool      *slicePool          // Pool for reusable data slices
+	workingSlice   []mmdbtype.DataType // Reusable working slice (cleared each iteration)
+	resultsBuffer  []maxminddb.Result  // Pre-allocated buffer for recursion (eliminates slices.Concat allocations)
+	stats          *Stats              // Time spent in each step, if enabled
+	checkpoints    *Checkpoints        // Positions taken for another goroutine, if enabled
+	trace          *Trace              // Merge of the traced networks, if enabled
+	networks       int64               // Networks processed, counted with checkpoints
+
+	// ipv4Only marks the IPv4-only databases, indexed like dbNamesList, when
+	// they are mixed with IPv6 databases; nil otherwise. Such merges cover
+	// the IPv4 and IPv6 address spaces in separate passes, and the IPv4-only
+	// databases have no data in the IPv6 pass.
+	ipv4Only []bool
+	ipv6Pass bool // Set during the IPv6 pass of a mixed merge
 }
 
 // NewMerger creates a new merger instance.
 // Returns an error if database readers are missing or path normalization fails.
-func NewMerger(readers *mmdb.Readers, cfg *config.Config, writer RowWriter) (*Merger, error) {
+func NewMerger(readers *mmdb.Readers, cfg *config.Config, writer row.Writer) (*Merger, error) {
 	includeEmptyRows := false
 	if cfg.Output.IncludeEmptyRows != nil {
 		includeEmptyRows = *cfg.Output.IncludeEmptyRows
@@ -98,37 +161,110 @@ func NewMerger(readers *mmdb.Readers, cfg *config.Config, writer RowWriter) (*Me
 		slicePool:    slicePool,
 		workingSlice: make([]mmdbtype.DataType, len(cfg.Columns)),
 	}
+	if slices.ContainsFunc(cfg.Columns, func(col config.Column) bool { return col.MergeIgnore }) {
+		m.acc.mergeIgnored = make([]bool, len(cfg.Columns))
+		for i, col := range cfg.Columns {
+			m.acc.mergeIgnored[i] = col.MergeIgnore
+		}
+	}
 
 	// Build ordered list of unique database names
 	// This determines the order for readersList and dbIndex values
-	dbNamesList := m.getUniqueDatabaseNames()
-	if len(dbNamesList) == 0 {
+	if len(m.getUniqueDatabaseNames()) == 0 {
 		return nil, errors.New("no databases configured")
 	}
+	iteratedNames := IteratedDatabaseNames(cfg)
+	if len(iteratedNames) == 0 {
+		return nil, errors.New(
+			"every database used by columns is preloaded; at least one must be iterated",
+		)
+	}
+	dbNamesList := slices.Concat(iteratedNames, preloadedDatabaseNames(cfg))
 	m.dbNamesList = dbNamesList
 
 	// Build readersList in the same order
-	readersList := make([]*mmdb.Reader, 0, len(dbNamesList))
+	allReaders := make([]*mmdb.Reader, 0, len(dbNamesList))
 	for _, dbName := range dbNamesList {
 		reader, ok := readers.Get(dbName)
 		if !ok {
 			return nil, fmt.Errorf("database '%s' not found", dbName)
 		}
-		readersList = append(readersList, reader)
+		allReaders = append(allReaders, reader)
 	}
+	readersList := allReaders[:len(iteratedNames)]
 	m.readersList = readersList
+	m.readModes = make([]readMode, len(readersList))
+	for _, db := range cfg.Databases {
+		i := slices.Index(iteratedNames, db.Name)
+		if i < 0 || db.Mode != config.DatabaseModeLookup {
+			continue
+		}
+		m.readModes[i] = readLookup
+		if db.AllowImpreciseLookup {
+			m.readModes[i] = readLookupImprecise
+		}
+	}
+	if m.readModes[0] != readIterate {
+		return nil, errors.New(
+			"every database used by columns is in lookup mode or preloaded; at least one must be iterated",
+		)
+	}
 
 	// Pre-allocate results buffer for recursion (eliminates slices.Concat allocations)
 	m.resultsBuffer = make([]maxminddb.Result, len(readersList))
 
 	// Validate IP versions before building extractors
-	if err := validateIPVersions(readersList, dbNamesList); err != nil {
+	ipv4Only, err := checkIPVersions(allReaders, dbNamesList)
+	if err != nil {
 		return nil, err
 	}
+	m.ipv4Only = ipv4Only
+
+	// Load preloaded databases into memory; they are consulted by lookup
+	// rather than iterated. Names sharing a file share its preloaded data.
+	preloadedReaders := allReaders[len(iteratedNames):]
+	for i, reader := range preloadedReaders {
+		if j := slices.IndexFunc(preloadedReaders[:i], reader.SharesFile); j >= 0 {
+			m.preloaded = append(m.preloaded, m.preloaded[j])
+			continue
+		}
+		preloaded, err := mmdb.Preload(reader)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"preloading database '%s': %w",
+				dbNamesList[len(iteratedNames)+i],
+				err,
+			)
+		}
+		m.preloaded = append(m.preloaded, preloaded)
+	}
+	m.preloadRecords = make([]mmdbtype.DataType, len(m.preloaded))
+	m.preloadNets = make([]netip.Prefix, len(m.preloaded))
 
 	// Pre-build column extractors with dbIndex values
-	extractors := make([]columnExtractor, len(cfg.Columns))
+	timeZoneAt := time.Now()
+	if cfg.TimeZoneReference != "" {
+		timeZoneAt, err = time.Parse(time.RFC3339, cfg.TimeZoneReference)
+		if err != nil {
+			return nil, fmt.Errorf("parsing time_zone_reference: %w", err)
+		}
+	}
+	var rollupTable *region.Table
+	if slices.ContainsFunc(cfg.Columns, func(col config.Column) bool { return col.CountryRollup != "" }) {
+		rollupTable = region.Default()
+		if cfg.CountryRollupFile != "" {
+			rollupTable, err = region.Load(cfg.CountryRollupFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading country_rollup_file: %w", err)
+			}
+		}
+	}
+	extractors := make([]columnExtractor, 0, len(cfg.Columns))
 	for i, column := range cfg.Columns {
+		// Scores are computed once the other columns are extracted
+		if column.Kind == config.ColumnKindScore {
+			continue
+		}
 		reader, ok := readers.Get(column.Database)
 		if !ok {
 			return nil, fmt.Errorf(
@@ -158,16 +294,84 @@ func NewMerger(readers *mmdb.Readers, cfg *config.Config, writer RowWriter) (*Me
 			}
 		}
 
-		extractors[i] = columnExtractor{
+		extractor := columnExtractor{
 			reader:   reader,
 			path:     pathSegments,
 			name:     column.Name,
 			database: column.Database,
 			dbIndex:  dbIdx,
 			colIndex: i,
+			tag:      column.TagValue,
+
+			prefixLength: column.PrefixLength,
+			defaultValue: column.Default,
+		}
+		if column.Transliterate != "" {
+			t, ok := translit.New(column.Transliterate)
+			if !ok {
+				return nil, fmt.Errorf(
+					"unknown transliterate locale '%s' for column '%s'",
+					column.Transliterate,
+					column.Name,
+				)
+			}
+			extractor.translit = t
+		}
+		if column.Transform != "" {
+			tc, ok := cfg.Transforms[column.Transform]
+			if !ok {
+				return nil, fmt.Errorf(
+					"unknown transform '%s' for column '%s'",
+					column.Transform,
+					column.Name,
+				)
+			}
+			opts := tc.Options()
+			if tc.OrgNamesFile != "" {
+				opts.OrgNames, err = transform.LoadOrgNames(tc.OrgNamesFile)
+				if err != nil {
+					return nil, fmt.Errorf("transform '%s': loading org_names_file: %w", column.Transform, err)
+				}
+			}
+			p, err := transform.New(tc.Steps, opts)
+			if err != nil {
+				return nil, fmt.Errorf("transform '%s': %w", column.Transform, err)
+			}
+			extractor.transform = p
+		}
+		if column.TimeZone != "" {
+			extractor.timeZone = newTimeZoneColumn(column.TimeZone, timeZoneAt)
+		}
+		if column.CountryRollup != "" {
+			extractor.rollup = &rollupColumn{property: column.CountryRollup, table: rollupTable}
 		}
+		extractors = append(extractors, extractor)
 	}
 	m.extractors = extractors
+	m.scores, err = newScoreColumns(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// A database whose columns all select a field within the record is read
+	// with DecodePath, which skips everything outside the selected fields.
+	// Columns without a path need the full record, so any such column makes
+	// its database fall back to decoding whole records.
+	// Preloaded databases are already decoded.
+	m.decodePaths = make([]bool, len(dbNamesList))
+	for i := range readersList {
+		m.decodePaths[i] = true
+	}
+	for _, extractor := range extractors {
+		// Tag and prefix length columns only check that the record exists
+		if extractor.tag != nil || extractor.prefixLength {
+			continue
+		}
+		if len(extractor.path) == 0 && extractor.dbIndex >= 0 {
+			m.decodePaths[extractor.dbIndex] = false
+		}
+	}
+	m.decodedRecords = make([]mmdbtype.DataType, len(dbNamesList))
 
 	// Create per-database unmarshaler to avoid cross-database cache contamination.
 	// When cfg.DisableCache is false (default), use NewUnmarshaler() which provides caching.
@@ -185,46 +389,84 @@ func NewMerger(readers *mmdb.Readers, cfg *config.Config, writer RowWriter) (*Me
 }
 
 // Merge performs the streaming merge of all databases.
-// It uses nested NetworksWithin iteration to find the smallest overlapping
+// It iterates the networks of the driver database, and uses nested
+// NetworksWithin iteration of the others to find the smallest overlapping
 // networks across all databases, then extracts data and streams to accumulator.
 func (m *Merger) Merge() error {
-	// readersList and dbNamesList are already built in NewMerger()
+	if m.ipv4Only != nil {
+		return m.mergeByIPVersion()
+	}
+
+	// readersList and dbNamesList are already built in NewMerger(), with
+	// the driver database first
 	firstReader := m.readersList[0]
 
-	// Iterate all networks in the first database
+	// Iterate all networks in the driver database
+	mark := m.statsNow()
 	for result := range firstReader.Networks(maxminddb.IncludeNetworksWithoutData()) {
+		m.addIterate(0, mark)
 		if err := result.Err(); err != nil {
-			return fmt.Errorf("iterating first database: %w", err)
+			return fmt.Errorf("iterating database '%s': %w", m.dbNamesList[0], err)
 		}
 
 		prefix := result.Prefix()
 
-		// If there's only one database, extract and process directly
+		// PUSH first result into buffer (used even for a single database)
+		m.resultsBuffer[0] = result
+
 		if len(m.readersList) == 1 {
-			m.resultsBuffer[0] = result // Use buffer even for single database
+			// If there's only one database, extract and process directly
 			if err := m.extractAndProcess(m.resultsBuffer[:1], prefix); err != nil {
 				return err
 			}
-			continue
-		}
-
-		// PUSH first result into buffer
-		m.resultsBuffer[0] = result
-
-		// Process this network through remaining databases starting at index 1
-		if err := m.processNetwork(prefix, 1); err != nil {
+		} else if err := m.processNetwork(prefix, 1); err != nil {
+			// Process this network through remaining databases starting at index 1
 			return err
 		}
+		mark = m.statsNow()
 	}
+	m.addIterate(0, mark)
 
 	// Flush any remaining accumulated data
+	start := m.statsNow()
 	if err := m.acc.Flush(); err != nil {
 		return fmt.Errorf("flushing accumulator: %w", err)
 	}
+	m.addProcess(start)
+
+	return nil
+}
+
+// mergeByIPVersion merges IPv4-only databases with IPv6 databases in two
+// passes. The IPv4 pass iterates the IPv4 address space of every database,
+// which IPv6 databases hold in their IPv4 subtree. The IPv6 pass iterates
+// the rest of the IPv6 address space, skipping the IPv4-only databases.
+func (m *Merger) mergeByIPVersion() error {
+	if err := m.processNetwork(netip.PrefixFrom(netip.IPv4Unspecified(), 0), 0); err != nil {
+		return err
+	}
+
+	m.ipv6Pass = true
+	defer func() { m.ipv6Pass = false }()
+	if err := m.processNetwork(netip.PrefixFrom(netip.IPv6Unspecified(), 0), 0); err != nil {
+		return err
+	}
 
+	start := m.statsNow()
+	if err := m.acc.Flush(); err != nil {
+		return fmt.Errorf("flushing accumulator: %w", err)
+	}
+	m.addProcess(start)
 	return nil
 }
 
+// skipped reports whether the database at dbIndex has no data for the
+// networks currently merged, because it is IPv4-only and the IPv6 address
+// space is being merged.
+func (m *Merger) skipped(dbIndex int) bool {
+	return m.ipv6Pass && m.ipv4Only[dbIndex]
+}
+
 // processNetwork recursively processes a network through remaining databases.
 // It uses the pre-allocated resultsBuffer with depth tracking to avoid allocations.
 //
@@ -242,16 +484,34 @@ func (m *Merger) processNetwork(
 		return m.extractAndProcess(m.resultsBuffer[:dbIndex], effectivePrefix)
 	}
 
+	if m.skipped(dbIndex) {
+		m.resultsBuffer[dbIndex] = maxminddb.Result{}
+		return m.processNetwork(effectivePrefix, dbIndex+1)
+	}
+
+	if m.readModes[dbIndex] != readIterate {
+		if done, err := m.lookupNetwork(effectivePrefix, dbIndex); done || err != nil {
+			return err
+		}
+	}
+
 	currentReader := m.readersList[dbIndex]
 
 	// Iterate networks within effectivePrefix in this database
 	// With IncludeNetworksWithoutData, this ALWAYS yields at least one Result
+	mark := m.statsNow()
 	for result := range currentReader.NetworksWithin(effectivePrefix, maxminddb.IncludeNetworksWithoutData()) {
+		m.addIterate(dbIndex, mark)
 		if err := result.Err(); err != nil {
 			return fmt.Errorf("iterating database within %s: %w", effectivePrefix, err)
 		}
 
 		nextNetwork := result.Prefix()
+		if m.ipv6Pass && nextNetwork.Addr().Is4() {
+			// The IPv4 subtree of an IPv6 database, merged in the IPv4 pass
+			mark = m.statsNow()
+			continue
+		}
 
 		// Determine smallest (most specific) network
 		smallest := network.SmallestNetwork(effectivePrefix, nextNetwork)
@@ -267,18 +527,42 @@ func (m *Merger) processNetwork(
 		}
 
 		// POP: Not needed - next iteration or return will naturally overwrite
+		mark = m.statsNow()
 	}
+	m.addIterate(dbIndex, mark)
 
 	return nil
 }
 
+// lookupNetwork processes effectivePrefix with the record of its first
+// address in the lookup mode database at dbIndex, saving the iteration of
+// the database's networks within the prefix. Unless imprecise lookups are
+// allowed, it reports false without processing the prefix if the record's
+// network does not cover the whole prefix, so that the caller iterates the
+// networks the database splits it into instead.
+func (m *Merger) lookupNetwork(effectivePrefix netip.Prefix, dbIndex int) (bool, error) {
+	mark := m.statsNow()
+	result := m.readersList[dbIndex].Lookup(effectivePrefix.Addr())
+	m.addIterate(dbIndex, mark)
+	if err := result.Err(); err != nil {
+		return false, fmt.Errorf(
+			"looking up %s in database '%s': %w",
+			effectivePrefix.Addr(),
+			m.dbNamesList[dbIndex],
+			err,
+		)
+	}
+	if result.Prefix().Bits() > effectivePrefix.Bits() && m.readModes[dbIndex] != readLookupImprecise {
+		return false, nil
+	}
+
+	m.resultsBuffer[dbIndex] = result
+	return true, m.processNetwork(effectivePrefix, dbIndex+1)
+}
+
 // extractAndProcess extracts data for all columns using precomputed Results,
 // then feeds the result to the accumulator.
 //
-// Key optimization: Decode each database's full record once, then extract all
-// columns from the cached record. This reduces decoder allocations from
-// O(columns) to O(databases) per network.
-//
 // This function performs NO database lookups - all Results come from the slice.
 // Invariants:
 // - results[i] corresponds to readersList[i].
@@ -287,11 +571,123 @@ func (m *Merger) extractAndProcess(
 	results []maxminddb.Result,
 	effectivePrefix netip.Prefix,
 ) error {
-	// Step 1: Decode full records once per database
-	// This replaces N decoder invocations (one per column) with M invocations (one per database)
-	// For typical configs: N=50+, M=1-3, so this is a ~16-50x reduction in decoder calls
-	decodedRecords := make([]mmdbtype.Map, len(results))
+	if m.checkpoints != nil {
+		m.checkpoint(results, effectivePrefix)
+	}
+
+	if len(m.preloaded) > 0 {
+		return m.extractAndProcessPreloaded(results, effectivePrefix)
+	}
+
+	if err := m.extractRow(results, nil); err != nil {
+		return err
+	}
+	m.traceStep(results, effectivePrefix)
+
+	// Use the effectivePrefix parameter - NOT derived from results!
+	// The accumulator will copy this slice to a pooled slice if data changes
+	return m.process(effectivePrefix)
+}
+
+// extractAndProcessPreloaded is extractAndProcess for merges with preloaded
+// databases. Preloaded networks may start or end inside effectivePrefix, so
+// the prefix is split at their boundaries and every part is processed with
+// the preloaded records covering it.
+func (m *Merger) extractAndProcessPreloaded(
+	results []maxminddb.Result,
+	effectivePrefix netip.Prefix,
+) error {
+	start := effectivePrefix.Addr()
+	end := netipx.PrefixLastIP(effectivePrefix)
+
+	bounds := m.boundsBuffer[:0]
+	for i, preloaded := range m.preloaded {
+		mark := m.statsNow()
+		for _, r := range preloaded.Overlapping(start, end) {
+			if r.Start.Compare(start) > 0 {
+				bounds = append(bounds, r.Start)
+			}
+			if r.End.Compare(end) < 0 {
+				bounds = append(bounds, r.End.Next())
+			}
+		}
+		m.addIterate(len(m.readersList)+i, mark)
+	}
+	slices.SortFunc(bounds, netip.Addr.Compare)
+	bounds = slices.Compact(bounds)
+	m.boundsBuffer = bounds
+
+	// Common case: the prefix lies within a single network of every
+	// preloaded database
+	if len(bounds) == 0 {
+		m.lookupPreloaded(start)
+		if err := m.extractRow(results, m.preloadRecords); err != nil {
+			return err
+		}
+		m.traceStep(results, effectivePrefix)
+		return m.process(effectivePrefix)
+	}
+
+	partStart := start
+	for i := 0; i <= len(bounds); i++ {
+		partEnd := end
+		if i < len(bounds) {
+			partEnd = bounds[i].Prev()
+		}
+
+		m.lookupPreloaded(partStart)
+		if err := m.extractRow(results, m.preloadRecords); err != nil {
+			return err
+		}
+		for _, prefix := range netipx.IPRangeFrom(partStart, partEnd).Prefixes() {
+			m.traceStep(results, prefix)
+			if err := m.process(prefix); err != nil {
+				return err
+			}
+		}
+
+		if i < len(bounds) {
+			partStart = bounds[i]
+		}
+	}
+	return nil
+}
+
+// lookupPreloaded fills m.preloadRecords with the record of each preloaded
+// database for addr.
+func (m *Merger) lookupPreloaded(addr netip.Addr) {
+	for i, preloaded := range m.preloaded {
+		mark := m.statsNow()
+		m.preloadRecords[i] = nil
+		if r, ok := preloaded.Lookup(addr); ok {
+			m.preloadRecords[i] = r.Record
+			m.preloadNets[i] = r.Prefix
+		}
+		m.addIterate(len(m.readersList)+i, mark)
+	}
+}
+
+// extractRow decodes the records referenced by results and fills
+// m.workingSlice with the value of every column. preloaded holds the records
+// of the preloaded databases, in order.
+//
+// Databases with a column that needs the full record are decoded once, and
+// all of their columns are extracted from the decoded record. This reduces
+// decoder allocations from O(columns) to O(databases) per network. For the
+// remaining databases, each column's path is decoded directly, so that a
+// scalar field such as an ISO code is read without building the rest of the
+// record. Strings decoded this way come from the reader's string cache and
+// are not copied.
+func (m *Merger) extractRow(results []maxminddb.Result, preloaded []mmdbtype.DataType) error {
+	// Step 1: Decode full records once per database that needs them
+	decodedRecords := m.decodedRecords
+	clear(decodedRecords)
+	copy(decodedRecords[len(results):], preloaded)
 	for i, result := range results {
+		if m.decodePaths[i] || m.skipped(i) {
+			continue
+		}
+
 		unmarshaler := m.unmarshalers[i]
 		if unmarshaler == nil {
 			return fmt.Errorf(
@@ -302,18 +698,16 @@ func (m *Merger) extractAndProcess(
 		}
 
 		// Decode the full record (empty path means decode entire record)
+		start := m.statsNow()
 		if err := result.Decode(unmarshaler); err != nil {
 			return fmt.Errorf("decoding database %d (%s): %w", i, m.dbNamesList[i], err)
 		}
+		m.addDecode(i, start)
 
-		// Get the decoded value and type-assert to Map
-		value := unmarshaler.Result()
+		// Records are usually maps, but may be any type, such as a scalar
+		// in a custom database; empty-path columns output them as is
+		decodedRecords[i] = unmarshaler.Result()
 		unmarshaler.Clear()
-
-		if record, ok := value.(mmdbtype.Map); ok {
-			decodedRecords[i] = record
-		}
-		// If not a Map, leave decodedRecords[i] as nil (no data for this database)
 	}
 	// Step 2: Extract column values into reusable working slice
 	// Clear the working slice before reuse
@@ -329,47 +723,200 @@ func (m *Merger) extractAndProcess(
 			)
 		}
 
-		// Get cached decoded record for this database
 		if extractor.dbIndex < 0 || extractor.dbIndex >= len(decodedRecords) {
 			// Database index out of bounds - skip column
 			continue
 		}
 
-		record := decodedRecords[extractor.dbIndex]
-		if record == nil {
-			continue // No data in this database for this network
+		if extractor.prefixLength {
+			if m.hasRecord(results, extractor.dbIndex) {
+				//nolint:gosec // prefix lengths are at most 128
+				bits := uint16(m.recordNetwork(results, extractor.dbIndex).Bits())
+				m.workingSlice[extractor.colIndex] = mmdbtype.Uint16(bits)
+			}
+			continue
 		}
 
-		// Walk the path in the cached record to extract the value
-		value, err := walkPath(record, extractor.path)
-		if err != nil {
-			return fmt.Errorf(
-				"decoding path for column '%s': %w",
-				extractor.name,
-				err,
-			)
+		if extractor.tag != nil && len(extractor.path) == 0 {
+			if m.hasRecord(results, extractor.dbIndex) {
+				m.workingSlice[extractor.colIndex] = extractor.tag
+			}
+			continue
+		}
+
+		var value mmdbtype.DataType
+		if m.decodePaths[extractor.dbIndex] {
+			if m.skipped(extractor.dbIndex) {
+				continue
+			}
+			// Decode only the field at the column's path
+			unmarshaler := m.unmarshalers[extractor.dbIndex]
+			start := m.statsNow()
+			err := results[extractor.dbIndex].DecodePath(unmarshaler, extractor.path...)
+			m.addDecode(extractor.dbIndex, start)
+			if err != nil {
+				return fmt.Errorf(
+					"decoding path for column '%s': %w",
+					extractor.name,
+					err,
+				)
+			}
+			value = unmarshaler.Result()
+			unmarshaler.Clear()
+		} else {
+			// Get cached decoded record for this database
+			record := decodedRecords[extractor.dbIndex]
+			if record == nil {
+				continue // No data in this database for this network
+			}
+
+			// Walk the path in the cached record to extract the value
+			var err error
+			start := m.statsNow()
+			value, err = walkPath(record, extractor.path)
+			m.addWalk(extractor.dbIndex, start)
+			if err != nil {
+				return fmt.Errorf(
+					"decoding path for column '%s': %w",
+					extractor.name,
+					err,
+				)
+			}
 		}
 
 		// Store value at column index (nil values are OK - they indicate missing data)
 		if value != nil {
+			if extractor.tag != nil {
+				// A tag column of a pre-merged database, whose path selects
+				// the original database's record
+				value = extractor.tag
+			} else {
+				if extractor.translit != nil {
+					value = extractor.translit.Value(value)
+				}
+				if extractor.transform != nil {
+					var err error
+					if value, err = extractor.transform.Value(value); err != nil {
+						return fmt.Errorf("transforming column '%s': %w", extractor.name, err)
+					}
+				}
+				if extractor.timeZone != nil {
+					value = extractor.timeZone.value(value)
+				}
+				if extractor.rollup != nil {
+					value = extractor.rollup.value(value)
+				}
+			}
 			m.workingSlice[extractor.colIndex] = value
+		} else if extractor.defaultValue != nil && m.hasRecord(results, extractor.dbIndex) {
+			m.workingSlice[extractor.colIndex] = extractor.defaultValue
 		}
 	}
 
-	// Use the effectivePrefix parameter - NOT derived from results!
-	// The accumulator will copy this slice to a pooled slice if data changes
-	return m.acc.Process(effectivePrefix, m.workingSlice)
+	for i := range m.scores {
+		if err := m.scores[i].compute(m.workingSlice); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// walkPath navigates through a nested mmdbtype.Map/Slice structure using the given path.
-// Returns nil if the path doesn't exist.
-func walkPath(root mmdbtype.Map, path []any) (mmdbtype.DataType, error) {
-	if len(path) == 0 {
-		// Empty path means return the entire record
-		return root, nil
+// hasRecord reports whether the database at dbIndex has a record for the
+// current network. It must be called after extractRow has decoded the full
+// records.
+func (m *Merger) hasRecord(results []maxminddb.Result, dbIndex int) bool {
+	if dbIndex < len(results) && m.decodePaths[dbIndex] {
+		return !m.skipped(dbIndex) && results[dbIndex].Found()
+	}
+	return m.decodedRecords[dbIndex] != nil
+}
+
+// recordNetwork returns the network of the database at dbIndex holding the
+// record of the current network, which may be larger than the current
+// network. It must only be called if hasRecord reports a record.
+func (m *Merger) recordNetwork(results []maxminddb.Result, dbIndex int) netip.Prefix {
+	if dbIndex < len(results) {
+		return results[dbIndex].Prefix()
+	}
+	return m.preloadNets[dbIndex-len(results)]
+}
+
+// UnknownCountries returns, by column name, the strings that the country
+// steps of the columns' transforms did not recognize as country codes, with
+// the number of values of each.
+func (m *Merger) UnknownCountries() map[mmdbtype.String]map[string]int {
+	unknown := map[mmdbtype.String]map[string]int{}
+	for _, extractor := range m.extractors {
+		if extractor.transform == nil {
+			continue
+		}
+		if codes := extractor.transform.UnknownCountries(); len(codes) > 0 {
+			unknown[extractor.name] = codes
+		}
+	}
+	return unknown
+}
+
+// Lookup returns the merged column values for a single address, ordered by
+// config.Columns, together with the most specific network containing the
+// address across all databases. Values are extracted exactly as during
+// Merge, which makes Lookup suitable for spot checks against the merged
+// view. The returned slice is owned by the caller.
+func (m *Merger) Lookup(addr netip.Addr) (netip.Prefix, []mmdbtype.DataType, error) {
+	// IPv4-only databases have no data for IPv6 addresses
+	m.ipv6Pass = m.ipv4Only != nil && addr.Is6()
+	defer func() { m.ipv6Pass = false }()
+
+	var prefix netip.Prefix
+	for i, reader := range m.readersList {
+		if m.skipped(i) {
+			m.resultsBuffer[i] = maxminddb.Result{}
+			continue
+		}
+		result := reader.Lookup(addr)
+		if err := result.Err(); err != nil {
+			return netip.Prefix{}, nil, fmt.Errorf(
+				"looking up %s in %s: %w",
+				addr,
+				m.dbNamesList[i],
+				err,
+			)
+		}
+		m.resultsBuffer[i] = result
+		if !prefix.IsValid() {
+			prefix = result.Prefix()
+		} else {
+			prefix = network.SmallestNetwork(prefix, result.Prefix())
+		}
+	}
+	if !prefix.IsValid() {
+		// Every iterated database is skipped, so the IPv6 pass merges the
+		// whole address space as one network
+		prefix = netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	}
+	for i, preloaded := range m.preloaded {
+		m.preloadRecords[i] = nil
+		if r, ok := preloaded.Lookup(addr); ok {
+			m.preloadRecords[i] = r.Record
+			m.preloadNets[i] = r.Prefix
+			prefix = network.SmallestNetwork(prefix, r.Prefix)
+		}
 	}
 
-	var current mmdbtype.DataType = root
+	if err := m.extractRow(m.resultsBuffer, m.preloadRecords); err != nil {
+		return netip.Prefix{}, nil, err
+	}
+
+	row := make([]mmdbtype.DataType, len(m.workingSlice))
+	copy(row, m.workingSlice)
+	return prefix, row, nil
+}
+
+// walkPath navigates through a nested mmdbtype.Map/Slice structure using the given path.
+// Returns nil if the path doesn't exist.
+func walkPath(root mmdbtype.DataType, path []any) (mmdbtype.DataType, error) {
+	current := root
 
 	for i, segment := range path {
 		switch key := segment.(type) {
@@ -449,10 +996,20 @@ func describeWalkPath(path []any) string {
 
 // getUniqueDatabaseNames returns the list of unique database names used in columns.
 func (m *Merger) getUniqueDatabaseNames() []string {
+	return DatabaseNames(m.config)
+}
+
+// DatabaseNames returns the databases referenced by the columns of cfg in
+// order of first use.
+func DatabaseNames(cfg *config.Config) []string {
 	seen := map[string]bool{}
 	var names []string
 
-	for _, column := range m.config.Columns {
+	for _, column := range cfg.Columns {
+		// Score columns are computed, not read from a database
+		if column.Kind == config.ColumnKindScore {
+			continue
+		}
 		if !seen[column.Database] {
 			seen[column.Database] = true
 			names = append(names, column.Database)
@@ -462,10 +1019,54 @@ func (m *Merger) getUniqueDatabaseNames() []string {
 	return names
 }
 
-func validateIPVersions(readers []*mmdb.Reader, names []string) error {
+// IteratedDatabaseNames returns the databases referenced by the columns of
+// cfg that are not preloaded, in the order they are nested: the driver
+// database first, if one is configured, then in order of first use, with
+// the databases in lookup mode innermost. Each of them adds a level of
+// nested iteration to the merge.
+func IteratedDatabaseNames(cfg *config.Config) []string {
+	preloaded := preloadedDatabaseNames(cfg)
+	lookup := map[string]bool{}
+	for _, db := range cfg.Databases {
+		lookup[db.Name] = db.Mode == config.DatabaseModeLookup
+	}
+	names := slices.DeleteFunc(DatabaseNames(cfg), func(name string) bool {
+		return slices.Contains(preloaded, name)
+	})
+	// Networks are smallest innermost, where a lookup is most likely to
+	// cover a whole network
+	iterated := slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		return lookup[name]
+	})
+	names = slices.Concat(iterated, slices.DeleteFunc(names, func(name string) bool {
+		return !lookup[name]
+	}))
+	if i := slices.Index(names, cfg.DriverDatabase); i > 0 {
+		names = slices.Concat(names[i:i+1], names[:i], names[i+1:])
+	}
+	return names
+}
+
+// preloadedDatabaseNames returns the preloaded databases referenced by the
+// columns of cfg, in order of first use.
+func preloadedDatabaseNames(cfg *config.Config) []string {
+	preload := map[string]bool{}
+	for _, db := range cfg.Databases {
+		preload[db.Name] = db.Preload
+	}
+	return slices.DeleteFunc(DatabaseNames(cfg), func(name string) bool {
+		return !preload[name]
+	})
+}
+
+// checkIPVersions checks that every database is an IPv4 or IPv6 tree. If
+// IPv4-only databases are mixed with IPv6 databases, it returns which
+// databases are IPv4-only.
+func checkIPVersions(readers []*mmdb.Reader, names []string) ([]bool, error) {
 	var (
-		ipv4Only     []string
-		ipv6Capable  []string
+		ipv4Only     = make([]bool, len(readers))
+		hasIPv4Only  bool
+		hasIPv6      bool
 		unsupportedV []string
 	)
 
@@ -473,9 +1074,10 @@ func validateIPVersions(readers []*mmdb.Reader, names []string) error {
 		version := reader.Metadata().IPVersion
 		switch version {
 		case 4:
-			ipv4Only = append(ipv4Only, names[idx])
+			ipv4Only[idx] = true
+			hasIPv4Only = true
 		case 6:
-			ipv6Capable = append(ipv6Capable, names[idx])
+			hasIPv6 = true
 		default:
 			unsupportedV = append(
 				unsupportedV,
@@ -485,19 +1087,14 @@ func validateIPVersions(readers []*mmdb.Reader, names []string) error {
 	}
 
 	if len(unsupportedV) > 0 {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"unsupported ip_version values reported: %s",
 			strings.Join(unsupportedV, ", "),
 		)
 	}
 
-	if len(ipv4Only) > 0 && len(ipv6Capable) > 0 {
-		return fmt.Errorf(
-			"configured databases mix IPv4-only (%s) and IPv6-capable (%s) files; run separate conversions per IP version or supply homogeneous databases",
-			strings.Join(ipv4Only, ", "),
-			strings.Join(ipv6Capable, ", "),
-		)
+	if !hasIPv4Only || !hasIPv6 {
+		return nil, nil
 	}
-
-	return nil
+	return ipv4Only, nil
 }
@@ -4,17 +4,17 @@ import (
 	"net/netip"
 	"testing"
 
-	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/stretchr/testify/require"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
 	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/row"
 )
 
 // discardWriter is a RowWriter that discards all data (for benchmarking).
 type discardWriter struct{}
 
-func (d *discardWriter) WriteRow(_ netip.Prefix, _ []mmdbtype.DataType) error {
+func (d *discardWriter) WriteRow(_ netip.Prefix, _ row.Row) error {
 	return nil
 }
 
@@ -3,6 +3,9 @@ package merger
 import (
 	"errors"
 	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
@@ -11,6 +14,9 @@ import (
 
 	"github.com/maxmind/mmdbconvert/internal/config"
 	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
 )
 
 const (
@@ -269,30 +275,73 @@ func TestMerger_MissingDatabase(t *testing.T) {
 	assert.Contains(t, err.Error(), "nonexistent")
 }
 
-func TestMerger_MixedIPVersionsFails(t *testing.T) {
+func TestMerger_MixedIPVersions(t *testing.T) {
 	databases := map[string]config.Database{
-		"ipv4": {
-			Path: testDataDir + "/MaxMind-DB-test-ipv4-24.mmdb",
-		},
-		"ipv6": {
-			Path: testDataDir + "/MaxMind-DB-test-ipv6-32.mmdb",
-		},
+		"ipv4": {Name: "ipv4", Path: writeTestIPv4Database(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"country": mmdbtype.String("AU")},
+		})},
+		"ipv6": {Name: "ipv6", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/25":    {"is_anonymous": mmdbtype.Bool(true)},
+			"2001:db8::/32": {"is_anonymous": mmdbtype.Bool(true)},
+		})},
 	}
-
 	readers, err := mmdb.OpenDatabases(databases)
 	require.NoError(t, err)
 	defer readers.Close()
 
-	cfg := &config.Config{
-		Columns: []config.Column{
-			{Name: "v4", Database: "ipv4", Path: config.Path{"value"}},
-			{Name: "v6", Database: "ipv6", Path: config.Path{"value"}},
-		},
+	country := config.Column{Name: "country", Database: "ipv4", Path: config.Path{"country"}}
+	anonymous := config.Column{Name: "anonymous", Database: "ipv6", Path: config.Path{"is_anonymous"}}
+	ipv4Record := config.Column{Name: "ipv4_record", Database: "ipv4"}
+
+	// Either database may be iterated first
+	for name, columns := range map[string][]config.Column{
+		"ipv4 first": {country, anonymous, ipv4Record},
+		"ipv6 first": {anonymous, country, ipv4Record},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{Columns: columns}
+			w := &mockWriter{}
+			m, err := NewMerger(readers, cfg, w)
+			require.NoError(t, err)
+			require.NoError(t, m.Merge())
+
+			values := func(prefix string) map[string]mmdbtype.DataType {
+				for _, r := range w.rows {
+					if r.prefix == netip.MustParsePrefix(prefix) {
+						got := map[string]mmdbtype.DataType{}
+						for i, col := range columns {
+							got[string(col.Name)] = r.data[i]
+						}
+						return got
+					}
+				}
+				t.Fatalf("no row for %s", prefix)
+				return nil
+			}
+			record := mmdbtype.Map{"country": mmdbtype.String("AU")}
+			assert.Equal(t, map[string]mmdbtype.DataType{
+				"country":     mmdbtype.String("AU"),
+				"anonymous":   mmdbtype.Bool(true),
+				"ipv4_record": record,
+			}, values("1.0.0.0/25"))
+			assert.Equal(t, map[string]mmdbtype.DataType{
+				"country":     mmdbtype.String("AU"),
+				"anonymous":   nil,
+				"ipv4_record": record,
+			}, values("1.0.0.128/25"))
+			assert.Equal(t, map[string]mmdbtype.DataType{
+				"country":     nil,
+				"anonymous":   mmdbtype.Bool(true),
+				"ipv4_record": nil,
+			}, values("2001:db8::/32"))
+			assert.Len(t, w.rows, 3)
+
+			prefix, got, err := m.Lookup(netip.MustParseAddr("2001:db8::1"))
+			require.NoError(t, err)
+			assert.Equal(t, netip.MustParsePrefix("2001:db8::/32"), prefix)
+			assert.Contains(t, got, mmdbtype.DataType(mmdbtype.Bool(true)))
+		})
 	}
-
-	_, err = NewMerger(readers, cfg, &mockWriter{})
-	require.Error(t, err)
-	assert.Contains(t, err.Error(), "mix IPv4-only")
 }
 
 func TestMerger_NoColumns(t *testing.T) {
@@ -804,3 +853,1217 @@ func mmdbEqual(a, b mmdbtype.DataType) bool {
 	}
 	return a.Equal(b)
 }
+
+func TestMerger_Lookup(t *testing.T) {
+	cityPath := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"81.2.69.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")}},
+	})
+	anonPath := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"81.2.69.128/25": {"is_anonymous": mmdbtype.Bool(true)},
+	})
+
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"city": {Path: cityPath},
+		"anon": {Path: anonPath},
+	})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country", "iso_code"}},
+			{Name: "is_anonymous", Database: "anon", Path: config.Path{"is_anonymous"}},
+		},
+	}
+
+	m, err := NewMerger(readers, cfg, &mockWriter{})
+	require.NoError(t, err)
+
+	prefix, row, err := m.Lookup(netip.MustParseAddr("81.2.69.200"))
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("81.2.69.128/25"), prefix)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("GB"), mmdbtype.Bool(true)}, row)
+
+	prefix, row, err = m.Lookup(netip.MustParseAddr("81.2.69.1"))
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("81.2.69.0/25"), prefix)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("GB"), nil}, row)
+
+	_, row, err = m.Lookup(netip.MustParseAddr("1.1.1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, []mmdbtype.DataType{nil, nil}, row)
+}
+
+func TestMerger_DecodePathsMatchesFullRecord(t *testing.T) {
+	dbPath := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"81.2.69.0/24": {
+			"country":  mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+			"location": mmdbtype.Map{"latitude": mmdbtype.Float64(51.5)},
+			"subdivisions": mmdbtype.Slice{
+				mmdbtype.Map{"iso_code": mmdbtype.String("ENG")},
+				mmdbtype.Map{"iso_code": mmdbtype.String("LND")},
+			},
+		},
+		"81.2.70.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("FR")}},
+	})
+
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"city": {Path: dbPath},
+	})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	columns := []config.Column{
+		{Name: "country", Database: "city", Path: config.Path{"country", "iso_code"}},
+		{Name: "latitude", Database: "city", Path: config.Path{"location", "latitude"}},
+		{Name: "subdivision", Database: "city", Path: config.Path{"subdivisions", int64(-1), "iso_code"}},
+		{Name: "location", Database: "city", Path: config.Path{"location"}},
+	}
+
+	pathWriter := &mockWriter{}
+	pathMerger, err := NewMerger(readers, &config.Config{Columns: columns}, pathWriter)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true}, pathMerger.decodePaths)
+	require.NoError(t, pathMerger.Merge())
+
+	// A column without a path needs the full record
+	fullColumns := append(slices.Clone(columns), config.Column{Name: "record", Database: "city"})
+	fullWriter := &mockWriter{}
+	fullMerger, err := NewMerger(readers, &config.Config{Columns: fullColumns}, fullWriter)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, fullMerger.decodePaths)
+	require.NoError(t, fullMerger.Merge())
+
+	require.Len(t, pathWriter.rows, 2)
+	require.Len(t, fullWriter.rows, len(pathWriter.rows))
+	for i, pathRow := range pathWriter.rows {
+		fullRow := fullWriter.rows[i]
+		assert.Equal(t, fullRow.prefix, pathRow.prefix)
+		assert.True(t, row.Row(fullRow.data[:len(columns)]).Equal(pathRow.data),
+			"row mismatch for %s", pathRow.prefix)
+	}
+
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("GB"),
+		mmdbtype.Float64(51.5),
+		mmdbtype.String("LND"),
+		mmdbtype.Map{"latitude": mmdbtype.Float64(51.5)},
+	}, pathWriter.rows[0].data)
+}
+
+func TestMerger_DecodePathsTypeMismatch(t *testing.T) {
+	dbPath := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"81.2.69.0/24": {"leaf": mmdbtype.String("value")},
+	})
+
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"city": {Path: dbPath},
+	})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "nested", Database: "city", Path: config.Path{"leaf", "nested"}},
+		},
+	}
+	m, err := NewMerger(readers, cfg, &mockWriter{})
+	require.NoError(t, err)
+
+	err = m.Merge()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decoding path for column 'nested'")
+}
+
+func TestMerger_PreloadMatchesIteration(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")}},
+			"81.2.70.0/23": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("FR")}},
+			"1.0.0.0/24":   {"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")}},
+		})},
+		// Overrides both split the city networks and span several of them
+		"overrides": {Name: "overrides", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.64/26": {"country": mmdbtype.String("XX")},
+			"81.2.70.0/24":  {"country": mmdbtype.String("YY")},
+			"1.0.0.0/23":    {"note": mmdbtype.String("block")},
+		})},
+	}
+	newConfig := func(preload bool) *config.Config {
+		overrides := databases["overrides"]
+		overrides.Preload = preload
+		return &config.Config{
+			Databases: []config.Database{databases["city"], overrides},
+			Columns: []config.Column{
+				{Name: "override", Database: "overrides", Path: config.Path{"country"}},
+				{Name: "country", Database: "city", Path: config.Path{"country", "iso_code"}},
+				{Name: "overrides_record", Database: "overrides"},
+			},
+		}
+	}
+	merge := func(cfg *config.Config) (*Merger, []mockRow) {
+		readers, err := mmdb.OpenDatabases(databases)
+		require.NoError(t, err)
+		t.Cleanup(func() { readers.Close() })
+
+		w := &mockWriter{}
+		m, err := NewMerger(readers, cfg, w)
+		require.NoError(t, err)
+		require.NoError(t, m.Merge())
+		return m, w.rows
+	}
+
+	_, expected := merge(newConfig(false))
+	m, rows := merge(newConfig(true))
+	require.Len(t, m.preloaded, 1)
+	assert.Equal(t, []string{"city", "overrides"}, m.dbNamesList)
+	assert.Equal(t, expected, rows)
+
+	prefix, values, err := m.Lookup(netip.MustParseAddr("81.2.69.70"))
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("81.2.69.64/26"), prefix)
+	assert.Equal(t, mmdbtype.String("XX"), values[0])
+	assert.Equal(t, mmdbtype.String("GB"), values[1])
+}
+
+func TestMerger_TagColumns(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")}},
+			"1.0.0.0/24":   {"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")}},
+		})},
+		"anon": {Name: "anon", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.128/25": {"is_anonymous": mmdbtype.Bool(true)},
+		})},
+	}
+	vendor := config.Column{
+		Name:     "anon_vendor",
+		Database: "anon",
+		Tag:      "vendor",
+		TagValue: mmdbtype.String("acme"),
+	}
+	country := config.Column{
+		Name:     "country",
+		Database: "city",
+		Path:     config.Path{"country", "iso_code"},
+	}
+	expected := []mockRow{
+		{
+			prefix: netip.MustParsePrefix("1.0.0.0/24"),
+			data:   []mmdbtype.DataType{mmdbtype.String("AU"), nil},
+		},
+		{
+			prefix: netip.MustParsePrefix("81.2.69.0/25"),
+			data:   []mmdbtype.DataType{mmdbtype.String("GB"), nil},
+		},
+		{
+			prefix: netip.MustParsePrefix("81.2.69.128/25"),
+			data:   []mmdbtype.DataType{mmdbtype.String("GB"), mmdbtype.String("acme")},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		columns []config.Column
+		preload bool
+	}{
+		{
+			name:    "decoded by path",
+			columns: []config.Column{country, vendor},
+		},
+		{
+			// The full anon record is decoded for the other column
+			name: "decoded record",
+			columns: []config.Column{
+				country,
+				vendor,
+				{Name: "anon_record", Database: "anon"},
+			},
+		},
+		{
+			name:    "preloaded",
+			columns: []config.Column{country, vendor},
+			preload: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readers, err := mmdb.OpenDatabases(databases)
+			require.NoError(t, err)
+			defer readers.Close()
+
+			anon := databases["anon"]
+			anon.Preload = tt.preload
+			cfg := &config.Config{
+				Databases: []config.Database{databases["city"], anon},
+				Columns:   tt.columns,
+			}
+			w := &mockWriter{}
+			m, err := NewMerger(readers, cfg, w)
+			require.NoError(t, err)
+			require.NoError(t, m.Merge())
+
+			rows := make([]mockRow, len(w.rows))
+			for i, r := range w.rows {
+				rows[i] = mockRow{prefix: r.prefix, data: r.data[:2]}
+			}
+			assert.Equal(t, expected, rows)
+		})
+	}
+}
+
+func TestMerger_PrefixLengthColumns(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")}},
+			"1.0.0.0/24":   {"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")}},
+		})},
+		"anon": {Name: "anon", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.128/25": {"is_anonymous": mmdbtype.Bool(true)},
+		})},
+	}
+	columns := []config.Column{
+		{Name: "country", Database: "city", Path: config.Path{"country", "iso_code"}},
+		{Name: "city_prefix_length", Database: "city", PrefixLength: true},
+		{Name: "anon_prefix_length", Database: "anon", PrefixLength: true},
+	}
+	expected := []mockRow{
+		{
+			prefix: netip.MustParsePrefix("1.0.0.0/24"),
+			data:   []mmdbtype.DataType{mmdbtype.String("AU"), mmdbtype.Uint16(24), nil},
+		},
+		{
+			// The city network is reported, not the smaller merged network
+			prefix: netip.MustParsePrefix("81.2.69.0/25"),
+			data:   []mmdbtype.DataType{mmdbtype.String("GB"), mmdbtype.Uint16(24), nil},
+		},
+		{
+			prefix: netip.MustParsePrefix("81.2.69.128/25"),
+			data: []mmdbtype.DataType{
+				mmdbtype.String("GB"),
+				mmdbtype.Uint16(24),
+				mmdbtype.Uint16(25),
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		columns []config.Column
+		preload bool
+	}{
+		{
+			name:    "decoded by path",
+			columns: columns,
+		},
+		{
+			name:    "decoded record",
+			columns: append(slices.Clone(columns), config.Column{Name: "anon_record", Database: "anon"}),
+		},
+		{
+			name:    "preloaded",
+			columns: columns,
+			preload: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readers, err := mmdb.OpenDatabases(databases)
+			require.NoError(t, err)
+			defer readers.Close()
+
+			anon := databases["anon"]
+			anon.Preload = tt.preload
+			cfg := &config.Config{
+				Databases: []config.Database{databases["city"], anon},
+				Columns:   tt.columns,
+			}
+			w := &mockWriter{}
+			m, err := NewMerger(readers, cfg, w)
+			require.NoError(t, err)
+			require.NoError(t, m.Merge())
+
+			rows := make([]mockRow, len(w.rows))
+			for i, r := range w.rows {
+				rows[i] = mockRow{prefix: r.prefix, data: r.data[:3]}
+			}
+			assert.Equal(t, expected, rows)
+
+			_, values, err := m.Lookup(netip.MustParseAddr("81.2.69.200"))
+			require.NoError(t, err)
+			assert.Equal(t, expected[2].data, values[:3])
+		})
+	}
+}
+
+func TestMerger_NonMapRecords(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"country": mmdbtype.String("AU")},
+			"2.0.0.0/24": {"country": mmdbtype.String("FR")},
+		})},
+		"score": {Name: "score", Path: writeTestDatabase(t, map[string]mmdbtype.DataType{
+			"1.0.0.0/24": mmdbtype.Slice{mmdbtype.Uint32(87)},
+			"2.0.0.0/24": mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.String("b")},
+		})},
+	}
+	expected := []mockRow{
+		{
+			prefix: netip.MustParsePrefix("1.0.0.0/24"),
+			data:   []mmdbtype.DataType{mmdbtype.String("AU"), mmdbtype.Slice{mmdbtype.Uint32(87)}, mmdbtype.Uint32(87)},
+		},
+		{
+			prefix: netip.MustParsePrefix("2.0.0.0/24"),
+			data: []mmdbtype.DataType{
+				mmdbtype.String("FR"),
+				mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.String("b")},
+				mmdbtype.String("b"),
+			},
+		},
+	}
+
+	for name, preload := range map[string]bool{"iterated": false, "preloaded": true} {
+		t.Run(name, func(t *testing.T) {
+			readers, err := mmdb.OpenDatabases(databases)
+			require.NoError(t, err)
+			defer readers.Close()
+
+			score := databases["score"]
+			score.Preload = preload
+			cfg := &config.Config{
+				Databases: []config.Database{databases["city"], score},
+				Columns: []config.Column{
+					{Name: "country", Database: "city", Path: config.Path{"country"}},
+					{Name: "score", Database: "score"},
+					{Name: "last", Database: "score", Path: config.Path{int64(-1)}},
+				},
+			}
+			w := &mockWriter{}
+			m, err := NewMerger(readers, cfg, w)
+			require.NoError(t, err)
+			require.NoError(t, m.Merge())
+
+			var rows []mockRow
+			for _, r := range w.rows {
+				if r.data[0] != nil {
+					rows = append(rows, r)
+				}
+			}
+			assert.Equal(t, expected, rows)
+		})
+	}
+}
+
+func TestMerger_TransliteratedColumns(t *testing.T) {
+	path := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"1.0.0.0/24": {"city": mmdbtype.Map{"names": mmdbtype.Map{"de": mmdbtype.String("München")}}},
+	})
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"city": {Name: "city", Path: path},
+	})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{{Name: "city", Path: path}},
+		Columns: []config.Column{
+			{Name: "name", Database: "city", Path: config.Path{"city", "names", "de"}},
+			{
+				Name:          "name_ascii",
+				Database:      "city",
+				Path:          config.Path{"city", "names", "de"},
+				Transliterate: "de",
+			},
+			{Name: "city", Database: "city", Path: config.Path{"city"}, Transliterate: "ascii"},
+		},
+	}
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+
+	require.Len(t, w.rows, 1)
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("München"),
+		mmdbtype.String("Muenchen"),
+		mmdbtype.Map{"names": mmdbtype.Map{"de": mmdbtype.String("Munchen")}},
+	}, w.rows[0].data)
+}
+
+func TestMerger_TransformedColumns(t *testing.T) {
+	path := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"1.0.0.0/24": {"city": mmdbtype.Map{"names": mmdbtype.Map{
+			"en": mmdbtype.String("  SAINT-ÉTIENNE "),
+			"fr": mmdbtype.String("saint  étienne"),
+		}}},
+	})
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"city": {Name: "city", Path: path},
+	})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{{Name: "city", Path: path}},
+		Transforms: map[string]config.TransformConfig{
+			"clean_name": {Steps: []string{"trim", "collapse_ws", "title_case"}},
+		},
+		Columns: []config.Column{
+			{
+				Name:      "name",
+				Database:  "city",
+				Path:      config.Path{"city", "names", "en"},
+				Transform: "clean_name",
+			},
+			// Transliteration comes first
+			{
+				Name:          "name_ascii",
+				Database:      "city",
+				Path:          config.Path{"city", "names", "en"},
+				Transliterate: "ascii",
+				Transform:     "clean_name",
+			},
+			{Name: "names", Database: "city", Path: config.Path{"city", "names"}, Transform: "clean_name"},
+		},
+	}
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+
+	require.Len(t, w.rows, 1)
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("Saint-Étienne"),
+		mmdbtype.String("Saint-Etienne"),
+		mmdbtype.Map{
+			"en": mmdbtype.String("Saint-Étienne"),
+			"fr": mmdbtype.String("Saint Étienne"),
+		},
+	}, w.rows[0].data)
+}
+
+func TestMerger_CountryTransform(t *testing.T) {
+	databases := map[string]config.Database{
+		"geo": {Name: "geo", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"country": mmdbtype.String("DEU")},
+			"1.0.1.0/24": {"country": mmdbtype.String("EU")},
+			"1.0.2.0/24": {"country": mmdbtype.String("XK")},
+		})},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{databases["geo"]},
+		Transforms: map[string]config.TransformConfig{
+			"country": {
+				Steps:          []string{"country_alpha2"},
+				CountryAliases: map[string]string{"XK": "XK"},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country", Database: "geo", Path: config.Path{"country"}, Transform: "country"},
+		},
+	}
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+
+	require.Len(t, w.rows, 3)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("DE")}, w.rows[0].data)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("EU")}, w.rows[1].data)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("XK")}, w.rows[2].data)
+	assert.Equal(
+		t,
+		map[mmdbtype.String]map[string]int{"country": {"EU": 1}},
+		m.UnknownCountries(),
+	)
+
+	// With unknown_countries = "error", the merge fails
+	tc := cfg.Transforms["country"]
+	tc.UnknownCountries = "error"
+	cfg.Transforms["country"] = tc
+	m, err = NewMerger(readers, cfg, &mockWriter{})
+	require.NoError(t, err)
+	require.EqualError(
+		t,
+		m.Merge(),
+		"transforming column 'country': unknown country code 'EU'",
+	)
+}
+
+func TestMerger_OrgNameTransform(t *testing.T) {
+	databases := map[string]config.Database{
+		"asn": {Name: "asn", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"org": mmdbtype.String("Example, Inc.")},
+			"1.0.1.0/24": {"org": mmdbtype.String("EXAMPLE INC")},
+			"1.0.2.0/24": {"org": mmdbtype.String("Example Cloud LLC")},
+		})},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	orgNames := filepath.Join(t.TempDir(), "org_names.csv")
+	require.NoError(t, os.WriteFile(orgNames, []byte("name,canonical\nExample Cloud,Example\n"), 0o600))
+
+	cfg := &config.Config{
+		Databases: []config.Database{databases["asn"]},
+		Transforms: map[string]config.TransformConfig{
+			"org": {Steps: []string{"org_name"}},
+		},
+		Columns: []config.Column{
+			{Name: "org", Database: "asn", Path: config.Path{"org"}, Transform: "org"},
+		},
+	}
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+
+	// The variants of the name are merged
+	require.Len(t, w.rows, 2)
+	assert.Equal(t, "1.0.0.0/23", w.rows[0].prefix.String())
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("example")}, w.rows[0].data)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("example cloud")}, w.rows[1].data)
+
+	// With org_names_file, names are mapped to their canonical name
+	cfg.Transforms["org"] = config.TransformConfig{Steps: []string{"org_name"}, OrgNamesFile: orgNames}
+	w = &mockWriter{}
+	m, err = NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+	require.Len(t, w.rows, 2)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("Example")}, w.rows[1].data)
+
+	cfg.Transforms["org"] = config.TransformConfig{
+		Steps:        []string{"org_name"},
+		OrgNamesFile: filepath.Join(t.TempDir(), "missing.csv"),
+	}
+	_, err = NewMerger(readers, cfg, &mockWriter{})
+	require.ErrorContains(t, err, "transform 'org': loading org_names_file: ")
+}
+
+func TestMerger_TimeZoneColumns(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"time_zone": mmdbtype.String("Europe/Berlin")},
+			"1.0.1.0/24": {"time_zone": mmdbtype.String("Asia/Kolkata")},
+			"1.0.2.0/24": {"time_zone": mmdbtype.String("Mars/Olympus_Mons")},
+		})},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases:         []config.Database{databases["city"]},
+		TimeZoneReference: "2025-07-01T12:00:00Z",
+		Columns: []config.Column{
+			{
+				Name:     "utc_offset",
+				Database: "city",
+				Path:     config.Path{"time_zone"},
+				TimeZone: config.TimeZoneUTCOffset,
+			},
+			{
+				Name:     "dst",
+				Database: "city",
+				Path:     config.Path{"time_zone"},
+				TimeZone: config.TimeZoneDST,
+			},
+		},
+	}
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+
+	// Unknown time zones leave the columns empty, so their row is left out
+	require.Len(t, w.rows, 2)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.Int32(7200), mmdbtype.Bool(true)}, w.rows[0].data)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.Int32(19800), mmdbtype.Bool(false)}, w.rows[1].data)
+
+	// In winter, Berlin is back to UTC+1
+	cfg.TimeZoneReference = "2025-01-15T12:00:00Z"
+	m, err = NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	_, values, err := m.Lookup(netip.MustParseAddr("1.0.0.1"))
+	require.NoError(t, err)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.Int32(3600), mmdbtype.Bool(false)}, values)
+}
+
+func TestMerger_CountryRollupColumns(t *testing.T) {
+	databases := map[string]config.Database{
+		"geo": {Name: "geo", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"country": mmdbtype.String("FR")},
+			"1.0.1.0/24": {"country": mmdbtype.String("NO")},
+			"1.0.2.0/24": {"country": mmdbtype.String("AQ")},
+		})},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	column := func(name, property string) config.Column {
+		return config.Column{
+			Name:          mmdbtype.String(name),
+			Database:      "geo",
+			Path:          config.Path{"country"},
+			CountryRollup: property,
+		}
+	}
+	cfg := &config.Config{
+		Databases: []config.Database{databases["geo"]},
+		Columns: []config.Column{
+			column("continent", "continent_code"),
+			column("is_eu", "is_in_european_union"),
+			column("un_region", "un_region"),
+		},
+	}
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+
+	require.Len(t, w.rows, 3)
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("EU"), mmdbtype.Bool(true), mmdbtype.String("Europe"),
+	}, w.rows[0].data)
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("EU"), mmdbtype.Bool(false), mmdbtype.String("Europe"),
+	}, w.rows[1].data)
+	// Antarctica is in no UN region
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("AN"), mmdbtype.Bool(false), nil,
+	}, w.rows[2].data)
+
+	cfg.CountryRollupFile = filepath.Join(t.TempDir(), "missing.csv")
+	_, err = NewMerger(readers, cfg, w)
+	require.ErrorContains(t, err, "loading country_rollup_file")
+}
+
+func TestMerger_DefaultValues(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/23": {"country": mmdbtype.String("AU")},
+		})},
+		"anon": {Name: "anon", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"is_anonymous": mmdbtype.Bool(true)},
+		})},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{databases["city"], databases["anon"]},
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country"}},
+			{
+				Name:     "is_anonymous",
+				Database: "anon",
+				Path:     config.Path{"is_anonymous"},
+				Default:  mmdbtype.Bool(false),
+			},
+			{
+				Name:     "is_public_proxy",
+				Database: "anon",
+				Path:     config.Path{"is_public_proxy"},
+				Default:  mmdbtype.Bool(false),
+			},
+		},
+	}
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	require.NoError(t, m.Merge())
+
+	// The default only fills in values missing from a record, so networks
+	// without a record in the database stay empty
+	require.Len(t, w.rows, 2)
+	assert.Equal(t, netip.MustParsePrefix("1.0.0.0/24"), w.rows[0].prefix)
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("AU"),
+		mmdbtype.Bool(true),
+		mmdbtype.Bool(false),
+	}, w.rows[0].data)
+	assert.Equal(t, netip.MustParsePrefix("1.0.1.0/24"), w.rows[1].prefix)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("AU"), nil, nil}, w.rows[1].data)
+}
+
+func TestMerger_ScoreColumns(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/22": {"country": mmdbtype.String("AU")},
+		})},
+		"anon": {Name: "anon", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {
+				"is_anonymous_vpn": mmdbtype.Bool(true),
+				"is_tor_exit_node": mmdbtype.Bool(true),
+				"confidence":       mmdbtype.Uint16(50),
+			},
+			"1.0.1.0/24": {"is_anonymous_vpn": mmdbtype.Bool(false)},
+			"1.0.2.0/24": {"is_tor_exit_node": mmdbtype.String("yes")},
+		})},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{databases["city"], databases["anon"]},
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country"}},
+			{
+				Name: "risk",
+				Kind: config.ColumnKindScore,
+				Weights: map[string]float64{
+					"is_anonymous_vpn": 40,
+					"is_tor_exit_node": 60,
+					"confidence":       0.1,
+				},
+			},
+			{Name: "is_anonymous_vpn", Database: "anon", Path: config.Path{"is_anonymous_vpn"}},
+			{Name: "is_tor_exit_node", Database: "anon", Path: config.Path{"is_tor_exit_node"}},
+			{Name: "confidence", Database: "anon", Path: config.Path{"confidence"}},
+		},
+	}
+	require.Equal(t, []string{"city", "anon"}, DatabaseNames(cfg))
+
+	w := &mockWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+
+	_, values, err := m.Lookup(netip.MustParseAddr("1.0.0.1"))
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Float64(105), values[1])
+
+	// False counts as 0, so the score is set
+	_, values, err = m.Lookup(netip.MustParseAddr("1.0.1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Float64(0), values[1])
+
+	// Without any input, the score is missing
+	_, values, err = m.Lookup(netip.MustParseAddr("1.0.3.1"))
+	require.NoError(t, err)
+	assert.Nil(t, values[1])
+
+	_, _, err = m.Lookup(netip.MustParseAddr("1.0.2.1"))
+	require.EqualError(
+		t,
+		err,
+		"computing score column 'risk': 'is_tor_exit_node' must be a boolean or number, got mmdbtype.String",
+	)
+}
+
+func TestIteratedDatabaseNames(t *testing.T) {
+	cfg := &config.Config{
+		Databases: []config.Database{
+			{Name: "city"},
+			{Name: "asn"},
+			{Name: "overrides", Preload: true},
+			{Name: "anon"},
+		},
+		Columns: []config.Column{
+			{Name: "country", Database: "city"},
+			{Name: "override", Database: "overrides"},
+			{Name: "asn", Database: "asn"},
+			{Name: "is_anonymous", Database: "anon"},
+			{Name: "city", Database: "city"},
+		},
+	}
+	assert.Equal(t, []string{"city", "asn", "anon"}, IteratedDatabaseNames(cfg))
+
+	cfg.DriverDatabase = "anon"
+	assert.Equal(t, []string{"anon", "city", "asn"}, IteratedDatabaseNames(cfg))
+	assert.Equal(t, []string{"city", "overrides", "asn", "anon"}, DatabaseNames(cfg))
+
+	cfg.DriverDatabase = "city"
+	assert.Equal(t, []string{"city", "asn", "anon"}, IteratedDatabaseNames(cfg))
+
+	// Databases in lookup mode are nested innermost
+	cfg.DriverDatabase = ""
+	cfg.Databases[0].Mode = config.DatabaseModeLookup
+	assert.Equal(t, []string{"asn", "anon", "city"}, IteratedDatabaseNames(cfg))
+}
+
+func TestMerger_DriverDatabase(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"country": mmdbtype.String("GB")},
+			"81.2.70.0/23": {"country": mmdbtype.String("FR")},
+			"1.0.0.0/24":   {"country": mmdbtype.String("AU")},
+		})},
+		"anon": {Name: "anon", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.128/25": {"is_anonymous": mmdbtype.Bool(true)},
+			"81.2.71.0/24":   {"is_anonymous": mmdbtype.Bool(true)},
+		})},
+		"asn": {Name: "asn", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.0.0/16": {"autonomous_system_number": mmdbtype.Uint32(20712)},
+		})},
+	}
+	merge := func(driver string) *mockWriter {
+		readers, err := mmdb.OpenDatabases(databases)
+		require.NoError(t, err)
+		defer readers.Close()
+
+		cfg := &config.Config{
+			DriverDatabase: driver,
+			Databases: []config.Database{
+				databases["city"], databases["anon"], databases["asn"],
+			},
+			Columns: []config.Column{
+				{Name: "country", Database: "city", Path: config.Path{"country"}},
+				{Name: "is_anonymous", Database: "anon", Path: config.Path{"is_anonymous"}},
+				{Name: "asn", Database: "asn", Path: config.Path{"autonomous_system_number"}},
+			},
+		}
+		w := &mockWriter{}
+		m, err := NewMerger(readers, cfg, w)
+		require.NoError(t, err)
+		require.NoError(t, m.Merge())
+		return w
+	}
+
+	// The driver only changes the order of iteration, not the output
+	expected := merge("")
+	require.NotEmpty(t, expected.rows)
+	for _, driver := range []string{"anon", "asn"} {
+		assert.Equal(t, expected.rows, merge(driver).rows, driver)
+	}
+}
+
+func TestMerger_LookupMode(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.0.0/16": {"country": mmdbtype.String("GB")},
+			"1.0.0.0/24":  {"country": mmdbtype.String("AU")},
+		})},
+		"asn": {Name: "asn", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.0.0/18":  {"autonomous_system_number": mmdbtype.Uint32(20712)},
+			"81.2.69.0/24": {"autonomous_system_number": mmdbtype.Uint32(13335)},
+			"1.0.0.0/8":    {"autonomous_system_number": mmdbtype.Uint32(4608)},
+		})},
+	}
+	merge := func(mode string, imprecise bool) *mockWriter {
+		readers, err := mmdb.OpenDatabases(databases)
+		require.NoError(t, err)
+		defer readers.Close()
+
+		asn := databases["asn"]
+		asn.Mode = mode
+		asn.AllowImpreciseLookup = imprecise
+		cfg := &config.Config{
+			Databases: []config.Database{databases["city"], asn},
+			Columns: []config.Column{
+				{Name: "country", Database: "city", Path: config.Path{"country"}},
+				{Name: "asn", Database: "asn", Path: config.Path{"autonomous_system_number"}},
+			},
+		}
+		w := &mockWriter{}
+		m, err := NewMerger(readers, cfg, w)
+		require.NoError(t, err)
+		require.NoError(t, m.Merge())
+		return w
+	}
+
+	// Lookups fall back to iteration where the database splits a network,
+	// so the output is exact
+	expected := merge("", false)
+	assert.Equal(t, expected.rows, merge(config.DatabaseModeLookup, false).rows)
+
+	// Imprecise lookups use the record of each network's first address for
+	// the whole of the city's /16
+	asns := func(w *mockWriter) []mmdbtype.DataType {
+		var asns []mmdbtype.DataType
+		for _, r := range w.rows {
+			if r.data[0] == mmdbtype.String("GB") {
+				asns = append(asns, r.data[1])
+			}
+		}
+		return asns
+	}
+	assert.Contains(t, asns(expected), mmdbtype.Uint32(13335))
+	assert.Equal(
+		t,
+		[]mmdbtype.DataType{mmdbtype.Uint32(20712)},
+		slices.Compact(asns(merge(config.DatabaseModeLookup, true))),
+	)
+}
+
+func TestMerger_AllDatabasesInLookupMode(t *testing.T) {
+	dbPath := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"81.2.69.0/24": {"country": mmdbtype.String("GB")},
+	})
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{"city": {Path: dbPath}})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{{Name: "city", Path: dbPath, Mode: config.DatabaseModeLookup}},
+		Columns:   []config.Column{{Name: "country", Database: "city", Path: config.Path{"country"}}},
+	}
+	_, err = NewMerger(readers, cfg, &mockWriter{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least one must be iterated")
+}
+
+func TestMerger_AllDatabasesPreloaded(t *testing.T) {
+	dbPath := writeTestDatabase(t, map[string]mmdbtype.Map{
+		"81.2.69.0/24": {"country": mmdbtype.String("GB")},
+	})
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{"city": {Path: dbPath}})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{{Name: "city", Path: dbPath, Preload: true}},
+		Columns:   []config.Column{{Name: "country", Database: "city", Path: config.Path{"country"}}},
+	}
+	_, err = NewMerger(readers, cfg, &mockWriter{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least one must be iterated")
+}
+
+func TestMerger_Stats(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")}},
+			"1.0.0.0/24":   {"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")}},
+		})},
+		"overrides": {Name: "overrides", Preload: true, Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.64/26": {"country": mmdbtype.String("XX")},
+		})},
+	}
+	cfg := &config.Config{
+		Databases: []config.Database{databases["city"], databases["overrides"]},
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country", "iso_code"}},
+			{Name: "override", Database: "overrides", Path: config.Path{"country"}},
+		},
+	}
+	merge := func(enable bool) (*Merger, []mockRow) {
+		readers, err := mmdb.OpenDatabases(databases)
+		require.NoError(t, err)
+		t.Cleanup(func() { readers.Close() })
+
+		w := &mockWriter{}
+		m, err := NewMerger(readers, cfg, w)
+		require.NoError(t, err)
+		if enable {
+			m.EnableStats()
+		}
+		require.NoError(t, m.Merge())
+		return m, w.rows
+	}
+
+	m, expected := merge(false)
+	assert.Nil(t, m.Stats())
+
+	m, rows := merge(true)
+	assert.Equal(t, expected, rows, "collecting statistics does not change the output")
+
+	stats := m.Stats()
+	require.NotNil(t, stats)
+	require.Len(t, stats.Databases, 2)
+	assert.Equal(t, "city", stats.Databases[0].Name)
+	assert.Equal(t, "overrides", stats.Databases[1].Name)
+	assert.Positive(t, stats.Databases[0].Iterate)
+	assert.Positive(t, stats.Databases[0].Decode)
+	assert.Positive(t, stats.Databases[1].Iterate)
+	assert.Positive(t, stats.Write)
+	assert.Positive(t, stats.Networks)
+}
+
+func TestMerger_Checkpoints(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"country": mmdbtype.String("GB")},
+			"1.0.0.0/24":   {"country": mmdbtype.String("AU")},
+		})},
+		"asn": {Name: "asn", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"81.2.69.128/25": {"asn": mmdbtype.Uint32(20712)},
+		})},
+	}
+	cfg := &config.Config{
+		Databases: []config.Database{databases["city"], databases["asn"]},
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country"}},
+			{Name: "asn", Database: "asn", Path: config.Path{"asn"}},
+		},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	w := &checkpointWriter{}
+	m, err := NewMerger(readers, cfg, w)
+	require.NoError(t, err)
+	w.checkpoints = m.EnableCheckpoints()
+	require.NoError(t, m.Merge())
+
+	// Every row written asks for the checkpoint of the next network.
+	require.NotEmpty(t, w.taken)
+	var networks int64
+	for _, checkpoint := range w.taken {
+		assert.Greater(t, checkpoint.Networks, networks)
+		networks = checkpoint.Networks
+		require.Len(t, checkpoint.Databases, 2)
+		assert.Equal(t, "city", checkpoint.Databases[0].Name)
+		assert.Equal(t, "asn", checkpoint.Databases[1].Name)
+		for _, db := range checkpoint.Databases {
+			assert.True(t, network.ContainsPrefix(db.Network, checkpoint.Network))
+		}
+	}
+	i := slices.IndexFunc(w.taken, func(c Checkpoint) bool {
+		return c.Network == netip.MustParsePrefix("81.2.69.128/25")
+	})
+	require.GreaterOrEqual(t, i, 0, "the row of 81.2.69.0/25 is written at the network after it")
+	assert.Equal(t, []DatabasePosition{
+		{Name: "city", Network: netip.MustParsePrefix("81.2.69.0/24")},
+		{Name: "asn", Network: netip.MustParsePrefix("81.2.69.128/25")},
+	}, w.taken[i].Databases)
+}
+
+// checkpointWriter asks for a checkpoint of the merge at every row written.
+type checkpointWriter struct {
+	checkpoints *Checkpoints
+	taken       []Checkpoint
+}
+
+func (w *checkpointWriter) WriteRow(netip.Prefix, row.Row) error {
+	if checkpoint, ok := w.checkpoints.Latest(); ok {
+		w.taken = append(w.taken, checkpoint)
+	}
+	return nil
+}
+
+// writeTestDatabase builds a small IPv6 MMDB containing records and returns
+// its path.
+func writeTestDatabase[V mmdbtype.DataType](t *testing.T, records map[string]V) string {
+	t.Helper()
+
+	return mmdbtest.WriteTemp(t, mmdbtest.Options(), records)
+}
+
+// writeTestIPv4Database is writeTestDatabase for an IPv4-only MMDB.
+func writeTestIPv4Database(t *testing.T, records map[string]mmdbtype.Map) string {
+	t.Helper()
+
+	opts := mmdbtest.Options()
+	opts.IPVersion = 4
+	return mmdbtest.WriteTemp(t, opts, records)
+}
+
+func TestMerger_MergeBreaks(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"country": mmdbtype.String("AU"), "city": mmdbtype.String("Sydney")},
+			"1.0.1.0/24": {"country": mmdbtype.String("AU"), "city": mmdbtype.String("Perth")},
+			"1.0.2.0/24": {"country": mmdbtype.String("NZ"), "city": mmdbtype.String("Auckland")},
+			"1.0.3.0/24": {"country": mmdbtype.String("NZ"), "city": mmdbtype.String("Auckland")},
+			"1.0.5.0/24": {"country": mmdbtype.String("FJ"), "city": mmdbtype.String("Suva")},
+		})},
+	}
+	cfg := &config.Config{
+		Databases: []config.Database{databases["city"]},
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country"}},
+			{Name: "city", Database: "city", Path: config.Path{"city"}},
+		},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	m, err := NewMerger(readers, cfg, &mockWriter{})
+	require.NoError(t, err)
+	assert.Nil(t, m.MergeBreaks())
+	m.EnableMergeBreaks()
+	require.NoError(t, m.Merge())
+
+	// Sydney and Perth differ by city alone, Perth and Auckland by both
+	// columns, and Suva is not adjacent to Auckland
+	assert.Equal(t, &MergeBreaks{
+		Total: 2,
+		Columns: []ColumnBreaks{
+			{Name: "country", Breaks: 1},
+			{Name: "city", Breaks: 2, Sole: 1},
+		},
+	}, m.MergeBreaks())
+}
+
+func TestMerger_Trace(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/23": {"country": mmdbtype.String("AU")},
+			"2.0.0.0/24": {"country": mmdbtype.String("NZ")},
+		})},
+		"asn": {Name: "asn", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"asn": mmdbtype.Uint32(1)},
+			"1.0.1.0/25": {"asn": mmdbtype.Uint32(2)},
+		})},
+	}
+	cfg := &config.Config{
+		Databases: []config.Database{databases["city"], databases["asn"]},
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country"}},
+			{Name: "asn", Database: "asn", Path: config.Path{"asn"}},
+		},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	writer := &mockRangeWriter{}
+	m, err := NewMerger(readers, cfg, writer)
+	require.NoError(t, err)
+	assert.Nil(t, m.Trace())
+	m.EnableTrace([]netip.Prefix{netip.MustParsePrefix("1.0.1.0/24")})
+	require.NoError(t, m.Merge())
+
+	values := func(country, asn any) []TraceValue {
+		return []TraceValue{
+			{Column: "country", Database: "city", Value: country},
+			{Column: "asn", Database: "asn", Value: asn},
+		}
+	}
+	trace := m.Trace()
+	assert.Equal(t, []TraceStep{
+		{
+			Network: netip.MustParsePrefix("1.0.1.0/25"),
+			Records: []TraceRecord{
+				{Database: "city", Found: true, Network: netip.MustParsePrefix("1.0.0.0/23")},
+				{Database: "asn", Found: true, Network: netip.MustParsePrefix("1.0.1.0/25")},
+			},
+			Values: values("AU", uint64(2)),
+		},
+		{
+			Network: netip.MustParsePrefix("1.0.1.128/25"),
+			Records: []TraceRecord{
+				{Database: "city", Found: true, Network: netip.MustParsePrefix("1.0.0.0/23")},
+				{Database: "asn"},
+			},
+			Values: values("AU", nil),
+		},
+	}, trace.Steps)
+	assert.Equal(t, []TraceRow{
+		{
+			Start:  netip.MustParseAddr("1.0.1.0"),
+			End:    netip.MustParseAddr("1.0.1.127"),
+			Values: values("AU", uint64(2)),
+		},
+		{
+			Start:  netip.MustParseAddr("1.0.1.128"),
+			End:    netip.MustParseAddr("1.0.1.255"),
+			Values: values("AU", nil),
+		},
+	}, trace.Rows)
+	// Untraced networks are written as usual
+	assert.Len(t, writer.ranges, 4)
+}
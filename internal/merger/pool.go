@@ -0,0 +1,87 @@
+package merger
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang/v2"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+)
+
+// LookupPool serves Lookup calls from several goroutines at once. A Merger
+// is not safe for concurrent use, as its unmarshalers and buffers are
+// reused from one lookup to the next, so the pool holds one Merger per
+// concurrent lookup. The Mergers share the readers, which are safe for
+// concurrent use, and the preloaded databases, which are read-only once
+// loaded; each has its own unmarshalers, so their caches are never shared
+// between goroutines.
+type LookupPool struct {
+	mergers chan *Merger
+}
+
+// NewLookupPool creates a pool serving up to size concurrent lookups.
+// Preloaded databases are loaded once for the whole pool.
+func NewLookupPool(readers *mmdb.Readers, cfg *config.Config, size int) (*LookupPool, error) {
+	if size < 1 {
+		return nil, errors.New("lookup pool size must be at least 1")
+	}
+	m, err := NewMerger(readers, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	p := &LookupPool{mergers: make(chan *Merger, size)}
+	p.mergers <- m
+	for range size - 1 {
+		p.mergers <- m.lookupClone()
+	}
+	return p, nil
+}
+
+// Size returns the number of lookups the pool serves at once.
+func (p *LookupPool) Size() int {
+	return cap(p.mergers)
+}
+
+// Lookup returns the merged column values for addr, as Merger.Lookup does.
+// It is safe for concurrent use, and blocks while Size lookups are already
+// running.
+func (p *LookupPool) Lookup(addr netip.Addr) (netip.Prefix, []mmdbtype.DataType, error) {
+	m := <-p.mergers
+	defer func() { p.mergers <- m }()
+	return m.Lookup(addr)
+}
+
+// lookupClone returns a Merger for lookups sharing the readers, extractors,
+// and preloaded databases of m, with buffers and unmarshalers of its own.
+// It has no accumulator and cannot Merge.
+func (m *Merger) lookupClone() *Merger {
+	c := &Merger{
+		readers:        m.readers,
+		config:         m.config,
+		readersList:    m.readersList,
+		dbNamesList:    m.dbNamesList,
+		preloaded:      m.preloaded,
+		preloadRecords: make([]mmdbtype.DataType, len(m.preloadRecords)),
+		preloadNets:    make([]netip.Prefix, len(m.preloadNets)),
+		extractors:     m.extractors,
+		scores:         m.scores,
+		unmarshalers:   make([]*mmdbtype.Unmarshaler, len(m.unmarshalers)),
+		decodePaths:    m.decodePaths,
+		decodedRecords: make([]mmdbtype.DataType, len(m.decodedRecords)),
+		slicePool:      newSlicePool(len(m.workingSlice)),
+		workingSlice:   make([]mmdbtype.DataType, len(m.workingSlice)),
+		resultsBuffer:  make([]maxminddb.Result, len(m.resultsBuffer)),
+		ipv4Only:       m.ipv4Only,
+	}
+	for i := range c.unmarshalers {
+		if m.config.DisableCache {
+			c.unmarshalers[i] = &mmdbtype.Unmarshaler{}
+		} else {
+			c.unmarshalers[i] = mmdbtype.NewUnmarshaler()
+		}
+	}
+	return c
+}
@@ -0,0 +1,76 @@
+package merger
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+)
+
+func TestLookupPool(t *testing.T) {
+	cityRecords := map[string]mmdbtype.Map{}
+	for i := range 64 {
+		cityRecords[fmt.Sprintf("10.0.%d.0/24", i)] = mmdbtype.Map{
+			"city": mmdbtype.Map{"name": mmdbtype.String(fmt.Sprintf("city-%d", i))},
+		}
+	}
+	databases := map[string]config.Database{
+		"city": {Name: "city", Path: writeTestDatabase(t, cityRecords)},
+		"anon": {Name: "anon", Path: writeTestDatabase(t, map[string]mmdbtype.Map{
+			"10.0.0.0/18": {"is_anonymous": mmdbtype.Bool(true)},
+		}), Preload: true},
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	require.NoError(t, err)
+	defer readers.Close()
+
+	cfg := &config.Config{
+		Databases: []config.Database{databases["city"], databases["anon"]},
+		Columns: []config.Column{
+			{Name: "city", Database: "city", Path: config.Path{"city", "name"}},
+			{Name: "is_anonymous", Database: "anon", Path: config.Path{"is_anonymous"}},
+			{Name: "record", Database: "city"},
+		},
+	}
+
+	_, err = NewLookupPool(readers, cfg, 0)
+	require.Error(t, err)
+
+	pool, err := NewLookupPool(readers, cfg, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 4, pool.Size())
+
+	// Every goroutine looks up every network, so that lookups through the
+	// same Merger would race
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Go(func() {
+			for i := range 64 {
+				addr := netip.MustParseAddr(fmt.Sprintf("10.0.%d.1", i))
+				prefix, values, err := pool.Lookup(addr)
+				if err != nil {
+					errs <- err
+					return
+				}
+				name := mmdbtype.String(fmt.Sprintf("city-%d", i))
+				if prefix.Bits() != 24 || values[0] != name || values[1] != mmdbtype.Bool(true) {
+					errs <- fmt.Errorf("%s: got %s %v", addr, prefix, values)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
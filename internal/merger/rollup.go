@@ -0,0 +1,40 @@
+package merger
+
+import (
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/region"
+)
+
+// rollupColumn outputs a property of the country whose alpha-2 code is a
+// column's value, from reference data.
+type rollupColumn struct {
+	property string
+	table    *region.Table
+}
+
+// value returns the property of the country with code v, or nil if v is not
+// a country of the table or the country has no value for the property.
+func (c *rollupColumn) value(v mmdbtype.DataType) mmdbtype.DataType {
+	code, ok := v.(mmdbtype.String)
+	if !ok {
+		return nil
+	}
+	country, ok := c.table.Lookup(string(code))
+	if !ok {
+		return nil
+	}
+	switch c.property {
+	case region.IsInEuropeanUnion:
+		return mmdbtype.Bool(country.IsInEuropeanUnion)
+	case region.ContinentCode:
+		if country.ContinentCode != "" {
+			return mmdbtype.String(country.ContinentCode)
+		}
+	case region.UNRegion:
+		if country.UNRegion != "" {
+			return mmdbtype.String(country.UNRegion)
+		}
+	}
+	return nil
+}
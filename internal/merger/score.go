@@ -0,0 +1,111 @@
+package merger
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+// scoreColumn computes a column of kind "score" from the values of other
+// columns.
+type scoreColumn struct {
+	name     mmdbtype.String
+	colIndex int
+	inputs   []scoreInput
+}
+
+// scoreInput is a column weighted into a score.
+type scoreInput struct {
+	name     string
+	colIndex int
+	weight   float64
+}
+
+// newScoreColumns returns the score columns of cfg, with their inputs in
+// column order.
+func newScoreColumns(cfg *config.Config) ([]scoreColumn, error) {
+	var scores []scoreColumn
+	for i, col := range cfg.Columns {
+		if col.Kind != config.ColumnKindScore {
+			continue
+		}
+		score := scoreColumn{name: col.Name, colIndex: i}
+		for j, input := range cfg.Columns {
+			weight, ok := col.Weights[string(input.Name)]
+			if ok {
+				score.inputs = append(score.inputs, scoreInput{
+					name:     string(input.Name),
+					colIndex: j,
+					weight:   weight,
+				})
+			}
+		}
+		if len(score.inputs) != len(col.Weights) {
+			return nil, fmt.Errorf("column '%s': weights refer to columns that are not configured", col.Name)
+		}
+		scores = append(scores, score)
+	}
+	return scores, nil
+}
+
+// compute sets the score in values, which holds the values of the other
+// columns. The score is the sum of the weighted values, with true counting
+// as 1 and false as 0, and is missing if every input is.
+func (s *scoreColumn) compute(values []mmdbtype.DataType) error {
+	var (
+		sum   float64
+		found bool
+	)
+	for _, input := range s.inputs {
+		value := values[input.colIndex]
+		if value == nil {
+			continue
+		}
+		n, ok := scoreValue(value)
+		if !ok {
+			return fmt.Errorf(
+				"computing score column '%s': '%s' must be a boolean or number, got %T",
+				s.name,
+				input.name,
+				value,
+			)
+		}
+		sum += input.weight * n
+		found = true
+	}
+	if found {
+		values[s.colIndex] = mmdbtype.Float64(sum)
+	}
+	return nil
+}
+
+// scoreValue returns the number a boolean or numeric value counts as.
+func scoreValue(value mmdbtype.DataType) (float64, bool) {
+	switch v := value.(type) {
+	case mmdbtype.Bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case mmdbtype.Float32:
+		return float64(v), true
+	case mmdbtype.Float64:
+		return float64(v), true
+	case mmdbtype.Int32:
+		return float64(v), true
+	case mmdbtype.Uint16:
+		return float64(v), true
+	case mmdbtype.Uint32:
+		return float64(v), true
+	case mmdbtype.Uint64:
+		return float64(v), true
+	case *mmdbtype.Uint128:
+		f, _ := new(big.Float).SetInt((*big.Int)(v)).Float64()
+		return f, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,125 @@
+package merger
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// Stats breaks down the time of a merge by step, and by database for the
+// steps that read a database. Times are measured around every network, so
+// they are only collected when enabled with Merger.EnableStats.
+type Stats struct {
+	Databases  []DatabaseStats
+	Accumulate time.Duration // Combining adjacent networks, excluding Write
+	Write      time.Duration // Time spent in the output writer
+	Networks   int64         // Networks passed to the accumulator
+
+	process time.Duration // Time spent in the accumulator, including Write
+}
+
+// DatabaseStats is the time spent reading one database.
+type DatabaseStats struct {
+	Name string
+	// Iterate is the time spent advancing the network iterator, which is
+	// mostly reading the search tree, or looking up the records of a
+	// preloaded database
+	Iterate time.Duration
+	Decode  time.Duration // Decoding records or the fields at column paths
+	Walk    time.Duration // Walking column paths in decoded records
+}
+
+// EnableStats starts collecting the time spent in each step of the merge,
+// available from Stats once the merge completes.
+func (m *Merger) EnableStats() {
+	m.stats = &Stats{Databases: make([]DatabaseStats, len(m.dbNamesList))}
+	for i, name := range m.dbNamesList {
+		m.stats.Databases[i].Name = name
+	}
+	timed := &timedWriter{writer: m.acc.writer, elapsed: &m.stats.Write}
+	m.acc.writer = timed
+}
+
+// Stats returns the collected statistics, or nil if EnableStats was not
+// called.
+func (m *Merger) Stats() *Stats {
+	if m.stats == nil {
+		return nil
+	}
+	stats := *m.stats
+	stats.Databases = slices.Clone(m.stats.Databases)
+	stats.Accumulate = max(stats.process-stats.Write, 0)
+	return &stats
+}
+
+// statsNow returns the current time if statistics are collected, so that
+// the clock is not read otherwise.
+func (m *Merger) statsNow() time.Time {
+	if m.stats == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// addIterate adds the time since start to the iteration time of database
+// dbIndex and returns the current time.
+func (m *Merger) addIterate(dbIndex int, start time.Time) time.Time {
+	if m.stats == nil {
+		return start
+	}
+	now := time.Now()
+	m.stats.Databases[dbIndex].Iterate += now.Sub(start)
+	return now
+}
+
+func (m *Merger) addDecode(dbIndex int, start time.Time) {
+	if m.stats != nil {
+		m.stats.Databases[dbIndex].Decode += time.Since(start)
+	}
+}
+
+func (m *Merger) addWalk(dbIndex int, start time.Time) {
+	if m.stats != nil {
+		m.stats.Databases[dbIndex].Walk += time.Since(start)
+	}
+}
+
+// process passes a network to the accumulator, timing it.
+func (m *Merger) process(prefix netip.Prefix) error {
+	if m.stats == nil {
+		return m.acc.Process(prefix, m.workingSlice)
+	}
+	start := time.Now()
+	err := m.acc.Process(prefix, m.workingSlice)
+	m.addProcess(start)
+	m.stats.Networks++
+	return err
+}
+
+func (m *Merger) addProcess(start time.Time) {
+	if m.stats != nil {
+		m.stats.process += time.Since(start)
+	}
+}
+
+// timedWriter adds the time spent in the wrapped writer to elapsed.
+type timedWriter struct {
+	writer  row.Writer
+	elapsed *time.Duration
+}
+
+func (w *timedWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	start := time.Now()
+	err := w.writer.WriteRow(prefix, r)
+	*w.elapsed += time.Since(start)
+	return err
+}
+
+func (w *timedWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	began := time.Now()
+	err := row.WriteRange(w.writer, start, end, r)
+	*w.elapsed += time.Since(began)
+	return err
+}
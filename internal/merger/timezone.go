@@ -0,0 +1,60 @@
+package merger
+
+import (
+	"sync"
+	"time"
+	// Time zones are embedded, so that time_zone columns do not depend on
+	// the system's time zone database
+	_ "time/tzdata"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+// timeZoneColumn outputs a property of the time zone named by a column's
+// value, such as its offset from UTC, at a reference time. It is safe for
+// concurrent use.
+type timeZoneColumn struct {
+	property string
+	at       time.Time
+
+	mu     sync.Mutex
+	values map[mmdbtype.String]mmdbtype.DataType // Property of each zone seen, nil if unknown
+}
+
+func newTimeZoneColumn(property string, at time.Time) *timeZoneColumn {
+	return &timeZoneColumn{
+		property: property,
+		at:       at,
+		values:   map[mmdbtype.String]mmdbtype.DataType{},
+	}
+}
+
+// value returns the property of the time zone named by v, or nil if v is
+// not the name of a time zone.
+func (c *timeZoneColumn) value(v mmdbtype.DataType) mmdbtype.DataType {
+	name, ok := v.(mmdbtype.String)
+	if !ok || name == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value, ok := c.values[name]; ok {
+		return value
+	}
+	var value mmdbtype.DataType
+	if loc, err := time.LoadLocation(string(name)); err == nil {
+		t := c.at.In(loc)
+		if c.property == config.TimeZoneDST {
+			value = mmdbtype.Bool(t.IsDST())
+		} else {
+			_, offset := t.Zone()
+			//nolint:gosec // offsets are within a day
+			value = mmdbtype.Int32(offset)
+		}
+	}
+	c.values[name] = value
+	return value
+}
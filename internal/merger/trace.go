@@ -0,0 +1,136 @@
+package merger
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// Trace records how the merge builds the rows of a set of traced networks:
+// the record of each database for every network merged within them, the
+// column values extracted from the records, and the rows written once
+// adjacent networks are joined. It is only recorded when enabled with
+// Merger.EnableTrace, and holds every network merged within the traced
+// networks, so they should be small.
+type Trace struct {
+	Networks []netip.Prefix `json:"networks"` // The traced networks
+	Steps    []TraceStep    `json:"steps"`    // Networks merged, in order
+	Rows     []TraceRow     `json:"rows"`     // Rows written, in order
+}
+
+// TraceStep is the merge of one network overlapping a traced network.
+type TraceStep struct {
+	Network netip.Prefix  `json:"network"`
+	Records []TraceRecord `json:"records"` // Indexed like the databases merged
+	Values  []TraceValue  `json:"values"`  // Indexed like config.Columns
+}
+
+// TraceRecord is the record a database has for a merged network.
+type TraceRecord struct {
+	Database string `json:"database"`
+	Found    bool   `json:"found"`
+	// Network is the network of the record, which may be larger than the
+	// merged network, if found
+	Network netip.Prefix `json:"network,omitzero"`
+}
+
+// TraceValue is the value of a column, as a plain Go value, after
+// transforms and defaults.
+type TraceValue struct {
+	Column   string `json:"column"`
+	Database string `json:"database,omitempty"` // The database it is read from, if any
+	Value    any    `json:"value"`
+}
+
+// TraceRow is a row written for a range overlapping a traced network,
+// which may cover several merged networks with the same values.
+type TraceRow struct {
+	Start  netip.Addr   `json:"start"`
+	End    netip.Addr   `json:"end"`
+	Values []TraceValue `json:"values"`
+}
+
+// EnableTrace starts recording the merge of networks, available from Trace
+// once the merge completes.
+func (m *Merger) EnableTrace(networks []netip.Prefix) {
+	m.trace = &Trace{Networks: slices.Clone(networks)}
+	m.acc.writer = &traceWriter{writer: m.acc.writer, merger: m}
+}
+
+// Trace returns the recorded trace, or nil if EnableTrace was not called.
+func (m *Merger) Trace() *Trace {
+	return m.trace
+}
+
+// traced reports whether the range from start to end overlaps a traced
+// network.
+func (t *Trace) traced(start, end netip.Addr) bool {
+	return slices.ContainsFunc(t.Networks, func(p netip.Prefix) bool {
+		return start.Compare(netipx.PrefixLastIP(p)) <= 0 && end.Compare(p.Addr()) >= 0
+	})
+}
+
+// traceStep records the merge of prefix, whose values were just extracted
+// from results, if it overlaps a traced network.
+func (m *Merger) traceStep(results []maxminddb.Result, prefix netip.Prefix) {
+	if m.trace == nil || !m.trace.traced(prefix.Addr(), netipx.PrefixLastIP(prefix)) {
+		return
+	}
+	step := TraceStep{
+		Network: prefix,
+		Records: make([]TraceRecord, len(m.dbNamesList)),
+		Values:  m.traceValues(m.workingSlice),
+	}
+	for i, name := range m.dbNamesList {
+		step.Records[i] = TraceRecord{Database: name, Found: m.hasRecord(results, i)}
+		if step.Records[i].Found {
+			step.Records[i].Network = m.recordNetwork(results, i)
+		}
+	}
+	m.trace.Steps = append(m.trace.Steps, step)
+}
+
+// traceValues returns the values of data, named by column.
+func (m *Merger) traceValues(data row.Row) []TraceValue {
+	values := make([]TraceValue, len(m.config.Columns))
+	for i, col := range m.config.Columns {
+		values[i] = TraceValue{
+			Column:   string(col.Name),
+			Database: col.Database,
+			Value:    data.Interface(i),
+		}
+	}
+	return values
+}
+
+// traceWriter records the rows written for the traced networks.
+type traceWriter struct {
+	writer row.Writer
+	merger *Merger
+}
+
+func (w *traceWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	w.record(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+	return w.writer.WriteRow(prefix, r)
+}
+
+func (w *traceWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	w.record(start, end, r)
+	return row.WriteRange(w.writer, start, end, r)
+}
+
+func (w *traceWriter) record(start, end netip.Addr, r row.Row) {
+	trace := w.merger.trace
+	if !trace.traced(start, end) {
+		return
+	}
+	trace.Rows = append(trace.Rows, TraceRow{
+		Start:  start,
+		End:    end,
+		Values: w.merger.traceValues(r),
+	})
+}
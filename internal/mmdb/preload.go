@@ -0,0 +1,84 @@
+package mmdb
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"go4.org/netipx"
+)
+
+// Range is a network of a preloaded database with its decoded record.
+type Range struct {
+	Prefix netip.Prefix
+	Start  netip.Addr // First address of Prefix
+	End    netip.Addr // Last address of Prefix
+	Record mmdbtype.DataType
+}
+
+// Preloaded holds every network with data of a database in memory, sorted by
+// address, so that the networks overlapping a range can be found with a
+// binary search instead of iterating the search tree. It is read-only once
+// loaded, and safe for concurrent use.
+type Preloaded struct {
+	ranges []Range
+}
+
+// Preload decodes every network with data in r.
+func Preload(r *Reader) (*Preloaded, error) {
+	unmarshaler := mmdbtype.NewUnmarshaler()
+
+	var ranges []Range
+	for result := range r.Networks() {
+		if err := result.Err(); err != nil {
+			return nil, fmt.Errorf("iterating networks: %w", err)
+		}
+		if err := result.Decode(unmarshaler); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", result.Prefix(), err)
+		}
+		record := unmarshaler.Result()
+		unmarshaler.Clear()
+
+		prefix := result.Prefix()
+		ranges = append(ranges, Range{
+			Prefix: prefix,
+			Start:  prefix.Addr(),
+			End:    netipx.PrefixLastIP(prefix),
+			Record: record,
+		})
+	}
+
+	slices.SortFunc(ranges, func(a, b Range) int {
+		return a.Start.Compare(b.Start)
+	})
+	return &Preloaded{ranges: ranges}, nil
+}
+
+// Len returns the number of networks with data.
+func (p *Preloaded) Len() int {
+	return len(p.ranges)
+}
+
+// Overlapping returns the networks that overlap start through end, in
+// ascending order. The result must not be modified.
+func (p *Preloaded) Overlapping(start, end netip.Addr) []Range {
+	first := sort.Search(len(p.ranges), func(i int) bool {
+		return p.ranges[i].End.Compare(start) >= 0
+	})
+	last := first
+	for last < len(p.ranges) && p.ranges[last].Start.Compare(end) <= 0 {
+		last++
+	}
+	return p.ranges[first:last]
+}
+
+// Lookup returns the network containing addr, if it has data.
+func (p *Preloaded) Lookup(addr netip.Addr) (Range, bool) {
+	ranges := p.Overlapping(addr, addr)
+	if len(ranges) == 0 {
+		return Range{}, false
+	}
+	return ranges[0], true
+}
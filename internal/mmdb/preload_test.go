@@ -0,0 +1,61 @@
+package mmdb
+
+import (
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+)
+
+func TestPreload(t *testing.T) {
+	path := mmdbtest.WriteTemp(t, mmdbwriter.Options{
+		DatabaseType: "Test",
+		IPVersion:    4,
+		RecordSize:   24,
+	}, map[string]mmdbtype.DataType{
+		"1.0.0.0/24": mmdbtype.Map{"name": mmdbtype.String("a")},
+		"1.0.2.0/23": mmdbtype.Map{"name": mmdbtype.String("b")},
+		"1.0.5.0/24": mmdbtype.String("not a map"),
+	})
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	reader, err := OpenBytes(data)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	preloaded, err := Preload(reader)
+	require.NoError(t, err)
+	assert.Equal(t, 3, preloaded.Len())
+
+	r, ok := preloaded.Lookup(netip.MustParseAddr("1.0.5.1"))
+	require.True(t, ok)
+	assert.Equal(t, mmdbtype.String("not a map"), r.Record, "records that are not maps are kept")
+
+	r, ok = preloaded.Lookup(netip.MustParseAddr("1.0.3.7"))
+	require.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("1.0.2.0/23"), r.Prefix)
+	assert.Equal(t, mmdbtype.Map{"name": mmdbtype.String("b")}, r.Record)
+
+	_, ok = preloaded.Lookup(netip.MustParseAddr("1.0.1.0"))
+	assert.False(t, ok)
+
+	overlapping := preloaded.Overlapping(
+		netip.MustParseAddr("1.0.0.128"),
+		netip.MustParseAddr("1.0.2.0"),
+	)
+	require.Len(t, overlapping, 2)
+	assert.Equal(t, netip.MustParseAddr("1.0.0.0"), overlapping[0].Start)
+	assert.Equal(t, netip.MustParseAddr("1.0.3.255"), overlapping[1].End)
+
+	assert.Empty(t, preloaded.Overlapping(
+		netip.MustParseAddr("1.0.1.0"),
+		netip.MustParseAddr("1.0.1.255"),
+	))
+}
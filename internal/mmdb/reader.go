@@ -2,9 +2,15 @@
 package mmdb
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"iter"
+	"maps"
 	"net/netip"
+	"os"
+	"slices"
 
 	"github.com/oschwald/maxminddb-golang/v2"
 
@@ -12,6 +18,12 @@ import (
 )
 
 // Reader wraps a maxminddb.Reader with additional functionality.
+//
+// A Reader is safe for concurrent use by multiple goroutines, except for
+// Close, which must not be called while other calls are running. Decoding
+// the Results it returns is safe as well, as long as each goroutine decodes
+// with its own mmdbtype.Unmarshaler: unmarshalers, and the caches they
+// keep, must not be shared between goroutines.
 type Reader struct {
 	reader   *maxminddb.Reader
 	priority int
@@ -30,6 +42,16 @@ func Open(db config.Database) (*Reader, error) {
 	}, nil
 }
 
+// OpenBytes opens an MMDB database held in memory, such as one built during
+// the conversion.
+func OpenBytes(data []byte) (*Reader, error) {
+	reader, err := maxminddb.OpenBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("opening in-memory MMDB: %w", err)
+	}
+	return &Reader{reader: reader}, nil
+}
+
 // Close closes the MMDB database.
 func (r *Reader) Close() error {
 	if err := r.reader.Close(); err != nil {
@@ -51,49 +73,183 @@ func (r *Reader) NetworksWithin(
 	return r.reader.NetworksWithin(prefix, options...)
 }
 
+// Lookup returns the result for the network containing addr.
+func (r *Reader) Lookup(addr netip.Addr) maxminddb.Result {
+	return r.reader.Lookup(addr)
+}
+
 // Metadata returns metadata about the database.
 func (r *Reader) Metadata() maxminddb.Metadata {
 	return r.reader.Metadata
 }
 
+// Verify checks the metadata, search tree, and data section of the
+// database, reading the whole file.
+func (r *Reader) Verify() error {
+	if err := r.reader.Verify(); err != nil {
+		return fmt.Errorf("verifying MMDB: %w", err)
+	}
+	return nil
+}
+
+// HasIPv6Networks reports whether the database contains any networks outside
+// the IPv4 address space. IPv4 data stored in an IPv6 tree, including its
+// aliases, does not count.
+func (r *Reader) HasIPv6Networks() (bool, error) {
+	if r.reader.Metadata.IPVersion == 4 {
+		return false, nil
+	}
+	for result := range r.reader.Networks() {
+		if err := result.Err(); err != nil {
+			return false, fmt.Errorf("iterating networks: %w", err)
+		}
+		if !result.Prefix().Addr().Is4() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (r *Reader) Priority() int {
 	return r.priority
 }
 
 // Readers manages multiple MMDB database readers.
+//
+// Get is safe for concurrent use, and so are the readers it returns. Add
+// and Close are not: readers must be added before they are shared between
+// goroutines, and closed once every goroutine is done with them.
 type Readers struct {
 	readers map[string]*Reader // database name -> reader
 }
 
-// OpenDatabases opens multiple MMDB databases.
+// OpenDatabases opens multiple MMDB databases. Entries referring to the same
+// file, or to files with identical content, share one underlying reader, so
+// aliasing a database under several names costs no extra memory or file
+// handles.
 func OpenDatabases(databases map[string]config.Database) (*Readers, error) {
 	readers := map[string]*Reader{}
+	var opened []openedFile
+
+	for _, name := range slices.Sorted(maps.Keys(databases)) {
+		db := databases[name]
+		file, shared, err := findOpened(opened, db.Path)
+		if err != nil {
+			closeReaders(readers)
+			return nil, err
+		}
+		if shared != nil {
+			readers[name] = &Reader{reader: shared, priority: db.Priority}
+			continue
+		}
 
-	for name, db := range databases {
 		reader, err := Open(db)
 		if err != nil {
-			// Close any already opened readers
-			for _, r := range readers {
-				r.Close()
-			}
+			closeReaders(readers)
 			return nil, err
 		}
 		readers[name] = reader
+		if file.info != nil {
+			file.reader = reader.reader
+			opened = append(opened, file)
+		}
 	}
 
 	return &Readers{readers: readers}, nil
 }
 
+// openedFile is a database file opened by OpenDatabases.
+type openedFile struct {
+	path   string
+	info   os.FileInfo
+	hash   []byte // SHA-256 of the content, computed when first needed
+	reader *maxminddb.Reader
+}
+
+// findOpened returns the reader of an already opened file that is path or
+// has the same content, if any, along with path's own file information.
+// Contents are only hashed when the sizes match. A path that cannot be
+// stat'ed is left for Open to report.
+func findOpened(opened []openedFile, path string) (openedFile, *maxminddb.Reader, error) {
+	file := openedFile{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return file, nil, nil
+	}
+	file.info = info
+
+	for i := range opened {
+		other := &opened[i]
+		if os.SameFile(info, other.info) {
+			return file, other.reader, nil
+		}
+		if info.Size() != other.info.Size() {
+			continue
+		}
+		if file.hash == nil {
+			if file.hash, err = hashFile(path); err != nil {
+				return file, nil, err
+			}
+		}
+		if other.hash == nil {
+			if other.hash, err = hashFile(other.path); err != nil {
+				return file, nil, err
+			}
+		}
+		if bytes.Equal(file.hash, other.hash) {
+			return file, other.reader, nil
+		}
+	}
+	return file, nil, nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening MMDB file '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("hashing MMDB file '%s': %w", path, err)
+	}
+	return h.Sum(nil), nil
+}
+
 // Get returns the reader for a database by name.
 func (rs *Readers) Get(name string) (*Reader, bool) {
 	reader, ok := rs.readers[name]
 	return reader, ok
 }
 
+// Add registers an already opened reader under name, replacing any reader
+// with that name. The reader is closed by Close.
+func (rs *Readers) Add(name string, reader *Reader) {
+	rs.readers[name] = reader
+}
+
+// SharesFile reports whether r and other read the same underlying database,
+// such as two names for one file.
+func (r *Reader) SharesFile(other *Reader) bool {
+	return r.reader == other.reader
+}
+
 // Close closes all database readers.
 func (rs *Readers) Close() error {
+	return closeReaders(rs.readers)
+}
+
+// closeReaders closes each underlying reader once, even when several names
+// share it.
+func closeReaders(readers map[string]*Reader) error {
 	var firstErr error
-	for _, reader := range rs.readers {
+	closed := map[*maxminddb.Reader]bool{}
+	for _, reader := range readers {
+		if closed[reader.reader] {
+			continue
+		}
+		closed[reader.reader] = true
 		if err := reader.Close(); err != nil && firstErr == nil {
 			firstErr = err
 		}
@@ -2,12 +2,17 @@ package mmdb
 
 import (
 	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
 )
 
 const (
@@ -237,3 +242,76 @@ func TestReader_NetworksWithin(t *testing.T) {
 	}
 	assert.Positive(t, count, "should have at least one network in prefix")
 }
+
+func TestReader_HasIPv6Networks(t *testing.T) {
+	tests := []struct {
+		name      string
+		ipVersion int
+		networks  []string
+		expected  bool
+	}{
+		{"IPv4 tree", 4, []string{"1.0.0.0/24"}, false},
+		{"IPv6 tree with only IPv4 data", 6, []string{"1.0.0.0/24", "2.0.0.0/16"}, false},
+		{"IPv6 tree with IPv6 data", 6, []string{"1.0.0.0/24", "2a02::/16"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make(map[string]mmdbtype.Map, len(tt.networks))
+			for _, network := range tt.networks {
+				records[network] = mmdbtype.Map{"value": mmdbtype.String(network)}
+			}
+			path := mmdbtest.WriteTemp(
+				t,
+				mmdbwriter.Options{DatabaseType: "Test", IPVersion: tt.ipVersion},
+				records,
+			)
+
+			reader, err := Open(config.Database{Path: path})
+			require.NoError(t, err)
+			defer reader.Close()
+
+			hasIPv6, err := reader.HasIPv6Networks()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, hasIPv6)
+		})
+	}
+}
+
+func TestOpenDatabases_SharesFiles(t *testing.T) {
+	opts := mmdbwriter.Options{DatabaseType: "Test", IPVersion: 4}
+	writeDB := func(value string) string {
+		return mmdbtest.WriteTemp(t, opts, map[string]mmdbtype.Map{
+			"1.0.0.0/24": {"value": mmdbtype.String(value)},
+		})
+	}
+	a := writeDB("a")
+	data, err := os.ReadFile(a)
+	require.NoError(t, err)
+	copied := filepath.Join(t.TempDir(), "copy.mmdb")
+	require.NoError(t, os.WriteFile(copied, data, 0o600))
+	// Same size as a, but different content
+	b := writeDB("b")
+
+	readers, err := OpenDatabases(map[string]config.Database{
+		"a":        {Path: a, Priority: 1},
+		"relative": {Path: filepath.Join(filepath.Dir(a), ".", filepath.Base(a)), Priority: 2},
+		"copy":     {Path: copied},
+		"b":        {Path: b},
+	})
+	require.NoError(t, err)
+
+	get := func(name string) *Reader {
+		reader, ok := readers.Get(name)
+		require.True(t, ok)
+		return reader
+	}
+	assert.True(t, get("a").SharesFile(get("relative")))
+	assert.True(t, get("a").SharesFile(get("copy")))
+	assert.False(t, get("a").SharesFile(get("b")))
+	assert.Equal(t, 1, get("a").Priority())
+	assert.Equal(t, 2, get("relative").Priority())
+
+	// Shared readers are closed once
+	assert.NoError(t, readers.Close())
+}
@@ -0,0 +1,63 @@
+// Package mmdbtest writes MMDB databases for tests.
+package mmdbtest
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/require"
+	"go4.org/netipx"
+)
+
+// Options returns the options of most test databases: an IPv6 tree of
+// database type "Test" that includes reserved networks, so that tests can
+// use any address.
+func Options() mmdbwriter.Options {
+	return mmdbwriter.Options{
+		DatabaseType:            "Test",
+		IncludeReservedNetworks: true,
+	}
+}
+
+// Write writes a database with opts to path, holding records keyed by
+// network in CIDR notation. Records of overlapping networks are inserted in
+// no particular order.
+func Write[V mmdbtype.DataType](
+	t testing.TB,
+	path string,
+	opts mmdbwriter.Options,
+	records map[string]V,
+) {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(opts)
+	require.NoError(t, err)
+	for cidr, record := range records {
+		prefix := netip.MustParsePrefix(cidr)
+		require.NoError(t, tree.Insert(netipx.PrefixIPNet(prefix), record), cidr)
+	}
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = tree.WriteTo(f)
+	require.NoError(t, err)
+}
+
+// WriteTemp is Write to a file in a new temporary directory, whose path it
+// returns.
+func WriteTemp[V mmdbtype.DataType](
+	t testing.TB,
+	opts mmdbwriter.Options,
+	records map[string]V,
+) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	Write(t, path, opts, records)
+	return path
+}
@@ -0,0 +1,191 @@
+// Package premerge reduces the number of databases taking part in the
+// nested iteration of the merge by merging small databases ahead of time.
+//
+// The merger iterates the networks of each database within every network of
+// the databases before it, so each additional database multiplies the work.
+// Merging two databases first produces a single database whose records hold
+// both source records, which the main merge then iterates as one.
+package premerge
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// WarnThreshold is the number of databases above which nested iteration
+// becomes slow enough to warn about.
+const WarnThreshold = 4
+
+// recordSize is large enough for any tree built from the databases being
+// pre-merged.
+const recordSize = 32
+
+// Merged describes a database built by Apply.
+type Merged struct {
+	Name    string   // Name the columns now reference
+	Sources []string // Databases merged into it
+	Nodes   uint     // Node count of the merged tree
+}
+
+// Apply keeps the nesting of the merge within cfg.MaxNestingDepth by
+// repeatedly merging the two databases with the fewest nodes into a single
+// in-memory database. Each record of a merged database holds the source
+// records keyed by database name, and the columns of cfg are rewritten to
+// read from it, so the final merge produces the same rows. The merged
+// readers are added to readers.
+func Apply(readers *mmdb.Readers, cfg *config.Config) ([]Merged, error) {
+	maxDepth := cfg.MaxNestingDepth
+	if maxDepth <= 0 {
+		return nil, nil
+	}
+
+	names := merger.IteratedDatabaseNames(cfg)
+	var merged []Merged
+	for len(names) > maxDepth {
+		candidates := mergeable(cfg, names)
+		if len(candidates) < 2 {
+			break
+		}
+		a, b, err := smallestPair(readers, candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		name := a + "+" + b
+		for {
+			if _, exists := readers.Get(name); !exists {
+				break
+			}
+			name += "+"
+		}
+
+		reader, err := mergePair(readers, cfg, a, b)
+		if err != nil {
+			return nil, fmt.Errorf("pre-merging databases '%s' and '%s': %w", a, b, err)
+		}
+		readers.Add(name, reader)
+
+		for i, col := range cfg.Columns {
+			if col.Database == a || col.Database == b {
+				cfg.Columns[i].Database = name
+				cfg.Columns[i].Path = append(config.Path{col.Database}, col.Path...)
+			}
+		}
+		if cfg.DriverDatabase == a || cfg.DriverDatabase == b {
+			cfg.DriverDatabase = name
+		}
+
+		merged = append(merged, Merged{
+			Name:    name,
+			Sources: []string{a, b},
+			Nodes:   reader.Metadata().NodeCount,
+		})
+		names = merger.IteratedDatabaseNames(cfg)
+	}
+
+	return merged, nil
+}
+
+// mergeable returns the databases of names that can be pre-merged. The
+// networks of a merged database are split at the networks of both sources,
+// so databases with prefix_length columns, which output the lengths of
+// their own networks, are left out. Databases in lookup mode are left out
+// too, as a merged database would be iterated.
+func mergeable(cfg *config.Config, names []string) []string {
+	return slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		if slices.ContainsFunc(cfg.Databases, func(db config.Database) bool {
+			return db.Name == name && db.Mode == config.DatabaseModeLookup
+		}) {
+			return true
+		}
+		return slices.ContainsFunc(cfg.Columns, func(col config.Column) bool {
+			return col.PrefixLength && col.Database == name
+		})
+	})
+}
+
+// smallestPair returns the two databases with the fewest nodes, in column
+// order.
+func smallestPair(readers *mmdb.Readers, names []string) (string, string, error) {
+	if len(names) < 2 {
+		return "", "", errors.New("at least two databases are required to pre-merge")
+	}
+
+	nodes := make(map[string]uint, len(names))
+	for _, name := range names {
+		reader, ok := readers.Get(name)
+		if !ok {
+			return "", "", fmt.Errorf("database '%s' not found", name)
+		}
+		nodes[name] = reader.Metadata().NodeCount
+	}
+
+	bySize := slices.Clone(names)
+	slices.SortStableFunc(bySize, func(a, b string) int {
+		return cmp.Compare(nodes[a], nodes[b])
+	})
+
+	a, b := bySize[0], bySize[1]
+	if slices.Index(names, a) > slices.Index(names, b) {
+		a, b = b, a
+	}
+	return a, b, nil
+}
+
+// mergePair merges databases a and b into an in-memory MMDB whose records
+// map each database name to its record.
+func mergePair(readers *mmdb.Readers, cfg *config.Config, a, b string) (*mmdb.Reader, error) {
+	first, ok := readers.Get(a)
+	if !ok {
+		return nil, fmt.Errorf("database '%s' not found", a)
+	}
+
+	size := recordSize
+	includeReserved := true
+	sub := &config.Config{
+		Output: config.OutputConfig{
+			MMDB: config.MMDBConfig{
+				DatabaseType:            "mmdbconvert-premerge",
+				RecordSize:              &size,
+				IncludeReservedNetworks: &includeReserved,
+			},
+		},
+		// Columns without a path copy the full record
+		Columns: []config.Column{
+			{Name: mmdbtype.String(a), Database: a},
+			{Name: mmdbtype.String(b), Database: b},
+		},
+		DisableCache: cfg.DisableCache,
+	}
+
+	//nolint:gosec // IPVersion is always 4 or 6, no overflow risk
+	ipVersion := int(first.Metadata().IPVersion)
+	tree, err := writer.NewMMDBWriter("", sub, ipVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := merger.NewMerger(readers, sub, tree)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Merge(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := tree.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return mmdb.OpenBytes(buf.Bytes())
+}
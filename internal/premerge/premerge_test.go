@@ -0,0 +1,140 @@
+package premerge
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// rowsWriter records the rows written by the merger.
+type rowsWriter struct {
+	prefixes []netip.Prefix
+	rows     []row.Row
+}
+
+func (w *rowsWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	w.prefixes = append(w.prefixes, prefix)
+	w.rows = append(w.rows, slices.Clone(r))
+	return nil
+}
+
+func TestApply_MatchesNestedMerge(t *testing.T) {
+	databases := map[string]config.Database{
+		"city": {Path: mmdbtest.WriteTemp(t, mmdbtest.Options(), map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")}},
+			"81.2.70.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("FR")}},
+			"1.0.0.0/24":   {"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")}},
+		})},
+		"anon": {Path: mmdbtest.WriteTemp(t, mmdbtest.Options(), map[string]mmdbtype.Map{
+			"81.2.69.128/25": {"is_anonymous": mmdbtype.Bool(true)},
+		})},
+		"asn": {Path: mmdbtest.WriteTemp(t, mmdbtest.Options(), map[string]mmdbtype.Map{
+			"81.2.69.0/26": {"autonomous_system_number": mmdbtype.Uint32(20712)},
+			"1.0.0.0/25":   {"autonomous_system_number": mmdbtype.Uint32(13335)},
+		})},
+	}
+	newConfig := func(maxDepth int) *config.Config {
+		return &config.Config{
+			MaxNestingDepth: maxDepth,
+			Columns: []config.Column{
+				{Name: "country", Database: "city", Path: config.Path{"country", "iso_code"}},
+				{Name: "is_anonymous", Database: "anon", Path: config.Path{"is_anonymous"}},
+				{Name: "asn", Database: "asn", Path: config.Path{"autonomous_system_number"}},
+				{Name: "anon_record", Database: "anon"},
+				{Name: "asn_vendor", Database: "asn", Tag: "vendor", TagValue: mmdbtype.String("acme")},
+			},
+		}
+	}
+	merge := func(cfg *config.Config) (*rowsWriter, []Merged) {
+		readers, err := mmdb.OpenDatabases(databases)
+		require.NoError(t, err)
+		defer readers.Close()
+
+		merged, err := Apply(readers, cfg)
+		require.NoError(t, err)
+
+		w := &rowsWriter{}
+		m, err := merger.NewMerger(readers, cfg, w)
+		require.NoError(t, err)
+		require.NoError(t, m.Merge())
+		return w, merged
+	}
+
+	expected, merged := merge(newConfig(0))
+	assert.Empty(t, merged)
+	require.NotEmpty(t, expected.rows)
+
+	cfg := newConfig(2)
+	got, merged := merge(cfg)
+	assert.Equal(t, expected, got)
+	require.Len(t, merged, 1)
+	assert.Len(t, merger.DatabaseNames(cfg), 2)
+	for _, col := range cfg.Columns {
+		if col.Database == merged[0].Name {
+			// Columns read their source's record within the merged record
+			assert.Contains(t, merged[0].Sources, col.Path[0])
+		}
+	}
+
+	cfg = newConfig(1)
+	got, merged = merge(cfg)
+	assert.Equal(t, expected, got)
+	assert.Len(t, merged, 2)
+	assert.Len(t, merger.DatabaseNames(cfg), 1)
+
+	// A pre-merged driver database is replaced by the merged database
+	cfg = newConfig(2)
+	cfg.DriverDatabase = "anon"
+	got, merged = merge(cfg)
+	assert.Equal(t, expected, got)
+	require.Len(t, merged, 1)
+	assert.Equal(t, merged[0].Name, cfg.DriverDatabase)
+}
+
+func TestSmallestPair(t *testing.T) {
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"large": {Path: mmdbtest.WriteTemp(t, mmdbtest.Options(), map[string]mmdbtype.Map{
+			"1.0.0.0/24":   {"v": mmdbtype.Uint32(1)},
+			"2.0.0.0/24":   {"v": mmdbtype.Uint32(2)},
+			"81.2.69.0/24": {"v": mmdbtype.Uint32(3)},
+		})},
+		"small_a": {Path: mmdbtest.WriteTemp(t, mmdbtest.Options(), map[string]mmdbtype.Map{
+			"81.2.69.0/24": {"v": mmdbtype.Uint32(4)},
+		})},
+		"small_b": {Path: mmdbtest.WriteTemp(t, mmdbtest.Options(), map[string]mmdbtype.Map{
+			"81.2.69.0/25": {"v": mmdbtype.Uint32(5)},
+		})},
+	})
+	require.NoError(t, err)
+	defer readers.Close()
+
+	a, b, err := smallestPair(readers, []string{"small_b", "large", "small_a"})
+	require.NoError(t, err)
+	assert.Equal(t, "small_b", a)
+	assert.Equal(t, "small_a", b)
+}
+
+func TestMergeable(t *testing.T) {
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "country", Database: "city", Path: config.Path{"country"}},
+			{Name: "city_prefix_length", Database: "city", PrefixLength: true},
+			{Name: "asn", Database: "asn", Path: config.Path{"autonomous_system_number"}},
+			{Name: "is_anonymous", Database: "anon", Path: config.Path{"is_anonymous"}},
+		},
+	}
+	assert.Equal(t, []string{"asn", "anon"}, mergeable(cfg, []string{"city", "asn", "anon"}))
+
+	cfg.Databases = []config.Database{{Name: "anon", Mode: config.DatabaseModeLookup}}
+	assert.Equal(t, []string{"asn"}, mergeable(cfg, []string{"city", "asn", "anon"}))
+}
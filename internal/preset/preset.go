@@ -0,0 +1,82 @@
+// Package preset defines named column sets for common geographic
+// granularities of GeoIP2 and GeoLite2 City databases. A preset supplies the
+// columns of its level and of every coarser level, and marks the columns
+// that vary within a level, such as coordinates, as ignored when merging
+// adjacent networks, so that ranges merge at the chosen granularity.
+package preset
+
+import (
+	"maps"
+	"slices"
+)
+
+// Column is a column supplied by a preset.
+type Column struct {
+	Name string // Column name, matching the MMDB template field names
+	Path []any  // Path in GeoIP2 City records
+	// MergeIgnore marks values that differ between networks of the same
+	// place, which must not keep adjacent networks from merging.
+	MergeIgnore bool
+}
+
+// Preset is a named granularity.
+type Preset struct {
+	Name    string
+	Columns []Column
+}
+
+// Lookup returns the preset with the given name.
+func Lookup(name string) (*Preset, bool) {
+	p, ok := presets[name]
+	return p, ok
+}
+
+// Names returns the names of all presets, sorted.
+func Names() []string {
+	return slices.Sorted(maps.Keys(presets))
+}
+
+var countryColumns = []Column{
+	{Name: "continent_code", Path: []any{"continent", "code"}},
+	{Name: "country_iso_code", Path: []any{"country", "iso_code"}},
+	{Name: "country_name", Path: []any{"country", "names", "en"}},
+}
+
+var subdivisionColumns = []Column{
+	{Name: "subdivision_1_iso_code", Path: []any{"subdivisions", 0, "iso_code"}},
+	{Name: "subdivision_1_name", Path: []any{"subdivisions", 0, "names", "en"}},
+}
+
+// cityColumns identify the city by its GeoNames ID, as names are not unique
+// within a subdivision. The location of a city varies between its networks.
+var cityColumns = []Column{
+	{Name: "city_geoname_id", Path: []any{"city", "geoname_id"}},
+	{Name: "city_name", Path: []any{"city", "names", "en"}},
+	{Name: "time_zone", Path: []any{"location", "time_zone"}},
+	{Name: "latitude", Path: []any{"location", "latitude"}, MergeIgnore: true},
+	{Name: "longitude", Path: []any{"location", "longitude"}, MergeIgnore: true},
+	{Name: "accuracy_radius", Path: []any{"location", "accuracy_radius"}, MergeIgnore: true},
+}
+
+var postalColumns = []Column{
+	{Name: "postal_code", Path: []any{"postal", "code"}},
+}
+
+var presets = map[string]*Preset{
+	"country": {
+		Name:    "country",
+		Columns: countryColumns,
+	},
+	"subdivision": {
+		Name:    "subdivision",
+		Columns: slices.Concat(countryColumns, subdivisionColumns),
+	},
+	"city": {
+		Name:    "city",
+		Columns: slices.Concat(countryColumns, subdivisionColumns, cityColumns),
+	},
+	"postal": {
+		Name:    "postal",
+		Columns: slices.Concat(countryColumns, subdivisionColumns, cityColumns, postalColumns),
+	},
+}
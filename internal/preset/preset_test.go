@@ -0,0 +1,45 @@
+package preset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, []string{"city", "country", "postal", "subdivision"}, Names())
+
+	_, ok := Lookup("metro")
+	assert.False(t, ok)
+
+	// Each granularity extends the coarser ones
+	previous := []Column{}
+	for _, name := range []string{"country", "subdivision", "city", "postal"} {
+		p, ok := Lookup(name)
+		require.True(t, ok, name)
+		assert.Equal(t, name, p.Name)
+		require.Greater(t, len(p.Columns), len(previous), name)
+		assert.Equal(t, previous, p.Columns[:len(previous)], name)
+		previous = p.Columns
+	}
+}
+
+func TestMergeIgnore(t *testing.T) {
+	p, ok := Lookup("city")
+	require.True(t, ok)
+
+	var ignored []string
+	for _, col := range p.Columns {
+		if col.MergeIgnore {
+			ignored = append(ignored, col.Name)
+		}
+	}
+	assert.Equal(t, []string{"latitude", "longitude", "accuracy_radius"}, ignored)
+
+	p, ok = Lookup("subdivision")
+	require.True(t, ok)
+	for _, col := range p.Columns {
+		assert.False(t, col.MergeIgnore, col.Name)
+	}
+}
@@ -0,0 +1,190 @@
+// Package profile samples a merge to report, per column, the types of the
+// values its path resolves to and a few example values, so that paths that
+// resolve to a map in some records and to a scalar in others are caught
+// before a full run.
+package profile
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// Defaults for Run.
+const (
+	DefaultSampleRanges = 100000
+	DefaultExamples     = 3
+)
+
+// typeNull names missing values in TypeCount.
+const typeNull = "null"
+
+// maxExampleLength is the length at which example values are cut off.
+const maxExampleLength = 60
+
+// TypeCount is the number of sampled rows in which a column had a value of
+// type Type, the name of the mmdbtype type, such as "String" or "Map", or
+// "null" for rows without a value.
+type TypeCount struct {
+	Type  string
+	Count int64
+}
+
+// Column is the profile of one data column.
+type Column struct {
+	Name     string
+	Database string
+	// Types is the distribution of value types, most frequent first.
+	Types []TypeCount
+	// Examples holds distinct values, formatted as in CSV output, with at
+	// least one of every type.
+	Examples []string
+}
+
+// Mixed reports whether the column resolved both to containers (maps or
+// slices) and to scalars. Such columns typically have a path that selects
+// different fields in different records.
+func (c *Column) Mixed() bool {
+	var containers, scalars bool
+	for _, t := range c.Types {
+		switch t.Type {
+		case typeNull:
+		case "Map", "Slice":
+			containers = true
+		default:
+			scalars = true
+		}
+	}
+	return containers && scalars
+}
+
+// Report is the profile of a sample of the merge.
+type Report struct {
+	Rows    int64 // Sampled merged ranges
+	Columns []Column
+	// Complete is set when the sample covered the whole merge.
+	Complete bool
+}
+
+// Mixed returns the columns that resolved both to containers and to
+// scalars.
+func (r *Report) Mixed() []Column {
+	var mixed []Column
+	for _, col := range r.Columns {
+		if col.Mixed() {
+			mixed = append(mixed, col)
+		}
+	}
+	return mixed
+}
+
+// errSampleFull stops the merge once the sample is complete.
+var errSampleFull = errors.New("sample complete")
+
+// Run profiles the first sampleRanges merged ranges of the export
+// configured by cfg, or every range if sampleRanges is 0, keeping up to
+// examples distinct example values per column.
+func Run(readers *mmdb.Readers, cfg *config.Config, sampleRanges, examples int) (*Report, error) {
+	p := &profiler{
+		limit:    sampleRanges,
+		examples: examples,
+		types:    make([]map[string]int64, len(cfg.Columns)),
+		seen:     make([]map[string]bool, len(cfg.Columns)),
+		exampled: make([]map[string]bool, len(cfg.Columns)),
+		report:   &Report{Columns: make([]Column, len(cfg.Columns))},
+	}
+	for i, col := range cfg.Columns {
+		p.types[i] = map[string]int64{}
+		p.seen[i] = map[string]bool{}
+		p.exampled[i] = map[string]bool{}
+		p.report.Columns[i] = Column{Name: string(col.Name), Database: col.Database}
+	}
+
+	m, err := merger.NewMerger(readers, cfg, p)
+	if err != nil {
+		return nil, fmt.Errorf("creating merger: %w", err)
+	}
+	err = m.Merge()
+	if err != nil && !errors.Is(err, errSampleFull) {
+		return nil, fmt.Errorf("merging sample: %w", err)
+	}
+	p.report.Complete = err == nil
+
+	for i := range p.report.Columns {
+		col := &p.report.Columns[i]
+		for name, count := range p.types[i] {
+			col.Types = append(col.Types, TypeCount{Type: name, Count: count})
+		}
+		slices.SortFunc(col.Types, func(a, b TypeCount) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Type, b.Type))
+		})
+	}
+	return p.report, nil
+}
+
+// profiler is a row.RangeWriter tallying the values of the merged ranges.
+type profiler struct {
+	limit    int
+	examples int
+	types    []map[string]int64 // Per column: count per type name
+	seen     []map[string]bool  // Per column: example values
+	exampled []map[string]bool  // Per column: types with an example
+	report   *Report
+}
+
+func (p *profiler) WriteRow(_ netip.Prefix, r row.Row) error {
+	return p.record(r)
+}
+
+func (p *profiler) WriteRange(_, _ netip.Addr, r row.Row) error {
+	return p.record(r)
+}
+
+func (p *profiler) record(r row.Row) error {
+	if p.limit > 0 && p.report.Rows >= int64(p.limit) {
+		return errSampleFull
+	}
+	p.report.Rows++
+
+	for i, value := range r {
+		name := typeName(value)
+		p.types[i][name]++
+		if value == nil || (len(p.seen[i]) >= p.examples && p.exampled[i][name]) {
+			continue
+		}
+		example, err := row.FormatValue(value)
+		if err != nil {
+			return fmt.Errorf("formatting value of column '%s': %w", p.report.Columns[i].Name, err)
+		}
+		if utf8.RuneCountInString(example) > maxExampleLength {
+			example = string([]rune(example)[:maxExampleLength]) + "..."
+		}
+		if !p.seen[i][example] {
+			p.seen[i][example] = true
+			p.exampled[i][name] = true
+			p.report.Columns[i].Examples = append(p.report.Columns[i].Examples, example)
+		}
+	}
+	return nil
+}
+
+// typeName returns the name of the mmdbtype type of value.
+func typeName(value mmdbtype.DataType) string {
+	if value == nil {
+		return typeNull
+	}
+	name := fmt.Sprintf("%T", value)
+	name = strings.TrimPrefix(name, "*")
+	return strings.TrimPrefix(name, "mmdbtype.")
+}
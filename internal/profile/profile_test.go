@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/mmdbtest"
+)
+
+func openTestDatabase(t *testing.T, records map[string]mmdbtype.Map) *mmdb.Readers {
+	t.Helper()
+
+	path := mmdbtest.WriteTemp(t, mmdbtest.Options(), records)
+	readers, err := mmdb.OpenDatabases(map[string]config.Database{
+		"db": {Name: "db", Path: path},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { readers.Close() })
+	return readers
+}
+
+func TestRun(t *testing.T) {
+	readers := openTestDatabase(t, map[string]mmdbtype.Map{
+		"1.0.0.0/24": {
+			"city":     mmdbtype.Map{"name": mmdbtype.String("Sydney")},
+			"accuracy": mmdbtype.Uint16(100),
+		},
+		"2.0.0.0/24": {
+			"city":     mmdbtype.String("Paris"),
+			"accuracy": mmdbtype.Uint16(20),
+		},
+		"3.0.0.0/24": {"accuracy": mmdbtype.Uint16(20)},
+		"4.0.0.0/24": {"accuracy": mmdbtype.Uint16(5)},
+	})
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "city", Database: "db", Path: config.Path{"city"}},
+			{Name: "accuracy", Database: "db", Path: config.Path{"accuracy"}},
+		},
+	}
+
+	report, err := Run(readers, cfg, 0, 1)
+	require.NoError(t, err)
+	assert.True(t, report.Complete)
+	assert.Equal(t, int64(4), report.Rows)
+
+	city := report.Columns[0]
+	assert.Equal(t, "db", city.Database)
+	assert.Equal(t, []TypeCount{
+		{Type: "null", Count: 2},
+		{Type: "Map", Count: 1},
+		{Type: "String", Count: 1},
+	}, city.Types)
+	assert.Equal(
+		t,
+		[]string{`{"name":"Sydney"}`, "Paris"},
+		city.Examples,
+		"every type has an example",
+	)
+	assert.True(t, city.Mixed())
+
+	accuracy := report.Columns[1]
+	assert.Equal(t, []TypeCount{{Type: "Uint16", Count: 4}}, accuracy.Types)
+	assert.Equal(t, []string{"100"}, accuracy.Examples)
+	assert.False(t, accuracy.Mixed())
+
+	mixed := report.Mixed()
+	require.Len(t, mixed, 1)
+	assert.Equal(t, "city", mixed[0].Name)
+
+	t.Run("sample", func(t *testing.T) {
+		report, err := Run(readers, cfg, 2, DefaultExamples)
+		require.NoError(t, err)
+		assert.False(t, report.Complete)
+		assert.Equal(t, int64(2), report.Rows)
+	})
+}
@@ -0,0 +1,69 @@
+// Package provenance describes how an output was produced: the tool version,
+// the configuration, and the source databases. Every output format records
+// the same key/value pairs, produced here, so that the information can be
+// compared across formats.
+package provenance
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// KeyPrefix starts every provenance key, keeping the keys apart from other
+// metadata stored alongside them.
+const KeyPrefix = "mmdbconvert."
+
+// Source describes a database read to produce the output.
+type Source struct {
+	Name         string
+	DatabaseType string
+	BuildEpoch   uint
+	Tags         map[string]string // Tags configured for the database
+}
+
+// Info is the provenance of an output. The zero value records nothing.
+type Info struct {
+	ToolVersion  string
+	ConfigSHA256 string // Hex SHA-256 of the configuration file
+	Sources      []Source
+	Redactions   []Redaction // Columns redacted with --redact
+}
+
+// Redaction records the redaction policy applied to a column, such as
+// "hash" or "truncate:2" for coordinates cut to two decimal places.
+type Redaction struct {
+	Column string
+	Policy string
+}
+
+// Pair is a provenance key and its value.
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// Pairs returns the provenance as key/value pairs in a stable order. Empty
+// fields are left out.
+func (i Info) Pairs() []Pair {
+	var pairs []Pair
+	add := func(key, value string) {
+		if value != "" {
+			pairs = append(pairs, Pair{Key: KeyPrefix + key, Value: value})
+		}
+	}
+
+	add("version", i.ToolVersion)
+	add("config_sha256", i.ConfigSHA256)
+	for _, s := range i.Sources {
+		add("source."+s.Name+".database_type", s.DatabaseType)
+		add("source."+s.Name+".build_epoch", strconv.FormatUint(uint64(s.BuildEpoch), 10))
+		for _, key := range slices.Sorted(maps.Keys(s.Tags)) {
+			add("source."+s.Name+".tag."+key, s.Tags[key])
+		}
+	}
+	for _, r := range i.Redactions {
+		add("redact."+r.Column, r.Policy)
+	}
+	return pairs
+}
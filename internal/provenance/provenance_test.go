@@ -0,0 +1,41 @@
+package provenance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfo_Pairs(t *testing.T) {
+	info := Info{
+		ToolVersion:  "1.2.3",
+		ConfigSHA256: "abc123",
+		Sources: []Source{
+			{
+				Name:         "city",
+				DatabaseType: "GeoIP2-City",
+				BuildEpoch:   1700000000,
+				Tags:         map[string]string{"vendor": "maxmind", "edition": "GeoIP2-City"},
+			},
+			{Name: "anon", BuildEpoch: 1700000001},
+		},
+		Redactions: []Redaction{
+			{Column: "latitude", Policy: "truncate:1"},
+			{Column: "user_id", Policy: "hash"},
+		},
+	}
+
+	assert.Equal(t, []Pair{
+		{Key: "mmdbconvert.version", Value: "1.2.3"},
+		{Key: "mmdbconvert.config_sha256", Value: "abc123"},
+		{Key: "mmdbconvert.source.city.database_type", Value: "GeoIP2-City"},
+		{Key: "mmdbconvert.source.city.build_epoch", Value: "1700000000"},
+		{Key: "mmdbconvert.source.city.tag.edition", Value: "GeoIP2-City"},
+		{Key: "mmdbconvert.source.city.tag.vendor", Value: "maxmind"},
+		{Key: "mmdbconvert.source.anon.build_epoch", Value: "1700000001"},
+		{Key: "mmdbconvert.redact.latitude", Value: "truncate:1"},
+		{Key: "mmdbconvert.redact.user_id", Value: "hash"},
+	}, info.Pairs())
+
+	assert.Empty(t, Info{}.Pairs(), "the zero value records nothing")
+}
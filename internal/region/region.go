@@ -0,0 +1,380 @@
+// Package region maps country codes to the continent, European Union
+// membership, and UN region of the country, for databases that only record
+// the country. The built-in reference data can be overridden from a CSV
+// file.
+package region
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Properties of a country, named as the fields of GeoIP2 records.
+const (
+	ContinentCode     = "continent_code"
+	IsInEuropeanUnion = "is_in_european_union"
+	UNRegion          = "un_region"
+)
+
+// Properties returns the supported property names.
+func Properties() []string {
+	return []string{ContinentCode, IsInEuropeanUnion, UNRegion}
+}
+
+// Country is the reference data of a country.
+type Country struct {
+	ContinentCode     string // Two-letter continent code, such as "EU"
+	IsInEuropeanUnion bool   // Whether the country is a member state of the EU
+	UNRegion          string // UN M49 region, such as "Europe"; empty for none
+}
+
+// Table maps ISO 3166-1 alpha-2 country codes to their reference data.
+type Table struct {
+	countries map[string]Country
+}
+
+// Default returns the table of the built-in reference data.
+func Default() *Table {
+	return &Table{countries: countries}
+}
+
+// Load returns the built-in table with the countries of the CSV file at path
+// overriding it. The file has a header naming the column "country", holding
+// alpha-2 codes, and any of the Properties. Each row replaces the listed
+// properties of its country, and an empty value clears them.
+func Load(path string) (*Table, error) {
+	// #nosec G304 -- path comes from the trusted configuration
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header of %s: %w", path, err)
+	}
+	if len(header) == 0 || header[0] != "country" {
+		return nil, fmt.Errorf("%s: the first column must be 'country'", path)
+	}
+	for _, name := range header[1:] {
+		if !slices.Contains(Properties(), name) {
+			return nil, fmt.Errorf(
+				"%s: unknown column '%s', must be one of: %s",
+				path,
+				name,
+				strings.Join(Properties(), ", "),
+			)
+		}
+	}
+
+	t := &Table{countries: maps.Clone(countries)}
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return t, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		code := strings.ToUpper(record[0])
+		country := t.countries[code]
+		for i, name := range header[1:] {
+			value := record[i+1]
+			switch name {
+			case ContinentCode:
+				country.ContinentCode = value
+			case UNRegion:
+				country.UNRegion = value
+			case IsInEuropeanUnion:
+				if value == "" {
+					country.IsInEuropeanUnion = false
+					continue
+				}
+				member, err := strconv.ParseBool(value)
+				if err != nil {
+					line, _ := r.FieldPos(i + 1)
+					return nil, fmt.Errorf(
+						"%s:%d: invalid %s '%s' for %s",
+						path,
+						line,
+						name,
+						value,
+						code,
+					)
+				}
+				country.IsInEuropeanUnion = member
+			}
+		}
+		t.countries[code] = country
+	}
+}
+
+// Lookup returns the reference data of the country with the alpha-2 code,
+// in any case.
+func (t *Table) Lookup(code string) (Country, bool) {
+	country, ok := t.countries[strings.ToUpper(code)]
+	return country, ok
+}
+
+// countries is the built-in reference data. Continents are those GeoIP2
+// databases use, and UN regions are those of the UN M49 standard.
+var countries = map[string]Country{
+	"AD": {ContinentCode: "EU", UNRegion: "Europe"},
+	"AE": {ContinentCode: "AS", UNRegion: "Asia"},
+	"AF": {ContinentCode: "AS", UNRegion: "Asia"},
+	"AG": {ContinentCode: "NA", UNRegion: "Americas"},
+	"AI": {ContinentCode: "NA", UNRegion: "Americas"},
+	"AL": {ContinentCode: "EU", UNRegion: "Europe"},
+	"AM": {ContinentCode: "AS", UNRegion: "Asia"},
+	"AO": {ContinentCode: "AF", UNRegion: "Africa"},
+	"AQ": {ContinentCode: "AN"},
+	"AR": {ContinentCode: "SA", UNRegion: "Americas"},
+	"AS": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"AT": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"AU": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"AW": {ContinentCode: "NA", UNRegion: "Americas"},
+	"AX": {ContinentCode: "EU", UNRegion: "Europe"},
+	"AZ": {ContinentCode: "AS", UNRegion: "Asia"},
+	"BA": {ContinentCode: "EU", UNRegion: "Europe"},
+	"BB": {ContinentCode: "NA", UNRegion: "Americas"},
+	"BD": {ContinentCode: "AS", UNRegion: "Asia"},
+	"BE": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"BF": {ContinentCode: "AF", UNRegion: "Africa"},
+	"BG": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"BH": {ContinentCode: "AS", UNRegion: "Asia"},
+	"BI": {ContinentCode: "AF", UNRegion: "Africa"},
+	"BJ": {ContinentCode: "AF", UNRegion: "Africa"},
+	"BL": {ContinentCode: "NA", UNRegion: "Americas"},
+	"BM": {ContinentCode: "NA", UNRegion: "Americas"},
+	"BN": {ContinentCode: "AS", UNRegion: "Asia"},
+	"BO": {ContinentCode: "SA", UNRegion: "Americas"},
+	"BQ": {ContinentCode: "NA", UNRegion: "Americas"},
+	"BR": {ContinentCode: "SA", UNRegion: "Americas"},
+	"BS": {ContinentCode: "NA", UNRegion: "Americas"},
+	"BT": {ContinentCode: "AS", UNRegion: "Asia"},
+	"BV": {ContinentCode: "AN", UNRegion: "Americas"},
+	"BW": {ContinentCode: "AF", UNRegion: "Africa"},
+	"BY": {ContinentCode: "EU", UNRegion: "Europe"},
+	"BZ": {ContinentCode: "NA", UNRegion: "Americas"},
+	"CA": {ContinentCode: "NA", UNRegion: "Americas"},
+	"CC": {ContinentCode: "AS", UNRegion: "Oceania"},
+	"CD": {ContinentCode: "AF", UNRegion: "Africa"},
+	"CF": {ContinentCode: "AF", UNRegion: "Africa"},
+	"CG": {ContinentCode: "AF", UNRegion: "Africa"},
+	"CH": {ContinentCode: "EU", UNRegion: "Europe"},
+	"CI": {ContinentCode: "AF", UNRegion: "Africa"},
+	"CK": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"CL": {ContinentCode: "SA", UNRegion: "Americas"},
+	"CM": {ContinentCode: "AF", UNRegion: "Africa"},
+	"CN": {ContinentCode: "AS", UNRegion: "Asia"},
+	"CO": {ContinentCode: "SA", UNRegion: "Americas"},
+	"CR": {ContinentCode: "NA", UNRegion: "Americas"},
+	"CU": {ContinentCode: "NA", UNRegion: "Americas"},
+	"CV": {ContinentCode: "AF", UNRegion: "Africa"},
+	"CW": {ContinentCode: "NA", UNRegion: "Americas"},
+	"CX": {ContinentCode: "AS", UNRegion: "Oceania"},
+	"CY": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Asia"},
+	"CZ": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"DE": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"DJ": {ContinentCode: "AF", UNRegion: "Africa"},
+	"DK": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"DM": {ContinentCode: "NA", UNRegion: "Americas"},
+	"DO": {ContinentCode: "NA", UNRegion: "Americas"},
+	"DZ": {ContinentCode: "AF", UNRegion: "Africa"},
+	"EC": {ContinentCode: "SA", UNRegion: "Americas"},
+	"EE": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"EG": {ContinentCode: "AF", UNRegion: "Africa"},
+	"EH": {ContinentCode: "AF", UNRegion: "Africa"},
+	"ER": {ContinentCode: "AF", UNRegion: "Africa"},
+	"ES": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"ET": {ContinentCode: "AF", UNRegion: "Africa"},
+	"FI": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"FJ": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"FK": {ContinentCode: "SA", UNRegion: "Americas"},
+	"FM": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"FO": {ContinentCode: "EU", UNRegion: "Europe"},
+	"FR": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"GA": {ContinentCode: "AF", UNRegion: "Africa"},
+	"GB": {ContinentCode: "EU", UNRegion: "Europe"},
+	"GD": {ContinentCode: "NA", UNRegion: "Americas"},
+	"GE": {ContinentCode: "AS", UNRegion: "Asia"},
+	"GF": {ContinentCode: "SA", UNRegion: "Americas"},
+	"GG": {ContinentCode: "EU", UNRegion: "Europe"},
+	"GH": {ContinentCode: "AF", UNRegion: "Africa"},
+	"GI": {ContinentCode: "EU", UNRegion: "Europe"},
+	"GL": {ContinentCode: "NA", UNRegion: "Americas"},
+	"GM": {ContinentCode: "AF", UNRegion: "Africa"},
+	"GN": {ContinentCode: "AF", UNRegion: "Africa"},
+	"GP": {ContinentCode: "NA", UNRegion: "Americas"},
+	"GQ": {ContinentCode: "AF", UNRegion: "Africa"},
+	"GR": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"GS": {ContinentCode: "AN", UNRegion: "Americas"},
+	"GT": {ContinentCode: "NA", UNRegion: "Americas"},
+	"GU": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"GW": {ContinentCode: "AF", UNRegion: "Africa"},
+	"GY": {ContinentCode: "SA", UNRegion: "Americas"},
+	"HK": {ContinentCode: "AS", UNRegion: "Asia"},
+	"HM": {ContinentCode: "AN", UNRegion: "Oceania"},
+	"HN": {ContinentCode: "NA", UNRegion: "Americas"},
+	"HR": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"HT": {ContinentCode: "NA", UNRegion: "Americas"},
+	"HU": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"ID": {ContinentCode: "AS", UNRegion: "Asia"},
+	"IE": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"IL": {ContinentCode: "AS", UNRegion: "Asia"},
+	"IM": {ContinentCode: "EU", UNRegion: "Europe"},
+	"IN": {ContinentCode: "AS", UNRegion: "Asia"},
+	"IO": {ContinentCode: "AS", UNRegion: "Africa"},
+	"IQ": {ContinentCode: "AS", UNRegion: "Asia"},
+	"IR": {ContinentCode: "AS", UNRegion: "Asia"},
+	"IS": {ContinentCode: "EU", UNRegion: "Europe"},
+	"IT": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"JE": {ContinentCode: "EU", UNRegion: "Europe"},
+	"JM": {ContinentCode: "NA", UNRegion: "Americas"},
+	"JO": {ContinentCode: "AS", UNRegion: "Asia"},
+	"JP": {ContinentCode: "AS", UNRegion: "Asia"},
+	"KE": {ContinentCode: "AF", UNRegion: "Africa"},
+	"KG": {ContinentCode: "AS", UNRegion: "Asia"},
+	"KH": {ContinentCode: "AS", UNRegion: "Asia"},
+	"KI": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"KM": {ContinentCode: "AF", UNRegion: "Africa"},
+	"KN": {ContinentCode: "NA", UNRegion: "Americas"},
+	"KP": {ContinentCode: "AS", UNRegion: "Asia"},
+	"KR": {ContinentCode: "AS", UNRegion: "Asia"},
+	"KW": {ContinentCode: "AS", UNRegion: "Asia"},
+	"KY": {ContinentCode: "NA", UNRegion: "Americas"},
+	"KZ": {ContinentCode: "AS", UNRegion: "Asia"},
+	"LA": {ContinentCode: "AS", UNRegion: "Asia"},
+	"LB": {ContinentCode: "AS", UNRegion: "Asia"},
+	"LC": {ContinentCode: "NA", UNRegion: "Americas"},
+	"LI": {ContinentCode: "EU", UNRegion: "Europe"},
+	"LK": {ContinentCode: "AS", UNRegion: "Asia"},
+	"LR": {ContinentCode: "AF", UNRegion: "Africa"},
+	"LS": {ContinentCode: "AF", UNRegion: "Africa"},
+	"LT": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"LU": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"LV": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"LY": {ContinentCode: "AF", UNRegion: "Africa"},
+	"MA": {ContinentCode: "AF", UNRegion: "Africa"},
+	"MC": {ContinentCode: "EU", UNRegion: "Europe"},
+	"MD": {ContinentCode: "EU", UNRegion: "Europe"},
+	"ME": {ContinentCode: "EU", UNRegion: "Europe"},
+	"MF": {ContinentCode: "NA", UNRegion: "Americas"},
+	"MG": {ContinentCode: "AF", UNRegion: "Africa"},
+	"MH": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"MK": {ContinentCode: "EU", UNRegion: "Europe"},
+	"ML": {ContinentCode: "AF", UNRegion: "Africa"},
+	"MM": {ContinentCode: "AS", UNRegion: "Asia"},
+	"MN": {ContinentCode: "AS", UNRegion: "Asia"},
+	"MO": {ContinentCode: "AS", UNRegion: "Asia"},
+	"MP": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"MQ": {ContinentCode: "NA", UNRegion: "Americas"},
+	"MR": {ContinentCode: "AF", UNRegion: "Africa"},
+	"MS": {ContinentCode: "NA", UNRegion: "Americas"},
+	"MT": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"MU": {ContinentCode: "AF", UNRegion: "Africa"},
+	"MV": {ContinentCode: "AS", UNRegion: "Asia"},
+	"MW": {ContinentCode: "AF", UNRegion: "Africa"},
+	"MX": {ContinentCode: "NA", UNRegion: "Americas"},
+	"MY": {ContinentCode: "AS", UNRegion: "Asia"},
+	"MZ": {ContinentCode: "AF", UNRegion: "Africa"},
+	"NA": {ContinentCode: "AF", UNRegion: "Africa"},
+	"NC": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"NE": {ContinentCode: "AF", UNRegion: "Africa"},
+	"NF": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"NG": {ContinentCode: "AF", UNRegion: "Africa"},
+	"NI": {ContinentCode: "NA", UNRegion: "Americas"},
+	"NL": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"NO": {ContinentCode: "EU", UNRegion: "Europe"},
+	"NP": {ContinentCode: "AS", UNRegion: "Asia"},
+	"NR": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"NU": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"NZ": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"OM": {ContinentCode: "AS", UNRegion: "Asia"},
+	"PA": {ContinentCode: "NA", UNRegion: "Americas"},
+	"PE": {ContinentCode: "SA", UNRegion: "Americas"},
+	"PF": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"PG": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"PH": {ContinentCode: "AS", UNRegion: "Asia"},
+	"PK": {ContinentCode: "AS", UNRegion: "Asia"},
+	"PL": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"PM": {ContinentCode: "NA", UNRegion: "Americas"},
+	"PN": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"PR": {ContinentCode: "NA", UNRegion: "Americas"},
+	"PS": {ContinentCode: "AS", UNRegion: "Asia"},
+	"PT": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"PW": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"PY": {ContinentCode: "SA", UNRegion: "Americas"},
+	"QA": {ContinentCode: "AS", UNRegion: "Asia"},
+	"RE": {ContinentCode: "AF", UNRegion: "Africa"},
+	"RO": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"RS": {ContinentCode: "EU", UNRegion: "Europe"},
+	"RU": {ContinentCode: "EU", UNRegion: "Europe"},
+	"RW": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SA": {ContinentCode: "AS", UNRegion: "Asia"},
+	"SB": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"SC": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SD": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SE": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"SG": {ContinentCode: "AS", UNRegion: "Asia"},
+	"SH": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SI": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"SJ": {ContinentCode: "EU", UNRegion: "Europe"},
+	"SK": {ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"},
+	"SL": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SM": {ContinentCode: "EU", UNRegion: "Europe"},
+	"SN": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SO": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SR": {ContinentCode: "SA", UNRegion: "Americas"},
+	"SS": {ContinentCode: "AF", UNRegion: "Africa"},
+	"ST": {ContinentCode: "AF", UNRegion: "Africa"},
+	"SV": {ContinentCode: "NA", UNRegion: "Americas"},
+	"SX": {ContinentCode: "NA", UNRegion: "Americas"},
+	"SY": {ContinentCode: "AS", UNRegion: "Asia"},
+	"SZ": {ContinentCode: "AF", UNRegion: "Africa"},
+	"TC": {ContinentCode: "NA", UNRegion: "Americas"},
+	"TD": {ContinentCode: "AF", UNRegion: "Africa"},
+	"TF": {ContinentCode: "AN", UNRegion: "Africa"},
+	"TG": {ContinentCode: "AF", UNRegion: "Africa"},
+	"TH": {ContinentCode: "AS", UNRegion: "Asia"},
+	"TJ": {ContinentCode: "AS", UNRegion: "Asia"},
+	"TK": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"TL": {ContinentCode: "OC", UNRegion: "Asia"},
+	"TM": {ContinentCode: "AS", UNRegion: "Asia"},
+	"TN": {ContinentCode: "AF", UNRegion: "Africa"},
+	"TO": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"TR": {ContinentCode: "AS", UNRegion: "Asia"},
+	"TT": {ContinentCode: "NA", UNRegion: "Americas"},
+	"TV": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"TW": {ContinentCode: "AS", UNRegion: "Asia"},
+	"TZ": {ContinentCode: "AF", UNRegion: "Africa"},
+	"UA": {ContinentCode: "EU", UNRegion: "Europe"},
+	"UG": {ContinentCode: "AF", UNRegion: "Africa"},
+	"UM": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"US": {ContinentCode: "NA", UNRegion: "Americas"},
+	"UY": {ContinentCode: "SA", UNRegion: "Americas"},
+	"UZ": {ContinentCode: "AS", UNRegion: "Asia"},
+	"VA": {ContinentCode: "EU", UNRegion: "Europe"},
+	"VC": {ContinentCode: "NA", UNRegion: "Americas"},
+	"VE": {ContinentCode: "SA", UNRegion: "Americas"},
+	"VG": {ContinentCode: "NA", UNRegion: "Americas"},
+	"VI": {ContinentCode: "NA", UNRegion: "Americas"},
+	"VN": {ContinentCode: "AS", UNRegion: "Asia"},
+	"VU": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"WF": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"WS": {ContinentCode: "OC", UNRegion: "Oceania"},
+	"YE": {ContinentCode: "AS", UNRegion: "Asia"},
+	"YT": {ContinentCode: "AF", UNRegion: "Africa"},
+	"ZA": {ContinentCode: "AF", UNRegion: "Africa"},
+	"ZM": {ContinentCode: "AF", UNRegion: "Africa"},
+	"ZW": {ContinentCode: "AF", UNRegion: "Africa"},
+}
@@ -0,0 +1,93 @@
+package region
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	table := Default()
+
+	country, ok := table.Lookup("de")
+	require.True(t, ok)
+	assert.Equal(t, Country{ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Europe"}, country)
+
+	country, ok = table.Lookup("CY")
+	require.True(t, ok)
+	assert.Equal(t, Country{ContinentCode: "EU", IsInEuropeanUnion: true, UNRegion: "Asia"}, country)
+
+	country, ok = table.Lookup("AQ")
+	require.True(t, ok)
+	assert.Equal(t, Country{ContinentCode: "AN"}, country)
+
+	_, ok = table.Lookup("XK")
+	assert.False(t, ok)
+
+	members := 0
+	for _, country := range countries {
+		if country.IsInEuropeanUnion {
+			members++
+		}
+	}
+	assert.Equal(t, 27, members)
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "countries.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	table, err := Load(writeFile(t, "country,is_in_european_union,un_region\nxk,false,Europe\nGF,true,\n"))
+	require.NoError(t, err)
+
+	// New countries are added, with the properties listed
+	country, ok := table.Lookup("XK")
+	require.True(t, ok)
+	assert.Equal(t, Country{UNRegion: "Europe"}, country)
+
+	// Listed properties are replaced, and empty values clear them
+	country, ok = table.Lookup("GF")
+	require.True(t, ok)
+	assert.Equal(t, Country{ContinentCode: "SA", IsInEuropeanUnion: true}, country)
+
+	// The built-in table is unchanged
+	_, ok = Default().Lookup("XK")
+	assert.False(t, ok)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectError string
+	}{
+		{
+			name:        "missing country column",
+			content:     "code,continent_code\nXK,EU\n",
+			expectError: "the first column must be 'country'",
+		},
+		{
+			name:        "unknown column",
+			content:     "country,region\nXK,Europe\n",
+			expectError: "unknown column 'region', must be one of: continent_code, is_in_european_union, un_region",
+		},
+		{
+			name:        "invalid boolean",
+			content:     "country,is_in_european_union\nXK,maybe\n",
+			expectError: ":2: invalid is_in_european_union 'maybe' for XK",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeFile(t, tt.content))
+			require.ErrorContains(t, err, tt.expectError)
+		})
+	}
+}
@@ -0,0 +1,537 @@
+// Package row defines the rows passed from the merger to output writers,
+// the interfaces writers implement, and the interface reading rows back from
+// an output. A Row holds the data column values of one merged network. Its
+// accessors return plain Go values and its setters take them, so that
+// writers and row transformations do not need to handle MMDB types; only
+// the extractor and the MMDB writer work with the underlying values.
+package row
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// Writer writes rows for CIDR prefixes. Rows arrive in ascending address
+// order.
+type Writer interface {
+	WriteRow(prefix netip.Prefix, r Row) error
+}
+
+// RangeWriter is a Writer that can also write a row for an arbitrary address
+// range, avoiding the split into CIDR prefixes.
+type RangeWriter interface {
+	Writer
+	WriteRange(start, end netip.Addr, r Row) error
+}
+
+// Flusher is implemented by writers that buffer output.
+type Flusher interface {
+	Flush() error
+}
+
+// Syncer is implemented by writers that can commit written rows to stable
+// storage, so that they survive a crash and are visible to readers of the
+// output file.
+type Syncer interface {
+	Sync() error
+}
+
+// Reader reads the ranges of an existing output, such as an earlier export,
+// with their data column values.
+type Reader interface {
+	// Columns returns the names of the data columns, in row order.
+	Columns() []string
+	// Read returns the next range and its row, or io.EOF after the last
+	// one. The row is only valid until the next call.
+	Read() (start, end netip.Addr, r Row, err error)
+	Close() error
+}
+
+// WriteRange writes a range to w, using WriteRange if w is a RangeWriter and
+// otherwise writing one row per CIDR prefix in the range.
+func WriteRange(w Writer, start, end netip.Addr, r Row) error {
+	if rangeWriter, ok := w.(RangeWriter); ok {
+		return rangeWriter.WriteRange(start, end, r)
+	}
+	for _, cidr := range network.RangeToPrefixes(start, end) {
+		if err := w.WriteRow(cidr, r); err != nil {
+			return fmt.Errorf("writing row for %s: %w", cidr, err)
+		}
+	}
+	return nil
+}
+
+// Flush flushes w if it is a Flusher.
+func Flush(w Writer) error {
+	if flusher, ok := w.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Sync syncs w if it is a Syncer.
+func Sync(w Writer) error {
+	if syncer, ok := w.(Syncer); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// Kind identifies the type of a column value.
+type Kind int
+
+// Value kinds.
+const (
+	KindNull Kind = iota
+	KindString
+	KindBool
+	KindInt   // Signed integer (MMDB int32)
+	KindUint  // Unsigned integer of up to 128 bits
+	KindFloat // Float or double
+	KindBytes // Byte string
+	KindMap   // Map with string keys
+	KindSlice // Array
+)
+
+// String returns the name of the kind as used in error messages.
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindString:
+		return "string"
+	case KindBool:
+		return "bool"
+	case KindInt:
+		return "int"
+	case KindUint:
+		return "uint"
+	case KindFloat:
+		return "float"
+	case KindBytes:
+		return "bytes"
+	case KindMap:
+		return "map"
+	case KindSlice:
+		return "slice"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Row holds the data column values of a merged network, ordered like
+// config.Columns. A nil value means the column has no data.
+//
+// Row is a slice of MMDB values as produced by the extractor. Code outside
+// the extractor and the MMDB writer should read and change values through
+// the accessors and setters rather than indexing the slice. A Row passed to
+// a writer is only valid for the duration of the call.
+type Row []mmdbtype.DataType
+
+// Kind returns the kind of the value in column i.
+func (r Row) Kind(i int) Kind {
+	switch r[i].(type) {
+	case nil:
+		return KindNull
+	case mmdbtype.String:
+		return KindString
+	case mmdbtype.Bool:
+		return KindBool
+	case mmdbtype.Int32:
+		return KindInt
+	case mmdbtype.Uint16, mmdbtype.Uint32, mmdbtype.Uint64, *mmdbtype.Uint128:
+		return KindUint
+	case mmdbtype.Float32, mmdbtype.Float64:
+		return KindFloat
+	case mmdbtype.Bytes:
+		return KindBytes
+	case mmdbtype.Map:
+		return KindMap
+	case mmdbtype.Slice:
+		return KindSlice
+	default:
+		return KindNull
+	}
+}
+
+// IsNull reports whether column i has no value.
+func (r Row) IsNull(i int) bool {
+	return r[i] == nil
+}
+
+// IsEmpty reports whether every column is null.
+func (r Row) IsEmpty() bool {
+	for _, v := range r {
+		if v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the value of column i if it is a string.
+func (r Row) String(i int) (string, bool) {
+	v, ok := r[i].(mmdbtype.String)
+	return string(v), ok
+}
+
+// Bool returns the value of column i if it is a boolean.
+func (r Row) Bool(i int) (bool, bool) {
+	v, ok := r[i].(mmdbtype.Bool)
+	return bool(v), ok
+}
+
+// Bytes returns the value of column i if it is a byte string.
+func (r Row) Bytes(i int) ([]byte, bool) {
+	v, ok := r[i].(mmdbtype.Bytes)
+	return []byte(v), ok
+}
+
+// BigInt returns the value of column i if it is an integer of any size.
+// The result is a new big.Int that the caller may modify.
+func (r Row) BigInt(i int) (*big.Int, bool) {
+	switch v := r[i].(type) {
+	case mmdbtype.Int32:
+		return big.NewInt(int64(v)), true
+	case mmdbtype.Uint16:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case mmdbtype.Uint32:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case mmdbtype.Uint64:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case *mmdbtype.Uint128:
+		return new(big.Int).Set((*big.Int)(v)), true
+	default:
+		return nil, false
+	}
+}
+
+// Int64 returns the value of column i if it is an integer that fits in an
+// int64.
+func (r Row) Int64(i int) (int64, bool) {
+	switch v := r[i].(type) {
+	case mmdbtype.Int32:
+		return int64(v), true
+	case mmdbtype.Uint16:
+		return int64(v), true
+	case mmdbtype.Uint32:
+		return int64(v), true
+	case mmdbtype.Uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case *mmdbtype.Uint128:
+		b := (*big.Int)(v)
+		if !b.IsInt64() {
+			return 0, false
+		}
+		return b.Int64(), true
+	default:
+		return 0, false
+	}
+}
+
+// Uint64 returns the value of column i if it is a non-negative integer that
+// fits in a uint64.
+func (r Row) Uint64(i int) (uint64, bool) {
+	switch v := r[i].(type) {
+	case mmdbtype.Int32:
+		if v < 0 {
+			return 0, false
+		}
+		return uint64(v), true
+	case mmdbtype.Uint16:
+		return uint64(v), true
+	case mmdbtype.Uint32:
+		return uint64(v), true
+	case mmdbtype.Uint64:
+		return uint64(v), true
+	case *mmdbtype.Uint128:
+		b := (*big.Int)(v)
+		if !b.IsUint64() {
+			return 0, false
+		}
+		return b.Uint64(), true
+	default:
+		return 0, false
+	}
+}
+
+// Float64 returns the value of column i if it is a float or double.
+func (r Row) Float64(i int) (float64, bool) {
+	switch v := r[i].(type) {
+	case mmdbtype.Float32:
+		return float64(v), true
+	case mmdbtype.Float64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// Interface returns the value of column i as a plain Go value: nil, string,
+// bool, int64, uint64, *big.Int (for integers above 64 bits), float64,
+// []byte, map[string]any, or []any.
+func (r Row) Interface(i int) any {
+	return plain(r[i])
+}
+
+// Text returns the textual representation of column i: booleans as 1 or 0,
+// bytes as hex, and maps and slices as JSON. Null values are empty.
+func (r Row) Text(i int) (string, error) {
+	return FormatValue(r[i])
+}
+
+// AppendText appends the textual representation of column i, as described
+// for Text, to dst and returns the extended buffer.
+func (r Row) AppendText(dst []byte, i int) ([]byte, error) {
+	return AppendValue(dst, r[i])
+}
+
+// Equal reports whether r and other hold the same values.
+func (r Row) Equal(other Row) bool {
+	if len(r) != len(other) {
+		return false
+	}
+	for i := range r {
+		a, b := r[i], other[i]
+		if a == nil || b == nil {
+			if a != b {
+				return false
+			}
+			continue
+		}
+		if !a.Equal(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// ColumnsEqual reports whether columns i and j of r hold the same value.
+// Two null columns are equal.
+func (r Row) ColumnsEqual(i, j int) bool {
+	a, b := r[i], r[j]
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
+
+// SetNull clears column i.
+func (r Row) SetNull(i int) {
+	r[i] = nil
+}
+
+// SetString sets column i to a string.
+func (r Row) SetString(i int, s string) {
+	r[i] = mmdbtype.String(s)
+}
+
+// SetBool sets column i to a boolean.
+func (r Row) SetBool(i int, b bool) {
+	r[i] = mmdbtype.Bool(b)
+}
+
+// SetInt32 sets column i to a signed 32-bit integer, the only signed
+// integer type of MMDB files.
+func (r Row) SetInt32(i int, v int32) {
+	r[i] = mmdbtype.Int32(v)
+}
+
+// SetUint64 sets column i to an unsigned integer.
+func (r Row) SetUint64(i int, v uint64) {
+	r[i] = mmdbtype.Uint64(v)
+}
+
+// SetFloat64 sets column i to a double.
+func (r Row) SetFloat64(i int, f float64) {
+	r[i] = mmdbtype.Float64(f)
+}
+
+// MapFloats replaces every float in column i, including floats nested in
+// maps and slices, with f applied to it. Floats keep their precision, and
+// maps and slices are copied rather than modified, so rows sharing them are
+// not affected.
+func (r Row) MapFloats(i int, f func(float64) float64) {
+	r[i] = mapFloats(r[i], f)
+}
+
+func mapFloats(value mmdbtype.DataType, f func(float64) float64) mmdbtype.DataType {
+	switch v := value.(type) {
+	case mmdbtype.Float64:
+		return mmdbtype.Float64(f(float64(v)))
+	case mmdbtype.Float32:
+		return mmdbtype.Float32(f(float64(v)))
+	case mmdbtype.Map:
+		out := make(mmdbtype.Map, len(v))
+		for key, value := range v {
+			out[key] = mapFloats(value, f)
+		}
+		return out
+	case mmdbtype.Slice:
+		out := make(mmdbtype.Slice, len(v))
+		for i, value := range v {
+			out[i] = mapFloats(value, f)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// WithValidity returns a copy of r followed by the validity interval of a
+// time-sliced export: when the row became valid, and when it stopped being
+// valid or the zero time if it still is. Writers read the interval with
+// ValidFrom and ValidTo.
+func (r Row) WithValidity(from, to time.Time) Row {
+	out := make(Row, len(r), len(r)+2)
+	copy(out, r)
+	out = append(out, formatValidity(from), formatValidity(to))
+	return out
+}
+
+// ValidFrom returns the start of the validity interval following the first
+// columns values of r, formatted as RFC 3339 in UTC, or "" if r has none.
+func (r Row) ValidFrom(columns int) string {
+	if columns >= len(r) {
+		return ""
+	}
+	s, _ := r.String(columns)
+	return s
+}
+
+// ValidTo returns the end of the validity interval following the first
+// columns values of r, formatted as RFC 3339 in UTC, or "" if r has none or
+// the row is still valid.
+func (r Row) ValidTo(columns int) string {
+	if columns+1 >= len(r) {
+		return ""
+	}
+	s, _ := r.String(columns + 1)
+	return s
+}
+
+func formatValidity(t time.Time) mmdbtype.DataType {
+	if t.IsZero() {
+		return nil
+	}
+	return mmdbtype.String(t.UTC().Format(time.RFC3339))
+}
+
+// FormatValue returns the textual representation of an MMDB value as
+// described for Row.Text.
+func FormatValue(value mmdbtype.DataType) (string, error) {
+	if v, ok := value.(mmdbtype.String); ok {
+		return string(v), nil
+	}
+	b, err := AppendValue(nil, value)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// AppendValue appends the textual representation of an MMDB value, as
+// described for Row.Text, to dst and returns the extended buffer.
+func AppendValue(dst []byte, value mmdbtype.DataType) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return dst, nil
+	case mmdbtype.Bool:
+		if bool(v) {
+			return append(dst, '1'), nil
+		}
+		return append(dst, '0'), nil
+	case mmdbtype.String:
+		return append(dst, v...), nil
+	case mmdbtype.Int32:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case mmdbtype.Uint16:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case mmdbtype.Uint32:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case mmdbtype.Uint64:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case *mmdbtype.Uint128:
+		return (*big.Int)(v).Append(dst, 10), nil
+	case mmdbtype.Float32:
+		return strconv.AppendFloat(dst, float64(v), 'g', -1, 32), nil
+	case mmdbtype.Float64:
+		return strconv.AppendFloat(dst, float64(v), 'g', -1, 64), nil
+	case mmdbtype.Bytes:
+		return hex.AppendEncode(dst, v), nil
+	case mmdbtype.Map:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return dst, fmt.Errorf("marshaling map to JSON: %w", err)
+		}
+		return append(dst, b...), nil
+	case mmdbtype.Slice:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return dst, fmt.Errorf("marshaling slice to JSON: %w", err)
+		}
+		return append(dst, b...), nil
+	default:
+		// Fallback for any unexpected types
+		return fmt.Appendf(dst, "%v", v), nil
+	}
+}
+
+// plain converts an MMDB value to the Go value described for Row.Interface.
+func plain(value mmdbtype.DataType) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case mmdbtype.String:
+		return string(v)
+	case mmdbtype.Bool:
+		return bool(v)
+	case mmdbtype.Int32:
+		return int64(v)
+	case mmdbtype.Uint16:
+		return uint64(v)
+	case mmdbtype.Uint32:
+		return uint64(v)
+	case mmdbtype.Uint64:
+		return uint64(v)
+	case *mmdbtype.Uint128:
+		b := (*big.Int)(v)
+		if b.IsUint64() {
+			return b.Uint64()
+		}
+		return new(big.Int).Set(b)
+	case mmdbtype.Float32:
+		return float64(v)
+	case mmdbtype.Float64:
+		return float64(v)
+	case mmdbtype.Bytes:
+		return []byte(v)
+	case mmdbtype.Map:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[string(key)] = plain(value)
+		}
+		return m
+	case mmdbtype.Slice:
+		s := make([]any, len(v))
+		for i, value := range v {
+			s[i] = plain(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
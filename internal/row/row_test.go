@@ -0,0 +1,233 @@
+package row
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type prefixWriter struct {
+	prefixes []netip.Prefix
+	err      error
+}
+
+func (w *prefixWriter) WriteRow(prefix netip.Prefix, _ Row) error {
+	w.prefixes = append(w.prefixes, prefix)
+	return w.err
+}
+
+type rangeWriter struct {
+	prefixWriter
+
+	ranges [][2]netip.Addr
+}
+
+func (w *rangeWriter) WriteRange(start, end netip.Addr, _ Row) error {
+	w.ranges = append(w.ranges, [2]netip.Addr{start, end})
+	return nil
+}
+
+func TestWriteRange(t *testing.T) {
+	start := netip.MustParseAddr("10.0.0.1")
+	end := netip.MustParseAddr("10.0.0.3")
+
+	pw := &prefixWriter{}
+	require.NoError(t, WriteRange(pw, start, end, nil))
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.2/31"),
+	}, pw.prefixes)
+
+	rw := &rangeWriter{}
+	require.NoError(t, WriteRange(rw, start, end, nil))
+	assert.Equal(t, [][2]netip.Addr{{start, end}}, rw.ranges)
+	assert.Empty(t, rw.prefixes)
+
+	failing := &prefixWriter{err: errors.New("write failure")}
+	err := WriteRange(failing, start, end, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "10.0.0.1/32")
+	assert.Contains(t, err.Error(), "write failure")
+}
+
+func TestRow_Accessors(t *testing.T) {
+	big128, _ := new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+	r := Row{
+		nil,
+		mmdbtype.String("US"),
+		mmdbtype.Bool(true),
+		mmdbtype.Int32(-7),
+		mmdbtype.Uint64(1 << 63),
+		(*mmdbtype.Uint128)(big128),
+		mmdbtype.Float32(1.5),
+		mmdbtype.Bytes{0xaa},
+		mmdbtype.Map{"en": mmdbtype.String("Germany")},
+		mmdbtype.Slice{mmdbtype.Uint16(1)},
+	}
+
+	kinds := []Kind{
+		KindNull, KindString, KindBool, KindInt, KindUint,
+		KindUint, KindFloat, KindBytes, KindMap, KindSlice,
+	}
+	for i, kind := range kinds {
+		assert.Equal(t, kind, r.Kind(i), "column %d", i)
+	}
+
+	assert.True(t, r.IsNull(0))
+	assert.False(t, r.IsEmpty())
+	assert.True(t, Row{nil, nil}.IsEmpty())
+
+	s, ok := r.String(1)
+	assert.True(t, ok)
+	assert.Equal(t, "US", s)
+	_, ok = r.String(2)
+	assert.False(t, ok)
+
+	b, ok := r.Bool(2)
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	i, ok := r.Int64(3)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-7), i)
+	_, ok = r.Int64(4)
+	assert.False(t, ok, "uint64 above MaxInt64")
+
+	_, ok = r.Uint64(3)
+	assert.False(t, ok, "negative int32")
+	u, ok := r.Uint64(4)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1<<63), u)
+	_, ok = r.Uint64(5)
+	assert.False(t, ok, "uint128 above MaxUint64")
+
+	n, ok := r.BigInt(5)
+	assert.True(t, ok)
+	assert.Equal(t, big128.String(), n.String())
+	n.SetInt64(0)
+	assert.Equal(t, "340282366920938463463374607431768211455", big128.String())
+
+	f, ok := r.Float64(6)
+	assert.True(t, ok)
+	assert.InDelta(t, 1.5, f, 0)
+	_, ok = r.Float64(3)
+	assert.False(t, ok)
+
+	bs, ok := r.Bytes(7)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0xaa}, bs)
+
+	assert.Nil(t, r.Interface(0))
+	assert.Equal(t, int64(-7), r.Interface(3))
+	assert.Equal(t, uint64(1<<63), r.Interface(4))
+	assert.Equal(t, map[string]any{"en": "Germany"}, r.Interface(8))
+	assert.Equal(t, []any{uint64(1)}, r.Interface(9))
+}
+
+func TestRow_Equal(t *testing.T) {
+	a := Row{mmdbtype.String("US"), nil}
+	assert.True(t, a.Equal(Row{mmdbtype.String("US"), nil}))
+	assert.False(t, a.Equal(Row{mmdbtype.String("CA"), nil}))
+	assert.False(t, a.Equal(Row{nil, nil}))
+	assert.False(t, a.Equal(Row{mmdbtype.String("US")}))
+}
+
+func TestRow_ColumnsEqual(t *testing.T) {
+	r := Row{mmdbtype.String("US"), mmdbtype.String("US"), mmdbtype.String("CA"), nil, nil}
+	assert.True(t, r.ColumnsEqual(0, 1))
+	assert.False(t, r.ColumnsEqual(0, 2))
+	assert.False(t, r.ColumnsEqual(0, 3))
+	assert.True(t, r.ColumnsEqual(3, 4))
+}
+
+func TestRow_Setters(t *testing.T) {
+	r := make(Row, 6)
+	r.SetString(0, "US")
+	r.SetBool(1, true)
+	r.SetInt32(2, -7)
+	r.SetUint64(3, 1<<63)
+	r.SetFloat64(4, 1.5)
+	r.SetString(5, "x")
+	r.SetNull(5)
+
+	assert.Equal(t, []any{"US", true, int64(-7), uint64(1 << 63), 1.5, nil}, []any{
+		r.Interface(0), r.Interface(1), r.Interface(2),
+		r.Interface(3), r.Interface(4), r.Interface(5),
+	})
+}
+
+func TestRow_MapFloats(t *testing.T) {
+	nested := mmdbtype.Map{
+		"latitude": mmdbtype.Float64(1.25),
+		"radius":   mmdbtype.Float32(2.5),
+		"list":     mmdbtype.Slice{mmdbtype.Float64(3.5), mmdbtype.String("a")},
+	}
+	r := Row{nested, mmdbtype.String("b")}
+	double := func(f float64) float64 { return f * 2 }
+	r.MapFloats(0, double)
+	r.MapFloats(1, double)
+
+	assert.Equal(t, mmdbtype.Map{
+		"latitude": mmdbtype.Float64(2.5),
+		"radius":   mmdbtype.Float32(5),
+		"list":     mmdbtype.Slice{mmdbtype.Float64(7), mmdbtype.String("a")},
+	}, r[0])
+	assert.Equal(t, mmdbtype.String("b"), r[1])
+	assert.Equal(t, mmdbtype.Float64(1.25), nested["latitude"], "MapFloats must not change shared maps")
+}
+
+func TestRow_WithValidity(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	r := Row{mmdbtype.String("US")}
+	closed := r.WithValidity(from, to)
+	assert.Equal(t, Row{mmdbtype.String("US")}, r, "WithValidity must not change r")
+	assert.Equal(t, "2025-01-01T00:00:00Z", closed.ValidFrom(1))
+	assert.Equal(t, "2025-01-31T23:00:00Z", closed.ValidTo(1))
+
+	open := r.WithValidity(from, time.Time{})
+	assert.Equal(t, "2025-01-01T00:00:00Z", open.ValidFrom(1))
+	assert.Empty(t, open.ValidTo(1))
+
+	assert.Empty(t, r.ValidFrom(1))
+	assert.Empty(t, r.ValidTo(1))
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    mmdbtype.DataType
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"string", mmdbtype.String("hello"), "hello"},
+		{"int32", mmdbtype.Int32(42), "42"},
+		{"uint16", mmdbtype.Uint16(42), "42"},
+		{"uint32", mmdbtype.Uint32(42), "42"},
+		{"uint64", mmdbtype.Uint64(42), "42"},
+		{"float64", mmdbtype.Float64(3.14), "3.14"},
+		{"bool true", mmdbtype.Bool(true), "1"},
+		{"bool false", mmdbtype.Bool(false), "0"},
+		{"binary", mmdbtype.Bytes([]byte{0xaa, 0xbb}), "aabb"},
+		{"map", mmdbtype.Map{"en": mmdbtype.String("Germany")}, `{"en":"Germany"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatValue(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+
+			text, err := Row{tt.value}.Text(0)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, text)
+		})
+	}
+}
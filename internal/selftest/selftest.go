@@ -0,0 +1,1120 @@
+// Package selftest converts the MaxMind-DB test databases through every
+// output format and checks what each output holds, so that users can verify
+// a build on their platform.
+//
+// The values expected for a few addresses are those of the merged view of
+// the source databases, so each output is checked for the values and, where
+// the format keeps them, the types of the source. A handful of facts that
+// hold in every release of the test databases guard the merged view itself.
+package selftest
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/pelletier/go-toml/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+	_ "modernc.org/sqlite" // Registers the "sqlite" driver
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/export"
+	"github.com/maxmind/mmdbconvert/internal/merger"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// Test databases of the MaxMind-DB repository read by the self-test.
+const (
+	CityDatabase      = "GeoIP2-City-Test.mmdb"
+	AnonymousDatabase = "GeoIP2-Anonymous-IP-Test.mmdb"
+)
+
+// Formats are the output formats the self-test converts to, in order.
+var Formats = []string{"csv", "parquet", "mmdb", "ptr", "vcl", "envoy", "sqlite", "clickhouse", "xlsx", "protobuf", "msgpack"}
+
+// hintedFormats are the formats that take the type hints of the columns, and
+// whose outputs keep the types of the values.
+var hintedFormats = []string{"parquet", "clickhouse", "protobuf", "msgpack"}
+
+// cidrFormats are the table formats written with a CIDR network column, as
+// CSV is, so that their outputs have the rows of the CSV output.
+var cidrFormats = []string{"parquet", "clickhouse", "xlsx", "protobuf", "msgpack"}
+
+// Convert runs the conversion configured by the file at configPath, as the
+// main command does.
+type Convert func(configPath string) error
+
+// Result is the outcome of converting the test databases to one format.
+type Result struct {
+	Format string
+	Rows   int   // Rows, networks, or entries read back from the output
+	Err    error // First failed check, or nil
+}
+
+// column is a data column of the self-test configuration.
+type column struct {
+	name     string
+	database string
+	path     []any
+	typ      string // Parquet type hint
+}
+
+var columns = []column{
+	{"country_code", "city", []any{"country", "iso_code"}, "string"},
+	{"city_geoname_id", "city", []any{"city", "geoname_id"}, "int64"},
+	{"latitude", "city", []any{"location", "latitude"}, "float64"},
+	{"is_anonymous", "anon", []any{"is_anonymous"}, "bool"},
+}
+
+// fact is a value that every release of the test databases has.
+type fact struct {
+	addr   string
+	column string
+	value  mmdbtype.DataType
+}
+
+var facts = []fact{
+	{"81.2.69.142", "country_code", mmdbtype.String("GB")},
+	{"89.160.20.112", "country_code", mmdbtype.String("SE")},
+	{"216.160.83.56", "country_code", mmdbtype.String("US")},
+	{"81.2.69.1", "is_anonymous", mmdbtype.Bool(true)},
+}
+
+// probes are the addresses whose values are checked in every output. Some
+// are in both databases, some in only one.
+var probes = []netip.Addr{
+	netip.MustParseAddr("81.2.69.142"),
+	netip.MustParseAddr("89.160.20.112"),
+	netip.MustParseAddr("216.160.83.56"),
+	netip.MustParseAddr("2.125.160.216"),
+	netip.MustParseAddr("81.2.69.1"),
+	netip.MustParseAddr("1.2.0.1"),
+}
+
+// ptrDomain is the domain of the names of the PTR records.
+const ptrDomain = "selftest.invalid."
+
+// Run converts the test databases in testDataDir to every format, writing
+// configurations and outputs to workDir, and checks each output. It returns
+// an error if the test databases cannot be read; failed checks are reported
+// in the results.
+func Run(testDataDir, workDir string, convert Convert) ([]Result, error) {
+	for _, name := range []string{CityDatabase, AnonymousDatabase} {
+		if _, err := os.Stat(filepath.Join(testDataDir, name)); err != nil {
+			return nil, fmt.Errorf("test database: %w", err)
+		}
+	}
+
+	expected, err := expectedValues(testDataDir, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(Formats))
+	rows := map[string]int{}
+	for _, format := range Formats {
+		result := Result{Format: format}
+		result.Rows, result.Err = runFormat(format, testDataDir, workDir, convert, expected)
+		rows[format] = result.Rows
+		if result.Err == nil && slices.Contains(cidrFormats, format) && rows["csv"] != result.Rows {
+			result.Err = fmt.Errorf("%d rows, but CSV output has %d", result.Rows, rows["csv"])
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// expectedValues looks up the probes in the merged view of the test
+// databases and checks the facts against it.
+func expectedValues(testDataDir, workDir string) (map[netip.Addr]row.Row, error) {
+	configPath, err := writeConfig("csv", testDataDir, workDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading self-test configuration: %w", err)
+	}
+	databases := make(map[string]config.Database, len(cfg.Databases))
+	for _, db := range cfg.Databases {
+		databases[db.Name] = db
+	}
+	readers, err := mmdb.OpenDatabases(databases)
+	if err != nil {
+		return nil, fmt.Errorf("opening test databases: %w", err)
+	}
+	defer readers.Close()
+
+	m, err := merger.NewMerger(readers, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[netip.Addr]row.Row, len(probes))
+	for _, addr := range probes {
+		_, values, err := m.Lookup(addr)
+		if err != nil {
+			return nil, err
+		}
+		expected[addr] = values
+	}
+
+	for _, f := range facts {
+		value := expected[netip.MustParseAddr(f.addr)][columnIndex(f.column)]
+		if !reflect.DeepEqual(value, f.value) {
+			return nil, fmt.Errorf(
+				"test databases have %s = %v for %s, expected %v; are they the MaxMind-DB test data?",
+				f.column,
+				value,
+				f.addr,
+				f.value,
+			)
+		}
+	}
+	return expected, nil
+}
+
+// runFormat converts the test databases to format and checks the output,
+// returning the number of rows read back.
+func runFormat(
+	format, testDataDir, workDir string,
+	convert Convert,
+	expected map[netip.Addr]row.Row,
+) (int, error) {
+	configPath, err := writeConfig(format, testDataDir, workDir)
+	if err != nil {
+		return 0, err
+	}
+	if err := convert(configPath); err != nil {
+		return 0, fmt.Errorf("converting: %w", err)
+	}
+
+	out := outputPath(format, workDir)
+	switch format {
+	case "csv", "parquet":
+		r, err := export.Open(out, export.Options{})
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+		return checkTable(r, slices.Contains(hintedFormats, format), expected)
+	case "sqlite":
+		r, err := readSQLite(out)
+		if err != nil {
+			return 0, err
+		}
+		// Booleans are integers in SQLite
+		return checkTable(r, false, expected)
+	case "clickhouse":
+		r, err := readClickHouseTSV(out)
+		if err != nil {
+			return 0, err
+		}
+		return checkTable(r, true, expected)
+	case "xlsx":
+		r, err := readXLSX(out)
+		if err != nil {
+			return 0, err
+		}
+		// Cells are compared as text, as integers and floats are both
+		// numbers
+		return checkTable(r, false, expected)
+	case "mmdb":
+		return checkMMDB(out, expected)
+	case "ptr":
+		return checkPTR(out, expected)
+	case "vcl":
+		return checkNetworkList(out, parseVCL, expected)
+	case "envoy":
+		return checkNetworkList(out, parseEnvoyJSON, expected)
+	case "protobuf":
+		r, err := readProtobuf(out)
+		if err != nil {
+			return 0, err
+		}
+		return checkTable(r, true, expected)
+	case "msgpack":
+		r, err := readMessagePack(out)
+		if err != nil {
+			return 0, err
+		}
+		return checkTable(r, true, expected)
+	default:
+		return 0, fmt.Errorf("unknown format '%s'", format)
+	}
+}
+
+// outputPath returns the path of the output of format in workDir.
+func outputPath(format, workDir string) string {
+	return filepath.Join(workDir, "selftest."+format)
+}
+
+// writeConfig writes the configuration converting the test databases to
+// format and returns its path.
+func writeConfig(format, testDataDir, workDir string) (string, error) {
+	cols := make([]map[string]any, 0, len(columns))
+	for _, col := range columns {
+		c := map[string]any{"name": col.name, "database": col.database, "path": col.path}
+		if slices.Contains(hintedFormats, format) {
+			c["type"] = col.typ
+		}
+		cols = append(cols, c)
+	}
+	cfg := map[string]any{
+		"output": map[string]any{
+			"format": format,
+			"file":   outputPath(format, workDir),
+		},
+		"databases": []map[string]any{
+			{"name": "city", "path": filepath.Join(testDataDir, CityDatabase)},
+			{"name": "anon", "path": filepath.Join(testDataDir, AnonymousDatabase)},
+		},
+		"columns": cols,
+	}
+	if slices.Contains(cidrFormats, format) {
+		cfg["network"] = map[string]any{
+			"columns": []map[string]any{{"name": "network", "type": "cidr"}},
+		}
+	}
+	output := cfg["output"].(map[string]any)
+	switch format {
+	case "mmdb":
+		output["mmdb"] = map[string]any{"database_type": "mmdbconvert-Selftest"}
+	case "ptr":
+		output["ptr"] = map[string]any{"template": "{country_code}." + ptrDomain}
+	case "vcl":
+		output["vcl"] = map[string]any{"acl": "selftest"}
+		output["filter"] = map[string]any{"is_anonymous": true}
+	case "envoy":
+		output["envoy"] = map[string]any{"encoding": "json"}
+		output["filter"] = map[string]any{"is_anonymous": true}
+	case "clickhouse":
+		// RowBinary needs the table schema to be read back
+		output["clickhouse"] = map[string]any{"encoding": "tsv"}
+	}
+
+	data, err := toml.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("encoding self-test configuration: %w", err)
+	}
+	path := filepath.Join(workDir, format+".toml")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// columnIndex returns the index of the named column in the rows.
+func columnIndex(name string) int {
+	for i, col := range columns {
+		if col.name == name {
+			return i
+		}
+	}
+	panic("unknown self-test column " + name)
+}
+
+// checkTable checks the rows of a table output: the row of each probe must
+// have the expected values, and the expected kinds too if typed.
+func checkTable(r row.Reader, typed bool, expected map[netip.Addr]row.Row) (int, error) {
+	indexes := make([]int, len(columns))
+	for i, col := range columns {
+		indexes[i] = -1
+		for j, name := range r.Columns() {
+			if name == col.name {
+				indexes[i] = j
+			}
+		}
+		if indexes[i] < 0 {
+			return 0, fmt.Errorf("column '%s' is missing", col.name)
+		}
+	}
+
+	found := map[netip.Addr]row.Row{}
+	n := 0
+	for {
+		start, end, values, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+		for _, addr := range probes {
+			if start.Compare(addr) <= 0 && addr.Compare(end) <= 0 {
+				found[addr] = slices.Clone(values)
+			}
+		}
+	}
+	if n == 0 {
+		return 0, errors.New("no rows")
+	}
+
+	for _, addr := range probes {
+		values := found[addr]
+		for i, col := range columns {
+			want := expected[addr]
+			var got row.Row
+			if values != nil {
+				got = row.Row{values[indexes[i]]}
+			}
+			if err := compareValue(addr, col.name, want, i, got, typed); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// compareValue compares the value of column i of want with the single value
+// of got, which is nil if the output has no row. Values are compared as
+// text, and their kinds too if typed.
+func compareValue(addr netip.Addr, name string, want row.Row, i int, got row.Row, typed bool) error {
+	wantText, err := want.Text(i)
+	if err != nil {
+		return err
+	}
+	gotText := ""
+	if got != nil {
+		if gotText, err = got.Text(0); err != nil {
+			return err
+		}
+	}
+	if gotText != wantText {
+		return fmt.Errorf("%s of %s is %q, expected %q", name, addr, gotText, wantText)
+	}
+	if typed && got != nil && got.Kind(0) != want.Kind(i) {
+		return fmt.Errorf("%s of %s is a %s, expected a %s", name, addr, got.Kind(0), want.Kind(i))
+	}
+	return nil
+}
+
+// tableRows is a row.Reader of the rows of an output read into memory, for
+// formats the export package does not read.
+type tableRows struct {
+	columns []string
+	rows    []tableRow
+}
+
+type tableRow struct {
+	start, end netip.Addr
+	values     row.Row
+}
+
+func (t *tableRows) Columns() []string {
+	return t.columns
+}
+
+func (t *tableRows) Read() (start, end netip.Addr, r row.Row, err error) {
+	if len(t.rows) == 0 {
+		return netip.Addr{}, netip.Addr{}, nil, io.EOF
+	}
+	next := t.rows[0]
+	t.rows = t.rows[1:]
+	return next.start, next.end, next.values, nil
+}
+
+func (t *tableRows) Close() error {
+	return nil
+}
+
+// setValue sets column i of r to a decoded value: nil, a string, a bool, an
+// int64, a float64, or bytes. Non-negative integers become uint64, as the
+// export package reads them.
+func setValue(r row.Row, i int, value any) error {
+	switch v := value.(type) {
+	case nil:
+		r.SetNull(i)
+	case string:
+		r.SetString(i, v)
+	case []byte:
+		r.SetString(i, string(v))
+	case bool:
+		r.SetBool(i, v)
+	case int64:
+		if v >= 0 {
+			r.SetUint64(i, uint64(v))
+		} else if v >= math.MinInt32 {
+			r.SetInt32(i, int32(v))
+		} else {
+			return fmt.Errorf("integer %d out of the range of MMDB types", v)
+		}
+	case float64:
+		r.SetFloat64(i, v)
+	default:
+		return fmt.Errorf("unsupported value %T", value)
+	}
+	return nil
+}
+
+// readSQLite reads the table of a SQLite output. Its first columns are the
+// start_int and end_int network columns SQLite output requires.
+func readSQLite(path string) (*tableRows, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT * FROM "networks"`)
+	if err != nil {
+		return nil, fmt.Errorf("reading table: %w", err)
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) < 2 {
+		return nil, errors.New("network columns are missing")
+	}
+
+	table := &tableRows{columns: names[2:]}
+	values := make([]any, len(names))
+	dest := make([]any, len(names))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		start, err := sqliteAddr(values[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := sqliteAddr(values[1])
+		if err != nil {
+			return nil, err
+		}
+		r := make(row.Row, len(table.columns))
+		for i, value := range values[2:] {
+			if err := setValue(r, i, value); err != nil {
+				return nil, fmt.Errorf("column '%s': %w", table.columns[i], err)
+			}
+		}
+		table.rows = append(table.rows, tableRow{start, end, r})
+	}
+	return table, rows.Err()
+}
+
+// sqliteAddr returns the address of an integer network column of a SQLite
+// output: an integer for IPv4 and a 16-byte blob for IPv6.
+func sqliteAddr(value any) (netip.Addr, error) {
+	switch v := value.(type) {
+	case int64:
+		if v >= 0 && v <= math.MaxUint32 {
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], uint32(v))
+			return netip.AddrFrom4(b), nil
+		}
+	case []byte:
+		if len(v) == 16 {
+			return netip.AddrFrom16([16]byte(v)), nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("invalid network bound %v", value)
+}
+
+// readClickHouseTSV reads a TabSeparated ClickHouse output, whose rows have
+// the CIDR network column and the data columns, with their type hints.
+func readClickHouseTSV(path string) (*tableRows, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the self-test directory
+	if err != nil {
+		return nil, err
+	}
+	table := &tableRows{}
+	for _, col := range columns {
+		table.columns = append(table.columns, col.name)
+	}
+	for line := range strings.Lines(string(data)) {
+		fields := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+		if len(fields) != 1+len(columns) {
+			return nil, fmt.Errorf("row %q has %d fields, expected %d", line, len(fields), 1+len(columns))
+		}
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		start, end := network.PrefixRange(prefix)
+		r := make(row.Row, len(columns))
+		for i, col := range columns {
+			value, err := parseClickHouseField(fields[1+i], col.typ)
+			if err != nil {
+				return nil, fmt.Errorf("column '%s': %w", col.name, err)
+			}
+			if err := setValue(r, i, value); err != nil {
+				return nil, fmt.Errorf("column '%s': %w", col.name, err)
+			}
+		}
+		table.rows = append(table.rows, tableRow{start, end, r})
+	}
+	return table, nil
+}
+
+// clickHouseUnescaper undoes the escapes of TabSeparated strings.
+var clickHouseUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\t`, "\t",
+	`\n`, "\n",
+	`\r`, "\r",
+	`\0`, "\x00",
+)
+
+// parseClickHouseField parses a TabSeparated field of a column with the type
+// hint typ.
+func parseClickHouseField(field, typ string) (any, error) {
+	if field == `\N` {
+		return nil, nil
+	}
+	switch typ {
+	case "int64":
+		return strconv.ParseInt(field, 10, 64)
+	case "float64":
+		return strconv.ParseFloat(field, 64)
+	case "bool":
+		return strconv.ParseBool(field)
+	default:
+		return clickHouseUnescaper.Replace(field), nil
+	}
+}
+
+// readXLSX reads the worksheet of an XLSX output, whose first row has the
+// column names and whose first column is the CIDR network column. Cells
+// are read as text.
+func readXLSX(path string) (*tableRows, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	part, err := z.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref   string `xml:"r,attr"`
+				Value string `xml:"v"`
+				Text  string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.NewDecoder(part).Decode(&sheet); err != nil {
+		return nil, fmt.Errorf("parsing worksheet: %w", err)
+	}
+	if len(sheet.Rows) == 0 || len(sheet.Rows[0].Cells) == 0 {
+		return nil, errors.New("header row is missing")
+	}
+
+	table := &tableRows{}
+	for _, cell := range sheet.Rows[0].Cells[1:] {
+		table.columns = append(table.columns, cell.Text)
+	}
+	for _, sheetRow := range sheet.Rows[1:] {
+		r := make(row.Row, len(table.columns))
+		var prefix netip.Prefix
+		for _, cell := range sheetRow.Cells {
+			column := xlsxColumn(cell.Ref)
+			text := cell.Value + cell.Text
+			switch {
+			case column == 0:
+				if prefix, err = netip.ParsePrefix(text); err != nil {
+					return nil, err
+				}
+			case column <= len(table.columns):
+				r.SetString(column-1, text)
+			default:
+				return nil, fmt.Errorf("cell %s is beyond the header", cell.Ref)
+			}
+		}
+		if !prefix.IsValid() {
+			return nil, errors.New("network cell is missing")
+		}
+		start, end := network.PrefixRange(prefix)
+		table.rows = append(table.rows, tableRow{start, end, r})
+	}
+	return table, nil
+}
+
+// xlsxColumn returns the zero-based column of a cell reference such as B2.
+func xlsxColumn(ref string) int {
+	column := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		column = column*26 + int(c-'A') + 1
+	}
+	return column - 1
+}
+
+// readProtobuf reads a length-delimited protobuf output, whose messages
+// have the CIDR network column as field 1 and the data columns, with their
+// type hints, as the following fields.
+func readProtobuf(path string) (*tableRows, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the self-test directory
+	if err != nil {
+		return nil, err
+	}
+	table := &tableRows{}
+	for _, col := range columns {
+		table.columns = append(table.columns, col.name)
+	}
+	for len(data) > 0 {
+		msg, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, fmt.Errorf("reading message length: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+		prefix, r, err := parseProtobufMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+		start, end := network.PrefixRange(prefix)
+		table.rows = append(table.rows, tableRow{start, end, r})
+	}
+	return table, nil
+}
+
+// parseProtobufMessage returns the network and data columns of a message.
+// Fields left out are null.
+func parseProtobufMessage(msg []byte) (netip.Prefix, row.Row, error) {
+	var prefix netip.Prefix
+	r := make(row.Row, len(columns))
+	for len(msg) > 0 {
+		number, wireType, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return prefix, nil, protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		column := int(number) - 2 // Field 1 is the network
+		if column < -1 || column >= len(columns) {
+			return prefix, nil, fmt.Errorf("unexpected field %d", number)
+		}
+
+		var value any
+		switch wireType {
+		case protowire.BytesType:
+			var b []byte
+			b, n = protowire.ConsumeBytes(msg)
+			value = string(b)
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(msg)
+			if column >= 0 && columns[column].typ == "bool" {
+				value = protowire.DecodeBool(v)
+			} else {
+				value = int64(v)
+			}
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(msg)
+			value = math.Float64frombits(v)
+		default:
+			return prefix, nil, fmt.Errorf("field %d has unexpected wire type %d", number, wireType)
+		}
+		if n < 0 {
+			return prefix, nil, protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		if column < 0 {
+			text, _ := value.(string)
+			var err error
+			if prefix, err = netip.ParsePrefix(text); err != nil {
+				return prefix, nil, err
+			}
+			continue
+		}
+		if err := setValue(r, column, value); err != nil {
+			return prefix, nil, fmt.Errorf("column '%s': %w", columns[column].name, err)
+		}
+	}
+	if !prefix.IsValid() {
+		return prefix, nil, errors.New("network field is missing")
+	}
+	return prefix, r, nil
+}
+
+// readMessagePack reads a MessagePack output, a map per row from the column
+// names, including the CIDR network column, to the values.
+func readMessagePack(path string) (*tableRows, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the self-test directory
+	if err != nil {
+		return nil, err
+	}
+	table := &tableRows{}
+	for _, col := range columns {
+		table.columns = append(table.columns, col.name)
+	}
+	d := &msgpackDecoder{data: data}
+	for len(d.data) > 0 {
+		n, err := d.mapLen()
+		if err != nil {
+			return nil, err
+		}
+		var prefix netip.Prefix
+		r := make(row.Row, len(columns))
+		for range n {
+			key, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("map key %v is not a string", key)
+			}
+			value, err := d.value()
+			if err != nil {
+				return nil, fmt.Errorf("column '%s': %w", name, err)
+			}
+			if name == "network" {
+				text, _ := value.(string)
+				if prefix, err = netip.ParsePrefix(text); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			i := slices.IndexFunc(columns, func(col column) bool { return col.name == name })
+			if i < 0 {
+				return nil, fmt.Errorf("unexpected column '%s'", name)
+			}
+			if err := setValue(r, i, value); err != nil {
+				return nil, fmt.Errorf("column '%s': %w", name, err)
+			}
+		}
+		if !prefix.IsValid() {
+			return nil, errors.New("network column is missing")
+		}
+		start, end := network.PrefixRange(prefix)
+		table.rows = append(table.rows, tableRow{start, end, r})
+	}
+	return table, nil
+}
+
+// msgpackDecoder decodes the MessagePack values of MessagePack output:
+// maps, strings, binary data, nil, booleans, integers, and floats.
+type msgpackDecoder struct {
+	data []byte
+}
+
+// take returns the next n bytes.
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n > len(d.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+// uint returns the next size bytes as a big-endian unsigned integer.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.take(size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// mapLen returns the number of entries of the next value, a map.
+func (d *msgpackDecoder) mapLen() (int, error) {
+	b, err := d.take(1)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch c := b[0]; {
+	case c&0xf0 == 0x80:
+		n = uint64(c & 0x0f)
+	case c == 0xde:
+		n, err = d.uint(2)
+	case c == 0xdf:
+		n, err = d.uint(4)
+	default:
+		return 0, fmt.Errorf("expected a map, got type 0x%02x", c)
+	}
+	return int(n), err
+}
+
+// value returns the next value, which is not a map: nil, a string, []byte,
+// a bool, an int64, or a float64.
+func (d *msgpackDecoder) value() (any, error) {
+	b, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.take(int(n))
+		return slices.Clone(b), err
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.uint(1 << (c - 0xcc))
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("integer %d out of the range of MMDB types", v)
+		}
+		return int64(v), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := d.uint(size)
+		// Sign-extend from size bytes
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	default:
+		return nil, fmt.Errorf("unsupported type 0x%02x", c)
+	}
+}
+
+// str returns the next n bytes as a string.
+func (d *msgpackDecoder) str(n int) (string, error) {
+	b, err := d.take(n)
+	return string(b), err
+}
+
+// checkMMDB checks an MMDB output: the record of each probe must hold the
+// expected values with their types.
+func checkMMDB(path string, expected map[netip.Addr]row.Row) (int, error) {
+	reader, err := mmdb.Open(config.Database{Path: path})
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	n := 0
+	for result := range reader.Networks() {
+		if err := result.Err(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, errors.New("no networks")
+	}
+
+	for _, addr := range probes {
+		result := reader.Lookup(addr)
+		if err := result.Err(); err != nil {
+			return n, err
+		}
+		var record mmdbtype.Map
+		if result.Found() {
+			unmarshaler := mmdbtype.NewUnmarshaler()
+			if err := result.Decode(unmarshaler); err != nil {
+				return n, fmt.Errorf("decoding record of %s: %w", addr, err)
+			}
+			var ok bool
+			if record, ok = unmarshaler.Result().(mmdbtype.Map); !ok {
+				return n, fmt.Errorf("record of %s is not a map", addr)
+			}
+		}
+		for i, col := range columns {
+			got, want := record[mmdbtype.String(col.name)], expected[addr][i]
+			if !reflect.DeepEqual(got, want) {
+				return n, fmt.Errorf("%s of %s is %#v, expected %#v", col.name, addr, got, want)
+			}
+		}
+	}
+	return n, nil
+}
+
+// checkPTR checks a reverse DNS zone: each IPv4 probe with a country must
+// have a record, possibly a wildcard, naming it after its country.
+func checkPTR(path string, expected map[netip.Addr]row.Row) (int, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the self-test directory
+	if err != nil {
+		return 0, err
+	}
+	targets := map[string]string{}
+	n := 0
+	for line := range strings.Lines(string(data)) {
+		fields := strings.Fields(line)
+		if len(fields) != 5 || strings.HasPrefix(fields[0], ";") || fields[3] != "PTR" {
+			continue
+		}
+		targets[fields[0]] = fields[4]
+		n++
+	}
+	if n == 0 {
+		return 0, errors.New("no PTR records")
+	}
+
+	country := columnIndex("country_code")
+	for _, addr := range probes {
+		want, err := expected[addr].Text(country)
+		if err != nil {
+			return n, err
+		}
+		got := ""
+		for _, name := range reverseNames(addr) {
+			if target, ok := targets[name]; ok {
+				got = target
+				break
+			}
+		}
+		if want == "" {
+			if got != "" {
+				return n, fmt.Errorf("%s has a PTR record but no country", addr)
+			}
+			continue
+		}
+		if wantTarget := strings.ToLower(want) + "." + ptrDomain; got != wantTarget {
+			return n, fmt.Errorf("PTR record of %s is %q, expected %q", addr, got, wantTarget)
+		}
+	}
+	return n, nil
+}
+
+// reverseNames returns the names of the in-addr.arpa records that can cover
+// an IPv4 address, from the most specific.
+func reverseNames(addr netip.Addr) []string {
+	b := addr.As4()
+	octets := []string{
+		strconv.Itoa(int(b[3])),
+		strconv.Itoa(int(b[2])),
+		strconv.Itoa(int(b[1])),
+		strconv.Itoa(int(b[0])),
+	}
+	names := make([]string, 0, len(octets))
+	for i := range octets {
+		labels := octets[i:]
+		if i > 0 {
+			labels = append([]string{"*"}, labels...)
+		}
+		names = append(names, strings.Join(labels, ".")+".in-addr.arpa.")
+	}
+	return names
+}
+
+// checkNetworkList checks a list of the anonymous networks: exactly the
+// probes expected to be anonymous must be covered by a listed network.
+func checkNetworkList(
+	path string,
+	parse func([]byte) ([]netip.Prefix, error),
+	expected map[netip.Addr]row.Row,
+) (int, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the self-test directory
+	if err != nil {
+		return 0, err
+	}
+	prefixes, err := parse(data)
+	if err != nil {
+		return 0, err
+	}
+	if len(prefixes) == 0 {
+		return 0, errors.New("no networks")
+	}
+
+	anonymous := columnIndex("is_anonymous")
+	for _, addr := range probes {
+		want, _ := expected[addr].Bool(anonymous)
+		got := false
+		for _, prefix := range prefixes {
+			if prefix.Contains(addr) {
+				got = true
+				break
+			}
+		}
+		if got != want {
+			return len(prefixes), fmt.Errorf("%s listed: %t, expected %t", addr, got, want)
+		}
+	}
+	return len(prefixes), nil
+}
+
+var vclEntry = regexp.MustCompile(`^\s*"([^"]+)"/(\d+);`)
+
+// parseVCL returns the networks of a VCL ACL.
+func parseVCL(data []byte) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for line := range strings.Lines(string(data)) {
+		m := vclEntry.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(m[1] + "/" + m[2])
+		if err != nil {
+			return nil, fmt.Errorf("parsing ACL entry %q: %w", strings.TrimSpace(line), err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// parseEnvoyJSON returns the networks of a JSON list of Envoy CidrRanges.
+func parseEnvoyJSON(data []byte) ([]netip.Prefix, error) {
+	var ranges []struct {
+		AddressPrefix string `json:"address_prefix"`
+		PrefixLen     int    `json:"prefix_len"`
+	}
+	if err := json.Unmarshal(data, &ranges); err != nil {
+		return nil, fmt.Errorf("parsing Envoy JSON: %w", err)
+	}
+	prefixes := make([]netip.Prefix, 0, len(ranges))
+	for _, r := range ranges {
+		addr, err := netip.ParseAddr(r.AddressPrefix)
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := addr.Prefix(r.PrefixLen)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
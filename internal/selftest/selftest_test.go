@@ -0,0 +1,48 @@
+package selftest
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseNames(t *testing.T) {
+	assert.Equal(t, []string{
+		"142.69.2.81.in-addr.arpa.",
+		"*.69.2.81.in-addr.arpa.",
+		"*.2.81.in-addr.arpa.",
+		"*.81.in-addr.arpa.",
+	}, reverseNames(netip.MustParseAddr("81.2.69.142")))
+}
+
+func TestParseVCL(t *testing.T) {
+	prefixes, err := parseVCL([]byte(`acl selftest {
+  "81.2.69.0"/24;
+  "2001:db8::"/32;
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("81.2.69.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, prefixes)
+
+	_, err = parseVCL([]byte(`  "81.2.69.0"/33;`))
+	require.Error(t, err)
+}
+
+func TestParseEnvoyJSON(t *testing.T) {
+	prefixes, err := parseEnvoyJSON([]byte(
+		`[{"address_prefix":"81.2.69.0","prefix_len":24},{"address_prefix":"2001:db8::","prefix_len":32}]`,
+	))
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("81.2.69.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, prefixes)
+
+	_, err = parseEnvoyJSON([]byte(`{}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,144 @@
+// Package telemetry traces the stages of a conversion with OpenTelemetry.
+// Spans are exported over OTLP/HTTP when an endpoint is configured with the
+// standard OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// environment variables. Otherwise tracing is a no-op.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+const tracerName = "github.com/maxmind/mmdbconvert"
+
+// Attribute keys recorded on spans.
+const (
+	AttrOutput    = attribute.Key("mmdbconvert.output")
+	AttrRows      = attribute.Key("mmdbconvert.rows")
+	AttrBytes     = attribute.Key("mmdbconvert.bytes")
+	AttrDatabases = attribute.Key("mmdbconvert.databases")
+	AttrColumns   = attribute.Key("mmdbconvert.columns")
+	AttrFormat    = attribute.Key("mmdbconvert.format")
+)
+
+// Enabled reports whether an OTLP endpoint is configured.
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider when an OTLP endpoint is
+// configured. The returned function exports any pending spans and must be
+// called before exiting.
+func Setup(ctx context.Context, version string) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	// Attributes from OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME take
+	// precedence over the defaults.
+	res, err := resource.New(
+		ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "mmdbconvert"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx.
+func Start(
+	ctx context.Context,
+	name string,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if not nil, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Writer wraps the row writer for one output, counting the rows written and
+// recording a span around Flush with the row count and the size of the
+// output file.
+type Writer struct {
+	w      row.Writer
+	ctx    context.Context
+	output string
+	rows   int64
+}
+
+// NewWriter wraps w, which writes to the file at output. Flush spans are
+// children of the span in ctx.
+func NewWriter(ctx context.Context, w row.Writer, output string) *Writer {
+	return &Writer{w: w, ctx: ctx, output: output}
+}
+
+// WriteRow writes a row to the wrapped writer.
+func (t *Writer) WriteRow(prefix netip.Prefix, r row.Row) error {
+	t.rows++
+	return t.w.WriteRow(prefix, r)
+}
+
+// WriteRange writes a range to the wrapped writer.
+func (t *Writer) WriteRange(start, end netip.Addr, r row.Row) error {
+	t.rows++
+	return row.WriteRange(t.w, start, end, r)
+}
+
+// Flush flushes the wrapped writer within a span.
+func (t *Writer) Flush() error {
+	_, span := Start(
+		t.ctx,
+		"flush",
+		AttrOutput.String(t.output),
+		AttrRows.Int64(t.rows),
+	)
+	err := row.Flush(t.w)
+	if err == nil {
+		if info, statErr := os.Stat(t.output); statErr == nil {
+			span.SetAttributes(AttrBytes.Int64(info.Size()))
+		}
+	}
+	End(span, err)
+	return err
+}
+
+// Sync syncs the wrapped writer.
+func (t *Writer) Sync() error {
+	return row.Sync(t.w)
+}
@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// fileWriter writes one line per row to a file when flushed.
+type fileWriter struct {
+	path  string
+	lines []byte
+	err   error
+}
+
+func (w *fileWriter) WriteRow(prefix netip.Prefix, _ row.Row) error {
+	w.lines = append(w.lines, prefix.String()+"\n"...)
+	return nil
+}
+
+func (w *fileWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return os.WriteFile(w.path, w.lines, 0o600)
+}
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestWriter_Flush(t *testing.T) {
+	recorder := recordSpans(t)
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w := NewWriter(t.Context(), &fileWriter{path: path}, path)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{mmdbtype.String("AU")}))
+	// Ranges are passed on as prefixes by writers without range support.
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2.0.0.0"),
+		netip.MustParseAddr("2.0.1.255"),
+		row.Row{mmdbtype.String("FR")},
+	))
+	require.NoError(t, w.Flush())
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "flush", spans[0].Name())
+	attrs := spanAttributes(spans[0])
+	assert.Equal(t, path, attrs[AttrOutput].AsString())
+	assert.Equal(t, int64(2), attrs[AttrRows].AsInt64())
+	assert.Equal(t, int64(len("1.0.0.0/24\n2.0.0.0/23\n")), attrs[AttrBytes].AsInt64())
+}
+
+func TestWriter_FlushError(t *testing.T) {
+	recorder := recordSpans(t)
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w := NewWriter(t.Context(), &fileWriter{path: path, err: errors.New("disk full")}, path)
+	require.EqualError(t, w.Flush(), "disk full")
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "disk full", spans[0].Status().Description)
+	_, ok := spanAttributes(spans[0])[AttrBytes]
+	assert.False(t, ok, "no size is recorded for a failed flush")
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	previous := otel.GetTracerProvider()
+	shutdown, err := Setup(t.Context(), "1.2.3")
+	require.NoError(t, err)
+	require.NoError(t, shutdown(t.Context()))
+	assert.Equal(t, previous, otel.GetTracerProvider(), "no provider is installed")
+}
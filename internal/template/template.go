@@ -0,0 +1,271 @@
+// Package template defines named MMDB output structures that match the
+// layout and types expected by GeoIP2 client libraries. A template maps
+// well-known column names to output paths and record types so that a
+// configuration only has to name its columns.
+package template
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Kind is the MMDB type a template field is written as.
+type Kind int
+
+// Kinds used by GeoIP2 records.
+const (
+	KindString Kind = iota
+	KindBool
+	KindUint16
+	KindUint32
+	KindFloat64
+)
+
+// String returns the name of the kind as used in error messages.
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindBool:
+		return "bool"
+	case KindUint16:
+		return "uint16"
+	case KindUint32:
+		return "uint32"
+	case KindFloat64:
+		return "float64"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Field is a column slot in a template.
+type Field struct {
+	Name     string // Column name that fills this field
+	Path     []any  // Output path in the MMDB record
+	Kind     Kind   // Type expected by client libraries
+	Required bool   // Whether the configuration must provide this column
+}
+
+// Template is a named output structure.
+type Template struct {
+	Name         string
+	DatabaseType string // Default output.mmdb.database_type
+	Fields       []Field
+}
+
+// Field returns the template field filled by the named column.
+func (t *Template) Field(name string) (Field, bool) {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Lookup returns the template with the given name.
+func Lookup(name string) (*Template, bool) {
+	t, ok := templates[name]
+	return t, ok
+}
+
+// Names returns the names of all templates, sorted.
+func Names() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Coerce converts value to the kind's MMDB type. Lossless numeric
+// conversions are allowed; anything else is an error.
+func (k Kind) Coerce(value mmdbtype.DataType) (mmdbtype.DataType, error) {
+	switch k {
+	case KindString:
+		if v, ok := value.(mmdbtype.String); ok {
+			return v, nil
+		}
+	case KindBool:
+		if v, ok := value.(mmdbtype.Bool); ok {
+			return v, nil
+		}
+	case KindUint16, KindUint32:
+		n, ok := unsignedValue(value)
+		if !ok {
+			break
+		}
+		if k == KindUint16 {
+			if n > math.MaxUint16 {
+				return nil, fmt.Errorf("value %d out of range for uint16", n)
+			}
+			return mmdbtype.Uint16(n), nil
+		}
+		if n > math.MaxUint32 {
+			return nil, fmt.Errorf("value %d out of range for uint32", n)
+		}
+		return mmdbtype.Uint32(n), nil
+	case KindFloat64:
+		switch v := value.(type) {
+		case mmdbtype.Float64:
+			return v, nil
+		case mmdbtype.Float32:
+			return mmdbtype.Float64(v), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", value, k)
+}
+
+// unsignedValue returns value as a uint64 if it is a non-negative integer
+// that fits.
+func unsignedValue(value mmdbtype.DataType) (uint64, bool) {
+	switch v := value.(type) {
+	case mmdbtype.Uint16:
+		return uint64(v), true
+	case mmdbtype.Uint32:
+		return uint64(v), true
+	case mmdbtype.Uint64:
+		return uint64(v), true
+	case mmdbtype.Int32:
+		if v < 0 {
+			return 0, false
+		}
+		return uint64(v), true
+	case *mmdbtype.Uint128:
+		i := (*big.Int)(v)
+		if !i.IsUint64() {
+			return 0, false
+		}
+		return i.Uint64(), true
+	default:
+		return 0, false
+	}
+}
+
+// countryFields are shared by the country and city templates.
+var countryFields = []Field{
+	{Name: "continent_code", Path: []any{"continent", "code"}, Kind: KindString},
+	{Name: "continent_geoname_id", Path: []any{"continent", "geoname_id"}, Kind: KindUint32},
+	{Name: "continent_name", Path: []any{"continent", "names", "en"}, Kind: KindString},
+	{
+		Name:     "country_iso_code",
+		Path:     []any{"country", "iso_code"},
+		Kind:     KindString,
+		Required: true,
+	},
+	{Name: "country_geoname_id", Path: []any{"country", "geoname_id"}, Kind: KindUint32},
+	{Name: "country_name", Path: []any{"country", "names", "en"}, Kind: KindString},
+	{
+		Name: "country_is_in_european_union",
+		Path: []any{"country", "is_in_european_union"},
+		Kind: KindBool,
+	},
+	{
+		Name: "registered_country_iso_code",
+		Path: []any{"registered_country", "iso_code"},
+		Kind: KindString,
+	},
+	{
+		Name: "registered_country_geoname_id",
+		Path: []any{"registered_country", "geoname_id"},
+		Kind: KindUint32,
+	},
+	{
+		Name: "represented_country_iso_code",
+		Path: []any{"represented_country", "iso_code"},
+		Kind: KindString,
+	},
+	{
+		Name: "represented_country_geoname_id",
+		Path: []any{"represented_country", "geoname_id"},
+		Kind: KindUint32,
+	},
+}
+
+var cityFields = []Field{
+	{
+		Name: "subdivision_1_iso_code",
+		Path: []any{"subdivisions", 0, "iso_code"},
+		Kind: KindString,
+	},
+	{
+		Name: "subdivision_1_geoname_id",
+		Path: []any{"subdivisions", 0, "geoname_id"},
+		Kind: KindUint32,
+	},
+	{
+		Name: "subdivision_1_name",
+		Path: []any{"subdivisions", 0, "names", "en"},
+		Kind: KindString,
+	},
+	{
+		Name: "subdivision_2_iso_code",
+		Path: []any{"subdivisions", 1, "iso_code"},
+		Kind: KindString,
+	},
+	{
+		Name: "subdivision_2_geoname_id",
+		Path: []any{"subdivisions", 1, "geoname_id"},
+		Kind: KindUint32,
+	},
+	{
+		Name: "subdivision_2_name",
+		Path: []any{"subdivisions", 1, "names", "en"},
+		Kind: KindString,
+	},
+	{Name: "city_geoname_id", Path: []any{"city", "geoname_id"}, Kind: KindUint32},
+	{Name: "city_name", Path: []any{"city", "names", "en"}, Kind: KindString, Required: true},
+	{Name: "postal_code", Path: []any{"postal", "code"}, Kind: KindString},
+	{
+		Name:     "latitude",
+		Path:     []any{"location", "latitude"},
+		Kind:     KindFloat64,
+		Required: true,
+	},
+	{
+		Name:     "longitude",
+		Path:     []any{"location", "longitude"},
+		Kind:     KindFloat64,
+		Required: true,
+	},
+	{
+		Name: "accuracy_radius",
+		Path: []any{"location", "accuracy_radius"},
+		Kind: KindUint16,
+	},
+	{Name: "metro_code", Path: []any{"location", "metro_code"}, Kind: KindUint16},
+	{Name: "time_zone", Path: []any{"location", "time_zone"}, Kind: KindString},
+}
+
+var anonymousIPFields = []Field{
+	{Name: "is_anonymous", Path: []any{"is_anonymous"}, Kind: KindBool, Required: true},
+	{Name: "is_anonymous_vpn", Path: []any{"is_anonymous_vpn"}, Kind: KindBool},
+	{Name: "is_hosting_provider", Path: []any{"is_hosting_provider"}, Kind: KindBool},
+	{Name: "is_public_proxy", Path: []any{"is_public_proxy"}, Kind: KindBool},
+	{Name: "is_residential_proxy", Path: []any{"is_residential_proxy"}, Kind: KindBool},
+	{Name: "is_tor_exit_node", Path: []any{"is_tor_exit_node"}, Kind: KindBool},
+}
+
+var templates = map[string]*Template{
+	"geoip2-country": {
+		Name:         "geoip2-country",
+		DatabaseType: "GeoIP2-Country",
+		Fields:       countryFields,
+	},
+	"geoip2-city": {
+		Name:         "geoip2-city",
+		DatabaseType: "GeoIP2-City",
+		Fields:       slices.Concat(countryFields, cityFields),
+	},
+	"geoip2-anonymous-ip": {
+		Name:         "geoip2-anonymous-ip",
+		DatabaseType: "GeoIP2-Anonymous-IP",
+		Fields:       anonymousIPFields,
+	},
+}
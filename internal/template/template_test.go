@@ -0,0 +1,69 @@
+package template
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKind_Coerce(t *testing.T) {
+	tests := []struct {
+		name        string
+		kind        Kind
+		value       mmdbtype.DataType
+		expected    mmdbtype.DataType
+		errContains string
+	}{
+		{"string", KindString, mmdbtype.String("US"), mmdbtype.String("US"), ""},
+		{"bool", KindBool, mmdbtype.Bool(true), mmdbtype.Bool(true), ""},
+		{"uint64 to uint32", KindUint32, mmdbtype.Uint64(6252001), mmdbtype.Uint32(6252001), ""},
+		{"int32 to uint16", KindUint16, mmdbtype.Int32(100), mmdbtype.Uint16(100), ""},
+		{
+			"uint128 to uint32",
+			KindUint32,
+			(*mmdbtype.Uint128)(big.NewInt(42)),
+			mmdbtype.Uint32(42),
+			"",
+		},
+		{"float32 to float64", KindFloat64, mmdbtype.Float32(1.5), mmdbtype.Float64(1.5), ""},
+		{"uint16 overflow", KindUint16, mmdbtype.Uint32(70000), nil, "out of range for uint16"},
+		{"negative int32", KindUint32, mmdbtype.Int32(-1), nil, "cannot convert"},
+		{"string to bool", KindBool, mmdbtype.String("true"), nil, "cannot convert"},
+		{"int to float64", KindFloat64, mmdbtype.Uint32(1), nil, "cannot convert"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.kind.Coerce(tt.value)
+			if tt.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, []string{"geoip2-anonymous-ip", "geoip2-city", "geoip2-country"}, Names())
+
+	tmpl, ok := Lookup("geoip2-city")
+	require.True(t, ok)
+	assert.Equal(t, "GeoIP2-City", tmpl.DatabaseType)
+
+	field, ok := tmpl.Field("subdivision_1_iso_code")
+	require.True(t, ok)
+	assert.Equal(t, []any{"subdivisions", 0, "iso_code"}, field.Path)
+
+	// City templates include the country fields
+	_, ok = tmpl.Field("country_iso_code")
+	assert.True(t, ok)
+
+	_, ok = Lookup("geoip2-isp")
+	assert.False(t, ok)
+}
@@ -0,0 +1,217 @@
+// Package throttle slows a conversion down so that it can run next to a
+// latency-sensitive process: it bounds the rows written per second and the
+// bytes written per second, and pauses while the system load average is
+// above a threshold.
+package throttle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// minSleep is the shortest pause taken. Shorter delays are accumulated
+// instead, as sleeping for very short durations is imprecise.
+const minSleep = 10 * time.Millisecond
+
+// Limiter paces events to a fixed rate. It is safe for concurrent use, so
+// one limiter can bound the total rate of several writers.
+type Limiter struct {
+	perSecond float64
+	now       func() time.Time
+	sleep     func(time.Duration)
+
+	mu   sync.Mutex
+	next time.Time // When the next event may happen
+}
+
+// NewLimiter creates a limiter allowing perSecond events per second.
+func NewLimiter(perSecond float64) *Limiter {
+	return &Limiter{perSecond: perSecond, now: time.Now, sleep: time.Sleep}
+}
+
+// Wait blocks until n more events fit within the rate.
+func (l *Limiter) Wait(n int) {
+	l.mu.Lock()
+	now := l.now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.perSecond * float64(time.Second)))
+	l.mu.Unlock()
+
+	if delay >= minSleep {
+		l.sleep(delay)
+	}
+}
+
+// LoadGuard pauses while the one-minute load average is above a threshold.
+// It is not safe for concurrent use.
+type LoadGuard struct {
+	max       float64
+	interval  time.Duration // Between load average checks
+	lastCheck time.Time
+
+	// OnPause, if not nil, is called with the load average when a pause
+	// starts.
+	OnPause func(load float64)
+
+	readLoad func() (float64, error)
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+// NewLoadGuard creates a guard pausing while the load average is above max.
+// The load average is read from /proc/loadavg, so only Linux is supported.
+func NewLoadGuard(maxLoad float64) (*LoadGuard, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("pausing on system load is not supported on %s", runtime.GOOS)
+	}
+	if _, err := readLoadAverage(); err != nil {
+		return nil, err
+	}
+	return &LoadGuard{
+		max:      maxLoad,
+		interval: time.Second,
+		readLoad: readLoadAverage,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}, nil
+}
+
+// Wait blocks while the load average is above the threshold. The load
+// average is read at most once per interval, and errors reading it are
+// treated as low load.
+func (g *LoadGuard) Wait() {
+	if g.now().Sub(g.lastCheck) < g.interval {
+		return
+	}
+	paused := false
+	for {
+		g.lastCheck = g.now()
+		load, err := g.readLoad()
+		if err != nil || load <= g.max {
+			return
+		}
+		if !paused && g.OnPause != nil {
+			g.OnPause(load)
+		}
+		paused = true
+		g.sleep(g.interval)
+	}
+}
+
+func readLoadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, fmt.Errorf("reading load average: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errors.New("reading load average: /proc/loadavg is empty")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("reading load average: %w", err)
+	}
+	return load, nil
+}
+
+// Writer wraps a row writer, waiting before each row for the row limiter
+// and the load guard. Either may be nil.
+type Writer struct {
+	w       row.Writer
+	limiter *Limiter
+	guard   *LoadGuard
+}
+
+// NewWriter wraps w.
+func NewWriter(w row.Writer, limiter *Limiter, guard *LoadGuard) *Writer {
+	return &Writer{w: w, limiter: limiter, guard: guard}
+}
+
+// WriteRow waits, then writes a row to the wrapped writer.
+func (t *Writer) WriteRow(prefix netip.Prefix, r row.Row) error {
+	t.wait()
+	return t.w.WriteRow(prefix, r)
+}
+
+// WriteRange waits, then writes a range to the wrapped writer.
+func (t *Writer) WriteRange(start, end netip.Addr, r row.Row) error {
+	t.wait()
+	return row.WriteRange(t.w, start, end, r)
+}
+
+// Flush flushes the wrapped writer.
+func (t *Writer) Flush() error {
+	return row.Flush(t.w)
+}
+
+// Sync syncs the wrapped writer.
+func (t *Writer) Sync() error {
+	return row.Sync(t.w)
+}
+
+func (t *Writer) wait() {
+	if t.guard != nil {
+		t.guard.Wait()
+	}
+	if t.limiter != nil {
+		t.limiter.Wait(1)
+	}
+}
+
+// maxChunk bounds the bytes written at once by IOWriter. Writes are also
+// split into at most a tenth of a second's worth of bytes, so that large
+// writes are spread out rather than followed by one long pause.
+const maxChunk = 64 * 1024
+
+// IOWriter wraps an io.Writer, limiting the bytes written per second.
+type IOWriter struct {
+	w       io.Writer
+	limiter *Limiter
+	chunk   int
+}
+
+// NewIOWriter wraps w, waiting for limiter before writing each byte. Writers
+// sharing a limiter share its rate.
+func NewIOWriter(w io.Writer, limiter *Limiter) *IOWriter {
+	chunk := min(max(int(limiter.perSecond/10), 1), maxChunk)
+	return &IOWriter{w: w, limiter: limiter, chunk: chunk}
+}
+
+// Write writes p to the wrapped writer in chunks, waiting for the limiter
+// before each.
+func (t *IOWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), t.chunk)]
+		t.limiter.Wait(len(chunk))
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Sync syncs the wrapped writer if it supports syncing, so that periodic
+// syncs still reach the output file.
+func (t *IOWriter) Sync() error {
+	if syncer, ok := t.w.(row.Syncer); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package throttle
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// fakeClock advances only when slept on.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(perSecond float64, clock *fakeClock) *Limiter {
+	l := NewLimiter(perSecond)
+	l.now = clock.Now
+	l.sleep = clock.Sleep
+	return l
+}
+
+func TestLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := newTestLimiter(100, clock)
+
+	for range 300 {
+		l.Wait(1)
+	}
+	// 300 events at 100/s take 2s after the first, which is not delayed.
+	assert.Equal(t, time.Unix(2, 990_000_000), clock.now)
+	for _, d := range clock.sleeps {
+		assert.GreaterOrEqual(t, d, minSleep, "short delays are accumulated")
+	}
+
+	// Time spent elsewhere is not made up for with a burst.
+	clock.now = clock.now.Add(time.Minute)
+	clock.sleeps = nil
+	l.Wait(50)
+	assert.Empty(t, clock.sleeps)
+	l.Wait(1)
+	assert.Equal(t, []time.Duration{500 * time.Millisecond}, clock.sleeps)
+}
+
+func TestLoadGuard(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	loads := []float64{9, 12, 11, 3}
+	var paused []float64
+	g := &LoadGuard{
+		max:      8,
+		interval: time.Second,
+		OnPause:  func(load float64) { paused = append(paused, load) },
+		readLoad: func() (float64, error) {
+			load := loads[0]
+			loads = loads[1:]
+			return load, nil
+		},
+		now:   clock.Now,
+		sleep: clock.Sleep,
+	}
+
+	g.Wait()
+	assert.Equal(t, []float64{9}, paused, "OnPause is called once per pause")
+	assert.Len(t, clock.sleeps, 3)
+	assert.Empty(t, loads)
+
+	// The load average is not read again within the interval.
+	g.Wait()
+
+	clock.now = clock.now.Add(time.Second)
+	g.readLoad = func() (float64, error) { return 0, errors.New("unreadable") }
+	g.Wait()
+	assert.Len(t, clock.sleeps, 3, "errors are treated as low load")
+}
+
+// recordingWriter records the prefixes written to it.
+type recordingWriter struct {
+	prefixes []netip.Prefix
+	flushed  bool
+}
+
+func (w *recordingWriter) WriteRow(prefix netip.Prefix, _ row.Row) error {
+	w.prefixes = append(w.prefixes, prefix)
+	return nil
+}
+
+func (w *recordingWriter) Flush() error {
+	w.flushed = true
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	inner := &recordingWriter{}
+	w := NewWriter(inner, newTestLimiter(10, clock), nil)
+
+	r := row.Row{mmdbtype.String("AU")}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2.0.0.0"),
+		netip.MustParseAddr("2.0.1.255"),
+		r,
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("1.0.0.0/24"),
+		netip.MustParsePrefix("2.0.0.0/23"),
+	}, inner.prefixes)
+	assert.True(t, inner.flushed)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond}, clock.sleeps)
+}
+
+// syncBuffer is a bytes.Buffer recording its writes and syncs.
+type syncBuffer struct {
+	bytes.Buffer
+	writes int
+	synced bool
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.writes++
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced = true
+	return nil
+}
+
+func TestIOWriter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	buf := &syncBuffer{}
+	w := NewIOWriter(buf, newTestLimiter(1000, clock))
+
+	data := bytes.Repeat([]byte("x"), 1000)
+	n, err := w.Write(data)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, n)
+	assert.Equal(t, data, buf.Bytes())
+	assert.Equal(t, 10, buf.writes, "writes are split into 100ms chunks")
+	assert.Equal(t, time.Unix(0, 900_000_000), clock.now)
+
+	require.NoError(t, w.Sync())
+	assert.True(t, buf.synced)
+}
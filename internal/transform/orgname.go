@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// legalSuffixes are the legal forms stripped from the end of organization
+// names, as words after punctuation is removed, so that "S.A." is "s a".
+var legalSuffixes = [][]string{
+	{"ab"}, {"ag"}, {"as"}, {"bhd"}, {"bv"}, {"b", "v"}, {"co"}, {"company"},
+	{"corp"}, {"corporation"}, {"doo"}, {"d", "o", "o"}, {"gmbh"}, {"inc"},
+	{"incorporated"}, {"jsc"}, {"kft"}, {"kg"}, {"kk"}, {"k", "k"}, {"llc"},
+	{"l", "l", "c"}, {"llp"}, {"lp"}, {"ltd"}, {"ltda"}, {"limited"}, {"nv"},
+	{"n", "v"}, {"ojsc"}, {"ooo"}, {"oy"}, {"pjsc"}, {"plc"}, {"pte"},
+	{"pty"}, {"pvt"}, {"sa"}, {"s", "a"}, {"sarl"}, {"s", "a", "r", "l"},
+	{"sas"}, {"s", "a", "s"}, {"sdn"}, {"se"}, {"spa"}, {"s", "p", "a"},
+	{"sro"}, {"s", "r", "o"}, {"srl"}, {"s", "r", "l"}, {"sa", "de", "cv"},
+	{"s", "a", "de", "c", "v"},
+	// Connectors left dangling, as in "GmbH & Co. KG"
+	{"&"}, {"and"},
+}
+
+// orgName reduces an organization name to a key shared by its case,
+// punctuation, and legal form variants: it is lowercased, apostrophes are
+// removed, other punctuation separates words, and legal suffixes such as
+// "Inc." and "GmbH" are stripped from the end. "Example, Inc." and
+// "EXAMPLE INC" both become "example". A name made only of legal forms is
+// kept.
+func orgName(s string) string {
+	words := strings.FieldsFunc(
+		strings.Map(func(r rune) rune {
+			switch {
+			case r == '\'' || r == '’':
+				return -1
+			case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '&':
+				return unicode.ToLower(r)
+			default:
+				return ' '
+			}
+		}, s),
+		func(r rune) bool { return r == ' ' },
+	)
+	for stripped := true; stripped; {
+		stripped = false
+		for _, suffix := range legalSuffixes {
+			if len(words) > len(suffix) && slices.Equal(words[len(words)-len(suffix):], suffix) {
+				words = words[:len(words)-len(suffix)]
+				stripped = true
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// orgNameStep applies the org_name step, replacing names mapped in the
+// canonical names of the pipeline.
+func (p *Pipeline) orgNameStep(s string) string {
+	key := orgName(s)
+	if canonical, ok := p.orgNames[key]; ok {
+		return canonical
+	}
+	return key
+}
+
+// LoadOrgNames reads the canonical organization names of a CSV file with
+// the header "name,canonical", for Options.OrgNames. Several names may map
+// to one canonical name.
+func LoadOrgNames(path string) (map[string]string, error) {
+	// #nosec G304 -- path comes from the trusted configuration
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = 2
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header of %s: %w", path, err)
+	}
+	if header[0] != "name" || header[1] != "canonical" {
+		return nil, fmt.Errorf("%s: the header must be 'name,canonical'", path)
+	}
+
+	names := map[string]string{}
+	keys := map[string]string{} // Names seen, by key
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		line, _ := r.FieldPos(0)
+		name, canonical := record[0], record[1]
+		if canonical == "" {
+			return nil, fmt.Errorf("%s:%d: empty canonical name for '%s'", path, line, name)
+		}
+		key := orgName(name)
+		if previous, ok := keys[key]; ok && names[key] != canonical {
+			return nil, fmt.Errorf(
+				"%s:%d: '%s' is the same name as '%s', mapped to '%s'",
+				path,
+				line,
+				name,
+				previous,
+				names[key],
+			)
+		}
+		keys[key] = name
+		names[key] = canonical
+	}
+}
@@ -0,0 +1,220 @@
+// Package transform normalizes the strings of column values with named
+// pipelines of steps, such as trimming whitespace, title casing, converting
+// country codes, and cleaning up organization names, so that many columns can share one definition
+// of a clean value.
+package transform
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// steps maps the name of each step to the function applying it.
+var steps = map[string]func(string) string{
+	"trim":        strings.TrimSpace,
+	"collapse_ws": collapseWhitespace,
+	"lower":       strings.ToLower,
+	"upper":       strings.ToUpper,
+	"title_case":  titleCase,
+}
+
+// OrgNameStep is the step reducing organization names to a key shared by
+// their variants.
+const OrgNameStep = "org_name"
+
+// Steps returns the supported step names.
+func Steps() []string {
+	names := slices.AppendSeq(slices.Collect(maps.Keys(steps)), maps.Keys(countrySteps))
+	names = append(names, OrgNameStep)
+	slices.Sort(names)
+	return names
+}
+
+// Options are the settings of the country and organization name steps.
+type Options struct {
+	// CountryAliases maps codes, such as user-assigned codes, to the code
+	// output in their place. Aliases to country codes are converted to the
+	// step's form.
+	CountryAliases map[string]string
+	// UnknownCountries is the policy for strings that are not country codes:
+	// one of the UnknownCountries constants (default: keep).
+	UnknownCountries string
+	// OrgNames maps organization names to the name the org_name step
+	// outputs in their place, as read by LoadOrgNames. Names match all of
+	// their variants.
+	OrgNames map[string]string
+}
+
+// Pipeline applies steps to strings in order. It is safe for concurrent use.
+type Pipeline struct {
+	steps []func(string) (string, error)
+
+	countryAliases map[string]string
+	unknownPolicy  string
+	orgNames       map[string]string // Canonical organization names, by key
+
+	mu               sync.Mutex
+	unknownCountries map[string]int // Unknown country codes seen, with their counts
+}
+
+// New returns the pipeline applying the named steps in order.
+func New(names []string, opts Options) (*Pipeline, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no steps, must have one or more of: %s", strings.Join(Steps(), ", "))
+	}
+	p := &Pipeline{
+		countryAliases:   make(map[string]string, len(opts.CountryAliases)),
+		unknownPolicy:    opts.UnknownCountries,
+		orgNames:         make(map[string]string, len(opts.OrgNames)),
+		unknownCountries: map[string]int{},
+	}
+	hasCountry := false
+	for _, name := range names {
+		if name == OrgNameStep {
+			p.steps = append(p.steps, func(s string) (string, error) {
+				return p.orgNameStep(s), nil
+			})
+			continue
+		}
+		if form, ok := countrySteps[name]; ok {
+			p.steps = append(p.steps, func(s string) (string, error) {
+				return p.country(form, s)
+			})
+			hasCountry = true
+			continue
+		}
+		step, ok := steps[name]
+		if !ok {
+			return nil, fmt.Errorf(
+				"unknown step '%s', must be one of: %s",
+				name,
+				strings.Join(Steps(), ", "),
+			)
+		}
+		p.steps = append(p.steps, func(s string) (string, error) {
+			return step(s), nil
+		})
+	}
+
+	switch opts.UnknownCountries {
+	case "", UnknownCountriesKeep, UnknownCountriesEmpty, UnknownCountriesError:
+	default:
+		return nil, fmt.Errorf(
+			"invalid unknown_countries '%s', must be one of: %s, %s, %s",
+			opts.UnknownCountries,
+			UnknownCountriesKeep,
+			UnknownCountriesEmpty,
+			UnknownCountriesError,
+		)
+	}
+	if !hasCountry && (len(opts.CountryAliases) > 0 || opts.UnknownCountries != "") {
+		return nil, fmt.Errorf(
+			"country_aliases and unknown_countries require one of the steps: %s",
+			strings.Join(slices.Sorted(maps.Keys(countrySteps)), ", "),
+		)
+	}
+	for code, alias := range opts.CountryAliases {
+		p.countryAliases[strings.ToUpper(code)] = alias
+	}
+	for name, canonical := range opts.OrgNames {
+		p.orgNames[orgName(name)] = canonical
+	}
+	return p, nil
+}
+
+// String applies the pipeline to s.
+func (p *Pipeline) String(s string) (string, error) {
+	for _, step := range p.steps {
+		var err error
+		if s, err = step(s); err != nil {
+			return "", err
+		}
+	}
+	return s, nil
+}
+
+// Value applies the pipeline to the strings of v, including strings nested
+// in maps and slices. Other values are returned unchanged.
+func (p *Pipeline) Value(v mmdbtype.DataType) (mmdbtype.DataType, error) {
+	switch v := v.(type) {
+	case mmdbtype.String:
+		s, err := p.String(string(v))
+		if err != nil {
+			return nil, err
+		}
+		return mmdbtype.String(s), nil
+	case mmdbtype.Map:
+		out := make(mmdbtype.Map, len(v))
+		for key, value := range v {
+			var err error
+			if out[key], err = p.Value(value); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case mmdbtype.Slice:
+		out := make(mmdbtype.Slice, len(v))
+		for i, value := range v {
+			var err error
+			if out[i], err = p.Value(value); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// collapseWhitespace replaces each run of whitespace with a single space.
+// Leading and trailing whitespace is collapsed too, but not removed.
+func collapseWhitespace(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// titleCase capitalizes the first letter of each word and lowercases the
+// others. Words are separated by anything but letters, digits, and
+// apostrophes, so "saint-étienne" becomes "Saint-Étienne" and "o'neill"
+// becomes "O'neill".
+func titleCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	inWord := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if inWord {
+				r = unicode.ToLower(r)
+			} else {
+				r = unicode.ToTitle(r)
+			}
+			inWord = true
+		case r == '\'' || r == '’':
+			// Apostrophes stay within a word
+		default:
+			inWord = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSteps(t *testing.T) {
+	tests := []struct {
+		step     string
+		input    string
+		expected string
+	}{
+		{"trim", "  Paris \t\n", "Paris"},
+		{"collapse_ws", "New \t York\n\nCity", "New York City"},
+		{"collapse_ws", "  padded  ", " padded "},
+		{"lower", "SÃO PAULO", "são paulo"},
+		{"upper", "münchen", "MÜNCHEN"},
+		{"title_case", "NEW YORK CITY", "New York City"},
+		{"title_case", "saint-étienne", "Saint-Étienne"},
+		{"title_case", "o'neill's 2nd st", "O'neill's 2nd St"},
+		{"title_case", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.step+" "+tt.input, func(t *testing.T) {
+			p, err := New([]string{tt.step}, Options{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, must(p.String(tt.input)))
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p, err := New([]string{"trim", "collapse_ws", "title_case"}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "Rio De Janeiro", must(p.String("  rio   de\tJANEIRO ")))
+
+	// Steps run in order
+	p, err = New([]string{"collapse_ws", "trim"}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "a b", must(p.String("\ta   b\n")))
+
+	assert.Equal(t, mmdbtype.Map{
+		"names":  mmdbtype.Slice{mmdbtype.String("A B"), mmdbtype.Uint32(7)},
+		"is_big": mmdbtype.Bool(true),
+	}, must(must(New([]string{"collapse_ws", "upper"}, Options{})).Value(mmdbtype.Map{
+		"names":  mmdbtype.Slice{mmdbtype.String("a  b"), mmdbtype.Uint32(7)},
+		"is_big": mmdbtype.Bool(true),
+	})))
+}
+
+func TestNew_Errors(t *testing.T) {
+	steps := "collapse_ws, country_alpha2, country_alpha3, country_numeric, lower, org_name, title_case, trim, upper"
+	_, err := New(nil, Options{})
+	require.EqualError(t, err, "no steps, must have one or more of: "+steps)
+
+	_, err = New([]string{"trim", "snake_case"}, Options{})
+	require.EqualError(t, err, "unknown step 'snake_case', must be one of: "+steps)
+
+	_, err = New([]string{"country_alpha2"}, Options{UnknownCountries: "drop"})
+	require.EqualError(t, err, "invalid unknown_countries 'drop', must be one of: keep, empty, error")
+
+	_, err = New([]string{"trim"}, Options{CountryAliases: map[string]string{"UK": "GB"}})
+	require.EqualError(
+		t,
+		err,
+		"country_aliases and unknown_countries require one of the steps: country_alpha2, country_alpha3, country_numeric",
+	)
+}
+
+func TestCountrySteps(t *testing.T) {
+	tests := []struct {
+		step     string
+		input    string
+		expected string
+	}{
+		{"country_alpha2", "DEU", "DE"},
+		{"country_alpha2", "de", "DE"},
+		{"country_alpha2", "276", "DE"},
+		{"country_alpha2", "36", "AU"},
+		{"country_alpha3", "US", "USA"},
+		{"country_alpha3", "840", "USA"},
+		{"country_alpha3", "gbr", "GBR"},
+		{"country_numeric", "AF", "004"},
+		{"country_numeric", "ZWE", "716"},
+		{"country_numeric", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.step+" "+tt.input, func(t *testing.T) {
+			p, err := New([]string{tt.step}, Options{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, must(p.String(tt.input)))
+		})
+	}
+}
+
+func TestCountrySteps_Aliases(t *testing.T) {
+	p, err := New([]string{"country_alpha3"}, Options{
+		CountryAliases: map[string]string{"uk": "GB", "XK": "XKX"},
+	})
+	require.NoError(t, err)
+
+	// Aliases to country codes are converted, and others are output as is
+	assert.Equal(t, "GBR", must(p.String("UK")))
+	assert.Equal(t, "XKX", must(p.String("XK")))
+	assert.Empty(t, p.UnknownCountries())
+}
+
+func TestCountrySteps_UnknownCountries(t *testing.T) {
+	keep, err := New([]string{"country_alpha2"}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "EU", must(keep.String("EU")))
+	assert.Equal(t, "EU", must(keep.String("EU")))
+	assert.Equal(t, "Q1", must(keep.String("Q1")))
+	assert.Equal(t, map[string]int{"EU": 2, "Q1": 1}, keep.UnknownCountries())
+
+	empty, err := New([]string{"country_alpha2"}, Options{UnknownCountries: UnknownCountriesEmpty})
+	require.NoError(t, err)
+	assert.Empty(t, must(empty.String("EU")))
+	assert.Equal(t, map[string]int{"EU": 1}, empty.UnknownCountries())
+
+	strict, err := New([]string{"trim", "country_alpha2"}, Options{UnknownCountries: UnknownCountriesError})
+	require.NoError(t, err)
+	_, err = strict.Value(mmdbtype.Slice{mmdbtype.String(" US "), mmdbtype.String("EU")})
+	require.EqualError(t, err, "unknown country code 'EU'")
+}
+
+func TestOrgNameStep(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Example, Inc.", "example"},
+		{"EXAMPLE INC", "example"},
+		{"example inc.", "example"},
+		{"Example Holdings Pty Ltd", "example holdings"},
+		{"Beispiel GmbH & Co. KG", "beispiel"},
+		{"Ejemplo S.A. de C.V.", "ejemplo"},
+		{"Exemple S.A.R.L.", "exemple"},
+		{"Amazon.com, Inc.", "amazon com"},
+		{"McDonald's Corporation", "mcdonalds"},
+		{"AT&T Services, Inc.", "at&t services"},
+		{"  Tele-Net   Ltd  ", "tele net"},
+		{"Limited", "limited"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := New([]string{"org_name"}, Options{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, must(p.String(tt.input)))
+		})
+	}
+}
+
+func TestOrgNameStep_OrgNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "org_names.csv")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"name,canonical\n"+
+			"Example Inc,Example\n"+
+			"Example Cloud LLC,Example\n",
+	), 0o600))
+	names, err := LoadOrgNames(path)
+	require.NoError(t, err)
+
+	p, err := New([]string{"org_name"}, Options{OrgNames: names})
+	require.NoError(t, err)
+	assert.Equal(t, "Example", must(p.String("EXAMPLE, INC.")))
+	assert.Equal(t, "Example", must(p.String("Example Cloud")))
+	assert.Equal(t, "other", must(p.String("Other Ltd")))
+}
+
+func TestLoadOrgNames_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{
+			name:     "header",
+			contents: "organization,canonical\n",
+			expected: "the header must be 'name,canonical'",
+		},
+		{
+			name:     "empty canonical name",
+			contents: "name,canonical\nExample Inc,\n",
+			expected: ":2: empty canonical name for 'Example Inc'",
+		},
+		{
+			name:     "conflicting names",
+			contents: "name,canonical\nExample Inc,Example\nEXAMPLE LLC,Other\n",
+			expected: ":3: 'EXAMPLE LLC' is the same name as 'Example Inc', mapped to 'Example'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "org_names.csv")
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+			_, err := LoadOrgNames(path)
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,177 @@
+// Package translit transliterates localized names to ASCII, for consumers
+// whose systems reject non-ASCII values. Latin letters lose their
+// diacritics, letters without a decomposition such as "ß" and "ø" are
+// spelled out, and Greek and Cyrillic letters are romanized. Locales
+// override the defaults where a language has its own convention, such as
+// German "ü" becoming "ue". Characters that cannot be transliterated become
+// "?".
+package translit
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ASCII is the locale applying only the default rules.
+const ASCII = "ascii"
+
+// unknown replaces characters that cannot be transliterated.
+const unknown = "?"
+
+// Transliterator converts strings to ASCII with the rules of a locale.
+type Transliterator struct {
+	locale map[rune]string
+}
+
+// Locales returns the supported locale names.
+func Locales() []string {
+	names := []string{ASCII}
+	for name := range localeRules {
+		names = append(names, name)
+	}
+	slices.Sort(names[1:])
+	return names
+}
+
+// New returns the Transliterator for locale, one of Locales, and whether
+// the locale is supported.
+func New(locale string) (*Transliterator, bool) {
+	if locale == ASCII {
+		return &Transliterator{}, true
+	}
+	rules, ok := localeRules[locale]
+	if !ok {
+		return nil, false
+	}
+	return &Transliterator{locale: rules}, true
+}
+
+// String transliterates s to ASCII.
+func (t *Transliterator) String(s string) string {
+	if isASCII(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+			continue
+		}
+		if out, ok := t.lookup(r); ok {
+			b.WriteString(out)
+			continue
+		}
+		// Decompose to strip diacritics, such as "é" to "e" and an acute
+		// accent, or Greek "ά" to "α" and a tonos
+		for _, d := range norm.NFD.String(string(r)) {
+			switch {
+			case d < utf8.RuneSelf:
+				b.WriteRune(d)
+			case unicode.Is(unicode.Mn, d):
+			default:
+				if out, ok := t.lookup(d); ok {
+					b.WriteString(out)
+				} else {
+					b.WriteString(unknown)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// Value transliterates the strings in v, including those nested in maps
+// and slices. Map keys are kept as they are.
+func (t *Transliterator) Value(v mmdbtype.DataType) mmdbtype.DataType {
+	switch v := v.(type) {
+	case mmdbtype.String:
+		return mmdbtype.String(t.String(string(v)))
+	case mmdbtype.Map:
+		out := make(mmdbtype.Map, len(v))
+		for key, value := range v {
+			out[key] = t.Value(value)
+		}
+		return out
+	case mmdbtype.Slice:
+		out := make(mmdbtype.Slice, len(v))
+		for i, value := range v {
+			out[i] = t.Value(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// lookup returns the spelling of r in the locale or the default rules.
+// Upper case letters are looked up in lower case and capitalized.
+func (t *Transliterator) lookup(r rune) (string, bool) {
+	lower := unicode.ToLower(r)
+	for _, rules := range []map[rune]string{t.locale, defaultRules} {
+		out, ok := rules[lower]
+		if !ok {
+			continue
+		}
+		if lower != r && out != "" {
+			out = strings.ToUpper(out[:1]) + out[1:]
+		}
+		return out, true
+	}
+	return "", false
+}
+
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultRules spell out, in lower case, the letters and punctuation that
+// do not decompose into ASCII.
+var defaultRules = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'ł': "l", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ı': "i", 'ħ': "h", 'ŀ': "l", 'ŧ': "t", 'ŋ': "ng", 'ĸ': "k",
+	'ſ': "s", 'ə': "e",
+
+	// Punctuation
+	'‘': "'", '’': "'", 'ʻ': "'", 'ʼ': "'", '‚': ",", '“': "\"", '”': "\"",
+	'„': "\"", '–': "-", '—': "-", '‐': "-", '…': "...", '\u00a0': " ",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+
+	// Cyrillic, looked up before decomposition, so that "й" is not
+	// reduced to "и"
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'і': "i",
+	'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+}
+
+// localeRules override defaultRules for a language.
+var localeRules = map[string]map[rune]string{
+	"da": danishNorwegian,
+	"de": {'ä': "ae", 'ö': "oe", 'ü': "ue"},
+	"nb": danishNorwegian,
+	"nn": danishNorwegian,
+	"no": danishNorwegian,
+	"uk": {'г': "h", 'и': "y", 'ї': "i"},
+}
+
+var danishNorwegian = map[rune]string{'å': "aa", 'æ': "ae", 'ø': "oe"}
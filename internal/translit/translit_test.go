@@ -0,0 +1,79 @@
+package translit
+
+import (
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		locale string
+		in     string
+		want   string
+	}{
+		{ASCII, "Paris", "Paris"},
+		{ASCII, "São Paulo", "Sao Paulo"},
+		{ASCII, "Zürich", "Zurich"},
+		{ASCII, "Kraków", "Krakow"},
+		{ASCII, "Łódź", "Lodz"},
+		{ASCII, "Straße", "Strasse"},
+		{ASCII, "Tromsø", "Tromso"},
+		{ASCII, "Reykjavík", "Reykjavik"},
+		{ASCII, "Hà Nội", "Ha Noi"},
+		{ASCII, "Αθήνα", "Athina"},
+		{ASCII, "Москва", "Moskva"},
+		{ASCII, "Щёлково", "Shchyolkovo"},
+		{ASCII, "Йошкар-Ола", "Yoshkar-Ola"},
+		{ASCII, "東京", "??"},
+		{"de", "Zürich", "Zuerich"},
+		{"de", "ÖSTERREICH", "OeSTERREICH"},
+		{"da", "Århus", "Aarhus"},
+		{"nb", "Tromsø", "Tromsoe"},
+		{"uk", "Харків", "Kharkiv"},
+		{"uk", "Київ", "Kyiv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale+"/"+tt.in, func(t *testing.T) {
+			tr, ok := New(tt.locale)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, tr.String(tt.in))
+		})
+	}
+}
+
+func TestValue(t *testing.T) {
+	tr, ok := New(ASCII)
+	require.True(t, ok)
+
+	in := mmdbtype.Map{
+		"names": mmdbtype.Map{
+			"de": mmdbtype.String("München"),
+			"ru": mmdbtype.String("Мюнхен"),
+		},
+		"aliases":    mmdbtype.Slice{mmdbtype.String("Minga")},
+		"geoname_id": mmdbtype.Uint32(2867714),
+	}
+	assert.Equal(t, mmdbtype.Map{
+		"names": mmdbtype.Map{
+			"de": mmdbtype.String("Munchen"),
+			"ru": mmdbtype.String("Myunkhen"),
+		},
+		"aliases":    mmdbtype.Slice{mmdbtype.String("Minga")},
+		"geoname_id": mmdbtype.Uint32(2867714),
+	}, tr.Value(in))
+	assert.Equal(
+		t,
+		mmdbtype.String("München"),
+		in["names"].(mmdbtype.Map)["de"],
+		"the input is not modified",
+	)
+}
+
+func TestNew(t *testing.T) {
+	_, ok := New("xx")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"ascii", "da", "de", "nb", "nn", "no", "uk"}, Locales())
+}
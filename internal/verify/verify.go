@@ -0,0 +1,338 @@
+// Package verify reads an output back once it is written and checks it
+// against the rows written to it, guarding against writer bugs and schema
+// drift.
+//
+// A Writer sits right above the writer of an output and records the number
+// of rows each file should hold and a sample of them, spread evenly over the
+// output. CSV and Parquet files are read back with the export package, so
+// that their row counts and the values of the sampled rows are checked as a
+// consumer would see them. MMDB files have no rows, as adjacent networks
+// share their records, so the record of each sampled network is looked up
+// instead.
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"reflect"
+	"slices"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/export"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+// sample is a row written to the output.
+type sample struct {
+	file       int // Index of the file written to
+	start, end netip.Addr
+	row        row.Row
+}
+
+// Writer wraps the writer of an output and records the rows written to it,
+// to check the output against with Verify.
+type Writer struct {
+	writer row.Writer
+	cfg    *config.Config
+	paths  []string // The output file, or its IPv4 and IPv6 files
+	ranges bool     // Whether the output writes a range as a single row
+
+	rows    []int64 // Rows written to each file
+	written int64   // Rows written to every file
+	size    int     // Maximum number of samples
+	stride  int64   // Rows between two samples
+	samples []sample
+}
+
+// NewWriter creates a writer recording the rows written to w, the writer of
+// the output of cfg. paths are the files of the output: one, or
+// the IPv4 and IPv6 files of a split output.
+func NewWriter(w row.Writer, cfg *config.Config, paths []string) *Writer {
+	return &Writer{
+		writer: w,
+		cfg:    cfg,
+		paths:  paths,
+		ranges: cfg.Output.Format == "csv" && writer.CSVWritesRanges(cfg),
+		rows:   make([]int64, len(paths)),
+		size:   cfg.Output.Verify.SampleRows,
+		stride: 1,
+	}
+}
+
+// WriteRow writes a row and records it.
+func (v *Writer) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if err := v.writer.WriteRow(prefix, r); err != nil {
+		return err
+	}
+	v.record(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+	return nil
+}
+
+// WriteRange writes a range and records the rows the output holds for it:
+// the range itself if the output writes ranges, and otherwise one row per
+// CIDR.
+func (v *Writer) WriteRange(start, end netip.Addr, r row.Row) error {
+	if err := row.WriteRange(v.writer, start, end, r); err != nil {
+		return err
+	}
+	if v.ranges {
+		v.record(start, end, r)
+		return nil
+	}
+	for _, prefix := range netipx.IPRangeFrom(start, end).Prefixes() {
+		v.record(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+	}
+	return nil
+}
+
+// record counts a row and samples every stride-th one. Once the sample is
+// full, every other sample is dropped and the stride doubled, so that the
+// sample stays spread over all the rows written.
+func (v *Writer) record(start, end netip.Addr, r row.Row) {
+	file := 0
+	if len(v.paths) > 1 && !start.Is4() {
+		file = 1
+	}
+	n := v.written
+	v.rows[file]++
+	v.written++
+	if n%v.stride != 0 {
+		return
+	}
+	if len(v.samples) == v.size {
+		kept := v.samples[:0]
+		for i := 0; i < len(v.samples); i += 2 {
+			kept = append(kept, v.samples[i])
+		}
+		v.samples = kept
+		v.stride *= 2
+		if n%v.stride != 0 {
+			return
+		}
+	}
+	// The row is only valid during the write, so keep a copy
+	v.samples = append(v.samples, sample{file: file, start: start, end: end, row: slices.Clone(r)})
+}
+
+// Flush flushes the wrapped writer.
+func (v *Writer) Flush() error {
+	return row.Flush(v.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (v *Writer) Sync() error {
+	return row.Sync(v.writer)
+}
+
+// Paths returns the files of the output.
+func (v *Writer) Paths() []string {
+	return v.paths
+}
+
+// Rows returns the number of rows written.
+func (v *Writer) Rows() int64 {
+	return v.written
+}
+
+// Samples returns the number of rows whose values Verify checks.
+func (v *Writer) Samples() int {
+	return len(v.samples)
+}
+
+// Verify reads the output back, once it is flushed, and checks it against
+// the rows written. CSV and Parquet files must hold as many rows as were
+// written, and the sampled rows with their values; MMDB files must hold the
+// records of the sampled networks.
+func (v *Writer) Verify() error {
+	for i, path := range v.paths {
+		var err error
+		if v.cfg.Output.Format == "mmdb" {
+			err = v.verifyMMDB(i)
+		} else {
+			err = v.verifyTable(i)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// fileSamples returns the indexes of the samples of file i.
+func (v *Writer) fileSamples(i int) []int {
+	var indexes []int
+	for j, s := range v.samples {
+		if s.file == i {
+			indexes = append(indexes, j)
+		}
+	}
+	return indexes
+}
+
+// verifyTable checks the CSV or Parquet file i. The rows of a range are
+// matched by their values, as a time-sliced export has a row per slice.
+func (v *Writer) verifyTable(i int) error {
+	path := v.paths[i]
+	// A CSV file without rows has no header either
+	if info, err := os.Stat(path); err == nil && info.Size() == 0 && v.rows[i] == 0 &&
+		v.cfg.Output.Format == "csv" {
+		return nil
+	}
+
+	r, err := export.Open(path, export.OptionsFromConfig(v.cfg))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	indexes := make([]int, len(v.cfg.Columns))
+	for c, col := range v.cfg.Columns {
+		name := col.OutputName(v.cfg.Output.Format)
+		indexes[c] = slices.Index(r.Columns(), name)
+		if indexes[c] < 0 {
+			return fmt.Errorf("column '%s' is missing", name)
+		}
+	}
+
+	pending := map[[2]netip.Addr][]int{}
+	for _, j := range v.fileSamples(i) {
+		key := [2]netip.Addr{v.samples[j].start, v.samples[j].end}
+		pending[key] = append(pending[key], j)
+	}
+	mismatches := map[int]error{}
+	var n int64
+	for {
+		start, end, values, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		n++
+
+		key := [2]netip.Addr{start, end}
+		for k, j := range pending[key] {
+			err := v.compareRow(v.samples[j], values, indexes)
+			if err == nil {
+				pending[key] = slices.Delete(pending[key], k, k+1)
+				delete(mismatches, j)
+				break
+			}
+			if _, ok := mismatches[j]; !ok {
+				mismatches[j] = err
+			}
+		}
+	}
+
+	if n != v.rows[i] {
+		return fmt.Errorf("%d rows read back, but %d were written", n, v.rows[i])
+	}
+	var missing []int
+	for _, js := range pending {
+		missing = append(missing, js...)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	j := slices.Min(missing)
+	if err, ok := mismatches[j]; ok {
+		return err
+	}
+	return fmt.Errorf("no row for %s", rangeString(v.samples[j].start, v.samples[j].end))
+}
+
+// compareRow compares the values read back for a sampled row with those
+// written: as text for CSV, and as the values written for Parquet, which
+// keep their types.
+func (v *Writer) compareRow(s sample, values row.Row, indexes []int) error {
+	for c, col := range v.cfg.Columns {
+		var want, got any
+		var err error
+		if v.cfg.Output.Format == "parquet" {
+			if want, err = writer.ParquetDataValue(s.row, c, col); err != nil {
+				return err
+			}
+			if got, err = writer.ParquetDataValue(values, indexes[c], col); err != nil {
+				return fmt.Errorf("%s of %s: %w", col.Name, rangeString(s.start, s.end), err)
+			}
+		} else {
+			if want, err = s.row.Text(c); err != nil {
+				return err
+			}
+			if got, err = values.Text(indexes[c]); err != nil {
+				return err
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			return fmt.Errorf(
+				"%s of %s is %#v, but %#v was written",
+				col.Name,
+				rangeString(s.start, s.end),
+				got,
+				want,
+			)
+		}
+	}
+	return nil
+}
+
+// verifyMMDB checks the MMDB file i: the first and last addresses of each
+// sampled network must have the record of its row. An IPv4 tree holds no
+// IPv6 rows.
+func (v *Writer) verifyMMDB(i int) error {
+	reader, err := mmdb.Open(config.Database{Path: v.paths[i]})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	ipv4Tree := reader.Metadata().IPVersion == 4
+
+	for _, j := range v.fileSamples(i) {
+		s := v.samples[j]
+		if ipv4Tree && !s.start.Is4() {
+			continue
+		}
+		want, err := writer.MMDBRecord(v.cfg, s.row)
+		if err != nil {
+			return err
+		}
+		for _, addr := range []netip.Addr{s.start, s.end} {
+			result := reader.Lookup(addr)
+			if err := result.Err(); err != nil {
+				return err
+			}
+			got := mmdbtype.Map{}
+			if result.Found() {
+				unmarshaler := mmdbtype.NewUnmarshaler()
+				if err := result.Decode(unmarshaler); err != nil {
+					return fmt.Errorf("decoding record of %s: %w", addr, err)
+				}
+				var ok bool
+				if got, ok = unmarshaler.Result().(mmdbtype.Map); !ok {
+					return fmt.Errorf("record of %s is not a map", addr)
+				}
+			}
+			if !got.Equal(want) {
+				return fmt.Errorf("record of %s is %v, but %v was written", addr, got, want)
+			}
+		}
+	}
+	return nil
+}
+
+// rangeString formats a range as a CIDR if it is one.
+func rangeString(start, end netip.Addr) string {
+	if prefix, ok := netipx.IPRangeFrom(start, end).Prefix(); ok {
+		return prefix.String()
+	}
+	return start.String() + "-" + end.String()
+}
@@ -0,0 +1,173 @@
+package verify
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/writer"
+)
+
+func verifyConfig(format string, sampleRows int, network ...config.NetworkColumn) *config.Config {
+	recordSize := 28
+	includeReserved := false
+	return &config.Config{
+		Output: config.OutputConfig{
+			Format:  format,
+			Verify:  config.VerifyConfig{SampleRows: sampleRows},
+			Parquet: config.ParquetConfig{Compression: "snappy", RowGroupSize: 100},
+			MMDB: config.MMDBConfig{
+				DatabaseType:            "Test",
+				RecordSize:              &recordSize,
+				IncludeReservedNetworks: &includeReserved,
+			},
+		},
+		Network: config.NetworkConfig{Columns: network},
+		Columns: []config.Column{
+			{Name: "country", Type: "string"},
+			{Name: "accuracy", Type: "int64"},
+		},
+	}
+}
+
+// writeRows writes rows of many networks and one range to w, and flushes it.
+func writeRows(t *testing.T, w *Writer) {
+	t.Helper()
+	for i := range 10 {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{1, 0, byte(i), 0}), 24)
+		require.NoError(t, w.WriteRow(prefix, row.Row{mmdbtype.String("US"), mmdbtype.Uint16(i)}))
+	}
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2.0.0.0"),
+		netip.MustParseAddr("2.0.2.255"),
+		row.Row{mmdbtype.String("DE"), nil},
+	))
+	require.NoError(t, w.Flush())
+}
+
+// newFileWriter creates the CSV or Parquet writer of cfg to a file in a
+// temporary directory.
+func newFileWriter(t *testing.T, cfg *config.Config) (row.Writer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out."+cfg.Output.Format)
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+	w, err := writer.New(file, cfg, writer.IPVersionAny)
+	require.NoError(t, err)
+	return w, path
+}
+
+func TestWriter_CSV(t *testing.T) {
+	cfg := verifyConfig("csv", 100,
+		config.NetworkColumn{Name: "start_ip", Type: "start_ip"},
+		config.NetworkColumn{Name: "end_ip", Type: "end_ip"},
+	)
+	inner, path := newFileWriter(t, cfg)
+	w := NewWriter(inner, cfg, []string{path})
+	writeRows(t, w)
+
+	// The range is a single row
+	assert.Equal(t, int64(11), w.Rows())
+	assert.Equal(t, 11, w.Samples())
+	require.NoError(t, w.Verify())
+
+	// A value changed after writing is caught
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), "DE", "FR", 1)), 0o600))
+	require.EqualError(t, w.Verify(), path+`: country of 2.0.0.0-2.0.2.255 is "FR", but "DE" was written`)
+
+	// So is a missing row
+	lines := strings.SplitAfter(string(data), "\n")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines[:len(lines)-2], "")), 0o600))
+	require.EqualError(t, w.Verify(), path+": 10 rows read back, but 11 were written")
+}
+
+func TestWriter_CSVPrefixes(t *testing.T) {
+	cfg := verifyConfig("csv", 100, config.NetworkColumn{Name: "network", Type: "cidr"})
+	inner, path := newFileWriter(t, cfg)
+	w := NewWriter(inner, cfg, []string{path})
+	writeRows(t, w)
+
+	// The range is written as one row per CIDR
+	assert.Equal(t, int64(12), w.Rows())
+	require.NoError(t, w.Verify())
+}
+
+func TestWriter_Parquet(t *testing.T) {
+	cfg := verifyConfig("parquet", 100, config.NetworkColumn{Name: "network", Type: "cidr"})
+	inner, path := newFileWriter(t, cfg)
+	w := NewWriter(inner, cfg, []string{path})
+	writeRows(t, w)
+
+	assert.Equal(t, int64(12), w.Rows())
+	require.NoError(t, w.Verify())
+
+	// Rows read back from another output are missing
+	other, otherPath := newFileWriter(t, cfg)
+	require.NoError(t, other.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{mmdbtype.String("US"), nil}))
+	require.NoError(t, row.Flush(other))
+	w.paths = []string{otherPath}
+	require.EqualError(t, w.Verify(), otherPath+": 1 rows read back, but 12 were written")
+}
+
+func TestWriter_MMDB(t *testing.T) {
+	cfg := verifyConfig("mmdb", 100)
+	path := filepath.Join(t.TempDir(), "out.mmdb")
+	inner, err := writer.NewMMDBWriter(path, cfg, 4)
+	require.NoError(t, err)
+	w := NewWriter(inner, cfg, []string{path})
+	writeRows(t, w)
+
+	// An IPv4 tree has no IPv6 rows, which are not checked
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{mmdbtype.String("JP"), nil}))
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Verify())
+
+	// A record that differs from the row is caught
+	w.samples[0].row = row.Row{mmdbtype.String("CA"), mmdbtype.Uint16(0)}
+	require.ErrorContains(t, w.Verify(), path+": record of 1.0.0.0 is ")
+}
+
+func TestWriter_Sample(t *testing.T) {
+	cfg := verifyConfig("csv", 4, config.NetworkColumn{Name: "network", Type: "cidr"})
+	w := NewWriter(&discardWriter{}, cfg, []string{"out.csv"})
+	for i := range 100 {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{1, 0, byte(i), 0}), 24)
+		require.NoError(t, w.WriteRow(prefix, row.Row{mmdbtype.String("US"), nil}))
+	}
+
+	// The sample is spread over every row written
+	var starts []string
+	for _, s := range w.samples {
+		starts = append(starts, s.start.String())
+	}
+	assert.Equal(t, []string{"1.0.0.0", "1.0.32.0", "1.0.64.0", "1.0.96.0"}, starts)
+	assert.Equal(t, int64(100), w.Rows())
+}
+
+func TestWriter_SplitFiles(t *testing.T) {
+	cfg := verifyConfig("csv", 10, config.NetworkColumn{Name: "network", Type: "cidr"})
+	w := NewWriter(&discardWriter{}, cfg, []string{"ipv4.csv", "ipv6.csv"})
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{mmdbtype.String("US"), nil}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{mmdbtype.String("JP"), nil}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db9::/32"), row.Row{mmdbtype.String("JP"), nil}))
+
+	assert.Equal(t, []int64{1, 2}, w.rows)
+	assert.Equal(t, []int{1, 2}, w.fileSamples(1))
+}
+
+type discardWriter struct{}
+
+func (*discardWriter) WriteRow(netip.Prefix, row.Row) error {
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package watchdog detects a conversion that has stopped making progress,
+// such as one blocked on a network filesystem or a wedged output, so that
+// an unattended run fails instead of hanging silently.
+//
+// Progress is read from counters, such as the networks merged or the bytes
+// written, and from the stages the conversion enters. The watchdog reports a
+// stall once none of them has moved for the configured time.
+package watchdog
+
+import (
+	"sync"
+	"time"
+)
+
+// Counter is a named measure of progress that only increases, such as the
+// bytes written. It must be safe to call from the watchdog's goroutine.
+type Counter struct {
+	Name  string
+	Value func() int64
+}
+
+// Stall describes a conversion that stopped making progress.
+type Stall struct {
+	Stage    string        // Stage the conversion is stuck in
+	Duration time.Duration // Time since the last progress
+	Counters []CounterValue
+}
+
+// CounterValue is the value of a counter when the stall was detected.
+type CounterValue struct {
+	Name  string
+	Value int64
+}
+
+// Watchdog checks the counters until it is stopped. All methods of a nil
+// *Watchdog do nothing, so callers need not check whether a watchdog is
+// configured.
+type Watchdog struct {
+	timeout time.Duration
+	now     func() time.Time
+	onStall func(Stall)
+
+	mu           sync.Mutex // Guards the fields below
+	stage        string
+	counters     []Counter
+	values       []int64
+	lastProgress time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start checks for progress every tenth of timeout, and calls onStall once,
+// from its own goroutine, if there has been none for timeout. onStall
+// usually ends the process; the watchdog stops checking either way.
+func Start(timeout time.Duration, onStall func(Stall)) *Watchdog {
+	return start(timeout, max(timeout/10, time.Millisecond), time.Now, onStall)
+}
+
+func start(timeout, interval time.Duration, now func() time.Time, onStall func(Stall)) *Watchdog {
+	w := &Watchdog{
+		timeout:      timeout,
+		now:          now,
+		onStall:      onStall,
+		lastProgress: now(),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go w.loop(interval)
+	return w
+}
+
+func (w *Watchdog) loop(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if stall, ok := w.check(); ok {
+				w.onStall(stall)
+				return
+			}
+		}
+	}
+}
+
+// check reads the counters, and reports a stall if none has moved and no
+// stage has started for the timeout.
+func (w *Watchdog) check() (Stall, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	for i, counter := range w.counters {
+		if value := counter.Value(); value != w.values[i] {
+			w.values[i] = value
+			w.lastProgress = now
+		}
+	}
+	stalled := now.Sub(w.lastProgress)
+	if stalled < w.timeout {
+		return Stall{}, false
+	}
+
+	stall := Stall{
+		Stage:    w.stage,
+		Duration: stalled,
+		Counters: make([]CounterValue, len(w.counters)),
+	}
+	for i, counter := range w.counters {
+		stall.Counters[i] = CounterValue{Name: counter.Name, Value: w.values[i]}
+	}
+	return stall, true
+}
+
+// Watch adds counter to the measures of progress. Its current value is the
+// starting point, so that adding it is not progress.
+func (w *Watchdog) Watch(counter Counter) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.counters = append(w.counters, counter)
+	w.values = append(w.values, counter.Value())
+}
+
+// SetStage records that the conversion has entered stage, which counts as
+// progress.
+func (w *Watchdog) SetStage(stage string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stage = stage
+	w.lastProgress = w.now()
+}
+
+// Stop stops checking for progress. It must not be called from onStall.
+func (w *Watchdog) Stop() {
+	if w == nil {
+		return
+	}
+	select {
+	case <-w.done:
+		// Already stopped after a stall
+	default:
+		close(w.stop)
+		<-w.done
+	}
+}
@@ -0,0 +1,85 @@
+package watchdog
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog_Check(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	var written int64
+
+	// The interval is long enough that only explicit checks run.
+	w := start(time.Minute, time.Hour, now, func(Stall) {})
+	defer w.Stop()
+	w.SetStage("merge")
+	w.Watch(Counter{Name: "bytes_written", Value: func() int64 { return written }})
+
+	clock = clock.Add(50 * time.Second)
+	_, stalled := w.check()
+	assert.False(t, stalled)
+
+	// Writing is progress.
+	written = 100
+	clock = clock.Add(50 * time.Second)
+	_, stalled = w.check()
+	assert.False(t, stalled)
+
+	// So is a new stage.
+	clock = clock.Add(50 * time.Second)
+	w.SetStage("flush")
+	clock = clock.Add(50 * time.Second)
+	_, stalled = w.check()
+	assert.False(t, stalled)
+
+	clock = clock.Add(20 * time.Second)
+	stall, stalled := w.check()
+	require.True(t, stalled)
+	assert.Equal(t, Stall{
+		Stage:    "flush",
+		Duration: 70 * time.Second,
+		Counters: []CounterValue{{Name: "bytes_written", Value: 100}},
+	}, stall)
+}
+
+func TestWatchdog_Stall(t *testing.T) {
+	stalls := make(chan Stall, 1)
+	w := Start(20*time.Millisecond, func(s Stall) { stalls <- s })
+	defer w.Stop()
+	w.SetStage("merge")
+
+	select {
+	case stall := <-stalls:
+		assert.Equal(t, "merge", stall.Stage)
+		assert.GreaterOrEqual(t, stall.Duration, 20*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("no stall reported")
+	}
+}
+
+func TestWatchdog_Progress(t *testing.T) {
+	var networks atomic.Int64
+	stalled := make(chan Stall, 1)
+	w := Start(50*time.Millisecond, func(s Stall) { stalled <- s })
+	w.Watch(Counter{Name: "networks", Value: networks.Load})
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		networks.Add(1)
+		time.Sleep(time.Millisecond)
+	}
+	w.Stop()
+	assert.Empty(t, stalled, "a run making progress is not stalled")
+}
+
+func TestWatchdog_Nil(t *testing.T) {
+	var w *Watchdog
+	w.Watch(Counter{Name: "networks", Value: func() int64 { return 0 }})
+	w.SetStage("merge")
+	w.Stop()
+}
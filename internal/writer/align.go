@@ -0,0 +1,77 @@
+package writer
+
+import (
+	"net/netip"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// AlignWriter wraps a row writer and splits each range at the boundaries of
+// the networks of the configured prefix length, so that consumers indexing on
+// those networks find every row within one of them.
+type AlignWriter struct {
+	writer   row.Writer
+	ipv4Bits int
+	ipv6Bits int
+}
+
+// NewAlignWriter creates a writer splitting the ranges written to writer as
+// configured by cfg.Output.Align.
+func NewAlignWriter(writer row.Writer, cfg *config.Config) *AlignWriter {
+	return &AlignWriter{
+		writer:   writer,
+		ipv4Bits: cfg.Output.Align.IPv4,
+		ipv6Bits: cfg.Output.Align.IPv6,
+	}
+}
+
+// WriteRow writes a row, split into networks of the prefix length if it is
+// larger.
+func (a *AlignWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	bits := a.bits(prefix.Addr())
+	if bits == 0 || prefix.Bits() >= bits {
+		return a.writer.WriteRow(prefix, r)
+	}
+	return a.WriteRange(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WriteRange writes a range as one range per network of the prefix length
+// it covers.
+func (a *AlignWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	bits := a.bits(start)
+	if bits == 0 {
+		return row.WriteRange(a.writer, start, end, r)
+	}
+	for {
+		last := netipx.PrefixLastIP(netip.PrefixFrom(start, bits).Masked())
+		if end.Compare(last) <= 0 {
+			return row.WriteRange(a.writer, start, end, r)
+		}
+		if err := row.WriteRange(a.writer, start, last, r); err != nil {
+			return err
+		}
+		start = last.Next()
+	}
+}
+
+// bits returns the prefix length at whose boundaries ranges starting at addr
+// are split, or 0.
+func (a *AlignWriter) bits(addr netip.Addr) int {
+	if addr.Is4() {
+		return a.ipv4Bits
+	}
+	return a.ipv6Bits
+}
+
+// Flush flushes the wrapped writer.
+func (a *AlignWriter) Flush() error {
+	return row.Flush(a.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (a *AlignWriter) Sync() error {
+	return row.Sync(a.writer)
+}
@@ -0,0 +1,99 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func alignConfig(ipv4, ipv6 int) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{Align: config.AlignConfig{IPv4: ipv4, IPv6: ipv6}},
+	}
+}
+
+func TestAlignWriter_WriteRange(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewAlignWriter(inner, alignConfig(16, 0))
+
+	r := row.Row{mmdbtype.String("US")}
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.128.0"),
+		netip.MustParseAddr("1.2.0.255"),
+		r,
+	))
+	// Within one /16
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.3.0.0"),
+		netip.MustParseAddr("1.3.0.255"),
+		r,
+	))
+
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("1.0.128.0"), netip.MustParseAddr("1.0.255.255")},
+		{netip.MustParseAddr("1.1.0.0"), netip.MustParseAddr("1.1.255.255")},
+		{netip.MustParseAddr("1.2.0.0"), netip.MustParseAddr("1.2.0.255")},
+		{netip.MustParseAddr("1.3.0.0"), netip.MustParseAddr("1.3.0.255")},
+	}, inner.ranges)
+	assert.Len(t, inner.rangeData, 4)
+}
+
+func TestAlignWriter_LastNetwork(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewAlignWriter(inner, alignConfig(8, 0))
+
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("254.255.0.0"),
+		netip.MustParseAddr("255.255.255.255"),
+		row.Row{mmdbtype.String("ZZ")},
+	))
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("254.255.0.0"), netip.MustParseAddr("254.255.255.255")},
+		{netip.MustParseAddr("255.0.0.0"), netip.MustParseAddr("255.255.255.255")},
+	}, inner.ranges)
+}
+
+func TestAlignWriter_WriteRow(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewAlignWriter(inner, alignConfig(24, 32))
+
+	r := row.Row{mmdbtype.String("SE")}
+	// Smaller than the prefix length, so written as is
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/28"), r))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/48"), r))
+	// Larger, so split
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2.0.0.0/23"), r))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/31"), r))
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("1.0.0.0/28"),
+		netip.MustParsePrefix("2001:db8::/48"),
+	}, inner.rows)
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("2.0.0.0"), netip.MustParseAddr("2.0.0.255")},
+		{netip.MustParseAddr("2.0.1.0"), netip.MustParseAddr("2.0.1.255")},
+		{netip.MustParseAddr("2001:db8::"), netip.MustParseAddr("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff")},
+		{netip.MustParseAddr("2001:db9::"), netip.MustParseAddr("2001:db9:ffff:ffff:ffff:ffff:ffff:ffff")},
+	}, inner.ranges)
+}
+
+func TestAlignWriter_Disabled(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	// IPv6 ranges are not split
+	w := NewAlignWriter(inner, alignConfig(16, 0))
+
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2001:db8::"),
+		netip.MustParseAddr("2001:dba::ffff"),
+		row.Row{mmdbtype.String("JP")},
+	))
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("2001:db8::"), netip.MustParseAddr("2001:dba::ffff")},
+	}, inner.ranges)
+}
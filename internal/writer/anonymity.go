@@ -0,0 +1,149 @@
+package writer
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"slices"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// AnonymityWriter wraps a row writer and holds back the rows of ranges that
+// cover fewer addresses than output.anonymity allows. Such rows are dropped
+// or, when generalizing, have the precise columns cleared and are joined
+// with adjacent small rows left with equal values; a joined range that is
+// still too small is dropped.
+type AnonymityWriter struct {
+	writer     row.Writer
+	ipv4Min    *big.Int // Minimum addresses of IPv4 ranges
+	ipv6Min    *big.Int // Minimum addresses of IPv6 ranges
+	generalize bool
+	cleared    []int // Indexes of the columns cleared when generalizing
+
+	pending    row.Row
+	start, end netip.Addr
+	dropped    int
+}
+
+// NewAnonymityWriter creates a writer applying cfg.Output.Anonymity to the
+// rows written to writer.
+func NewAnonymityWriter(writer row.Writer, cfg *config.Config) (*AnonymityWriter, error) {
+	anonymity := cfg.Output.Anonymity
+	cleared := make([]int, len(anonymity.Generalize))
+	for i, name := range anonymity.Generalize {
+		cleared[i] = slices.IndexFunc(cfg.Columns, func(col config.Column) bool {
+			return string(col.Name) == name
+		})
+		if cleared[i] < 0 {
+			return nil, fmt.Errorf("output.anonymity.generalize: '%s' is not a configured data column", name)
+		}
+	}
+
+	a := &AnonymityWriter{
+		writer:     writer,
+		ipv4Min:    big.NewInt(anonymity.MinAddresses),
+		ipv6Min:    big.NewInt(anonymity.MinAddresses),
+		generalize: anonymity.Action == config.AnonymityGeneralize,
+		cleared:    cleared,
+	}
+	if anonymity.IPv6MaxPrefixLength > 0 {
+		a.ipv6Min = new(big.Int).Lsh(big.NewInt(1), uint(128-anonymity.IPv6MaxPrefixLength))
+	}
+	return a, nil
+}
+
+// WriteRow writes a row if its network is large enough, and otherwise drops
+// or generalizes it.
+func (a *AnonymityWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	start, end := prefix.Addr(), netipx.PrefixLastIP(prefix)
+	if !a.large(start, end) {
+		return a.writeSmall(start, end, r)
+	}
+	if err := a.writePending(); err != nil {
+		return err
+	}
+	return a.writer.WriteRow(prefix, r)
+}
+
+// WriteRange writes a range if it is large enough, and otherwise drops or
+// generalizes it.
+func (a *AnonymityWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !a.large(start, end) {
+		return a.writeSmall(start, end, r)
+	}
+	if err := a.writePending(); err != nil {
+		return err
+	}
+	return row.WriteRange(a.writer, start, end, r)
+}
+
+// writeSmall drops a range that is too small or, when generalizing, joins it
+// to the pending range.
+func (a *AnonymityWriter) writeSmall(start, end netip.Addr, r row.Row) error {
+	if !a.generalize {
+		a.dropped++
+		return nil
+	}
+
+	generalized := slices.Clone(r)
+	for _, i := range a.cleared {
+		generalized[i] = nil
+	}
+	if a.pending != nil && a.end.Next() == start && a.pending.Equal(generalized) {
+		a.end = end
+		return nil
+	}
+	if err := a.writePending(); err != nil {
+		return err
+	}
+	a.pending, a.start, a.end = generalized, start, end
+	return nil
+}
+
+// writePending writes the pending generalized range, if it is large enough.
+func (a *AnonymityWriter) writePending() error {
+	pending := a.pending
+	a.pending = nil
+	if pending == nil {
+		return nil
+	}
+	if !a.large(a.start, a.end) {
+		a.dropped++
+		return nil
+	}
+	return row.WriteRange(a.writer, a.start, a.end, pending)
+}
+
+// large reports whether the range from start to end covers enough
+// addresses.
+func (a *AnonymityWriter) large(start, end netip.Addr) bool {
+	minimum := a.ipv6Min
+	if start.Is4() {
+		minimum = a.ipv4Min
+	}
+	return network.RangeSize(start, end).Cmp(minimum) >= 0
+}
+
+// Dropped returns the number of ranges dropped as too small, after joining.
+func (a *AnonymityWriter) Dropped() int {
+	return a.dropped
+}
+
+// Flush writes the pending range and flushes the wrapped writer.
+func (a *AnonymityWriter) Flush() error {
+	if err := a.writePending(); err != nil {
+		return err
+	}
+	return row.Flush(a.writer)
+}
+
+// Sync syncs the wrapped writer. The pending range is written later, as it
+// may still be joined.
+func (a *AnonymityWriter) Sync() error {
+	return row.Sync(a.writer)
+}
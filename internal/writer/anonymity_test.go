@@ -0,0 +1,110 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func anonymityConfig(anonymity config.AnonymityConfig) *config.Config {
+	return &config.Config{
+		Output:  config.OutputConfig{Anonymity: anonymity},
+		Columns: []config.Column{{Name: "country"}, {Name: "city"}},
+	}
+}
+
+func TestAnonymityWriter_Drop(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w, err := NewAnonymityWriter(inner, anonymityConfig(config.AnonymityConfig{
+		MinAddresses: 256,
+		Action:       config.AnonymityDrop,
+	}))
+	require.NoError(t, err)
+
+	r := row.Row{mmdbtype.String("US"), mmdbtype.String("Chicago")}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.1.0/25"), r))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.1.128"),
+		netip.MustParseAddr("1.0.2.127"),
+		r,
+	))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.2.128"),
+		netip.MustParseAddr("1.0.2.255"),
+		r,
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, inner.rows)
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("1.0.1.128"), netip.MustParseAddr("1.0.2.127")},
+	}, inner.ranges)
+	assert.Equal(t, 2, w.Dropped())
+}
+
+func TestAnonymityWriter_Generalize(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w, err := NewAnonymityWriter(inner, anonymityConfig(config.AnonymityConfig{
+		MinAddresses: 256,
+		Action:       config.AnonymityGeneralize,
+		Generalize:   []string{"city"},
+	}))
+	require.NoError(t, err)
+
+	us := func(city string) row.Row {
+		return row.Row{mmdbtype.String("US"), mmdbtype.String(city)}
+	}
+	// Two small networks of different cities, joined once generalized
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/25"), us("Chicago")))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.128/25"), us("Denver")))
+	// Large enough, so kept as is
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.1.0/24"), us("Boston")))
+	// Small, and still small once generalized
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.2.0/26"), us("Austin")))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.255")},
+	}, inner.ranges)
+	assert.Equal(t, [][]mmdbtype.DataType{{mmdbtype.String("US"), nil}}, inner.rangeData)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.1.0/24")}, inner.rows)
+	assert.Equal(t, []mmdbtype.DataType{mmdbtype.String("US"), mmdbtype.String("Boston")}, inner.data[0])
+	assert.Equal(t, 1, w.Dropped())
+}
+
+func TestAnonymityWriter_IPv6MaxPrefixLength(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w, err := NewAnonymityWriter(inner, anonymityConfig(config.AnonymityConfig{
+		MinAddresses:        256,
+		IPv6MaxPrefixLength: 48,
+		Action:              config.AnonymityDrop,
+	}))
+	require.NoError(t, err)
+
+	r := row.Row{mmdbtype.String("JP"), nil}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/48"), r))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8:1::/56"), r))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("2001:db8::/48"),
+		netip.MustParsePrefix("1.0.0.0/24"),
+	}, inner.rows)
+}
+
+func TestNewAnonymityWriter_UnknownColumn(t *testing.T) {
+	_, err := NewAnonymityWriter(&rangeRecordWriter{}, anonymityConfig(config.AnonymityConfig{
+		MinAddresses: 256,
+		Action:       config.AnonymityGeneralize,
+		Generalize:   []string{"postal_code"},
+	}))
+	require.EqualError(t, err, "output.anonymity.generalize: 'postal_code' is not a configured data column")
+}
@@ -0,0 +1,135 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// asyncBufferSize is the number of rows that may be queued for an
+// AsyncWriter before writes block.
+const asyncBufferSize = 1024
+
+// asyncWrite is a row queued for an AsyncWriter. A valid prefix marks a
+// WriteRow call; otherwise start and end hold a range.
+type asyncWrite struct {
+	prefix     netip.Prefix
+	start, end netip.Addr
+	r          row.Row
+}
+
+// AsyncWriter passes rows to a writer running in its own goroutine, so that
+// writers for independent outputs, such as the IPv4 and IPv6 trees of split
+// MMDB output, can be built concurrently. Writes are queued and report
+// errors from earlier rows; Flush waits for the queue to drain and then
+// flushes the wrapped writer in the same goroutine. Close stops the
+// goroutine of a writer that is not flushed, such as when the merge fails.
+type AsyncWriter struct {
+	writer  row.Writer
+	rows    chan asyncWrite
+	done    chan struct{}
+	flushed bool // Flushed or closed
+
+	mu  sync.Mutex
+	err error // First error returned by the wrapped writer
+}
+
+// NewAsyncWriter starts a goroutine that writes rows to w. Flush or Close
+// must be called to stop it.
+func NewAsyncWriter(w row.Writer) *AsyncWriter {
+	a := &AsyncWriter{
+		writer: w,
+		rows:   make(chan asyncWrite, asyncBufferSize),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// WriteRow queues a row for the wrapped writer.
+func (a *AsyncWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return a.queue(asyncWrite{prefix: prefix, r: r})
+}
+
+// WriteRange queues a range for the wrapped writer.
+func (a *AsyncWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	return a.queue(asyncWrite{start: start, end: end, r: r})
+}
+
+// Flush waits for all queued rows to be written and then flushes the wrapped
+// writer. No rows may be written afterwards.
+func (a *AsyncWriter) Flush() error {
+	if a.flushed {
+		return errors.New("async writer already flushed")
+	}
+	a.flushed = true
+	close(a.rows)
+	<-a.done
+
+	if err := a.firstError(); err != nil {
+		return err
+	}
+	return row.Flush(a.writer)
+}
+
+// Close stops the goroutine without flushing the wrapped writer, discarding
+// the queued rows. It does nothing once the writer is flushed or closed.
+func (a *AsyncWriter) Close() error {
+	if a.flushed {
+		return nil
+	}
+	a.flushed = true
+	a.mu.Lock()
+	if a.err == nil {
+		a.err = errors.New("async writer closed")
+	}
+	a.mu.Unlock()
+	close(a.rows)
+	<-a.done
+	return nil
+}
+
+func (a *AsyncWriter) queue(w asyncWrite) error {
+	if a.flushed {
+		return errors.New("write after flush or close")
+	}
+	if err := a.firstError(); err != nil {
+		return err
+	}
+	// The row is only valid during the call, so queue a copy
+	w.r = slices.Clone(w.r)
+	a.rows <- w
+	return nil
+}
+
+// run writes queued rows until the queue is closed. After an error, the
+// remaining rows are discarded so that the sender never blocks.
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	for w := range a.rows {
+		if a.firstError() != nil {
+			continue
+		}
+		var err error
+		if w.prefix.IsValid() {
+			err = a.writer.WriteRow(w.prefix, w.r)
+		} else {
+			err = row.WriteRange(a.writer, w.start, w.end, w.r)
+		}
+		if err != nil {
+			a.mu.Lock()
+			a.err = fmt.Errorf("writing in background: %w", err)
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *AsyncWriter) firstError() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
@@ -0,0 +1,140 @@
+package writer
+
+import (
+	"errors"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestAsyncWriter_WritesInOrder(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewAsyncWriter(inner)
+
+	// The caller reuses its row, as the merger does
+	data := row.Row{mmdbtype.String("US")}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("10.0.0.0/24"), data))
+	data[0] = mmdbtype.String("CA")
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("10.0.1.0"),
+		netip.MustParseAddr("10.0.1.9"),
+		data,
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")}, inner.rows)
+	assert.Equal(t, [][]mmdbtype.DataType{{mmdbtype.String("US")}}, inner.data)
+	assert.Equal(t, [][2]netip.Addr{{
+		netip.MustParseAddr("10.0.1.0"),
+		netip.MustParseAddr("10.0.1.9"),
+	}}, inner.ranges)
+	assert.Equal(t, [][]mmdbtype.DataType{{mmdbtype.String("CA")}}, inner.rangeData)
+}
+
+func TestAsyncWriter_PropagatesErrors(t *testing.T) {
+	inner := &rangeRecordWriter{writeRowE: errors.New("tree full")}
+	w := NewAsyncWriter(inner)
+
+	prefix := netip.MustParsePrefix("10.0.0.0/24")
+	require.NoError(t, w.WriteRow(prefix, nil), "errors are reported by later calls")
+
+	err := w.Flush()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tree full")
+
+	err = w.WriteRow(prefix, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "write after flush or close")
+	require.Error(t, w.Flush())
+}
+
+func TestAsyncWriter_FlushesWrappedWriter(t *testing.T) {
+	inner := &recordWriter{flushE: errors.New("flush failure")}
+	w := NewAsyncWriter(inner)
+
+	err := w.Flush()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "flush failure")
+}
+
+func TestAsyncWriter_Close(t *testing.T) {
+	inner := &rangeRecordWriter{writeRowE: errors.New("tree full")}
+	w := NewAsyncWriter(inner)
+
+	// The run fails before flushing, e.g., when the merge fails
+	prefix := netip.MustParsePrefix("10.0.0.0/24")
+	require.NoError(t, w.WriteRow(prefix, nil))
+	require.NoError(t, w.Close())
+
+	select {
+	case <-w.done:
+	default:
+		t.Fatal("Close returned before the goroutine exited")
+	}
+	require.NoError(t, w.Close(), "closing again does nothing")
+	err := w.WriteRow(prefix, nil)
+	require.ErrorContains(t, err, "write after flush or close")
+	require.Error(t, w.Flush())
+
+	// Closing a flushed writer does nothing
+	flushed := NewAsyncWriter(&recordWriter{})
+	require.NoError(t, flushed.Flush())
+	require.NoError(t, flushed.Close())
+}
+
+func TestSplitMMDBOutput(t *testing.T) {
+	recordSize := 24
+	includeReserved := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			MMDB: config.MMDBConfig{
+				DatabaseType:            "Test",
+				RecordSize:              &recordSize,
+				IncludeReservedNetworks: &includeReserved,
+			},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	dir := t.TempDir()
+	ipv4Path := filepath.Join(dir, "out_ipv4.mmdb")
+	ipv6Path := filepath.Join(dir, "out_ipv6.mmdb")
+	ipv4Writer, err := NewMMDBWriter(ipv4Path, cfg, 4)
+	require.NoError(t, err)
+	ipv6Writer, err := NewMMDBWriter(ipv6Path, cfg, 6)
+	require.NoError(t, err)
+
+	w := NewSplitRowWriter(NewAsyncWriter(ipv4Writer), NewAsyncWriter(ipv6Writer))
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("AU")},
+	))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2a02::"),
+		netip.MustParseAddr("2a02::ff"),
+		row.Row{mmdbtype.String("DE")},
+	))
+	require.NoError(t, w.Flush())
+
+	lookup := func(path, ip string) map[string]any {
+		reader, err := maxminddb.Open(path)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		var record map[string]any
+		require.NoError(t, reader.Lookup(netip.MustParseAddr(ip)).Decode(&record))
+		return record
+	}
+
+	assert.Equal(t, map[string]any{"country": "AU"}, lookup(ipv4Path, "1.0.0.1"))
+	assert.Equal(t, map[string]any{"country": "DE"}, lookup(ipv6Path, "2a02::1"))
+	assert.Nil(t, lookup(ipv6Path, "1.0.0.1"), "IPv4 rows only go to the IPv4 tree")
+}
@@ -0,0 +1,339 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"go4.org/netipx"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// bigQueryBatchSize is the number of serialized bytes of rows that triggers
+// an append, well below the 10 MB limit of a request.
+const bigQueryBatchSize = 4 << 20
+
+// BigQueryWriter streams rows into a BigQuery table with the Storage Write
+// API, as protobuf messages appended to a pending stream that is committed
+// when the writer is flushed. Until then, readers of the table see its
+// previous rows, and the rows of a writer closed without being flushed are
+// discarded.
+type BigQueryWriter struct {
+	ctx          context.Context
+	client       *managedwriter.Client
+	stream       *managedwriter.ManagedStream
+	config       *config.Config
+	rangeCapable bool
+	encoder      protobufEncoder
+	send         func(rows [][]byte) error
+	rows         [][]byte                      // Serialized rows not yet appended
+	size         int                           // Bytes of rows
+	appends      []*managedwriter.AppendResult // Appends not yet known to succeed
+}
+
+// NewBigQueryWriter creates output.bigquery.table from the columns unless it
+// exists, and opens a pending stream appending rows to it. Credentials are
+// found as by the Google Cloud client libraries, from
+// GOOGLE_APPLICATION_CREDENTIALS or the environment.
+func NewBigQueryWriter(ctx context.Context, cfg *config.Config) (*BigQueryWriter, error) {
+	bq := cfg.Output.BigQuery
+	schema := bigQuerySchema(cfg)
+	if err := createBigQueryTable(ctx, cfg, schema); err != nil {
+		return nil, err
+	}
+	message, err := bigQueryMessage(schema)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := adapt.NormalizeDescriptor(message)
+	if err != nil {
+		return nil, fmt.Errorf("building BigQuery row descriptor: %w", err)
+	}
+
+	client, err := managedwriter.NewClient(ctx, bq.Project)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to BigQuery: %w", err)
+	}
+	stream, err := client.NewManagedStream(
+		ctx,
+		managedwriter.WithDestinationTable(
+			managedwriter.TableParentFromParts(bq.Project, bq.Dataset, bq.Table),
+		),
+		managedwriter.WithType(managedwriter.PendingStream),
+		managedwriter.WithSchemaDescriptor(descriptor),
+	)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("opening BigQuery write stream: %w", err)
+	}
+
+	w := newBigQueryWriter(cfg, nil)
+	w.ctx = ctx
+	w.client = client
+	w.stream = stream
+	w.send = w.appendRows
+	return w, nil
+}
+
+// newBigQueryWriter creates a writer passing each batch of serialized rows
+// to send, without a stream.
+func newBigQueryWriter(cfg *config.Config, send func(rows [][]byte) error) *BigQueryWriter {
+	return &BigQueryWriter{
+		config: cfg,
+		rangeCapable: !slices.ContainsFunc(cfg.Network.Columns, func(col config.NetworkColumn) bool {
+			return col.Type == NetworkColumnCIDR
+		}),
+		encoder: newProtobufEncoder(cfg),
+		send:    send,
+	}
+}
+
+// createBigQueryTable creates the table of the BigQuery output of cfg with
+// schema, unless it exists.
+func createBigQueryTable(ctx context.Context, cfg *config.Config, schema bigquery.Schema) error {
+	bq := cfg.Output.BigQuery
+	client, err := bigquery.NewClient(ctx, bq.Project)
+	if err != nil {
+		return fmt.Errorf("connecting to BigQuery: %w", err)
+	}
+	defer client.Close()
+
+	table := client.Dataset(bq.Dataset).Table(bq.Table)
+	err = table.Create(ctx, &bigquery.TableMetadata{Schema: schema})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("creating table %s.%s: %w", bq.Dataset, bq.Table, err)
+	}
+	return nil
+}
+
+// bigQuerySchema returns the schema of the table of the BigQuery output of
+// cfg. Network columns are required strings, except integer columns, which
+// are the bytes of the address in network order, as NET.IP_FROM_STRING
+// returns them, decimal columns, which are BIGNUMERIC, and validity
+// columns, which are null outside history output. Data columns are nullable strings unless a type hint is set.
+func bigQuerySchema(cfg *config.Config) bigquery.Schema {
+	schema := make(bigquery.Schema, 0, len(cfg.Network.Columns)+len(cfg.Columns))
+	for _, col := range cfg.Network.Columns {
+		schema = append(schema, &bigquery.FieldSchema{
+			Name:     col.OutputName("bigquery"),
+			Type:     bigQueryNetworkType(col.Type),
+			Required: col.Type != NetworkColumnValidFrom && col.Type != NetworkColumnValidTo,
+		})
+	}
+	for _, col := range cfg.Columns {
+		schema = append(schema, &bigquery.FieldSchema{
+			Name: col.OutputName("bigquery"),
+			Type: bigQueryDataType(col.Type),
+		})
+	}
+	return schema
+}
+
+// bigQueryNetworkType returns the BigQuery type of a network column.
+func bigQueryNetworkType(colType string) bigquery.FieldType {
+	switch colType {
+	case NetworkColumnStartInt, NetworkColumnEndInt:
+		return bigquery.BytesFieldType
+	case NetworkColumnStartDecimal, NetworkColumnEndDecimal:
+		// NUMERIC holds 29 integer digits, too few for IPv6
+		return bigquery.BigNumericFieldType
+	default:
+		return bigquery.StringFieldType
+	}
+}
+
+// bigQueryDataType returns the BigQuery type of a data column with a type
+// hint.
+func bigQueryDataType(typeHint string) bigquery.FieldType {
+	switch typeHint {
+	case "int64":
+		return bigquery.IntegerFieldType
+	case "float64":
+		return bigquery.FloatFieldType
+	case "bool":
+		return bigquery.BooleanFieldType
+	case "binary":
+		return bigquery.BytesFieldType
+	default:
+		return bigquery.StringFieldType
+	}
+}
+
+// bigQueryMessage returns the descriptor of the protobuf messages the rows
+// of schema are appended as. Its fields are numbered in column order, as
+// protobufEncoder numbers them.
+func bigQueryMessage(schema bigquery.Schema) (protoreflect.MessageDescriptor, error) {
+	tableSchema, err := adapt.BQSchemaToStorageTableSchema(schema)
+	if err != nil {
+		return nil, fmt.Errorf("converting BigQuery schema: %w", err)
+	}
+	// BIGNUMERIC values are sent as the decimal strings protobufEncoder
+	// writes, which the Storage Write API converts, rather than as scaled
+	// bytes
+	for _, field := range tableSchema.GetFields() {
+		if field.GetType() == storagepb.TableFieldSchema_BIGNUMERIC {
+			field.Type = storagepb.TableFieldSchema_STRING
+		}
+	}
+	descriptor, err := adapt.StorageSchemaToProto2Descriptor(tableSchema, "root")
+	if err != nil {
+		return nil, fmt.Errorf("building BigQuery row descriptor: %w", err)
+	}
+	message, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, errors.New("building BigQuery row descriptor: not a message")
+	}
+	return message, nil
+}
+
+// WriteRow writes a single row with network prefix and column data.
+func (w *BigQueryWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeMessage(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *BigQueryWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, writing a single row unless a
+// network column is a CIDR.
+func (w *BigQueryWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		for _, cidr := range network.RangeToPrefixes(start, end) {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.writeMessage(netip.Prefix{}, start, end, r)
+}
+
+// writeMessage serializes a row and adds it to the batch. prefix is invalid
+// for range rows.
+func (w *BigQueryWriter) writeMessage(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	msg, err := w.encoder.encode(prefix, start, end, r)
+	if err != nil {
+		return err
+	}
+	// The stream holds on to the rows until they are acknowledged
+	w.rows = append(w.rows, bytes.Clone(msg))
+	w.size += len(msg)
+	if w.size >= bigQueryBatchSize {
+		return w.sendBatch()
+	}
+	return nil
+}
+
+// sendBatch passes the batched rows on.
+func (w *BigQueryWriter) sendBatch() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	if err := w.send(w.rows); err != nil {
+		return err
+	}
+	w.rows = nil
+	w.size = 0
+	return nil
+}
+
+// appendRows appends rows to the stream without waiting for the append to
+// complete, failing if an earlier append did.
+func (w *BigQueryWriter) appendRows(rows [][]byte) error {
+	if err := w.checkAppends(false); err != nil {
+		return err
+	}
+	result, err := w.stream.AppendRows(w.ctx, rows)
+	if err != nil {
+		return fmt.Errorf("appending rows to BigQuery: %w", err)
+	}
+	w.appends = append(w.appends, result)
+	return nil
+}
+
+// checkAppends forgets the appends that completed, in order, failing at the
+// first that did not succeed. If wait is set, it waits for every append to
+// complete.
+func (w *BigQueryWriter) checkAppends(wait bool) error {
+	for len(w.appends) > 0 {
+		if !wait {
+			select {
+			case <-w.appends[0].Ready():
+			default:
+				return nil
+			}
+		}
+		if _, err := w.appends[0].GetResult(w.ctx); err != nil {
+			return fmt.Errorf("appending rows to BigQuery: %w", err)
+		}
+		w.appends = w.appends[1:]
+	}
+	return nil
+}
+
+// Flush appends the remaining rows, waits for every append to complete,
+// and commits the stream, which adds its rows to the table at once. Later
+// calls do nothing.
+func (w *BigQueryWriter) Flush() error {
+	if err := w.sendBatch(); err != nil {
+		return err
+	}
+	if w.stream == nil {
+		return nil
+	}
+	defer w.Close()
+
+	if err := w.checkAppends(true); err != nil {
+		return err
+	}
+	if _, err := w.stream.Finalize(w.ctx); err != nil {
+		return fmt.Errorf("committing rows to BigQuery: %w", err)
+	}
+	resp, err := w.client.BatchCommitWriteStreams(w.ctx, &storagepb.BatchCommitWriteStreamsRequest{
+		Parent:       managedwriter.TableParentFromStreamName(w.stream.StreamName()),
+		WriteStreams: []string{w.stream.StreamName()},
+	})
+	if err != nil {
+		return fmt.Errorf("committing rows to BigQuery: %w", err)
+	}
+	if streamErrors := resp.GetStreamErrors(); len(streamErrors) > 0 {
+		return fmt.Errorf("committing rows to BigQuery: %s", streamErrors[0].GetErrorMessage())
+	}
+	return nil
+}
+
+// Close closes the stream and the client. The rows of a stream that was not
+// committed are discarded.
+func (w *BigQueryWriter) Close() error {
+	if w.stream == nil {
+		return nil
+	}
+	w.rows = nil
+	w.appends = nil
+	err := w.stream.Close()
+	if closeErr := w.client.Close(); err == nil {
+		err = closeErr
+	}
+	w.stream = nil
+	return err
+}
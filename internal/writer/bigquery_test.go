@@ -0,0 +1,189 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func bigQueryConfig(network ...config.NetworkColumn) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{
+			BigQuery: config.BigQueryConfig{Project: "geo-project", Dataset: "geo", Table: "networks"},
+		},
+		Network: config.NetworkConfig{Columns: network},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "accuracy", Type: "int64"},
+			{Name: "latitude", Type: "float64"},
+			{Name: "is_eu", Type: "bool", Aliases: config.Aliases{SQLName: "in_eu"}},
+		},
+	}
+}
+
+// readBigQueryRows decodes batches of rows with the descriptor of the table
+// of cfg, into their fields by name.
+func readBigQueryRows(t *testing.T, cfg *config.Config, batches [][][]byte) []map[string]any {
+	t.Helper()
+	message, err := bigQueryMessage(bigQuerySchema(cfg))
+	require.NoError(t, err)
+
+	var rows []map[string]any
+	for _, batch := range batches {
+		for _, data := range batch {
+			msg := dynamicpb.NewMessage(message)
+			require.NoError(t, proto.Unmarshal(data, msg))
+			fields := map[string]any{}
+			msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+				fields[string(fd.Name())] = v.Interface()
+				return true
+			})
+			rows = append(rows, fields)
+		}
+	}
+	return rows
+}
+
+func TestBigQuerySchema(t *testing.T) {
+	cfg := bigQueryConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+		config.NetworkColumn{Name: "valid_from", Type: NetworkColumnValidFrom},
+	)
+	assert.Equal(t, bigquery.Schema{
+		{Name: "network", Type: bigquery.StringFieldType, Required: true},
+		{Name: "start_int", Type: bigquery.BytesFieldType, Required: true},
+		{Name: "valid_from", Type: bigquery.StringFieldType},
+		{Name: "country", Type: bigquery.StringFieldType},
+		{Name: "accuracy", Type: bigquery.IntegerFieldType},
+		{Name: "latitude", Type: bigquery.FloatFieldType},
+		{Name: "in_eu", Type: bigquery.BooleanFieldType},
+	}, bigQuerySchema(cfg))
+}
+
+func TestBigQueryWriter_Decimal(t *testing.T) {
+	cfg := bigQueryConfig(
+		config.NetworkColumn{Name: "start_decimal", Type: NetworkColumnStartDecimal},
+		config.NetworkColumn{Name: "end_decimal", Type: NetworkColumnEndDecimal},
+	)
+	schema := bigQuerySchema(cfg)
+	assert.Equal(t, bigquery.BigNumericFieldType, schema[0].Type)
+	assert.Equal(t, bigquery.BigNumericFieldType, schema[1].Type)
+
+	// The values are sent as decimal strings
+	var batches [][][]byte
+	w := newBigQueryWriter(cfg, func(rows [][]byte) error {
+		batches = append(batches, rows)
+		return nil
+	})
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2001:db8::"),
+		netip.MustParseAddr("2001:db8::ff"),
+		row.Row{nil, nil, nil, nil},
+	))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []map[string]any{{
+		"start_decimal": "42540766411282592856903984951653826560",
+		"end_decimal":   "42540766411282592856903984951653826815",
+	}}, readBigQueryRows(t, cfg, batches))
+}
+
+func TestBigQueryWriter(t *testing.T) {
+	cfg := bigQueryConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+	)
+	var batches [][][]byte
+	w := newBigQueryWriter(cfg, func(rows [][]byte) error {
+		batches = append(batches, rows)
+		return nil
+	})
+	assert.False(t, w.WritesRanges())
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("DE"),
+		mmdbtype.Uint16(20),
+		mmdbtype.Float64(51.5),
+		mmdbtype.Bool(false),
+	}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{
+		nil,
+		mmdbtype.Int32(-1),
+		nil,
+		nil,
+	}))
+	require.Empty(t, batches)
+	require.NoError(t, w.Flush())
+
+	// The rows are sent together, and each is its own message
+	require.Len(t, batches, 1)
+	assert.Equal(t, []map[string]any{
+		{
+			"network":   "1.0.0.0/24",
+			"start_int": []byte{1, 0, 0, 0},
+			"country":   "DE",
+			"accuracy":  int64(20),
+			"latitude":  51.5,
+			"in_eu":     false,
+		},
+		{
+			"network":   "2001:db8::/32",
+			"start_int": netip.MustParseAddr("2001:db8::").AsSlice(),
+			"accuracy":  int64(-1),
+		},
+	}, readBigQueryRows(t, cfg, batches))
+
+	// Nothing is left to send
+	require.NoError(t, w.Flush())
+	assert.Len(t, batches, 1)
+}
+
+func TestBigQueryWriter_Ranges(t *testing.T) {
+	cfg := bigQueryConfig(
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "end_ip", Type: NetworkColumnEndIP},
+	)
+	var batches [][][]byte
+	w := newBigQueryWriter(cfg, func(rows [][]byte) error {
+		batches = append(batches, rows)
+		return nil
+	})
+	require.True(t, w.WritesRanges())
+
+	r := row.Row{mmdbtype.String("DE"), nil, nil, nil}
+	require.NoError(t, w.WriteRange(netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.9"), r))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []map[string]any{
+		{"start_ip": "1.0.0.0", "end_ip": "1.0.0.9", "country": "DE"},
+	}, readBigQueryRows(t, cfg, batches))
+}
+
+func TestBigQueryWriter_InvalidValue(t *testing.T) {
+	cfg := bigQueryConfig(config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR})
+	var batches [][][]byte
+	w := newBigQueryWriter(cfg, func(rows [][]byte) error {
+		batches = append(batches, rows)
+		return nil
+	})
+	err := w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("DE"),
+		mmdbtype.String("twenty"),
+		nil,
+		nil,
+	})
+	require.ErrorContains(t, err, "converting column 'accuracy'")
+
+	// The failed row leaves nothing behind
+	require.NoError(t, w.Flush())
+	assert.Empty(t, batches)
+}
@@ -0,0 +1,104 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// CheckWriter wraps an output writer and verifies the network of every row
+// before writing it. A range must convert to CIDRs that cover exactly the
+// range, and neither the range nor any CIDR may mix IPv4 and IPv6. A
+// violation fails the export rather than writing wrong networks.
+type CheckWriter struct {
+	writer row.Writer
+}
+
+// NewCheckWriter creates a writer verifying the networks of the rows written
+// to writer.
+func NewCheckWriter(writer row.Writer) *CheckWriter {
+	return &CheckWriter{writer: writer}
+}
+
+// WriteRow checks that prefix is a valid, masked network and writes the row.
+func (c *CheckWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if !prefix.IsValid() {
+		return fmt.Errorf("check failed: invalid network %s", prefix)
+	}
+	if prefix != prefix.Masked() {
+		return fmt.Errorf("check failed: network %s has host bits set", prefix)
+	}
+	return c.writer.WriteRow(prefix, r)
+}
+
+// WriteRange checks the CIDRs of a range and writes it.
+func (c *CheckWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if err := CheckRange(start, end); err != nil {
+		return fmt.Errorf("check failed: %w", err)
+	}
+	return row.WriteRange(c.writer, start, end, r)
+}
+
+// Flush flushes the wrapped writer.
+func (c *CheckWriter) Flush() error {
+	return row.Flush(c.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (c *CheckWriter) Sync() error {
+	return row.Sync(c.writer)
+}
+
+// CheckRange verifies that the CIDRs of the range from start to end, as
+// writers compute them, reconstruct the range exactly: they are masked, of
+// the range's IP version, and follow each other without gaps or overlaps
+// from start to end.
+func CheckRange(start, end netip.Addr) error {
+	if !start.IsValid() || !end.IsValid() {
+		return fmt.Errorf("invalid range %s-%s", start, end)
+	}
+	if start.Is4() != end.Is4() {
+		return fmt.Errorf("range %s-%s crosses the IPv4/IPv6 boundary", start, end)
+	}
+	if end.Less(start) {
+		return fmt.Errorf("range %s-%s ends before it starts", start, end)
+	}
+
+	prefixes := network.RangeToPrefixes(start, end)
+	if len(prefixes) == 0 {
+		return fmt.Errorf("range %s-%s converts to no networks", start, end)
+	}
+	next := start
+	for i, prefix := range prefixes {
+		if !prefix.IsValid() || prefix != prefix.Masked() {
+			return fmt.Errorf("network %s of range %s-%s is not a masked network", prefix, start, end)
+		}
+		if prefix.Addr().Is4() != start.Is4() {
+			return fmt.Errorf(
+				"network %s of range %s-%s crosses the IPv4/IPv6 boundary",
+				prefix, start, end,
+			)
+		}
+		if prefix.Addr() != next {
+			return fmt.Errorf(
+				"network %s of range %s-%s starts at %s, expected %s",
+				prefix, start, end, prefix.Addr(), next,
+			)
+		}
+		last := netipx.PrefixLastIP(prefix)
+		if i == len(prefixes)-1 {
+			if last != end {
+				return fmt.Errorf("networks of range %s-%s end at %s", start, end, last)
+			}
+			break
+		}
+		if next = last.Next(); !next.IsValid() {
+			return fmt.Errorf("networks of range %s-%s continue past %s", start, end, last)
+		}
+	}
+	return nil
+}
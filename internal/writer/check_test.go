@@ -0,0 +1,94 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestCheckRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		start, end  string
+		expectError string
+	}{
+		{name: "single network", start: "1.0.0.0", end: "1.0.0.255"},
+		{name: "unaligned", start: "1.0.0.3", end: "1.0.2.7"},
+		{name: "single address", start: "2001:db8::1", end: "2001:db8::1"},
+		{name: "whole IPv4 space", start: "0.0.0.0", end: "255.255.255.255"},
+		{name: "end of IPv6 space", start: "ffff::", end: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{
+			name:        "IPv4 to IPv6",
+			start:       "255.255.255.0",
+			end:         "::1",
+			expectError: "range 255.255.255.0-::1 crosses the IPv4/IPv6 boundary",
+		},
+		{
+			name:        "IPv4-mapped end",
+			start:       "1.0.0.0",
+			end:         "::ffff:1.0.0.255",
+			expectError: "crosses the IPv4/IPv6 boundary",
+		},
+		{
+			name:        "reversed",
+			start:       "1.0.1.0",
+			end:         "1.0.0.255",
+			expectError: "range 1.0.1.0-1.0.0.255 ends before it starts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRange(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end))
+			if tt.expectError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectError)
+		})
+	}
+}
+
+func TestCheckRange_Invalid(t *testing.T) {
+	require.ErrorContains(
+		t,
+		CheckRange(netip.Addr{}, netip.MustParseAddr("1.0.0.0")),
+		"invalid range",
+	)
+}
+
+func TestCheckWriter(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewCheckWriter(inner)
+	r := row.Row{mmdbtype.String("US")}
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.1.0"),
+		netip.MustParseAddr("1.0.3.255"),
+		r,
+	))
+
+	err := w.WriteRow(netip.PrefixFrom(netip.MustParseAddr("1.0.4.1"), 24), r)
+	require.EqualError(t, err, "check failed: network 1.0.4.1/24 has host bits set")
+	err = w.WriteRange(
+		netip.MustParseAddr("255.255.255.0"),
+		netip.MustParseAddr("::"),
+		r,
+	)
+	require.EqualError(
+		t,
+		err,
+		"check failed: range 255.255.255.0-:: crosses the IPv4/IPv6 boundary",
+	)
+
+	// Failing rows are not written
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, inner.rows)
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("1.0.1.0"), netip.MustParseAddr("1.0.3.255")},
+	}, inner.ranges)
+}
@@ -0,0 +1,399 @@
+package writer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// ClickHouseWriter writes rows for bulk loading into ClickHouse, in the
+// RowBinary format or as TabSeparated text, with the column types of
+// ClickHouseDDL. Neither format has a header, so the table must exist, and
+// the provenance is not written.
+type ClickHouseWriter struct {
+	out          io.Writer
+	config       *config.Config
+	tsv          bool
+	rangeCapable bool
+	buf          []byte // Serialized rows not yet written to out
+	field        []byte // Scratch space for formatting a single field
+}
+
+// NewClickHouseWriter creates a new ClickHouse writer.
+func NewClickHouseWriter(w io.Writer, cfg *config.Config) *ClickHouseWriter {
+	return &ClickHouseWriter{
+		out:    w,
+		config: cfg,
+		tsv:    cfg.Output.ClickHouse.Encoding == "tsv",
+		rangeCapable: !slices.ContainsFunc(cfg.Network.Columns, func(col config.NetworkColumn) bool {
+			return col.Type == NetworkColumnCIDR
+		}),
+		buf:   make([]byte, 0, csvFlushSize+4096),
+		field: make([]byte, 0, 128),
+	}
+}
+
+// ClickHouseDDL returns the CREATE TABLE statement of the table the
+// ClickHouse output of cfg is loaded into. Addresses are IPv6 columns and
+// integers UInt128 columns, both with IPv4 addresses mapped into IPv6, so
+// that both IP versions share a table. The table is ordered by the first start
+// column.
+func ClickHouseDDL(cfg *config.Config) string {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE ")
+	b.WriteString(quoteClickHouseIdentifier(cfg.Output.ClickHouse.Table))
+	b.WriteString("\n(\n")
+	var columns []string
+	orderBy := "tuple()"
+	for _, col := range cfg.Network.Columns {
+		name := quoteClickHouseIdentifier(col.OutputName("clickhouse"))
+		columns = append(columns, name+" "+clickHouseNetworkType(col.Type))
+		if orderBy == "tuple()" &&
+			(col.Type == NetworkColumnStartInt || col.Type == NetworkColumnStartIP ||
+				col.Type == NetworkColumnStartDecimal) {
+			orderBy = name
+		}
+	}
+	for _, col := range cfg.Columns {
+		columns = append(
+			columns,
+			quoteClickHouseIdentifier(col.OutputName("clickhouse"))+" "+clickHouseDataType(col.Type),
+		)
+	}
+	for i, col := range columns {
+		b.WriteString("    ")
+		b.WriteString(col)
+		if i < len(columns)-1 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString(")\nENGINE = MergeTree\nORDER BY ")
+	b.WriteString(orderBy)
+	b.WriteString(";\n")
+	return b.String()
+}
+
+// clickHouseNetworkType returns the ClickHouse type of a network column.
+func clickHouseNetworkType(colType string) string {
+	switch colType {
+	case NetworkColumnStartIP, NetworkColumnEndIP:
+		return "IPv6"
+	case NetworkColumnStartInt, NetworkColumnEndInt:
+		return "UInt128"
+	case NetworkColumnStartDecimal, NetworkColumnEndDecimal:
+		return "Decimal(39,0)"
+	case NetworkColumnValidFrom, NetworkColumnValidTo:
+		return "Nullable(String)"
+	default:
+		return "String"
+	}
+}
+
+// clickHouseDataType returns the ClickHouse type of a data column with a
+// type hint. Binary values are strings, which hold arbitrary bytes in
+// ClickHouse.
+func clickHouseDataType(typeHint string) string {
+	switch typeHint {
+	case "int64":
+		return "Nullable(Int64)"
+	case "float64":
+		return "Nullable(Float64)"
+	case "bool":
+		return "Nullable(Bool)"
+	default:
+		return "Nullable(String)"
+	}
+}
+
+// quoteClickHouseIdentifier quotes a table or column name.
+func quoteClickHouseIdentifier(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
+
+// WriteRow writes a single row with network prefix and column data.
+func (w *ClickHouseWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeRecord(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *ClickHouseWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, writing a single row unless a
+// network column is a CIDR.
+func (w *ClickHouseWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		for _, cidr := range network.RangeToPrefixes(start, end) {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.writeRecord(netip.Prefix{}, start, end, r)
+}
+
+// writeRecord serializes a row. prefix is invalid for range rows.
+func (w *ClickHouseWriter) writeRecord(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	// Discard a partially serialized row on error
+	rowStart := len(w.buf)
+	field := 0
+
+	for _, netCol := range w.config.Network.Columns {
+		w.separate(field)
+		field++
+		if err := w.appendNetworkValue(prefix, start, end, r, netCol.Type); err != nil {
+			w.buf = w.buf[:rowStart]
+			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
+		}
+	}
+
+	for i, col := range w.config.Columns {
+		w.separate(field)
+		field++
+		value, err := convertToParquetType(r, i, col.Type)
+		if err != nil {
+			w.buf = w.buf[:rowStart]
+			return fmt.Errorf("converting column '%s': %w", col.Name, err)
+		}
+		w.appendDataValue(value)
+	}
+	if w.tsv {
+		w.buf = append(w.buf, '\n')
+	}
+
+	if len(w.buf) >= csvFlushSize {
+		return w.flushBuffer()
+	}
+	return nil
+}
+
+// separate starts field i of a row, which TabSeparated rows separate with
+// tabs.
+func (w *ClickHouseWriter) separate(i int) {
+	if w.tsv && i > 0 {
+		w.buf = append(w.buf, '\t')
+	}
+}
+
+// appendNetworkValue appends the value of a network column.
+func (w *ClickHouseWriter) appendNetworkValue(
+	prefix netip.Prefix,
+	start, end netip.Addr,
+	r row.Row,
+	colType string,
+) error {
+	switch colType {
+	case NetworkColumnStartIP:
+		w.appendIP(start)
+	case NetworkColumnEndIP:
+		w.appendIP(end)
+	case NetworkColumnStartInt:
+		w.appendUint128(start)
+	case NetworkColumnEndInt:
+		w.appendUint128(end)
+	case NetworkColumnStartDecimal:
+		w.appendDecimal(start)
+	case NetworkColumnEndDecimal:
+		w.appendDecimal(end)
+	case NetworkColumnValidFrom:
+		w.appendNullableString(r.ValidFrom(len(w.config.Columns)))
+	case NetworkColumnValidTo:
+		w.appendNullableString(r.ValidTo(len(w.config.Columns)))
+	default:
+		var err error
+		w.field, err = appendNetworkValue(w.field[:0], prefix, start, end, colType)
+		if err != nil {
+			return err
+		}
+		w.appendString(w.field)
+	}
+	return nil
+}
+
+// appendIP appends an IPv6 value, with IPv4 addresses mapped into IPv6.
+func (w *ClickHouseWriter) appendIP(addr netip.Addr) {
+	addr = network.MapToIPv6(addr)
+	if w.tsv {
+		w.buf = addr.AppendTo(w.buf)
+		return
+	}
+	w.buf = append(w.buf, addr.AsSlice()...)
+}
+
+// appendUint128 appends an address as a UInt128 value, the 128-bit integer
+// of the address with IPv4 addresses mapped into IPv6, as appendIP maps
+// them, so that the integers of both IP versions order and join like the
+// addresses.
+func (w *ClickHouseWriter) appendUint128(addr netip.Addr) {
+	addr = network.MapToIPv6(addr)
+	if w.tsv {
+		w.buf = appendAddrInt(w.buf, addr)
+		return
+	}
+	hi, lo := network.AddrToUint128(addr)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, lo)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, hi)
+}
+
+// appendDecimal appends an address as a Decimal(39,0) value, the integer of
+// the address, which RowBinary writes as the 256-bit little-endian integer
+// of a Decimal256.
+func (w *ClickHouseWriter) appendDecimal(addr netip.Addr) {
+	if w.tsv {
+		w.buf = appendAddrInt(w.buf, addr)
+		return
+	}
+	hi, lo := network.AddrToUint128(addr)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, lo)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, hi)
+	w.buf = append(w.buf, make([]byte, 16)...)
+}
+
+// appendNullableString appends a Nullable(String) value, null if empty.
+func (w *ClickHouseWriter) appendNullableString(s string) {
+	if s == "" {
+		w.appendNull()
+		return
+	}
+	w.appendNotNull()
+	w.appendString([]byte(s))
+}
+
+// appendDataValue appends the value of a data column, converted by its type
+// hint.
+func (w *ClickHouseWriter) appendDataValue(value any) {
+	if value == nil {
+		w.appendNull()
+		return
+	}
+	w.appendNotNull()
+	switch v := value.(type) {
+	case string:
+		w.appendString([]byte(v))
+	case []byte:
+		w.appendString(v)
+	case int64:
+		if w.tsv {
+			w.buf = strconv.AppendInt(w.buf, v, 10)
+		} else {
+			w.buf = binary.LittleEndian.AppendUint64(w.buf, uint64(v))
+		}
+	case float64:
+		if w.tsv {
+			w.buf = appendClickHouseFloat(w.buf, v)
+		} else {
+			w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+		}
+	case bool:
+		switch {
+		case w.tsv && v:
+			w.buf = append(w.buf, "true"...)
+		case w.tsv:
+			w.buf = append(w.buf, "false"...)
+		case v:
+			w.buf = append(w.buf, 1)
+		default:
+			w.buf = append(w.buf, 0)
+		}
+	}
+}
+
+// appendClickHouseFloat appends a float as ClickHouse parses it, which
+// spells infinities and NaN in lowercase.
+func appendClickHouseFloat(dst []byte, f float64) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return append(dst, "inf"...)
+	case math.IsInf(f, -1):
+		return append(dst, "-inf"...)
+	case math.IsNaN(f):
+		return append(dst, "nan"...)
+	default:
+		return strconv.AppendFloat(dst, f, 'g', -1, 64)
+	}
+}
+
+// appendNull appends a null value: \N in TabSeparated rows, and a set null
+// flag in RowBinary rows.
+func (w *ClickHouseWriter) appendNull() {
+	if w.tsv {
+		w.buf = append(w.buf, `\N`...)
+		return
+	}
+	w.buf = append(w.buf, 1)
+}
+
+// appendNotNull starts a value of a Nullable column, which RowBinary rows
+// precede with a cleared null flag.
+func (w *ClickHouseWriter) appendNotNull() {
+	if !w.tsv {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// appendString appends a string: escaped in TabSeparated rows, and preceded
+// by its length in RowBinary rows.
+func (w *ClickHouseWriter) appendString(s []byte) {
+	if !w.tsv {
+		w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+		w.buf = append(w.buf, s...)
+		return
+	}
+	for _, c := range s {
+		switch c {
+		case '\\':
+			w.buf = append(w.buf, `\\`...)
+		case '\t':
+			w.buf = append(w.buf, `\t`...)
+		case '\n':
+			w.buf = append(w.buf, `\n`...)
+		case '\r':
+			w.buf = append(w.buf, `\r`...)
+		case 0:
+			w.buf = append(w.buf, `\0`...)
+		default:
+			w.buf = append(w.buf, c)
+		}
+	}
+}
+
+// flushBuffer writes all buffered rows to the underlying writer.
+func (w *ClickHouseWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("writing ClickHouse rows: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush writes all buffered rows.
+func (w *ClickHouseWriter) Flush() error {
+	return w.flushBuffer()
+}
+
+// Sync writes all buffered rows and commits them to stable storage if the
+// underlying writer supports it, as *os.File does.
+func (w *ClickHouseWriter) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return syncOutput(w.out)
+}
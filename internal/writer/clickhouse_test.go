@@ -0,0 +1,186 @@
+package writer
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func clickHouseConfig(encoding string, network ...config.NetworkColumn) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{
+			ClickHouse: config.ClickHouseConfig{Encoding: encoding, Table: "geo"},
+		},
+		Network: config.NetworkConfig{Columns: network},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "accuracy", Type: "int64"},
+			{Name: "is_eu", Type: "bool"},
+		},
+	}
+}
+
+func TestClickHouseDDL(t *testing.T) {
+	cfg := clickHouseConfig("rowbinary",
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+		config.NetworkColumn{Name: "end_ip", Type: NetworkColumnEndIP},
+	)
+	assert.Equal(t, "CREATE TABLE `geo`\n"+
+		"(\n"+
+		"    `network` String,\n"+
+		"    `start_int` UInt128,\n"+
+		"    `end_ip` IPv6,\n"+
+		"    `country` Nullable(String),\n"+
+		"    `accuracy` Nullable(Int64),\n"+
+		"    `is_eu` Nullable(Bool)\n"+
+		")\n"+
+		"ENGINE = MergeTree\n"+
+		"ORDER BY `start_int`;\n", ClickHouseDDL(cfg))
+
+	// Without a start column, the table has no sorting key
+	cfg.Network.Columns = nil
+	assert.Contains(t, ClickHouseDDL(cfg), "ORDER BY tuple();\n")
+}
+
+func TestClickHouseDDL_Decimal(t *testing.T) {
+	cfg := clickHouseConfig("rowbinary",
+		config.NetworkColumn{Name: "start_decimal", Type: NetworkColumnStartDecimal},
+		config.NetworkColumn{Name: "end_decimal", Type: NetworkColumnEndDecimal},
+	)
+	ddl := ClickHouseDDL(cfg)
+	assert.Contains(t, ddl, "    `start_decimal` Decimal(39,0),\n    `end_decimal` Decimal(39,0),\n")
+	assert.Contains(t, ddl, "ORDER BY `start_decimal`;\n")
+}
+
+func TestClickHouseDDL_Aliases(t *testing.T) {
+	cfg := clickHouseConfig("rowbinary",
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt, Aliases: config.Aliases{
+			Header:  "Start",
+			SQLName: "ip_start",
+		}},
+	)
+	cfg.Columns[0].SQLName = "cntry_cd"
+	ddl := ClickHouseDDL(cfg)
+	assert.Contains(t, ddl, "    `ip_start` UInt128,\n    `cntry_cd` Nullable(String),\n")
+	assert.Contains(t, ddl, "ORDER BY `ip_start`;\n")
+}
+
+func TestClickHouseWriter_TSV(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewClickHouseWriter(&buf, clickHouseConfig("tsv",
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "end_int", Type: NetworkColumnEndInt},
+	))
+	require.True(t, w.WritesRanges())
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("U\tS\\"),
+		mmdbtype.Uint16(100),
+		mmdbtype.Bool(false),
+	}))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2001:db8::"),
+		netip.MustParseAddr("2001:db8::ff"),
+		row.Row{nil, nil, mmdbtype.Bool(true)},
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, "::ffff:1.0.0.0\t281470698520831\tU\\tS\\\\\t100\tfalse\n"+
+		"2001:db8::\t42540766411282592856903984951653826815\t\\N\t\\N\ttrue\n", buf.String())
+}
+
+func TestClickHouseWriter_BothIPVersions(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewClickHouseWriter(&buf, clickHouseConfig("tsv",
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+		config.NetworkColumn{Name: "end_int", Type: NetworkColumnEndInt},
+	))
+	r := row.Row{nil, nil, nil}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("::/96"), r))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/120"), r))
+	require.NoError(t, w.Flush())
+
+	// The integers of IPv4 rows are those of their mapped addresses, so they
+	// neither overlap ::/96 nor order apart from the address columns
+	assert.Equal(t, "::\t0\t4294967295\t\\N\t\\N\t\\N\n"+
+		"::ffff:1.0.0.0\t281470698520576\t281470698520831\t\\N\t\\N\t\\N\n"+
+		"2001:db8::\t42540766411282592856903984951653826560\t"+
+		"42540766411282592856903984951653826815\t\\N\t\\N\t\\N\n", buf.String())
+}
+
+func TestClickHouseWriter_RowBinary(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewClickHouseWriter(&buf, clickHouseConfig("rowbinary",
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+	))
+	require.False(t, w.WritesRanges())
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("US"),
+		nil,
+		mmdbtype.Bool(true),
+	}))
+	require.NoError(t, w.Flush())
+
+	expected := []byte{10}
+	expected = append(expected, "1.0.0.0/24"...)
+	expected = append(expected, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 1, 0, 0, 0)
+	expected = append(expected, 0, 0, 0, 1, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0, 2, 'U', 'S') // Not null, then the string
+	expected = append(expected, 1)              // Null
+	expected = append(expected, 0, 1)
+	assert.Equal(t, expected, buf.Bytes())
+}
+
+func TestClickHouseWriter_Decimal(t *testing.T) {
+	network := []config.NetworkColumn{
+		{Name: "start_decimal", Type: NetworkColumnStartDecimal},
+		{Name: "end_decimal", Type: NetworkColumnEndDecimal},
+	}
+	r := row.Row{nil, nil, nil}
+	end := netip.MustParseAddr("2001:db8::ff")
+
+	var tsv bytes.Buffer
+	w := NewClickHouseWriter(&tsv, clickHouseConfig("tsv", network...))
+	require.NoError(t, w.WriteRange(netip.MustParseAddr("2001:db8::"), end, r))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "42540766411282592856903984951653826560\t"+
+		"42540766411282592856903984951653826815\t\\N\t\\N\t\\N\n", tsv.String())
+
+	// RowBinary writes the 256-bit little-endian integer of a Decimal256
+	var binary bytes.Buffer
+	w = NewClickHouseWriter(&binary, clickHouseConfig("rowbinary", network...))
+	require.NoError(t, w.WriteRange(netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.255"), r))
+	require.NoError(t, w.Flush())
+	expected := make([]byte, 64)
+	expected[3] = 1
+	expected[32], expected[35] = 0xff, 1
+	expected = append(expected, 1, 1, 1) // Null data columns
+	assert.Equal(t, expected, binary.Bytes())
+}
+
+func TestClickHouseWriter_InvalidValue(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewClickHouseWriter(&buf, clickHouseConfig("tsv"))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{nil, nil, nil}))
+
+	// The failed row is discarded
+	require.EqualError(t,
+		w.WriteRow(netip.MustParsePrefix("1.0.1.0/24"), row.Row{nil, mmdbtype.String("high"), nil}),
+		"converting column 'accuracy': cannot convert string to int64",
+	)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "\\N\t\\N\t\\N\n", buf.String())
+}
@@ -2,21 +2,19 @@
 package writer
 
 import (
-	"encoding/csv"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
-	"math/big"
+	"math/bits"
 	"net/netip"
 	"strconv"
-	"sync"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"go4.org/netipx"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
-	"github.com/maxmind/mmdbconvert/internal/network"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
 )
 
 // Network column type constants.
@@ -26,27 +24,49 @@ const (
 	NetworkColumnEndIP    = "end_ip"
 	NetworkColumnStartInt = "start_int"
 	NetworkColumnEndInt   = "end_int"
+
+	// Full integer of an IPv4 or IPv6 boundary as a decimal string, for
+	// consumers that only join on decimal integers
+	NetworkColumnStartDecimal = "start_decimal"
+	NetworkColumnEndDecimal   = "end_decimal"
+
+	// Validity interval of a row in a time-sliced export
+	NetworkColumnValidFrom = "valid_from"
+	NetworkColumnValidTo   = "valid_to"
 )
 
-// CSVWriter writes merged MMDB data to CSV format.
+// csvFlushSize is the number of buffered bytes that triggers a write to the
+// underlying writer.
+const csvFlushSize = 64 * 1024
+
+// CSVWriter writes merged MMDB data to CSV format. Rows are serialized
+// directly into a reusable buffer, following the quoting rules of
+// encoding/csv, and written out in large chunks.
 type CSVWriter struct {
-	writer        *csv.Writer
+	out           io.Writer
 	config        *config.Config
+	comma         byte
 	headerWritten bool
 	headerEnabled bool
 	rangeCapable  bool
-	bigIntPool    *sync.Pool // Pool of big.Int for IPv6 integer conversion
-	rowBatch      [][]string // Batch buffer for rows
-	batchSize     int        // Number of rows to batch before writing
+	legacy        bool   // Quote every field, as the legacy GeoIP CSV files do
+	spaced        bool   // Follow delimiters with a space in the current row
+	buf           []byte // Serialized rows not yet written to out
+	field         []byte // Scratch space for formatting a single field
+
+	// Rotation by size; parts is nil unless output.csv.max_file_size is set
+	parts    *PartFiles
+	maxSize  int64
+	partSize int64  // Bytes written to the current part
+	partRows int64  // Rows in the current part, including buffered ones
+	pending  []byte // A row moved to the next part
 }
 
 // NewCSVWriter creates a new CSV writer.
 func NewCSVWriter(w io.Writer, cfg *config.Config) *CSVWriter {
-	csvWriter := csv.NewWriter(w)
-
-	// Set delimiter (rune conversion)
+	comma := byte(',')
 	if cfg.Output.CSV.Delimiter != "" {
-		csvWriter.Comma = rune(cfg.Output.CSV.Delimiter[0])
+		comma = cfg.Output.CSV.Delimiter[0]
 	}
 
 	headerEnabled := true
@@ -54,299 +74,345 @@ func NewCSVWriter(w io.Writer, cfg *config.Config) *CSVWriter {
 		headerEnabled = *cfg.Output.CSV.IncludeHeader
 	}
 
-	rangeCapable := true
+	return &CSVWriter{
+		out:           w,
+		config:        cfg,
+		comma:         comma,
+		headerEnabled: headerEnabled,
+		rangeCapable:  CSVWritesRanges(cfg),
+		legacy:        cfg.Output.CSV.Profile == "geoip-legacy",
+		buf:           make([]byte, 0, csvFlushSize+4096),
+		field:         make([]byte, 0, 128),
+	}
+}
+
+// CSVWritesRanges reports whether the CSV output of cfg writes a range as a
+// single row, which its network columns allow unless one is a CIDR.
+func CSVWritesRanges(cfg *config.Config) bool {
 	for _, col := range cfg.Network.Columns {
 		switch col.Type {
-		case NetworkColumnStartIP, NetworkColumnEndIP, NetworkColumnStartInt, NetworkColumnEndInt:
+		case NetworkColumnStartIP, NetworkColumnEndIP, NetworkColumnStartInt, NetworkColumnEndInt,
+			NetworkColumnStartDecimal, NetworkColumnEndDecimal,
+			NetworkColumnValidFrom, NetworkColumnValidTo:
 			// supported
 		default:
-			rangeCapable = false
+			return false
 		}
 	}
+	return true
+}
 
-	const defaultBatchSize = 1000
-	return &CSVWriter{
-		writer:        csvWriter,
-		config:        cfg,
-		headerEnabled: headerEnabled,
-		headerWritten: !headerEnabled,
-		rangeCapable:  rangeCapable,
-		bigIntPool: &sync.Pool{
-			New: func() any {
-				return new(big.Int)
-			},
-		},
-		rowBatch:  make([][]string, 0, defaultBatchSize),
-		batchSize: defaultBatchSize,
+// NewRotatingCSVWriter creates a CSV writer that starts a new part file
+// before a row would grow the current part beyond
+// cfg.Output.CSV.MaxFileSize bytes. Each part starts with its own header, so
+// that it can be loaded on its own. A row larger than the limit gets a part
+// to itself.
+func NewRotatingCSVWriter(parts *PartFiles, cfg *config.Config) (*CSVWriter, error) {
+	out, err := parts.Next()
+	if err != nil {
+		return nil, err
 	}
+	w := NewCSVWriter(out, cfg)
+	w.parts = parts
+	w.maxSize = cfg.Output.CSV.MaxFileSize
+	return w, nil
 }
 
 // WriteRow writes a single row with network prefix and column data.
-func (w *CSVWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error {
-	if err := w.ensureHeader(); err != nil {
-		return err
+func (w *CSVWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeRecord(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *CSVWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, emitting a single row when the
+// configured network columns support ranges, or falling back to prefix output
+// otherwise.
+func (w *CSVWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		cidrs := network.RangeToPrefixes(start, end)
+		for _, cidr := range cidrs {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
+	return w.writeRecord(netip.Prefix{}, start, end, r)
+}
+
+// writeRecord serializes one row. prefix is only valid for prefix rows.
+func (w *CSVWriter) writeRecord(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	w.ensureHeader()
 
-	// Build row with network columns + data columns
-	row := make([]string, 0, len(w.config.Network.Columns)+len(w.config.Columns))
+	// Discard a partially serialized row on error
+	rowStart := len(w.buf)
+	first := true
+	// The legacy GeoIPv6.csv separates fields with ", "
+	w.spaced = w.legacy && !start.Is4()
 
-	// Add network column values
 	for _, netCol := range w.config.Network.Columns {
-		value, err := w.generateNetworkColumnValue(prefix, netCol.Type)
+		var err error
+		switch netCol.Type {
+		case NetworkColumnValidFrom:
+			w.field = append(w.field[:0], r.ValidFrom(len(w.config.Columns))...)
+		case NetworkColumnValidTo:
+			w.field = append(w.field[:0], r.ValidTo(len(w.config.Columns))...)
+		default:
+			w.field, err = appendNetworkValue(w.field[:0], prefix, start, end, netCol.Type)
+		}
 		if err != nil {
+			w.buf = w.buf[:rowStart]
 			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
 		}
-		row = append(row, value)
+		w.buf = w.appendField(w.buf, w.field, first)
+		first = false
 	}
 
-	// Add data column values (in config order)
 	for i, col := range w.config.Columns {
-		value := data[i]
-		strValue, err := convertToString(value)
+		var err error
+		w.field, err = r.AppendText(w.field[:0], i)
 		if err != nil {
+			w.buf = w.buf[:rowStart]
 			return fmt.Errorf("converting column '%s' to string: %w", col.Name, err)
 		}
-		row = append(row, strValue)
+		w.buf = w.appendField(w.buf, w.field, first)
+		first = false
 	}
+	w.buf = append(w.buf, '\n')
 
-	// Add row to batch
-	w.rowBatch = append(w.rowBatch, row)
-
-	// Flush batch if it's full
-	if len(w.rowBatch) >= w.batchSize {
-		return w.flushBatch()
+	if w.parts != nil {
+		if err := w.rotateIfFull(rowStart); err != nil {
+			return err
+		}
 	}
 
+	if len(w.buf) >= csvFlushSize {
+		return w.flushBuffer()
+	}
 	return nil
 }
 
-// flushBatch writes all batched rows to the CSV writer.
-func (w *CSVWriter) flushBatch() error {
-	for _, row := range w.rowBatch {
-		if err := w.writer.Write(row); err != nil {
-			return fmt.Errorf("writing CSV row: %w", err)
-		}
+// rotateIfFull moves the row serialized at w.buf[rowStart:] to a new part
+// if it would make the current part exceed the size limit.
+func (w *CSVWriter) rotateIfFull(rowStart int) error {
+	if w.partRows == 0 || w.partSize+int64(len(w.buf)) <= w.maxSize {
+		w.partRows++
+		return nil
 	}
-	// Clear the batch
-	w.rowBatch = w.rowBatch[:0]
-	return nil
-}
 
-// Flush ensures all buffered data is written.
-func (w *CSVWriter) Flush() error {
-	// Flush any remaining batched rows
-	if err := w.flushBatch(); err != nil {
+	w.pending = append(w.pending[:0], w.buf[rowStart:]...)
+	w.buf = w.buf[:rowStart]
+	if err := w.flushBuffer(); err != nil {
 		return err
 	}
-	w.writer.Flush()
-	if err := w.writer.Error(); err != nil {
-		return fmt.Errorf("CSV flush error: %w", err)
+	w.parts.SetCurrent(w.partSize, w.partRows)
+
+	out, err := w.parts.Next()
+	if err != nil {
+		return fmt.Errorf("rotating CSV output: %w", err)
 	}
+	w.out = out
+	w.partSize = 0
+	w.partRows = 1
+	w.headerWritten = false
+	w.ensureHeader()
+	w.buf = append(w.buf, w.pending...)
 	return nil
 }
 
-// WriteRange implements merger.RangeRowWriter, emitting a single row when the
-// configured network columns support ranges, or falling back to prefix output
-// otherwise.
-func (w *CSVWriter) WriteRange(start, end netip.Addr, data []mmdbtype.DataType) error {
-	if !w.rangeCapable {
-		cidrs := netipx.IPRangeFrom(start, end).Prefixes()
-		for _, cidr := range cidrs {
-			if err := w.WriteRow(cidr, data); err != nil {
-				return err
-			}
-		}
+// flushBuffer writes all buffered rows to the underlying writer.
+func (w *CSVWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
 		return nil
 	}
-	if err := w.ensureHeader(); err != nil {
-		return err
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("writing CSV rows: %w", err)
 	}
+	w.partSize += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
 
-	row := make([]string, 0, len(w.config.Network.Columns)+len(w.config.Columns))
-
-	for _, netCol := range w.config.Network.Columns {
-		value, err := w.generateRangeNetworkValue(start, end, netCol.Type)
-		if err != nil {
-			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
-		}
-		row = append(row, value)
+// Flush ensures all buffered data is written, and updates the manifest of a
+// rotated output.
+func (w *CSVWriter) Flush() error {
+	if err := w.flushBuffer(); err != nil {
+		return fmt.Errorf("CSV flush error: %w", err)
 	}
-
-	for i, col := range w.config.Columns {
-		value := data[i]
-		strValue, err := convertToString(value)
-		if err != nil {
-			return fmt.Errorf("converting column '%s' to string: %w", col.Name, err)
+	if w.parts != nil {
+		w.parts.SetCurrent(w.partSize, w.partRows)
+		if err := w.parts.WriteManifest(); err != nil {
+			return fmt.Errorf("CSV flush error: %w", err)
 		}
-		row = append(row, strValue)
 	}
+	return nil
+}
 
-	// Add row to batch
-	w.rowBatch = append(w.rowBatch, row)
-
-	// Flush batch if it's full
-	if len(w.rowBatch) >= w.batchSize {
-		return w.flushBatch()
+// Sync writes all buffered rows and commits them to stable storage if the
+// underlying writer supports it, as *os.File does.
+func (w *CSVWriter) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
 	}
-
-	return nil
+	return syncOutput(w.out)
 }
 
 // writeHeader writes the CSV header row.
-func (w *CSVWriter) writeHeader() error {
-	header := make([]string, 0, len(w.config.Network.Columns)+len(w.config.Columns))
+func (w *CSVWriter) writeHeader() {
+	first := true
 
 	// Add network column names
 	for _, netCol := range w.config.Network.Columns {
-		header = append(header, string(netCol.Name))
+		w.buf = w.appendField(w.buf, []byte(netCol.OutputName("csv")), first)
+		first = false
 	}
 
 	// Add data column names
 	for _, col := range w.config.Columns {
-		header = append(header, string(col.Name))
-	}
-
-	if err := w.writer.Write(header); err != nil {
-		return fmt.Errorf("writing header: %w", err)
+		w.buf = w.appendField(w.buf, []byte(col.OutputName("csv")), first)
+		first = false
 	}
 
-	return nil
+	w.buf = append(w.buf, '\n')
 }
 
-func (w *CSVWriter) ensureHeader() error {
-	if w.headerEnabled && !w.headerWritten {
-		if err := w.writeHeader(); err != nil {
-			return fmt.Errorf("writing CSV header: %w", err)
-		}
-		w.headerWritten = true
+// writeProvenance writes the provenance as comment lines of the form
+// "# key=value".
+func (w *CSVWriter) writeProvenance() {
+	for _, pair := range w.config.Provenance.Pairs() {
+		w.buf = append(w.buf, "# "...)
+		w.buf = append(w.buf, pair.Key...)
+		w.buf = append(w.buf, '=')
+		w.buf = append(w.buf, pair.Value...)
+		w.buf = append(w.buf, '\n')
 	}
-	return nil
 }
 
-// generateNetworkColumnValue generates the value for a network column.
-func (w *CSVWriter) generateNetworkColumnValue(
-	prefix netip.Prefix,
-	colType string,
-) (string, error) {
-	addr := prefix.Addr()
-
-	switch colType {
-	case NetworkColumnCIDR:
-		return prefix.String(), nil
-
-	case NetworkColumnStartIP:
-		return addr.String(), nil
-
-	case NetworkColumnEndIP:
-		endIP := netipx.PrefixLastIP(prefix)
-		return endIP.String(), nil
+func (w *CSVWriter) ensureHeader() {
+	if w.headerWritten {
+		return
+	}
+	w.headerWritten = true
+	if w.config.Output.CSV.ProvenanceHeader {
+		w.writeProvenance()
+	}
+	if w.headerEnabled {
+		w.writeHeader()
+	}
+}
 
-	case NetworkColumnStartInt:
-		if addr.Is4() {
-			return strconv.FormatUint(uint64(network.IPv4ToUint32(addr)), 10), nil
+// appendField appends a field, preceded by the delimiter unless it is the
+// first in the row, and quoted if necessary.
+func (w *CSVWriter) appendField(dst []byte, field []byte, first bool) []byte {
+	if !first {
+		dst = append(dst, w.comma)
+		if w.spaced {
+			dst = append(dst, ' ')
 		}
-		return w.formatIPv6AsInt(addr), nil
+	}
+	if !w.legacy && !w.fieldNeedsQuotes(field) {
+		return append(dst, field...)
+	}
 
-	case NetworkColumnEndInt:
-		endIP := netipx.PrefixLastIP(prefix)
-		if endIP.Is4() {
-			return strconv.FormatUint(uint64(network.IPv4ToUint32(endIP)), 10), nil
+	dst = append(dst, '"')
+	for _, c := range field {
+		if c == '"' {
+			dst = append(dst, '"')
 		}
-		return w.formatIPv6AsInt(endIP), nil
+		dst = append(dst, c)
+	}
+	return append(dst, '"')
+}
 
-	default:
-		return "", fmt.Errorf("unknown network column type: %s", colType)
+// fieldNeedsQuotes reports whether field must be quoted, using the same
+// rules as encoding/csv: fields containing the delimiter, a quote, or a line
+// break, fields starting with a space, and the field \. are quoted.
+func (w *CSVWriter) fieldNeedsQuotes(field []byte) bool {
+	if len(field) == 0 {
+		return false
+	}
+	if len(field) == 2 && field[0] == '\\' && field[1] == '.' {
+		return true
+	}
+	for _, c := range field {
+		if c == '\n' || c == '\r' || c == '"' || c == w.comma {
+			return true
+		}
 	}
+	r, _ := utf8.DecodeRune(field)
+	return unicode.IsSpace(r)
 }
 
-func (w *CSVWriter) generateRangeNetworkValue(
+// appendNetworkValue appends the value of a network column. prefix is only
+// used for CIDR columns and is invalid for range rows.
+func appendNetworkValue(
+	dst []byte,
+	prefix netip.Prefix,
 	start netip.Addr,
 	end netip.Addr,
 	colType string,
-) (string, error) {
+) ([]byte, error) {
 	switch colType {
+	case NetworkColumnCIDR:
+		if !prefix.IsValid() {
+			return dst, fmt.Errorf(
+				"unsupported network column type '%s' for range output",
+				colType,
+			)
+		}
+		return prefix.AppendTo(dst), nil
 	case NetworkColumnStartIP:
-		return start.String(), nil
+		return start.AppendTo(dst), nil
 	case NetworkColumnEndIP:
-		return end.String(), nil
-	case NetworkColumnStartInt:
-		if start.Is4() {
-			return strconv.FormatUint(uint64(network.IPv4ToUint32(start)), 10), nil
-		}
-		return w.formatIPv6AsInt(start), nil
-	case NetworkColumnEndInt:
-		if end.Is4() {
-			return strconv.FormatUint(uint64(network.IPv4ToUint32(end)), 10), nil
-		}
-		return w.formatIPv6AsInt(end), nil
+		return end.AppendTo(dst), nil
+	case NetworkColumnStartInt, NetworkColumnStartDecimal:
+		return appendAddrInt(dst, start), nil
+	case NetworkColumnEndInt, NetworkColumnEndDecimal:
+		return appendAddrInt(dst, end), nil
 	default:
-		return "", fmt.Errorf("unsupported network column type '%s' for range output", colType)
+		return dst, fmt.Errorf("unknown network column type: %s", colType)
 	}
 }
 
-// formatIPv6AsInt formats an IPv6 address as a decimal integer string using a
-// pooled big.Int to reduce allocations.
-func (w *CSVWriter) formatIPv6AsInt(addr netip.Addr) string {
-	// Get a big.Int from the pool
-	i := w.bigIntPool.Get().(*big.Int)
-	defer func() {
-		// Reset and return to pool
-		i.SetInt64(0)
-		w.bigIntPool.Put(i)
-	}()
-
-	// Convert IPv6 address to big.Int
-	b := addr.As16()
-	i.SetBytes(b[:])
-	return i.String()
+// appendAddrInt appends an address as a decimal integer.
+func appendAddrInt(dst []byte, addr netip.Addr) []byte {
+	hi, lo := network.AddrToUint128(addr)
+	return appendUint128(dst, hi, lo)
 }
 
-// convertToString converts a value to its CSV string representation.
-// Handles mmdbtype.DataType values from the extractor.
-func convertToString(value any) (string, error) {
-	if value == nil {
-		return "", nil
+// appendUint128 appends the 128-bit unsigned integer hi<<64 | lo in decimal
+// without allocating.
+func appendUint128(dst []byte, hi, lo uint64) []byte {
+	if hi == 0 {
+		return strconv.AppendUint(dst, lo, 10)
 	}
 
-	// Handle mmdbtype.DataType values
-	switch v := value.(type) {
-	case mmdbtype.Bool:
-		if bool(v) {
-			return "1", nil
-		}
-		return "0", nil
-	case mmdbtype.String:
-		return string(v), nil
-	case mmdbtype.Int32:
-		return strconv.FormatInt(int64(v), 10), nil
-	case mmdbtype.Uint16:
-		return strconv.FormatUint(uint64(v), 10), nil
-	case mmdbtype.Uint32:
-		return strconv.FormatUint(uint64(v), 10), nil
-	case mmdbtype.Uint64:
-		return strconv.FormatUint(uint64(v), 10), nil
-	case *mmdbtype.Uint128:
-		return (*big.Int)(v).String(), nil
-	case mmdbtype.Float32:
-		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
-	case mmdbtype.Float64:
-		return strconv.FormatFloat(float64(v), 'g', -1, 64), nil
-	case mmdbtype.Bytes:
-		return hex.EncodeToString([]byte(v)), nil
-	case mmdbtype.Map:
-		b, err := json.Marshal(v)
-		if err != nil {
-			return "", fmt.Errorf("marshaling map to JSON: %w", err)
-		}
-		return string(b), nil
-	case mmdbtype.Slice:
-		b, err := json.Marshal(v)
-		if err != nil {
-			return "", fmt.Errorf("marshaling slice to JSON: %w", err)
+	// Split into base 10^19 digits, the largest power of ten below 2^64.
+	// A 128-bit value has at most three.
+	const base = 10_000_000_000_000_000_000
+	var parts [3]uint64
+	n := 0
+	for hi != 0 || lo != 0 {
+		var rem uint64
+		hi, rem = hi/base, hi%base
+		lo, rem = bits.Div64(rem, lo, base)
+		parts[n] = rem
+		n++
+	}
+
+	dst = strconv.AppendUint(dst, parts[n-1], 10)
+	for i := n - 2; i >= 0; i-- {
+		var digits [19]byte
+		for j := len(digits) - 1; j >= 0; j-- {
+			digits[j] = byte('0' + parts[i]%10)
+			parts[i] /= 10
 		}
-		return string(b), nil
-	default:
-		// Fallback for any unexpected types
-		return fmt.Sprintf("%v", v), nil
+		dst = append(dst, digits[:]...)
 	}
+	return dst
 }
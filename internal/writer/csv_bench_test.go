@@ -55,3 +55,44 @@ func BenchmarkCSVWriteRow(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkCSVWriteRangeIPv6 benchmarks writing IPv6 ranges with integer
+// network columns, which require 128-bit decimal formatting.
+func BenchmarkCSVWriteRangeIPv6(b *testing.B) {
+	cfg := &config.Config{
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_int", Type: "start_int"},
+				{Name: "end_int", Type: "end_int"},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country", Database: "db", Path: config.Path{"country", "iso_code"}},
+			{Name: "city", Database: "db", Path: config.Path{"city", "name"}},
+		},
+		Output: config.OutputConfig{
+			CSV: config.CSVConfig{Delimiter: ","},
+		},
+	}
+
+	writer := NewCSVWriter(io.Discard, cfg)
+
+	start := netip.MustParseAddr("2001:db8::")
+	end := netip.MustParseAddr("2001:db8::ffff")
+
+	// Data in column order: country, city
+	data := []mmdbtype.DataType{
+		mmdbtype.String("DE"),
+		mmdbtype.String("Berlin"),
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		err := writer.WriteRange(start, end, data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
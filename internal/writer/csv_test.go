@@ -2,15 +2,21 @@ package writer
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"math/big"
 	"net/netip"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
 )
 
 func TestCSVWriter_SingleRow(t *testing.T) {
@@ -471,29 +477,292 @@ func TestCSVWriter_CSVEscaping(t *testing.T) {
 	assert.Contains(t, output, "\"hello, world\"")
 }
 
-func TestConvertToString(t *testing.T) {
-	tests := []struct {
-		name     string
-		value    any
-		expected string
-	}{
-		{"nil", nil, ""},
-		{"string", mmdbtype.String("hello"), "hello"},
-		{"int32", mmdbtype.Int32(42), "42"},
-		{"uint16", mmdbtype.Uint16(42), "42"},
-		{"uint32", mmdbtype.Uint32(42), "42"},
-		{"uint64", mmdbtype.Uint64(42), "42"},
-		{"float64", mmdbtype.Float64(3.14), "3.14"},
-		{"bool true", mmdbtype.Bool(true), "1"},
-		{"bool false", mmdbtype.Bool(false), "0"},
-		{"binary", mmdbtype.Bytes([]byte{0xaa, 0xbb}), "aabb"},
+// TestCSVWriter_MatchesEncodingCSV checks that fields are quoted exactly as
+// encoding/csv would quote them.
+func TestCSVWriter_MatchesEncodingCSV(t *testing.T) {
+	fields := []string{
+		"",
+		"plain",
+		"hello, world",
+		`say "hi"`,
+		"line\nbreak",
+		"carriage\rreturn",
+		" leading space",
+		"\tleading tab",
+		"trailing space ",
+		`\.`,
+		`\.x`,
+		"semi;colon",
+		"tab\tinside",
+		"naïve",
+		" non-breaking",
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result, err := convertToString(tt.value)
-			require.NoError(t, err)
-			assert.Equal(t, tt.expected, result)
+	for _, delimiter := range []string{",", ";", "\t", "|"} {
+		t.Run(fmt.Sprintf("delimiter %q", delimiter), func(t *testing.T) {
+			cfg := &config.Config{
+				Output: config.OutputConfig{
+					CSV: config.CSVConfig{Delimiter: delimiter},
+				},
+				Network: config.NetworkConfig{
+					Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+				},
+				Columns: []config.Column{{Name: "a"}, {Name: "b"}},
+			}
+
+			got := &bytes.Buffer{}
+			w := NewCSVWriter(got, cfg)
+
+			want := &bytes.Buffer{}
+			expected := csv.NewWriter(want)
+			expected.Comma = rune(delimiter[0])
+			require.NoError(t, expected.Write([]string{"network", "a", "b"}))
+
+			prefix := netip.MustParsePrefix("10.0.0.0/24")
+			for _, field := range fields {
+				require.NoError(t, w.WriteRow(prefix, row.Row{
+					mmdbtype.String(field),
+					mmdbtype.String("x" + field),
+				}))
+				require.NoError(t, expected.Write([]string{prefix.String(), field, "x" + field}))
+			}
+			require.NoError(t, w.Flush())
+			expected.Flush()
+
+			assert.Equal(t, want.String(), got.String())
 		})
 	}
 }
+
+func TestCSVWriter_IPv6Integers(t *testing.T) {
+	buf := &bytes.Buffer{}
+	includeHeader := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			CSV: config.CSVConfig{Delimiter: ",", IncludeHeader: &includeHeader},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_int", Type: "start_int"},
+				{Name: "end_int", Type: "end_int"},
+			},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	w := NewCSVWriter(buf, cfg)
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2001:db8::"),
+		netip.MustParseAddr("2001:db8::ff"),
+		row.Row{mmdbtype.String("DE")},
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(
+		t,
+		"42540766411282592856903984951653826560,42540766411282592856903984951653826815,DE\n",
+		buf.String(),
+	)
+}
+
+func TestCSVWriter_DecimalColumns(t *testing.T) {
+	buf := &bytes.Buffer{}
+	includeHeader := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			CSV: config.CSVConfig{Delimiter: ",", IncludeHeader: &includeHeader},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_decimal", Type: "start_decimal"},
+				{Name: "end_decimal", Type: "end_decimal"},
+			},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	w := NewCSVWriter(buf, cfg)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{mmdbtype.String("AU")}))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2001:db8::"),
+		netip.MustParseAddr("2001:db8::ff"),
+		row.Row{mmdbtype.String("DE")},
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(
+		t,
+		"16777216,16777471,AU\n"+
+			"42540766411282592856903984951653826560,42540766411282592856903984951653826815,DE\n",
+		buf.String(),
+	)
+}
+
+func TestCSVWriter_GeoIPLegacyProfile(t *testing.T) {
+	buf := &bytes.Buffer{}
+	includeHeader := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			CSV: config.CSVConfig{
+				Delimiter:     ",",
+				IncludeHeader: &includeHeader,
+				Profile:       "geoip-legacy",
+			},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_ip", Type: "start_ip"},
+				{Name: "end_ip", Type: "end_ip"},
+				{Name: "start_int", Type: "start_int"},
+				{Name: "end_int", Type: "end_int"},
+			},
+		},
+		Columns: []config.Column{{Name: "country_code"}, {Name: "country_name"}},
+	}
+
+	w := NewCSVWriter(buf, cfg)
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.0.0"),
+		netip.MustParseAddr("1.0.0.255"),
+		row.Row{mmdbtype.String("AU"), mmdbtype.String("Australia")},
+	))
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("2001:200::/32"),
+		row.Row{mmdbtype.String("JP"), mmdbtype.String("Japan")},
+	))
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("2.0.0.0/24"),
+		row.Row{mmdbtype.String("KR"), mmdbtype.String(`Korea, "Republic of"`)},
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(
+		t,
+		`"1.0.0.0","1.0.0.255","16777216","16777471","AU","Australia"`+"\n"+
+			`"2001:200::", "2001:200:ffff:ffff:ffff:ffff:ffff:ffff", `+
+			`"42540528726795050063891204319802818560", "42540528806023212578155541913346768895", `+
+			`"JP", "Japan"`+"\n"+
+			`"2.0.0.0","2.0.0.255","33554432","33554687","KR","Korea, ""Republic of"""`+"\n",
+		buf.String(),
+	)
+}
+
+func TestAppendUint128(t *testing.T) {
+	values := []string{
+		"0",
+		"1",
+		"18446744073709551615",
+		"18446744073709551616",
+		"10000000000000000000",
+		"99999999999999999999999999999999999999",
+		"100000000000000000000000000000000000000",
+		"340282366920938463463374607431768211455",
+	}
+
+	for _, value := range values {
+		n, ok := new(big.Int).SetString(value, 10)
+		require.True(t, ok)
+		var b [16]byte
+		n.FillBytes(b[:])
+
+		got := appendUint128(
+			nil,
+			binary.BigEndian.Uint64(b[:8]),
+			binary.BigEndian.Uint64(b[8:]),
+		)
+		assert.Equal(t, value, string(got))
+	}
+}
+
+func TestCSVWriter_ProvenanceHeader(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := newTestConfig("csv")
+	cfg.Output.CSV.ProvenanceHeader = true
+	cfg.Provenance = testProvenance
+
+	writer := NewCSVWriter(buf, cfg)
+	require.NoError(t, writer.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("AU")},
+	))
+	require.NoError(t, writer.Flush())
+
+	expected := "# mmdbconvert.version=1.2.3\n" +
+		"# mmdbconvert.config_sha256=abc123\n" +
+		"# mmdbconvert.source.city.database_type=GeoIP2-City\n" +
+		"# mmdbconvert.source.city.build_epoch=1700000000\n" +
+		"network,country\n" +
+		"1.0.0.0/24,AU\n"
+	assert.Equal(t, expected, buf.String())
+
+	// Without the option, the provenance is left out.
+	buf.Reset()
+	cfg.Output.CSV.ProvenanceHeader = false
+	writer = NewCSVWriter(buf, cfg)
+	require.NoError(t, writer.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("AU")},
+	))
+	require.NoError(t, writer.Flush())
+	assert.Equal(t, "network,country\n1.0.0.0/24,AU\n", buf.String())
+}
+
+func TestCSVWriter_ValidityColumns(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := newTestConfig("csv")
+	cfg.Network.Columns = append(
+		cfg.Network.Columns,
+		config.NetworkColumn{Name: "valid_from", Type: "valid_from"},
+		config.NetworkColumn{Name: "valid_to", Type: "valid_to"},
+	)
+
+	writer := NewCSVWriter(buf, cfg)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	data := row.Row{mmdbtype.String("AU")}
+	require.NoError(t, writer.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		data.WithValidity(from, to),
+	))
+	require.NoError(t, writer.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("NZ")}.WithValidity(to, time.Time{}),
+	))
+	require.NoError(t, writer.Flush())
+
+	expected := "network,valid_from,valid_to,country\n" +
+		"1.0.0.0/24,2025-01-01T00:00:00Z,2025-02-01T00:00:00Z,AU\n" +
+		"1.0.0.0/24,2025-02-01T00:00:00Z,,NZ\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestCSVWriter_Aliases(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			CSV: config.CSVConfig{Delimiter: ","},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "network", Type: "cidr", Aliases: config.Aliases{Header: "Network"}},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country", Aliases: config.Aliases{Header: "Country", StorageName: "cntry_cd"}},
+			{Name: "city", Aliases: config.Aliases{SQLName: "city_nm"}},
+		},
+	}
+
+	writer := NewCSVWriter(buf, cfg)
+	require.NoError(t, writer.WriteRow(netip.MustParsePrefix("10.0.0.0/24"), row.Row{
+		mmdbtype.String("US"),
+		mmdbtype.String("New York"),
+	}))
+	require.NoError(t, writer.Flush())
+
+	// Only headers rename CSV columns
+	assert.Equal(t, "Network,Country,city\n10.0.0.0/24,US,New York\n", buf.String())
+}
@@ -0,0 +1,248 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+// Parquet datasets are directories of part files named
+// part-NNNNN.parquet, which query engines read as a single table.
+const (
+	partFilePrefix = "part-"
+	partFileSuffix = ".parquet"
+)
+
+// dataTypeHints are the type hints tried, in order, when casting a data
+// column to the type it has in an existing dataset.
+var dataTypeHints = []string{"string", "int64", "float64", "bool", "binary"}
+
+// PrepareDatasetPart checks that the schema of the export is compatible with
+// every part file already in the Parquet dataset directory dir, and returns
+// the path of the next part file. The directory is created if needed.
+//
+// With output.parquet.schema_mismatch = "cast", data columns whose type
+// differs from the dataset get the dataset's type as their type hint, so
+// that their values are converted when written; cfg is updated accordingly.
+// Any other difference, such as a missing or renamed column, is an error.
+func PrepareDatasetPart(dir string, cfg *config.Config) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("creating dataset directory: %w", err)
+	}
+	parts, err := datasetParts(dir)
+	if err != nil {
+		return "", err
+	}
+
+	next := 0
+	for _, part := range parts {
+		next = part.number + 1
+
+		existing, err := readParquetSchema(part.path)
+		if err != nil {
+			return "", err
+		}
+		if err := alignSchema(existing, cfg); err != nil {
+			return "", fmt.Errorf("%s: %w", part.path, err)
+		}
+	}
+
+	return filepath.Join(dir, fmt.Sprintf("%s%05d%s", partFilePrefix, next, partFileSuffix)), nil
+}
+
+// PruneDataset removes the part files of the dataset directory dir that the
+// retention policy no longer keeps: parts other than the newest
+// retention.KeepLast, and parts last modified more than
+// retention.MaxAgeDays days before now. The newest part, normally the one
+// just written, is always kept, and files that are not part files are never
+// touched. It returns the paths of the removed parts.
+func PruneDataset(dir string, retention config.RetentionConfig, now time.Time) ([]string, error) {
+	parts, err := datasetParts(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	maxAge := time.Duration(retention.MaxAgeDays) * 24 * time.Hour
+	var removed []string
+	// Walk from the newest part, skipping it
+	for i := len(parts) - 2; i >= 0; i-- {
+		part := parts[i]
+		newer := len(parts) - 1 - i
+		expired := retention.MaxAgeDays > 0 && now.Sub(part.modified) > maxAge
+		if !expired && (retention.KeepLast == 0 || newer < retention.KeepLast) {
+			continue
+		}
+		if err := os.Remove(part.path); err != nil {
+			return removed, fmt.Errorf("removing dataset part: %w", err)
+		}
+		removed = append(removed, part.path)
+	}
+	return removed, nil
+}
+
+// datasetPart is a part file of a dataset directory.
+type datasetPart struct {
+	path     string
+	number   int
+	modified time.Time
+}
+
+// datasetParts lists the part files of dir, ordered by number.
+func datasetParts(dir string) ([]datasetPart, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading dataset directory: %w", err)
+	}
+
+	var parts []datasetPart
+	for _, entry := range entries {
+		n, ok := partNumber(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("reading dataset part: %w", err)
+		}
+		parts = append(parts, datasetPart{
+			path:     filepath.Join(dir, entry.Name()),
+			number:   n,
+			modified: info.ModTime(),
+		})
+	}
+	slices.SortFunc(parts, func(a, b datasetPart) int {
+		return a.number - b.number
+	})
+	return parts, nil
+}
+
+// partNumber returns the number of a part file name.
+func partNumber(name string) (int, bool) {
+	digits, ok := strings.CutPrefix(name, partFilePrefix)
+	if !ok {
+		return 0, false
+	}
+	digits, ok = strings.CutSuffix(digits, partFileSuffix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	return n, err == nil && n >= 0
+}
+
+func readParquetSchema(path string) (*parquet.Schema, error) {
+	// #nosec G304 -- the dataset directory comes from trusted configuration
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening dataset part: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading dataset part: %w", err)
+	}
+	file, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("reading Parquet schema of %s: %w", path, err)
+	}
+	return file.Schema(), nil
+}
+
+// alignSchema compares the schema built from cfg with existing, casting
+// data columns if the configuration allows it.
+func alignSchema(existing *parquet.Schema, cfg *config.Config) error {
+	schema, err := buildSchema(cfg, IPVersionAny)
+	if err != nil {
+		return fmt.Errorf("building schema: %w", err)
+	}
+
+	var errs []error
+	for _, field := range schema.Fields() {
+		if !slices.ContainsFunc(existing.Fields(), func(f parquet.Field) bool {
+			return f.Name() == field.Name()
+		}) {
+			errs = append(errs, fmt.Errorf("column '%s' is not in the dataset", field.Name()))
+		}
+	}
+	for _, want := range existing.Fields() {
+		got, ok := schemaField(schema, want.Name())
+		if !ok {
+			errs = append(errs, fmt.Errorf("dataset column '%s' is not in this export", want.Name()))
+			continue
+		}
+		if parquet.EqualNodes(got, want) {
+			continue
+		}
+		if err := castColumn(cfg, want); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func schemaField(schema *parquet.Schema, name string) (parquet.Field, bool) {
+	for _, field := range schema.Fields() {
+		if field.Name() == name {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// castColumn sets the type hint of the data column named like want to the
+// hint producing want's type, if casting is enabled.
+func castColumn(cfg *config.Config, want parquet.Field) error {
+	mismatch := fmt.Errorf(
+		"column '%s' has type %s in the dataset but %s in this export",
+		want.Name(),
+		want.Type(),
+		columnTypeName(cfg, want.Name()),
+	)
+	if cfg.Output.Parquet.SchemaMismatch != "cast" {
+		return mismatch
+	}
+
+	i := slices.IndexFunc(cfg.Columns, func(col config.Column) bool {
+		return col.OutputName("parquet") == want.Name()
+	})
+	if i < 0 {
+		// Network columns have a fixed type
+		return mismatch
+	}
+	for _, hint := range dataTypeHints {
+		col := cfg.Columns[i]
+		col.Type = hint
+		node, err := buildDataNode(col)
+		if err == nil && parquet.EqualNodes(node, want) {
+			cfg.Columns[i].Type = hint
+			return nil
+		}
+	}
+	return fmt.Errorf("%w, and cannot be cast", mismatch)
+}
+
+// columnTypeName describes the type of a column of the export.
+func columnTypeName(cfg *config.Config, name string) string {
+	schema, err := buildSchema(cfg, IPVersionAny)
+	if err != nil {
+		return "unknown"
+	}
+	field, ok := schemaField(schema, name)
+	if !ok {
+		return "unknown"
+	}
+	return field.Type().String()
+}
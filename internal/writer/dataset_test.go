@@ -0,0 +1,158 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func newDatasetConfig(columns ...config.Column) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{
+			Parquet: config.ParquetConfig{
+				Compression:    "snappy",
+				RowGroupSize:   500000,
+				Append:         true,
+				SchemaMismatch: "error",
+			},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_int", Type: "start_int"},
+				{Name: "end_int", Type: "end_int"},
+			},
+		},
+		Columns: columns,
+	}
+}
+
+// writeDatasetPart writes a part file with a single row.
+func writeDatasetPart(t *testing.T, path string, cfg *config.Config, r row.Row) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w, err := NewParquetWriter(f, cfg)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.Flush())
+}
+
+func TestPrepareDatasetPart(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dataset")
+
+	cfg := newDatasetConfig(config.Column{Name: "asn", Type: "int64"})
+	path, err := PrepareDatasetPart(dir, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "part-00000.parquet"), path)
+	writeDatasetPart(t, path, cfg, row.Row{mmdbtype.Uint32(13335)})
+
+	// Other files in the directory are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_SUCCESS"), nil, 0o600))
+
+	path, err = PrepareDatasetPart(dir, newDatasetConfig(config.Column{Name: "asn", Type: "int64"}))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "part-00001.parquet"), path)
+
+	t.Run("type mismatch", func(t *testing.T) {
+		_, err := PrepareDatasetPart(dir, newDatasetConfig(config.Column{Name: "asn"}))
+		require.ErrorContains(
+			t,
+			err,
+			"column 'asn' has type INT(64,true) in the dataset but STRING in this export",
+		)
+	})
+
+	t.Run("cast", func(t *testing.T) {
+		cfg := newDatasetConfig(config.Column{Name: "asn"})
+		cfg.Output.Parquet.SchemaMismatch = "cast"
+		_, err := PrepareDatasetPart(dir, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "int64", cfg.Columns[0].Type)
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		cfg := newDatasetConfig(config.Column{Name: "asn", Type: "int64"}, config.Column{Name: "org"})
+		cfg.Output.Parquet.SchemaMismatch = "cast"
+		_, err := PrepareDatasetPart(dir, cfg)
+		require.ErrorContains(t, err, "column 'org' is not in the dataset")
+	})
+
+	t.Run("network column mismatch", func(t *testing.T) {
+		cfg := newDatasetConfig(config.Column{Name: "asn", Type: "int64"})
+		cfg.Network.Columns[0].Type = "start_ip"
+		cfg.Output.Parquet.SchemaMismatch = "cast"
+		_, err := PrepareDatasetPart(dir, cfg)
+		require.ErrorContains(t, err, "column 'start_int' has type INT(64,true) in the dataset")
+	})
+}
+
+func TestPruneDataset(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	newDataset := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		// Parts 0 to 4, written a day apart, the newest now
+		for i := range 5 {
+			path := filepath.Join(dir, fmt.Sprintf("part-%05d.parquet", i))
+			require.NoError(t, os.WriteFile(path, nil, 0o600))
+			modified := now.Add(time.Duration(i-4) * 24 * time.Hour)
+			require.NoError(t, os.Chtimes(path, modified, modified))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o600))
+		return dir
+	}
+	remaining := func(t *testing.T, dir string) []string {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	t.Run("keep last", func(t *testing.T) {
+		dir := newDataset(t)
+		removed, err := PruneDataset(dir, config.RetentionConfig{KeepLast: 2}, now)
+		require.NoError(t, err)
+		assert.Len(t, removed, 3)
+		assert.Equal(
+			t,
+			[]string{"notes.txt", "part-00003.parquet", "part-00004.parquet"},
+			remaining(t, dir),
+		)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		dir := newDataset(t)
+		_, err := PruneDataset(dir, config.RetentionConfig{MaxAgeDays: 2}, now)
+		require.NoError(t, err)
+		assert.Equal(
+			t,
+			[]string{"notes.txt", "part-00002.parquet", "part-00003.parquet", "part-00004.parquet"},
+			remaining(t, dir),
+		)
+	})
+
+	t.Run("newest part is always kept", func(t *testing.T) {
+		dir := newDataset(t)
+		_, err := PruneDataset(dir, config.RetentionConfig{MaxAgeDays: 1}, now.Add(30*24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"notes.txt", "part-00004.parquet"}, remaining(t, dir))
+	})
+}
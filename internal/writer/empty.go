@@ -0,0 +1,63 @@
+package writer
+
+import (
+	"net/netip"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// EmptyColumnsWriter wraps a row writer and counts the values of each data
+// column, to find the columns left without a value in any row, which
+// usually have a misconfigured path.
+type EmptyColumnsWriter struct {
+	writer row.Writer
+	values []int64 // Non-null values of each data column
+}
+
+// NewEmptyColumnsWriter creates a writer counting the values of the first
+// columns values of each row, the data columns, passed on to writer.
+func NewEmptyColumnsWriter(writer row.Writer, columns int) *EmptyColumnsWriter {
+	return &EmptyColumnsWriter{writer: writer, values: make([]int64, columns)}
+}
+
+// WriteRow counts the values of a row and writes it.
+func (e *EmptyColumnsWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	e.count(r)
+	return e.writer.WriteRow(prefix, r)
+}
+
+// WriteRange counts the values of a range and writes it.
+func (e *EmptyColumnsWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	e.count(r)
+	return row.WriteRange(e.writer, start, end, r)
+}
+
+// Flush flushes the wrapped writer.
+func (e *EmptyColumnsWriter) Flush() error {
+	return row.Flush(e.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (e *EmptyColumnsWriter) Sync() error {
+	return row.Sync(e.writer)
+}
+
+func (e *EmptyColumnsWriter) count(r row.Row) {
+	for i := range e.values {
+		if i < len(r) && !r.IsNull(i) {
+			e.values[i]++
+		}
+	}
+}
+
+// EmptyColumns returns the indexes of the data columns without a value in
+// any row written so far.
+func (e *EmptyColumnsWriter) EmptyColumns() []int {
+	var empty []int
+	for i, n := range e.values {
+		if n == 0 {
+			empty = append(empty, i)
+		}
+	}
+	return empty
+}
@@ -0,0 +1,33 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestEmptyColumnsWriter(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewEmptyColumnsWriter(inner, 3)
+	assert.Equal(t, []int{0, 1, 2}, w.EmptyColumns())
+
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("AU"), nil, nil},
+	))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2.0.0.0"),
+		netip.MustParseAddr("2.0.1.255"),
+		row.Row{nil, nil, mmdbtype.Uint32(13335)},
+	))
+	assert.Equal(t, []int{1}, w.EmptyColumns())
+
+	// Rows are passed on unchanged
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, inner.rows)
+	assert.Len(t, inner.ranges, 1)
+}
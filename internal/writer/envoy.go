@@ -0,0 +1,129 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// EnvoyWriter writes the networks of the merge as a list of Envoy CidrRange
+// entries, with address_prefix and prefix_len fields, in YAML or JSON. The
+// list can be pasted into RBAC policies or IP tagging filters. Data columns
+// are not written; use output.filter to choose the networks.
+type EnvoyWriter struct {
+	out    io.Writer
+	config *config.Config
+	json   bool
+	opened bool
+	closed bool
+	count  int    // Entries written so far
+	buf    []byte // Serialized entries not yet written to out
+}
+
+// NewEnvoyWriter creates a new Envoy CIDR list writer.
+func NewEnvoyWriter(w io.Writer, cfg *config.Config) *EnvoyWriter {
+	return &EnvoyWriter{
+		out:    w,
+		config: cfg,
+		json:   cfg.Output.Envoy.Encoding == "json",
+		buf:    make([]byte, 0, aclFlushSize+4096),
+	}
+}
+
+// WriteRow writes a CidrRange entry for the network.
+func (w *EnvoyWriter) WriteRow(prefix netip.Prefix, _ row.Row) error {
+	if w.closed {
+		return errWriterClosed
+	}
+	w.ensureOpened()
+
+	if w.json {
+		if w.count > 0 {
+			w.buf = append(w.buf, ',')
+		}
+		w.buf = append(w.buf, "\n  {\"address_prefix\": \""...)
+		w.buf = prefix.Addr().AppendTo(w.buf)
+		w.buf = append(w.buf, "\", \"prefix_len\": "...)
+		w.buf = strconv.AppendInt(w.buf, int64(prefix.Bits()), 10)
+		w.buf = append(w.buf, '}')
+	} else {
+		// Quoted, as some YAML parsers read IPv6 addresses as other types
+		w.buf = append(w.buf, "- address_prefix: \""...)
+		w.buf = prefix.Addr().AppendTo(w.buf)
+		w.buf = append(w.buf, "\"\n  prefix_len: "...)
+		w.buf = strconv.AppendInt(w.buf, int64(prefix.Bits()), 10)
+		w.buf = append(w.buf, '\n')
+	}
+	w.count++
+
+	if len(w.buf) >= aclFlushSize {
+		return w.flushBuffer()
+	}
+	return nil
+}
+
+// ensureOpened writes the start of the document: the provenance as comments
+// for YAML, which JSON cannot hold, or the opening bracket for JSON.
+func (w *EnvoyWriter) ensureOpened() {
+	if w.opened {
+		return
+	}
+	w.opened = true
+	if w.json {
+		w.buf = append(w.buf, '[')
+		return
+	}
+	for _, pair := range w.config.Provenance.Pairs() {
+		w.buf = append(w.buf, "# "...)
+		w.buf = append(w.buf, pair.Key...)
+		w.buf = append(w.buf, '=')
+		w.buf = append(w.buf, pair.Value...)
+		w.buf = append(w.buf, '\n')
+	}
+}
+
+func (w *EnvoyWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("writing Envoy CIDR list: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush completes the document and writes all buffered data. No rows can be
+// written afterwards.
+func (w *EnvoyWriter) Flush() error {
+	if !w.closed {
+		w.ensureOpened()
+		switch {
+		case w.json && w.count > 0:
+			w.buf = append(w.buf, "\n]\n"...)
+		case w.json:
+			w.buf = append(w.buf, "]\n"...)
+		case w.count == 0:
+			// An empty YAML document would be null rather than a list
+			w.buf = append(w.buf, "[]\n"...)
+		}
+		w.closed = true
+	}
+	if err := w.flushBuffer(); err != nil {
+		return fmt.Errorf("envoy flush error: %w", err)
+	}
+	return nil
+}
+
+// Sync writes the entries so far, without completing the document, and
+// commits them to stable storage if the underlying writer supports it.
+func (w *EnvoyWriter) Sync() error {
+	if err := w.flushBuffer(); err != nil {
+		return err
+	}
+	return syncOutput(w.out)
+}
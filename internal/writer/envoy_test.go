@@ -0,0 +1,69 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+func TestEnvoyWriter(t *testing.T) {
+	tests := []struct {
+		encoding string
+		prefixes []string
+		expected string
+	}{
+		{
+			encoding: "yaml",
+			prefixes: []string{"1.0.0.0/24", "2001:db8::/32"},
+			expected: `- address_prefix: "1.0.0.0"
+  prefix_len: 24
+- address_prefix: "2001:db8::"
+  prefix_len: 32
+`,
+		},
+		{
+			encoding: "yaml",
+			expected: "[]\n",
+		},
+		{
+			encoding: "json",
+			prefixes: []string{"1.0.0.0/24", "2001:db8::/32"},
+			expected: `[
+  {"address_prefix": "1.0.0.0", "prefix_len": 24},
+  {"address_prefix": "2001:db8::", "prefix_len": 32}
+]
+`,
+		},
+		{
+			encoding: "json",
+			expected: "[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			var buf bytes.Buffer
+			cfg := &config.Config{
+				Output: config.OutputConfig{Envoy: config.EnvoyConfig{Encoding: tt.encoding}},
+			}
+			w := NewEnvoyWriter(&buf, cfg)
+			for _, prefix := range tt.prefixes {
+				require.NoError(t, w.WriteRow(netip.MustParsePrefix(prefix), nil))
+			}
+			require.NoError(t, w.Flush())
+			assert.Equal(t, tt.expected, buf.String())
+
+			if tt.encoding == "json" {
+				var ranges []map[string]any
+				require.NoError(t, json.Unmarshal(buf.Bytes(), &ranges))
+				assert.Len(t, ranges, len(tt.prefixes))
+			}
+		})
+	}
+}
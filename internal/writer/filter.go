@@ -0,0 +1,100 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// FilterWriter wraps a row writer and passes on only the rows whose data
+// columns have the values of output.filter.
+type FilterWriter struct {
+	writer     row.Writer
+	conditions []filterCondition
+}
+
+// filterCondition requires data column column to equal value, a string,
+// bool, int64, or float64 as decoded from TOML.
+type filterCondition struct {
+	column int
+	value  any
+}
+
+// NewFilterWriter creates a writer passing rows that match every value of
+// cfg.Output.Filter on to writer.
+func NewFilterWriter(writer row.Writer, cfg *config.Config) (*FilterWriter, error) {
+	var conditions []filterCondition
+	for name, value := range cfg.Output.Filter {
+		i := slices.IndexFunc(cfg.Columns, func(col config.Column) bool {
+			return string(col.Name) == name
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("filter column '%s' is not a configured data column", name)
+		}
+		conditions = append(conditions, filterCondition{column: i, value: value})
+	}
+	// Check the conditions in column order, for consistent behavior
+	slices.SortFunc(conditions, func(a, b filterCondition) int {
+		return a.column - b.column
+	})
+	return &FilterWriter{writer: writer, conditions: conditions}, nil
+}
+
+// WriteRow writes a single row if it matches.
+func (f *FilterWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if !f.matches(r) {
+		return nil
+	}
+	return f.writer.WriteRow(prefix, r)
+}
+
+// WriteRange writes a range if it matches.
+func (f *FilterWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !f.matches(r) {
+		return nil
+	}
+	return row.WriteRange(f.writer, start, end, r)
+}
+
+// Flush flushes the wrapped writer.
+func (f *FilterWriter) Flush() error {
+	return row.Flush(f.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (f *FilterWriter) Sync() error {
+	return row.Sync(f.writer)
+}
+
+func (f *FilterWriter) matches(r row.Row) bool {
+	for _, cond := range f.conditions {
+		if !valueMatches(r, cond.column, cond.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// valueMatches reports whether column i of r equals want. Integers match
+// regardless of their MMDB integer type.
+func valueMatches(r row.Row, i int, want any) bool {
+	switch want := want.(type) {
+	case string:
+		v, ok := r.String(i)
+		return ok && v == want
+	case bool:
+		v, ok := r.Bool(i)
+		return ok && v == want
+	case int64:
+		v, ok := r.Int64(i)
+		return ok && v == want
+	case float64:
+		v, ok := r.Float64(i)
+		return ok && v == want
+	default:
+		return false
+	}
+}
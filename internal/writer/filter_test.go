@@ -0,0 +1,51 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestFilterWriter(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Filter: map[string]any{"is_hosting": true, "asn": int64(13335)},
+		},
+		Columns: []config.Column{{Name: "country"}, {Name: "is_hosting"}, {Name: "asn"}},
+	}
+	inner := &rangeRecordWriter{}
+	w, err := NewFilterWriter(inner, cfg)
+	require.NoError(t, err)
+
+	rows := map[string]row.Row{
+		"1.0.0.0/24": {mmdbtype.String("AU"), mmdbtype.Bool(true), mmdbtype.Uint32(13335)},
+		"2.0.0.0/24": {mmdbtype.String("FR"), mmdbtype.Bool(false), mmdbtype.Uint32(13335)},
+		"3.0.0.0/24": {mmdbtype.String("US"), mmdbtype.Bool(true), mmdbtype.Uint32(15169)},
+		"4.0.0.0/24": {mmdbtype.String("US"), nil, mmdbtype.Uint32(13335)},
+	}
+	for _, network := range []string{"1.0.0.0/24", "2.0.0.0/24", "3.0.0.0/24", "4.0.0.0/24"} {
+		require.NoError(t, w.WriteRow(netip.MustParsePrefix(network), rows[network]))
+	}
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("5.0.0.0"),
+		netip.MustParseAddr("5.0.1.255"),
+		rows["1.0.0.0/24"],
+	))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("6.0.0.0"),
+		netip.MustParseAddr("6.0.1.255"),
+		rows["2.0.0.0/24"],
+	))
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, inner.rows)
+	assert.Equal(t, [][2]netip.Addr{{
+		netip.MustParseAddr("5.0.0.0"),
+		netip.MustParseAddr("5.0.1.255"),
+	}}, inner.ranges)
+}
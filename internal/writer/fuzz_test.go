@@ -0,0 +1,188 @@
+package writer
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// fuzzKeys are the map keys used by fuzzed records. Keeping the set small
+// makes collisions between the record and the merged value likely.
+var fuzzKeys = []string{"a", "b", "c", "d"}
+
+var fuzzPolicies = []string{
+	"",
+	ConflictPolicyError,
+	ConflictPolicyKeepExisting,
+	ConflictPolicyOverwrite,
+	ConflictPolicyConcatenate,
+}
+
+// fuzzInput builds records and paths from fuzzer-provided bytes. Once the
+// bytes run out, every read returns zero.
+type fuzzInput struct {
+	data []byte
+}
+
+func (in *fuzzInput) next() byte {
+	if len(in.data) == 0 {
+		return 0
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b
+}
+
+func (in *fuzzInput) value(depth int) mmdbtype.DataType {
+	b := in.next()
+	kinds := byte(5)
+	if depth >= 4 {
+		kinds = 3
+	}
+	switch b % kinds {
+	case 0:
+		return mmdbtype.String(fuzzKeys[int(in.next())%len(fuzzKeys)])
+	case 1:
+		return mmdbtype.Uint32(in.next())
+	case 2:
+		return mmdbtype.Bool(b&0x80 != 0)
+	case 3:
+		return in.mapValue(depth + 1)
+	default:
+		s := make(mmdbtype.Slice, in.next()%4)
+		for i := range s {
+			s[i] = in.value(depth + 1)
+		}
+		return s
+	}
+}
+
+func (in *fuzzInput) mapValue(depth int) mmdbtype.Map {
+	m := mmdbtype.Map{}
+	for range in.next() % 4 {
+		m[mmdbtype.String(fuzzKeys[int(in.next())%len(fuzzKeys)])] = in.value(depth)
+	}
+	return m
+}
+
+// parseFuzzPath splits spec on '/' into path segments, treating segments
+// that parse as integers as slice indexes.
+func parseFuzzPath(spec string) []any {
+	if spec == "" {
+		return nil
+	}
+	parts := strings.Split(spec, "/")
+	if len(parts) > 8 {
+		parts = parts[:8]
+	}
+	path := make([]any, len(parts))
+	for i, part := range parts {
+		if idx, err := strconv.Atoi(part); err == nil {
+			path[i] = idx
+		} else {
+			path[i] = part
+		}
+	}
+	return path
+}
+
+// lookupNested returns the value at path, or nil if there is none.
+func lookupNested(root mmdbtype.DataType, path []any) mmdbtype.DataType {
+	current := root
+	for _, segment := range path {
+		switch key := segment.(type) {
+		case string:
+			m, ok := current.(mmdbtype.Map)
+			if !ok {
+				return nil
+			}
+			current = m[mmdbtype.String(key)]
+		case int:
+			s, ok := current.(mmdbtype.Slice)
+			if !ok || key < 0 || key >= len(s) {
+				return nil
+			}
+			current = s[key]
+		}
+	}
+	return current
+}
+
+func FuzzMergeNestedValue(f *testing.F) {
+	f.Add([]byte{3, 1, 0, 0, 1}, []byte{1, 7}, "a/b", byte(0))
+	f.Add([]byte{3, 1, 1, 4, 2, 1, 1, 1, 2}, []byte{0, 3}, "b/2", byte(4))
+	f.Add([]byte{3, 2, 0, 3, 1, 1, 1, 5, 2, 0, 1}, []byte{3, 1, 1, 1, 9}, "a", byte(2))
+	f.Add([]byte{3, 1, 0, 0, 1}, []byte{3, 1, 0, 1, 9}, "", byte(3))
+
+	f.Fuzz(func(t *testing.T, rootData, valueData []byte, spec string, policyIndex byte) {
+		root := (&fuzzInput{data: rootData}).mapValue(0)
+		value := (&fuzzInput{data: valueData}).value(1)
+		path := parseFuzzPath(spec)
+		policy := fuzzPolicies[int(policyIndex)%len(fuzzPolicies)]
+
+		originalRoot := root.Copy()
+		originalValue := value.Copy()
+		result, err := mergeNestedValue(root, path, value, policy)
+		if !reflect.DeepEqual(originalRoot, root) {
+			t.Fatalf("mergeNestedValue modified the root: %v", root)
+		}
+		if !reflect.DeepEqual(originalValue, value) {
+			t.Fatalf("mergeNestedValue modified the value: %v", value)
+		}
+		if err != nil {
+			return
+		}
+
+		if len(path) == 0 {
+			for key := range value.(mmdbtype.Map) {
+				if _, ok := result[key]; !ok {
+					t.Fatalf("merging into the root dropped %s", key)
+				}
+			}
+			return
+		}
+
+		// Keys outside the path are left alone.
+		if first, ok := path[0].(string); ok {
+			for key, v := range root {
+				if key != mmdbtype.String(first) && !reflect.DeepEqual(result[key], v) {
+					t.Fatalf("%s changed from %v to %v", key, v, result[key])
+				}
+			}
+		}
+
+		got := lookupNested(result, path)
+		existing := lookupNested(root, path)
+		_, existingIsMap := existing.(mmdbtype.Map)
+		if valueMap, ok := value.(mmdbtype.Map); ok && (existing == nil || existingIsMap) {
+			gotMap, ok := got.(mmdbtype.Map)
+			if !ok {
+				t.Fatalf("merged map at %v is %T", path, got)
+			}
+			for key := range valueMap {
+				if _, ok := gotMap[key]; !ok {
+					t.Fatalf("merged map at %v dropped %s", path, key)
+				}
+			}
+			return
+		}
+
+		want := value
+		switch {
+		case existing == nil:
+		case policy == ConflictPolicyKeepExisting:
+			want = existing
+		case policy == ConflictPolicyConcatenate:
+			if _, ok := got.(mmdbtype.Slice); !ok {
+				t.Fatalf("concatenated value at %v is %T", path, got)
+			}
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("value at %v is %v, want %v", path, got, want)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// IgnoredError is a write error that was downgraded to a warning because
+// the rows overlap a network of output.ignore_errors.
+type IgnoredError struct {
+	Start, End netip.Addr // The range that was not written
+	Network    netip.Prefix
+	Reason     string
+	Err        error
+}
+
+func (e *IgnoredError) Error() string {
+	return fmt.Sprintf(
+		"skipped %s-%s, in ignored network %s (%s): %v",
+		e.Start,
+		e.End,
+		e.Network,
+		e.Reason,
+		e.Err,
+	)
+}
+
+func (e *IgnoredError) Unwrap() error {
+	return e.Err
+}
+
+// IgnoreErrorsWriter wraps a row writer and skips the rows that fail to be
+// written if they overlap a network of output.ignore_errors, reporting each
+// skipped row to a callback instead of failing.
+type IgnoreErrorsWriter struct {
+	writer   row.Writer
+	networks []ignoredNetwork
+	warn     func(*IgnoredError)
+	skipped  int
+}
+
+type ignoredNetwork struct {
+	network netip.Prefix
+	reason  string
+}
+
+// NewIgnoreErrorsWriter creates a writer ignoring the write errors of the
+// networks of cfg.Output.IgnoreErrors. warn is called for every skipped row
+// and may be nil.
+func NewIgnoreErrorsWriter(
+	writer row.Writer,
+	cfg *config.Config,
+	warn func(*IgnoredError),
+) (*IgnoreErrorsWriter, error) {
+	networks := make([]ignoredNetwork, 0, len(cfg.Output.IgnoreErrors))
+	for _, entry := range cfg.Output.IgnoreErrors {
+		network, err := netip.ParsePrefix(entry.Network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore_errors network '%s': %w", entry.Network, err)
+		}
+		networks = append(networks, ignoredNetwork{network: network.Masked(), reason: entry.Reason})
+	}
+	return &IgnoreErrorsWriter{writer: writer, networks: networks, warn: warn}, nil
+}
+
+// WriteRow writes a single row, ignoring errors in ignored networks.
+func (w *IgnoreErrorsWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	err := w.writer.WriteRow(prefix, r)
+	if err == nil {
+		return nil
+	}
+	return w.ignore(prefix.Masked().Addr(), netipx.PrefixLastIP(prefix), err)
+}
+
+// WriteRange writes a range, ignoring errors in ignored networks.
+func (w *IgnoreErrorsWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	err := row.WriteRange(w.writer, start, end, r)
+	if err == nil {
+		return nil
+	}
+	return w.ignore(start, end, err)
+}
+
+// Flush flushes the wrapped writer.
+func (w *IgnoreErrorsWriter) Flush() error {
+	return row.Flush(w.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (w *IgnoreErrorsWriter) Sync() error {
+	return row.Sync(w.writer)
+}
+
+// Skipped returns the number of rows skipped because of ignored errors.
+func (w *IgnoreErrorsWriter) Skipped() int {
+	return w.skipped
+}
+
+// ignore returns err unless the range from start to end overlaps an ignored
+// network, in which case the error is reported to the callback.
+func (w *IgnoreErrorsWriter) ignore(start, end netip.Addr, err error) error {
+	rng := netipx.IPRangeFrom(start, end)
+	for _, n := range w.networks {
+		if !netipx.RangeOfPrefix(n.network).Overlaps(rng) {
+			continue
+		}
+		w.skipped++
+		if w.warn != nil {
+			w.warn(&IgnoredError{
+				Start:   start,
+				End:     end,
+				Network: n.network,
+				Reason:  n.reason,
+				Err:     err,
+			})
+		}
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package writer
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+var errBrokenRecord = errors.New("broken record")
+
+// brokenWriter fails to write rows whose first column is "broken".
+type brokenWriter struct {
+	rangeRecordWriter
+}
+
+func (w *brokenWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if r[0] == mmdbtype.String("broken") {
+		return errBrokenRecord
+	}
+	return w.rangeRecordWriter.WriteRow(prefix, r)
+}
+
+func (w *brokenWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if r[0] == mmdbtype.String("broken") {
+		return errBrokenRecord
+	}
+	return w.rangeRecordWriter.WriteRange(start, end, r)
+}
+
+func TestIgnoreErrorsWriter(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			IgnoreErrors: []config.IgnoreErrorsConfig{
+				{Network: "1.2.3.0/24", Reason: "vendor test record"},
+			},
+		},
+	}
+	inner := &brokenWriter{}
+	var warnings []*IgnoredError
+	w, err := NewIgnoreErrorsWriter(inner, cfg, func(e *IgnoredError) {
+		warnings = append(warnings, e)
+	})
+	require.NoError(t, err)
+
+	ok := row.Row{mmdbtype.String("ok")}
+	broken := row.Row{mmdbtype.String("broken")}
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), ok))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.2.3.128/25"), broken))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.2.2.0"),
+		netip.MustParseAddr("1.2.3.0"),
+		broken,
+	))
+
+	err = w.WriteRow(netip.MustParsePrefix("1.2.4.0/24"), broken)
+	require.ErrorIs(t, err, errBrokenRecord, "errors outside ignored networks fail")
+	err = w.WriteRange(netip.MustParseAddr("9.0.0.0"), netip.MustParseAddr("9.0.0.255"), broken)
+	require.ErrorIs(t, err, errBrokenRecord)
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, inner.rows)
+	assert.Equal(t, 2, w.Skipped())
+	require.Len(t, warnings, 2)
+	assert.Equal(t, netip.MustParsePrefix("1.2.3.0/24"), warnings[0].Network)
+	assert.Equal(t, netip.MustParseAddr("1.2.3.128"), warnings[0].Start)
+	assert.Equal(t, netip.MustParseAddr("1.2.3.255"), warnings[0].End)
+	assert.Equal(t, "vendor test record", warnings[0].Reason)
+	require.ErrorIs(t, warnings[1], errBrokenRecord)
+	assert.Equal(
+		t,
+		"skipped 1.2.2.0-1.2.3.0, in ignored network 1.2.3.0/24 (vendor test record): broken record",
+		warnings[1].Error(),
+	)
+}
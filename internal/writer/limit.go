@@ -0,0 +1,112 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// LimitError reports that an output reached a limit of output.limits.
+type LimitError struct {
+	Limit string // "max_rows" or "max_bytes"
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("output.limits.%s of %d reached", e.Limit, e.Value)
+}
+
+// LimitWriter wraps a row writer and stops writing rows once the output has
+// reached a limit of output.limits: either the export fails, or the rows
+// beyond the limit are left out and a callback is warned once.
+type LimitWriter struct {
+	writer   row.Writer
+	maxRows  int64
+	maxBytes int64
+	written  *atomic.Int64 // Bytes written to the output files
+	truncate bool
+	warn     func(*LimitError)
+
+	rows    int64
+	dropped int64
+}
+
+// NewLimitWriter creates a writer applying cfg.Output.Limits to writer.
+// written counts the bytes written to the output files, and may be nil
+// without a byte limit. warn is called when rows are first left out and may
+// be nil.
+func NewLimitWriter(
+	writer row.Writer,
+	cfg *config.Config,
+	written *atomic.Int64,
+	warn func(*LimitError),
+) *LimitWriter {
+	limits := cfg.Output.Limits
+	return &LimitWriter{
+		writer:   writer,
+		maxRows:  limits.MaxRows,
+		maxBytes: limits.MaxBytes,
+		written:  written,
+		truncate: limits.OnExceed == config.LimitTruncate,
+		warn:     warn,
+	}
+}
+
+// WriteRow writes a row unless a limit has been reached.
+func (l *LimitWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if ok, err := l.allow(); !ok {
+		return err
+	}
+	return l.writer.WriteRow(prefix, r)
+}
+
+// WriteRange writes a range, counted as one row, unless a limit has been
+// reached.
+func (l *LimitWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if ok, err := l.allow(); !ok {
+		return err
+	}
+	return row.WriteRange(l.writer, start, end, r)
+}
+
+// allow reports whether the next row can be written. If not, the error is
+// that of the limit, or nil if the row is left out.
+func (l *LimitWriter) allow() (bool, error) {
+	var exceeded *LimitError
+	switch {
+	case l.maxRows > 0 && l.rows >= l.maxRows:
+		exceeded = &LimitError{Limit: "max_rows", Value: l.maxRows}
+	case l.maxBytes > 0 && l.written != nil && l.written.Load() >= l.maxBytes:
+		exceeded = &LimitError{Limit: "max_bytes", Value: l.maxBytes}
+	default:
+		l.rows++
+		return true, nil
+	}
+
+	if !l.truncate {
+		return false, exceeded
+	}
+	if l.dropped == 0 && l.warn != nil {
+		l.warn(exceeded)
+	}
+	l.dropped++
+	return false, nil
+}
+
+// Dropped returns the number of rows left out after a limit was reached.
+func (l *LimitWriter) Dropped() int64 {
+	return l.dropped
+}
+
+// Flush flushes the wrapped writer.
+func (l *LimitWriter) Flush() error {
+	return row.Flush(l.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (l *LimitWriter) Sync() error {
+	return row.Sync(l.writer)
+}
@@ -0,0 +1,81 @@
+package writer
+
+import (
+	"net/netip"
+	"sync/atomic"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func limitConfig(limits config.LimitsConfig) *config.Config {
+	return &config.Config{Output: config.OutputConfig{Limits: limits}}
+}
+
+func TestLimitWriter_MaxRowsAbort(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewLimitWriter(inner, limitConfig(config.LimitsConfig{
+		MaxRows:  2,
+		OnExceed: config.LimitAbort,
+	}), nil, nil)
+
+	r := row.Row{mmdbtype.String("US")}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.1.0"),
+		netip.MustParseAddr("1.0.2.255"),
+		r,
+	))
+	err := w.WriteRow(netip.MustParsePrefix("1.0.3.0/24"), r)
+	require.EqualError(t, err, "output.limits.max_rows of 2 reached")
+
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "max_rows", limitErr.Limit)
+	assert.Len(t, inner.rows, 1)
+	assert.Len(t, inner.ranges, 1)
+}
+
+func TestLimitWriter_MaxRowsTruncate(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	var warnings []string
+	w := NewLimitWriter(inner, limitConfig(config.LimitsConfig{
+		MaxRows:  1,
+		OnExceed: config.LimitTruncate,
+	}), nil, func(e *LimitError) {
+		warnings = append(warnings, e.Error())
+	})
+
+	for i := range 3 {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{1, 0, byte(i), 0}), 24)
+		require.NoError(t, w.WriteRow(prefix, row.Row{mmdbtype.String("US")}))
+	}
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, inner.rows)
+	assert.Equal(t, []string{"output.limits.max_rows of 1 reached"}, warnings)
+	assert.Equal(t, int64(2), w.Dropped())
+}
+
+func TestLimitWriter_MaxBytes(t *testing.T) {
+	var written atomic.Int64
+	inner := &rangeRecordWriter{}
+	w := NewLimitWriter(inner, limitConfig(config.LimitsConfig{
+		MaxBytes: 100,
+		OnExceed: config.LimitAbort,
+	}), &written, nil)
+
+	r := row.Row{mmdbtype.String("US")}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	written.Store(99)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.1.0/24"), r))
+	written.Store(100)
+	err := w.WriteRow(netip.MustParsePrefix("1.0.2.0/24"), r)
+	require.EqualError(t, err, "output.limits.max_bytes of 100 reached")
+	assert.Len(t, inner.rows, 2)
+}
@@ -1,23 +1,109 @@
 package writer
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/maphash"
+	"io"
 	"maps"
 	"net/netip"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/inserter"
 	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang/v2"
 	"go4.org/netipx"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/mmdb"
+	"github.com/maxmind/mmdbconvert/internal/provenance"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/internal/template"
+	"github.com/maxmind/mmdbconvert/network"
 )
 
+// Conflict policies control what happens when a column's value collides with
+// data already placed at the same key of an MMDB output record.
+const (
+	// ConflictPolicyError fails on any collision.
+	ConflictPolicyError = "error"
+	// ConflictPolicyKeepExisting keeps the value written first.
+	ConflictPolicyKeepExisting = "keep_existing"
+	// ConflictPolicyOverwrite replaces the existing value.
+	ConflictPolicyOverwrite = "overwrite"
+	// ConflictPolicyConcatenate collects both values into a slice.
+	ConflictPolicyConcatenate = "concatenate"
+)
+
+// insertStrategies maps output.mmdb.insert_strategy values to the functions
+// used to combine merged rows with data loaded from a base database.
+var insertStrategies = map[string]inserter.FuncGenerator{
+	"replace":         inserter.ReplaceWith,
+	"top_level_merge": inserter.TopLevelMergeWith,
+	"deep_merge":      inserter.DeepMergeWith,
+}
+
+// InsertError is a network the MMDB tree rejected, such as a reserved
+// network, that was left out under output.mmdb.on_insert_error = "skip".
+type InsertError struct {
+	Network netip.Prefix
+	Err     error
+}
+
+func (e *InsertError) Error() string {
+	return fmt.Sprintf("skipped %s, which could not be inserted: %v", e.Network, e.Err)
+}
+
+func (e *InsertError) Unwrap() error {
+	return e.Err
+}
+
 // MMDBWriter writes merged MMDB data to MMDB format.
 type MMDBWriter struct {
 	tree     *mmdbwriter.Tree
 	config   *config.Config
 	filePath string
+	out      io.Writer // Written to instead of filePath when set
+	wrapFile func(io.Writer) io.Writer
+
+	// skipInsertErrors leaves out the networks the tree rejects, telling
+	// reportInsert, if set, of each.
+	skipInsertErrors bool
+	reportInsert     func(*InsertError)
+	skippedInserts   int
+
+	// skipIPv6 drops IPv6 rows when building an IPv4 tree.
+	skipIPv6 bool
+
+	// templateFields holds, per column, the output template field the column
+	// fills, or nil if there is no template or the column isn't part of it.
+	templateFields []*template.Field
+
+	// nestedCache memoizes buildNestedData by a hash of the flat row, so that
+	// the many networks sharing the same data also share one nested record
+	// instead of rebuilding it per row. It is a direct-mapped table of
+	// nestedCacheSize slots, in which a row replaces the row of its slot, so
+	// that it holds recent rows rather than every distinct row of the run.
+	// It is nil when caching is disabled.
+	nestedCache []nestedEntry
+	hashSeed    maphash.Seed
+	hashBuf     []byte // Scratch space for the serialized row being hashed
+}
+
+// nestedCacheSize is the number of slots of the nested record cache.
+// Networks sharing data are mostly adjacent, so a small table catches most
+// repeats.
+const nestedCacheSize = 4096
+
+// nestedEntry is a cached nested record and the flat row it was built from.
+type nestedEntry struct {
+	key    uint64
+	flat   row.Row // Nil for an empty slot
+	nested mmdbtype.Map
 }
 
 // NewMMDBWriter creates a new MMDB writer.
@@ -26,79 +112,265 @@ func NewMMDBWriter(outputPath string, cfg *config.Config, ipVersion int) (*MMDBW
 		return nil, fmt.Errorf("invalid IP version: %d", ipVersion)
 	}
 
-	tree, err := mmdbwriter.New(mmdbwriter.Options{
+	description, err := mmdbDescription(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := mmdbwriter.Options{
 		DatabaseType:            cfg.Output.MMDB.DatabaseType,
-		Description:             cfg.Output.MMDB.Description,
+		Description:             description,
 		Languages:               cfg.Output.MMDB.Languages,
 		RecordSize:              *cfg.Output.MMDB.RecordSize,
 		IPVersion:               ipVersion,
 		IncludeReservedNetworks: *cfg.Output.MMDB.IncludeReservedNetworks,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("creating MMDB tree: %w", err)
 	}
 
-	return &MMDBWriter{
-		tree:     tree,
-		config:   cfg,
-		filePath: outputPath,
-	}, nil
+	var tree *mmdbwriter.Tree
+	if base := cfg.Output.MMDB.Base; base != "" {
+		opts.Inserter = insertStrategies[cfg.Output.MMDB.InsertStrategy]
+		// Use IPv6 when the merged data needs it; otherwise keep the base's
+		// IP version so that all of its networks can be loaded.
+		if ipVersion == 4 {
+			opts.IPVersion = 0
+		}
+		tree, err = mmdbwriter.Load(base, opts)
+		if err != nil {
+			return nil, fmt.Errorf("loading base MMDB: %w", err)
+		}
+	} else {
+		tree, err = mmdbwriter.New(opts)
+		if err != nil {
+			return nil, fmt.Errorf("creating MMDB tree: %w", err)
+		}
+	}
+
+	w := &MMDBWriter{
+		tree:             tree,
+		config:           cfg,
+		filePath:         outputPath,
+		skipIPv6:         ipVersion == 4 && cfg.Output.MMDB.Base == "",
+		skipInsertErrors: cfg.Output.MMDB.OnInsertError == config.InsertErrorSkip,
+	}
+	if !cfg.DisableCache {
+		w.nestedCache = make([]nestedEntry, nestedCacheSize)
+		w.hashSeed = maphash.MakeSeed()
+	}
+	w.templateFields = mmdbTemplateFields(cfg)
+
+	return w, nil
+}
+
+// mmdbTemplateFields returns the output template field each column of cfg
+// fills, or nil if there is no template.
+func mmdbTemplateFields(cfg *config.Config) []*template.Field {
+	tmpl, ok := template.Lookup(cfg.Output.MMDB.Template)
+	if !ok {
+		return nil
+	}
+	fields := make([]*template.Field, len(cfg.Columns))
+	for i, col := range cfg.Columns {
+		if field, ok := tmpl.Field(string(col.Name)); ok {
+			fields[i] = &field
+		}
+	}
+	return fields
+}
+
+// MMDBRecord returns the record the MMDB output of cfg holds for a row.
+func MMDBRecord(cfg *config.Config, data row.Row) (mmdbtype.Map, error) {
+	w := &MMDBWriter{config: cfg, templateFields: mmdbTemplateFields(cfg)}
+	return w.buildNestedData(data)
+}
+
+// mmdbDescription returns the configured description with the provenance
+// added. MMDB metadata has no place for other keys, so the provenance is
+// stored in the description map, where its prefixed keys cannot be mistaken
+// for language codes. Without a configured description, a base database
+// keeps its own.
+func mmdbDescription(cfg *config.Config) (map[string]string, error) {
+	pairs := cfg.Provenance.Pairs()
+	if len(pairs) == 0 {
+		return cfg.Output.MMDB.Description, nil
+	}
+
+	configured := cfg.Output.MMDB.Description
+	if configured == nil && cfg.Output.MMDB.Base != "" {
+		reader, err := maxminddb.Open(cfg.Output.MMDB.Base)
+		if err != nil {
+			return nil, fmt.Errorf("reading base MMDB metadata: %w", err)
+		}
+		// Provenance of an earlier build no longer applies
+		configured = maps.Clone(reader.Metadata.Description)
+		reader.Close()
+		maps.DeleteFunc(configured, func(key, _ string) bool {
+			return strings.HasPrefix(key, provenance.KeyPrefix)
+		})
+	}
+
+	description := make(map[string]string, len(configured)+len(pairs))
+	maps.Copy(description, configured)
+	for _, pair := range pairs {
+		description[pair.Key] = pair.Value
+	}
+	return description, nil
 }
 
 // WriteRow writes a single row with network prefix and column data.
-func (w *MMDBWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error {
-	nested, err := w.buildNestedData(data)
-	if err != nil {
-		return fmt.Errorf("building nested data: %w", err)
+func (w *MMDBWriter) WriteRow(prefix netip.Prefix, data row.Row) error {
+	if w.skipIPv6 && !prefix.Addr().Is4() {
+		return nil
 	}
 
-	ipnet := netipx.PrefixIPNet(prefix)
-	if err := w.tree.Insert(ipnet, nested); err != nil {
-		return fmt.Errorf("inserting %s: %w", prefix, err)
+	nested, err := w.nestedData(data)
+	if err != nil {
+		return fmt.Errorf("building nested data: %w", err)
 	}
 
-	return nil
+	return w.insertFailed(prefix, w.tree.Insert(netipx.PrefixIPNet(prefix), nested))
 }
 
 // WriteRange writes a range of IP addresses with the same data.
-func (w *MMDBWriter) WriteRange(start, end netip.Addr, data []mmdbtype.DataType) error {
-	nested, err := w.buildNestedData(data)
+func (w *MMDBWriter) WriteRange(start, end netip.Addr, data row.Row) error {
+	if w.skipIPv6 && !start.Is4() {
+		return nil
+	}
+
+	nested, err := w.nestedData(data)
 	if err != nil {
 		return fmt.Errorf("building nested data: %w", err)
 	}
 
-	cidrs := netipx.IPRangeFrom(start, end).Prefixes()
+	cidrs := network.RangeToPrefixes(start, end)
 	for _, cidr := range cidrs {
-		ipnet := netipx.PrefixIPNet(cidr)
-		if err := w.tree.Insert(ipnet, nested); err != nil {
-			switch err.(type) {
-			case *mmdbwriter.ReservedNetworkError:
-				continue // Skip reserved IP ranges from databases provided by some government organizations
-			default:
-				return fmt.Errorf("inserting %s: %w", cidr, err)
-			}
+		err := w.tree.Insert(netipx.PrefixIPNet(cidr), nested)
+		if _, ok := err.(*mmdbwriter.ReservedNetworkError); ok {
+			continue // Skip reserved IP ranges from databases provided by some government organizations
+		}
+		if err := w.insertFailed(cidr, err); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// Flush writes the MMDB tree to disk.
+// insertFailed returns the error of inserting prefix into the tree, if any,
+// unless insert errors are skipped.
+func (w *MMDBWriter) insertFailed(prefix netip.Prefix, err error) error {
+	if err == nil {
+		return nil
+	}
+	if !w.skipInsertErrors {
+		return fmt.Errorf("inserting %s: %w", prefix, err)
+	}
+	w.skippedInserts++
+	if w.reportInsert != nil {
+		w.reportInsert(&InsertError{Network: prefix, Err: err})
+	}
+	return nil
+}
+
+// ReportSkippedInserts sets the function told of each network left out
+// under output.mmdb.on_insert_error = "skip". It is called from the
+// goroutine writing the rows.
+func (w *MMDBWriter) ReportSkippedInserts(report func(*InsertError)) {
+	w.reportInsert = report
+}
+
+// SkippedInserts returns the number of networks left out under
+// output.mmdb.on_insert_error = "skip".
+func (w *MMDBWriter) SkippedInserts() int {
+	return w.skippedInserts
+}
+
+// Flush writes the MMDB tree to disk, or to the writer it was created with
+// by New.
 func (w *MMDBWriter) Flush() error {
+	if w.out != nil {
+		if _, err := w.WriteTo(w.out); err != nil {
+			return fmt.Errorf("writing MMDB: %w", err)
+		}
+		return nil
+	}
+
 	f, err := os.Create(w.filePath)
 	if err != nil {
 		return fmt.Errorf("creating output file: %w", err)
 	}
 	defer f.Close()
 
-	_, err = w.tree.WriteTo(f)
-	if err != nil {
+	var out io.Writer = f
+	if w.wrapFile != nil {
+		out = w.wrapFile(f)
+	}
+	if _, err := w.WriteTo(out); err != nil {
 		return fmt.Errorf("writing MMDB to file: %w", err)
 	}
 
 	return nil
 }
 
+// WrapFile sets a function that wraps the output file once it is created by
+// Flush, for example to limit the write rate.
+func (w *MMDBWriter) WrapFile(wrap func(io.Writer) io.Writer) {
+	w.wrapFile = wrap
+}
+
+// WriteTo serializes the MMDB tree to out.
+func (w *MMDBWriter) WriteTo(out io.Writer) (int64, error) {
+	n, err := w.tree.WriteTo(out)
+	if err != nil {
+		return n, fmt.Errorf("serializing MMDB tree: %w", err)
+	}
+	return n, nil
+}
+
+// nestedData returns the nested record for a flat row, reusing the record
+// built for an identical earlier row when possible. The returned map is
+// shared and must not be modified.
+func (w *MMDBWriter) nestedData(data row.Row) (mmdbtype.Map, error) {
+	if w.nestedCache == nil {
+		return w.buildNestedData(data)
+	}
+
+	key, ok := w.hashRow(data)
+	if !ok {
+		return w.buildNestedData(data)
+	}
+	entry := &w.nestedCache[key%uint64(len(w.nestedCache))]
+	if entry.flat != nil && entry.key == key && entry.flat.Equal(data) {
+		return entry.nested, nil
+	}
+
+	nested, err := w.buildNestedData(data)
+	if err != nil {
+		return nil, err
+	}
+	// The row is only valid during the write, so keep a copy for comparison
+	*entry = nestedEntry{key: key, flat: slices.Clone(data), nested: nested}
+	return nested, nil
+}
+
+// hashRow hashes the kind and text of every column. Values of different
+// types can share a hash, so cache hits are confirmed with row.Equal. It
+// returns false if a value cannot be represented as text.
+func (w *MMDBWriter) hashRow(data row.Row) (uint64, bool) {
+	w.hashBuf = w.hashBuf[:0]
+	for i := range data {
+		// Prefix the text with its kind and length to keep columns apart
+		w.hashBuf = append(w.hashBuf, byte(data.Kind(i)), 0, 0, 0, 0)
+		start := len(w.hashBuf)
+		var err error
+		w.hashBuf, err = data.AppendText(w.hashBuf, i)
+		if err != nil {
+			return 0, false
+		}
+		binary.LittleEndian.PutUint32(w.hashBuf[start-4:start], uint32(len(w.hashBuf)-start))
+	}
+	return maphash.Bytes(w.hashSeed, w.hashBuf), true
+}
+
 // buildNestedData converts flat column data to nested mmdbtype.Map.
 func (w *MMDBWriter) buildNestedData(flatData []mmdbtype.DataType) (mmdbtype.Map, error) {
 	root := make(mmdbtype.Map)
@@ -109,14 +381,27 @@ func (w *MMDBWriter) buildNestedData(flatData []mmdbtype.DataType) (mmdbtype.Map
 			continue
 		}
 
+		// Convert to the type client libraries expect for template fields
+		if w.templateFields != nil && w.templateFields[i] != nil {
+			var err error
+			value, err = w.templateFields[i].Kind.Coerce(value)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", col.Name, err)
+			}
+		}
+
 		// Use output_path if set, otherwise use [name] for flat structure
 		path := col.OutputPath
 		if path == nil {
-			path = &config.Path{col.Name}
+			path = &config.Path{string(col.Name)}
 		}
 
-		var err error
-		root, err = mergeNestedValue(root, path.Segments(), value)
+		// Normalize integer segments, which TOML decodes as int64
+		segments, err := mmdb.NormalizeSegments(*path)
+		if err != nil {
+			return nil, fmt.Errorf("normalizing output_path for column %s: %w", col.Name, err)
+		}
+		root, err = mergeNestedValue(root, segments, value, col.ConflictPolicy)
 		if err != nil {
 			return nil, fmt.Errorf("setting column %s: %w", col.Name, err)
 		}
@@ -127,11 +412,15 @@ func (w *MMDBWriter) buildNestedData(flatData []mmdbtype.DataType) (mmdbtype.Map
 
 // mergeNestedValue returns a new map with value merged at the specified path.
 // If the value is a Map and a Map already exists at the target location, they are merged.
+// Collisions are resolved according to policy; when policy is empty, conflicting
+// keys within merged maps are an error while a non-map value replaces any
+// existing value.
 // Neither root nor value are modified.
 func mergeNestedValue(
 	root mmdbtype.Map,
 	path []any,
 	value mmdbtype.DataType,
+	policy string,
 ) (mmdbtype.Map, error) {
 	// Special case: empty path means merge into root
 	if len(path) == 0 {
@@ -142,82 +431,151 @@ func mergeNestedValue(
 				value,
 			)
 		}
-		return mergeMaps(root, valueMap)
+		return mergeMaps(root, valueMap, policy)
 	}
 
-	// Copy root to avoid mutation
-	result := make(mmdbtype.Map, len(root))
-	maps.Copy(result, root)
+	result, err := setNestedValue(root, path, 0, value, policy)
+	if err != nil {
+		return nil, err
+	}
+	return result.(mmdbtype.Map), nil
+}
 
-	// Navigate to parent, copying and creating nested maps as needed
-	current := result
-	for i := range len(path) - 1 {
-		key, ok := path[i].(string)
-		if !ok {
-			return nil, fmt.Errorf("non-string key: %v", path[i])
+// setNestedValue returns a copy of container with value set at path[depth:].
+// String segments index into maps and integer segments index into slices; missing
+// containers are created along the way. A slice may be extended by at most
+// one element at a time so that no gaps are left in the output.
+func setNestedValue(
+	container mmdbtype.DataType,
+	path []any,
+	depth int,
+	value mmdbtype.DataType,
+	policy string,
+) (mmdbtype.DataType, error) {
+	last := depth == len(path)-1
+	switch seg := path[depth].(type) {
+	case string:
+		var result mmdbtype.Map
+		switch c := container.(type) {
+		case nil:
+			result = make(mmdbtype.Map)
+		case mmdbtype.Map:
+			// Copy to avoid mutation
+			result = make(mmdbtype.Map, len(c)+1)
+			maps.Copy(result, c)
+		default:
+			return nil, fmt.Errorf("path conflict at %s: expected map, got %T", seg, container)
 		}
 
-		mmdbKey := mmdbtype.String(key)
-
-		if existing, exists := current[mmdbKey]; exists {
-			// Must be a map to navigate further
-			existingMap, ok := existing.(mmdbtype.Map)
-			if !ok {
-				return nil, fmt.Errorf("path conflict at %s: expected map, got %T", key, existing)
+		key := mmdbtype.String(seg)
+		existing := result[key]
+		if !last {
+			next, err := setNestedValue(existing, path, depth+1, value, policy)
+			if err != nil {
+				return nil, err
 			}
-			// Copy the nested map to avoid mutation
-			next := make(mmdbtype.Map, len(existingMap))
-			maps.Copy(next, existingMap)
-			current[mmdbKey] = next
-			current = next
+			result[key] = next
+			return result, nil
+		}
+		leaf, err := setLeafValue(key, existing, value, path, policy)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = leaf
+		return result, nil
+
+	case int:
+		var result mmdbtype.Slice
+		switch c := container.(type) {
+		case nil:
+		case mmdbtype.Slice:
+			// Copy to avoid mutation
+			result = make(mmdbtype.Slice, len(c), len(c)+1)
+			copy(result, c)
+		default:
+			return nil, fmt.Errorf("path conflict at %d: expected slice, got %T", seg, container)
+		}
+
+		if seg < 0 || seg > len(result) {
+			return nil, fmt.Errorf(
+				"slice index %d out of range: slice has %d elements",
+				seg,
+				len(result),
+			)
+		}
+		var existing mmdbtype.DataType
+		if seg == len(result) {
+			result = append(result, nil)
+		} else {
+			existing = result[seg]
+		}
+
+		var err error
+		if !last {
+			result[seg], err = setNestedValue(existing, path, depth+1, value, policy)
 		} else {
-			// Create new nested map
-			next := make(mmdbtype.Map)
-			current[mmdbKey] = next
-			current = next
+			result[seg], err = setLeafValue(
+				mmdbtype.String(strconv.Itoa(seg)),
+				existing,
+				value,
+				path,
+				policy,
+			)
 		}
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	default:
+		return nil, fmt.Errorf("invalid path segment: %v", path[depth])
 	}
+}
 
-	// Handle final key
-	finalKey, ok := path[len(path)-1].(string)
-	if !ok {
-		return nil, fmt.Errorf("non-string final key: %v", path[len(path)-1])
+// setLeafValue returns the value to store at the final path segment given
+// the existing value there, which is nil if the location is empty.
+func setLeafValue(
+	key mmdbtype.String,
+	existing, value mmdbtype.DataType,
+	path []any,
+	policy string,
+) (mmdbtype.DataType, error) {
+	if existing == nil {
+		return value, nil
 	}
 
-	mmdbKey := mmdbtype.String(finalKey)
+	// If value is a Map and a Map already exists at this key, merge them
+	valueMap, valueIsMap := value.(mmdbtype.Map)
+	if existingMap, ok := existing.(mmdbtype.Map); ok && valueIsMap {
+		return mergeMaps(existingMap, valueMap, policy)
+	}
 
-	// If value is a Map and something already exists at this key, try to merge
-	if valueMap, ok := value.(mmdbtype.Map); ok {
-		if existing, exists := current[mmdbKey]; exists {
-			// If existing is also a Map, merge them
-			if existingMap, ok := existing.(mmdbtype.Map); ok {
-				merged, err := mergeMaps(existingMap, valueMap)
-				if err != nil {
-					return nil, err
-				}
-				current[mmdbKey] = merged
-				return result, nil
-			}
-			// Cannot merge map into non-map
+	if policy == "" {
+		if valueIsMap {
 			return nil, fmt.Errorf(
 				"cannot merge map into non-map at path %v: existing value is %T",
 				path,
 				existing,
 			)
 		}
+		// Not a map - just set the value
+		return value, nil
 	}
 
-	// No conflict or not a map - just set the value
-	current[mmdbKey] = value
-	return result, nil
+	resolved, err := resolveConflict(key, existing, value, policy)
+	if err != nil {
+		return nil, fmt.Errorf("at path %v: %w", path, err)
+	}
+	return resolved, nil
 }
 
 // mergeMaps returns a new map with contents merged from dest and source.
 // If both maps have the same key:
 // - If both values are maps, merge recursively.
-// - Otherwise, return error (fail-fast on conflicts).
+// - Otherwise, resolve the conflict according to policy, failing when the
+// policy is empty (fail-fast on conflicts).
 // Neither dest nor source are modified.
-func mergeMaps(dest, source mmdbtype.Map) (mmdbtype.Map, error) {
+func mergeMaps(dest, source mmdbtype.Map, policy string) (mmdbtype.Map, error) {
 	// Pre-allocate for efficiency
 	result := make(mmdbtype.Map, len(dest)+len(source))
 	maps.Copy(result, dest)
@@ -230,7 +588,7 @@ func mergeMaps(dest, source mmdbtype.Map) (mmdbtype.Map, error) {
 
 			if destIsMap && sourceIsMap {
 				// Both are maps - merge recursively
-				merged, err := mergeMaps(destMap, sourceMap)
+				merged, err := mergeMaps(destMap, sourceMap, policy)
 				if err != nil {
 					return nil, err
 				}
@@ -239,12 +597,12 @@ func mergeMaps(dest, source mmdbtype.Map) (mmdbtype.Map, error) {
 			}
 
 			// Conflict: same key but at least one is not a map
-			return nil, fmt.Errorf(
-				"field conflict: key %s already exists (cannot merge %T with %T)",
-				key,
-				destValue,
-				sourceValue,
-			)
+			resolved, err := resolveConflict(key, destValue, sourceValue, policy)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = resolved
+			continue
 		}
 
 		// No conflict - add to result
@@ -253,3 +611,37 @@ func mergeMaps(dest, source mmdbtype.Map) (mmdbtype.Map, error) {
 
 	return result, nil
 }
+
+// resolveConflict returns the value to store at key when existing and
+// incoming collide and cannot be merged as maps.
+func resolveConflict(
+	key mmdbtype.String,
+	existing, incoming mmdbtype.DataType,
+	policy string,
+) (mmdbtype.DataType, error) {
+	switch policy {
+	case ConflictPolicyKeepExisting:
+		return existing, nil
+	case ConflictPolicyOverwrite:
+		return incoming, nil
+	case ConflictPolicyConcatenate:
+		var out mmdbtype.Slice
+		for _, v := range []mmdbtype.DataType{existing, incoming} {
+			if s, ok := v.(mmdbtype.Slice); ok {
+				out = append(out, s...)
+			} else {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	case "", ConflictPolicyError:
+		return nil, fmt.Errorf(
+			"field conflict: key %s already exists (cannot merge %T with %T)",
+			key,
+			existing,
+			incoming,
+		)
+	default:
+		return nil, fmt.Errorf("unknown conflict policy '%s'", policy)
+	}
+}
@@ -1,13 +1,23 @@
 package writer
 
 import (
+	"bytes"
+	"hash/maphash"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/maxmind/mmdbwriter"
 	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go4.org/netipx"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
 )
 
 func TestMergeNestedValue_EmptyPath(t *testing.T) {
@@ -88,7 +98,7 @@ func TestMergeNestedValue_EmptyPath(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := mergeNestedValue(tt.root, []any{}, tt.value)
+			result, err := mergeNestedValue(tt.root, []any{}, tt.value, "")
 
 			if tt.expectErr {
 				require.Error(t, err)
@@ -186,7 +196,7 @@ func TestMergeNestedValue_MapMergingAtPath(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := mergeNestedValue(tt.root, tt.path, tt.value)
+			result, err := mergeNestedValue(tt.root, tt.path, tt.value, "")
 
 			if tt.expectErr {
 				require.Error(t, err)
@@ -310,7 +320,7 @@ func TestMergeMaps(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := mergeMaps(tt.dest, tt.source)
+			result, err := mergeMaps(tt.dest, tt.source, "")
 
 			if tt.expectErr {
 				require.Error(t, err)
@@ -389,14 +399,14 @@ func TestMergeMaps_NoMutation(t *testing.T) {
 	source := original
 
 	// Merge same source reference into two different destinations
-	result1, err := mergeMaps(dest1, source)
+	result1, err := mergeMaps(dest1, source, "")
 	require.NoError(t, err)
 
 	// Verify original source map was not mutated by first merge
 	assert.Equal(t, expectedOriginal, original)
 
 	// Second merge with same source reference should succeed because source wasn't mutated
-	result2, err := mergeMaps(dest2, source)
+	result2, err := mergeMaps(dest2, source, "")
 	require.NoError(t, err)
 
 	// Verify original source map still not mutated
@@ -423,13 +433,13 @@ func TestMergeNestedValue_NoMutation(t *testing.T) {
 
 	// First write
 	root1 := make(mmdbtype.Map)
-	result1, err := mergeNestedValue(root1, []any{"traits"}, sharedMap)
+	result1, err := mergeNestedValue(root1, []any{"traits"}, sharedMap, "")
 	require.NoError(t, err)
 	assert.NotNil(t, result1)
 
 	// Second write with SAME reference (simulates accumulator reuse)
 	root2 := make(mmdbtype.Map)
-	result2, err := mergeNestedValue(root2, []any{"traits"}, sharedMap)
+	result2, err := mergeNestedValue(root2, []any{"traits"}, sharedMap, "")
 	require.NoError(t, err)
 	assert.NotNil(t, result2)
 
@@ -486,3 +496,634 @@ func TestBuildNestedData_MapMergingAtPath(t *testing.T) {
 
 	assert.Equal(t, expected, result)
 }
+
+func TestMergeMaps_ConflictPolicies(t *testing.T) {
+	dest := mmdbtype.Map{
+		"traits": mmdbtype.Map{
+			"is_anonymous": mmdbtype.Bool(false),
+			"tags":         mmdbtype.Slice{mmdbtype.String("a")},
+		},
+	}
+	source := mmdbtype.Map{
+		"traits": mmdbtype.Map{
+			"is_anonymous": mmdbtype.Bool(true),
+			"tags":         mmdbtype.String("b"),
+		},
+	}
+
+	tests := []struct {
+		policy      string
+		expected    mmdbtype.Map
+		errContains string
+	}{
+		{
+			policy:      "",
+			errContains: "field conflict",
+		},
+		{
+			policy:      ConflictPolicyError,
+			errContains: "field conflict",
+		},
+		{
+			policy: ConflictPolicyKeepExisting,
+			expected: mmdbtype.Map{
+				"traits": mmdbtype.Map{
+					"is_anonymous": mmdbtype.Bool(false),
+					"tags":         mmdbtype.Slice{mmdbtype.String("a")},
+				},
+			},
+		},
+		{
+			policy: ConflictPolicyOverwrite,
+			expected: mmdbtype.Map{
+				"traits": mmdbtype.Map{
+					"is_anonymous": mmdbtype.Bool(true),
+					"tags":         mmdbtype.String("b"),
+				},
+			},
+		},
+		{
+			policy: ConflictPolicyConcatenate,
+			expected: mmdbtype.Map{
+				"traits": mmdbtype.Map{
+					"is_anonymous": mmdbtype.Slice{mmdbtype.Bool(false), mmdbtype.Bool(true)},
+					"tags":         mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.String("b")},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			result, err := mergeMaps(dest, source, tt.policy)
+			if tt.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestMergeNestedValue_ConflictPolicies(t *testing.T) {
+	root := mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("US")},
+	}
+	path := []any{"country", "iso_code"}
+
+	result, err := mergeNestedValue(root, path, mmdbtype.String("CA"), "")
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("CA")},
+	}, result, "unset policy keeps overwriting scalars")
+
+	_, err = mergeNestedValue(root, path, mmdbtype.String("CA"), ConflictPolicyError)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field conflict")
+
+	result, err = mergeNestedValue(root, path, mmdbtype.String("CA"), ConflictPolicyKeepExisting)
+	require.NoError(t, err)
+	assert.Equal(t, root, result)
+
+	result, err = mergeNestedValue(
+		root,
+		[]any{"country"},
+		mmdbtype.String("CA"),
+		ConflictPolicyConcatenate,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"country": mmdbtype.Slice{
+			mmdbtype.Map{"iso_code": mmdbtype.String("US")},
+			mmdbtype.String("CA"),
+		},
+	}, result)
+}
+
+func TestMergeNestedValue_SliceIndexes(t *testing.T) {
+	root := mmdbtype.Map{}
+	var err error
+
+	root, err = mergeNestedValue(
+		root,
+		[]any{"subdivisions", 0, "iso_code"},
+		mmdbtype.String("ENG"),
+		"",
+	)
+	require.NoError(t, err)
+	root, err = mergeNestedValue(
+		root,
+		[]any{"subdivisions", 0, "names", "en"},
+		mmdbtype.String("England"),
+		"",
+	)
+	require.NoError(t, err)
+	root, err = mergeNestedValue(
+		root,
+		[]any{"subdivisions", 1, "iso_code"},
+		mmdbtype.String("LND"),
+		"",
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, mmdbtype.Map{
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{
+				"iso_code": mmdbtype.String("ENG"),
+				"names":    mmdbtype.Map{"en": mmdbtype.String("England")},
+			},
+			mmdbtype.Map{"iso_code": mmdbtype.String("LND")},
+		},
+	}, root)
+}
+
+func TestMergeNestedValue_SliceIndexDoesNotMutate(t *testing.T) {
+	original := mmdbtype.Slice{mmdbtype.String("a")}
+	root := mmdbtype.Map{"tags": original}
+
+	result, err := mergeNestedValue(root, []any{"tags", 1}, mmdbtype.String("b"), "")
+	require.NoError(t, err)
+
+	assert.Equal(t, mmdbtype.Slice{mmdbtype.String("a")}, original)
+	assert.Equal(t, mmdbtype.Map{
+		"tags": mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.String("b")},
+	}, result)
+}
+
+func TestMergeNestedValue_SliceIndexErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		root        mmdbtype.Map
+		path        []any
+		errContains string
+	}{
+		{
+			name:        "gap in slice",
+			root:        mmdbtype.Map{},
+			path:        []any{"subdivisions", 1, "iso_code"},
+			errContains: "slice index 1 out of range",
+		},
+		{
+			name:        "index into map",
+			root:        mmdbtype.Map{"country": mmdbtype.Map{}},
+			path:        []any{"country", 0},
+			errContains: "expected slice",
+		},
+		{
+			name:        "key into slice",
+			root:        mmdbtype.Map{"tags": mmdbtype.Slice{}},
+			path:        []any{"tags", "first"},
+			errContains: "expected map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mergeNestedValue(tt.root, tt.path, mmdbtype.String("x"), "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestBuildNestedData_SliceIndexOutputPath(t *testing.T) {
+	// TOML decodes integer path segments as int64
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{
+				Name:       "subdivision_1_iso_code",
+				Database:   "city",
+				OutputPath: &config.Path{"subdivisions", int64(0), "iso_code"},
+			},
+			{
+				Name:       "subdivision_2_iso_code",
+				Database:   "city",
+				OutputPath: &config.Path{"subdivisions", int64(1), "iso_code"},
+			},
+		},
+	}
+
+	writer := &MMDBWriter{
+		config: cfg,
+	}
+
+	result, err := writer.buildNestedData([]mmdbtype.DataType{
+		mmdbtype.String("ENG"),
+		mmdbtype.String("LND"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, mmdbtype.Map{
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"iso_code": mmdbtype.String("ENG")},
+			mmdbtype.Map{"iso_code": mmdbtype.String("LND")},
+		},
+	}, result)
+}
+
+func TestBuildNestedData_Template(t *testing.T) {
+	recordSize := 28
+	includeReserved := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			MMDB: config.MMDBConfig{
+				Template:                "geoip2-city",
+				RecordSize:              &recordSize,
+				IncludeReservedNetworks: &includeReserved,
+			},
+		},
+		Columns: []config.Column{
+			{
+				Name:       "country_geoname_id",
+				OutputPath: &config.Path{"country", "geoname_id"},
+			},
+			{
+				Name:       "accuracy_radius",
+				OutputPath: &config.Path{"location", "accuracy_radius"},
+			},
+			{Name: "custom"},
+		},
+	}
+
+	writer, err := NewMMDBWriter(filepath.Join(t.TempDir(), "out.mmdb"), cfg, 6)
+	require.NoError(t, err)
+
+	result, err := writer.buildNestedData([]mmdbtype.DataType{
+		mmdbtype.Uint64(2635167),
+		mmdbtype.Uint32(50),
+		mmdbtype.Uint64(7),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"country":  mmdbtype.Map{"geoname_id": mmdbtype.Uint32(2635167)},
+		"location": mmdbtype.Map{"accuracy_radius": mmdbtype.Uint16(50)},
+		"custom":   mmdbtype.Uint64(7),
+	}, result)
+
+	_, err = writer.buildNestedData([]mmdbtype.DataType{
+		mmdbtype.String("not a number"),
+		nil,
+		nil,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "country_geoname_id")
+}
+
+func TestMMDBWriter_Base(t *testing.T) {
+	baseTree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "Base-DB",
+		IPVersion:    6,
+	})
+	require.NoError(t, err)
+	for network, record := range map[string]mmdbtype.Map{
+		"1.0.0.0/24": {
+			"source": mmdbtype.String("base"),
+			"traits": mmdbtype.Map{"is_vpn": mmdbtype.Bool(true)},
+		},
+		"2.0.0.0/24": {"source": mmdbtype.String("base")},
+	} {
+		require.NoError(t, baseTree.Insert(
+			netipx.PrefixIPNet(netip.MustParsePrefix(network)),
+			record,
+		))
+	}
+	basePath := filepath.Join(t.TempDir(), "base.mmdb")
+	f, err := os.Create(basePath)
+	require.NoError(t, err)
+	_, err = baseTree.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	tests := []struct {
+		strategy string
+		expected map[string]any
+	}{
+		{
+			strategy: "replace",
+			expected: map[string]any{
+				"traits": map[string]any{"is_tor": true},
+			},
+		},
+		{
+			strategy: "top_level_merge",
+			expected: map[string]any{
+				"source": "base",
+				"traits": map[string]any{"is_tor": true},
+			},
+		},
+		{
+			strategy: "deep_merge",
+			expected: map[string]any{
+				"source": "base",
+				"traits": map[string]any{"is_tor": true, "is_vpn": true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			recordSize := 28
+			includeReserved := false
+			cfg := &config.Config{
+				Output: config.OutputConfig{
+					MMDB: config.MMDBConfig{
+						RecordSize:              &recordSize,
+						IncludeReservedNetworks: &includeReserved,
+						Base:                    basePath,
+						InsertStrategy:          tt.strategy,
+					},
+				},
+				Columns: []config.Column{
+					{Name: "is_tor", OutputPath: &config.Path{"traits", "is_tor"}},
+				},
+			}
+
+			outPath := filepath.Join(t.TempDir(), "out.mmdb")
+			w, err := NewMMDBWriter(outPath, cfg, 4)
+			require.NoError(t, err)
+			require.NoError(t, w.WriteRow(
+				netip.MustParsePrefix("1.0.0.0/24"),
+				[]mmdbtype.DataType{mmdbtype.Bool(true)},
+			))
+			require.NoError(t, w.Flush())
+
+			reader, err := maxminddb.Open(outPath)
+			require.NoError(t, err)
+			defer reader.Close()
+			assert.Equal(t, "Base-DB", reader.Metadata.DatabaseType)
+
+			var record map[string]any
+			require.NoError(t, reader.Lookup(netip.MustParseAddr("1.0.0.1")).Decode(&record))
+			assert.Equal(t, tt.expected, record)
+
+			// Networks not in the merged rows are kept from the base
+			var untouched map[string]any
+			require.NoError(t, reader.Lookup(netip.MustParseAddr("2.0.0.1")).Decode(&untouched))
+			assert.Equal(t, map[string]any{"source": "base"}, untouched)
+		})
+	}
+}
+
+func TestMMDBWriter_IPv4Tree(t *testing.T) {
+	recordSize := 24
+	includeReserved := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			MMDB: config.MMDBConfig{
+				DatabaseType:            "Test",
+				RecordSize:              &recordSize,
+				IncludeReservedNetworks: &includeReserved,
+				IPVersion:               "ipv4",
+			},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	outPath := filepath.Join(t.TempDir(), "out.mmdb")
+	w, err := NewMMDBWriter(outPath, cfg, 4)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		[]mmdbtype.DataType{mmdbtype.String("AU")},
+	))
+	// IPv6 rows are dropped rather than failing the IPv4 tree
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("2a02::/16"),
+		[]mmdbtype.DataType{mmdbtype.String("DE")},
+	))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2a03::"),
+		netip.MustParseAddr("2a03::ff"),
+		[]mmdbtype.DataType{mmdbtype.String("DE")},
+	))
+	require.NoError(t, w.Flush())
+
+	reader, err := maxminddb.Open(outPath)
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, uint(4), reader.Metadata.IPVersion)
+
+	var record map[string]any
+	require.NoError(t, reader.Lookup(netip.MustParseAddr("1.0.0.1")).Decode(&record))
+	assert.Equal(t, map[string]any{"country": "AU"}, record)
+}
+
+func TestMMDBWriter_SkipInsertErrors(t *testing.T) {
+	recordSize := 24
+	includeReserved := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			MMDB: config.MMDBConfig{
+				DatabaseType:            "Test",
+				RecordSize:              &recordSize,
+				IncludeReservedNetworks: &includeReserved,
+				OnInsertError:           config.InsertErrorAbort,
+			},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+	reserved := netip.MustParsePrefix("10.0.0.0/8")
+	data := []mmdbtype.DataType{mmdbtype.String("AU")}
+
+	w, err := NewMMDBWriter(filepath.Join(t.TempDir(), "out.mmdb"), cfg, 6)
+	require.NoError(t, err)
+	err = w.WriteRow(reserved, data)
+	require.ErrorContains(t, err, "inserting 10.0.0.0/8")
+
+	cfg.Output.MMDB.OnInsertError = config.InsertErrorSkip
+	outPath := filepath.Join(t.TempDir(), "out.mmdb")
+	w, err = NewMMDBWriter(outPath, cfg, 6)
+	require.NoError(t, err)
+	var skipped []*InsertError
+	w.ReportSkippedInserts(func(e *InsertError) {
+		skipped = append(skipped, e)
+	})
+	require.NoError(t, w.WriteRow(reserved, data))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), data))
+	// Reserved networks within ranges are left out silently, as before
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("10.1.0.0"),
+		netip.MustParseAddr("10.1.0.255"),
+		data,
+	))
+	require.NoError(t, w.Flush())
+
+	require.Len(t, skipped, 1)
+	assert.Equal(t, reserved, skipped[0].Network)
+	assert.ErrorAs(t, skipped[0], new(*mmdbwriter.ReservedNetworkError))
+	assert.Equal(t, 1, w.SkippedInserts())
+
+	reader, err := maxminddb.Open(outPath)
+	require.NoError(t, err)
+	defer reader.Close()
+	var record map[string]any
+	require.NoError(t, reader.Lookup(netip.MustParseAddr("1.0.0.1")).Decode(&record))
+	assert.Equal(t, map[string]any{"country": "AU"}, record)
+}
+
+func TestMMDBWriter_NestedDataCache(t *testing.T) {
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "country", OutputPath: &config.Path{"country", "iso_code"}},
+			{Name: "geoname_id"},
+		},
+	}
+	writer := &MMDBWriter{
+		config:      cfg,
+		nestedCache: make([]nestedEntry, nestedCacheSize),
+		hashSeed:    maphash.MakeSeed(),
+	}
+
+	// Rows are only valid during a write, so pass a fresh slice each time
+	first, err := writer.nestedData(row.Row{mmdbtype.String("GB"), mmdbtype.Uint32(1)})
+	require.NoError(t, err)
+	second, err := writer.nestedData(row.Row{mmdbtype.String("GB"), mmdbtype.Uint32(1)})
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"country":    mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+		"geoname_id": mmdbtype.Uint32(1),
+	}, first)
+	assert.Equal(t,
+		reflect.ValueOf(first).Pointer(),
+		reflect.ValueOf(second).Pointer(),
+		"identical rows share one nested record",
+	)
+
+	// Same text but a different type must not reuse the cached record
+	other, err := writer.nestedData(row.Row{mmdbtype.String("GB"), mmdbtype.Uint64(1)})
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Uint64(1), other["geoname_id"])
+
+	// Columns are kept apart when hashing
+	shifted, err := writer.nestedData(row.Row{mmdbtype.String("GB1"), nil})
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB1")},
+	}, shifted)
+}
+
+func TestMMDBWriter_NestedDataCacheBounded(t *testing.T) {
+	cfg := &config.Config{Columns: []config.Column{{Name: "geoname_id"}}}
+	writer := &MMDBWriter{
+		config:      cfg,
+		nestedCache: make([]nestedEntry, nestedCacheSize),
+		hashSeed:    maphash.MakeSeed(),
+	}
+
+	// Many more distinct rows than slots replace each other rather than
+	// growing the cache
+	for i := range 4 * nestedCacheSize {
+		_, err := writer.nestedData(row.Row{mmdbtype.Uint32(i)})
+		require.NoError(t, err)
+	}
+	assert.Len(t, writer.nestedCache, nestedCacheSize)
+	cached := 0
+	for _, entry := range writer.nestedCache {
+		if entry.flat != nil {
+			cached++
+		}
+	}
+	assert.LessOrEqual(t, cached, nestedCacheSize)
+	assert.Positive(t, cached)
+
+	// The last row is still cached
+	first, err := writer.nestedData(row.Row{mmdbtype.Uint32(4*nestedCacheSize - 1)})
+	require.NoError(t, err)
+	second, err := writer.nestedData(row.Row{mmdbtype.Uint32(4*nestedCacheSize - 1)})
+	require.NoError(t, err)
+	assert.Equal(t, reflect.ValueOf(first).Pointer(), reflect.ValueOf(second).Pointer())
+}
+
+func TestMMDBWriter_DisableCacheSkipsNestedCache(t *testing.T) {
+	recordSize := 24
+	includeReserved := false
+	cfg := &config.Config{
+		DisableCache: true,
+		Output: config.OutputConfig{
+			MMDB: config.MMDBConfig{
+				DatabaseType:            "Test",
+				RecordSize:              &recordSize,
+				IncludeReservedNetworks: &includeReserved,
+			},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	w, err := NewMMDBWriter(filepath.Join(t.TempDir(), "out.mmdb"), cfg, 6)
+	require.NoError(t, err)
+	assert.Nil(t, w.nestedCache)
+
+	first, err := w.nestedData(row.Row{mmdbtype.String("GB")})
+	require.NoError(t, err)
+	second, err := w.nestedData(row.Row{mmdbtype.String("GB")})
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, reflect.ValueOf(first).Pointer(), reflect.ValueOf(second).Pointer())
+}
+
+func TestMMDBWriter_ProvenanceDescription(t *testing.T) {
+	cfg := newTestConfig("mmdb")
+	cfg.Output.MMDB.Description = map[string]string{"en": "Merged"}
+	cfg.Provenance = testProvenance
+
+	var buf bytes.Buffer
+	w, err := New(&buf, cfg, 4)
+	require.NoError(t, err)
+	require.NoError(t, row.Flush(w))
+
+	reader, err := maxminddb.OpenBytes(buf.Bytes())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, map[string]string{
+		"en":                                    "Merged",
+		"mmdbconvert.version":                   "1.2.3",
+		"mmdbconvert.config_sha256":             "abc123",
+		"mmdbconvert.source.city.database_type": "GeoIP2-City",
+		"mmdbconvert.source.city.build_epoch":   "1700000000",
+	}, reader.Metadata.Description)
+	assert.Equal(t, map[string]string{"en": "Merged"}, cfg.Output.MMDB.Description,
+		"the configured description is not modified")
+}
+
+func TestMMDBWriter_ProvenanceKeepsBaseDescription(t *testing.T) {
+	baseTree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "Base-DB",
+		Description: map[string]string{
+			"en":                                   "Base",
+			"mmdbconvert.source.old.build_epoch":   "1",
+			"mmdbconvert.source.old.database_type": "Old-DB",
+		},
+		IPVersion: 6,
+	})
+	require.NoError(t, err)
+	basePath := filepath.Join(t.TempDir(), "base.mmdb")
+	f, err := os.Create(basePath)
+	require.NoError(t, err)
+	_, err = baseTree.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	cfg := newTestConfig("mmdb")
+	cfg.Output.MMDB.Base = basePath
+	cfg.Output.MMDB.InsertStrategy = "replace"
+	cfg.Provenance = testProvenance
+
+	var buf bytes.Buffer
+	w, err := New(&buf, cfg, 6)
+	require.NoError(t, err)
+	require.NoError(t, row.Flush(w))
+
+	reader, err := maxminddb.OpenBytes(buf.Bytes())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	expected := map[string]string{"en": "Base"}
+	for _, pair := range testProvenance.Pairs() {
+		expected[pair.Key] = pair.Value
+	}
+	assert.Equal(t, expected, reader.Metadata.Description,
+		"the base description is kept without its earlier provenance")
+}
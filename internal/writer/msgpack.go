@@ -0,0 +1,283 @@
+package writer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// MessagePackWriter writes each row as a MessagePack map from column names
+// to values, one after the other with nothing in between, as streaming
+// decoders read them. Every map has a key for every column, in column order,
+// with nil for null values.
+type MessagePackWriter struct {
+	out          io.Writer
+	config       *config.Config
+	rangeCapable bool
+	header       []byte   // The encoded map header
+	keys         [][]byte // The encoded key of each column
+	buf          []byte   // Rows not yet written to out
+	field        []byte   // Scratch space for formatting a single field
+}
+
+// NewMessagePackWriter creates a new MessagePack writer.
+func NewMessagePackWriter(w io.Writer, cfg *config.Config) *MessagePackWriter {
+	mw := &MessagePackWriter{
+		out:    w,
+		config: cfg,
+		rangeCapable: !slices.ContainsFunc(cfg.Network.Columns, func(col config.NetworkColumn) bool {
+			return col.Type == NetworkColumnCIDR
+		}),
+		header: appendMsgpackMapHeader(nil, len(cfg.Network.Columns)+len(cfg.Columns)),
+		buf:    make([]byte, 0, csvFlushSize+4096),
+		field:  make([]byte, 0, 128),
+	}
+	for _, col := range cfg.Network.Columns {
+		mw.keys = append(mw.keys, appendMsgpackString(nil, []byte(col.OutputName("msgpack"))))
+	}
+	for _, col := range cfg.Columns {
+		mw.keys = append(mw.keys, appendMsgpackString(nil, []byte(col.OutputName("msgpack"))))
+	}
+	return mw
+}
+
+// WriteRow writes a single row with network prefix and column data.
+func (w *MessagePackWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeMap(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *MessagePackWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, writing a single map unless a
+// network column is a CIDR.
+func (w *MessagePackWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		for _, cidr := range network.RangeToPrefixes(start, end) {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.writeMap(netip.Prefix{}, start, end, r)
+}
+
+// writeMap encodes a row and appends it to the buffer. prefix is invalid for
+// range rows.
+func (w *MessagePackWriter) writeMap(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	rowStart := len(w.buf)
+	w.buf = append(w.buf, w.header...)
+	key := 0
+
+	for _, netCol := range w.config.Network.Columns {
+		w.buf = append(w.buf, w.keys[key]...)
+		key++
+		if err := w.appendNetworkValue(prefix, start, end, r, netCol.Type); err != nil {
+			w.buf = w.buf[:rowStart]
+			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
+		}
+	}
+
+	for i, col := range w.config.Columns {
+		w.buf = append(w.buf, w.keys[key]...)
+		key++
+		value, err := convertToParquetType(r, i, col.Type)
+		if err != nil {
+			w.buf = w.buf[:rowStart]
+			return fmt.Errorf("converting column '%s': %w", col.Name, err)
+		}
+		w.appendDataValue(value)
+	}
+
+	if len(w.buf) >= csvFlushSize {
+		return w.flushBuffer()
+	}
+	return nil
+}
+
+// appendNetworkValue appends the value of a network column. Integer
+// columns are unsigned integers for IPv4 and, as MessagePack has no 128-bit
+// integers, the 16 bytes of the address in network order for IPv6.
+func (w *MessagePackWriter) appendNetworkValue(
+	prefix netip.Prefix,
+	start, end netip.Addr,
+	r row.Row,
+	colType string,
+) error {
+	switch colType {
+	case NetworkColumnStartInt:
+		w.appendAddrInt(start)
+	case NetworkColumnEndInt:
+		w.appendAddrInt(end)
+	case NetworkColumnValidFrom:
+		w.appendOptionalString(r.ValidFrom(len(w.config.Columns)))
+	case NetworkColumnValidTo:
+		w.appendOptionalString(r.ValidTo(len(w.config.Columns)))
+	default:
+		var err error
+		w.field, err = appendNetworkValue(w.field[:0], prefix, start, end, colType)
+		if err != nil {
+			return err
+		}
+		w.buf = appendMsgpackString(w.buf, w.field)
+	}
+	return nil
+}
+
+// appendAddrInt appends an address as an integer column value.
+func (w *MessagePackWriter) appendAddrInt(addr netip.Addr) {
+	if addr.Is4() {
+		b := addr.As4()
+		w.buf = appendMsgpackUint(w.buf, uint64(binary.BigEndian.Uint32(b[:])))
+		return
+	}
+	b := addr.As16()
+	w.buf = appendMsgpackBinary(w.buf, b[:])
+}
+
+// appendOptionalString appends s, or nil if it is empty.
+func (w *MessagePackWriter) appendOptionalString(s string) {
+	if s == "" {
+		w.buf = append(w.buf, 0xc0)
+		return
+	}
+	w.buf = appendMsgpackString(w.buf, []byte(s))
+}
+
+// appendDataValue appends the value of a data column, converted by its type
+// hint.
+func (w *MessagePackWriter) appendDataValue(value any) {
+	switch v := value.(type) {
+	case string:
+		w.buf = appendMsgpackString(w.buf, []byte(v))
+	case []byte:
+		w.buf = appendMsgpackBinary(w.buf, v)
+	case int64:
+		w.buf = appendMsgpackInt(w.buf, v)
+	case float64:
+		w.buf = append(w.buf, 0xcb)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, math.Float64bits(v))
+	case bool:
+		if v {
+			w.buf = append(w.buf, 0xc3)
+		} else {
+			w.buf = append(w.buf, 0xc2)
+		}
+	default:
+		w.buf = append(w.buf, 0xc0)
+	}
+}
+
+// appendMsgpackMapHeader appends the header of a map of n entries.
+func appendMsgpackMapHeader(dst []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(dst, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, 0xdf), uint32(n))
+	}
+}
+
+// appendMsgpackString appends s as a string in the shortest encoding.
+func appendMsgpackString(dst, s []byte) []byte {
+	switch n := len(s); {
+	case n < 32:
+		dst = append(dst, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xda), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xdb), uint32(n))
+	}
+	return append(dst, s...)
+}
+
+// appendMsgpackBinary appends b as binary data in the shortest encoding.
+func appendMsgpackBinary(dst, b []byte) []byte {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xc5), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xc6), uint32(n))
+	}
+	return append(dst, b...)
+}
+
+// appendMsgpackUint appends v in the shortest encoding.
+func appendMsgpackUint(dst []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(dst, byte(v))
+	case v <= math.MaxUint8:
+		return append(dst, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, 0xcf), v)
+	}
+}
+
+// appendMsgpackInt appends v in the shortest encoding, which is unsigned
+// for values that are not negative.
+func appendMsgpackInt(dst []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(dst, uint64(v))
+	case v >= -32:
+		return append(dst, byte(v))
+	case v >= math.MinInt8:
+		return append(dst, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, 0xd3), uint64(v))
+	}
+}
+
+// flushBuffer writes all buffered rows to the underlying writer.
+func (w *MessagePackWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("writing MessagePack rows: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush writes all buffered rows.
+func (w *MessagePackWriter) Flush() error {
+	return w.flushBuffer()
+}
+
+// Sync writes all buffered rows and commits them to stable storage if the
+// underlying writer supports it, as *os.File does.
+func (w *MessagePackWriter) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return syncOutput(w.out)
+}
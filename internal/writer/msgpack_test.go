@@ -0,0 +1,143 @@
+package writer
+
+import (
+	"bytes"
+	"math"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func msgpackConfig(network ...config.NetworkColumn) *config.Config {
+	return &config.Config{
+		Network: config.NetworkConfig{Columns: network},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "accuracy", Type: "int64"},
+			{Name: "latitude", Type: "float64"},
+			{Name: "is_eu", Type: "bool", Aliases: config.Aliases{StorageName: "in_eu"}},
+		},
+	}
+}
+
+func TestMessagePackWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewMessagePackWriter(&buf, msgpackConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+	))
+	assert.False(t, w.WritesRanges())
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("DE"),
+		mmdbtype.Uint16(200),
+		mmdbtype.Float64(51.5),
+		mmdbtype.Bool(false),
+	}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{
+		nil,
+		mmdbtype.Int32(-1),
+		nil,
+		nil,
+	}))
+	require.NoError(t, w.Flush())
+
+	ipv6 := netip.MustParseAddr("2001:db8::").As16()
+	var want []byte
+	want = append(want, 0x86)
+	want = append(want, "\xa7network\xaa1.0.0.0/24"...)
+	want = append(want, "\xa9start_int\xce\x01\x00\x00\x00"...)
+	want = append(want, "\xa7country\xa2DE"...)
+	want = append(want, "\xa8accuracy\xcc\xc8"...)
+	want = append(want, "\xa8latitude\xcb\x40\x49\xc0\x00\x00\x00\x00\x00"...)
+	want = append(want, "\xa5in_eu\xc2"...)
+	want = append(want, 0x86)
+	want = append(want, "\xa7network\xad2001:db8::/32"...)
+	want = append(want, "\xa9start_int\xc4\x10"...)
+	want = append(want, ipv6[:]...)
+	want = append(want, "\xa7country\xc0"...)
+	want = append(want, "\xa8accuracy\xff"...)
+	want = append(want, "\xa8latitude\xc0"...)
+	want = append(want, "\xa5in_eu\xc0"...)
+	assert.Equal(t, want, buf.Bytes())
+}
+
+func TestMessagePackWriter_Ranges(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewMessagePackWriter(&buf, msgpackConfig(
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "end_ip", Type: NetworkColumnEndIP},
+	))
+	require.True(t, w.WritesRanges())
+
+	r := row.Row{mmdbtype.String("DE"), nil, nil, mmdbtype.Bool(true)}
+	require.NoError(t, w.WriteRange(netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.9"), r))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "\x86"+
+		"\xa8start_ip\xa71.0.0.0"+
+		"\xa6end_ip\xa71.0.0.9"+
+		"\xa7country\xa2DE"+
+		"\xa8accuracy\xc0"+
+		"\xa8latitude\xc0"+
+		"\xa5in_eu\xc3", buf.String())
+}
+
+func TestMessagePackWriter_InvalidValue(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewMessagePackWriter(&buf, msgpackConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+	))
+	err := w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("DE"),
+		mmdbtype.String("twenty"),
+		nil,
+		nil,
+	})
+	require.ErrorContains(t, err, "converting column 'accuracy'")
+
+	// The failed row leaves nothing behind
+	require.NoError(t, w.Flush())
+	assert.Empty(t, buf.Bytes())
+}
+
+func TestAppendMsgpackInt(t *testing.T) {
+	tests := []struct {
+		value int64
+		want  string
+	}{
+		{0, "\x00"},
+		{127, "\x7f"},
+		{128, "\xcc\x80"},
+		{65535, "\xcd\xff\xff"},
+		{65536, "\xce\x00\x01\x00\x00"},
+		{math.MaxInt64, "\xcf\x7f\xff\xff\xff\xff\xff\xff\xff"},
+		{-32, "\xe0"},
+		{-33, "\xd0\xdf"},
+		{-129, "\xd1\xff\x7f"},
+		{-32769, "\xd2\xff\xff\x7f\xff"},
+		{math.MinInt64, "\xd3\x80\x00\x00\x00\x00\x00\x00\x00"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, string(appendMsgpackInt(nil, tt.value)), "value %d", tt.value)
+	}
+}
+
+func TestAppendMsgpackString(t *testing.T) {
+	for n, header := range map[int]string{
+		0:     "\xa0",
+		31:    "\xbf",
+		32:    "\xd9\x20",
+		255:   "\xd9\xff",
+		256:   "\xda\x01\x00",
+		65536: "\xdb\x00\x01\x00\x00",
+	} {
+		s := bytes.Repeat([]byte("a"), n)
+		assert.Equal(t, header+string(s), string(appendMsgpackString(nil, s)), "length %d", n)
+	}
+}
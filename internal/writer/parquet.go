@@ -1,19 +1,20 @@
 package writer
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
-	"math/big"
 	"net/netip"
 
-	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/parquet-go/parquet-go"
 	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/encoding"
 	"go4.org/netipx"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
-	"github.com/maxmind/mmdbconvert/internal/network"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
 )
 
 const (
@@ -31,6 +32,8 @@ const (
 
 // ParquetWriter writes merged MMDB data to Parquet format.
 type ParquetWriter struct {
+	out          io.Writer
+	buffer       *bufio.Writer // Buffers out so that Sync can drain it
 	writer       *parquet.GenericWriter[map[string]any]
 	config       *config.Config
 	schema       *parquet.Schema
@@ -63,14 +66,22 @@ func NewParquetWriterWithIPVersion(
 		return nil, fmt.Errorf("getting compression codec: %w", err)
 	}
 
-	// Create Parquet writer with options
-	parquetWriter := parquet.NewGenericWriter[map[string]any](
-		w,
+	// Create Parquet writer with options. Buffering is done here rather than
+	// by the Parquet writer, which has no way to drain its buffer except Close.
+	buffer := bufio.NewWriterSize(w, parquet.DefaultWriteBufferSize)
+	options := []parquet.WriterOption{
 		schema,
 		parquet.Compression(codec),
-	)
+		parquet.WriteBufferSize(0),
+	}
+	for _, pair := range cfg.Provenance.Pairs() {
+		options = append(options, parquet.KeyValueMetadata(pair.Key, pair.Value))
+	}
+	parquetWriter := parquet.NewGenericWriter[map[string]any](buffer, options...)
 
 	return &ParquetWriter{
+		out:          w,
+		buffer:       buffer,
 		writer:       parquetWriter,
 		config:       cfg,
 		schema:       schema,
@@ -80,31 +91,30 @@ func NewParquetWriterWithIPVersion(
 }
 
 // WriteRow writes a single row with network prefix and column data.
-func (w *ParquetWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error {
+func (w *ParquetWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
 	// Build row with network columns + data columns
-	row := map[string]any{}
+	record := map[string]any{}
 
 	// Add network column values
 	for _, netCol := range w.config.Network.Columns {
-		value, err := w.generateNetworkColumnValue(prefix, netCol.Type)
+		value, err := w.generateNetworkColumnValue(prefix, r, netCol.Type)
 		if err != nil {
 			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
 		}
-		row[string(netCol.Name)] = value
+		record[netCol.OutputName("parquet")] = value
 	}
 
 	// Add data column values (with type conversion)
 	for i, col := range w.config.Columns {
-		value := data[i]
-		converted, err := convertToParquetType(value, col.Type)
+		converted, err := convertToParquetType(r, i, col.Type)
 		if err != nil {
 			return fmt.Errorf("converting column '%s': %w", col.Name, err)
 		}
-		row[string(col.Name)] = converted
+		SetParquetDataValue(record, col, converted)
 	}
 
 	// Write the row
-	if _, err := w.writer.Write([]map[string]any{row}); err != nil {
+	if _, err := w.writer.Write([]map[string]any{record}); err != nil {
 		return fmt.Errorf("writing Parquet row: %w", err)
 	}
 
@@ -126,17 +136,42 @@ func (w *ParquetWriter) Flush() error {
 	if err := w.writer.Close(); err != nil {
 		return fmt.Errorf("closing Parquet writer: %w", err)
 	}
+	if err := w.buffer.Flush(); err != nil {
+		return fmt.Errorf("flushing Parquet output: %w", err)
+	}
 	return nil
 }
 
+// Sync ends the current row group and commits the written row groups to
+// stable storage if the underlying writer supports it. The file footer is
+// only written by Flush, so a synced file is not readable until then, but
+// its row groups can be recovered.
+func (w *ParquetWriter) Sync() error {
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("flushing row group: %w", err)
+	}
+	w.rowCount = 0
+	if err := w.buffer.Flush(); err != nil {
+		return fmt.Errorf("flushing Parquet output: %w", err)
+	}
+	return syncOutput(w.out)
+}
+
 // generateNetworkColumnValue generates the value for a network column.
 func (w *ParquetWriter) generateNetworkColumnValue(
 	prefix netip.Prefix,
+	r row.Row,
 	colType string,
 ) (any, error) {
 	addr := prefix.Addr()
 
 	switch colType {
+	case NetworkColumnValidFrom:
+		return nullIfEmpty(r.ValidFrom(len(w.config.Columns))), nil
+
+	case NetworkColumnValidTo:
+		return nullIfEmpty(r.ValidTo(len(w.config.Columns))), nil
+
 	case NetworkColumnCIDR:
 		return prefix.String(), nil
 
@@ -147,6 +182,12 @@ func (w *ParquetWriter) generateNetworkColumnValue(
 		endIP := netipx.PrefixLastIP(prefix)
 		return endIP.String(), nil
 
+	case NetworkColumnStartDecimal:
+		return string(appendAddrInt(nil, addr)), nil
+
+	case NetworkColumnEndDecimal:
+		return string(appendAddrInt(nil, netipx.PrefixLastIP(prefix))), nil
+
 	case NetworkColumnStartInt:
 		if addr.Is4() {
 			if w.ipVersion == ipVersion6 {
@@ -205,26 +246,160 @@ func buildSchema(cfg *config.Config, ipVersion int) (*parquet.Schema, error) {
 				err,
 			)
 		}
-		fields[string(netCol.Name)] = node
+		node, err = applyColumnOptions(node, cfg.Output.Parquet.Columns[string(netCol.Name)])
+		if err != nil {
+			return nil, fmt.Errorf("configuring network column '%s': %w", netCol.Name, err)
+		}
+		fields[netCol.OutputName("parquet")] = node
+	}
+
+	if err := AddParquetDataFields(fields, cfg); err != nil {
+		return nil, err
 	}
 
-	// Add data columns
+	schema := parquet.NewSchema("mmdb", fields)
+	return schema, nil
+}
+
+// AddParquetDataFields adds the nodes of the data columns of cfg to fields,
+// with the encoding and compression configured for each column. The columns
+// of a group are nested in a field named after the group.
+func AddParquetDataFields(fields parquet.Group, cfg *config.Config) error {
 	for _, col := range cfg.Columns {
 		node, err := buildDataNode(col)
 		if err != nil {
-			return nil, fmt.Errorf("building node for column '%s': %w", col.Name, err)
+			return fmt.Errorf("building node for column '%s': %w", col.Name, err)
+		}
+		node, err = applyColumnOptions(node, cfg.Output.Parquet.Columns[string(col.Name)])
+		if err != nil {
+			return fmt.Errorf("configuring column '%s': %w", col.Name, err)
+		}
+		parent := fields
+		if col.Group != "" {
+			group, ok := fields[col.Group].(parquet.Group)
+			if !ok {
+				group = parquet.Group{}
+				fields[col.Group] = group
+			}
+			parent = group
 		}
-		fields[string(col.Name)] = node
+		parent[col.OutputName("parquet")] = node
 	}
+	return nil
+}
 
-	schema := parquet.NewSchema("mmdb", fields)
-	return schema, nil
+// SetParquetDataValue sets the value of a data column in a Parquet record,
+// in the nested record of the column's group if it has one.
+func SetParquetDataValue(record map[string]any, col config.Column, value any) {
+	if col.Group == "" {
+		record[col.OutputName("parquet")] = value
+		return
+	}
+	group, ok := record[col.Group].(map[string]any)
+	if !ok {
+		group = map[string]any{}
+		record[col.Group] = group
+	}
+	group[col.OutputName("parquet")] = value
+}
+
+// ParquetDataValue converts column i of r to the value written for col in
+// the Parquet output.
+func ParquetDataValue(r row.Row, i int, col config.Column) (any, error) {
+	return convertToParquetType(r, i, col.Type)
+}
+
+// ParquetCompression returns the file compression codec of the Parquet
+// output of cfg.
+func ParquetCompression(cfg *config.Config) (compress.Codec, error) {
+	return getCompressionCodec(cfg.Output.Parquet.Compression)
+}
+
+// applyColumnOptions sets the encoding and compression configured for a
+// column.
+func applyColumnOptions(node parquet.Node, opts config.ParquetColumnConfig) (parquet.Node, error) {
+	enc, err := getEncoding(opts)
+	if err != nil {
+		return nil, err
+	}
+	if enc != nil {
+		kind := node.Type().Kind()
+		// Dictionary encoding applies to any type
+		if enc != &parquet.RLEDictionary && !canEncode(enc, kind) {
+			return nil, fmt.Errorf(
+				"encoding %s cannot be used for %s values",
+				enc.Encoding(),
+				kind,
+			)
+		}
+		node = parquet.Encoded(node, enc)
+	}
+
+	if opts.Compression != "" {
+		codec, err := getCompressionCodec(opts.Compression)
+		if err != nil {
+			return nil, err
+		}
+		node = parquet.Compressed(node, codec)
+	}
+	return node, nil
+}
+
+// getEncoding returns the encoding configured for a column, or nil to use
+// the default.
+func getEncoding(opts config.ParquetColumnConfig) (encoding.Encoding, error) {
+	if opts.Dictionary != nil && *opts.Dictionary {
+		return &parquet.RLEDictionary, nil
+	}
+	switch opts.Encoding {
+	case "":
+		return nil, nil
+	case "plain":
+		return &parquet.Plain, nil
+	case "rle":
+		return &parquet.RLE, nil
+	case "delta_binary_packed":
+		return &parquet.DeltaBinaryPacked, nil
+	case "delta_length_byte_array":
+		return &parquet.DeltaLengthByteArray, nil
+	case "delta_byte_array":
+		return &parquet.DeltaByteArray, nil
+	case "byte_stream_split":
+		return &parquet.ByteStreamSplit, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding: %s", opts.Encoding)
+	}
+}
+
+// canEncode reports whether enc supports values of the given kind, which
+// parquet.Encoded otherwise enforces with a panic.
+func canEncode(enc encoding.Encoding, kind parquet.Kind) bool {
+	switch kind {
+	case parquet.Boolean:
+		return encoding.CanEncodeBoolean(enc)
+	case parquet.Int32:
+		return encoding.CanEncodeInt32(enc)
+	case parquet.Int64:
+		return encoding.CanEncodeInt64(enc)
+	case parquet.Float:
+		return encoding.CanEncodeFloat(enc)
+	case parquet.Double:
+		return encoding.CanEncodeDouble(enc)
+	case parquet.ByteArray:
+		return encoding.CanEncodeByteArray(enc)
+	case parquet.FixedLenByteArray:
+		return encoding.CanEncodeFixedLenByteArray(enc)
+	default:
+		return false
+	}
 }
 
 // buildNetworkNode builds a Parquet node for a network column.
 func buildNetworkNode(col config.NetworkColumn, ipVersion int) (parquet.Node, error) {
 	switch col.Type {
-	case NetworkColumnCIDR, NetworkColumnStartIP, NetworkColumnEndIP:
+	case NetworkColumnCIDR, NetworkColumnStartIP, NetworkColumnEndIP,
+		NetworkColumnStartDecimal, NetworkColumnEndDecimal,
+		NetworkColumnValidFrom, NetworkColumnValidTo:
 		// String columns
 		return parquet.Optional(parquet.String()), nil
 
@@ -239,6 +414,14 @@ func buildNetworkNode(col config.NetworkColumn, ipVersion int) (parquet.Node, er
 	}
 }
 
+// nullIfEmpty returns nil for an empty string, which is written as null.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 func ipv6IntBytes(addr netip.Addr) []byte {
 	b := addr.As16()
 	out := make([]byte, 16)
@@ -271,75 +454,49 @@ func buildDataNode(col config.Column) (parquet.Node, error) {
 	}
 }
 
-// convertToParquetType converts a value to the appropriate Parquet type.
-func convertToParquetType(value any, typeHint string) (any, error) {
-	if value == nil {
+// convertToParquetType converts column i of r to the appropriate Parquet
+// type.
+func convertToParquetType(r row.Row, i int, typeHint string) (any, error) {
+	if r.IsNull(i) {
 		return nil, nil
 	}
 
 	// If no type hint, return as-is (will be string)
 	if typeHint == "" || typeHint == "string" {
-		return convertToString(value)
+		return r.Text(i)
 	}
 
 	switch typeHint {
 	case "int64":
-		switch v := value.(type) {
-		case mmdbtype.Int32:
-			return int64(v), nil
-		case mmdbtype.Uint16:
-			return int64(v), nil
-		case mmdbtype.Uint32:
-			return int64(v), nil
-		case mmdbtype.Uint64:
-			if v > 9223372036854775807 {
-				return nil, fmt.Errorf("uint64 value %d overflows int64", v)
-			}
-			//nolint:gosec // Overflow checked above
-			return int64(v), nil
-		case *mmdbtype.Uint128:
-			i := (*big.Int)(v)
-			if !i.IsInt64() {
-				return nil, fmt.Errorf("uint128 value %s overflows int64", i.String())
-			}
-			return i.Int64(), nil
-		default:
-			return nil, fmt.Errorf("cannot convert %T to int64", value)
+		if v, ok := r.Int64(i); ok {
+			return v, nil
+		}
+		if v, ok := r.BigInt(i); ok {
+			return nil, fmt.Errorf("%s value %s overflows int64", r.Kind(i), v)
 		}
+		return nil, fmt.Errorf("cannot convert %s to int64", r.Kind(i))
 
 	case "float64":
-		switch v := value.(type) {
-		case mmdbtype.Float32:
-			return float64(v), nil
-		case mmdbtype.Float64:
-			return float64(v), nil
-		case mmdbtype.Int32:
-			return float64(v), nil
-		case mmdbtype.Uint16:
-			return float64(v), nil
-		case mmdbtype.Uint32:
-			return float64(v), nil
-		case mmdbtype.Uint64:
-			return float64(v), nil
-		case *mmdbtype.Uint128:
-			i := (*big.Int)(v)
-			f, _ := i.Float64()
+		if v, ok := r.Float64(i); ok {
+			return v, nil
+		}
+		if v, ok := r.BigInt(i); ok {
+			f, _ := v.Float64()
 			return f, nil
-		default:
-			return nil, fmt.Errorf("cannot convert %T to float64", value)
 		}
+		return nil, fmt.Errorf("cannot convert %s to float64", r.Kind(i))
 
 	case "bool":
-		if v, ok := value.(mmdbtype.Bool); ok {
-			return bool(v), nil
+		if v, ok := r.Bool(i); ok {
+			return v, nil
 		}
-		return nil, fmt.Errorf("cannot convert %T to bool", value)
+		return nil, fmt.Errorf("cannot convert %s to bool", r.Kind(i))
 
 	case "binary":
-		if v, ok := value.(mmdbtype.Bytes); ok {
-			return []byte(v), nil
+		if v, ok := r.Bytes(i); ok {
+			return v, nil
 		}
-		return nil, fmt.Errorf("cannot convert %T to binary", value)
+		return nil, fmt.Errorf("cannot convert %s to binary", r.Kind(i))
 
 	default:
 		return nil, fmt.Errorf("unknown type hint: %s", typeHint)
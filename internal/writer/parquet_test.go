@@ -2,16 +2,21 @@ package writer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"net/netip"
 	"testing"
+	"time"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
 )
 
 func TestParquetWriter_SingleRow(t *testing.T) {
@@ -376,7 +381,7 @@ func TestParquetWriter_RowGroupSize(t *testing.T) {
 func TestConvertToParquetType(t *testing.T) {
 	tests := []struct {
 		name     string
-		value    any
+		value    mmdbtype.DataType
 		typeHint string
 		expected any
 		wantErr  bool
@@ -392,11 +397,12 @@ func TestConvertToParquetType(t *testing.T) {
 		{"binary", mmdbtype.Bytes([]byte{0xaa, 0xbb}), "binary", []byte{0xaa, 0xbb}, false},
 		{"invalid int conversion", mmdbtype.String("hello"), "int64", nil, true},
 		{"invalid bool conversion", mmdbtype.String("true"), "bool", nil, true},
+		{"uint64 overflows int64", mmdbtype.Uint64(1 << 63), "int64", nil, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := convertToParquetType(tt.value, tt.typeHint)
+			result, err := convertToParquetType(row.Row{tt.value}, 0, tt.typeHint)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -406,3 +412,310 @@ func TestConvertToParquetType(t *testing.T) {
 		})
 	}
 }
+
+func TestParquetWriter_ColumnOptions(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	dictionary := true
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Parquet: config.ParquetConfig{
+				Compression:  "snappy",
+				RowGroupSize: 1000,
+				Columns: map[string]config.ParquetColumnConfig{
+					"start_int": {Encoding: "delta_binary_packed"},
+					"country":   {Dictionary: &dictionary, Compression: "zstd"},
+					"latitude":  {Encoding: "byte_stream_split"},
+				},
+			},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_int", Type: "start_int"},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "latitude", Type: "float64"},
+		},
+	}
+
+	writer, err := NewParquetWriter(buf, cfg)
+	require.NoError(t, err)
+
+	for i := range 3 {
+		prefix := netip.MustParsePrefix(fmt.Sprintf("10.0.%d.0/24", i))
+		require.NoError(t, writer.WriteRow(prefix, row.Row{
+			mmdbtype.String("US"),
+			mmdbtype.Float64(37.75),
+		}))
+	}
+	require.NoError(t, writer.Flush())
+
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pf.NumRows())
+
+	chunks := map[string]format.ColumnMetaData{}
+	for _, chunk := range pf.Metadata().RowGroups[0].Columns {
+		chunks[chunk.MetaData.PathInSchema[0]] = chunk.MetaData
+	}
+	assert.Contains(t, chunks["start_int"].Encoding, format.DeltaBinaryPacked)
+	assert.Contains(t, chunks["country"].Encoding, format.RLEDictionary)
+	assert.Equal(t, format.Zstd, chunks["country"].Codec)
+	assert.Contains(t, chunks["latitude"].Encoding, format.ByteStreamSplit)
+	assert.Equal(t, format.Snappy, chunks["latitude"].Codec)
+
+	rows := make([]parquet.Row, 3)
+	n, err := pf.RowGroups()[0].Rows().ReadRows(rows)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, n)
+	assert.Equal(t, int64(0x0a000200), rows[2][2].Int64())
+}
+
+func TestParquetWriter_IncompatibleEncoding(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Parquet: config.ParquetConfig{
+				Compression: "none",
+				Columns: map[string]config.ParquetColumnConfig{
+					"country": {Encoding: "delta_binary_packed"},
+				},
+			},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	_, err := NewParquetWriter(&bytes.Buffer{}, cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "column 'country'")
+	assert.Contains(t, err.Error(), "cannot be used for BYTE_ARRAY values")
+}
+
+func TestParquetWriter_ProvenanceMetadata(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := newTestConfig("parquet")
+	cfg.Provenance = testProvenance
+
+	writer, err := NewParquetWriter(buf, cfg)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("AU")},
+	))
+	require.NoError(t, writer.Flush())
+
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	for _, pair := range testProvenance.Pairs() {
+		value, ok := pf.Lookup(pair.Key)
+		assert.True(t, ok, pair.Key)
+		assert.Equal(t, pair.Value, value, pair.Key)
+	}
+}
+
+func TestParquetWriter_ValidityColumns(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := newTestConfig("parquet")
+	cfg.Network.Columns = append(
+		cfg.Network.Columns,
+		config.NetworkColumn{Name: "valid_from", Type: "valid_from"},
+		config.NetworkColumn{Name: "valid_to", Type: "valid_to"},
+	)
+
+	writer, err := NewParquetWriter(buf, cfg)
+	require.NoError(t, err)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, writer.WriteRow(
+		netip.MustParsePrefix("1.0.0.0/24"),
+		row.Row{mmdbtype.String("AU")}.WithValidity(from, time.Time{}),
+	))
+	require.NoError(t, writer.Flush())
+
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	rows := make([]parquet.Row, 1)
+	n, err := pf.RowGroups()[0].Rows().ReadRows(rows)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, n)
+
+	validFrom, ok := pf.Schema().Lookup("valid_from")
+	require.True(t, ok)
+	validTo, ok := pf.Schema().Lookup("valid_to")
+	require.True(t, ok)
+	assert.Equal(t, "2025-01-01T00:00:00Z", rows[0][validFrom.ColumnIndex].String())
+	assert.True(t, rows[0][validTo.ColumnIndex].IsNull())
+}
+
+func TestParquetWriter_DecimalColumns(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Parquet: config.ParquetConfig{Compression: "none", RowGroupSize: 100},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "start_decimal", Type: "start_decimal"},
+				{Name: "end_decimal", Type: "end_decimal"},
+			},
+		},
+	}
+
+	// Both families can be written to one file
+	writer, err := NewParquetWriter(buf, cfg)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{}))
+	require.NoError(t, writer.WriteRow(netip.MustParsePrefix("2001:db8::/120"), row.Row{}))
+	require.NoError(t, writer.Flush())
+
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	rows := make([]parquet.Row, 2)
+	n, err := pf.RowGroups()[0].Rows().ReadRows(rows)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, n)
+
+	start, ok := pf.Schema().Lookup("start_decimal")
+	require.True(t, ok)
+	assert.Equal(t, parquet.ByteArray, start.Node.Type().Kind())
+	end, ok := pf.Schema().Lookup("end_decimal")
+	require.True(t, ok)
+	assert.Equal(t, "16777216", rows[0][start.ColumnIndex].String())
+	assert.Equal(t, "16777471", rows[0][end.ColumnIndex].String())
+	assert.Equal(t, "42540766411282592856903984951653826560", rows[1][start.ColumnIndex].String())
+	assert.Equal(t, "42540766411282592856903984951653826815", rows[1][end.ColumnIndex].String())
+}
+
+func TestParquetWriter_Groups(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Parquet: config.ParquetConfig{
+				Compression:  "snappy",
+				RowGroupSize: 1000,
+				Columns: map[string]config.ParquetColumnConfig{
+					"latitude": {Encoding: "byte_stream_split"},
+				},
+			},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "network", Type: "cidr"},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "latitude", Type: "float64", Group: "location"},
+			{Name: "time_zone", Group: "location"},
+		},
+	}
+
+	writer, err := NewParquetWriter(buf, cfg)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRow(netip.MustParsePrefix("10.0.0.0/24"), row.Row{
+		mmdbtype.String("US"),
+		mmdbtype.Float64(37.75),
+		mmdbtype.String("America/Chicago"),
+	}))
+	require.NoError(t, writer.WriteRow(netip.MustParsePrefix("10.0.1.0/24"), row.Row{
+		mmdbtype.String("US"),
+		nil,
+		nil,
+	}))
+	require.NoError(t, writer.Flush())
+
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"country"},
+		{"location", "latitude"},
+		{"location", "time_zone"},
+		{"network"},
+	}, pf.Schema().Columns())
+
+	// Column options apply to columns within groups
+	for _, chunk := range pf.Metadata().RowGroups[0].Columns {
+		if chunk.MetaData.PathInSchema[len(chunk.MetaData.PathInSchema)-1] == "latitude" {
+			assert.Contains(t, chunk.MetaData.Encoding, format.ByteStreamSplit)
+		}
+	}
+
+	records := []map[string]any{{}, {}}
+	r := parquet.NewGenericReader[map[string]any](bytes.NewReader(buf.Bytes()), pf.Schema())
+	n, err := r.Read(records)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, n)
+	assert.Equal(t, map[string]any{
+		"network": "10.0.0.0/24",
+		"country": "US",
+		"location": map[string]any{
+			"latitude":  37.75,
+			"time_zone": "America/Chicago",
+		},
+	}, records[0])
+	assert.Equal(t, map[string]any{"latitude": nil, "time_zone": nil}, records[1]["location"])
+}
+
+func TestParquetWriter_Aliases(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Parquet: config.ParquetConfig{
+				Compression:  "snappy",
+				RowGroupSize: 1000,
+			},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{
+				{Name: "network", Type: "cidr", Aliases: config.Aliases{StorageName: "ip_network"}},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country", Aliases: config.Aliases{Header: "Country", StorageName: "cntry_cd"}},
+			{Name: "time_zone", Group: "location", Aliases: config.Aliases{StorageName: "tz_nm"}},
+		},
+	}
+
+	writer, err := NewParquetWriter(buf, cfg)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRow(netip.MustParsePrefix("10.0.0.0/24"), row.Row{
+		mmdbtype.String("US"),
+		mmdbtype.String("America/Chicago"),
+	}))
+	require.NoError(t, writer.Flush())
+
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"cntry_cd"},
+		{"ip_network"},
+		{"location", "tz_nm"},
+	}, pf.Schema().Columns())
+
+	records := []map[string]any{{}}
+	r := parquet.NewGenericReader[map[string]any](bytes.NewReader(buf.Bytes()), pf.Schema())
+	n, err := r.Read(records)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, n)
+	assert.Equal(t, "US", records[0]["cntry_cd"])
+	assert.Equal(t, "10.0.0.0/24", records[0]["ip_network"])
+	assert.Equal(t, map[string]any{"tz_nm": "America/Chicago"}, records[0]["location"])
+}
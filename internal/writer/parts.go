@@ -0,0 +1,148 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PartFiles creates the numbered files of an output rotated by size, such
+// as out-0001.csv and out-0002.csv for out.csv, and writes the continuation
+// manifest out.manifest.json listing them in order.
+type PartFiles struct {
+	path     string
+	wrap     func(io.Writer) io.Writer
+	file     *os.File
+	manifest Manifest
+}
+
+// Manifest lists the parts of a rotated output in order. File names are
+// relative to the directory of the manifest.
+type Manifest struct {
+	Parts []ManifestPart `json:"parts"`
+}
+
+// ManifestPart describes one part file of a rotated output.
+type ManifestPart struct {
+	File string `json:"file"`
+	Size int64  `json:"size"`
+	Rows int64  `json:"rows"`
+}
+
+// NewPartFiles returns the part files for the configured output path. wrap,
+// if not nil, wraps every part file, e.g. to throttle writes.
+func NewPartFiles(path string, wrap func(io.Writer) io.Writer) *PartFiles {
+	return &PartFiles{path: path, wrap: wrap}
+}
+
+// Next closes the current part file, if any, and creates the next one.
+func (p *PartFiles) Next() (io.Writer, error) {
+	if err := p.closeFile(); err != nil {
+		return nil, err
+	}
+
+	path := p.partPath(len(p.manifest.Parts) + 1)
+	// #nosec G304 -- paths come from trusted configuration
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", path, err)
+	}
+	p.file = file
+	p.manifest.Parts = append(p.manifest.Parts, ManifestPart{File: filepath.Base(path)})
+
+	if p.wrap == nil {
+		return file, nil
+	}
+	return p.wrap(file), nil
+}
+
+// SetCurrent records the size and row count of the current part.
+func (p *PartFiles) SetCurrent(size, rows int64) {
+	if len(p.manifest.Parts) == 0 {
+		return
+	}
+	part := &p.manifest.Parts[len(p.manifest.Parts)-1]
+	part.Size = size
+	part.Rows = rows
+}
+
+// Paths returns the paths of the part files created so far.
+func (p *PartFiles) Paths() []string {
+	paths := make([]string, len(p.manifest.Parts))
+	for i, part := range p.manifest.Parts {
+		paths[i] = filepath.Join(filepath.Dir(p.path), part.File)
+	}
+	return paths
+}
+
+// ManifestPath returns the path of the manifest.
+func (p *PartFiles) ManifestPath() string {
+	ext := filepath.Ext(p.path)
+	return strings.TrimSuffix(p.path, ext) + ".manifest.json"
+}
+
+// WriteManifest replaces the manifest with the parts created so far. The
+// manifest has the mode of the part files.
+func (p *PartFiles) WriteManifest() error {
+	data, err := json.MarshalIndent(p.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	path := p.ManifestPath()
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	// Temporary files are only readable by their owner, whereas the
+	// manifest is read with the parts, so it gets their mode
+	if len(p.manifest.Parts) > 0 {
+		if info, err := os.Stat(p.partPath(1)); err == nil {
+			if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+				tmp.Close()
+				os.Remove(tmp.Name())
+				return fmt.Errorf("writing manifest: %w", err)
+			}
+		}
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	return nil
+}
+
+// Close closes the current part file.
+func (p *PartFiles) Close() error {
+	return p.closeFile()
+}
+
+func (p *PartFiles) closeFile() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	if err != nil {
+		return fmt.Errorf("closing part file: %w", err)
+	}
+	return nil
+}
+
+// partPath returns the path of part n: the configured path with -NNNN
+// inserted before the extension.
+func (p *PartFiles) partPath(n int) string {
+	ext := filepath.Ext(p.path)
+	return fmt.Sprintf("%s-%04d%s", strings.TrimSuffix(p.path, ext), n, ext)
+}
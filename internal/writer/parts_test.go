@@ -0,0 +1,103 @@
+package writer
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestRotatingCSVWriter(t *testing.T) {
+	dir := t.TempDir()
+	includeHeader := true
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			CSV: config.CSVConfig{
+				Delimiter:     ",",
+				IncludeHeader: &includeHeader,
+				MaxFileSize:   44,
+			},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	parts := NewPartFiles(filepath.Join(dir, "out.csv"), nil)
+	w, err := NewRotatingCSVWriter(parts, cfg)
+	require.NoError(t, err)
+
+	// The header is 16 bytes and each row 14, so two rows fill a part
+	for _, prefix := range []string{"1.0.0.0/24", "2.0.0.0/24", "3.0.0.0/24", "4.0.0.0/24", "5.0.0.0/24"} {
+		require.NoError(t, w.WriteRow(netip.MustParsePrefix(prefix), row.Row{mmdbtype.String("AU")}))
+	}
+	require.NoError(t, w.Flush())
+	require.NoError(t, parts.Close())
+
+	expected := map[string]string{
+		"out-0001.csv": "network,country\n1.0.0.0/24,AU\n2.0.0.0/24,AU\n",
+		"out-0002.csv": "network,country\n3.0.0.0/24,AU\n4.0.0.0/24,AU\n",
+		"out-0003.csv": "network,country\n5.0.0.0/24,AU\n",
+	}
+	for name, content := range expected {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data), name)
+	}
+
+	assert.Equal(t, filepath.Join(dir, "out.manifest.json"), parts.ManifestPath())
+	data, err := os.ReadFile(parts.ManifestPath())
+	require.NoError(t, err)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, Manifest{Parts: []ManifestPart{
+		{File: "out-0001.csv", Size: 44, Rows: 2},
+		{File: "out-0002.csv", Size: 44, Rows: 2},
+		{File: "out-0003.csv", Size: 30, Rows: 1},
+	}}, manifest)
+
+	// The manifest is as readable as the parts
+	partInfo, err := os.Stat(filepath.Join(dir, "out-0001.csv"))
+	require.NoError(t, err)
+	manifestInfo, err := os.Stat(parts.ManifestPath())
+	require.NoError(t, err)
+	assert.Equal(t, partInfo.Mode(), manifestInfo.Mode())
+}
+
+func TestRotatingCSVWriter_OversizedRow(t *testing.T) {
+	dir := t.TempDir()
+	includeHeader := false
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			CSV: config.CSVConfig{Delimiter: ",", IncludeHeader: &includeHeader, MaxFileSize: 5},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+
+	parts := NewPartFiles(filepath.Join(dir, "out.csv"), nil)
+	w, err := NewRotatingCSVWriter(parts, cfg)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{mmdbtype.String("AU")}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2.0.0.0/24"), row.Row{mmdbtype.String("NZ")}))
+	require.NoError(t, w.Flush())
+	require.NoError(t, parts.Close())
+
+	// Rows larger than the limit get a part each
+	assert.Equal(
+		t,
+		[]string{filepath.Join(dir, "out-0001.csv"), filepath.Join(dir, "out-0002.csv")},
+		parts.Paths(),
+	)
+}
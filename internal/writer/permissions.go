@@ -0,0 +1,151 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// PermissionsWriter wraps the row writer of an output and sets the mode and
+// owner of output.permissions on its files once flushed, as some writers
+// only create or complete their files then.
+type PermissionsWriter struct {
+	writer row.Writer
+	paths  func() []string
+	mode   os.FileMode // 0 keeps the mode of the files
+	uid    int         // -1 keeps the owner of the files
+	gid    int         // -1 keeps the group of the files
+}
+
+// NewPermissionsWriter creates a writer setting perms on the files paths
+// returns once writer is flushed. Only root can give the files to another
+// user, or to a group it is not a member of, such as in a container writing
+// to a shared volume; other users fail rather than leave the owner unset.
+func NewPermissionsWriter(
+	writer row.Writer,
+	perms config.PermissionsConfig,
+	paths func() []string,
+) (*PermissionsWriter, error) {
+	gids, err := os.Getgroups()
+	if err != nil {
+		return nil, fmt.Errorf("listing groups of the process: %w", err)
+	}
+	return newPermissionsWriter(
+		writer,
+		perms,
+		paths,
+		os.Geteuid(),
+		append(gids, os.Getegid()),
+	)
+}
+
+// newPermissionsWriter is NewPermissionsWriter for a process running as
+// euid, a member of gids.
+func newPermissionsWriter(
+	writer row.Writer,
+	perms config.PermissionsConfig,
+	paths func() []string,
+	euid int,
+	gids []int,
+) (*PermissionsWriter, error) {
+	p := &PermissionsWriter{
+		writer: writer,
+		paths:  paths,
+		mode:   perms.FileMode(),
+		uid:    -1,
+		gid:    -1,
+	}
+	if perms.Owner != "" {
+		uid, err := lookupID(perms.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("looking up output.permissions.owner: %w", err)
+		}
+		if euid != 0 && uid != euid {
+			return nil, fmt.Errorf(
+				"output.permissions.owner '%s' is another user; only root can set it",
+				perms.Owner,
+			)
+		}
+		p.uid = uid
+	}
+	if perms.Group != "" {
+		gid, err := lookupID(perms.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("looking up output.permissions.group: %w", err)
+		}
+		if euid != 0 && !slices.Contains(gids, gid) {
+			return nil, fmt.Errorf(
+				"output.permissions.group '%s' is not a group of the process; only root can set it",
+				perms.Group,
+			)
+		}
+		p.gid = gid
+	}
+	return p, nil
+}
+
+// lookupID returns the numeric ID name, or the ID lookup returns for it.
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// WriteRow writes a single row.
+func (p *PermissionsWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return p.writer.WriteRow(prefix, r)
+}
+
+// WriteRange writes a range.
+func (p *PermissionsWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	return row.WriteRange(p.writer, start, end, r)
+}
+
+// Flush flushes the wrapped writer, then sets the permissions of its
+// files.
+func (p *PermissionsWriter) Flush() error {
+	if err := row.Flush(p.writer); err != nil {
+		return err
+	}
+	for _, path := range p.paths() {
+		if p.mode != 0 {
+			if err := os.Chmod(path, p.mode); err != nil {
+				return fmt.Errorf("setting mode of %s: %w", path, err)
+			}
+		}
+		if p.uid >= 0 || p.gid >= 0 {
+			if err := os.Chown(path, p.uid, p.gid); err != nil {
+				return fmt.Errorf("setting owner of %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Sync syncs the wrapped writer.
+func (p *PermissionsWriter) Sync() error {
+	return row.Sync(p.writer)
+}
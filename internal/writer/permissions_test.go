@@ -0,0 +1,109 @@
+package writer
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestPermissionsWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	var flushed bool
+	inner := &recordWriter{}
+	w, err := NewPermissionsWriter(
+		inner,
+		config.PermissionsConfig{
+			Mode:  "0640",
+			Owner: strconv.Itoa(os.Geteuid()),
+			Group: strconv.Itoa(os.Getegid()),
+		},
+		func() []string {
+			// The files are listed once flushed
+			flushed = true
+			require.NoError(t, os.WriteFile(path, nil, 0o600))
+			return []string{path}
+		},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{mmdbtype.String("US")}))
+	assert.False(t, flushed)
+	require.NoError(t, w.Flush())
+	assert.Len(t, inner.rows, 1)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+}
+
+func TestPermissionsWriter_MissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.csv")
+	w, err := NewPermissionsWriter(
+		&recordWriter{},
+		config.PermissionsConfig{Mode: "0644"},
+		func() []string { return []string{path} },
+	)
+	require.NoError(t, err)
+	require.ErrorContains(t, w.Flush(), "setting mode of "+path)
+}
+
+func TestPermissionsWriter_NotRoot(t *testing.T) {
+	const euid, egid = 1000, 1000
+	perms := config.PermissionsConfig{Owner: "1000", Group: "1000"}
+	newWriter := func(perms config.PermissionsConfig) (*PermissionsWriter, error) {
+		return newPermissionsWriter(
+			&recordWriter{},
+			perms,
+			func() []string { return nil },
+			euid,
+			[]int{egid, 2000},
+		)
+	}
+
+	// The process's own user and groups are allowed
+	w, err := newWriter(perms)
+	require.NoError(t, err)
+	assert.Equal(t, euid, w.uid)
+	assert.Equal(t, egid, w.gid)
+	perms.Group = "2000"
+	_, err = newWriter(perms)
+	require.NoError(t, err)
+
+	perms.Owner = "1001"
+	_, err = newWriter(perms)
+	require.EqualError(
+		t,
+		err,
+		"output.permissions.owner '1001' is another user; only root can set it",
+	)
+
+	perms.Owner = ""
+	perms.Group = "3000"
+	_, err = newWriter(perms)
+	require.EqualError(
+		t,
+		err,
+		"output.permissions.group '3000' is not a group of the process; only root can set it",
+	)
+
+	// Root may give files to anyone
+	w, err = newPermissionsWriter(
+		&recordWriter{},
+		config.PermissionsConfig{Owner: "1001", Group: "3000"},
+		func() []string { return nil },
+		0,
+		[]int{0},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1001, w.uid)
+	assert.Equal(t, 3000, w.gid)
+}
@@ -0,0 +1,445 @@
+package writer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// errPostgresAborted ends the COPY of a writer closed without being flushed.
+var errPostgresAborted = errors.New("output closed before the rows were complete")
+
+// PostgresWriter streams rows into a PostgreSQL table with COPY, in the text
+// format, within a transaction committed when the writer is flushed. Until
+// then, readers of the table see its previous rows, which
+// output.postgres.truncate removes in the same transaction.
+type PostgresWriter struct {
+	ctx          context.Context
+	conn         *pgconn.PgConn
+	config       *config.Config
+	out          io.Writer // COPY data, streamed to the server
+	pipe         *io.PipeWriter
+	copyDone     chan error
+	rangeCapable bool
+	buf          []byte // Serialized rows not yet written to out
+}
+
+// NewPostgresWriter connects to output.postgres.dsn and starts copying rows
+// into output.postgres.table, creating the table first if configured.
+// wrapOutput, if not nil, wraps the COPY data sent to the server, for example
+// to limit the write rate.
+func NewPostgresWriter(
+	ctx context.Context,
+	cfg *config.Config,
+	wrapOutput func(io.Writer) io.Writer,
+) (*PostgresWriter, error) {
+	conn, err := pgconn.Connect(ctx, cfg.Output.Postgres.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to PostgreSQL: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	w := newPostgresWriter(pw, cfg)
+	w.ctx = ctx
+	w.conn = conn
+	w.pipe = pw
+	if wrapOutput != nil {
+		w.out = wrapOutput(pw)
+	}
+	if err := w.begin(); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	w.copyDone = make(chan error, 1)
+	go func() {
+		_, err := conn.CopyFrom(ctx, pr, postgresCopy(cfg))
+		// Rows written after a failed COPY fail rather than block
+		pr.CloseWithError(err)
+		w.copyDone <- err
+	}()
+	return w, nil
+}
+
+// newPostgresWriter creates a writer serializing the COPY data of rows to
+// w, without a connection.
+func newPostgresWriter(w io.Writer, cfg *config.Config) *PostgresWriter {
+	return &PostgresWriter{
+		out:    w,
+		config: cfg,
+		rangeCapable: !slices.ContainsFunc(cfg.Network.Columns, func(col config.NetworkColumn) bool {
+			return col.Type == NetworkColumnCIDR
+		}),
+		buf: make([]byte, 0, csvFlushSize+4096),
+	}
+}
+
+// begin starts the transaction, and creates or empties the table if
+// configured.
+func (w *PostgresWriter) begin() error {
+	statements := []string{"BEGIN"}
+	pg := w.config.Output.Postgres
+	if pg.CreateTable {
+		statements = append(statements, postgresDDL(w.config))
+	}
+	if pg.Truncate {
+		statements = append(statements, "TRUNCATE "+quotePostgresTable(pg.Table))
+	}
+	for _, statement := range statements {
+		if _, err := w.conn.Exec(w.ctx, statement).ReadAll(); err != nil {
+			return fmt.Errorf("preparing table %s: %w", pg.Table, err)
+		}
+	}
+	return nil
+}
+
+// postgresDDL returns the statement creating the table of the PostgreSQL
+// output of cfg, unless it exists. CIDR columns are cidr and address
+// columns inet, so that both IP versions share a table, and integers are
+// numeric, which holds IPv6 addresses.
+func postgresDDL(cfg *config.Config) string {
+	columns := make([]string, 0, len(cfg.Network.Columns)+len(cfg.Columns))
+	for _, col := range cfg.Network.Columns {
+		columns = append(
+			columns,
+			quotePostgresIdentifier(col.OutputName("postgres"))+" "+postgresNetworkType(col.Type),
+		)
+	}
+	for _, col := range cfg.Columns {
+		columns = append(
+			columns,
+			quotePostgresIdentifier(col.OutputName("postgres"))+" "+postgresDataType(col.Type),
+		)
+	}
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (%s)",
+		quotePostgresTable(cfg.Output.Postgres.Table),
+		strings.Join(columns, ", "),
+	)
+}
+
+// postgresIndex returns the statement indexing the table of the PostgreSQL
+// output of cfg for lookups, unless the index exists: a GiST index on the
+// first CIDR column, which serves the inet containment operators, or
+// otherwise a B-tree index on the first start column. It returns "" if the
+// table has neither.
+func postgresIndex(cfg *config.Config) string {
+	table := cfg.Output.Postgres.Table
+	index := table[strings.LastIndexByte(table, '.')+1:] + "_lookup"
+	for _, method := range []struct {
+		colType string
+		using   string
+	}{
+		{NetworkColumnCIDR, "gist (%s inet_ops)"},
+		{NetworkColumnStartIP, "btree (%s)"},
+		{NetworkColumnStartInt, "btree (%s)"},
+		{NetworkColumnStartDecimal, "btree (%s)"},
+	} {
+		for _, col := range cfg.Network.Columns {
+			if col.Type != method.colType {
+				continue
+			}
+			return fmt.Sprintf(
+				"CREATE INDEX IF NOT EXISTS %s ON %s USING "+method.using,
+				quotePostgresIdentifier(index),
+				quotePostgresTable(table),
+				quotePostgresIdentifier(col.OutputName("postgres")),
+			)
+		}
+	}
+	return ""
+}
+
+// postgresCopy returns the COPY statement loading the rows of cfg.
+func postgresCopy(cfg *config.Config) string {
+	columns := make([]string, 0, len(cfg.Network.Columns)+len(cfg.Columns))
+	for _, col := range cfg.Network.Columns {
+		columns = append(columns, quotePostgresIdentifier(col.OutputName("postgres")))
+	}
+	for _, col := range cfg.Columns {
+		columns = append(columns, quotePostgresIdentifier(col.OutputName("postgres")))
+	}
+	return fmt.Sprintf(
+		"COPY %s (%s) FROM STDIN",
+		quotePostgresTable(cfg.Output.Postgres.Table),
+		strings.Join(columns, ", "),
+	)
+}
+
+// postgresNetworkType returns the PostgreSQL type of a network column.
+func postgresNetworkType(colType string) string {
+	switch colType {
+	case NetworkColumnCIDR:
+		return "cidr"
+	case NetworkColumnStartIP, NetworkColumnEndIP:
+		return "inet"
+	case NetworkColumnStartDecimal, NetworkColumnEndDecimal:
+		return "numeric(39,0)"
+	case NetworkColumnValidFrom, NetworkColumnValidTo:
+		return "text"
+	default:
+		return "numeric"
+	}
+}
+
+// postgresDataType returns the PostgreSQL type of a data column with a type
+// hint.
+func postgresDataType(typeHint string) string {
+	switch typeHint {
+	case "int64":
+		return "bigint"
+	case "float64":
+		return "double precision"
+	case "bool":
+		return "boolean"
+	case "binary":
+		return "bytea"
+	default:
+		return "text"
+	}
+}
+
+// quotePostgresIdentifier quotes a column or index name.
+func quotePostgresIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quotePostgresTable quotes a table name, which may be qualified by its
+// schema, as in "geo.networks".
+func quotePostgresTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quotePostgresIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// WriteRow writes a single row with network prefix and column data.
+func (w *PostgresWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeRecord(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *PostgresWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, writing a single row unless a
+// network column is a CIDR.
+func (w *PostgresWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		for _, cidr := range network.RangeToPrefixes(start, end) {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.writeRecord(netip.Prefix{}, start, end, r)
+}
+
+// writeRecord serializes a row. prefix is invalid for range rows.
+func (w *PostgresWriter) writeRecord(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	// Discard a partially serialized row on error
+	rowStart := len(w.buf)
+	field := 0
+
+	for _, netCol := range w.config.Network.Columns {
+		w.separate(field)
+		field++
+		if err := w.appendNetworkValue(prefix, start, end, r, netCol.Type); err != nil {
+			w.buf = w.buf[:rowStart]
+			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
+		}
+	}
+
+	for i, col := range w.config.Columns {
+		w.separate(field)
+		field++
+		value, err := convertToParquetType(r, i, col.Type)
+		if err != nil {
+			w.buf = w.buf[:rowStart]
+			return fmt.Errorf("converting column '%s': %w", col.Name, err)
+		}
+		w.appendDataValue(value)
+	}
+	w.buf = append(w.buf, '\n')
+
+	if len(w.buf) >= csvFlushSize {
+		return w.flushBuffer()
+	}
+	return nil
+}
+
+// separate starts field i of a row.
+func (w *PostgresWriter) separate(i int) {
+	if i > 0 {
+		w.buf = append(w.buf, '\t')
+	}
+}
+
+// appendNetworkValue appends the value of a network column.
+func (w *PostgresWriter) appendNetworkValue(
+	prefix netip.Prefix,
+	start, end netip.Addr,
+	r row.Row,
+	colType string,
+) error {
+	switch colType {
+	case NetworkColumnValidFrom:
+		w.appendNullableString(r.ValidFrom(len(w.config.Columns)))
+	case NetworkColumnValidTo:
+		w.appendNullableString(r.ValidTo(len(w.config.Columns)))
+	default:
+		// Addresses and integers need no escaping
+		var err error
+		w.buf, err = appendNetworkValue(w.buf, prefix, start, end, colType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendNullableString appends a string, null if empty.
+func (w *PostgresWriter) appendNullableString(s string) {
+	if s == "" {
+		w.buf = append(w.buf, `\N`...)
+		return
+	}
+	w.appendString([]byte(s))
+}
+
+// appendDataValue appends the value of a data column, converted by its type
+// hint.
+func (w *PostgresWriter) appendDataValue(value any) {
+	switch v := value.(type) {
+	case nil:
+		w.buf = append(w.buf, `\N`...)
+	case string:
+		w.appendString([]byte(v))
+	case []byte:
+		// The bytea hex format, whose backslash COPY escapes
+		w.buf = append(w.buf, `\\x`...)
+		w.buf = hex.AppendEncode(w.buf, v)
+	case int64:
+		w.buf = strconv.AppendInt(w.buf, v, 10)
+	case float64:
+		w.buf = appendPostgresFloat(w.buf, v)
+	case bool:
+		if v {
+			w.buf = append(w.buf, 't')
+		} else {
+			w.buf = append(w.buf, 'f')
+		}
+	}
+}
+
+// appendPostgresFloat appends a float as PostgreSQL parses it, which spells
+// infinities and NaN out.
+func appendPostgresFloat(dst []byte, f float64) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return append(dst, "Infinity"...)
+	case math.IsInf(f, -1):
+		return append(dst, "-Infinity"...)
+	case math.IsNaN(f):
+		return append(dst, "NaN"...)
+	default:
+		return strconv.AppendFloat(dst, f, 'g', -1, 64)
+	}
+}
+
+// appendString appends a string escaped for the COPY text format.
+func (w *PostgresWriter) appendString(s []byte) {
+	for _, c := range s {
+		switch c {
+		case '\\':
+			w.buf = append(w.buf, `\\`...)
+		case '\t':
+			w.buf = append(w.buf, `\t`...)
+		case '\n':
+			w.buf = append(w.buf, `\n`...)
+		case '\r':
+			w.buf = append(w.buf, `\r`...)
+		default:
+			w.buf = append(w.buf, c)
+		}
+	}
+}
+
+// flushBuffer writes all buffered rows to the COPY data.
+func (w *PostgresWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("copying rows to PostgreSQL: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush ends the COPY, indexes the table if it was created, and commits
+// the rows. Later calls do nothing.
+func (w *PostgresWriter) Flush() error {
+	if err := w.flushBuffer(); err != nil {
+		return err
+	}
+	if w.conn == nil {
+		return nil
+	}
+	defer w.Close()
+
+	w.pipe.Close()
+	err := <-w.copyDone
+	w.copyDone = nil
+	if err != nil {
+		return fmt.Errorf("copying rows to PostgreSQL: %w", err)
+	}
+
+	statements := []string{"COMMIT"}
+	if w.config.Output.Postgres.CreateTable {
+		if index := postgresIndex(w.config); index != "" {
+			statements = []string{index, "COMMIT"}
+		}
+	}
+	for _, statement := range statements {
+		if _, err := w.conn.Exec(w.ctx, statement).ReadAll(); err != nil {
+			return fmt.Errorf("committing rows to PostgreSQL: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close aborts the COPY if the writer was not flushed, which rolls the
+// transaction back, and closes the connection.
+func (w *PostgresWriter) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	w.buf = w.buf[:0]
+	if w.copyDone != nil {
+		w.pipe.CloseWithError(errPostgresAborted)
+		<-w.copyDone
+		w.copyDone = nil
+	}
+	err := w.conn.Close(w.ctx)
+	w.conn = nil
+	return err
+}
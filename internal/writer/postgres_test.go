@@ -0,0 +1,249 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func postgresConfig(network ...config.NetworkColumn) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{
+			Postgres: config.PostgresConfig{Table: "geo.networks"},
+		},
+		Network: config.NetworkConfig{Columns: network},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "accuracy", Type: "int64"},
+			{Name: "is_eu", Type: "bool"},
+			{Name: "raw", Type: "binary"},
+		},
+	}
+}
+
+func TestPostgresDDL(t *testing.T) {
+	cfg := postgresConfig(
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "end_ip", Type: NetworkColumnEndIP},
+	)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "geo"."networks" (`+
+		`"start_int" numeric, "network" cidr, "end_ip" inet, `+
+		`"country" text, "accuracy" bigint, "is_eu" boolean, "raw" bytea)`, postgresDDL(cfg))
+	assert.Equal(t, `COPY "geo"."networks" `+
+		`("start_int", "network", "end_ip", "country", "accuracy", "is_eu", "raw") FROM STDIN`,
+		postgresCopy(cfg))
+
+	// A CIDR column is preferred for the index, as inet operators use it
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "networks_lookup" ON "geo"."networks" `+
+		`USING gist ("network" inet_ops)`, postgresIndex(cfg))
+
+	cfg.Network.Columns = cfg.Network.Columns[:1]
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "networks_lookup" ON "geo"."networks" `+
+		`USING btree ("start_int")`, postgresIndex(cfg))
+
+	cfg.Network.Columns = nil
+	assert.Empty(t, postgresIndex(cfg))
+}
+
+func TestPostgresDDL_Decimal(t *testing.T) {
+	cfg := postgresConfig(
+		config.NetworkColumn{Name: "start_decimal", Type: NetworkColumnStartDecimal},
+		config.NetworkColumn{Name: "end_decimal", Type: NetworkColumnEndDecimal},
+	)
+	assert.Contains(t, postgresDDL(cfg),
+		`("start_decimal" numeric(39,0), "end_decimal" numeric(39,0), "country" text,`)
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "networks_lookup" ON "geo"."networks" `+
+		`USING btree ("start_decimal")`, postgresIndex(cfg))
+}
+
+func TestPostgresWriter_Rows(t *testing.T) {
+	var buf bytes.Buffer
+	w := newPostgresWriter(&buf, postgresConfig(
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "end_int", Type: NetworkColumnEndInt},
+	))
+	require.True(t, w.WritesRanges())
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("U\tS\\\n"),
+		mmdbtype.Uint16(100),
+		mmdbtype.Bool(false),
+		mmdbtype.Bytes{0x01, 0xab},
+	}))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2001:db8::"),
+		netip.MustParseAddr("2001:db8::ff"),
+		row.Row{nil, nil, mmdbtype.Bool(true), nil},
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, "1.0.0.0\t16777471\tU\\tS\\\\\\n\t100\tf\t\\\\x01ab\n"+
+		"2001:db8::\t42540766411282592856903984951653826815\t\\N\t\\N\tt\t\\N\n", buf.String())
+}
+
+func TestPostgresWriter_InvalidValue(t *testing.T) {
+	var buf bytes.Buffer
+	w := newPostgresWriter(&buf, postgresConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+	))
+	require.False(t, w.WritesRanges())
+
+	err := w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("US"),
+		mmdbtype.String("not a number"),
+		nil,
+		nil,
+	})
+	require.ErrorContains(t, err, "converting column 'accuracy'")
+
+	// The failed row is not written
+	require.NoError(t, w.Flush())
+	assert.Empty(t, buf.String())
+}
+
+func TestPostgresWriter_Copy(t *testing.T) {
+	server := startFakePostgres(t)
+	cfg := postgresConfig(config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR})
+	cfg.Output.Postgres.DSN = server.dsn
+	cfg.Output.Postgres.CreateTable = true
+	cfg.Output.Postgres.Truncate = true
+
+	w, err := NewPostgresWriter(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("US"), mmdbtype.Uint16(5), mmdbtype.Bool(true), nil,
+	}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{
+		mmdbtype.String("DE"), nil, nil, nil,
+	}))
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Close())
+
+	statements := <-server.done
+	assert.Equal(t, []string{
+		"BEGIN",
+		postgresDDL(cfg),
+		`TRUNCATE "geo"."networks"`,
+		postgresCopy(cfg),
+		postgresIndex(cfg),
+		"COMMIT",
+	}, statements.queries)
+	assert.Equal(t, "1.0.0.0/24\tUS\t5\tt\t\\N\n"+
+		"2001:db8::/32\tDE\t\\N\t\\N\t\\N\n", statements.copied)
+}
+
+func TestPostgresWriter_Close(t *testing.T) {
+	server := startFakePostgres(t)
+	cfg := postgresConfig(config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR})
+	cfg.Output.Postgres.DSN = server.dsn
+
+	w, err := NewPostgresWriter(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("US"), nil, nil, nil,
+	}))
+
+	// Closing without flushing fails the COPY, and never commits
+	require.NoError(t, w.Close())
+	statements := <-server.done
+	assert.Equal(t, []string{"BEGIN", postgresCopy(cfg)}, statements.queries)
+	assert.True(t, statements.copyFailed)
+	require.NoError(t, w.Flush())
+}
+
+// fakePostgres is a server speaking enough of the PostgreSQL protocol to
+// run simple queries and COPY FROM STDIN on a single connection.
+type fakePostgres struct {
+	dsn  string
+	done chan fakePostgresSession
+}
+
+// fakePostgresSession is what a client sent a fakePostgres.
+type fakePostgresSession struct {
+	queries    []string
+	copied     string
+	copyFailed bool
+}
+
+func startFakePostgres(t *testing.T) *fakePostgres {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakePostgres{
+		dsn:  "postgres://test@" + listener.Addr().String() + "/test?sslmode=disable",
+		done: make(chan fakePostgresSession, 1),
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		server.done <- serveFakePostgres(pgproto3.NewBackend(conn, conn))
+	}()
+	return server
+}
+
+func serveFakePostgres(backend *pgproto3.Backend) fakePostgresSession {
+	var session fakePostgresSession
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return session
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if backend.Flush() != nil {
+		return session
+	}
+
+	var copied strings.Builder
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return session
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			session.queries = append(session.queries, msg.String)
+			if strings.HasPrefix(msg.String, "COPY ") {
+				backend.Send(&pgproto3.CopyInResponse{})
+				break
+			}
+			tag := strings.Fields(msg.String)[0]
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'T'})
+		case *pgproto3.CopyData:
+			copied.Write(msg.Data)
+		case *pgproto3.CopyDone:
+			session.copied = copied.String()
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("COPY")})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'T'})
+		case *pgproto3.CopyFail:
+			session.copyFailed = true
+			backend.Send(&pgproto3.ErrorResponse{
+				Severity: "ERROR",
+				Code:     "57014",
+				Message:  "COPY from stdin failed: " + msg.Message,
+			})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'E'})
+		case *pgproto3.Terminate:
+			return session
+		}
+		if backend.Flush() != nil {
+			return session
+		}
+	}
+}
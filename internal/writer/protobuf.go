@@ -0,0 +1,259 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"go4.org/netipx"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// ProtobufWriter writes each row as a protobuf message of the schema
+// ProtobufSchema generates, preceded by its length as a varint, as Java's
+// writeDelimitedTo and Go's protodelim package read them. Fields are
+// numbered in column order, starting at 1.
+type ProtobufWriter struct {
+	out          io.Writer
+	config       *config.Config
+	rangeCapable bool
+	encoder      protobufEncoder
+	buf          []byte // Messages not yet written to out
+}
+
+// protobufEncoder serializes rows as messages whose fields are numbered in
+// column order, starting at 1, with the types ProtobufSchema gives them.
+type protobufEncoder struct {
+	config *config.Config
+	msg    []byte // The message being serialized
+	field  []byte // Scratch space for formatting a single field
+}
+
+// newProtobufEncoder creates an encoder of the rows of cfg.
+func newProtobufEncoder(cfg *config.Config) protobufEncoder {
+	return protobufEncoder{config: cfg, field: make([]byte, 0, 128)}
+}
+
+// NewProtobufWriter creates a new protobuf writer.
+func NewProtobufWriter(w io.Writer, cfg *config.Config) *ProtobufWriter {
+	return &ProtobufWriter{
+		out:    w,
+		config: cfg,
+		rangeCapable: !slices.ContainsFunc(cfg.Network.Columns, func(col config.NetworkColumn) bool {
+			return col.Type == NetworkColumnCIDR
+		}),
+		encoder: newProtobufEncoder(cfg),
+		buf:     make([]byte, 0, csvFlushSize+4096),
+	}
+}
+
+// ProtobufSchema returns the proto3 schema of the messages the protobuf
+// output of cfg is made of. Network columns are strings, except integer
+// columns, which are the bytes of the address in network order: 4 for IPv4
+// and 16 for IPv6. Data columns are optional strings unless a type hint is
+// set, as for Parquet, so that missing values can be told from empty ones.
+func ProtobufSchema(cfg *config.Config) string {
+	var b strings.Builder
+	b.WriteString("// Generated by mmdbconvert from its configuration. Do not edit.\n\n")
+	b.WriteString("syntax = \"proto3\";\n\n")
+	if cfg.Output.Protobuf.Package != "" {
+		fmt.Fprintf(&b, "package %s;\n\n", cfg.Output.Protobuf.Package)
+	}
+	fmt.Fprintf(&b, "message %s {\n", cfg.Output.Protobuf.Message)
+	number := 1
+	for _, col := range cfg.Network.Columns {
+		fmt.Fprintf(&b, "  %s %s = %d;\n", protobufNetworkType(col.Type), col.OutputName("protobuf"), number)
+		number++
+	}
+	for _, col := range cfg.Columns {
+		fmt.Fprintf(&b, "  optional %s %s = %d;\n", protobufDataType(col.Type), col.OutputName("protobuf"), number)
+		number++
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// protobufNetworkType returns the protobuf type of a network column.
+func protobufNetworkType(colType string) string {
+	switch colType {
+	case NetworkColumnStartInt, NetworkColumnEndInt:
+		return "bytes"
+	case NetworkColumnValidFrom, NetworkColumnValidTo:
+		return "optional string"
+	default:
+		return "string"
+	}
+}
+
+// protobufDataType returns the protobuf type of a data column with a type
+// hint.
+func protobufDataType(typeHint string) string {
+	switch typeHint {
+	case "int64":
+		return "int64"
+	case "float64":
+		return "double"
+	case "bool":
+		return "bool"
+	case "binary":
+		return "bytes"
+	default:
+		return "string"
+	}
+}
+
+// WriteRow writes a single row with network prefix and column data.
+func (w *ProtobufWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeMessage(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *ProtobufWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, writing a single message unless a
+// network column is a CIDR.
+func (w *ProtobufWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		for _, cidr := range network.RangeToPrefixes(start, end) {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.writeMessage(netip.Prefix{}, start, end, r)
+}
+
+// writeMessage serializes a row and appends it to the buffer with its
+// length. prefix is invalid for range rows.
+func (w *ProtobufWriter) writeMessage(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	msg, err := w.encoder.encode(prefix, start, end, r)
+	if err != nil {
+		return err
+	}
+	w.buf = protowire.AppendBytes(w.buf, msg)
+	if len(w.buf) >= csvFlushSize {
+		return w.flushBuffer()
+	}
+	return nil
+}
+
+// encode serializes a row, returning the message, which is only valid
+// until the next call. prefix is invalid for range rows.
+func (w *protobufEncoder) encode(prefix netip.Prefix, start, end netip.Addr, r row.Row) ([]byte, error) {
+	w.msg = w.msg[:0]
+	number := protowire.Number(1)
+
+	for _, netCol := range w.config.Network.Columns {
+		if err := w.appendNetworkValue(number, prefix, start, end, r, netCol.Type); err != nil {
+			return nil, fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
+		}
+		number++
+	}
+
+	for i, col := range w.config.Columns {
+		value, err := convertToParquetType(r, i, col.Type)
+		if err != nil {
+			return nil, fmt.Errorf("converting column '%s': %w", col.Name, err)
+		}
+		w.appendDataValue(number, value)
+		number++
+	}
+	return w.msg, nil
+}
+
+// appendNetworkValue appends the value of a network column as field
+// number.
+func (w *protobufEncoder) appendNetworkValue(
+	number protowire.Number,
+	prefix netip.Prefix,
+	start, end netip.Addr,
+	r row.Row,
+	colType string,
+) error {
+	switch colType {
+	case NetworkColumnStartInt:
+		w.appendBytes(number, start.AsSlice())
+	case NetworkColumnEndInt:
+		w.appendBytes(number, end.AsSlice())
+	case NetworkColumnValidFrom:
+		if from := r.ValidFrom(len(w.config.Columns)); from != "" {
+			w.appendBytes(number, []byte(from))
+		}
+	case NetworkColumnValidTo:
+		if to := r.ValidTo(len(w.config.Columns)); to != "" {
+			w.appendBytes(number, []byte(to))
+		}
+	default:
+		var err error
+		w.field, err = appendNetworkValue(w.field[:0], prefix, start, end, colType)
+		if err != nil {
+			return err
+		}
+		w.appendBytes(number, w.field)
+	}
+	return nil
+}
+
+// appendDataValue appends the value of a data column, converted by its type
+// hint, as field number. Null values are left out, which optional fields
+// read as unset.
+func (w *protobufEncoder) appendDataValue(number protowire.Number, value any) {
+	switch v := value.(type) {
+	case string:
+		w.appendBytes(number, []byte(v))
+	case []byte:
+		w.appendBytes(number, v)
+	case int64:
+		w.msg = protowire.AppendTag(w.msg, number, protowire.VarintType)
+		w.msg = protowire.AppendVarint(w.msg, uint64(v))
+	case float64:
+		w.msg = protowire.AppendTag(w.msg, number, protowire.Fixed64Type)
+		w.msg = protowire.AppendFixed64(w.msg, math.Float64bits(v))
+	case bool:
+		w.msg = protowire.AppendTag(w.msg, number, protowire.VarintType)
+		w.msg = protowire.AppendVarint(w.msg, protowire.EncodeBool(v))
+	}
+}
+
+// appendBytes appends a string or bytes field.
+func (w *protobufEncoder) appendBytes(number protowire.Number, b []byte) {
+	w.msg = protowire.AppendTag(w.msg, number, protowire.BytesType)
+	w.msg = protowire.AppendBytes(w.msg, b)
+}
+
+// flushBuffer writes all buffered messages to the underlying writer.
+func (w *ProtobufWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("writing protobuf messages: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush writes all buffered messages.
+func (w *ProtobufWriter) Flush() error {
+	return w.flushBuffer()
+}
+
+// Sync writes all buffered messages and commits them to stable storage if
+// the underlying writer supports it, as *os.File does.
+func (w *ProtobufWriter) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return syncOutput(w.out)
+}
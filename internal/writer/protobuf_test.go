@@ -0,0 +1,159 @@
+package writer
+
+import (
+	"bytes"
+	"math"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func protobufConfig(network ...config.NetworkColumn) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{
+			Protobuf: config.ProtobufConfig{Package: "geo.v1", Message: "Network"},
+		},
+		Network: config.NetworkConfig{Columns: network},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "accuracy", Type: "int64"},
+			{Name: "latitude", Type: "float64"},
+			{Name: "is_eu", Type: "bool", Aliases: config.Aliases{StorageName: "in_eu"}},
+		},
+	}
+}
+
+// readProtobufMessages splits delimited messages into their fields, by
+// number, with varint and fixed64 values as uint64 and others as bytes.
+func readProtobufMessages(t *testing.T, data []byte) []map[protowire.Number]any {
+	t.Helper()
+	var messages []map[protowire.Number]any
+	for len(data) > 0 {
+		msg, n := protowire.ConsumeBytes(data)
+		require.GreaterOrEqual(t, n, 0)
+		data = data[n:]
+
+		fields := map[protowire.Number]any{}
+		for len(msg) > 0 {
+			number, typ, n := protowire.ConsumeTag(msg)
+			require.GreaterOrEqual(t, n, 0)
+			msg = msg[n:]
+			switch typ {
+			case protowire.VarintType:
+				v, n := protowire.ConsumeVarint(msg)
+				fields[number], msg = v, msg[n:]
+			case protowire.Fixed64Type:
+				v, n := protowire.ConsumeFixed64(msg)
+				fields[number], msg = v, msg[n:]
+			case protowire.BytesType:
+				v, n := protowire.ConsumeBytes(msg)
+				fields[number], msg = string(v), msg[n:]
+			default:
+				t.Fatalf("unexpected wire type %d", typ)
+			}
+		}
+		messages = append(messages, fields)
+	}
+	return messages
+}
+
+func TestProtobufSchema(t *testing.T) {
+	cfg := protobufConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+		config.NetworkColumn{Name: "valid_from", Type: NetworkColumnValidFrom},
+	)
+	assert.Equal(t, "// Generated by mmdbconvert from its configuration. Do not edit.\n\n"+
+		"syntax = \"proto3\";\n\n"+
+		"package geo.v1;\n\n"+
+		"message Network {\n"+
+		"  string network = 1;\n"+
+		"  bytes start_int = 2;\n"+
+		"  optional string valid_from = 3;\n"+
+		"  optional string country = 4;\n"+
+		"  optional int64 accuracy = 5;\n"+
+		"  optional double latitude = 6;\n"+
+		"  optional bool in_eu = 7;\n"+
+		"}\n", ProtobufSchema(cfg))
+}
+
+func TestProtobufWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewProtobufWriter(&buf, protobufConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+	))
+	assert.False(t, w.WritesRanges())
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("DE"),
+		mmdbtype.Uint16(20),
+		mmdbtype.Float64(51.5),
+		mmdbtype.Bool(false),
+	}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{
+		nil,
+		mmdbtype.Int32(-1),
+		nil,
+		nil,
+	}))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, []map[protowire.Number]any{
+		{
+			1: "1.0.0.0/24",
+			2: "\x01\x00\x00\x00",
+			3: "DE",
+			4: uint64(20),
+			5: math.Float64bits(51.5),
+			// A false value is still set
+			6: uint64(0),
+		},
+		{
+			1: "2001:db8::/32",
+			2: string(netip.MustParseAddr("2001:db8::").AsSlice()),
+			4: uint64(math.MaxUint64),
+		},
+	}, readProtobufMessages(t, buf.Bytes()))
+}
+
+func TestProtobufWriter_Ranges(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewProtobufWriter(&buf, protobufConfig(
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "end_ip", Type: NetworkColumnEndIP},
+	))
+	require.True(t, w.WritesRanges())
+
+	r := row.Row{mmdbtype.String("DE"), nil, nil, nil}
+	require.NoError(t, w.WriteRange(netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.9"), r))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []map[protowire.Number]any{
+		{1: "1.0.0.0", 2: "1.0.0.9", 3: "DE"},
+	}, readProtobufMessages(t, buf.Bytes()))
+}
+
+func TestProtobufWriter_InvalidValue(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewProtobufWriter(&buf, protobufConfig(
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+	))
+	err := w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("DE"),
+		mmdbtype.String("twenty"),
+		nil,
+		nil,
+	})
+	require.ErrorContains(t, err, "converting column 'accuracy'")
+
+	// The failed row leaves nothing behind
+	require.NoError(t, w.Flush())
+	assert.Empty(t, buf.Bytes())
+}
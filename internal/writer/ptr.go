@@ -0,0 +1,261 @@
+package writer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// ptrFlushSize is the number of buffered bytes that triggers a write to the
+// underlying writer.
+const ptrFlushSize = 64 * 1024
+
+// PTRWriter writes reverse DNS zone fragments: PTR records in the
+// in-addr.arpa and ip6.arpa trees, named by a template filled from the data
+// columns.
+//
+// Reverse names only exist at octet (IPv4) or nibble (IPv6) boundaries, so a
+// network is written as a wildcard record covering it, after splitting it
+// into networks on the next boundary. Networks that split into single
+// addresses get one record per address.
+type PTRWriter struct {
+	out           io.Writer
+	config        *config.Config
+	ttl           string
+	parts         []ptrPart
+	headerWritten bool
+	buf           []byte // Serialized records not yet written to out
+	name          []byte // Scratch space for the record's target name
+	field         []byte // Scratch space for a single column value
+}
+
+// ptrPart is a literal piece of the name template, or a placeholder for a
+// data column.
+type ptrPart struct {
+	literal string
+	column  int // Index into config.Columns, or -1 for a literal
+}
+
+// NewPTRWriter creates a new PTR zone writer. It returns an error if the
+// name template is malformed or refers to an unknown column.
+func NewPTRWriter(w io.Writer, cfg *config.Config) (*PTRWriter, error) {
+	parts, err := parsePTRTemplate(cfg.Output.PTR.Template, cfg.Columns)
+	if err != nil {
+		return nil, fmt.Errorf("parsing output.ptr.template: %w", err)
+	}
+	return &PTRWriter{
+		out:    w,
+		config: cfg,
+		ttl:    strconv.Itoa(cfg.Output.PTR.TTL),
+		parts:  parts,
+		buf:    make([]byte, 0, ptrFlushSize+4096),
+	}, nil
+}
+
+// parsePTRTemplate splits tmpl into literals and {column} placeholders.
+func parsePTRTemplate(tmpl string, columns []config.Column) ([]ptrPart, error) {
+	indexes := make(map[string]int, len(columns))
+	for i, col := range columns {
+		indexes[string(col.Name)] = i
+	}
+
+	var parts []ptrPart
+	rest := tmpl
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			parts = append(parts, ptrPart{literal: rest, column: -1})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, errors.New("unexpected '}'")
+		}
+		if open > 0 {
+			parts = append(parts, ptrPart{literal: rest[:open], column: -1})
+		}
+		closing := strings.IndexByte(rest[open:], '}')
+		if closing < 0 {
+			return nil, errors.New("unterminated '{'")
+		}
+		name := rest[open+1 : open+closing]
+		i, ok := indexes[name]
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a configured data column", name)
+		}
+		parts = append(parts, ptrPart{column: i})
+		rest = rest[open+closing+1:]
+	}
+	return parts, nil
+}
+
+// WriteRow writes the PTR records of a network. Networks where a column used
+// by the template has no value are skipped, as they have no name.
+func (w *PTRWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	w.ensureHeader()
+
+	ok, err := w.appendName(r)
+	if err != nil || !ok {
+		return err
+	}
+
+	bits := 8
+	if !prefix.Addr().Is4() {
+		bits = 4
+	}
+	aligned := (prefix.Bits() + bits - 1) / bits * bits
+	maxBits := prefix.Addr().BitLen()
+	prefix = prefix.Masked()
+
+	// Walk the networks of length aligned within prefix
+	addr := prefix.Addr()
+	for range 1 << (aligned - prefix.Bits()) {
+		w.buf = appendReverseName(w.buf, addr, aligned, bits, aligned < maxBits)
+		w.buf = append(w.buf, '\t')
+		w.buf = append(w.buf, w.ttl...)
+		w.buf = append(w.buf, "\tIN\tPTR\t"...)
+		w.buf = append(w.buf, w.name...)
+		w.buf = append(w.buf, '\n')
+
+		network, err := addr.Prefix(aligned)
+		if err != nil {
+			return fmt.Errorf("splitting %s: %w", prefix, err)
+		}
+		addr = netipx.PrefixLastIP(network).Next()
+	}
+
+	if len(w.buf) >= ptrFlushSize {
+		return w.flushBuffer()
+	}
+	return nil
+}
+
+// appendName fills w.name from the template. It reports false if a column
+// used by the template has no value.
+func (w *PTRWriter) appendName(r row.Row) (bool, error) {
+	w.name = w.name[:0]
+	for _, part := range w.parts {
+		if part.column < 0 {
+			w.name = append(w.name, part.literal...)
+			continue
+		}
+		if r.IsNull(part.column) {
+			return false, nil
+		}
+		var err error
+		w.field, err = r.AppendText(w.field[:0], part.column)
+		if err != nil {
+			return false, fmt.Errorf(
+				"converting column '%s' to string: %w",
+				w.config.Columns[part.column].Name,
+				err,
+			)
+		}
+		start := len(w.name)
+		w.name = appendLabel(w.name, w.field)
+		if len(w.name) == start {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// appendLabel appends value as DNS label characters: letters are lowercased,
+// characters other than letters, digits, and hyphens become hyphens, and
+// leading and trailing hyphens are dropped.
+func appendLabel(dst, value []byte) []byte {
+	start := len(dst)
+	for _, c := range value {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			dst = append(dst, c+'a'-'A')
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			dst = append(dst, c)
+		default:
+			dst = append(dst, '-')
+		}
+	}
+	label := bytes.Trim(dst[start:], "-")
+	return dst[:start+copy(dst[start:], label)]
+}
+
+// appendReverseName appends the reverse DNS name of the first bits bits of
+// addr, in labels of labelBits bits, preceded by a wildcard label if
+// wildcard is set.
+func appendReverseName(dst []byte, addr netip.Addr, bits, labelBits int, wildcard bool) []byte {
+	if wildcard {
+		dst = append(dst, "*."...)
+	}
+	if addr.Is4() {
+		b := addr.As4()
+		for i := bits/labelBits - 1; i >= 0; i-- {
+			dst = strconv.AppendUint(dst, uint64(b[i]), 10)
+			dst = append(dst, '.')
+		}
+		return append(dst, "in-addr.arpa."...)
+	}
+
+	const hex = "0123456789abcdef"
+	b := addr.As16()
+	for i := bits/labelBits - 1; i >= 0; i-- {
+		nibble := b[i/2] >> 4
+		if i%2 == 1 {
+			nibble = b[i/2] & 0x0f
+		}
+		dst = append(dst, hex[nibble], '.')
+	}
+	return append(dst, "ip6.arpa."...)
+}
+
+// ensureHeader writes the provenance as zone file comments before the first
+// record.
+func (w *PTRWriter) ensureHeader() {
+	if w.headerWritten {
+		return
+	}
+	w.headerWritten = true
+	for _, pair := range w.config.Provenance.Pairs() {
+		w.buf = append(w.buf, "; "...)
+		w.buf = append(w.buf, pair.Key...)
+		w.buf = append(w.buf, '=')
+		w.buf = append(w.buf, pair.Value...)
+		w.buf = append(w.buf, '\n')
+	}
+}
+
+// flushBuffer writes all buffered records to the underlying writer.
+func (w *PTRWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("writing PTR records: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush ensures all buffered data is written.
+func (w *PTRWriter) Flush() error {
+	if err := w.flushBuffer(); err != nil {
+		return fmt.Errorf("PTR flush error: %w", err)
+	}
+	return nil
+}
+
+// Sync writes all buffered records and commits them to stable storage if the
+// underlying writer supports it, as *os.File does.
+func (w *PTRWriter) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return syncOutput(w.out)
+}
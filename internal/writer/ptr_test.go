@@ -0,0 +1,130 @@
+package writer
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func newPTRConfig(template string) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{
+			Format: "ptr",
+			PTR:    config.PTRConfig{Template: template, TTL: 3600},
+		},
+		Columns: []config.Column{{Name: "country"}, {Name: "asn"}},
+	}
+}
+
+func TestPTRWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		row      row.Row
+		expected []string
+	}{
+		{
+			name:     "octet boundary",
+			prefix:   "192.0.2.0/24",
+			row:      row.Row{mmdbtype.String("AU"), mmdbtype.Uint32(13335)},
+			expected: []string{"*.2.0.192.in-addr.arpa.\t3600\tIN\tPTR\tau.as13335.geo.example."},
+		},
+		{
+			name:   "split to the next octet",
+			prefix: "10.0.0.0/15",
+			row:    row.Row{mmdbtype.String("AU"), mmdbtype.Uint32(13335)},
+			expected: []string{
+				"*.0.10.in-addr.arpa.\t3600\tIN\tPTR\tau.as13335.geo.example.",
+				"*.1.10.in-addr.arpa.\t3600\tIN\tPTR\tau.as13335.geo.example.",
+			},
+		},
+		{
+			name:   "single addresses",
+			prefix: "192.0.2.6/31",
+			row:    row.Row{mmdbtype.String("AU"), mmdbtype.Uint32(13335)},
+			expected: []string{
+				"6.2.0.192.in-addr.arpa.\t3600\tIN\tPTR\tau.as13335.geo.example.",
+				"7.2.0.192.in-addr.arpa.\t3600\tIN\tPTR\tau.as13335.geo.example.",
+			},
+		},
+		{
+			name:   "IPv6 nibbles",
+			prefix: "2001:db8::/31",
+			row:    row.Row{mmdbtype.String("DE"), mmdbtype.Uint32(3320)},
+			expected: []string{
+				"*.8.b.d.0.1.0.0.2.ip6.arpa.\t3600\tIN\tPTR\tde.as3320.geo.example.",
+				"*.9.b.d.0.1.0.0.2.ip6.arpa.\t3600\tIN\tPTR\tde.as3320.geo.example.",
+			},
+		},
+		{
+			name:   "IPv6 address",
+			prefix: "2001:db8::1/128",
+			row:    row.Row{mmdbtype.String("DE"), mmdbtype.Uint32(3320)},
+			expected: []string{
+				"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.\t3600\tIN\tPTR\tde.as3320.geo.example.",
+			},
+		},
+		{
+			name:     "values made into labels",
+			prefix:   "192.0.2.0/24",
+			row:      row.Row{mmdbtype.String(" United Kingdom."), mmdbtype.Uint32(1)},
+			expected: []string{"*.2.0.192.in-addr.arpa.\t3600\tIN\tPTR\tunited-kingdom.as1.geo.example."},
+		},
+		{
+			name:   "missing value",
+			prefix: "192.0.2.0/24",
+			row:    row.Row{mmdbtype.String("AU"), nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewPTRWriter(&buf, newPTRConfig("{country}.as{asn}.geo.example."))
+			require.NoError(t, err)
+			require.NoError(t, w.WriteRow(netip.MustParsePrefix(tt.prefix), tt.row))
+			require.NoError(t, w.Flush())
+
+			var expected string
+			if tt.expected != nil {
+				expected = strings.Join(tt.expected, "\n") + "\n"
+			}
+			assert.Equal(t, expected, buf.String())
+		})
+	}
+}
+
+func TestPTRWriter_Provenance(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := newPTRConfig("{country}.geo.example.")
+	cfg.Provenance = testProvenance
+	w, err := NewPTRWriter(&buf, cfg)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("192.0.2.0/24"), row.Row{mmdbtype.String("AU")}))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(buf.String(), "\n")
+	require.Len(t, lines, len(testProvenance.Pairs())+2)
+	assert.Equal(t, "; mmdbconvert.version=1.2.3", lines[0])
+}
+
+func TestNewPTRWriter_Errors(t *testing.T) {
+	tests := map[string]string{
+		"{city}.geo.example.":  "'city' is not a configured data column",
+		"{country.geo.example": "unterminated '{'",
+		"country}.geo.example": "unexpected '}'",
+	}
+	for template, expected := range tests {
+		_, err := NewPTRWriter(&bytes.Buffer{}, newPTRConfig(template))
+		require.Error(t, err, template)
+		assert.Contains(t, err.Error(), expected)
+	}
+}
@@ -0,0 +1,134 @@
+package writer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// redactor redacts column i of a row in place.
+type redactor func(r row.Row, i int) error
+
+// RedactWriter wraps a row writer and applies the redact policies of the
+// data columns to every row before passing it on.
+type RedactWriter struct {
+	writer    row.Writer
+	redactors []redactor // Per column, nil if not redacted
+	buf       row.Row
+}
+
+// NewRedactWriter creates a writer redacting the columns of cfg that have a
+// redact policy. Salts of hashed columns are read from the environment
+// variables named by salt_env here.
+func NewRedactWriter(writer row.Writer, cfg *config.Config) (*RedactWriter, error) {
+	redactors := make([]redactor, len(cfg.Columns))
+	for i, col := range cfg.Columns {
+		if col.Redact == nil {
+			continue
+		}
+		switch col.Redact.Policy {
+		case config.RedactDrop:
+			redactors[i] = func(r row.Row, i int) error {
+				r.SetNull(i)
+				return nil
+			}
+		case config.RedactHash:
+			salt := col.Redact.Salt
+			if col.Redact.SaltEnv != "" {
+				salt = os.Getenv(col.Redact.SaltEnv)
+				if salt == "" {
+					return nil, fmt.Errorf(
+						"environment variable %s with the salt of column '%s' is not set",
+						col.Redact.SaltEnv,
+						col.Name,
+					)
+				}
+			}
+			redactors[i] = hashRedactor([]byte(salt))
+		case config.RedactTruncate:
+			scale := math.Pow10(*col.Redact.Decimals)
+			redactors[i] = func(r row.Row, i int) error {
+				r.MapFloats(i, func(f float64) float64 {
+					return math.Trunc(f*scale) / scale
+				})
+				return nil
+			}
+		default:
+			return nil, fmt.Errorf(
+				"unknown redact policy '%s' for column '%s'",
+				col.Redact.Policy,
+				col.Name,
+			)
+		}
+	}
+	return &RedactWriter{
+		writer:    writer,
+		redactors: redactors,
+		buf:       make(row.Row, len(cfg.Columns)),
+	}, nil
+}
+
+// WriteRow writes a single redacted row.
+func (w *RedactWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	redacted, err := w.redact(r)
+	if err != nil {
+		return fmt.Errorf("redacting %s: %w", prefix, err)
+	}
+	return w.writer.WriteRow(prefix, redacted)
+}
+
+// WriteRange writes a redacted range.
+func (w *RedactWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	redacted, err := w.redact(r)
+	if err != nil {
+		return fmt.Errorf("redacting %s-%s: %w", start, end, err)
+	}
+	return row.WriteRange(w.writer, start, end, redacted)
+}
+
+// Flush flushes the wrapped writer.
+func (w *RedactWriter) Flush() error {
+	return row.Flush(w.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (w *RedactWriter) Sync() error {
+	return row.Sync(w.writer)
+}
+
+// redact returns a copy of r with the redacted values. The caller's row is
+// left unchanged, since it may still be compared against later rows.
+func (w *RedactWriter) redact(r row.Row) (row.Row, error) {
+	copy(w.buf, r)
+	for i, redact := range w.redactors {
+		if redact == nil || w.buf.IsNull(i) {
+			continue
+		}
+		if err := redact(w.buf, i); err != nil {
+			return nil, err
+		}
+	}
+	return w.buf, nil
+}
+
+// hashRedactor returns a redactor replacing values with the hex HMAC-SHA256
+// of their textual representation, keyed with salt.
+func hashRedactor(salt []byte) redactor {
+	return func(r row.Row, i int) error {
+		text, err := r.Text(i)
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(sha256.New, salt)
+		mac.Write([]byte(text))
+		r.SetString(i, hex.EncodeToString(mac.Sum(nil)))
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestRedactWriter(t *testing.T) {
+	decimals := 1
+	t.Setenv("TEST_REDACT_SALT", "pepper")
+	cfg := &config.Config{
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "postal_code", Redact: &config.RedactConfig{Policy: config.RedactDrop}},
+			{Name: "user_id", Redact: &config.RedactConfig{Policy: config.RedactHash, Salt: "pepper"}},
+			{
+				Name:   "user_id_env",
+				Redact: &config.RedactConfig{Policy: config.RedactHash, SaltEnv: "TEST_REDACT_SALT"},
+			},
+			{
+				Name:   "location",
+				Redact: &config.RedactConfig{Policy: config.RedactTruncate, Decimals: &decimals},
+			},
+		},
+	}
+	inner := &rangeRecordWriter{}
+	w, err := NewRedactWriter(inner, cfg)
+	require.NoError(t, err)
+
+	r := row.Row{
+		mmdbtype.String("GB"),
+		mmdbtype.String("EC1A"),
+		mmdbtype.Uint32(42),
+		mmdbtype.Uint32(42),
+		mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(51.5142),
+			"longitude": mmdbtype.Float64(-0.1275),
+			"radius":    mmdbtype.Uint16(20),
+		},
+	}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("81.2.69.0/24"), r))
+
+	// HMAC-SHA256 of "42" keyed with "pepper"
+	const hash = "05072a49e7c724c2ac3638d3a54eb8848d39fb952094ee45037176152e15d416"
+	require.Len(t, inner.data, 1)
+	assert.Equal(t, []mmdbtype.DataType{
+		mmdbtype.String("GB"),
+		nil,
+		mmdbtype.String(hash),
+		mmdbtype.String(hash),
+		mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(51.5),
+			"longitude": mmdbtype.Float64(-0.1),
+			"radius":    mmdbtype.Uint16(20),
+		},
+	}, inner.data[0])
+	assert.Equal(t, mmdbtype.String("EC1A"), r[1], "the input row is not modified")
+
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("81.2.70.0"),
+		netip.MustParseAddr("81.2.71.255"),
+		row.Row{mmdbtype.String("GB"), nil, nil, nil, nil},
+	))
+	assert.Equal(t, [][]mmdbtype.DataType{{mmdbtype.String("GB"), nil, nil, nil, nil}}, inner.rangeData)
+
+	t.Run("missing salt variable", func(t *testing.T) {
+		cfg := &config.Config{Columns: []config.Column{{
+			Name:   "user_id",
+			Redact: &config.RedactConfig{Policy: config.RedactHash, SaltEnv: "TEST_REDACT_UNSET"},
+		}}}
+		_, err := NewRedactWriter(inner, cfg)
+		require.EqualError(
+			t,
+			err,
+			"environment variable TEST_REDACT_UNSET with the salt of column 'user_id' is not set",
+		)
+	})
+}
@@ -0,0 +1,197 @@
+package writer
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// These match the networks that mmdbwriter excludes from MMDB output unless
+// reserved networks are included. The IPv4-mapped and 6to4 ranges are left
+// out of the IPv6 list because IPv4 data is written with IPv4 addresses.
+var (
+	reservedNetworksIPv4 = []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/29",
+		"192.0.2.0/24",
+		"192.88.99.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+	}
+	reservedNetworksIPv6 = []string{
+		"100::/64",
+		"2001:1::/32",
+		"2001:2::/31",
+		"2001:4::/30",
+		"2001:8::/29",
+		"2001:10::/28",
+		"2001:20::/27",
+		"2001:40::/26",
+		"2001:80::/25",
+		"2001:100::/24",
+		"2001:db8::/32",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	}
+)
+
+// ReservedRanges returns the reserved and private address ranges in
+// ascending order, including IPv6 ranges only if ipv6 is true.
+func ReservedRanges(ipv6 bool) []netipx.IPRange {
+	var b netipx.IPSetBuilder
+	networks := reservedNetworksIPv4
+	if ipv6 {
+		networks = append(networks[:len(networks):len(networks)], reservedNetworksIPv6...)
+	}
+	for _, network := range networks {
+		b.AddPrefix(netip.MustParsePrefix(network))
+	}
+	set, _ := b.IPSet()
+	return set.Ranges()
+}
+
+// ReservedRowData builds the column data written for reserved networks from
+// output.reserved_networks.values. Columns without a value are null.
+func ReservedRowData(cfg *config.Config) (row.Row, error) {
+	data := make(row.Row, len(cfg.Columns))
+	for i, col := range cfg.Columns {
+		value, ok := cfg.Output.ReservedNetworks.Values[string(col.Name)]
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			data.SetString(i, v)
+		case bool:
+			data.SetBool(i, v)
+		case float64:
+			data.SetFloat64(i, v)
+		case int64:
+			switch {
+			case v >= 0:
+				data.SetUint64(i, uint64(v))
+			case v >= math.MinInt32:
+				data.SetInt32(i, int32(v))
+			default:
+				return nil, fmt.Errorf(
+					"reserved network value %d for column '%s' is out of range",
+					v,
+					col.Name,
+				)
+			}
+		default:
+			return nil, fmt.Errorf(
+				"unsupported reserved network value type %T for column '%s'",
+				value,
+				col.Name,
+			)
+		}
+	}
+	return data, nil
+}
+
+// ReservedNetworkWriter wraps a row writer and emits rows for reserved
+// networks with constant data. Rows must arrive in ascending address order,
+// as produced by the merger. Source data inside reserved networks is
+// replaced, mirroring how MMDB output excludes those networks.
+type ReservedNetworkWriter struct {
+	writer   row.Writer
+	reserved []netipx.IPRange
+	data     row.Row
+
+	next    int  // Index of the first reserved range not yet passed
+	written bool // Whether reserved[next] has been written
+}
+
+// NewReservedNetworkWriter creates a writer that injects rows with data for
+// each range in reserved, which must be sorted and non-overlapping.
+func NewReservedNetworkWriter(
+	writer row.Writer,
+	reserved []netipx.IPRange,
+	data row.Row,
+) *ReservedNetworkWriter {
+	return &ReservedNetworkWriter{
+		writer:   writer,
+		reserved: reserved,
+		data:     data,
+	}
+}
+
+// WriteRow writes a single prefix.
+func (r *ReservedNetworkWriter) WriteRow(prefix netip.Prefix, data row.Row) error {
+	return r.WriteRange(prefix.Addr(), netipx.PrefixLastIP(prefix), data)
+}
+
+// WriteRange writes the non-reserved parts of the range, first emitting any
+// reserved ranges that start at or before its end.
+func (r *ReservedNetworkWriter) WriteRange(
+	start, end netip.Addr,
+	data row.Row,
+) error {
+	for r.next < len(r.reserved) && r.reserved[r.next].From().Compare(end) <= 0 {
+		res := r.reserved[r.next]
+		if start.Less(res.From()) {
+			if err := r.write(start, res.From().Prev(), data); err != nil {
+				return err
+			}
+		}
+		if err := r.writeReserved(); err != nil {
+			return err
+		}
+		if res.To().Compare(end) >= 0 {
+			// The rest of the range is reserved
+			return nil
+		}
+		if res.To().Compare(start) >= 0 {
+			start = res.To().Next()
+		}
+		r.next++
+		r.written = false
+	}
+	return r.write(start, end, data)
+}
+
+// Flush emits the remaining reserved ranges and flushes the wrapped writer.
+func (r *ReservedNetworkWriter) Flush() error {
+	for ; r.next < len(r.reserved); r.next++ {
+		if err := r.writeReserved(); err != nil {
+			return err
+		}
+		r.written = false
+	}
+	return row.Flush(r.writer)
+}
+
+// Sync syncs the wrapped writer. Reserved ranges after the last written row
+// are not emitted until Flush.
+func (r *ReservedNetworkWriter) Sync() error {
+	return row.Sync(r.writer)
+}
+
+func (r *ReservedNetworkWriter) writeReserved() error {
+	if r.written {
+		return nil
+	}
+	r.written = true
+	res := r.reserved[r.next]
+	return r.write(res.From(), res.To(), r.data)
+}
+
+func (r *ReservedNetworkWriter) write(start, end netip.Addr, data row.Row) error {
+	return row.WriteRange(r.writer, start, end, data)
+}
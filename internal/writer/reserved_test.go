@@ -0,0 +1,162 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestReservedNetworkWriter(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	reserved := []netipx.IPRange{
+		netipx.MustParseIPRange("10.0.0.0-10.255.255.255"),
+		netipx.MustParseIPRange("20.0.0.0-20.0.0.255"),
+		netipx.MustParseIPRange("30.0.0.0-30.0.0.255"),
+		netipx.MustParseIPRange("fc00::-fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+	}
+	zz := []mmdbtype.DataType{mmdbtype.String("ZZ")}
+	w := NewReservedNetworkWriter(inner, reserved, zz)
+
+	us := []mmdbtype.DataType{mmdbtype.String("US")}
+	de := []mmdbtype.DataType{mmdbtype.String("DE")}
+
+	// Before any reserved range
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), us))
+	// Overlaps the start of 10.0.0.0/8 and is otherwise inside it
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("9.255.255.0"),
+		netip.MustParseAddr("10.0.0.255"),
+		us,
+	))
+	// Inside a reserved range that was already written
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("10.1.0.0/16"), us))
+	// Spans 20.0.0.0/24, after skipping past 10.0.0.0/8
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("19.0.0.0"),
+		netip.MustParseAddr("21.0.0.0"),
+		de,
+	))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2a02::/16"), de))
+	require.NoError(t, w.Flush())
+
+	addr := netip.MustParseAddr
+	assert.Equal(t, [][2]netip.Addr{
+		{addr("1.0.0.0"), addr("1.0.0.255")},
+		{addr("9.255.255.0"), addr("9.255.255.255")},
+		{addr("10.0.0.0"), addr("10.255.255.255")},
+		{addr("19.0.0.0"), addr("19.255.255.255")},
+		{addr("20.0.0.0"), addr("20.0.0.255")},
+		{addr("20.0.1.0"), addr("21.0.0.0")},
+		{addr("30.0.0.0"), addr("30.0.0.255")},
+		{addr("2a02::"), addr("2a02:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+		{addr("fc00::"), addr("fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+	}, inner.ranges)
+	assert.Equal(t, []mmdbtype.DataType{
+		us[0], us[0], zz[0], de[0], zz[0], de[0], zz[0], de[0], zz[0],
+	}, firstValues(inner.rangeData))
+}
+
+func TestReservedNetworkWriter_Ordering(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	reserved := []netipx.IPRange{
+		netipx.MustParseIPRange("30.0.0.0-30.0.0.255"),
+		netipx.MustParseIPRange("fc00::-fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+	}
+	zz := []mmdbtype.DataType{mmdbtype.String("ZZ")}
+	w := NewReservedNetworkWriter(inner, reserved, zz)
+
+	de := []mmdbtype.DataType{mmdbtype.String("DE")}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2a02::/16"), de))
+	require.NoError(t, w.Flush())
+
+	// Reserved ranges before the row are written first; the rest on Flush
+	addr := netip.MustParseAddr
+	assert.Equal(t, [][2]netip.Addr{
+		{addr("30.0.0.0"), addr("30.0.0.255")},
+		{addr("2a02::"), addr("2a02:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+		{addr("fc00::"), addr("fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+	}, inner.ranges)
+}
+
+func TestReservedNetworkWriter_PrefixWriter(t *testing.T) {
+	inner := &recordWriter{}
+	reserved := []netipx.IPRange{netipx.MustParseIPRange("10.0.0.0-10.0.0.255")}
+	w := NewReservedNetworkWriter(inner, reserved, []mmdbtype.DataType{nil})
+
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("10.0.0.0/23"),
+		[]mmdbtype.DataType{mmdbtype.String("US")},
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("10.0.1.0/24"),
+	}, inner.rows)
+	assert.Equal(t, []mmdbtype.DataType{nil}, inner.data[0])
+}
+
+func TestReservedRanges(t *testing.T) {
+	ipv4 := ReservedRanges(false)
+	for _, r := range ipv4 {
+		assert.True(t, r.From().Is4(), "unexpected IPv6 range %s", r)
+	}
+	assert.Contains(t, ipv4, netipx.MustParseIPRange("10.0.0.0-10.255.255.255"))
+	// 224.0.0.0/4 and 240.0.0.0/4 are adjacent and combined
+	assert.Equal(t, netipx.MustParseIPRange("224.0.0.0-255.255.255.255"), ipv4[len(ipv4)-1])
+
+	both := ReservedRanges(true)
+	assert.Greater(t, len(both), len(ipv4))
+	assert.Equal(t, netip.MustParseAddr("ff00::"), both[len(both)-1].From())
+}
+
+func TestReservedRowData(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			ReservedNetworks: config.ReservedNetworksConfig{
+				Include: true,
+				Values: map[string]any{
+					"country":  "ZZ",
+					"is_vpn":   false,
+					"asn":      int64(0),
+					"offset":   int64(-5),
+					"accuracy": 1.5,
+				},
+			},
+		},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "city"},
+			{Name: "is_vpn"},
+			{Name: "asn"},
+			{Name: "offset"},
+			{Name: "accuracy"},
+		},
+	}
+
+	data, err := ReservedRowData(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, row.Row{
+		mmdbtype.String("ZZ"),
+		nil,
+		mmdbtype.Bool(false),
+		mmdbtype.Uint64(0),
+		mmdbtype.Int32(-5),
+		mmdbtype.Float64(1.5),
+	}, data)
+}
+
+func firstValues(rows [][]mmdbtype.DataType) []mmdbtype.DataType {
+	values := make([]mmdbtype.DataType, len(rows))
+	for i, r := range rows {
+		values[i] = r[0]
+	}
+	return values
+}
@@ -0,0 +1,217 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// Route is a named destination of a RouterWriter, which receives the rows
+// meeting all of its conditions. A route without conditions receives every
+// row no earlier route takes.
+type Route struct {
+	Name   string
+	Writer row.Writer
+
+	// IPVersion only takes the networks of one IP version, 4 or 6
+	IPVersion int
+	// Prefixes only takes the networks within the set. Ranges crossing the
+	// edge of the set are split there, so each part is routed on its own.
+	Prefixes *netipx.IPSet
+	// Values only takes the rows whose data column of each index has one
+	// of the values, a string, bool, int64, or float64 as decoded from TOML
+	Values map[int][]any
+	// Match only takes the ranges it reports true for, for conditions the
+	// other fields cannot express. Ranges are split at the edges of the
+	// Prefixes of every route first.
+	Match func(start, end netip.Addr, r row.Row) bool
+}
+
+// matches reports whether the range meets the conditions of the route. The
+// range lies within the Prefixes of the route or outside them entirely.
+func (rt *Route) matches(start, end netip.Addr, r row.Row) bool {
+	switch rt.IPVersion {
+	case 4:
+		if !start.Is4() {
+			return false
+		}
+	case 6:
+		if start.Is4() {
+			return false
+		}
+	}
+	if rt.Prefixes != nil && !rt.Prefixes.Contains(start) {
+		return false
+	}
+	for i, values := range rt.Values {
+		if !slices.ContainsFunc(values, func(want any) bool {
+			return valueMatches(r, i, want)
+		}) {
+			return false
+		}
+	}
+	return rt.Match == nil || rt.Match(start, end, r)
+}
+
+// RouterWriter dispatches each row to the first of its routes the row
+// matches, such as to split the output by IP version, by country, or into
+// public and private networks. Rows no route matches are an error.
+type RouterWriter struct {
+	routes []Route
+	// edges are the sorted first addresses after the start or end of a
+	// range of the Prefixes of a route, at which ranges are split
+	edges []netip.Addr
+}
+
+// NewRouterWriter creates a writer dispatching rows to the routes, tried in
+// order. Routes may share a writer, which is flushed once.
+func NewRouterWriter(routes ...Route) *RouterWriter {
+	var edges []netip.Addr
+	for _, rt := range routes {
+		if rt.Prefixes == nil {
+			continue
+		}
+		for _, r := range rt.Prefixes.Ranges() {
+			edges = append(edges, r.From())
+			if next := r.To().Next(); next.IsValid() {
+				edges = append(edges, next)
+			}
+		}
+	}
+	slices.SortFunc(edges, netip.Addr.Compare)
+	return &RouterWriter{routes: routes, edges: slices.Compact(edges)}
+}
+
+// WriteRow writes the row to the writer of its route. A network crossing
+// the edge of the Prefixes of a route is written as a range.
+func (w *RouterWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	rng := netipx.RangeOfPrefix(prefix)
+	if w.nextEdge(rng.From(), rng.To()).IsValid() {
+		return w.WriteRange(rng.From(), rng.To(), r)
+	}
+	rt, err := w.route(rng.From(), rng.To(), r)
+	if err != nil {
+		return err
+	}
+	return rt.Writer.WriteRow(prefix, r)
+}
+
+// WriteRange writes an IP range to the writer of its route, or its parts
+// on either side of the edges of the Prefixes of the routes to theirs. If
+// the writer is not a row.RangeWriter, the range is converted to CIDRs and
+// written with WriteRow.
+//
+// The start and end addresses are guaranteed to be the same IP version by
+// the Accumulator.
+func (w *RouterWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	// Adjacent parts taking the same route are written as one range
+	var pending *Route
+	var pendingStart netip.Addr
+	for {
+		partEnd := end
+		if edge := w.nextEdge(start, end); edge.IsValid() {
+			partEnd = edge.Prev()
+		}
+		rt, err := w.route(start, partEnd, r)
+		if err != nil {
+			return err
+		}
+		if pending != nil && pending != rt {
+			if err := w.write(pending, pendingStart, start.Prev(), r); err != nil {
+				return err
+			}
+			pending = nil
+		}
+		if pending == nil {
+			pending, pendingStart = rt, start
+		}
+		if partEnd == end {
+			return w.write(pending, pendingStart, end, r)
+		}
+		start = partEnd.Next()
+	}
+}
+
+func (w *RouterWriter) write(rt *Route, start, end netip.Addr, r row.Row) error {
+	if err := row.WriteRange(rt.Writer, start, end, r); err != nil {
+		return fmt.Errorf("writing range to %s writer: %w", rt.Name, err)
+	}
+	return nil
+}
+
+// nextEdge returns the first edge after start and no later than end, or
+// the zero Addr if the range crosses none.
+func (w *RouterWriter) nextEdge(start, end netip.Addr) netip.Addr {
+	i, found := slices.BinarySearchFunc(w.edges, start, netip.Addr.Compare)
+	if found {
+		i++
+	}
+	if i < len(w.edges) && w.edges[i].Compare(end) <= 0 {
+		return w.edges[i]
+	}
+	return netip.Addr{}
+}
+
+// route returns the first route the range matches.
+func (w *RouterWriter) route(start, end netip.Addr, r row.Row) (*Route, error) {
+	for i := range w.routes {
+		rt := &w.routes[i]
+		if !rt.matches(start, end, r) {
+			continue
+		}
+		if rt.Writer == nil {
+			return nil, fmt.Errorf("no %s writer configured", rt.Name)
+		}
+		return rt, nil
+	}
+	return nil, fmt.Errorf("no route for %s", netipx.IPRangeFrom(start, end))
+}
+
+// writers returns the writers of the routes, each once.
+func (w *RouterWriter) writers() []*Route {
+	var routes []*Route
+	for i := range w.routes {
+		rt := &w.routes[i]
+		if rt.Writer == nil || slices.ContainsFunc(routes, func(other *Route) bool {
+			return other.Writer == rt.Writer
+		}) {
+			continue
+		}
+		routes = append(routes, rt)
+	}
+	return routes
+}
+
+// Flush flushes the underlying writers when supported. The outputs are
+// independent, so they are flushed concurrently, which lets writers that do
+// their work on Flush, such as MMDBWriter, serialize their files at once.
+func (w *RouterWriter) Flush() error {
+	routes := w.writers()
+	errs := make([]error, len(routes))
+	var wg sync.WaitGroup
+	for i, rt := range routes {
+		wg.Go(func() {
+			if err := row.Flush(rt.Writer); err != nil {
+				errs[i] = fmt.Errorf("flushing %s writer: %w", rt.Name, err)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Sync syncs the underlying writers when supported.
+func (w *RouterWriter) Sync() error {
+	for _, rt := range w.writers() {
+		if err := row.Sync(rt.Writer); err != nil {
+			return fmt.Errorf("syncing %s writer: %w", rt.Name, err)
+		}
+	}
+	return nil
+}
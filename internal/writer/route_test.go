@@ -0,0 +1,123 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestRouterWriter_Values(t *testing.T) {
+	europe := &rangeRecordWriter{}
+	rest := &rangeRecordWriter{}
+	w := NewRouterWriter(
+		Route{Name: "europe", Writer: europe, Values: map[int][]any{0: {"DE", "FR"}}},
+		Route{Name: "rest", Writer: rest},
+	)
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2.0.0.0/16"), row.Row{mmdbtype.String("FR")}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("3.0.0.0/16"), row.Row{mmdbtype.String("US")}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("4.0.0.0/16"), row.Row{nil}))
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("2.0.0.0/16")}, europe.rows)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("3.0.0.0/16"),
+		netip.MustParsePrefix("4.0.0.0/16"),
+	}, rest.rows)
+}
+
+func TestRouterWriter_PrefixesSplitRanges(t *testing.T) {
+	var b netipx.IPSetBuilder
+	b.AddPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	private, err := b.IPSet()
+	require.NoError(t, err)
+
+	internal := &rangeRecordWriter{}
+	public := &rangeRecordWriter{}
+	w := NewRouterWriter(
+		Route{Name: "private", Writer: internal, Prefixes: private},
+		Route{Name: "public", Writer: public},
+	)
+
+	// The range crosses both edges of 10.0.0.0/8
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("9.0.0.0"),
+		netip.MustParseAddr("11.0.0.255"),
+		row.Row{mmdbtype.String("x")},
+	))
+	// The network contains 10.0.0.0/8, so it is written as a range
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("10.0.0.0/7"), row.Row{mmdbtype.String("y")}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("10.1.0.0/16"), row.Row{mmdbtype.String("z")}))
+
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.255.255.255")},
+		{netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.255.255.255")},
+	}, internal.ranges)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}, internal.rows)
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("9.0.0.0"), netip.MustParseAddr("9.255.255.255")},
+		{netip.MustParseAddr("11.0.0.0"), netip.MustParseAddr("11.0.0.255")},
+		{netip.MustParseAddr("11.0.0.0"), netip.MustParseAddr("11.255.255.255")},
+	}, public.ranges)
+}
+
+func TestRouterWriter_SameRouteAcrossEdges(t *testing.T) {
+	var b netipx.IPSetBuilder
+	b.AddPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	private, err := b.IPSet()
+	require.NoError(t, err)
+
+	ipv4 := &rangeRecordWriter{}
+	w := NewRouterWriter(
+		Route{Name: "IPv4", Writer: ipv4, IPVersion: 4},
+		Route{Name: "private", Writer: &rangeRecordWriter{}, Prefixes: private},
+	)
+
+	// The parts split at the edges all take the first route, so the range
+	// is written whole
+	start, end := netip.MustParseAddr("9.0.0.0"), netip.MustParseAddr("11.0.0.255")
+	require.NoError(t, w.WriteRange(start, end, nil))
+	assert.Equal(t, [][2]netip.Addr{{start, end}}, ipv4.ranges)
+}
+
+func TestRouterWriter_Match(t *testing.T) {
+	small := &recordWriter{}
+	w := NewRouterWriter(Route{
+		Name:   "small",
+		Writer: small,
+		Match: func(start, end netip.Addr, _ row.Row) bool {
+			_, ok := netipx.IPRangeFrom(start, end).Prefix()
+			return ok
+		},
+	})
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), nil))
+	err := w.WriteRange(netip.MustParseAddr("1.0.0.1"), netip.MustParseAddr("1.0.0.2"), nil)
+	require.ErrorContains(t, err, "no route for 1.0.0.1-1.0.0.2")
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, small.rows)
+}
+
+func TestRouterWriter_SharedWriterFlushedOnce(t *testing.T) {
+	shared := &countingFlushWriter{}
+	w := NewRouterWriter(
+		Route{Name: "IPv4", Writer: shared, IPVersion: 4},
+		Route{Name: "IPv6", Writer: shared, IPVersion: 6},
+	)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, 1, shared.flushes)
+}
+
+type countingFlushWriter struct {
+	recordWriter
+	flushes int
+}
+
+func (c *countingFlushWriter) Flush() error {
+	c.flushes++
+	return nil
+}
@@ -0,0 +1,71 @@
+package writer
+
+import (
+	"math/rand/v2"
+	"net/netip"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// SampleWriter passes each row on to a writer with a fixed probability, so
+// that the rows it writes are a uniform random sample of those it is given.
+// A range is sampled as a single row.
+type SampleWriter struct {
+	writer  row.Writer
+	rate    float64
+	rand    *rand.Rand
+	rows    int
+	sampled int
+}
+
+// NewSampleWriter creates a writer passing each row on to writer with
+// probability rate, drawing from src.
+func NewSampleWriter(writer row.Writer, rate float64, src rand.Source) *SampleWriter {
+	return &SampleWriter{writer: writer, rate: rate, rand: rand.New(src)}
+}
+
+// WriteRow writes a single row if it is drawn.
+func (s *SampleWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if !s.draw() {
+		return nil
+	}
+	return s.writer.WriteRow(prefix, r)
+}
+
+// WriteRange writes a range if it is drawn.
+func (s *SampleWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !s.draw() {
+		return nil
+	}
+	return row.WriteRange(s.writer, start, end, r)
+}
+
+// draw counts a row and reports whether it is sampled.
+func (s *SampleWriter) draw() bool {
+	s.rows++
+	if s.rand.Float64() >= s.rate {
+		return false
+	}
+	s.sampled++
+	return true
+}
+
+// Rows returns the number of rows given to the writer.
+func (s *SampleWriter) Rows() int {
+	return s.rows
+}
+
+// Sampled returns the number of rows passed on.
+func (s *SampleWriter) Sampled() int {
+	return s.sampled
+}
+
+// Flush flushes the wrapped writer.
+func (s *SampleWriter) Flush() error {
+	return row.Flush(s.writer)
+}
+
+// Sync syncs the wrapped writer.
+func (s *SampleWriter) Sync() error {
+	return row.Sync(s.writer)
+}
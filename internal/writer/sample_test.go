@@ -0,0 +1,44 @@
+package writer
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestSampleWriter(t *testing.T) {
+	inner := &rangeRecordWriter{}
+	w := NewSampleWriter(inner, 0.1, rand.NewPCG(1, 2))
+
+	r := row.Row{mmdbtype.String("DE")}
+	addr := netip.MustParseAddr("1.0.0.0")
+	for range 5000 {
+		require.NoError(t, w.WriteRow(netip.PrefixFrom(addr, 32), r))
+		addr = addr.Next()
+		require.NoError(t, w.WriteRange(addr, addr.Next(), r))
+		addr = addr.Next().Next()
+	}
+
+	assert.Equal(t, 10000, w.Rows())
+	assert.Equal(t, len(inner.rows)+len(inner.ranges), w.Sampled())
+	// Both rows and ranges are sampled at about the rate
+	assert.InDelta(t, 500, len(inner.rows), 75)
+	assert.InDelta(t, 500, len(inner.ranges), 75)
+}
+
+func TestSampleWriter_Rates(t *testing.T) {
+	for rate, want := range map[float64]int{0: 0, 1: 100} {
+		inner := &rangeRecordWriter{}
+		w := NewSampleWriter(inner, rate, rand.NewPCG(1, 2))
+		for range 100 {
+			require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{}))
+		}
+		assert.Len(t, inner.rows, want, "rate %v", rate)
+	}
+}
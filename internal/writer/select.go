@@ -0,0 +1,99 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// SelectWriter wraps the row writer of an additional output, passing on the
+// data columns it writes out of the rows of the merge. Adjacent ranges left
+// with equal values are joined, and ranges left without values are dropped
+// unless the output includes empty rows.
+type SelectWriter struct {
+	writer       row.Writer
+	indexes      []int // Index of each data column of the output in the rows
+	columns      int   // Data columns of the rows, followed by any validity
+	includeEmpty bool
+
+	pending    row.Row
+	start, end netip.Addr
+}
+
+// NewSelectWriter creates a writer passing the data columns of output, taken
+// by name from rows with the data columns of cfg, on to writer.
+func NewSelectWriter(writer row.Writer, cfg, output *config.Config) (*SelectWriter, error) {
+	indexes := make([]int, len(output.Columns))
+	for i, col := range output.Columns {
+		indexes[i] = slices.IndexFunc(cfg.Columns, func(c config.Column) bool {
+			return c.Name == col.Name
+		})
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("column '%s' is not a configured data column", col.Name)
+		}
+	}
+	return &SelectWriter{
+		writer:       writer,
+		indexes:      indexes,
+		columns:      len(cfg.Columns),
+		includeEmpty: output.Output.IncludeEmptyRows != nil && *output.Output.IncludeEmptyRows,
+	}, nil
+}
+
+// WriteRow writes the selected columns of a row.
+func (s *SelectWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return s.WriteRange(prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WriteRange writes the selected columns of a range, joining it to the
+// previous range if their values are equal.
+func (s *SelectWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	selected := make(row.Row, len(s.indexes), len(s.indexes)+max(len(r)-s.columns, 0))
+	for i, index := range s.indexes {
+		selected[i] = r[index]
+	}
+	// The validity of time-sliced rows follows the data columns
+	if len(r) > s.columns {
+		selected = append(selected, r[s.columns:]...)
+	}
+
+	if s.pending != nil && s.end.Next() == start && s.pending.Equal(selected) {
+		s.end = end
+		return nil
+	}
+	if err := s.writePending(); err != nil {
+		return err
+	}
+	s.pending, s.start, s.end = selected, start, end
+	return nil
+}
+
+// writePending writes the pending range, unless it is empty and empty rows
+// are left out.
+func (s *SelectWriter) writePending() error {
+	pending := s.pending
+	s.pending = nil
+	if pending == nil || (!s.includeEmpty && pending[:len(s.indexes)].IsEmpty()) {
+		return nil
+	}
+	return row.WriteRange(s.writer, s.start, s.end, pending)
+}
+
+// Flush writes the pending range and flushes the wrapped writer.
+func (s *SelectWriter) Flush() error {
+	if err := s.writePending(); err != nil {
+		return err
+	}
+	return row.Flush(s.writer)
+}
+
+// Sync syncs the wrapped writer. The pending range is written later, as it
+// may still be joined.
+func (s *SelectWriter) Sync() error {
+	return row.Sync(s.writer)
+}
@@ -0,0 +1,105 @@
+package writer
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestSelectWriter(t *testing.T) {
+	cfg := &config.Config{
+		Columns: []config.Column{{Name: "country"}, {Name: "city"}, {Name: "is_hosting"}},
+	}
+	output := &config.Config{
+		Output:  config.OutputConfig{IncludeEmptyRows: new(bool)},
+		Columns: []config.Column{{Name: "is_hosting"}, {Name: "country"}},
+	}
+	inner := &rangeRecordWriter{}
+	w, err := NewSelectWriter(inner, cfg, output)
+	require.NoError(t, err)
+
+	us := func(city string, hosting mmdbtype.DataType) row.Row {
+		return row.Row{mmdbtype.String("US"), mmdbtype.String(city), hosting}
+	}
+	// Cities differ, but the selected columns of the first three are equal
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), us("Chicago", nil)))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.1.0/24"), us("Denver", nil)))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.2.0"),
+		netip.MustParseAddr("1.0.3.255"),
+		us("Boston", nil),
+	))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.4.0/24"), us("Boston", mmdbtype.Bool(true))))
+	// Not adjacent
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.6.0/24"), us("Boston", mmdbtype.Bool(true))))
+	// Empty once selected
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("1.0.7.0/24"),
+		row.Row{nil, mmdbtype.String("Paris"), nil},
+	))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, [][2]netip.Addr{
+		{netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.3.255")},
+		{netip.MustParseAddr("1.0.4.0"), netip.MustParseAddr("1.0.4.255")},
+		{netip.MustParseAddr("1.0.6.0"), netip.MustParseAddr("1.0.6.255")},
+	}, inner.ranges)
+	assert.Equal(t, [][]mmdbtype.DataType{
+		{nil, mmdbtype.String("US")},
+		{mmdbtype.Bool(true), mmdbtype.String("US")},
+		{mmdbtype.Bool(true), mmdbtype.String("US")},
+	}, inner.rangeData)
+}
+
+func TestSelectWriter_IncludeEmptyRows(t *testing.T) {
+	cfg := &config.Config{Columns: []config.Column{{Name: "country"}, {Name: "city"}}}
+	includeEmpty := true
+	output := &config.Config{
+		Output:  config.OutputConfig{IncludeEmptyRows: &includeEmpty},
+		Columns: []config.Column{{Name: "country"}},
+	}
+	inner := &rangeRecordWriter{}
+	w, err := NewSelectWriter(inner, cfg, output)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{nil, mmdbtype.String("Paris")}))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, [][]mmdbtype.DataType{{nil}}, inner.rangeData)
+}
+
+func TestSelectWriter_Validity(t *testing.T) {
+	cfg := &config.Config{Columns: []config.Column{{Name: "country"}, {Name: "city"}}}
+	output := &config.Config{
+		Output:  config.OutputConfig{IncludeEmptyRows: new(bool)},
+		Columns: []config.Column{{Name: "country"}},
+	}
+	inner := &rangeRecordWriter{}
+	w, err := NewSelectWriter(inner, cfg, output)
+	require.NoError(t, err)
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := row.Row{mmdbtype.String("FR"), mmdbtype.String("Paris")}.WithValidity(from, time.Time{})
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), r))
+	require.NoError(t, w.Flush())
+
+	require.Len(t, inner.rangeData, 1)
+	written := row.Row(inner.rangeData[0])
+	assert.Equal(t, mmdbtype.String("FR"), written[0])
+	assert.Equal(t, r.ValidFrom(2), written.ValidFrom(1))
+}
+
+func TestNewSelectWriter_UnknownColumn(t *testing.T) {
+	_, err := NewSelectWriter(
+		&rangeRecordWriter{},
+		&config.Config{Columns: []config.Column{{Name: "country"}}},
+		&config.Config{Columns: []config.Column{{Name: "city"}}},
+	)
+	require.EqualError(t, err, "column 'city' is not a configured data column")
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
 )
 
 type recordWriter struct {
@@ -16,7 +18,7 @@ type recordWriter struct {
 	flushE error
 }
 
-func (r *recordWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error {
+func (r *recordWriter) WriteRow(prefix netip.Prefix, data row.Row) error {
 	r.rows = append(r.rows, prefix)
 	r.data = append(r.data, data)
 	return nil
@@ -36,7 +38,7 @@ type rangeRecordWriter struct {
 	writeRangeE error
 }
 
-func (r *rangeRecordWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataType) error {
+func (r *rangeRecordWriter) WriteRow(prefix netip.Prefix, data row.Row) error {
 	if r.writeRowE != nil {
 		return r.writeRowE
 	}
@@ -45,7 +47,7 @@ func (r *rangeRecordWriter) WriteRow(prefix netip.Prefix, data []mmdbtype.DataTy
 	return nil
 }
 
-func (r *rangeRecordWriter) WriteRange(start, end netip.Addr, data []mmdbtype.DataType) error {
+func (r *rangeRecordWriter) WriteRange(start, end netip.Addr, data row.Row) error {
 	if r.writeRangeE != nil {
 		return r.writeRangeE
 	}
@@ -264,26 +266,22 @@ type errorRecordWriter struct {
 	err error
 }
 
-func (e *errorRecordWriter) WriteRow(netip.Prefix, []mmdbtype.DataType) error {
+func (e *errorRecordWriter) WriteRow(netip.Prefix, row.Row) error {
 	return e.err
 }
 
 // TestSplitRowWriter_ImplementsRangeRowWriter verifies that SplitRowWriter
-// implements the RangeRowWriter interface used by merger.Accumulator.
+// implements the row.RangeWriter interface used by merger.Accumulator.
 func TestSplitRowWriter_ImplementsRangeRowWriter(t *testing.T) {
 	v4 := &rangeRecordWriter{}
 	v6 := &rangeRecordWriter{}
 	split := NewSplitRowWriter(v4, v6)
 
-	// Verify that SplitRowWriter satisfies the RangeRowWriter interface
-	// This is the same check that merger.Accumulator.Flush() performs
-	type rangeRowWriter interface {
-		WriteRange(netip.Addr, netip.Addr, []mmdbtype.DataType) error
-	}
-
+	// Verify that SplitRowWriter satisfies the row.RangeWriter interface
+	// This is the same check that row.WriteRange performs for the Accumulator
 	// This type assertion must succeed for the feature to work
-	_, ok := any(split).(rangeRowWriter)
-	assert.True(t, ok, "SplitRowWriter must implement RangeRowWriter interface")
+	_, ok := any(split).(row.RangeWriter)
+	assert.True(t, ok, "SplitRowWriter must implement row.RangeWriter interface")
 
 	// Verify it actually calls WriteRange on the underlying writer
 	start := netip.MustParseAddr("10.0.0.0")
@@ -311,13 +309,9 @@ func TestSplitRowWriter_RangeCompressionWithAccumulator(t *testing.T) {
 	v6 := &rangeRecordWriter{}
 	split := NewSplitRowWriter(v4, v6)
 
-	// Simulate what merger.Accumulator does: check for RangeRowWriter
-	type rangeRowWriter interface {
-		WriteRange(netip.Addr, netip.Addr, []mmdbtype.DataType) error
-	}
-
+	// Simulate what merger.Accumulator does: check for row.RangeWriter
 	// When Accumulator.Flush() runs, it does this check:
-	if rangeWriter, ok := any(split).(rangeRowWriter); ok {
+	if rangeWriter, ok := any(split).(row.RangeWriter); ok {
 		// It should call WriteRange with a range of adjacent networks
 		start := netip.MustParseAddr("10.0.0.0")
 		end := netip.MustParseAddr("10.0.3.255") // Covers 4 /24s
@@ -334,6 +328,6 @@ func TestSplitRowWriter_RangeCompressionWithAccumulator(t *testing.T) {
 		assert.Equal(t, end, v4.ranges[0][1])
 		assert.Empty(t, v4.rows, "should NOT call WriteRow when WriteRange is available")
 	} else {
-		t.Fatal("SplitRowWriter should implement RangeRowWriter interface")
+		t.Fatal("SplitRowWriter should implement row.RangeWriter interface")
 	}
 }
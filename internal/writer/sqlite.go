@@ -0,0 +1,313 @@
+package writer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"go4.org/netipx"
+	_ "modernc.org/sqlite" // Registers the "sqlite" driver
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// SQLiteWriter writes rows to a table of a SQLite database, indexed on the
+// start and end integer columns so that an address is found with a range
+// lookup. IPv4 bounds are stored as integers and IPv6 bounds as 16-byte
+// big-endian blobs; SQLite orders every integer before every blob, so one
+// query works for both.
+//
+// SQLite builds a database in a file of its own, so the database is built in
+// a temporary file and copied to the output when the writer is flushed.
+type SQLiteWriter struct {
+	out          io.Writer
+	config       *config.Config
+	path         string // Temporary database file
+	db           *sql.DB
+	tx           *sql.Tx
+	insert       *sql.Stmt
+	index        string // Statement creating the lookup index
+	rangeCapable bool
+	args         []any
+}
+
+// NewSQLiteWriter creates a SQLite writer, writing the database to w when
+// flushed.
+func NewSQLiteWriter(w io.Writer, cfg *config.Config) (*SQLiteWriter, error) {
+	file, err := os.CreateTemp("", "mmdbconvert-*.sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary database: %w", err)
+	}
+	path := file.Name()
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("creating temporary database: %w", err)
+	}
+
+	sw := &SQLiteWriter{
+		out:    w,
+		config: cfg,
+		path:   path,
+		rangeCapable: !slices.ContainsFunc(cfg.Network.Columns, func(col config.NetworkColumn) bool {
+			return col.Type == NetworkColumnCIDR
+		}),
+		args: make([]any, len(cfg.Network.Columns)+len(cfg.Columns)),
+	}
+	if err := sw.open(); err != nil {
+		sw.Close()
+		return nil, err
+	}
+	return sw, nil
+}
+
+// open creates the table and prepares the insert statement. The database
+// is a scratch file until it is copied to the output, so it is written
+// without a journal or syncs.
+func (w *SQLiteWriter) open() error {
+	db, err := sql.Open("sqlite", w.path)
+	if err != nil {
+		return fmt.Errorf("opening temporary database: %w", err)
+	}
+	w.db = db
+	// The transaction and the pragmas must share a connection
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{"PRAGMA journal_mode = OFF", "PRAGMA synchronous = OFF"} {
+		if _, err := db.Exec(pragma); err != nil {
+			return fmt.Errorf("configuring temporary database: %w", err)
+		}
+	}
+
+	table := quoteSQLiteIdentifier(w.config.Output.SQLite.Table)
+	var columns, params []string
+	var start, end string
+	for _, col := range w.config.Network.Columns {
+		name := quoteSQLiteIdentifier(col.OutputName("sqlite"))
+		switch col.Type {
+		case NetworkColumnStartInt:
+			// Integers or blobs, so no declared type
+			columns = append(columns, name)
+			if start == "" {
+				start = name
+			}
+		case NetworkColumnEndInt:
+			columns = append(columns, name)
+			if end == "" {
+				end = name
+			}
+		default:
+			// Decimal columns are TEXT too: with NUMERIC affinity, SQLite
+			// would store integers beyond 64 bits as lossy REAL values
+			columns = append(columns, name+" TEXT")
+		}
+		params = append(params, "?")
+	}
+	for _, col := range w.config.Columns {
+		// Values keep the storage class of their MMDB type
+		columns = append(columns, quoteSQLiteIdentifier(col.OutputName("sqlite")))
+		params = append(params, "?")
+	}
+	if _, err := db.Exec(
+		fmt.Sprintf("CREATE TABLE %s (%s)", table, strings.Join(columns, ", ")),
+	); err != nil {
+		return fmt.Errorf("creating table: %w", err)
+	}
+	w.index = fmt.Sprintf(
+		"CREATE INDEX %s ON %s (%s, %s)",
+		quoteSQLiteIdentifier(w.config.Output.SQLite.Table+"_range"),
+		table,
+		start,
+		end,
+	)
+
+	if w.tx, err = db.Begin(); err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	w.insert, err = w.tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %s VALUES (%s)",
+		table,
+		strings.Join(params, ", "),
+	))
+	if err != nil {
+		return fmt.Errorf("preparing insert: %w", err)
+	}
+	return nil
+}
+
+// WriteRow writes a single row with network prefix and column data.
+func (w *SQLiteWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeRecord(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *SQLiteWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, writing a single row unless a
+// network column is a CIDR.
+func (w *SQLiteWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		for _, cidr := range network.RangeToPrefixes(start, end) {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.writeRecord(netip.Prefix{}, start, end, r)
+}
+
+// writeRecord inserts a row. prefix is invalid for range rows.
+func (w *SQLiteWriter) writeRecord(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	for i, netCol := range w.config.Network.Columns {
+		value, err := w.networkValue(prefix, start, end, r, netCol.Type)
+		if err != nil {
+			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
+		}
+		w.args[i] = value
+	}
+	offset := len(w.config.Network.Columns)
+	for i, col := range w.config.Columns {
+		value, err := sqliteValue(r, i)
+		if err != nil {
+			return fmt.Errorf("converting column '%s': %w", col.Name, err)
+		}
+		w.args[offset+i] = value
+	}
+	if _, err := w.insert.Exec(w.args...); err != nil {
+		return fmt.Errorf("inserting row: %w", err)
+	}
+	return nil
+}
+
+// networkValue returns the value of a network column.
+func (w *SQLiteWriter) networkValue(
+	prefix netip.Prefix,
+	start, end netip.Addr,
+	r row.Row,
+	colType string,
+) (any, error) {
+	switch colType {
+	case NetworkColumnStartInt:
+		return sqliteAddrInt(start), nil
+	case NetworkColumnEndInt:
+		return sqliteAddrInt(end), nil
+	case NetworkColumnValidFrom:
+		return nullIfEmpty(r.ValidFrom(len(w.config.Columns))), nil
+	case NetworkColumnValidTo:
+		return nullIfEmpty(r.ValidTo(len(w.config.Columns))), nil
+	default:
+		value, err := appendNetworkValue(nil, prefix, start, end, colType)
+		if err != nil {
+			return nil, err
+		}
+		return string(value), nil
+	}
+}
+
+// sqliteAddrInt returns an address as an integer for IPv4 and as a 16-byte
+// big-endian blob for IPv6, which does not fit in SQLite's 64-bit integers.
+func sqliteAddrInt(addr netip.Addr) any {
+	if addr.Is4() {
+		return int64(network.IPv4ToUint32(addr))
+	}
+	return ipv6IntBytes(addr)
+}
+
+// sqliteValue returns the value of data column i in its SQLite storage
+// class: strings as TEXT, booleans and integers as INTEGER, floats as
+// REAL, and bytes as BLOB. Integers beyond 64 bits are stored as decimal
+// TEXT, and maps and slices as JSON TEXT.
+func sqliteValue(r row.Row, i int) (any, error) {
+	switch r.Kind(i) {
+	case row.KindNull:
+		return nil, nil
+	case row.KindString:
+		s, _ := r.String(i)
+		return s, nil
+	case row.KindBool:
+		if b, _ := r.Bool(i); b {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case row.KindInt, row.KindUint:
+		if n, ok := r.Int64(i); ok {
+			return n, nil
+		}
+		return r.Text(i)
+	case row.KindFloat:
+		f, _ := r.Float64(i)
+		return f, nil
+	case row.KindBytes:
+		b, _ := r.Bytes(i)
+		return b, nil
+	default:
+		return r.Text(i)
+	}
+}
+
+// quoteSQLiteIdentifier quotes a table, index, or column name.
+func quoteSQLiteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Flush commits the rows, creates the lookup index, and copies the
+// database to the output. Later calls do nothing.
+func (w *SQLiteWriter) Flush() error {
+	if w.db == nil {
+		return nil
+	}
+	defer w.Close()
+
+	if err := w.insert.Close(); err != nil {
+		return fmt.Errorf("closing insert: %w", err)
+	}
+	if err := w.tx.Commit(); err != nil {
+		return fmt.Errorf("committing rows: %w", err)
+	}
+	w.tx = nil
+	if _, err := w.db.Exec(w.index); err != nil {
+		return fmt.Errorf("creating index: %w", err)
+	}
+	if err := w.db.Close(); err != nil {
+		return fmt.Errorf("closing database: %w", err)
+	}
+	w.db = nil
+
+	// #nosec G304 -- the temporary file created by NewSQLiteWriter
+	file, err := os.Open(w.path)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(w.out, file); err != nil {
+		return fmt.Errorf("writing database: %w", err)
+	}
+	return nil
+}
+
+// Close discards the database if it was not flushed, and removes the
+// temporary file.
+func (w *SQLiteWriter) Close() error {
+	var errs []error
+	if w.tx != nil {
+		errs = append(errs, w.tx.Rollback())
+		w.tx = nil
+	}
+	if w.db != nil {
+		errs = append(errs, w.db.Close())
+		w.db = nil
+	}
+	if err := os.Remove(w.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
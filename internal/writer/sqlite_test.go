@@ -0,0 +1,176 @@
+package writer
+
+import (
+	"bytes"
+	"database/sql"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func sqliteConfig(network ...config.NetworkColumn) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{SQLite: config.SQLiteConfig{Table: "networks"}},
+		Network: config.NetworkConfig{Columns: append([]config.NetworkColumn{
+			{Name: "start_int", Type: NetworkColumnStartInt},
+			{Name: "end_int", Type: NetworkColumnEndInt},
+		}, network...)},
+		Columns: []config.Column{
+			{Name: "country"},
+			{Name: "accuracy"},
+			{Name: "is_eu"},
+			{Name: "names"},
+		},
+	}
+}
+
+// openSQLite writes the database in buf to a file and opens it.
+func openSQLite(t *testing.T, buf *bytes.Buffer) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out.sqlite")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewSQLiteWriter(&buf, sqliteConfig(config.NetworkColumn{Name: "first", Type: NetworkColumnStartIP}))
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("US"),
+		mmdbtype.Uint16(100),
+		mmdbtype.Bool(false),
+		mmdbtype.Map{"en": mmdbtype.String("United States")},
+	}))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.1.0"),
+		netip.MustParseAddr("1.0.2.255"),
+		row.Row{mmdbtype.String("DE"), nil, mmdbtype.Bool(true), nil},
+	))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{
+		mmdbtype.String("JP"),
+		mmdbtype.Uint64(1 << 63),
+		nil,
+		nil,
+	}))
+	require.NoError(t, w.Flush())
+	_, err = os.Stat(w.path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	db := openSQLite(t, &buf)
+	rows, err := db.Query("SELECT first, country, accuracy, is_eu, names FROM networks ORDER BY start_int")
+	require.NoError(t, err)
+	defer rows.Close()
+	var got [][]any
+	for rows.Next() {
+		values := make([]any, 5)
+		pointers := make([]any, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		require.NoError(t, rows.Scan(pointers...))
+		got = append(got, values)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]any{
+		{"1.0.0.0", "US", int64(100), int64(0), `{"en":"United States"}`},
+		{"1.0.1.0", "DE", nil, int64(1), nil},
+		{"2001:db8::", "JP", "9223372036854775808", nil, nil},
+	}, got)
+
+	// A range lookup finds addresses of both IP versions with the index
+	lookup := func(addr string) string {
+		var bound any = ipv6IntBytes(netip.MustParseAddr(addr))
+		if a := netip.MustParseAddr(addr); a.Is4() {
+			bound = sqliteAddrInt(a)
+		}
+		var country string
+		err := db.QueryRow(
+			"SELECT country FROM networks WHERE start_int <= ? AND end_int >= ? ORDER BY start_int DESC LIMIT 1",
+			bound,
+			bound,
+		).Scan(&country)
+		if err != nil {
+			return err.Error()
+		}
+		return country
+	}
+	assert.Equal(t, "DE", lookup("1.0.2.7"))
+	assert.Equal(t, "JP", lookup("2001:db8::1"))
+	assert.Equal(t, "sql: no rows in result set", lookup("1.0.3.0"))
+
+	var plan string
+	require.NoError(t, db.QueryRow(
+		"EXPLAIN QUERY PLAN SELECT country FROM networks WHERE start_int <= 1 ORDER BY start_int DESC LIMIT 1",
+	).Scan(new(int), new(int), new(int), &plan))
+	assert.Contains(t, plan, "networks_range")
+}
+
+func TestSQLiteWriter_CIDR(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewSQLiteWriter(&buf, sqliteConfig(config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR}))
+	require.NoError(t, err)
+	assert.False(t, w.WritesRanges())
+
+	// The range is written as one row per CIDR
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("1.0.1.0"),
+		netip.MustParseAddr("1.0.2.255"),
+		row.Row{mmdbtype.String("DE"), nil, nil, nil},
+	))
+	require.NoError(t, w.Flush())
+
+	rows, err := openSQLite(t, &buf).Query("SELECT network FROM networks ORDER BY start_int")
+	require.NoError(t, err)
+	defer rows.Close()
+	var networks []string
+	for rows.Next() {
+		var network string
+		require.NoError(t, rows.Scan(&network))
+		networks = append(networks, network)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"1.0.1.0/24", "1.0.2.0/24"}, networks)
+}
+
+func TestSQLiteWriter_Decimal(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewSQLiteWriter(&buf, sqliteConfig(
+		config.NetworkColumn{Name: "start_decimal", Type: NetworkColumnStartDecimal},
+	))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{nil, nil, nil, nil}))
+	require.NoError(t, w.Flush())
+
+	// The integer is kept whole, rather than rounded to a REAL
+	var start any
+	var typ string
+	require.NoError(t, openSQLite(t, &buf).QueryRow(
+		"SELECT start_decimal, typeof(start_decimal) FROM networks",
+	).Scan(&start, &typ))
+	assert.Equal(t, "42540766411282592856903984951653826560", start)
+	assert.Equal(t, "text", typ)
+}
+
+func TestSQLiteWriter_Close(t *testing.T) {
+	w, err := NewSQLiteWriter(&bytes.Buffer{}, sqliteConfig())
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), make(row.Row, 4)))
+
+	// An unflushed database is discarded
+	require.NoError(t, w.Close())
+	_, err = os.Stat(w.path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
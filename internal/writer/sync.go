@@ -0,0 +1,90 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// SyncWriter wraps a row writer and syncs it after a number of rows or an
+// amount of time, whichever comes first. Syncing happens inline, so the
+// merger waits while the output is committed to storage rather than running
+// ahead of a slow disk.
+type SyncWriter struct {
+	writer    row.Writer
+	everyRows int
+	interval  time.Duration
+	now       func() time.Time
+
+	rows     int       // Rows written since the last sync
+	lastSync time.Time // Time of the last sync
+}
+
+// NewSyncWriter creates a writer that syncs w every everyRows rows and every
+// interval. A zero value disables that trigger.
+func NewSyncWriter(w row.Writer, everyRows int, interval time.Duration) *SyncWriter {
+	return &SyncWriter{
+		writer:    w,
+		everyRows: everyRows,
+		interval:  interval,
+		now:       time.Now,
+		lastSync:  time.Now(),
+	}
+}
+
+// WriteRow writes a row and syncs if a threshold has been reached.
+func (s *SyncWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if err := s.writer.WriteRow(prefix, r); err != nil {
+		return err
+	}
+	return s.wrote()
+}
+
+// WriteRange writes a range and syncs if a threshold has been reached.
+func (s *SyncWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if err := row.WriteRange(s.writer, start, end, r); err != nil {
+		return err
+	}
+	return s.wrote()
+}
+
+// Flush flushes the wrapped writer.
+func (s *SyncWriter) Flush() error {
+	return row.Flush(s.writer)
+}
+
+// Sync syncs the wrapped writer and resets both thresholds.
+func (s *SyncWriter) Sync() error {
+	s.rows = 0
+	s.lastSync = s.now()
+	if err := row.Sync(s.writer); err != nil {
+		return fmt.Errorf("syncing output: %w", err)
+	}
+	return nil
+}
+
+func (s *SyncWriter) wrote() error {
+	s.rows++
+	if s.everyRows > 0 && s.rows >= s.everyRows {
+		return s.Sync()
+	}
+	if s.interval > 0 && s.now().Sub(s.lastSync) >= s.interval {
+		return s.Sync()
+	}
+	return nil
+}
+
+// syncOutput commits w to stable storage if it supports syncing.
+func syncOutput(w io.Writer) error {
+	syncer, ok := w.(row.Syncer)
+	if !ok {
+		return nil
+	}
+	if err := syncer.Sync(); err != nil {
+		return fmt.Errorf("syncing output file: %w", err)
+	}
+	return nil
+}
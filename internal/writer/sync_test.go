@@ -0,0 +1,142 @@
+package writer
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// syncRecordWriter records rows and counts syncs.
+type syncRecordWriter struct {
+	recordWriter
+
+	syncs int
+	syncE error
+}
+
+func (s *syncRecordWriter) Sync() error {
+	s.syncs++
+	return s.syncE
+}
+
+// syncBuffer is an output buffer that records the bytes present at each sync.
+type syncBuffer struct {
+	bytes.Buffer
+
+	synced []int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced = append(b.synced, b.Len())
+	return nil
+}
+
+func TestSyncWriter_EveryRows(t *testing.T) {
+	inner := &syncRecordWriter{}
+	w := NewSyncWriter(inner, 2, 0)
+
+	prefix := netip.MustParsePrefix("10.0.0.0/24")
+	for range 5 {
+		require.NoError(t, w.WriteRow(prefix, nil))
+	}
+
+	assert.Len(t, inner.rows, 5)
+	assert.Equal(t, 2, inner.syncs)
+}
+
+func TestSyncWriter_Interval(t *testing.T) {
+	inner := &syncRecordWriter{}
+	w := NewSyncWriter(inner, 0, time.Minute)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	w.lastSync = now
+
+	start := netip.MustParseAddr("10.0.0.0")
+	end := netip.MustParseAddr("10.0.0.255")
+	require.NoError(t, w.WriteRange(start, end, nil))
+	assert.Equal(t, 0, inner.syncs)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, w.WriteRange(start, end, nil))
+	assert.Equal(t, 1, inner.syncs)
+
+	now = now.Add(30 * time.Second)
+	require.NoError(t, w.WriteRange(start, end, nil))
+	assert.Equal(t, 1, inner.syncs, "interval restarts after a sync")
+
+	// The inner writer has no WriteRange, so ranges arrive as prefixes
+	assert.Len(t, inner.rows, 3)
+}
+
+func TestSyncWriter_PropagatesErrors(t *testing.T) {
+	inner := &syncRecordWriter{syncE: errors.New("disk full")}
+	w := NewSyncWriter(inner, 1, 0)
+
+	err := w.WriteRow(netip.MustParsePrefix("10.0.0.0/24"), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "disk full")
+}
+
+func TestSyncWriter_NonSyncingWriter(t *testing.T) {
+	inner := &recordWriter{}
+	w := NewSyncWriter(inner, 1, 0)
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("10.0.0.0/24"), nil))
+	assert.Len(t, inner.rows, 1)
+}
+
+func TestCSVWriter_Sync(t *testing.T) {
+	cfg := &config.Config{
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+	out := &syncBuffer{}
+	w := NewCSVWriter(out, cfg)
+
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("10.0.0.0/24"),
+		row.Row{mmdbtype.String("US")},
+	))
+	assert.Zero(t, out.Len(), "rows are batched until synced")
+
+	require.NoError(t, w.Sync())
+	assert.Equal(t, "network,country\n10.0.0.0/24,US\n", out.String())
+	assert.Equal(t, []int{out.Len()}, out.synced)
+}
+
+func TestParquetWriter_Sync(t *testing.T) {
+	cfg := &config.Config{
+		Output: config.OutputConfig{
+			Parquet: config.ParquetConfig{Compression: "none", RowGroupSize: 1000},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+	out := &syncBuffer{}
+	w, err := NewParquetWriter(out, cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRow(
+		netip.MustParsePrefix("10.0.0.0/24"),
+		row.Row{mmdbtype.String("US")},
+	))
+	require.NoError(t, w.Sync())
+	require.Len(t, out.synced, 1)
+	assert.Positive(t, out.synced[0], "row group written before sync")
+
+	require.NoError(t, w.Flush())
+}
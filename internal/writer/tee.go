@@ -0,0 +1,46 @@
+package writer
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// TeeWriter writes every row to two writers, such as the output and a merge
+// file saving the merged rows.
+type TeeWriter struct {
+	writer row.Writer
+	tee    row.Writer
+}
+
+// NewTeeWriter creates a writer passing rows on to writer and then to tee.
+func NewTeeWriter(writer, tee row.Writer) *TeeWriter {
+	return &TeeWriter{writer: writer, tee: tee}
+}
+
+// WriteRow writes a row to both writers.
+func (t *TeeWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	if err := t.writer.WriteRow(prefix, r); err != nil {
+		return err
+	}
+	return t.tee.WriteRow(prefix, r)
+}
+
+// WriteRange writes a range to both writers.
+func (t *TeeWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if err := row.WriteRange(t.writer, start, end, r); err != nil {
+		return err
+	}
+	return row.WriteRange(t.tee, start, end, r)
+}
+
+// Flush flushes both writers, even if the first fails.
+func (t *TeeWriter) Flush() error {
+	return errors.Join(row.Flush(t.writer), row.Flush(t.tee))
+}
+
+// Sync syncs both writers.
+func (t *TeeWriter) Sync() error {
+	return errors.Join(row.Sync(t.writer), row.Sync(t.tee))
+}
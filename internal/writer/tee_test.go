@@ -0,0 +1,52 @@
+package writer
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func TestTeeWriter(t *testing.T) {
+	first := &rangeRecordWriter{}
+	second := &recordWriter{}
+	w := NewTeeWriter(first, second)
+
+	data := row.Row{mmdbtype.String("US")}
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), data))
+	require.NoError(t, w.WriteRange(
+		netip.MustParseAddr("2.0.0.0"),
+		netip.MustParseAddr("2.0.1.255"),
+		data,
+	))
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/24")}, first.rows)
+	assert.Equal(t, [][2]netip.Addr{{
+		netip.MustParseAddr("2.0.0.0"),
+		netip.MustParseAddr("2.0.1.255"),
+	}}, first.ranges)
+	// The second writer has no WriteRange, so the range arrives as a prefix
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("1.0.0.0/24"),
+		netip.MustParsePrefix("2.0.0.0/23"),
+	}, second.rows)
+
+	errFlush := errors.New("flush failed")
+	second.flushE = errFlush
+	require.ErrorIs(t, w.Flush(), errFlush)
+}
+
+func TestTeeWriter_StopsOnError(t *testing.T) {
+	errWrite := errors.New("write failed")
+	second := &recordWriter{}
+	w := NewTeeWriter(&rangeRecordWriter{writeRowE: errWrite}, second)
+
+	err := w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{nil})
+	require.ErrorIs(t, err, errWrite)
+	assert.Empty(t, second.rows)
+}
@@ -0,0 +1,110 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// aclFlushSize is the number of buffered bytes that triggers a write to the
+// underlying writer of the VCL and Envoy writers.
+const aclFlushSize = 64 * 1024
+
+// errWriterClosed is returned for rows written after the final flush of a
+// writer whose output has a closing delimiter.
+var errWriterClosed = errors.New("write after the output was completed by Flush")
+
+// VCLWriter writes the networks of the merge as a Varnish VCL ACL block.
+// Data columns are not written; use output.filter to choose the networks.
+type VCLWriter struct {
+	out    io.Writer
+	config *config.Config
+	opened bool
+	closed bool
+	buf    []byte // Serialized lines not yet written to out
+}
+
+// NewVCLWriter creates a new VCL ACL writer.
+func NewVCLWriter(w io.Writer, cfg *config.Config) *VCLWriter {
+	return &VCLWriter{
+		out:    w,
+		config: cfg,
+		buf:    make([]byte, 0, aclFlushSize+4096),
+	}
+}
+
+// WriteRow writes an ACL entry for the network.
+func (w *VCLWriter) WriteRow(prefix netip.Prefix, _ row.Row) error {
+	if w.closed {
+		return errWriterClosed
+	}
+	w.ensureOpened()
+
+	w.buf = append(w.buf, "    \""...)
+	w.buf = prefix.Addr().AppendTo(w.buf)
+	w.buf = append(w.buf, "\"/"...)
+	w.buf = strconv.AppendInt(w.buf, int64(prefix.Bits()), 10)
+	w.buf = append(w.buf, ";\n"...)
+
+	if len(w.buf) >= aclFlushSize {
+		return w.flushBuffer()
+	}
+	return nil
+}
+
+// ensureOpened writes the provenance as comments and opens the ACL block.
+func (w *VCLWriter) ensureOpened() {
+	if w.opened {
+		return
+	}
+	w.opened = true
+	for _, pair := range w.config.Provenance.Pairs() {
+		w.buf = append(w.buf, "# "...)
+		w.buf = append(w.buf, pair.Key...)
+		w.buf = append(w.buf, '=')
+		w.buf = append(w.buf, pair.Value...)
+		w.buf = append(w.buf, '\n')
+	}
+	w.buf = append(w.buf, "acl "...)
+	w.buf = append(w.buf, w.config.Output.VCL.ACL...)
+	w.buf = append(w.buf, " {\n"...)
+}
+
+func (w *VCLWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return fmt.Errorf("writing VCL: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush closes the ACL block and writes all buffered data. No rows can be
+// written afterwards.
+func (w *VCLWriter) Flush() error {
+	if !w.closed {
+		w.ensureOpened()
+		w.buf = append(w.buf, "}\n"...)
+		w.closed = true
+	}
+	if err := w.flushBuffer(); err != nil {
+		return fmt.Errorf("VCL flush error: %w", err)
+	}
+	return nil
+}
+
+// Sync writes the entries so far, without closing the ACL block, and commits
+// them to stable storage if the underlying writer supports it.
+func (w *VCLWriter) Sync() error {
+	if err := w.flushBuffer(); err != nil {
+		return err
+	}
+	return syncOutput(w.out)
+}
@@ -0,0 +1,46 @@
+package writer
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+)
+
+func TestVCLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &config.Config{
+		Output: config.OutputConfig{VCL: config.VCLConfig{ACL: "hosting"}},
+	}
+	w := NewVCLWriter(&buf, cfg)
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), nil))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), nil))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, `acl hosting {
+    "1.0.0.0"/24;
+    "2001:db8::"/32;
+}
+`, buf.String())
+
+	require.ErrorIs(t, w.WriteRow(netip.MustParsePrefix("3.0.0.0/24"), nil), errWriterClosed)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n    ")), "a second flush adds nothing")
+}
+
+func TestVCLWriter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &config.Config{
+		Output:     config.OutputConfig{VCL: config.VCLConfig{ACL: "hosting"}},
+		Provenance: testProvenance,
+	}
+	w := NewVCLWriter(&buf, cfg)
+	require.NoError(t, w.Flush())
+
+	assert.Contains(t, buf.String(), "# mmdbconvert.version=1.2.3\n")
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("acl hosting {\n}\n")))
+}
@@ -0,0 +1,60 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+// New creates the writer for cfg.Output.Format, writing to w with every
+// format-specific option of cfg applied.
+//
+// ipVersion is IPVersion4 or IPVersion6 for one half of split output, or
+// IPVersionAny when w receives both. MMDB output requires a version, as it
+// decides the tree built, and is serialized to w only when the writer is
+// flushed, as is SQLite output.
+func New(w io.Writer, cfg *config.Config, ipVersion int) (row.Writer, error) {
+	switch cfg.Output.Format {
+	case "csv":
+		return NewCSVWriter(w, cfg), nil
+
+	case "parquet":
+		return NewParquetWriterWithIPVersion(w, cfg, ipVersion)
+
+	case "ptr":
+		return NewPTRWriter(w, cfg)
+
+	case "vcl":
+		return NewVCLWriter(w, cfg), nil
+
+	case "envoy":
+		return NewEnvoyWriter(w, cfg), nil
+
+	case "sqlite":
+		return NewSQLiteWriter(w, cfg)
+
+	case "clickhouse":
+		return NewClickHouseWriter(w, cfg), nil
+
+	case "xlsx":
+		return NewXLSXWriter(w, cfg)
+
+	case "protobuf":
+		return NewProtobufWriter(w, cfg), nil
+
+	case "msgpack":
+		return NewMessagePackWriter(w, cfg), nil
+
+	case "mmdb":
+		mmdbWriter, err := NewMMDBWriter("", cfg, ipVersion)
+		if err != nil {
+			return nil, err
+		}
+		mmdbWriter.out = w
+		return mmdbWriter, nil
+	}
+
+	return nil, fmt.Errorf("unsupported output format: %s", cfg.Output.Format)
+}
@@ -0,0 +1,113 @@
+package writer
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/provenance"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func newTestConfig(format string) *config.Config {
+	recordSize := 24
+	includeReserved := false
+	return &config.Config{
+		Output: config.OutputConfig{
+			Format: format,
+			Parquet: config.ParquetConfig{
+				Compression:  "none",
+				RowGroupSize: 500000,
+			},
+			MMDB: config.MMDBConfig{
+				DatabaseType:            "Test",
+				RecordSize:              &recordSize,
+				IncludeReservedNetworks: &includeReserved,
+			},
+			PTR: config.PTRConfig{Template: "{country}.geo.example.", TTL: 60},
+		},
+		Network: config.NetworkConfig{
+			Columns: []config.NetworkColumn{{Name: "network", Type: "cidr"}},
+		},
+		Columns: []config.Column{{Name: "country"}},
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		format string
+		check  func(t *testing.T, w row.Writer, out []byte)
+	}{
+		{
+			format: "csv",
+			check: func(t *testing.T, w row.Writer, out []byte) {
+				assert.IsType(t, &CSVWriter{}, w)
+				assert.Equal(t, "network,country\n1.0.0.0/24,AU\n", string(out))
+			},
+		},
+		{
+			format: "parquet",
+			check: func(t *testing.T, w row.Writer, out []byte) {
+				assert.IsType(t, &ParquetWriter{}, w)
+				assert.Equal(t, "PAR1", string(out[:4]))
+			},
+		},
+		{
+			format: "mmdb",
+			check: func(t *testing.T, w row.Writer, out []byte) {
+				assert.IsType(t, &MMDBWriter{}, w)
+				reader, err := maxminddb.OpenBytes(out)
+				require.NoError(t, err)
+				var record map[string]any
+				require.NoError(t, reader.Lookup(netip.MustParseAddr("1.0.0.1")).Decode(&record))
+				assert.Equal(t, map[string]any{"country": "AU"}, record)
+			},
+		},
+		{
+			format: "ptr",
+			check: func(t *testing.T, w row.Writer, out []byte) {
+				assert.IsType(t, &PTRWriter{}, w)
+				assert.Equal(t, "*.0.0.1.in-addr.arpa.\t60\tIN\tPTR\tau.geo.example.\n", string(out))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := New(&buf, newTestConfig(tt.format), IPVersion4)
+			require.NoError(t, err)
+
+			require.NoError(t, w.WriteRow(
+				netip.MustParsePrefix("1.0.0.0/24"),
+				row.Row{mmdbtype.String("AU")},
+			))
+			require.NoError(t, row.Flush(w))
+			tt.check(t, w, buf.Bytes())
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, newTestConfig("mmdb"), IPVersionAny)
+	require.Error(t, err, "MMDB output needs an IP version")
+	assert.Contains(t, err.Error(), "invalid IP version")
+
+	_, err = New(&bytes.Buffer{}, newTestConfig("json"), IPVersionAny)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported output format: json")
+}
+
+var testProvenance = provenance.Info{
+	ToolVersion:  "1.2.3",
+	ConfigSHA256: "abc123",
+	Sources: []provenance.Source{
+		{Name: "city", DatabaseType: "GeoIP2-City", BuildEpoch: 1700000000},
+	},
+}
@@ -0,0 +1,357 @@
+package writer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"go4.org/netipx"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+	"github.com/maxmind/mmdbconvert/network"
+)
+
+// xlsxMaxCellChars is the most characters an XLSX cell holds.
+const xlsxMaxCellChars = 32767
+
+// xlsxMaxExactInt is the largest integer Excel stores exactly, as its
+// numbers are doubles.
+const xlsxMaxExactInt = 1 << 53
+
+// xlsxStaticParts are the parts of the workbook besides the worksheet.
+// xl/workbook.xml has the sheet name at %s.
+var xlsxStaticParts = []struct{ name, content string }{
+	{
+		"[Content_Types].xml",
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+	},
+	{
+		"_rels/.rels",
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		"xl/_rels/workbook.xml.rels",
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		"xl/workbook.xml",
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+	},
+}
+
+// XLSXWriter writes rows to the worksheet of an Excel workbook, with a
+// header row of the column names. Values keep their types: numbers and
+// booleans are cells of those types, and strings are text cells, so codes
+// with leading zeros keep them. Integers Excel cannot hold exactly, such as
+// IPv6 start_int values, are text.
+//
+// A worksheet holds about a million rows, so output.xlsx.max_rows guards
+// against exports too large for a spreadsheet: the row beyond it fails the
+// export.
+type XLSXWriter struct {
+	zip          *zip.Writer
+	sheet        *bufio.Writer
+	config       *config.Config
+	maxRows      int
+	rows         int // Rows written below the header
+	rangeCapable bool
+	row          bytes.Buffer // The row being built
+	ref          []byte       // Scratch cell reference
+	flushed      bool
+}
+
+// NewXLSXWriter creates an XLSX writer, writing the workbook to w as rows
+// are written. It is complete once the writer is flushed.
+func NewXLSXWriter(w io.Writer, cfg *config.Config) (*XLSXWriter, error) {
+	xw := &XLSXWriter{
+		zip:     zip.NewWriter(w),
+		config:  cfg,
+		maxRows: cfg.Output.XLSX.MaxRows,
+		rangeCapable: !slices.ContainsFunc(cfg.Network.Columns, func(col config.NetworkColumn) bool {
+			return col.Type == NetworkColumnCIDR
+		}),
+	}
+	if xw.maxRows == 0 {
+		xw.maxRows = config.XLSXMaxRows
+	}
+
+	var sheetName bytes.Buffer
+	if err := xml.EscapeText(&sheetName, []byte(cfg.Output.XLSX.Sheet)); err != nil {
+		return nil, fmt.Errorf("escaping sheet name: %w", err)
+	}
+	for _, part := range xlsxStaticParts {
+		content := part.content
+		if part.name == "xl/workbook.xml" {
+			content = fmt.Sprintf(content, sheetName.String())
+		}
+		if err := xw.writePart(part.name, content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := xw.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("creating worksheet: %w", err)
+	}
+	xw.sheet = bufio.NewWriter(sheet)
+	xw.sheet.WriteString(xml.Header)
+	// The header row stays in view while scrolling
+	xw.sheet.WriteString(
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+			`</sheetView></sheetViews><sheetData>`,
+	)
+
+	xw.startRow(1)
+	column := 0
+	for _, col := range cfg.Network.Columns {
+		if err := xw.appendString(1, column, col.OutputName("xlsx")); err != nil {
+			return nil, err
+		}
+		column++
+	}
+	for _, col := range cfg.Columns {
+		if err := xw.appendString(1, column, col.OutputName("xlsx")); err != nil {
+			return nil, err
+		}
+		column++
+	}
+	if err := xw.endRow(); err != nil {
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (w *XLSXWriter) writePart(name, content string) error {
+	part, err := w.zip.Create(name)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name, err)
+	}
+	if _, err := io.WriteString(part, xml.Header+content); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// WriteRow writes a single row with network prefix and column data.
+func (w *XLSXWriter) WriteRow(prefix netip.Prefix, r row.Row) error {
+	return w.writeRecord(prefix, prefix.Addr(), netipx.PrefixLastIP(prefix), r)
+}
+
+// WritesRanges reports whether WriteRange writes a single row per range,
+// rather than one per CIDR.
+func (w *XLSXWriter) WritesRanges() bool {
+	return w.rangeCapable
+}
+
+// WriteRange implements row.RangeWriter, writing a single row unless a
+// network column is a CIDR.
+func (w *XLSXWriter) WriteRange(start, end netip.Addr, r row.Row) error {
+	if !w.rangeCapable {
+		for _, cidr := range network.RangeToPrefixes(start, end) {
+			if err := w.WriteRow(cidr, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.writeRecord(netip.Prefix{}, start, end, r)
+}
+
+// writeRecord writes a row below the rows written so far. prefix is
+// invalid for range rows.
+func (w *XLSXWriter) writeRecord(prefix netip.Prefix, start, end netip.Addr, r row.Row) error {
+	if w.rows >= w.maxRows {
+		return fmt.Errorf(
+			"XLSX output holds at most %d rows (output.xlsx.max_rows); filter the output to fewer networks",
+			w.maxRows,
+		)
+	}
+	rowNum := w.rows + 2
+	w.startRow(rowNum)
+
+	column := 0
+	for _, netCol := range w.config.Network.Columns {
+		if err := w.appendNetworkCell(rowNum, column, prefix, start, end, r, netCol.Type); err != nil {
+			return fmt.Errorf("generating network column '%s': %w", netCol.Name, err)
+		}
+		column++
+	}
+	for i, col := range w.config.Columns {
+		if err := w.appendValue(rowNum, column, r, i); err != nil {
+			return fmt.Errorf("converting column '%s': %w", col.Name, err)
+		}
+		column++
+	}
+	if err := w.endRow(); err != nil {
+		return err
+	}
+	w.rows++
+	return nil
+}
+
+// appendNetworkCell appends the cell of a network column. IPv4 integers are
+// numbers, and IPv6 integers text.
+func (w *XLSXWriter) appendNetworkCell(
+	rowNum, column int,
+	prefix netip.Prefix,
+	start, end netip.Addr,
+	r row.Row,
+	colType string,
+) error {
+	switch colType {
+	case NetworkColumnStartInt, NetworkColumnEndInt:
+		addr := start
+		if colType == NetworkColumnEndInt {
+			addr = end
+		}
+		if addr.Is4() {
+			w.appendNumber(rowNum, column, strconv.AppendUint(nil, uint64(network.IPv4ToUint32(addr)), 10))
+			return nil
+		}
+		return w.appendString(rowNum, column, string(appendAddrInt(nil, addr)))
+	case NetworkColumnValidFrom:
+		return w.appendString(rowNum, column, r.ValidFrom(len(w.config.Columns)))
+	case NetworkColumnValidTo:
+		return w.appendString(rowNum, column, r.ValidTo(len(w.config.Columns)))
+	default:
+		value, err := appendNetworkValue(nil, prefix, start, end, colType)
+		if err != nil {
+			return err
+		}
+		return w.appendString(rowNum, column, string(value))
+	}
+}
+
+// appendValue appends the cell of data column i, or nothing if it has no
+// value.
+func (w *XLSXWriter) appendValue(rowNum, column int, r row.Row, i int) error {
+	switch r.Kind(i) {
+	case row.KindNull:
+		return nil
+	case row.KindString:
+		s, _ := r.String(i)
+		return w.appendString(rowNum, column, s)
+	case row.KindBool:
+		b, _ := r.Bool(i)
+		w.appendCell(rowNum, column, `" t="b"><v>`)
+		if b {
+			w.row.WriteByte('1')
+		} else {
+			w.row.WriteByte('0')
+		}
+		w.row.WriteString("</v></c>")
+		return nil
+	case row.KindInt, row.KindUint:
+		if n, ok := r.Int64(i); ok && n >= -xlsxMaxExactInt && n <= xlsxMaxExactInt {
+			w.appendNumber(rowNum, column, strconv.AppendInt(nil, n, 10))
+			return nil
+		}
+	case row.KindFloat:
+		if f, _ := r.Float64(i); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			w.appendNumber(rowNum, column, strconv.AppendFloat(nil, f, 'g', -1, 64))
+			return nil
+		}
+	}
+	text, err := r.Text(i)
+	if err != nil {
+		return err
+	}
+	return w.appendString(rowNum, column, text)
+}
+
+// appendNumber appends a number cell.
+func (w *XLSXWriter) appendNumber(rowNum, column int, value []byte) {
+	w.appendCell(rowNum, column, `"><v>`)
+	w.row.Write(value)
+	w.row.WriteString("</v></c>")
+}
+
+// appendString appends a text cell, which Excel limits to 32767
+// characters.
+func (w *XLSXWriter) appendString(rowNum, column int, s string) error {
+	if utf8.RuneCountInString(s) > xlsxMaxCellChars {
+		return fmt.Errorf("value is longer than the %d characters of a cell", xlsxMaxCellChars)
+	}
+	w.appendCell(rowNum, column, `" t="inlineStr"><is><t xml:space="preserve">`)
+	if err := xml.EscapeText(&w.row, []byte(s)); err != nil {
+		return err
+	}
+	w.row.WriteString("</t></is></c>")
+	return nil
+}
+
+// appendCell appends the start of a cell up to its reference, followed by
+// rest.
+func (w *XLSXWriter) appendCell(rowNum, column int, rest string) {
+	w.row.WriteString(`<c r="`)
+	w.ref = appendXLSXColumn(w.ref[:0], column)
+	w.ref = strconv.AppendInt(w.ref, int64(rowNum), 10)
+	w.row.Write(w.ref)
+	w.row.WriteString(rest)
+}
+
+// appendXLSXColumn appends the letters of the zero-based column, such as A,
+// Z, AA, and AB.
+func appendXLSXColumn(dst []byte, column int) []byte {
+	var letters [4]byte
+	n := len(letters)
+	for column++; column > 0; column = (column - 1) / 26 {
+		n--
+		letters[n] = byte('A' + (column-1)%26)
+	}
+	return append(dst, letters[n:]...)
+}
+
+func (w *XLSXWriter) startRow(rowNum int) {
+	w.row.Reset()
+	w.row.WriteString(`<row r="`)
+	w.row.WriteString(strconv.Itoa(rowNum))
+	w.row.WriteString(`">`)
+}
+
+// endRow writes the row built to the worksheet.
+func (w *XLSXWriter) endRow() error {
+	w.row.WriteString("</row>")
+	if _, err := w.sheet.Write(w.row.Bytes()); err != nil {
+		return fmt.Errorf("writing row: %w", err)
+	}
+	return nil
+}
+
+// Flush completes the worksheet and the workbook. Later calls do nothing.
+func (w *XLSXWriter) Flush() error {
+	if w.flushed {
+		return nil
+	}
+	w.flushed = true
+	w.sheet.WriteString("</sheetData></worksheet>")
+	if err := w.sheet.Flush(); err != nil {
+		return fmt.Errorf("writing worksheet: %w", err)
+	}
+	if err := w.zip.Close(); err != nil {
+		return fmt.Errorf("writing workbook: %w", err)
+	}
+	return nil
+}
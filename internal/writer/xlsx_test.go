@@ -0,0 +1,112 @@
+package writer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/mmdbconvert/internal/config"
+	"github.com/maxmind/mmdbconvert/internal/row"
+)
+
+func xlsxConfig(maxRows int, network ...config.NetworkColumn) *config.Config {
+	return &config.Config{
+		Output: config.OutputConfig{
+			XLSX: config.XLSXConfig{Sheet: "R&D", MaxRows: maxRows},
+		},
+		Network: config.NetworkConfig{Columns: network},
+		Columns: []config.Column{
+			{Name: "postal"},
+			{Name: "accuracy"},
+			{Name: "is_eu"},
+			{Name: "latitude"},
+		},
+	}
+}
+
+// readXLSXPart returns the content of a part of the workbook in data.
+func readXLSXPart(t *testing.T, data []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	f, err := zr.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, xlsxConfig(0,
+		config.NetworkColumn{Name: "network", Type: NetworkColumnCIDR},
+		config.NetworkColumn{Name: "start_int", Type: NetworkColumnStartInt},
+	))
+	require.NoError(t, err)
+	assert.False(t, w.WritesRanges())
+
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("1.0.0.0/24"), row.Row{
+		mmdbtype.String("01234"),
+		mmdbtype.Uint16(20),
+		mmdbtype.Bool(true),
+		mmdbtype.Float64(51.5),
+	}))
+	require.NoError(t, w.WriteRow(netip.MustParsePrefix("2001:db8::/32"), row.Row{
+		mmdbtype.String("<a & b>"),
+		mmdbtype.Uint64(1 << 60),
+		nil,
+		nil,
+	}))
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Flush())
+
+	assert.Contains(t, readXLSXPart(t, buf.Bytes(), "xl/workbook.xml"), `<sheet name="R&amp;D"`)
+	sheet := readXLSXPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	text := func(ref, s string) string {
+		return `<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + s + `</t></is></c>`
+	}
+	number := func(ref, v string) string {
+		return `<c r="` + ref + `"><v>` + v + `</v></c>`
+	}
+	assert.Contains(t, sheet, `<row r="1">`+
+		text("A1", "network")+text("B1", "start_int")+text("C1", "postal")+
+		text("D1", "accuracy")+text("E1", "is_eu")+text("F1", "latitude")+`</row>`)
+	// Leading zeros are kept, as the postal code is text
+	assert.Contains(t, sheet, `<row r="2">`+
+		text("A2", "1.0.0.0/24")+number("B2", "16777216")+text("C2", "01234")+
+		number("D2", "20")+`<c r="E2" t="b"><v>1</v></c>`+number("F2", "51.5")+`</row>`)
+	// Integers a double cannot hold are text, and null values have no cell
+	assert.Contains(t, sheet, `<row r="3">`+
+		text("A3", "2001:db8::/32")+text("B3", "42540766411282592856903984951653826560")+
+		text("C3", "&lt;a &amp; b&gt;")+text("D3", "1152921504606846976")+`</row>`)
+	assert.Contains(t, sheet, `</sheetData></worksheet>`)
+}
+
+func TestXLSXWriter_MaxRows(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, xlsxConfig(2,
+		config.NetworkColumn{Name: "start_ip", Type: NetworkColumnStartIP},
+		config.NetworkColumn{Name: "end_ip", Type: NetworkColumnEndIP},
+	))
+	require.NoError(t, err)
+	require.True(t, w.WritesRanges())
+
+	r := row.Row{nil, nil, nil, nil}
+	require.NoError(t, w.WriteRange(netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.9"), r))
+	require.NoError(t, w.WriteRange(netip.MustParseAddr("2.0.0.0"), netip.MustParseAddr("2.0.0.9"), r))
+	err = w.WriteRange(netip.MustParseAddr("3.0.0.0"), netip.MustParseAddr("3.0.0.9"), r)
+	require.ErrorContains(t, err, "XLSX output holds at most 2 rows")
+}
+
+func TestAppendXLSXColumn(t *testing.T) {
+	for column, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		assert.Equal(t, want, string(appendXLSXColumn(nil, column)))
+	}
+}
@@ -0,0 +1,102 @@
+// Package network provides the IP address, range, and prefix utilities that
+// mmdbconvert's writers share, for writers of other formats to build on.
+//
+// Merged data reaches a writer as CIDR prefixes or as inclusive ranges of
+// addresses of one IP family. IPv4 addresses are plain 4-byte addresses, as
+// netip.Addr.Is4 reports, even when they come from the IPv4 subtree of an
+// IPv6 database.
+package network
+
+import (
+	"encoding/binary"
+	"math/big"
+	"net/netip"
+
+	"go4.org/netipx"
+)
+
+// IPv4ToUint32 converts an IPv4 address to uint32.
+func IPv4ToUint32(addr netip.Addr) uint32 {
+	if !addr.Is4() {
+		panic("IPv4ToUint32 called with non-IPv4 address")
+	}
+	bytes := addr.As4()
+	return binary.BigEndian.Uint32(bytes[:])
+}
+
+// IsAdjacent checks if two IP addresses are consecutive (no gap between them).
+func IsAdjacent(endIP, startIP netip.Addr) bool {
+	if endIP.Is4() != startIP.Is4() {
+		return false
+	}
+	return endIP.Next() == startIP
+}
+
+// SmallestNetwork returns the smaller (more specific) of two overlapping network prefixes.
+func SmallestNetwork(a, b netip.Prefix) netip.Prefix {
+	// The network with more bits (longer prefix length) is more specific
+	if a.Bits() >= b.Bits() {
+		return a
+	}
+	return b
+}
+
+// RangeSize returns the number of addresses from start to end, inclusive:
+// the length of the range. Both addresses must belong to the same IP family.
+func RangeSize(start, end netip.Addr) *big.Int {
+	size := new(big.Int).SetBytes(end.AsSlice())
+	size.Sub(size, new(big.Int).SetBytes(start.AsSlice()))
+	return size.Add(size, big.NewInt(1))
+}
+
+// PrefixSize returns the number of addresses in prefix.
+func PrefixSize(prefix netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits()))
+}
+
+// PrefixRange returns the first and last addresses of prefix. Host bits of
+// the prefix address are ignored, so 192.0.2.1/24 spans 192.0.2.0 to
+// 192.0.2.255.
+func PrefixRange(prefix netip.Prefix) (start, end netip.Addr) {
+	prefix = prefix.Masked()
+	return prefix.Addr(), netipx.PrefixLastIP(prefix)
+}
+
+// RangeToPrefixes returns the fewest CIDR prefixes that exactly cover the
+// range from start to end, inclusive, in ascending order. It returns nil if
+// the addresses are of different IP families or end is before start.
+func RangeToPrefixes(start, end netip.Addr) []netip.Prefix {
+	return netipx.IPRangeFrom(start, end).Prefixes()
+}
+
+// ContainsPrefix reports whether every address of inner is in outer. Prefixes
+// of different IP families never contain each other.
+func ContainsPrefix(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+// RangeContainsPrefix reports whether every address of prefix is in the range
+// from start to end, inclusive.
+func RangeContainsPrefix(start, end netip.Addr, prefix netip.Prefix) bool {
+	first, last := PrefixRange(prefix)
+	return start.Is4() == first.Is4() && start.Compare(first) <= 0 && last.Compare(end) <= 0
+}
+
+// MapToIPv6 returns an IPv4 address as an IPv4-mapped IPv6 address
+// (::ffff:192.0.2.1), for outputs whose address columns hold both IP
+// families. IPv6 addresses are returned unchanged; netip.Addr.Unmap reverses
+// the mapping.
+func MapToIPv6(addr netip.Addr) netip.Addr {
+	return netip.AddrFrom16(addr.As16())
+}
+
+// AddrToUint128 returns an address as a 128-bit unsigned integer, hi<<64 |
+// lo. An IPv4 address is its 32-bit integer, as IPv4ToUint32 returns, rather
+// than the integer of its IPv4-mapped form.
+func AddrToUint128(addr netip.Addr) (hi, lo uint64) {
+	if addr.Is4() {
+		return 0, uint64(IPv4ToUint32(addr))
+	}
+	b := addr.As16()
+	return binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+}
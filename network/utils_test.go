@@ -0,0 +1,287 @@
+package network
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPv4ToUint32(t *testing.T) {
+	tests := []struct {
+		name     string
+		ip       string
+		expected uint32
+	}{
+		{
+			name:     "zero address",
+			ip:       "0.0.0.0",
+			expected: 0,
+		},
+		{
+			name:     "simple address",
+			ip:       "192.168.1.1",
+			expected: 3232235777,
+		},
+		{
+			name:     "max address",
+			ip:       "255.255.255.255",
+			expected: 4294967295,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip := netip.MustParseAddr(tt.ip)
+			result := IPv4ToUint32(ip)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestIsAdjacent(t *testing.T) {
+	tests := []struct {
+		name     string
+		endIP    string
+		startIP  string
+		expected bool
+	}{
+		{
+			name:     "IPv4 adjacent",
+			endIP:    "192.168.1.1",
+			startIP:  "192.168.1.2",
+			expected: true,
+		},
+		{
+			name:     "IPv4 not adjacent",
+			endIP:    "192.168.1.1",
+			startIP:  "192.168.1.3",
+			expected: false,
+		},
+		{
+			name:     "IPv4 same address",
+			endIP:    "192.168.1.1",
+			startIP:  "192.168.1.1",
+			expected: false,
+		},
+		{
+			name:     "IPv6 adjacent",
+			endIP:    "2001:db8::1",
+			startIP:  "2001:db8::2",
+			expected: true,
+		},
+		{
+			name:     "IPv6 not adjacent",
+			endIP:    "2001:db8::1",
+			startIP:  "2001:db8::3",
+			expected: false,
+		},
+		{
+			name:     "IPv4 and IPv6 mix",
+			endIP:    "192.168.1.1",
+			startIP:  "2001:db8::1",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endIP := netip.MustParseAddr(tt.endIP)
+			startIP := netip.MustParseAddr(tt.startIP)
+			result := IsAdjacent(endIP, startIP)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestSmallestNetwork(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			name:     "/24 is smaller than /16",
+			a:        "10.0.0.0/16",
+			b:        "10.0.1.0/24",
+			expected: "10.0.1.0/24",
+		},
+		{
+			name:     "/32 is smallest",
+			a:        "10.0.0.0/24",
+			b:        "10.0.0.1/32",
+			expected: "10.0.0.1/32",
+		},
+		{
+			name:     "equal prefixes",
+			a:        "10.0.0.0/24",
+			b:        "10.0.1.0/24",
+			expected: "10.0.0.0/24",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := netip.MustParsePrefix(tt.a)
+			b := netip.MustParsePrefix(tt.b)
+			result := SmallestNetwork(a, b)
+			expected := netip.MustParsePrefix(tt.expected)
+			assert.Equal(t, expected, result)
+		})
+	}
+}
+
+func TestRangeSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		expected string
+	}{
+		{
+			name:     "single address",
+			start:    "10.0.0.1",
+			end:      "10.0.0.1",
+			expected: "1",
+		},
+		{
+			name:     "IPv4 /24",
+			start:    "10.0.0.0",
+			end:      "10.0.0.255",
+			expected: "256",
+		},
+		{
+			name:     "entire IPv4 space",
+			start:    "0.0.0.0",
+			end:      "255.255.255.255",
+			expected: "4294967296",
+		},
+		{
+			name:     "entire IPv6 space",
+			start:    "::",
+			end:      "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			expected: "340282366920938463463374607431768211456",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := RangeSize(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end))
+			assert.Equal(t, tt.expected, size.String())
+		})
+	}
+}
+
+func TestPrefixSize(t *testing.T) {
+	assert.Equal(t, "256", PrefixSize(netip.MustParsePrefix("10.0.0.0/24")).String())
+	assert.Equal(t, "1", PrefixSize(netip.MustParsePrefix("10.0.0.1/32")).String())
+	assert.Equal(t, "18446744073709551616", PrefixSize(netip.MustParsePrefix("2001:db8::/64")).String())
+}
+
+func TestPrefixRange(t *testing.T) {
+	start, end := PrefixRange(netip.MustParsePrefix("192.0.2.1/24"))
+	assert.Equal(t, netip.MustParseAddr("192.0.2.0"), start)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.255"), end)
+
+	start, end = PrefixRange(netip.MustParsePrefix("2001:db8::/32"))
+	assert.Equal(t, netip.MustParseAddr("2001:db8::"), start)
+	assert.Equal(t, netip.MustParseAddr("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"), end)
+}
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		expected []string
+	}{
+		{
+			name:     "single prefix",
+			start:    "10.0.0.0",
+			end:      "10.0.0.255",
+			expected: []string{"10.0.0.0/24"},
+		},
+		{
+			name:     "unaligned range",
+			start:    "10.0.0.255",
+			end:      "10.0.2.0",
+			expected: []string{"10.0.0.255/32", "10.0.1.0/24", "10.0.2.0/32"},
+		},
+		{
+			name:     "IPv6 range",
+			start:    "2001:db8::",
+			end:      "2001:db8::2",
+			expected: []string{"2001:db8::/127", "2001:db8::2/128"},
+		},
+		{
+			name:  "end before start",
+			start: "10.0.0.1",
+			end:   "10.0.0.0",
+		},
+		{
+			name:  "mixed IP families",
+			start: "10.0.0.0",
+			end:   "2001:db8::",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prefixes []string
+			for _, prefix := range RangeToPrefixes(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end)) {
+				prefixes = append(prefixes, prefix.String())
+			}
+			assert.Equal(t, tt.expected, prefixes)
+		})
+	}
+}
+
+func TestContainsPrefix(t *testing.T) {
+	tests := []struct {
+		outer    string
+		inner    string
+		expected bool
+	}{
+		{outer: "10.0.0.0/8", inner: "10.1.0.0/16", expected: true},
+		{outer: "10.0.0.0/8", inner: "10.0.0.0/8", expected: true},
+		{outer: "10.1.0.0/16", inner: "10.0.0.0/8", expected: false},
+		{outer: "10.0.0.0/8", inner: "11.0.0.0/16", expected: false},
+		{outer: "::/0", inner: "10.0.0.0/8", expected: false},
+		{outer: "2001:db8::/32", inner: "2001:db8:1::/48", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.outer+" "+tt.inner, func(t *testing.T) {
+			result := ContainsPrefix(netip.MustParsePrefix(tt.outer), netip.MustParsePrefix(tt.inner))
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestRangeContainsPrefix(t *testing.T) {
+	start := netip.MustParseAddr("10.0.0.128")
+	end := netip.MustParseAddr("10.0.2.255")
+
+	assert.True(t, RangeContainsPrefix(start, end, netip.MustParsePrefix("10.0.1.0/24")))
+	assert.True(t, RangeContainsPrefix(start, end, netip.MustParsePrefix("10.0.0.128/25")))
+	assert.False(t, RangeContainsPrefix(start, end, netip.MustParsePrefix("10.0.0.0/24")))
+	assert.False(t, RangeContainsPrefix(start, end, netip.MustParsePrefix("10.0.2.0/23")))
+	assert.False(t, RangeContainsPrefix(start, end, netip.MustParsePrefix("::/0")))
+}
+
+func TestMapToIPv6(t *testing.T) {
+	assert.Equal(t, netip.MustParseAddr("::ffff:192.0.2.1"), MapToIPv6(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), MapToIPv6(netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), MapToIPv6(netip.MustParseAddr("192.0.2.1")).Unmap())
+}
+
+func TestAddrToUint128(t *testing.T) {
+	hi, lo := AddrToUint128(netip.MustParseAddr("192.168.1.1"))
+	assert.Equal(t, uint64(0), hi)
+	assert.Equal(t, uint64(3232235777), lo)
+
+	hi, lo = AddrToUint128(netip.MustParseAddr("2001:db8::1"))
+	assert.Equal(t, uint64(0x20010db800000000), hi)
+	assert.Equal(t, uint64(1), lo)
+}